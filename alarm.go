@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	defaultAlarmVolume = 70
+	defaultAlarmRamp   = 5 * time.Minute
+)
+
+// parseClock parses a time of day, such as "7:30" or "7am", returning it
+// as a time on January 1, year 0.
+func parseClock(s string) (time.Time, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, layout := range clockLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time of day %q: use a form like 7:30, 19:30, or 7:30am", s)
+}
+
+// nextAlarmTime returns the first time after now that is at the time of
+// day of clock, in now's location. If weekdays is true, Saturdays and
+// Sundays are skipped.
+func nextAlarmTime(now, clock time.Time, weekdays bool) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	for weekdays && (next.Weekday() == time.Saturday || next.Weekday() == time.Sunday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// runAlarm waits until a time of day, then plays the stream, raising the
+// volume gradually so as not to wake the listener with a start.
+func runAlarm(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs       = flag.NewFlagSet("alarm", flag.ExitOnError)
+		weekdays = fs.Bool("weekdays", false, "Only go off Monday through Friday")
+		volume   = fs.Int("volume", defaultAlarmVolume, "Volume to ramp up to, from 0 to 100")
+		ramp     = fs.Duration("ramp", defaultAlarmRamp, "How long to take to reach full volume")
+		interval = fs.Duration("interval", 15*time.Second, "How often to check the station for a new track")
+	)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: ph alarm [flags] <time of day>")
+	}
+	if *volume < 0 || *volume > 100 {
+		return fmt.Errorf("invalid volume %d: must be from 0 to 100", *volume)
+	}
+	clock, err := parseClock(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	argv, err := cfg.Play.playerCommand(cfg.Play.streamURL(cfg.station()))
+	if err != nil {
+		return err
+	}
+	p := newPlayer(argv)
+
+	var started func()
+	if p.canChangeVolume() && *ramp > 0 {
+		p.setVolume(0)
+		started = func() { rampVolume(p, 0, *volume, *ramp) }
+	} else {
+		if *ramp > 0 {
+			warn(fmt.Errorf("%s cannot change volume while playing, so the volume will not ramp up", p.name))
+		}
+		p.setVolume(*volume)
+	}
+
+	at := nextAlarmTime(time.Now(), clock, *weekdays)
+	fmt.Printf("Alarm set for %s; press Ctrl-C to cancel\n", at.Format("Mon 2-Jan-2006 15:04"))
+	time.Sleep(time.Until(at))
+	return playStream(cfg, p, *interval, started, 0)
+}
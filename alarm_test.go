@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextAlarmTime(t *testing.T) {
+	// June 7, 2024 was a Friday.
+	var (
+		loc    = time.FixedZone("PDT", -7*60*60)
+		friday = time.Date(2024, 6, 7, 6, 0, 0, 0, loc)
+	)
+	tt := []struct {
+		desc     string
+		now      time.Time
+		clock    string
+		weekdays bool
+		want     time.Time
+	}{
+		{desc: "later today", now: friday, clock: "7:30", want: time.Date(2024, 6, 7, 7, 30, 0, 0, loc)},
+		{desc: "tomorrow", now: friday, clock: "5:45am", want: time.Date(2024, 6, 8, 5, 45, 0, 0, loc)},
+		{desc: "weekday later today", now: friday, clock: "7:30", weekdays: true, want: time.Date(2024, 6, 7, 7, 30, 0, 0, loc)},
+		{desc: "weekday skips weekend", now: friday, clock: "5:45", weekdays: true, want: time.Date(2024, 6, 10, 5, 45, 0, 0, loc)},
+		{desc: "exactly now", now: friday, clock: "6:00", want: time.Date(2024, 6, 8, 6, 0, 0, 0, loc)},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			clock, err := parseClock(tc.clock)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := nextAlarmTime(tc.now, clock, tc.weekdays); !got.Equal(tc.want) {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseClockInvalid(t *testing.T) {
+	for _, s := range []string{"", "noon", "25:00", "2024-06-07"} {
+		if _, err := parseClock(s); err == nil {
+			t.Errorf("wanted an error for %q, but got none", s)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const alertsFile = "alerts.json"
+
+// showAlert asks to be told when a track from a show, or of a song, airs.
+// Every criterion given must match; those left empty match any track.
+type showAlert struct {
+	Artist string    `json:"artist,omitempty"`
+	Date   time.Time `json:"date,omitempty"`
+	Song   string    `json:"song,omitempty"`
+	Added  time.Time `json:"added"`
+
+	// Aired is when a matching track last started airing.
+	Aired time.Time `json:"aired,omitempty"`
+}
+
+func (a showAlert) String() string {
+	var parts []string
+	if a.Artist != "" {
+		parts = append(parts, a.Artist)
+	}
+	if a.Song != "" {
+		parts = append(parts, fmt.Sprintf("%q", a.Song))
+	}
+	if !a.Date.IsZero() {
+		parts = append(parts, a.Date.Format("Mon 2-Jan-2006"))
+	}
+	return strings.Join(parts, " - ")
+}
+
+// matches returns a predicate that selects the tracks a matches.
+func (a showAlert) matches() func(Track) bool {
+	var preds []func(Track) bool
+	if a.Artist != "" {
+		preds = append(preds, ByArtist(a.Artist))
+	}
+	if !a.Date.IsZero() {
+		preds = append(preds, ByPerformanceDate(a.Date))
+	}
+	if a.Song != "" {
+		preds = append(preds, ByTitleRegex(regexp.MustCompile(`(?i)^`+regexp.QuoteMeta(a.Song)+`$`)))
+	}
+	return All(preds...)
+}
+
+func (s *store) alerts() ([]showAlert, error) {
+	var alerts []showAlert
+	err := s.readJSON(alertsFile, &alerts)
+	return alerts, err
+}
+
+func (s *store) saveAlerts(alerts []showAlert) error {
+	return s.writeJSON(alertsFile, alerts)
+}
+
+// alertAdd adds an alert, unless there is already one for the same
+// artist, date, and song. It reports whether the alert was added.
+func (s *store) alertAdd(a showAlert) (bool, error) {
+	alerts, err := s.alerts()
+	if err != nil {
+		return false, err
+	}
+	for _, existing := range alerts {
+		if strings.EqualFold(existing.Artist, a.Artist) && existing.Date.Equal(a.Date) && strings.EqualFold(existing.Song, a.Song) {
+			return false, nil
+		}
+	}
+	return true, s.saveAlerts(append(alerts, a))
+}
+
+// alertRemove removes the nth (starting at 1) alert, and returns it.
+func (s *store) alertRemove(n int) (showAlert, error) {
+	alerts, err := s.alerts()
+	if err != nil {
+		return showAlert{}, err
+	}
+	if n < 1 || n > len(alerts) {
+		return showAlert{}, fmt.Errorf(tr("no alert number %d"), n)
+	}
+	a := alerts[n-1]
+	return a, s.saveAlerts(append(alerts[:n-1], alerts[n:]...))
+}
+
+// alertsAired finds the alerts that t matches, records that they aired at
+// t's start time, and returns those to notify about. t is from a show whose
+// airing started at airing, unless airing is zero. An alert for a show,
+// rather than a song, is notified about once an airing: for the first of
+// the show's tracks to match it, and not again after a station break.
+func (s *store) alertsAired(t Track, airing time.Time) ([]showAlert, error) {
+	alerts, err := s.alerts()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		matched []showAlert
+		aired   bool
+	)
+	for i, a := range alerts {
+		if !a.matches()(t) {
+			continue
+		}
+		aired = true
+		alerts[i].Aired = t.StartTime
+		if a.Song == "" && !airing.IsZero() && !a.Aired.IsZero() && !a.Aired.Before(airing) {
+			continue
+		}
+		matched = append(matched, alerts[i])
+	}
+	if !aired {
+		return nil, nil
+	}
+	return matched, s.saveAlerts(alerts)
+}
+
+// showAlerter notifies the user each time a track that matches one of
+// their alerts starts airing, or for an alert for a show, each time the
+// show airs, as it observes station statuses. The alerts
+// are read from the store as each track starts, so that alerts added or
+// removed while ph serve runs take effect.
+type showAlerter struct {
+	store *store
+
+	// notifiers deliver the alerts; mu guards them, since they change when
+	// the config is reloaded.
+	mu        sync.Mutex
+	notifiers []notifier
+
+	// seen is the state of the station last seen, which is saved in
+	// snapshots.
+	seen lastSeen
+
+	// airing follows the show being aired, so that an alert for it is sent
+	// once however often the station breaks into it.
+	airing showAiringTracker
+
+	// cfg gives the links sent with each track.
+	cfg config
+}
+
+// run sends alerts for the statuses published on the bus, until the bus is
+// closed. Unless the state was restored from a snapshot, the first status
+// only establishes what is airing, so that restarting does not repeat an
+// alert. Errors are reported to onError.
+func (a *showAlerter) run(events <-chan interface{}, onError func(error)) {
+	for e := range events {
+		fetched, ok := e.(statusFetched)
+		if !ok {
+			continue
+		}
+		a.mu.Lock()
+		notifiers := a.notifiers
+		a.mu.Unlock()
+		show, started := a.airing.observe(fetched.Status, fetched.Time)
+		for _, change := range a.seen.observe(fetched.Status, fetched.Time) {
+			// Without notifiers, alerts are not marked aired, so they are
+			// not missed once there are some.
+			if change.Type != eventTrackStarted || len(notifiers) == 0 {
+				continue
+			}
+			t := Track(*change.Track)
+			var airing time.Time
+			if showKey(t) == show {
+				airing = started
+			}
+			matched, err := a.store.alertsAired(t, airing)
+			if err != nil {
+				onError(err)
+			}
+			if len(matched) == 0 {
+				continue
+			}
+			if err := sendNotification(notifiers, trackNotification(t, a.cfg)); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// setNotifiers changes the notifiers that deliver the alerts.
+func (a *showAlerter) setNotifiers(ns []notifier) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.notifiers = ns
+}
+
+func runAlert(args []string) error {
+	const usage = "usage: ph alert add|list|remove"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "add":
+		return runAlertAdd(st, args[1:])
+	case "list", "ls":
+		return runAlertList(st)
+	case "remove", "rm":
+		if len(args) != 2 {
+			return errors.New("usage: ph alert remove <number>")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid alert number %q", args[1])
+		}
+		a, err := st.alertRemove(n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf(tr("Removed the alert for %s.")+"\n", a)
+		return nil
+	default:
+		return errors.New(usage)
+	}
+}
+
+func runAlertAdd(st *store, args []string) error {
+	var (
+		fs      = flag.NewFlagSet("alert add", flag.ExitOnError)
+		artist  = fs.String("artist", "", "Only alert for tracks by this artist")
+		dateStr = fs.String("date", "", "Alert when a track from the show on this date (YYYY-MM-DD) airs")
+		song    = fs.String("song", "", "Alert when this song airs")
+	)
+	fs.Parse(args)
+
+	a := showAlert{Artist: *artist, Song: *song, Added: time.Now()}
+	if *dateStr != "" {
+		d, err := time.Parse("2006-01-02", *dateStr)
+		if err != nil {
+			return fmt.Errorf("invalid date %q: %w", *dateStr, err)
+		}
+		a.Date = d
+	}
+	if a.Artist == "" && a.Date.IsZero() && a.Song == "" {
+		return errors.New(tr("use --date, --artist, or --song to choose what to be alerted about"))
+	}
+	added, err := st.alertAdd(a)
+	if err != nil {
+		return err
+	}
+	if !added {
+		fmt.Printf(tr("There is already an alert for %s.")+"\n", a)
+		return nil
+	}
+	fmt.Printf(tr("Added an alert for %s.")+"\n", a)
+	return nil
+}
+
+func runAlertList(st *store) error {
+	alerts, err := st.alerts()
+	if err != nil {
+		return err
+	}
+	if len(alerts) == 0 {
+		fmt.Println(tr("There are no alerts."))
+		return nil
+	}
+	for i, a := range alerts {
+		fmt.Printf("%3d. %s", i+1, a)
+		if !a.Aired.IsZero() {
+			fmt.Printf(" ("+tr("last aired %s")+")", a.Aired.Local().Format("Mon 2-Jan-2006 15:04"))
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore_Alerts(t *testing.T) {
+	st := newTestStore(t)
+	alerts := []showAlert{
+		{Date: mustParseDate("1997-11-17")},
+		{Artist: "Grateful Dead", Song: "Scarlet Begonias"},
+		{Artist: "Phish", Date: mustParseDate("1995-12-31")},
+	}
+	for _, a := range alerts {
+		added, err := st.alertAdd(a)
+		if err != nil {
+			t.Fatalf("unexpected error adding %s: %v", a, err)
+		}
+		if !added {
+			t.Fatalf("wanted %s to be added", a)
+		}
+	}
+	if added, _ := st.alertAdd(showAlert{Artist: "grateful dead", Song: "scarlet begonias"}); added {
+		t.Errorf("wanted duplicate alert not to be added")
+	}
+
+	removed, err := st.alertRemove(2)
+	if err != nil {
+		t.Fatalf("unexpected error removing alert: %v", err)
+	}
+	if removed.Song != "Scarlet Begonias" {
+		t.Errorf("wanted Scarlet Begonias alert removed, but got %s", removed)
+	}
+	if _, err := st.alertRemove(3); err == nil {
+		t.Errorf("wanted error removing nonexistent alert")
+	}
+	got, err := st.alerts()
+	if err != nil {
+		t.Fatalf("unexpected error listing alerts: %v", err)
+	}
+	if len(got) != 2 || !got[1].Date.Equal(alerts[2].Date) {
+		t.Errorf("wanted alerts %v and %v left, but got %v", alerts[0], alerts[2], got)
+	}
+}
+
+func TestShowAlerter(t *testing.T) {
+	st := newTestStore(t)
+	for _, a := range []showAlert{
+		{Date: mustParseDate("1997-11-17")},
+		{Artist: "Goose", Song: "arcadia"},
+	} {
+		if _, err := st.alertAdd(a); err != nil {
+			t.Fatalf("unexpected error adding %s: %v", a, err)
+		}
+	}
+	var (
+		sent   recordingNotifier
+		a      = &showAlerter{store: st, notifiers: []notifier{&sent}}
+		events = make(chan interface{}, 5)
+		at     = func(clock string) time.Time { return mustParseDate("2024-06-01T" + clock) }
+		status = func(t Track) statusFetched {
+			return statusFetched{Status: statusResponseBody{Status: "online", CurrentTrack: t}, Time: t.StartTime}
+		}
+		mike = Track{Artist: "Phish", Title: "Mike's Song", PerformanceTime: mustParseDate("1997-11-17"), StartTime: at("20:00:00")}
+	)
+	// The first status only establishes what is airing, so Mike's Song,
+	// already airing when ph serve starts, is not announced.
+	events <- status(mike)
+	events <- status(Track{Artist: "Goose", Title: "Arcadia", StartTime: at("20:10:00")})
+	events <- status(Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22"), StartTime: at("20:20:00")})
+	events <- status(Track{Artist: "Phish", Title: "Weekapaug Groove", PerformanceTime: mustParseDate("1997-11-17"), StartTime: at("20:30:00")})
+	close(events)
+	a.run(events, func(err error) { t.Errorf("unexpected error: %v", err) })
+
+	want := []string{"JEMP Radio: Goose - Arcadia", "JEMP Radio: Phish - Weekapaug Groove (Mon 17-Nov-1997)"}
+	if len(sent) != len(want) {
+		t.Fatalf("wanted alerts %q, but got %v", want, sent)
+	}
+	for i, n := range sent {
+		if n.Subject != want[i] {
+			t.Errorf("alert %d: wanted %q, but got %q", i, want[i], n.Subject)
+		}
+	}
+	alerts, err := st.alerts()
+	if err != nil {
+		t.Fatalf("unexpected error listing alerts: %v", err)
+	}
+	if !alerts[0].Aired.Equal(at("20:30:00")) {
+		t.Errorf("wanted the date alert to have last aired at 20:30, but got %v", alerts[0].Aired)
+	}
+}
+
+func TestShowAlerter_AcrossBreaks(t *testing.T) {
+	st := newTestStore(t)
+	if _, err := st.alertAdd(showAlert{Date: mustParseDate("1997-11-17")}); err != nil {
+		t.Fatalf("unexpected error adding alert: %v", err)
+	}
+	var (
+		sent    recordingNotifier
+		a       = &showAlerter{store: st, notifiers: []notifier{&sent}}
+		events  = make(chan interface{}, 6)
+		history TrackList
+		at      = func(clock string) time.Time { return mustParseDate("2024-06-01T" + clock) }
+		denver  = mustParseDate("1997-11-17")
+		status  = func(t Track) statusFetched {
+			history = append(TrackList{t}, history...)
+			return statusFetched{Status: statusResponseBody{Status: "online", CurrentTrack: t, History: history}, Time: t.StartTime}
+		}
+	)
+	events <- status(Track{Artist: "Goose", Title: "Arcadia", StartTime: at("19:50:00")})
+	events <- status(Track{Artist: "Phish", Title: "17-Nov-1997 Set 1", Set: &ShowSet{Number: 1}, PerformanceTime: denver, StartTime: at("20:00:00")})
+	events <- status(Track{Artist: "jempradio.com", Title: "Station ID", StartTime: at("21:15:00")})
+	events <- status(Track{Artist: "Phish", Title: "17-Nov-1997 Set 2", Set: &ShowSet{Number: 2}, PerformanceTime: denver, StartTime: at("21:20:00")})
+	events <- status(Track{Artist: "Goose", Title: "Arcadia", StartTime: at("22:40:00")})
+	events <- status(Track{Artist: "Phish", Title: "Ghost", PerformanceTime: denver, StartTime: at("22:50:00")})
+	close(events)
+	a.run(events, func(err error) { t.Errorf("unexpected error: %v", err) })
+
+	// The second set is part of the airing the alert was sent for, but the
+	// show airing again after something else is not.
+	want := []string{"JEMP Radio: Phish - 17-Nov-1997 Set 1 (Mon 17-Nov-1997)", "JEMP Radio: Phish - Ghost (Mon 17-Nov-1997)"}
+	if len(sent) != len(want) {
+		t.Fatalf("wanted alerts %q, but got %v", want, sent)
+	}
+	for i, n := range sent {
+		if n.Subject != want[i] {
+			t.Errorf("alert %d: wanted %q, but got %q", i, want[i], n.Subject)
+		}
+	}
+}
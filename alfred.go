@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// alfredOutput is the JSON an Alfred Script Filter reads: a list of items
+// to choose from. See https://www.alfredapp.com/help/workflows/inputs/script-filter/json/.
+type alfredOutput struct {
+	Items []alfredItem `json:"items"`
+}
+
+// alfredItem is a choice in Alfred. Arg is what the workflow is given when
+// it is chosen, here a URL to open, and Valid is false for items that have
+// nothing to open.
+type alfredItem struct {
+	Title    string                    `json:"title"`
+	Subtitle string                    `json:"subtitle,omitempty"`
+	Arg      string                    `json:"arg,omitempty"`
+	Valid    bool                      `json:"valid"`
+	Text     *alfredText               `json:"text,omitempty"`
+	Mods     map[string]alfredModifier `json:"mods,omitempty"`
+}
+
+// alfredText is what Alfred copies, and shows in large type, for an item.
+type alfredText struct {
+	Copy      string `json:"copy,omitempty"`
+	LargeType string `json:"largetype,omitempty"`
+}
+
+// alfredModifier is what an item does when chosen with a modifier key held.
+type alfredModifier struct {
+	Subtitle string `json:"subtitle"`
+	Arg      string `json:"arg"`
+	Valid    bool   `json:"valid"`
+}
+
+// newAlfredItem makes an item for a track, which opens its show's stream,
+// or failing that its setlist, and with ⌘ held, its setlist. The item is
+// described with the output settings of cfg.
+func newAlfredItem(t Track, cfg config) alfredItem {
+	summary := t.Title
+	if t.Artist != "" {
+		summary = t.Artist + " - " + summary
+	}
+	item := alfredItem{
+		Title: summary,
+		Text:  &alfredText{Copy: summary, LargeType: summary},
+	}
+	var details []string
+	if pt := t.PerformanceTime; !pt.IsZero() {
+		details = append(details, pt.Format("Mon 2-Jan-2006"))
+	}
+	if t.Location != "" {
+		details = append(details, t.Location)
+	}
+	if elapsed := t.Elapsed(); elapsed != 0 {
+		details = append(details, cfg.elapsedFormat().started(elapsed))
+	}
+	item.Subtitle = strings.Join(details, " · ")
+
+	links := t.Links(cfg, relistenArtists)
+	stream, setlist := linkURL(links, linkKindStream), linkURL(links, linkKindSetlist)
+	item.Arg = stream
+	if item.Arg == "" {
+		item.Arg = setlist
+	}
+	item.Valid = item.Arg != ""
+	if stream != "" && setlist != "" {
+		item.Mods = map[string]alfredModifier{
+			"cmd": {Subtitle: tr("Open the setlist"), Arg: setlist, Valid: true},
+		}
+	}
+	return item
+}
+
+// writeAlfred writes the tracks in v, which may be a track, a list of
+// them, or a value holding tracks, such as the view of ph now, as Alfred
+// Script Filter JSON, an item for each track in order.
+func writeAlfred(w io.Writer, v interface{}, cfg config) error {
+	var out alfredOutput
+	for _, t := range collectTracks(reflect.ValueOf(v)) {
+		out.Items = append(out.Items, newAlfredItem(t, cfg))
+	}
+	if len(out.Items) == 0 {
+		out.Items = []alfredItem{{Title: tr("Nothing to show.")}}
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// collectTracks finds the tracks in v, in order, looking through pointers,
+// lists, and the exported fields of structs.
+func collectTracks(v reflect.Value) []Track {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	var tracks []Track
+	switch {
+	case v.Kind() == reflect.Struct && v.Type().ConvertibleTo(trackType):
+		tracks = append(tracks, v.Convert(trackType).Interface().(Track))
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			tracks = append(tracks, collectTracks(v.Index(i))...)
+		}
+	case v.Kind() == reflect.Struct && v.Type() != timeType:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				tracks = append(tracks, collectTracks(v.Field(i))...)
+			}
+		}
+	}
+	return tracks
+}
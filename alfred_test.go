@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteAlfred(t *testing.T) {
+	relistenArtists = goldenRelistenArtists(t)
+	var (
+		reba = Track{
+			Artist:          "Phish",
+			Title:           "Reba",
+			PerformanceTime: mustParseDate("1997-11-22"),
+			Location:        "Hampton, VA",
+		}
+		rebaItem = alfredItem{
+			Title:    "Phish - Reba",
+			Subtitle: "Sat 22-Nov-1997 · Hampton, VA",
+			Arg:      "https://relisten.net/phish/1997/11/22",
+			Valid:    true,
+			Text:     &alfredText{Copy: "Phish - Reba", LargeType: "Phish - Reba"},
+			Mods: map[string]alfredModifier{
+				"cmd": {Subtitle: "Open the setlist", Arg: "https://phish.net/setlists/?d=1997-11-22", Valid: true},
+			},
+		}
+		crossroads     = Track{Artist: "Cream", Title: "Crossroads"}
+		crossroadsItem = alfredItem{
+			Title: "Cream - Crossroads",
+			Text:  &alfredText{Copy: "Cream - Crossroads", LargeType: "Cream - Crossroads"},
+		}
+	)
+	tt := []struct {
+		desc string
+		v    interface{}
+		want []alfredItem
+	}{
+		{desc: "track", v: reba, want: []alfredItem{rebaItem}},
+		{desc: "track list", v: TrackList{reba, crossroads}, want: []alfredItem{rebaItem, crossroadsItem}},
+		{desc: "now view", v: nowView{Playing: &crossroads, Recent: TrackList{reba}}, want: []alfredItem{crossroadsItem, rebaItem}},
+		{desc: "nothing", v: TrackList{}, want: []alfredItem{{Title: "Nothing to show."}}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeAlfred(&b, tc.v, config{}); err != nil {
+				t.Fatal(err)
+			}
+			var got alfredOutput
+			if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+				t.Fatalf("unable to decode output: %v", err)
+			}
+			if !reflect.DeepEqual(got.Items, tc.want) {
+				t.Errorf("wanted %+v, but got %+v", tc.want, got.Items)
+			}
+		})
+	}
+}
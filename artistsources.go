@@ -0,0 +1,142 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// artistSourcesFile is the file, in the config directory, listing sources
+// of artists in addition to, or instead of, those ph knows of.
+const artistSourcesFile = "artists.yaml"
+
+// artistSource says where the shows of an artist are found when the
+// station names the artist differently than the services that carry its
+// shows do, or the shows are somewhere ph does not otherwise link to.
+type artistSource struct {
+	// Names are what the station calls the artist.
+	Names []string `yaml:"names"`
+
+	// Relisten are the artist's names on Relisten, in order of preference.
+	Relisten []string `yaml:"relisten,omitempty"`
+
+	// ArchiveCollection is the archive.org collection of the artist's
+	// shows, searched instead of the shows the artist created.
+	ArchiveCollection string `yaml:"archive_collection,omitempty"`
+
+	// LivePhish is whether LivePhish sells the artist's shows.
+	LivePhish bool `yaml:"livephish,omitempty"`
+
+	// Nugs is whether nugs.net sells the artist's shows.
+	Nugs bool `yaml:"nugs,omitempty"`
+
+	// Setlist is the name of the provider of the setlists of the artist's
+	// shows, or empty for the default provider.
+	Setlist string `yaml:"setlist,omitempty"`
+}
+
+// defaultArtistSources are the sources of the artists ph knows of, in the
+// format of artistSourcesFile.
+//
+//go:embed defaults/artists.yaml
+var defaultArtistSources []byte
+
+// builtinArtistSources are the sources of defaultArtistSources, parsed.
+var builtinArtistSources = mustParseArtistSources(defaultArtistSources)
+
+// parseArtistSources parses a list of artist sources, as in
+// artistSourcesFile.
+func parseArtistSources(b []byte) ([]artistSource, error) {
+	var sources []artistSource
+	if err := yaml.UnmarshalStrict(b, &sources); err != nil {
+		return nil, err
+	}
+	for i, s := range sources {
+		if len(s.Names) == 0 {
+			return nil, fmt.Errorf("artist source %d has no names", i+1)
+		}
+		if s.Setlist != "" {
+			if err := validateSetlistProvider(s.Setlist); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.Names[0], err)
+			}
+		}
+	}
+	return sources, nil
+}
+
+func mustParseArtistSources(b []byte) []artistSource {
+	sources, err := parseArtistSources(b)
+	if err != nil {
+		panic(err)
+	}
+	return sources
+}
+
+// loadArtistSources reads artistSourcesFile from dir, if it is there, and
+// returns its sources followed by defaults. An artist the file names is
+// found there before it is found in defaults, so the file can change the
+// sources of the artists ph knows of as well as add others.
+func loadArtistSources(dir string, defaults []artistSource) ([]artistSource, error) {
+	path := filepath.Join(dir, artistSourcesFile)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return defaults, nil
+	}
+	if err != nil {
+		return defaults, err
+	}
+	sources, err := parseArtistSources(b)
+	if err != nil {
+		return defaults, fmt.Errorf("parse artist sources %s: %w", path, err)
+	}
+	return append(sources, defaults...), nil
+}
+
+// artistSources returns the artists with sources of their own: those
+// readConfig loaded, or else the ones ph knows of.
+func (c config) artistSources() []artistSource {
+	if c.sources == nil {
+		return builtinArtistSources
+	}
+	return c.sources
+}
+
+// lookupArtistSource returns the source of the artist the station calls
+// artist, ignoring case, and reports whether it has one.
+func (c config) lookupArtistSource(artist string) (artistSource, bool) {
+	for _, s := range c.artistSources() {
+		for _, name := range s.Names {
+			if strings.EqualFold(name, artist) {
+				return s, true
+			}
+		}
+	}
+	return artistSource{}, false
+}
+
+// addRelistenAliases maps each name the station calls an artist of
+// artistSources to the artist's slug on Relisten, unless the name is
+// already the name of an artist on Relisten.
+func (c config) addRelistenAliases(artists map[string]string) {
+	for _, s := range c.artistSources() {
+		var slug string
+		for _, name := range s.Relisten {
+			if slug = artists[name]; slug != "" {
+				break
+			}
+		}
+		if slug == "" {
+			continue
+		}
+		for _, name := range s.Names {
+			if _, ok := artists[name]; !ok {
+				artists[name] = slug
+			}
+		}
+	}
+}
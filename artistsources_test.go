@@ -0,0 +1,161 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAddRelistenAliases(t *testing.T) {
+	tt := []struct {
+		desc    string
+		artists map[string]string
+		want    map[string]string
+	}{
+		{
+			desc:    "station names of an artist on Relisten",
+			artists: map[string]string{"Jerry Garcia Band": "jgb"},
+			want: map[string]string{
+				"Jerry Garcia Band": "jgb",
+				"JGB":               "jgb",
+				"Jerry Garcia":      "jgb",
+			},
+		},
+		{
+			desc:    "artist on Relisten under another name",
+			artists: map[string]string{"Trey Anastasio": "trey"},
+			want: map[string]string{
+				"Trey Anastasio":      "trey",
+				"Trey Anastasio Band": "trey",
+				"TAB":                 "trey",
+			},
+		},
+		{
+			desc:    "names already on Relisten are kept",
+			artists: map[string]string{"Jerry Garcia Band": "jgb", "Jerry Garcia": "jerry-garcia"},
+			want: map[string]string{
+				"Jerry Garcia Band": "jgb",
+				"JGB":               "jgb",
+				"Jerry Garcia":      "jerry-garcia",
+			},
+		},
+		{
+			desc:    "artists not on Relisten",
+			artists: map[string]string{"Grateful Dead": "grateful-dead"},
+			want:    map[string]string{"Grateful Dead": "grateful-dead"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			config{}.addRelistenAliases(tc.artists)
+			if !reflect.DeepEqual(tc.artists, tc.want) {
+				t.Errorf("wanted %v, but got %v", tc.want, tc.artists)
+			}
+		})
+	}
+}
+
+func TestLookupArtistSource(t *testing.T) {
+	tt := []struct {
+		artist     string
+		want       bool
+		collection string
+		livePhish  bool
+	}{
+		{artist: "Trey Anastasio Band", want: true, livePhish: true},
+		{artist: "tab", want: true, livePhish: true},
+		{artist: "JGB", want: true, collection: "JerryGarcia"},
+		{artist: "Phish", want: true, livePhish: true},
+		{artist: "Goose"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.artist, func(t *testing.T) {
+			s, ok := config{}.lookupArtistSource(tc.artist)
+			if ok != tc.want {
+				t.Fatalf("wanted ok %v, but got %v", tc.want, ok)
+			}
+			if s.ArchiveCollection != tc.collection {
+				t.Errorf("wanted collection %q, but got %q", tc.collection, s.ArchiveCollection)
+			}
+			if s.LivePhish != tc.livePhish {
+				t.Errorf("wanted livePhish %v, but got %v", tc.livePhish, s.LivePhish)
+			}
+		})
+	}
+}
+
+func TestDefaultArtistSources(t *testing.T) {
+	for _, artist := range []string{"Dead and Company", "Dead & Co", "Billy Strings"} {
+		s, ok := config{}.lookupArtistSource(artist)
+		if !ok {
+			t.Errorf("wanted a source for %s, but got none", artist)
+			continue
+		}
+		if !s.Nugs {
+			t.Errorf("wanted %s to be sold on nugs.net, but it is not", artist)
+		}
+	}
+}
+
+func TestLoadArtistSources(t *testing.T) {
+	defaults := mustParseArtistSources(defaultArtistSources)
+	tt := []struct {
+		desc    string
+		file    string
+		wantErr bool
+		wantLen int
+		want    artistSource
+	}{
+		{
+			desc:    "no file",
+			wantLen: len(defaults),
+			want:    defaults[0],
+		},
+		{
+			desc:    "file sources come first",
+			file:    "- names: [Goose]\n  relisten: [Goose]\n  nugs: true\n",
+			wantLen: len(defaults) + 1,
+			want:    artistSource{Names: []string{"Goose"}, Relisten: []string{"Goose"}, Nugs: true},
+		},
+		{
+			desc:    "source with no names",
+			file:    "- relisten: [Goose]\n",
+			wantErr: true,
+			wantLen: len(defaults),
+			want:    defaults[0],
+		},
+		{
+			desc:    "unknown field",
+			file:    "- names: [Goose]\n  bandcamp: true\n",
+			wantErr: true,
+			wantLen: len(defaults),
+			want:    defaults[0],
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ph-artists")
+			if err != nil {
+				t.Fatalf("unable to create config dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if tc.file != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, artistSourcesFile), []byte(tc.file), 0644); err != nil {
+					t.Fatalf("unable to write artist sources: %v", err)
+				}
+			}
+			got, err := loadArtistSources(dir, defaults)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("wanted error %v, but got %v", tc.wantErr, err)
+			}
+			if len(got) != tc.wantLen {
+				t.Fatalf("wanted %d sources, but got %d", tc.wantLen, len(got))
+			}
+			if !reflect.DeepEqual(got[0], tc.want) {
+				t.Errorf("wanted %+v, but got %+v", tc.want, got[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// atLayouts are the layouts accepted for the time given to ph at, in local
+// time.
+var atLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02 3pm",
+	"2006-01-02 3:04pm",
+}
+
+// clockLayouts are the layouts accepted for a time of day alone, which is
+// taken to mean its most recent occurrence.
+var clockLayouts = []string{"15:04", "15:04:05", "3pm", "3:04pm"}
+
+// parseAtTime parses the time given to ph at, relative to now. A time of
+// day alone, such as "21:00" or "9pm", means its most recent occurrence,
+// and may be preceded by "yesterday".
+func parseAtTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	// The layouts only accept am and pm in lower case, but the T between a
+	// date and time must be upper case.
+	lower := strings.ToLower(s)
+	for _, layout := range atLayouts {
+		for _, v := range []string{s, lower} {
+			if t, err := time.ParseInLocation(layout, v, now.Location()); err == nil {
+				return t, nil
+			}
+		}
+	}
+	s = lower
+	var daysAgo int
+	if strings.HasPrefix(s, "yesterday ") {
+		s, daysAgo = strings.TrimSpace(strings.TrimPrefix(s, "yesterday ")), 1
+	}
+	for _, layout := range clockLayouts {
+		tod, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		t := time.Date(now.Year(), now.Month(), now.Day(), tod.Hour(), tod.Minute(), tod.Second(), 0, now.Location())
+		if daysAgo > 0 {
+			return t.AddDate(0, 0, -daysAgo), nil
+		}
+		if t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf(tr("invalid time %q: use a date and time, such as \"2024-06-01 21:00\", or a time of day, such as 21:00 or 9pm"), s)
+}
+
+// playAt returns the play, in a play history in order of start time, that
+// was airing at t, and reports whether there was one. How long each play
+// lasted is inferred from the start of the play that followed it.
+func playAt(plays TrackList, t time.Time) (Track, bool) {
+	for i := len(plays) - 1; i >= 0; i-- {
+		start := plays[i].StartTime
+		if start.IsZero() || start.After(t) {
+			continue
+		}
+		if t.Before(start.Add(playLength(plays, i))) {
+			return plays[i], true
+		}
+		return Track{}, false
+	}
+	return Track{}, false
+}
+
+// runAt tells what was airing at a given time, from the play history, or
+// from the station if the time is after the last recorded play.
+func runAt(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("at", flag.ExitOnError)
+		format = fs.StringP("format", "f", cfg.format(), "Output format (text, json, yaml)")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ph at [flags] <time>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New(tr("no time given"))
+	}
+	now := time.Now()
+	at, err := parseAtTime(strings.Join(fs.Args(), " "), now)
+	if err != nil {
+		return err
+	}
+	if at.After(now) {
+		return errors.New(tr("that time has not happened yet"))
+	}
+	writeOutput, err := getRenderer(*format, useColor(cfg, os.Stdout), cfg)
+	if err != nil {
+		return err
+	}
+
+	var plays TrackList
+	if st, err := openStore(); err != nil {
+		warn(fmt.Errorf("play history unavailable: %w", err))
+	} else if plays, err = st.plays(); err != nil {
+		return fmt.Errorf("read play history: %w", err)
+	}
+	t, ok := playAt(plays, at)
+	// The play history only knows what was playing when ph checked, so the
+	// station is asked about times after the last recorded play.
+	if n := len(plays); !ok && (n == 0 || !plays[n-1].StartTime.After(at)) {
+		status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+		if err != nil {
+			warn(fmt.Errorf("unable to get station status: %w", err))
+		} else if st := status.CurrentTrack.StartTime; !st.IsZero() && !st.After(at) {
+			t, ok = status.CurrentTrack, true
+		}
+	}
+	if !ok {
+		if *format != "text" {
+			return writeOutput(nil)
+		}
+		return fmt.Errorf(tr("no record of what was airing at %s"), at.Format("Mon 2-Jan-2006 15:04"))
+	}
+	if cfg.Catalog.Autocorrect {
+		t = loadSongCatalog(context.Background(), apiClient(cfg), cfg).correct(t)
+	}
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	if *format != "text" {
+		return writeOutput(t)
+	}
+	fmt.Printf(tr("At %s: %s")+"\n", at.Format("Mon 2-Jan-2006 15:04"), trackSummary(t))
+	fmt.Printf(tr("Started %s, %s in")+"\n", t.StartTime.Local().Format("15:04"), cfg.elapsedFormat().elapsed(at.Sub(t.StartTime)))
+	for _, line := range linkLines(t.Links(cfg, relistenArtists)) {
+		fmt.Println(line)
+	}
+	return nil
+}
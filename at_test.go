@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAtTime(t *testing.T) {
+	var (
+		loc = time.FixedZone("PDT", -7*60*60)
+		now = time.Date(2024, 6, 2, 8, 30, 0, 0, loc)
+	)
+	tt := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2024-06-01 21:00", want: time.Date(2024, 6, 1, 21, 0, 0, 0, loc)},
+		{in: "2024-06-01T21:00:30", want: time.Date(2024, 6, 1, 21, 0, 30, 0, loc)},
+		{in: "2024-06-01 9PM", want: time.Date(2024, 6, 1, 21, 0, 0, 0, loc)},
+		{in: "2024-06-01T21:00:00-04:00", want: time.Date(2024, 6, 1, 18, 0, 0, 0, loc)},
+		{in: "7:15", want: time.Date(2024, 6, 2, 7, 15, 0, 0, loc)},
+		{in: "21:00", want: time.Date(2024, 6, 1, 21, 0, 0, 0, loc)},
+		{in: "9PM", want: time.Date(2024, 6, 1, 21, 0, 0, 0, loc)},
+		{in: "yesterday 7:15am", want: time.Date(2024, 6, 1, 7, 15, 0, 0, loc)},
+		{in: "last night", wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parseAtTime(tc.in, now)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wanted an error, but got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPlayAt(t *testing.T) {
+	var (
+		at    = func(clock string) time.Time { return mustParseDate("2024-06-01T" + clock) }
+		reba  = Track{Artist: "Phish", Title: "Reba", StartTime: at("20:55:00")}
+		bowie = Track{Artist: "Phish", Title: "David Bowie", StartTime: at("21:10:00")}
+		show  = Track{Artist: "Phish", Title: "22-Nov-1997 Set 2", Set: &ShowSet{Number: 2}, StartTime: at("23:00:00")}
+		plays = TrackList{reba, bowie, show}
+	)
+	tt := []struct {
+		desc   string
+		at     time.Time
+		want   Track
+		wantOK bool
+	}{
+		{desc: "during a play", at: at("21:00:00"), want: reba, wantOK: true},
+		{desc: "at the start of a play", at: at("21:10:00"), want: bowie, wantOK: true},
+		{desc: "before any play", at: at("20:00:00")},
+		{desc: "after the last play ended", at: mustParseDate("2024-06-02T00:30:00")},
+		{desc: "during a full show", at: at("23:45:00"), want: show, wantOK: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := playAt(plays, tc.at)
+			if ok != tc.wantOK || got != tc.want {
+				t.Errorf("wanted %v (%v), but got %v (%v)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const auditFile = "audit.jsonl"
+
+// Actions recorded in the audit log.
+const (
+	auditNotify   = "notify"
+	auditScrobble = "scrobble"
+	auditAnnounce = "announce"
+	auditReply    = "reply"
+)
+
+// auditEntry is something ph sent out on its own: a notification, a batch
+// of scrobbles, or a chat message, with where it went and whether it got
+// there.
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+
+	// Via is the service or channel used, such as webhook or lastfm, and
+	// Target where it went through it, such as a host, a chat room, or
+	// email addresses.
+	Via    string `json:"via"`
+	Target string `json:"target,omitempty"`
+
+	// Summary says what was sent, such as the subject of a notification.
+	Summary string `json:"summary"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (e auditEntry) String() string {
+	via := e.Via
+	if e.Target != "" {
+		via += " (" + e.Target + ")"
+	}
+	result := "ok"
+	if !e.OK {
+		result = "failed: " + e.Error
+	}
+	return fmt.Sprintf("%s  %-8s  %s  %s  %s", e.Time.Local().Format("2006-01-02 15:04:05"), e.Action, via, e.Summary, result)
+}
+
+// auditLog appends the actions ph takes on its own to the audit log, so
+// that one that misfires can be traced. A nil auditLog records nothing.
+type auditLog struct {
+	store *store
+}
+
+// openAuditLog returns the audit log in the user's store, or nil if there
+// is no store.
+func openAuditLog() *auditLog {
+	st, err := openStore()
+	if err != nil {
+		return nil
+	}
+	return &auditLog{store: st}
+}
+
+// record appends an action to the log, with the error it failed with, if
+// any. Failing to record it is only warned about, since it should not stop
+// the action from being taken.
+func (l *auditLog) record(action, via, target, summary string, err error) {
+	if l == nil || l.store == nil {
+		return
+	}
+	e := auditEntry{Time: appClock.Now().UTC(), Action: action, Via: via, Target: target, Summary: summary, OK: err == nil}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if err := l.store.appendJSONLine(auditFile, e); err != nil {
+		warn(fmt.Errorf("unable to write audit log: %w", err))
+	}
+}
+
+// audit returns the entries in the audit log recorded at or after since,
+// oldest first.
+func (s *store) audit(since time.Time) ([]auditEntry, error) {
+	var entries []auditEntry
+	err := s.readJSONLines(auditFile, func(line []byte) error {
+		var e auditEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil
+		}
+		if !e.Time.Before(since) {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// auditedNotifier records each notification it delivers in the audit log.
+type auditedNotifier struct {
+	notifier
+	log         *auditLog
+	via, target string
+}
+
+func (a auditedNotifier) notify(n notification) error {
+	err := a.notifier.notify(n)
+	a.log.record(auditNotify, a.via, a.target, n.Subject, err)
+	return err
+}
+
+// auditedTransport records each message a chat bot sends in the audit log:
+// announcements, and replies to commands.
+type auditedTransport struct {
+	chatTransport
+	log         *auditLog
+	via, target string
+}
+
+func (a auditedTransport) serve(ctx context.Context, onMessage func(text string, reply func(string) error)) error {
+	return a.chatTransport.serve(ctx, func(text string, reply func(string) error) {
+		onMessage(text, func(answer string) error {
+			err := reply(answer)
+			a.log.record(auditReply, a.via, "", firstLine(answer), err)
+			return err
+		})
+	})
+}
+
+func (a auditedTransport) send(text string) error {
+	err := a.chatTransport.send(text)
+	a.log.record(auditAnnounce, a.via, a.target, firstLine(text), err)
+	return err
+}
+
+// firstLine returns the first line of a message that may span several.
+func firstLine(s string) string {
+	return strings.SplitN(s, "\n", 2)[0]
+}
+
+// listensSummary says which listens were scrobbled, for the audit log.
+func listensSummary(ls []listen) string {
+	if len(ls) == 0 {
+		return ""
+	}
+	s := ls[0].Artist + " - " + ls[0].Title
+	if len(ls) > 1 {
+		s += fmt.Sprintf(" and %d more", len(ls)-1)
+	}
+	return s
+}
+
+// urlHost returns the host of a URL, which is where a webhook posts to,
+// without the path or query, which can hold secrets.
+func urlHost(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Host
+}
+
+// runAudit lists what ph has sent out on its own, from the audit log.
+func runAudit(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("audit", flag.ExitOnError)
+		since  = fs.Duration("since", 24*time.Hour, "Show actions within this long ago; 0 shows all actions")
+		action = fs.String("action", "", "Only show this action: "+strings.Join([]string{auditNotify, auditScrobble, auditAnnounce, auditReply}, ", "))
+		via    = fs.String("via", "", "Only show actions through this service or channel, such as webhook or lastfm")
+		failed = fs.Bool("failed", false, "Only show actions that failed")
+		asJSON = fs.Bool("json", false, "Print actions as JSON lines")
+	)
+	fs.Parse(args)
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	entries, err := st.audit(from)
+	if err != nil {
+		return fmt.Errorf("read audit log: %w", err)
+	}
+	for _, e := range entries {
+		if (*action != "" && e.Action != *action) || (*via != "" && e.Via != *via) || (*failed && e.OK) {
+			continue
+		}
+		if *asJSON {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			continue
+		}
+		fmt.Println(e)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// notifierFunc is a notifier that calls a function.
+type notifierFunc func(notification) error
+
+func (f notifierFunc) notify(n notification) error { return f(n) }
+
+// failingNotifier fails to deliver every notification.
+type failingNotifier struct{}
+
+func (failingNotifier) notify(notification) error { return errors.New("connection refused") }
+
+func TestAuditedNotifier(t *testing.T) {
+	now := mustParseDate("2020-06-05T20:00:00")
+	useFakeClock(t, now)
+	var (
+		st    = newTestStore(t)
+		audit = &auditLog{store: st}
+		ok    = auditedNotifier{notifierFunc(func(notification) error { return nil }), audit, "webhook", "hooks.example.com"}
+		bad   = auditedNotifier{failingNotifier{}, audit, "plugin", "notify-send"}
+	)
+	if err := ok.notify(notification{Subject: "Phish is on"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := bad.notify(notification{Subject: "Phish is on"}); err == nil {
+		t.Fatalf("wanted the notifier's error, but got none")
+	}
+	// A nil log records nothing, and does not fail.
+	auditedNotifier{failingNotifier{}, nil, "plugin", "notify-send"}.notify(notification{})
+
+	entries, err := st.audit(time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []auditEntry{
+		{Time: now, Action: auditNotify, Via: "webhook", Target: "hooks.example.com", Summary: "Phish is on", OK: true},
+		{Time: now, Action: auditNotify, Via: "plugin", Target: "notify-send", Summary: "Phish is on", Error: "connection refused"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("wanted %d entries, but got %+v", len(want), entries)
+	}
+	for i := range want {
+		if got := entries[i]; !got.Time.Equal(want[i].Time) || got.Action != want[i].Action || got.Via != want[i].Via ||
+			got.Target != want[i].Target || got.Summary != want[i].Summary || got.OK != want[i].OK || got.Error != want[i].Error {
+			t.Errorf("entry %d: wanted %+v, but got %+v", i, want[i], got)
+		}
+	}
+	if entries, _ := st.audit(now.Add(time.Minute)); len(entries) != 0 {
+		t.Errorf("wanted no entries since after they were recorded, but got %d", len(entries))
+	}
+}
+
+func TestScrobbleQueue_Audit(t *testing.T) {
+	var (
+		st  = newTestStore(t)
+		now = mustParseDate("2020-06-05T20:00:00")
+		fs  = &fakeScrobbler{offline: true}
+		q   = &scrobbleQueue{store: st, scrobblers: []scrobbler{fs}, now: func() time.Time { return now }}
+	)
+	useFakeClock(t, now)
+	q.submit(listen{Artist: "Phish", Title: "Reba", ListenedAt: now})
+	fs.offline = false
+	q.submit(listen{Artist: "Phish", Title: "Tweezer", ListenedAt: now})
+
+	entries, err := st.audit(time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("wanted 2 entries, but got %+v", entries)
+	}
+	if e := entries[0]; e.Action != auditScrobble || e.Via != "fake" || e.OK || e.Summary != "Phish - Reba" {
+		t.Errorf("wanted a failed scrobble of Reba, but got %+v", e)
+	}
+	if e := entries[1]; !e.OK || e.Summary != "Phish - Reba and 1 more" {
+		t.Errorf("wanted both listens scrobbled, but got %+v", e)
+	}
+}
+
+func TestURLHost(t *testing.T) {
+	tt := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://hooks.slack.com/services/T000/B000/XXXX", want: "hooks.slack.com"},
+		{url: "http://localhost:8080/notify?token=secret", want: "localhost:8080"},
+		{url: "not a url", want: ""},
+	}
+	for _, tc := range tt {
+		if got := urlHost(tc.url); got != tc.want {
+			t.Errorf("%s: wanted %q, but got %q", tc.url, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	tokensFile = "tokens.json"
+
+	// authTimeout is how long to wait for the user to complete logging in
+	// with their browser before giving up.
+	authTimeout = 5 * time.Minute
+)
+
+// oauthToken is a credential obtained from a service by logging in with
+// OAuth. Tokens are stored in the ph configuration directory, keyed by
+// service name.
+type oauthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	TokenType    string    `json:"token_type,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+
+	// ClientID and ClientSecret are kept for services that issue client
+	// credentials dynamically, such as Mastodon, since they are needed to
+	// refresh or revoke the token later.
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// oauthProvider implements the service-specific parts of an OAuth
+// authorization code flow. The redirect is always captured by a local HTTP
+// server, so the user never needs to copy and paste codes or tokens.
+type oauthProvider interface {
+	// authCodeURL returns the URL the user should visit to grant ph access.
+	authCodeURL(redirectURI, state, verifier string) (string, error)
+
+	// exchange trades an authorization code for a token, giving up when
+	// ctx is done.
+	exchange(ctx context.Context, code, redirectURI, verifier string) (oauthToken, error)
+}
+
+// oauthProviders returns the services that can be logged in to, configured
+// from cfg.
+func oauthProviders(cfg config) map[string]oauthProvider {
+	return map[string]oauthProvider{
+		"spotify":  &spotifyOAuth{cfg: cfg.Spotify, client: withBreaker(httpClient(cfg), spotifyBreaker), timeout: cfg.Timeouts.spotify()},
+		"mastodon": &mastodonOAuth{cfg: cfg.Mastodon, client: httpClient(cfg)},
+	}
+}
+
+func runAuth(args []string) error {
+	const usage = "usage: ph auth login|logout|status [service]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	providers := oauthProviders(cfg)
+	switch args[0] {
+	case "login":
+		if len(args) != 2 {
+			return errors.New("usage: ph auth login <service>")
+		}
+		p, ok := providers[args[1]]
+		if !ok {
+			return fmt.Errorf("unknown service %q (available: %s)", args[1], providerNames(providers))
+		}
+		return authLogin(args[1], p, cfg)
+	case "logout":
+		if len(args) != 2 {
+			return errors.New("usage: ph auth logout <service>")
+		}
+		return authLogout(args[1])
+	case "status":
+		return authStatus(providers)
+	default:
+		return errors.New(usage)
+	}
+}
+
+func providerNames(providers map[string]oauthProvider) string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// authLogin runs the authorization code flow for a service, capturing the
+// redirect on a loopback address, and stores the resulting token.
+func authLogin(service string, p oauthProvider, cfg config) error {
+	port := 0
+	if service == "spotify" {
+		// Spotify requires the redirect URI to exactly match one registered
+		// with the application, so the port must be predictable.
+		port = cfg.Spotify.RedirectPort
+		if port == 0 {
+			port = 8888
+		}
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return fmt.Errorf("listen for %s login redirect: %w", service, err)
+	}
+	defer ln.Close()
+
+	var (
+		redirectURI = fmt.Sprintf("http://%s/callback", ln.Addr())
+		state       = randomString(16)
+		verifier    = randomString(32)
+	)
+	authURL, err := p.authCodeURL(redirectURI, state, verifier)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), authTimeout)
+	defer cancel()
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	srv := &http.Server{Handler: oauthCallbackHandler(state, codes, errs)}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	fmt.Printf("Opening your browser to log in to %s. If it does not open, visit:\n%s\n", service, authURL)
+	if err := openBrowser(authURL); err != nil {
+		warn(fmt.Errorf("unable to open browser: %w", err))
+	}
+
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-errs:
+		return fmt.Errorf("log in to %s: %w", service, err)
+	case <-ctx.Done():
+		return fmt.Errorf("log in to %s: timed out waiting for authorization", service)
+	}
+	// The code arrives near the end of the login timeout as easily as at
+	// the start, so the exchange gets its own deadline.
+	token, err := p.exchange(context.Background(), code, redirectURI, verifier)
+	if err != nil {
+		return fmt.Errorf("log in to %s: %w", service, err)
+	}
+	if err := saveToken(service, token); err != nil {
+		return fmt.Errorf("save %s token: %w", service, err)
+	}
+	fmt.Printf("Logged in to %s.\n", service)
+	return nil
+}
+
+// oauthCallbackHandler handles the redirect from the authorization server,
+// sending the authorization code to codes or a failure to errs.
+func oauthCallbackHandler(state string, codes chan<- string, errs chan<- error) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			http.Error(w, "Authorization failed: "+e, http.StatusBadRequest)
+			nonBlockingSend(errs, fmt.Errorf("authorization denied: %s", e))
+			return
+		}
+		if q.Get("state") != state {
+			http.Error(w, "Authorization failed: state mismatch", http.StatusBadRequest)
+			nonBlockingSend(errs, errors.New("authorization state mismatch"))
+			return
+		}
+		code := q.Get("code")
+		if code == "" {
+			http.Error(w, "Authorization failed: no code received", http.StatusBadRequest)
+			nonBlockingSend(errs, errors.New("no authorization code received"))
+			return
+		}
+		fmt.Fprintln(w, "Logged in. You can close this window and return to ph.")
+		select {
+		case codes <- code:
+		default:
+		}
+	})
+	return mux
+}
+
+func nonBlockingSend(errs chan<- error, err error) {
+	select {
+	case errs <- err:
+	default:
+	}
+}
+
+func authLogout(service string) error {
+	tokens, err := loadTokens()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[service]; !ok {
+		fmt.Printf("Not logged in to %s.\n", service)
+		return nil
+	}
+	delete(tokens, service)
+	if err := writeTokens(tokens); err != nil {
+		return err
+	}
+	fmt.Printf("Logged out of %s.\n", service)
+	return nil
+}
+
+func authStatus(providers map[string]oauthProvider) error {
+	tokens, err := loadTokens()
+	if err != nil {
+		return err
+	}
+	names := strings.Split(providerNames(providers), ", ")
+	for _, name := range names {
+		t, ok := tokens[name]
+		switch {
+		case !ok:
+			fmt.Printf("%-10s not logged in\n", name)
+		case !t.Expiry.IsZero() && t.Expiry.Before(time.Now()) && t.RefreshToken == "":
+			fmt.Printf("%-10s token expired\n", name)
+		default:
+			fmt.Printf("%-10s logged in\n", name)
+		}
+	}
+	return nil
+}
+
+func saveToken(service string, token oauthToken) error {
+	tokens, err := loadTokens()
+	if err != nil {
+		return err
+	}
+	tokens[service] = token
+	return writeTokens(tokens)
+}
+
+func tokensPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, tokensFile), nil
+}
+
+func loadTokens() (map[string]oauthToken, error) {
+	tokens := make(map[string]oauthToken)
+	path, err := tokensPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, fmt.Errorf("parse tokens file %s: %w", path, err)
+	}
+	return tokens, nil
+}
+
+// writeTokens stores tokens readable only by the current user, since they
+// grant access to the user's accounts.
+func writeTokens(tokens map[string]oauthToken) error {
+	path, err := tokensPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0700)); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, os.FileMode(0600))
+}
+
+// spotifyOAuth logs in to Spotify using the authorization code flow with
+// PKCE, which does not require a client secret.
+type spotifyOAuth struct {
+	cfg     spotifyConfig
+	client  *http.Client
+	timeout time.Duration
+}
+
+func (s *spotifyOAuth) authCodeURL(redirectURI, state, verifier string) (string, error) {
+	if s.cfg.ClientID == "" {
+		return "", errors.New("spotify.client_id must be set in the config file to log in to Spotify")
+	}
+	q := url.Values{
+		"client_id":             {s.cfg.ClientID},
+		"response_type":         {"code"},
+		"redirect_uri":          {redirectURI},
+		"state":                 {state},
+		"scope":                 {"user-read-private"},
+		"code_challenge_method": {"S256"},
+		"code_challenge":        {pkceChallenge(verifier)},
+	}
+	return "https://accounts.spotify.com/authorize?" + q.Encode(), nil
+}
+
+func (s *spotifyOAuth) exchange(ctx context.Context, code, redirectURI, verifier string) (oauthToken, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {s.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	token, err := requestToken(ctx, s.client, "https://accounts.spotify.com/api/token", form)
+	if err != nil {
+		return token, err
+	}
+	token.ClientID = s.cfg.ClientID
+	return token, nil
+}
+
+// mastodonOAuth logs in to a Mastodon instance. Since every instance is
+// independent, ph registers itself as an application on the instance
+// before starting the authorization code flow.
+type mastodonOAuth struct {
+	cfg    mastodonConfig
+	client *http.Client
+
+	clientID     string
+	clientSecret string
+}
+
+const mastodonScopes = "read write:statuses"
+
+func (m *mastodonOAuth) instanceURL() (string, error) {
+	instance := strings.TrimSuffix(m.cfg.Instance, "/")
+	if instance == "" {
+		return "", errors.New("mastodon.instance must be set in the config file to log in to Mastodon")
+	}
+	if !strings.Contains(instance, "://") {
+		instance = "https://" + instance
+	}
+	return instance, nil
+}
+
+func (m *mastodonOAuth) authCodeURL(redirectURI, state, _ string) (string, error) {
+	instance, err := m.instanceURL()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"client_name":   {"ph"},
+		"redirect_uris": {redirectURI},
+		"scopes":        {mastodonScopes},
+		"website":       {"https://github.com/ianfoo/ph"},
+	}
+	resp, err := m.client.PostForm(instance+"/api/v1/apps", form)
+	if err != nil {
+		return "", fmt.Errorf("register application with %s: %w", instance, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("register application with %s: %s", instance, resp.Status)
+	}
+	var app struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&app); err != nil {
+		return "", fmt.Errorf("parse application registration: %w", err)
+	}
+	m.clientID, m.clientSecret = app.ClientID, app.ClientSecret
+
+	q := url.Values{
+		"client_id":     {m.clientID},
+		"response_type": {"code"},
+		"redirect_uri":  {redirectURI},
+		"scope":         {mastodonScopes},
+		"state":         {state},
+	}
+	return instance + "/oauth/authorize?" + q.Encode(), nil
+}
+
+func (m *mastodonOAuth) exchange(ctx context.Context, code, redirectURI, _ string) (oauthToken, error) {
+	instance, err := m.instanceURL()
+	if err != nil {
+		return oauthToken{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {m.clientID},
+		"client_secret": {m.clientSecret},
+		"scope":         {mastodonScopes},
+	}
+	token, err := requestToken(ctx, m.client, instance+"/oauth/token", form)
+	if err != nil {
+		return token, err
+	}
+	token.ClientID, token.ClientSecret = m.clientID, m.clientSecret
+	return token, nil
+}
+
+// requestToken posts form to a token endpoint and decodes the standard
+// OAuth token response.
+func requestToken(ctx context.Context, client *http.Client, endpoint string, form url.Values) (oauthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oauthToken{}, fmt.Errorf("request token: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return oauthToken{}, fmt.Errorf("request token: %w", err)
+	}
+	defer resp.Body.Close()
+	var tr struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		Scope        string `json:"scope"`
+		ExpiresIn    int    `json:"expires_in"`
+		Error        string `json:"error"`
+		ErrorDesc    string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return oauthToken{}, fmt.Errorf("parse token response (%s): %w", resp.Status, err)
+	}
+	if tr.Error != "" {
+		return oauthToken{}, fmt.Errorf("request token: %s: %s", tr.Error, tr.ErrorDesc)
+	}
+	if tr.AccessToken == "" {
+		return oauthToken{}, fmt.Errorf("request token: no access token in response (%s)", resp.Status)
+	}
+	token := oauthToken{
+		AccessToken:  tr.AccessToken,
+		RefreshToken: tr.RefreshToken,
+		TokenType:    tr.TokenType,
+		Scope:        tr.Scope,
+	}
+	if tr.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// pkceChallenge derives the S256 code challenge for a PKCE code verifier,
+// as described in RFC 7636.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomString returns a URL-safe random string made from n random bytes.
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("read random bytes: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// openBrowser tries to open url in the user's web browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
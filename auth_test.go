@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	// Example from RFC 7636, Appendix B.
+	const (
+		verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		want     = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+	if got := pkceChallenge(verifier); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
+
+func TestOAuthCallbackHandler(t *testing.T) {
+	tt := []struct {
+		desc       string
+		query      string
+		wantStatus int
+		wantCode   string
+		wantErr    bool
+	}{
+		{
+			desc:       "code received",
+			query:      "?state=xyz&code=abc",
+			wantStatus: http.StatusOK,
+			wantCode:   "abc",
+		},
+		{
+			desc:       "state mismatch",
+			query:      "?state=nope&code=abc",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    true,
+		},
+		{
+			desc:       "access denied",
+			query:      "?error=access_denied&state=xyz",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    true,
+		},
+		{
+			desc:       "missing code",
+			query:      "?state=xyz",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			var (
+				codes = make(chan string, 1)
+				errs  = make(chan error, 1)
+				h     = oauthCallbackHandler("xyz", codes, errs)
+				rec   = httptest.NewRecorder()
+			)
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/callback"+tc.query, nil))
+			if rec.Code != tc.wantStatus {
+				t.Errorf("wanted status %d, but got %d", tc.wantStatus, rec.Code)
+			}
+			select {
+			case code := <-codes:
+				if code != tc.wantCode {
+					t.Errorf("wanted code %q, but got %q", tc.wantCode, code)
+				}
+			case err := <-errs:
+				if !tc.wantErr {
+					t.Errorf("unexpected error: %v", err)
+				}
+			default:
+				t.Error("handler produced neither a code nor an error")
+			}
+		})
+	}
+}
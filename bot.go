@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// botConfig holds settings for ph bot, which joins a chat channel to
+// announce tracks and answer commands.
+type botConfig struct {
+	Quiet    bool           `yaml:"quiet,omitempty" doc:"Only answer commands; do not announce track changes"`
+	IRC      ircConfig      `yaml:"irc,omitempty" doc:"IRC channel to join"`
+	Matrix   matrixConfig   `yaml:"matrix,omitempty" doc:"Matrix room to join"`
+	Telegram telegramConfig `yaml:"telegram,omitempty" doc:"Telegram bot to run"`
+}
+
+// chatTransport connects a bot to a chat service.
+type chatTransport interface {
+	// serve receives messages until ctx is canceled or the connection
+	// fails, passing the text of each message sent to the bot to
+	// onMessage, with a function that replies to where it was sent.
+	serve(ctx context.Context, onMessage func(text string, reply func(string) error)) error
+
+	// send posts a message, which may span several lines, to the bot's
+	// channel.
+	send(text string) error
+}
+
+// chatBot answers chat commands about the station, and announces track
+// changes, from the statuses published on the bus.
+type chatBot struct {
+	// cfg gives the links announced with each track.
+	cfg         config
+	linkPlugins []string
+
+	// announceIf, if not nil, limits the tracks that are announced to those
+	// it reports true for.
+	announceIf func(Track) bool
+
+	mu     sync.Mutex
+	status statusResponseBody
+	have   bool
+}
+
+// run keeps the bot's status up to date with the statuses published on the
+// bus, until the bus is closed. Each new track is passed to announce, if it
+// is not nil.
+func (b *chatBot) run(events <-chan interface{}, announce func(string)) {
+	for e := range events {
+		fetched, ok := e.(statusFetched)
+		if !ok {
+			continue
+		}
+		b.mu.Lock()
+		previous, had := b.status.CurrentTrack, b.have
+		b.status, b.have = fetched.Status, true
+		b.mu.Unlock()
+
+		current := fetched.Status.CurrentTrack
+		if announce == nil || !had || previous.Key().Equal(current.Key()) || current.IsStationBreak() || current.Title == "" {
+			continue
+		}
+		if b.announceIf != nil && !b.announceIf(current) {
+			continue
+		}
+		announce(b.nowPlaying(current))
+	}
+}
+
+// command returns the reply to a chat command, given without its prefix,
+// such as "np" for "!np". It reports false for messages that are not
+// commands the bot knows.
+//
+// The commands are "np" (or "now") for the current track, "history" for
+// the tracks aired before it, and "setlist" for the show being aired.
+func (b *chatBot) command(text string) (string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", false
+	}
+	b.mu.Lock()
+	status, have := b.status, b.have
+	b.mu.Unlock()
+
+	name := strings.ToLower(fields[0])
+	switch name {
+	case "np", "now", "history", "setlist":
+	default:
+		return "", false
+	}
+	if !have {
+		return "The station status is not available yet.", true
+	}
+	switch name {
+	case "np", "now":
+		return b.nowPlaying(status.CurrentTrack), true
+	case "history":
+		return recentHistory(status.CurrentTrack, status.History, botHistoryLength), true
+	case "setlist":
+		show, ok := currentShow(status.CurrentTrack, status.History)
+		if !ok {
+			return "Not airing a live show right now.", true
+		}
+		return show.String(), true
+	}
+	return "", false
+}
+
+// botHistoryLength is how many tracks the history command lists.
+const botHistoryLength = 10
+
+// recentHistory lists up to n tracks aired before current, most recent
+// first. The history may begin with the current track, which is skipped.
+func recentHistory(current Track, history TrackList, n int) string {
+	if len(history) > 0 && history[0].Key().Equal(current.Key()) {
+		history = history[1:]
+	}
+	if len(history) == 0 {
+		return "No tracks have aired yet."
+	}
+	if len(history) > n {
+		history = history[:n]
+	}
+	lines := make([]string, 0, len(history))
+	for i, t := range history {
+		lines = append(lines, fmt.Sprintf("%2d. %s", i+1, trackSummary(t)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// nowPlaying describes a track with its links, including any from link
+// plugins.
+func (b *chatBot) nowPlaying(t Track) string {
+	lines := []string{"Now playing: " + trackSummary(t)}
+	lines = append(lines, linkLines(t.Links(b.cfg, relistenArtists))...)
+	if len(b.linkPlugins) > 0 {
+		links, err := pluginLinks(b.linkPlugins, t)
+		if err != nil {
+			warn(err)
+		}
+		for _, l := range links {
+			lines = append(lines, l.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runBot joins a chat channel, or runs a Telegram bot, announcing track
+// changes and answering commands until interrupted.
+func runBot(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs       = flag.NewFlagSet("bot", flag.ExitOnError)
+		network  = fs.String("network", "", "Chat network to join: irc, matrix, or telegram (default: whichever is configured)")
+		quiet    = fs.Bool("quiet", cfg.Bot.Quiet, "Only answer commands; do not announce track changes")
+		interval = fs.Duration("interval", 30*time.Second, "How often to check the station")
+	)
+	fs.Parse(args)
+
+	if *network == "" {
+		var configured []string
+		if cfg.Bot.IRC.Server != "" {
+			configured = append(configured, "irc")
+		}
+		if cfg.Bot.Matrix.Homeserver != "" {
+			configured = append(configured, "matrix")
+		}
+		if cfg.Bot.Telegram.Token != "" {
+			configured = append(configured, "telegram")
+		}
+		switch len(configured) {
+		case 0:
+			return errors.New("no chat network configured; set bot.irc, bot.matrix, or bot.telegram in the config file")
+		case 1:
+			*network = configured[0]
+		default:
+			return fmt.Errorf("%s are configured; choose one with --network", strings.Join(configured, " and "))
+		}
+	}
+	bot := &chatBot{cfg: cfg, linkPlugins: cfg.Plugins.Links}
+	var transport chatTransport
+	switch *network {
+	case "irc":
+		transport, err = newIRCClient(cfg.Bot.IRC)
+	case "matrix":
+		transport, err = newMatrixClient(httpClient(cfg), cfg.Bot.Matrix)
+	case "telegram":
+		transport, err = newTelegramClient(httpClient(cfg), cfg.Bot.Telegram)
+		// Telegram announcements are pushed to a chat, so they are limited
+		// to the user's favorite artists, and need a chat to push to.
+		bot.announceIf = ByArtist(cfg.Favorites.Artists...)
+		if cfg.Bot.Telegram.ChatID == 0 {
+			*quiet = true
+		}
+	default:
+		return fmt.Errorf("unknown network %q: must be irc, matrix, or telegram", *network)
+	}
+	if err != nil {
+		return err
+	}
+	targets := map[string]string{
+		"irc":    cfg.Bot.IRC.Channel,
+		"matrix": cfg.Bot.Matrix.Room,
+	}
+	if id := cfg.Bot.Telegram.ChatID; id != 0 {
+		targets["telegram"] = strconv.FormatInt(id, 10)
+	}
+	transport = auditedTransport{transport, openAuditLog(), *network, targets[*network]}
+	prefix := "!"
+	if *network == "telegram" {
+		prefix = "/"
+	}
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	return serveChatBot(cfg, bot, transport, *quiet, *interval, prefix)
+}
+
+// serveChatBot runs a bot on a transport until interrupted or the
+// connection fails. Messages starting with prefix are treated as commands.
+func serveChatBot(cfg config, bot *chatBot, transport chatTransport, quiet bool, interval time.Duration, prefix string) error {
+	var (
+		b        = &bus{}
+		announce func(string)
+	)
+	if !quiet {
+		announce = func(text string) {
+			if err := transport.send(text); err != nil {
+				warn(fmt.Errorf("unable to announce track: %w", err))
+			}
+		}
+	}
+	events := b.subscribe(4)
+	go bot.run(events, announce)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &poller{
+		radioCo:  newRadioCoClient(httpClient(cfg), cfg),
+		station:  cfg.station(),
+		interval: interval,
+		bus:      b,
+	}
+	go func() {
+		p.run(ctx)
+		b.close()
+	}()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		cancel()
+	}()
+
+	err := transport.serve(ctx, func(text string, reply func(string) error) {
+		if !strings.HasPrefix(text, prefix) {
+			return
+		}
+		if answer, ok := bot.command(strings.TrimPrefix(text, prefix)); ok {
+			if err := reply(answer); err != nil {
+				warn(fmt.Errorf("unable to reply: %w", err))
+			}
+		}
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
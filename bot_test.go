@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChatBot_Command(t *testing.T) {
+	relistenArtists = goldenRelistenArtists(t)
+	var (
+		hampton = mustParseDate("1997-11-22")
+		reba    = Track{Artist: "Phish", Title: "Reba", PerformanceTime: hampton, Location: "Hampton, VA"}
+		tweezer = Track{Artist: "Phish", Title: "Tweezer", PerformanceTime: hampton}
+		bot     = &chatBot{}
+	)
+	if got, ok := bot.command("np"); !ok || got != "The station status is not available yet." {
+		t.Errorf("before first status: unexpected reply %q (%v)", got, ok)
+	}
+	bot.status = statusResponseBody{CurrentTrack: reba, History: TrackList{reba, tweezer}}
+	bot.have = true
+
+	tt := []struct {
+		desc   string
+		text   string
+		want   string
+		wantOK bool
+	}{
+		{
+			desc:   "now playing",
+			text:   "np",
+			want:   "Now playing: Phish - Reba (Sat 22-Nov-1997)\nStream: https://relisten.net/phish/1997/11/22\nSetlist: https://phish.net/setlists/?d=1997-11-22",
+			wantOK: true,
+		},
+		{
+			desc:   "setlist",
+			text:   "setlist",
+			want:   " 1. Tweezer\n 2. Reba  (now playing)",
+			wantOK: true,
+		},
+		{desc: "any case", text: "NP", want: "Now playing: Phish - Reba", wantOK: true},
+		{desc: "now alias", text: "now", want: "Now playing: Phish - Reba", wantOK: true},
+		{
+			desc:   "history",
+			text:   "history",
+			want:   " 1. Phish - Tweezer (Sat 22-Nov-1997)",
+			wantOK: true,
+		},
+		{desc: "unknown command", text: "help"},
+		{desc: "empty", text: ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := bot.command(tc.text)
+			if ok != tc.wantOK || !strings.Contains(got, tc.want) {
+				t.Errorf("wanted reply containing %q (%v), but got %q (%v)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestChatBot_Announce(t *testing.T) {
+	var (
+		bot       = &chatBot{}
+		events    = make(chan interface{}, 5)
+		announced []string
+		status    = func(t Track) statusFetched {
+			return statusFetched{Status: statusResponseBody{CurrentTrack: t}}
+		}
+		reba      = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:00:00")}
+		stationID = Track{Artist: "jempradio.com", Title: "JEMP Radio", StartTime: mustParseDate("2020-06-05T20:10:00")}
+		tweezer   = Track{Artist: "Phish", Title: "Tweezer", StartTime: mustParseDate("2020-06-05T20:12:00")}
+	)
+	// The first status is not announced, since the track may have been
+	// playing for a while.
+	events <- status(reba)
+	events <- status(reba)
+	events <- status(stationID)
+	events <- status(tweezer)
+	close(events)
+	bot.run(events, func(text string) { announced = append(announced, text) })
+
+	if len(announced) != 1 || announced[0] != "Now playing: Phish - Tweezer" {
+		t.Errorf("wanted only Tweezer to be announced, but got %q", announced)
+	}
+}
+
+func TestChatBot_AnnounceIf(t *testing.T) {
+	var (
+		bot       = &chatBot{announceIf: ByArtist("phish")}
+		events    = make(chan interface{}, 3)
+		announced []string
+	)
+	for _, track := range []Track{
+		{Artist: "Goose", Title: "Arcadia"},
+		{Artist: "Grateful Dead", Title: "Bertha"},
+		{Artist: "Phish", Title: "Reba"},
+	} {
+		events <- statusFetched{Status: statusResponseBody{CurrentTrack: track}}
+	}
+	close(events)
+	bot.run(events, func(text string) { announced = append(announced, text) })
+
+	if len(announced) != 1 || announced[0] != "Now playing: Phish - Reba" {
+		t.Errorf("wanted only the favorite artist to be announced, but got %q", announced)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	boundariesFile = "boundaries.jsonl"
+
+	// defaultBoundaryWindow is how much of the end of each recording is
+	// searched for the start of the next track. The station reports a new
+	// track late, so the next track starts before its recording does.
+	defaultBoundaryWindow = 45 * time.Second
+
+	// maxBoundaryShift is the furthest a play's start time is moved to a
+	// detected track boundary.
+	maxBoundaryShift = 90 * time.Second
+
+	// envelopeRate is the sample rate audio is decoded at to find jingles,
+	// and envelopeFrame the number of samples in each frame of its loudness
+	// envelope, 50ms.
+	envelopeRate  = 8000
+	envelopeFrame = 400
+
+	// jingleMatchThreshold is the least correlation between a jingle's
+	// envelope and a stretch of audio for the jingle to be taken as found.
+	jingleMatchThreshold = 0.9
+)
+
+// recordConfig holds settings for ph record.
+type recordConfig struct {
+	Dir    string `yaml:"dir,omitempty" doc:"Directory to record to (default: the current directory)"`
+	Jingle string `yaml:"jingle,omitempty" doc:"Audio file of the station's jingle, which marks the start of a track when heard"`
+}
+
+func (c recordConfig) dir() string {
+	if c.Dir != "" {
+		return c.Dir
+	}
+	return "."
+}
+
+// boundary is the detected start of a track, found by listening to the
+// stream rather than from the station's status.
+type boundary struct {
+	Time time.Time `json:"time"`
+
+	// Source is how the boundary was found: "silence", for the end of a
+	// silence, or "jingle", for the end of the station's jingle.
+	Source string `json:"source"`
+}
+
+func (s *store) recordBoundary(b boundary) error {
+	return s.appendJSONLine(boundariesFile, b)
+}
+
+// boundaries returns the detected track boundaries, in order.
+func (s *store) boundaries() ([]boundary, error) {
+	var bs []boundary
+	err := s.readJSONLines(boundariesFile, func(line []byte) error {
+		var b boundary
+		if err := json.Unmarshal(line, &b); err == nil {
+			bs = append(bs, b)
+		}
+		return nil
+	})
+	sort.Slice(bs, func(i, j int) bool { return bs[i].Time.Before(bs[j].Time) })
+	return bs, err
+}
+
+// adjustStartTimes returns plays, in order of start time, with each start
+// time moved to the nearest detected boundary within max of it. A start
+// time is not moved past the start of the play before or after it.
+func adjustStartTimes(plays TrackList, boundaries []boundary, max time.Duration) TrackList {
+	out := append(TrackList{}, plays...)
+	if len(boundaries) == 0 {
+		return out
+	}
+	for i := range out {
+		start := out[i].StartTime
+		if start.IsZero() {
+			continue
+		}
+		var (
+			best     time.Time
+			bestDist = max + 1
+		)
+		j := sort.Search(len(boundaries), func(j int) bool { return !boundaries[j].Time.Before(start.Add(-max)) })
+		for ; j < len(boundaries) && !boundaries[j].Time.After(start.Add(max)); j++ {
+			d := boundaries[j].Time.Sub(start)
+			if d < 0 {
+				d = -d
+			}
+			if d < bestDist {
+				best, bestDist = boundaries[j].Time, d
+			}
+		}
+		if best.IsZero() {
+			continue
+		}
+		if i > 0 && !best.After(out[i-1].StartTime) {
+			continue
+		}
+		if i+1 < len(out) && !out[i+1].StartTime.IsZero() && !best.Before(out[i+1].StartTime) {
+			continue
+		}
+		out[i].StartTime = best
+	}
+	return out
+}
+
+// silence is a stretch of silence in a recording, as offsets from where
+// the analysis began.
+type silence struct {
+	Start, End time.Duration
+}
+
+// parseSilenceDetect parses the silences logged by ffmpeg's silencedetect
+// filter. A silence that lasts to the end of the audio has no end.
+func parseSilenceDetect(out string) []silence {
+	var silences []silence
+	for _, line := range strings.Split(out, "\n") {
+		for _, key := range []string{"silence_start: ", "silence_end: "} {
+			i := strings.Index(line, key)
+			if i < 0 {
+				continue
+			}
+			f := strings.Fields(line[i+len(key):])
+			if len(f) == 0 {
+				continue
+			}
+			secs, err := strconv.ParseFloat(f[0], 64)
+			if err != nil {
+				continue
+			}
+			d := time.Duration(secs * float64(time.Second))
+			if key == "silence_start: " {
+				silences = append(silences, silence{Start: d})
+			} else if n := len(silences); n > 0 {
+				silences[n-1].End = d
+			}
+		}
+	}
+	return silences
+}
+
+// ffmpegSeek returns the arguments that start ffmpeg's input at offset.
+func ffmpegSeek(offset time.Duration) []string {
+	if offset <= 0 {
+		return nil
+	}
+	return []string{"-ss", fmt.Sprintf("%.3f", offset.Seconds())}
+}
+
+// detectSilences finds the silences in a recording from offset on, with
+// ffmpeg.
+func detectSilences(path string, offset time.Duration) ([]silence, error) {
+	args := append([]string{"-hide_banner", "-nostats"}, ffmpegSeek(offset)...)
+	args = append(args, "-i", path, "-af", "silencedetect=noise=-50dB:d=0.5", "-f", "null", "-")
+	out, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("detect silences in %s: %w", path, err)
+	}
+	return parseSilenceDetect(string(out)), nil
+}
+
+// decodeEnvelope decodes a recording from offset on with ffmpeg, returning
+// its loudness envelope.
+func decodeEnvelope(path string, offset time.Duration) ([]float64, error) {
+	args := append([]string{"-hide_banner", "-nostats", "-loglevel", "error"}, ffmpegSeek(offset)...)
+	args = append(args, "-i", path, "-ac", "1", "-ar", strconv.Itoa(envelopeRate), "-f", "s16le", "-")
+	out, err := exec.Command("ffmpeg", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	samples := make([]int16, len(out)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(out[2*i:]))
+	}
+	return envelope(samples, envelopeFrame), nil
+}
+
+// envelope returns the RMS level of each frame of samples.
+func envelope(samples []int16, frame int) []float64 {
+	env := make([]float64, 0, len(samples)/frame)
+	for i := 0; i+frame <= len(samples); i += frame {
+		var sum float64
+		for _, s := range samples[i : i+frame] {
+			sum += float64(s) * float64(s)
+		}
+		env = append(env, math.Sqrt(sum/float64(frame)))
+	}
+	return env
+}
+
+// correlation returns the Pearson correlation of two envelopes of the same
+// length, which is 0 if either is flat.
+func correlation(a, b []float64) float64 {
+	var meanA, meanB float64
+	for i := range a {
+		meanA += a[i]
+		meanB += b[i]
+	}
+	meanA /= float64(len(a))
+	meanB /= float64(len(b))
+	var cov, varA, varB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+// findJingle returns the frame at which the last match of the jingle's
+// envelope in env ends, and reports whether there is one.
+func findJingle(env, jingle []float64) (int, bool) {
+	if len(jingle) == 0 {
+		return 0, false
+	}
+	for i := len(env) - len(jingle); i >= 0; i-- {
+		if correlation(env[i:i+len(jingle)], jingle) >= jingleMatchThreshold {
+			return i + len(jingle), true
+		}
+	}
+	return 0, false
+}
+
+// tailBoundary finds the start of the track after a recording, from the
+// analysis of its audio from offset on: the end of the station's jingle, if
+// its envelope is given and it is heard, or otherwise the end of the last
+// silence.
+func tailBoundary(e recordingEntry, offset time.Duration, silences []silence, env, jingle []float64) (boundary, bool) {
+	if end, ok := findJingle(env, jingle); ok {
+		d := offset + time.Duration(end*envelopeFrame)*time.Second/envelopeRate
+		return boundary{Time: e.Started.Add(d), Source: "jingle"}, true
+	}
+	for i := len(silences) - 1; i >= 0; i-- {
+		if silences[i].End > 0 {
+			return boundary{Time: e.Started.Add(offset + silences[i].End), Source: "silence"}, true
+		}
+	}
+	return boundary{}, false
+}
+
+// detectBoundary searches the end of a recording for the start of the
+// track that followed it.
+func detectBoundary(e recordingEntry, jingle []float64, window time.Duration) (boundary, bool, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return boundary{}, false, errors.New("detecting track boundaries needs ffmpeg on the PATH")
+	}
+	offset := e.Recorded.Sub(e.Started) - window
+	if offset < 0 {
+		offset = 0
+	}
+	silences, err := detectSilences(e.Path, offset)
+	if err != nil {
+		return boundary{}, false, err
+	}
+	var env []float64
+	if len(jingle) > 0 {
+		if env, err = decodeEnvelope(e.Path, offset); err != nil {
+			return boundary{}, false, err
+		}
+	}
+	b, ok := tailBoundary(e, offset, silences, env, jingle)
+	return b, ok, nil
+}
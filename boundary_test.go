@@ -0,0 +1,142 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSilenceDetect(t *testing.T) {
+	out := `Input #0, mp3, from 'Reba.mp3':
+[silencedetect @ 0x7f9d] silence_start: 12.5
+[silencedetect @ 0x7f9d] silence_end: 14.25 | silence_duration: 1.75
+[silencedetect @ 0x7f9d] silence_start: 40.1
+size=N/A time=00:00:45.00 bitrate=N/A speed= 300x
+`
+	want := []silence{
+		{Start: 12500 * time.Millisecond, End: 14250 * time.Millisecond},
+		{Start: 40100 * time.Millisecond},
+	}
+	if got := parseSilenceDetect(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+}
+
+func TestFindJingle(t *testing.T) {
+	jingle := []float64{1, 8, 2, 9, 3}
+	tt := []struct {
+		desc   string
+		env    []float64
+		want   int
+		wantOK bool
+	}{
+		{desc: "at the end", env: []float64{5, 5, 5, 1, 8, 2, 9, 3}, want: 8, wantOK: true},
+		{desc: "louder", env: []float64{2, 16, 4, 18, 6, 5, 5}, want: 5, wantOK: true},
+		{desc: "last of two", env: []float64{1, 8, 2, 9, 3, 0, 1, 8, 2, 9, 3, 4}, want: 11, wantOK: true},
+		{desc: "not heard", env: []float64{5, 4, 6, 5, 4, 6, 5}},
+		{desc: "shorter than the jingle", env: []float64{1, 8}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := findJingle(tc.env, jingle)
+			if ok != tc.wantOK || got != tc.want {
+				t.Errorf("wanted %d (%t), but got %d (%t)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	got := envelope([]int16{3, -3, 3, -3, 0, 0, 0, 0, 1}, 4)
+	if want := []float64{3, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+}
+
+func TestTailBoundary(t *testing.T) {
+	var (
+		started = mustParseDate("2024-06-01T21:00:00")
+		e       = recordingEntry{Started: started, Recorded: started.Add(5 * time.Minute)}
+		offset  = 4 * time.Minute
+		// Silences whose ends are known, and one that runs to the end.
+		silences = []silence{{Start: 10 * time.Second, End: 11 * time.Second}, {Start: 30 * time.Second, End: 32 * time.Second}, {Start: 58 * time.Second}}
+		jingle   = []float64{1, 8, 2, 9, 3}
+		// The jingle ends at frame 60, 3 seconds in.
+		env = append(make([]float64, 55), append(jingle, 0, 0, 0)...)
+	)
+	tt := []struct {
+		desc     string
+		silences []silence
+		jingle   []float64
+		want     boundary
+		wantOK   bool
+	}{
+		{desc: "last silence", silences: silences, want: boundary{Time: started.Add(4*time.Minute + 32*time.Second), Source: "silence"}, wantOK: true},
+		{desc: "jingle", silences: silences, jingle: jingle, want: boundary{Time: started.Add(4*time.Minute + 3*time.Second), Source: "jingle"}, wantOK: true},
+		{desc: "nothing heard", silences: silences[2:]},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := tailBoundary(e, offset, tc.silences, env, tc.jingle)
+			if ok != tc.wantOK || !got.Time.Equal(tc.want.Time) || got.Source != tc.want.Source {
+				t.Errorf("wanted %v (%t), but got %v (%t)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestAdjustStartTimes(t *testing.T) {
+	var (
+		at    = func(clock string) time.Time { return mustParseDate("2024-06-01T" + clock) }
+		plays = TrackList{
+			{Title: "Reba", StartTime: at("21:00:00")},
+			{Title: "Ghost", StartTime: at("21:15:00")},
+			{Title: "Station break", StartTime: at("21:30:00")},
+			{Title: "Tweezer", StartTime: at("21:31:00")},
+			{Title: "Drums", StartTime: at("21:45:00")},
+			{Title: "Space", StartTime: at("21:45:30")},
+			{Title: "Untimed"},
+		}
+		boundaries = []boundary{
+			{Time: at("21:14:20")},
+			{Time: at("21:14:50")},
+			{Time: at("21:29:30")},
+			{Time: at("21:30:45")},
+			{Time: at("21:45:20")},
+			{Time: at("23:00:00")},
+		}
+	)
+	got := adjustStartTimes(plays, boundaries, maxBoundaryShift)
+	// Space is left alone, since its nearest boundary is taken by Drums.
+	want := []time.Time{at("21:00:00"), at("21:14:50"), at("21:29:30"), at("21:30:45"), at("21:45:20"), at("21:45:30"), {}}
+	for i := range want {
+		if !got[i].StartTime.Equal(want[i]) {
+			t.Errorf("%s: wanted %v, but got %v", got[i].Title, want[i], got[i].StartTime)
+		}
+	}
+	if !plays[1].StartTime.Equal(at("21:15:00")) {
+		t.Error("wanted the plays given not to be changed")
+	}
+}
+
+func TestStore_PlaysWithBoundaries(t *testing.T) {
+	st := newTestStore(t)
+	start := mustParseDate("2024-06-01T21:15:00")
+	if _, err := st.recordPlay(Track{Title: "Ghost", StartTime: start}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := st.recordBoundary(boundary{Time: start.Add(-20 * time.Second), Source: "silence"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plays, err := st.plays()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := start.Add(-20 * time.Second); len(plays) != 1 || !plays[0].StartTime.Equal(want) {
+		t.Errorf("wanted the play to start at %v, but got %v", want, plays)
+	}
+	// The play is still recognized as recorded by its reported start time.
+	if recorded, err := st.recordPlay(Track{Title: "Ghost", StartTime: start}); err != nil || recorded {
+		t.Errorf("wanted the play not to be recorded again, but got %t (%v)", recorded, err)
+	}
+}
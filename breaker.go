@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold is how many requests in a row must fail for a
+	// service's circuit breaker to open, and breakerCooldown how long it
+	// then stays open before a request is let through to try again.
+	breakerThreshold = 5
+	breakerCooldown  = 2 * time.Minute
+)
+
+// The circuit breakers of the services ph calls, shared across the
+// process.
+var (
+	radioCoBreaker   = newCircuitBreaker("radio.co", breakerThreshold, breakerCooldown, time.Now)
+	relistenBreaker  = newCircuitBreaker("Relisten", breakerThreshold, breakerCooldown, time.Now)
+	phishNetBreaker  = newCircuitBreaker("phish.net", breakerThreshold, breakerCooldown, time.Now)
+	setlistFMBreaker = newCircuitBreaker("setlist.fm", breakerThreshold, breakerCooldown, time.Now)
+	spotifyBreaker   = newCircuitBreaker("Spotify", breakerThreshold, breakerCooldown, time.Now)
+	lyricsBreaker    = newCircuitBreaker("lyrics", breakerThreshold, breakerCooldown, time.Now)
+)
+
+// circuitOpenError is returned instead of calling a service whose circuit
+// breaker is open.
+type circuitOpenError struct {
+	Service string
+	Until   time.Time
+	// Trying is set when the cooldown is over, but another request is
+	// already trying the service again.
+	Trying bool
+}
+
+func (e *circuitOpenError) Error() string {
+	if e.Trying {
+		return fmt.Sprintf("%s is failing; waiting on a request trying it again", e.Service)
+	}
+	return fmt.Sprintf("%s is failing; not trying it again until %s", e.Service, e.Until.Local().Format("15:04:05"))
+}
+
+// circuitBreaker stops calling a service that keeps failing, so that ph
+// neither waits on it nor logs every failure while it is down. Once
+// threshold requests in a row have failed, the breaker opens, and requests
+// fail at once with a *circuitOpenError for the cooldown. After that a
+// single trial request is let through (the breaker is half-open), and the
+// breaker closes if it succeeds, or opens again if it fails. Other requests
+// are refused until the trial has a result. It is safe for concurrent use.
+type circuitBreaker struct {
+	service   string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func newCircuitBreaker(service string, threshold int, cooldown time.Duration, now func() time.Time) *circuitBreaker {
+	return &circuitBreaker{service: service, threshold: threshold, cooldown: cooldown, now: now}
+}
+
+// allow returns a *circuitOpenError if the breaker is open, or if it is
+// half-open and a trial request is already under way. Otherwise a request
+// allowed while half-open is the trial, and its caller must record its
+// result or release it.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	if b.now().Before(b.openUntil) {
+		return &circuitOpenError{Service: b.service, Until: b.openUntil}
+	}
+	if b.trial {
+		return &circuitOpenError{Service: b.service, Until: b.openUntil, Trying: true}
+	}
+	b.trial = true
+	return nil
+}
+
+// release gives up the trial request, if one is under way, without a
+// result, so that the next request is let through to try in its place.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// record counts the outcome of a request, opening the breaker when too many
+// have failed in a row. Opening is logged once, rather than each request
+// refused.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil {
+		if b.failures >= b.threshold {
+			warn(fmt.Errorf("%s is responding again", b.service))
+		}
+		b.failures, b.openUntil = 0, time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+		if b.failures == b.threshold {
+			warn(fmt.Errorf("%s failed %d times in a row, last with: %w; not trying it again for %s", b.service, b.failures, err, b.cooldown))
+		}
+	}
+}
+
+// breakerTransport sends requests through a circuit breaker. Errors and
+// server errors count as failures; requests canceled by the caller count
+// as neither.
+type breakerTransport struct {
+	base    http.RoundTripper
+	breaker *circuitBreaker
+}
+
+func (t breakerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := t.breaker.allow(); err != nil {
+		return nil, err
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(r)
+	switch {
+	case errors.Is(r.Context().Err(), context.Canceled):
+		t.breaker.release()
+	case err != nil:
+		t.breaker.record(err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		t.breaker.record(errors.New(resp.Status))
+	default:
+		t.breaker.record(nil)
+	}
+	return resp, err
+}
+
+// withBreaker returns a copy of client that sends its requests through b.
+func withBreaker(client *http.Client, b *circuitBreaker) *http.Client {
+	c := *client
+	c.Transport = breakerTransport{base: client.Transport, breaker: b}
+	return &c
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var (
+		now     = time.Date(2020, 7, 4, 18, 0, 0, 0, time.UTC)
+		b       = newCircuitBreaker("radio.co", 3, time.Minute, func() time.Time { return now })
+		failure = errors.New("connection refused")
+	)
+	defer func(saved func(error)) { warn = saved }(warn)
+	warn = func(error) {}
+
+	tt := []struct {
+		desc      string
+		advance   time.Duration
+		noRequest bool
+		result    error
+		wantOpen  bool
+	}{
+		{desc: "first failure", result: failure},
+		{desc: "success resets", result: nil},
+		{desc: "failure 1", result: failure},
+		{desc: "failure 2", result: failure},
+		{desc: "failure 3 opens", result: failure, wantOpen: true},
+		{desc: "still cooling down", advance: 30 * time.Second, noRequest: true, wantOpen: true},
+		{desc: "trial fails, reopens", advance: 30 * time.Second, result: failure, wantOpen: true},
+		{desc: "trial succeeds, closes", advance: time.Minute, result: nil},
+	}
+	for _, tc := range tt {
+		now = now.Add(tc.advance)
+		if !tc.noRequest {
+			if err := b.allow(); err != nil {
+				t.Fatalf("%s: wanted the request allowed, but got %v", tc.desc, err)
+			}
+			b.record(tc.result)
+		}
+		var open *circuitOpenError
+		if got := errors.As(b.allow(), &open); got != tc.wantOpen {
+			t.Errorf("%s: wanted open %t, but got %t", tc.desc, tc.wantOpen, got)
+		}
+	}
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	var (
+		now     = time.Date(2020, 7, 4, 18, 0, 0, 0, time.UTC)
+		b       = newCircuitBreaker("radio.co", 1, time.Minute, func() time.Time { return now })
+		failure = errors.New("connection refused")
+	)
+	defer func(saved func(error)) { warn = saved }(warn)
+	warn = func(error) {}
+
+	b.record(failure)
+	now = now.Add(time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("wanted the trial request allowed, but got %v", err)
+	}
+	var open *circuitOpenError
+	if err := b.allow(); !errors.As(err, &open) || !open.Trying {
+		t.Fatalf("wanted a second request refused during the trial, but got %v", err)
+	}
+	b.release()
+	if err := b.allow(); err != nil {
+		t.Fatalf("wanted a request allowed after the trial was released, but got %v", err)
+	}
+	b.record(nil)
+	for i := 0; i < 2; i++ {
+		if err := b.allow(); err != nil {
+			t.Errorf("wanted requests allowed once closed, but got %v", err)
+		}
+	}
+}
+
+func TestBreakerTransport(t *testing.T) {
+	code := http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+	defer func(saved func(error)) { warn = saved }(warn)
+	var warnings []error
+	warn = func(err error) { warnings = append(warnings, err) }
+
+	var (
+		b      = newCircuitBreaker("radio.co", 2, time.Minute, time.Now)
+		client = withBreaker(srv.Client(), b)
+	)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+	var open *circuitOpenError
+	if _, err := client.Get(srv.URL); !errors.As(err, &open) {
+		t.Errorf("wanted the circuit open after two server errors, but got %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("wanted 1 warning, but got %d: %v", len(warnings), warnings)
+	}
+}
+
+func TestGetStatus_Stale(t *testing.T) {
+	defer func(g *statusGroup, b *circuitBreaker, w func(error)) {
+		statusRequests, radioCoBreaker, warn = g, b, w
+	}(statusRequests, radioCoBreaker, warn)
+	warn = func(error) {}
+	statusRequests = newStatusGroup(time.Now, time.Sleep)
+	radioCoBreaker = newCircuitBreaker("radio.co", 1, time.Minute, time.Now)
+
+	want := statusResponseBody{CurrentTrack: Track{Artist: "Phish", Title: "Reba"}}
+	statusRequests.do(context.Background(), "s1", func() (statusResponseBody, error) {
+		return want, nil
+	})
+	radioCoBreaker.record(errors.New("connection refused"))
+
+	status, err := getStatus(context.Background(), radioCoClient{client: http.DefaultClient}, "s1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Stale == nil {
+		t.Fatal("wanted the status marked stale")
+	}
+	if got := status.CurrentTrack.Title; got != want.CurrentTrack.Title {
+		t.Errorf("wanted %q, but got %q", want.CurrentTrack.Title, got)
+	}
+}
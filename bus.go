@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Events published on the bus. Subscribers receive them as interface{}
+// values and switch on their type, ignoring types they do not handle.
+type (
+	// statusFetched is published each time the station status is fetched.
+	statusFetched struct {
+		Status statusResponseBody
+		Time   time.Time
+	}
+
+	// statusFailed is published when the station status cannot be
+	// fetched.
+	statusFailed struct {
+		Err  error
+		Time time.Time
+	}
+)
+
+// bus delivers events from publishers, such as the poller, to every
+// subscriber, such as the play recorder and the HTTP server. Each
+// subscriber receives every event in the order it was published, on its
+// own channel, so subscribers can be written and tested independently.
+type bus struct {
+	mu     sync.Mutex
+	subs   []chan interface{}
+	closed bool
+}
+
+// subscribe returns a channel that receives every event published after
+// the call. The channel is closed when the bus is closed.
+func (b *bus) subscribe(buffer int) <-chan interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan interface{}, buffer)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subs = append(b.subs, ch)
+	return ch
+}
+
+// publish sends e to every subscriber. It blocks until every subscriber
+// has room for the event, so that a slow subscriber delays events rather
+// than missing them. Events published after the bus is closed are dropped.
+func (b *bus) publish(e interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	for _, ch := range b.subs {
+		ch <- e
+	}
+}
+
+// close closes every subscriber's channel, telling subscribers that no more
+// events will be published.
+func (b *bus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, ch := range b.subs {
+		close(ch)
+	}
+}
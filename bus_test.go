@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBus(t *testing.T) {
+	var (
+		b      = &bus{}
+		first  = b.subscribe(2)
+		second = b.subscribe(2)
+	)
+	b.publish("one")
+	b.publish("two")
+	b.close()
+	b.publish("three")
+
+	for i, ch := range []<-chan interface{}{first, second} {
+		var got []interface{}
+		for e := range ch {
+			got = append(got, e)
+		}
+		if len(got) != 2 || got[0] != "one" || got[1] != "two" {
+			t.Errorf("subscriber %d: wanted [one two], but got %v", i, got)
+		}
+	}
+	if _, ok := <-b.subscribe(1); ok {
+		t.Errorf("wanted subscribing to a closed bus to return a closed channel")
+	}
+}
+
+func TestStore_RecordPlays(t *testing.T) {
+	var (
+		st     = newTestStore(t)
+		events = make(chan interface{}, 3)
+		reba   = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:00:00")}
+	)
+	events <- statusFetched{Status: statusResponseBody{CurrentTrack: reba}}
+	events <- statusFailed{Err: errors.New("connection refused")}
+	events <- statusFetched{Status: statusResponseBody{CurrentTrack: reba}}
+	close(events)
+	st.recordPlays(newPlayRing(10), events, func(err error) {
+		t.Errorf("unexpected error: %v", err)
+	})
+
+	plays, err := st.plays()
+	if err != nil {
+		t.Fatalf("unexpected error reading plays: %v", err)
+	}
+	if len(plays) != 1 || plays[0].Title != "Reba" {
+		t.Errorf("wanted a single play of Reba, but got %v", plays)
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	var (
+		now    = mustParseDate("2020-06-05T20:00:00")
+		s      = newTestServer(&now)
+		events = make(chan interface{}, 2)
+	)
+	events <- statusFetched{Status: statusResponseBody{CurrentTrack: Track{Title: "Reba"}}, Time: now}
+	events <- statusFailed{Err: errors.New("connection refused"), Time: now.Add(time.Minute)}
+	close(events)
+	s.run(events)
+
+	if got := s.status.CurrentTrack.Title; got != "Reba" {
+		t.Errorf("wanted current track Reba, but got %q", got)
+	}
+	if s.lastErr == nil {
+		t.Errorf("wanted the failed poll to be recorded")
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// pathPhishNetSongs is the phish.net song catalog, relative to the
+	// phish.net endpoint, given an API key.
+	pathPhishNetSongs = "/v5/songs.json?apikey=%s"
+
+	// phishNetSongsFile caches the phish.net song catalog in the cache
+	// directory for phishNetSongsTTL.
+	phishNetSongsFile = "phishnet-songs.json"
+	phishNetSongsTTL  = 7 * 24 * time.Hour
+)
+
+// catalogConfig configures the correction of song titles against catalogs
+// of known songs.
+type catalogConfig struct {
+	Autocorrect    bool   `yaml:"autocorrect,omitempty" doc:"Correct misspelled song titles against known song catalogs"`
+	PhishNetAPIKey string `yaml:"phishnet_api_key,omitempty" doc:"phish.net API key, used to fetch the Phish song catalog and setlists"`
+	SongsDir       string `yaml:"songs_dir,omitempty" doc:"Directory of song lists named <artist>.txt, one title per line (default: songs in the config directory)"`
+}
+
+// songsDir returns the directory of per-artist song lists.
+func (c catalogConfig) songsDir() (string, error) {
+	if c.SongsDir != "" {
+		return c.SongsDir, nil
+	}
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "songs"), nil
+}
+
+// songCatalog holds the known song titles of each artist, keyed by the
+// artist's name in lower case.
+type songCatalog map[string][]string
+
+func (c songCatalog) add(artist string, titles ...string) {
+	key := strings.ToLower(artist)
+	c[key] = append(c[key], titles...)
+}
+
+// correctTitle corrects each song in a broadcast title, which may contain
+// several songs joined by segue markers, to the closest title the artist is
+// known to have played. Songs that are not close to any known title are
+// left alone. It reports whether any song was corrected.
+func (c songCatalog) correctTitle(artist, title string) (string, bool) {
+	known := c[strings.ToLower(artist)]
+	if len(known) == 0 {
+		return title, false
+	}
+	byNormal := make(map[string]string, len(known))
+	normals := make([]string, 0, len(known))
+	for _, k := range known {
+		n := normalizeSongTitle(k)
+		if _, ok := byNormal[n]; !ok {
+			byNormal[n] = k
+			normals = append(normals, n)
+		}
+	}
+	var (
+		b         strings.Builder
+		corrected bool
+		start     int
+	)
+	// Walk the title song by song, keeping the segue markers between them.
+	for i, r := range title + ">" {
+		if r != '>' && r != '→' {
+			continue
+		}
+		song := title[start:i]
+		start = i + len(string(r))
+		if fixed, ok := c.correctSong(song, byNormal, normals); ok {
+			song, corrected = fixed, true
+		}
+		b.WriteString(song)
+		if i < len(title) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), corrected
+}
+
+// correctSong corrects a single song title. A title is only corrected when
+// it is a small number of edits from a known title, relative to its length,
+// so that songs missing from the catalog are not replaced by unrelated ones.
+func (c songCatalog) correctSong(song string, byNormal map[string]string, normals []string) (string, bool) {
+	n := normalizeSongTitle(song)
+	if n == "" {
+		return song, false
+	}
+	if _, ok := byNormal[n]; ok {
+		return song, false
+	}
+	maxDistance := len(n) / 6
+	if maxDistance == 0 {
+		return song, false
+	}
+	match, ok := closestMatch(n, normals, maxDistance)
+	if !ok {
+		return song, false
+	}
+	// Keep any whitespace around the song, as in "Mercury > Simple".
+	lead := song[:len(song)-len(strings.TrimLeft(song, " "))]
+	trail := song[len(strings.TrimRight(song, " ")):]
+	return lead + byNormal[match] + trail, true
+}
+
+// correct returns the track with its title corrected, keeping the title as
+// broadcast in RawTitle. Full shows and station breaks are not corrected.
+func (c songCatalog) correct(t Track) Track {
+	if t.IsFullShow() || t.IsStationBreak() {
+		return t
+	}
+	if title, ok := c.correctTitle(t.Artist, t.Title); ok {
+		t.RawTitle, t.Title = t.Title, title
+	}
+	return t
+}
+
+// correctAll returns a copy of tl with every title corrected.
+func (c songCatalog) correctAll(tl TrackList) TrackList {
+	corrected := make(TrackList, len(tl))
+	for i, t := range tl {
+		corrected[i] = c.correct(t)
+	}
+	return corrected
+}
+
+// loadSongCatalog loads the song lists in the songs directory, and the
+// phish.net song catalog if an API key is configured. Catalogs that cannot
+// be loaded are reported as warnings, since correction is best effort.
+func loadSongCatalog(ctx context.Context, client *http.Client, cfg config) songCatalog {
+	catalog := make(songCatalog)
+	if dir, err := cfg.Catalog.songsDir(); err == nil {
+		if err := catalog.loadSongsDir(dir); err != nil {
+			warn(fmt.Errorf("unable to load song lists: %w", err))
+		}
+	}
+	if key := cfg.Catalog.PhishNetAPIKey; key != "" {
+		songs, err := phishNetSongs(ctx, client, cfg, key)
+		if err != nil {
+			warn(fmt.Errorf("unable to get phish.net song catalog: %w", err))
+		}
+		catalog.add("Phish", songs...)
+	}
+	return catalog
+}
+
+// loadSongsDir adds the song lists in dir, which are named for the artist,
+// as in "Goose.txt". A missing directory is not an error.
+func (c songCatalog) loadSongsDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		var (
+			artist = strings.TrimSuffix(filepath.Base(path), ".txt")
+			sc     = bufio.NewScanner(f)
+		)
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				c.add(artist, line)
+			}
+		}
+		f.Close()
+		if err := sc.Err(); err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// phishNetSongs returns the titles of every song in the phish.net catalog,
+// which is cached for a week. Fetching it is abandoned when ctx is done, or
+// after the phish.net timeout. If it cannot be fetched, the cached catalog
+// is used however old it is, with a warning.
+func phishNetSongs(ctx context.Context, client *http.Client, cfg config, apiKey string) ([]string, error) {
+	var (
+		cachePath string
+		cached    []string
+		cachedAt  time.Time
+	)
+	if dir, err := cfg.cacheDir(); err == nil {
+		cachePath = filepath.Join(dir, phishNetSongsFile)
+		if info, err := os.Stat(cachePath); err == nil {
+			if b, err := ioutil.ReadFile(cachePath); err == nil && json.Unmarshal(b, &cached) == nil {
+				cachedAt = info.ModTime()
+			}
+		}
+	}
+	if !cachedAt.IsZero() && appClock.Now().Sub(cachedAt) < phishNetSongsTTL {
+		return cached, nil
+	}
+
+	songs, err := fetchPhishNetSongs(ctx, client, cfg.Endpoints.phishNet(), cfg.Timeouts.phishNet(), apiKey)
+	if err != nil {
+		if cachedAt.IsZero() {
+			return nil, err
+		}
+		warn(fmt.Errorf("using the phish.net song catalog from %s: %w", cachedAt.Format("2-Jan-2006"), err))
+		return cached, nil
+	}
+	if cachePath != "" {
+		if b, err := json.Marshal(songs); err == nil {
+			if err := os.MkdirAll(filepath.Dir(cachePath), os.FileMode(0777)); err == nil {
+				writeFileAtomic(cachePath, b)
+			}
+		}
+	}
+	return songs, nil
+}
+
+// fetchPhishNetSongs asks phish.net, at endpoint, for the titles of every
+// song in its catalog, through the phish.net circuit breaker, giving up
+// after timeout.
+func fetchPhishNetSongs(ctx context.Context, client *http.Client, endpoint string, timeout time.Duration, apiKey string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+fmt.Sprintf(pathPhishNetSongs, url.QueryEscape(apiKey)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := withBreaker(client, phishNetBreaker).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("phish.net: %s", resp.Status)
+	}
+	var body struct {
+		Error        bool   `json:"error"`
+		ErrorMessage string `json:"error_message"`
+		Data         []struct {
+			Song string `json:"song"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode phish.net songs: %w", err)
+	}
+	if body.Error {
+		return nil, fmt.Errorf("phish.net: %s", body.ErrorMessage)
+	}
+	songs := make([]string, 0, len(body.Data))
+	for _, d := range body.Data {
+		songs = append(songs, d.Song)
+	}
+	return songs, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSongCatalog_CorrectTitle(t *testing.T) {
+	catalog := make(songCatalog)
+	catalog.add("Phish", "Tweezer", "Tweezer Reprise", "Mercury", "Simple", "Death Don't Hurt Very Long", "Reba")
+	tt := []struct {
+		desc   string
+		artist string
+		title  string
+		want   string
+		wantOK bool
+	}{
+		{desc: "typo", artist: "Phish", title: "Tweezzer", want: "Tweezer", wantOK: true},
+		{desc: "artist in any case", artist: "PHISH", title: "Tweezzer", want: "Tweezer", wantOK: true},
+		{desc: "correct title", artist: "Phish", title: "Tweezer Reprise", want: "Tweezer Reprise"},
+		{desc: "different punctuation", artist: "Phish", title: "tweezer reprise!", want: "tweezer reprise!"},
+		{desc: "typo in segue", artist: "Phish", title: "Mercury > Deth Don't Hurt Very Long", want: "Mercury > Death Don't Hurt Very Long", wantOK: true},
+		{desc: "unknown song", artist: "Phish", title: "Ghost", want: "Ghost"},
+		{desc: "short title", artist: "Phish", title: "Rebb", want: "Rebb"},
+		{desc: "unknown artist", artist: "Goose", title: "Tweezzer", want: "Tweezzer"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := catalog.correctTitle(tc.artist, tc.title)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("wanted %q (%v), but got %q (%v)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestSongCatalog_Correct(t *testing.T) {
+	catalog := make(songCatalog)
+	catalog.add("Phish", "Tweezer")
+
+	got := catalog.correct(Track{Artist: "Phish", Title: "Tweezzer"})
+	if got.Title != "Tweezer" || got.RawTitle != "Tweezzer" {
+		t.Errorf("wanted Tweezer corrected from Tweezzer, but got %q from %q", got.Title, got.RawTitle)
+	}
+	got = catalog.correct(Track{Artist: "Phish", Title: "Tweezer"})
+	if got.RawTitle != "" {
+		t.Errorf("wanted no raw title for a correct title, but got %q", got.RawTitle)
+	}
+	show := Track{Artist: "Phish", Title: "31-Dec-1995 Tweezzer Set 2", Set: &ShowSet{Number: 2}}
+	if got := catalog.correct(show); got != show {
+		t.Errorf("wanted full show to be left alone, but got %+v", got)
+	}
+}
+
+func TestSongCatalog_LoadSongsDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-songs")
+	if err != nil {
+		t.Fatalf("unable to create songs dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	list := "# Goose originals\nArcadia\n\nHot Tea\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "Goose.txt"), []byte(list), 0644); err != nil {
+		t.Fatalf("unable to write song list: %v", err)
+	}
+
+	catalog := make(songCatalog)
+	if err := catalog.loadSongsDir(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := catalog["goose"]; len(got) != 2 || got[0] != "Arcadia" || got[1] != "Hot Tea" {
+		t.Errorf("wanted [Arcadia Hot Tea], but got %v", got)
+	}
+	if err := catalog.loadSongsDir(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("wanted no error for a missing directory, but got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// sparkBlocks are the characters used to draw sparklines, from lowest to
+// highest.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders values as a single line of block characters, scaled so
+// that the largest value is a full block. Zero values are drawn as spaces.
+func sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		if v <= 0 || max == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparkBlocks[(v*len(sparkBlocks)-1)/max])
+	}
+	return b.String()
+}
+
+// textBar returns a bar of block characters for n out of max, at most width
+// characters long. Any nonzero n gets at least one block.
+func textBar(n, max, width int) string {
+	if max <= 0 || n <= 0 {
+		return ""
+	}
+	l := n * width / max
+	if l == 0 {
+		l = 1
+	}
+	return strings.Repeat("█", l)
+}
+
+// writeBarChart writes a horizontal bar chart with a row for each tally,
+// with bars scaled so the longest is width characters.
+func writeBarChart(w io.Writer, rows []tally, width int) error {
+	var maxName, maxCount int
+	for _, r := range rows {
+		if n := utf8.RuneCountInString(r.Name); n > maxName {
+			maxName = n
+		}
+		if r.Count > maxCount {
+			maxCount = r.Count
+		}
+	}
+	for _, r := range rows {
+		pad := strings.Repeat(" ", maxName-utf8.RuneCountInString(r.Name))
+		line := strings.TrimRight(fmt.Sprintf("%s%s %5d %s", r.Name, pad, r.Count, textBar(r.Count, maxCount, width)), " ")
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
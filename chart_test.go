@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextBar(t *testing.T) {
+	tt := []struct {
+		n, max, width int
+		want          int
+	}{
+		{n: 0, max: 10, width: 10, want: 0},
+		{n: 10, max: 10, width: 10, want: 10},
+		{n: 5, max: 10, width: 10, want: 5},
+		{n: 1, max: 100, width: 10, want: 1},
+		{n: 3, max: 0, width: 10, want: 0},
+	}
+	for _, tc := range tt {
+		if got := len([]rune(textBar(tc.n, tc.max, tc.width))); got != tc.want {
+			t.Errorf("textBar(%d, %d, %d): wanted %d blocks, but got %d", tc.n, tc.max, tc.width, tc.want, got)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tt := []struct {
+		desc   string
+		values []int
+		want   string
+	}{
+		{desc: "empty", values: nil, want: ""},
+		{desc: "all zero", values: []int{0, 0}, want: "  "},
+		{desc: "scaled", values: []int{0, 1, 4, 8}, want: " ▁▄█"},
+		{desc: "constant", values: []int{3, 3, 3}, want: "███"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := sparkline(tc.values); got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteBarChart(t *testing.T) {
+	var b strings.Builder
+	writeBarChart(&b, []tally{{"Phish", 4}, {"Grateful Dead", 2}, {"Goose", 0}}, 8)
+	want := "Phish             4 ████████\n" +
+		"Grateful Dead     2 ████\n" +
+		"Goose             0\n"
+	if got := b.String(); got != want {
+		t.Errorf("wanted:\n%s\nbut got:\n%s", want, got)
+	}
+}
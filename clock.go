@@ -0,0 +1,36 @@
+package main
+
+import "time"
+
+// clock tells the time and makes tickers. Code that depends on the time,
+// such as how long ago a track started, how old a cached response is, or
+// when to poll next, asks a clock rather than the time package, so that
+// tests can set the time and move it along without waiting.
+type clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) ticker
+}
+
+// ticker delivers ticks on a channel at intervals, like a time.Ticker.
+type ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// appClock is the clock ph runs on; tests replace it.
+var appClock clock = realClock{}
+
+// realClock is the system's clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
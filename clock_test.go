@@ -0,0 +1,102 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to. Its tickers tick as
+// Advance moves past each of their intervals, dropping ticks that are not
+// received, as a time.Ticker does.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	clock    *fakeClock
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+	stopped  bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+// useFakeClock replaces appClock with a fakeClock set to now for the rest of
+// the test.
+func useFakeClock(t *testing.T, now time.Time) *fakeClock {
+	t.Helper()
+	c := &fakeClock{now: now}
+	saved := appClock
+	appClock = c
+	t.Cleanup(func() { appClock = saved })
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), interval: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock along by d, ticking the tickers it passes.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.stopped && !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.interval)
+		}
+	}
+}
+
+func TestFakeClock_Ticker(t *testing.T) {
+	var (
+		c  = &fakeClock{now: mustParseDate("2020-07-04T18:00:00")}
+		tk = c.NewTicker(time.Minute)
+	)
+	c.Advance(30 * time.Second)
+	select {
+	case <-tk.C():
+		t.Fatal("wanted no tick before the interval")
+	default:
+	}
+	c.Advance(30 * time.Second)
+	select {
+	case got := <-tk.C():
+		if want := mustParseDate("2020-07-04T18:01:00"); !got.Equal(want) {
+			t.Errorf("wanted tick at %s, but got %s", want, got)
+		}
+	default:
+		t.Fatal("wanted a tick after the interval")
+	}
+	tk.Stop()
+	c.Advance(time.Hour)
+	select {
+	case <-tk.C():
+		t.Error("wanted no tick after stopping")
+	default:
+	}
+}
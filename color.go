@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	ansiBold  = "\x1b[1m"
+	ansiCyan  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// useColor reports whether output written to f should be colored: color
+// must not be disabled by configuration, and f must be a terminal that
+// supports it.
+func useColor(cfg config, f *os.File) bool {
+	if cfg.NoColor || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize highlights text output: the first line, which is the track or
+// the table heading, is bold, and lines that are only a link, labeled or
+// not, are cyan.
+func colorize(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case line == "":
+		case i == 0:
+			lines[i] = ansiBold + line + ansiReset
+		case isLinkLine(line):
+			lines[i] = ansiCyan + line + ansiReset
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isLinkLine reports whether line is only a URL, or a link as Link.String
+// writes it, as in "Setlist: https://...".
+func isLinkLine(line string) bool {
+	if i := strings.Index(line, ": "); i > 0 && !strings.Contains(line[:i], "://") {
+		line = line[i+2:]
+	}
+	return (strings.HasPrefix(line, "https://") || strings.HasPrefix(line, "http://")) && !strings.Contains(line, " ")
+}
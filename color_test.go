@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestIsLinkLine(t *testing.T) {
+	tt := []struct {
+		line string
+		want bool
+	}{
+		{"https://relisten.net/phish/1997/11/22", true},
+		{"Stream: https://relisten.net/phish/1997/11/22", true},
+		{"Setlist: https://phish.net/setlists/?d=1997-11-22", true},
+		{"Phish - Reba (Sat 22-Nov-1997)", false},
+		{"Now playing: Phish - Reba", false},
+		{"See https://phish.net for more", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.line, func(t *testing.T) {
+			if got := isLinkLine(tc.line); got != tc.want {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
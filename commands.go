@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// command is a ph subcommand, such as the "auth" in "ph auth login spotify".
+// When the first argument to ph does not name a command, ph falls back to
+// showing the current track or track history.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// commands lists the subcommands that ph supports.
+var commands = []command{
+	{name: "auth", summary: "Log in to or out of third-party services", run: runAuth},
+	{name: "download", summary: "Download the audio of the current track's show", run: runDownload},
+	{name: "queue", summary: "Save shows to listen to later", run: runQueue},
+	{name: "ics", summary: "Export full shows and favorite-artist plays as an iCalendar file", run: runICS},
+	{name: "plugins", summary: "List plugins (ph-<name> executables) found on the PATH", run: runPlugins},
+	{name: "config", summary: "Create, validate, and edit the config file", run: runConfig},
+	{name: "digest", summary: "Summarize the past week or month of plays", run: runDigest},
+	{name: "wrapped", summary: "Write a year in review of the play history", run: runWrapped},
+	{name: "stats", summary: "Chart plays by hour, weekday, and artist", run: runStats},
+	{name: "random", summary: "Pick a random show from Relisten to listen to", run: runRandom},
+	{name: "set", summary: "Show the live show being aired and its setlist so far", run: runSet},
+	{name: "overlay", summary: "Keep a file up to date with the current track for streaming overlays", run: runOverlay},
+	{name: "bot", summary: "Announce tracks and answer commands on IRC, Matrix, or Telegram", run: runBot},
+	{name: "play", summary: "Play the station's stream, showing each track as it airs", run: runPlay},
+	{name: "scrobble", summary: "Show or retry the listens waiting to be scrobbled", run: runScrobble},
+	{name: "tui", summary: "Show a full screen view of the current track and its show's setlist", run: runTUI},
+	{name: "now", summary: "Show the current track and the tracks before it", run: runNow},
+	{name: "at", summary: "Tell what was airing at a given time", run: runAt},
+	{name: "alarm", summary: "Play the stream at a time of day, ramping up the volume", run: runAlarm},
+	{name: "missed", summary: "List the tracks aired since ph play --sleep stopped", run: runMissed},
+	{name: "record", summary: "Record the stream to a file for each track", run: runRecord},
+	{name: "tagfix", summary: "Tag recordings in a directory from the play history", run: runTagfix},
+	{name: "recordings", summary: "List recordings made by ph record, with their loudness", run: runRecordings},
+	{name: "recommend", summary: "Recommend shows related to the show that aired last", run: runRecommend},
+	{name: "song", summary: "Show how often a song has been performed and aired, by year", run: runSong},
+	{name: "lyrics", summary: "Show the lyrics of the current track in the pager", run: runLyrics},
+	{name: "links", summary: "List every link to the current track, and why any service has none", run: runLinks},
+	{name: "station", summary: "Show the station's name, status, streams, and listeners", run: runStation},
+	{name: "compare", summary: "Show the songs and shows other stations aired in common with the station", run: runCompare},
+	{name: "alert", summary: "Get notified when a show, artist, or song airs (ph serve)", run: runAlert},
+	{name: "dnd", summary: "Snooze notifications for a while, or turn the snooze off", run: runDND},
+	{name: "db", summary: "Upgrade local data written by older versions of ph", run: runDB},
+	{name: "debug", summary: "Compare or record statuses of the station, to understand its quirks", run: runDebug},
+	{name: "demo", summary: "Run a command, such as tui, against a simulated station", run: runDemo},
+	{name: "events", summary: "Replay the log of track changes and station outages", run: runEvents},
+	{name: "audit", summary: "List the notifications, scrobbles, and chat messages ph sent on its own", run: runAudit},
+	{name: "serve", summary: "Poll the station and serve its status over HTTP", run: runServe},
+	{name: "healthcheck", summary: "Check the health of a running ph serve", run: runHealthcheck},
+	{name: "doctor", summary: "Check the config, services, cache, and local data, and how to fix problems", run: runDoctor},
+}
+
+// lookupCommand finds the subcommand with the given name.
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// commandsUsage returns a summary of the available subcommands, suitable
+// for appending to usage output.
+func commandsUsage() string {
+	sorted := make([]command, len(commands))
+	copy(sorted, commands)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+	// Summaries are aligned past the longest name.
+	width := 0
+	for _, c := range sorted {
+		if len(c.name) > width {
+			width = len(c.name)
+		}
+	}
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	for _, c := range sorted {
+		fmt.Fprintf(&b, "  %-*s %s\n", width, c.name, c.summary)
+	}
+	return b.String()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandsUsage(t *testing.T) {
+	// Each summary starts in the same column, past the longest name.
+	column := -1
+	for _, line := range strings.Split(strings.TrimSuffix(commandsUsage(), "\n"), "\n")[1:] {
+		c, ok := lookupCommand(strings.Fields(line)[0])
+		if !ok {
+			t.Fatalf("unexpected line %q", line)
+		}
+		at := strings.Index(line, c.summary)
+		if column < 0 {
+			column = at
+		}
+		if at != column || line[at-1] != ' ' {
+			t.Errorf("wanted the summary at column %d, but got %q", column, line)
+		}
+	}
+}
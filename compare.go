@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// stationPlays are the tracks a station aired, most recent first.
+type stationPlays struct {
+	Station string
+	Tracks  TrackList
+}
+
+// sharedPlay is a song, or a show, that more than one station aired, with
+// how many times each aired it.
+type sharedPlay struct {
+	Artist string `json:"artist"`
+
+	// Song is the song's title, for a shared song, and PerformanceTime the
+	// show's date, for a shared show.
+	Song            string     `json:"song,omitempty"`
+	PerformanceTime *time.Time `json:"performance_time,omitempty"`
+	Location        string     `json:"location,omitempty"`
+
+	Airings map[string]int `json:"airings"`
+}
+
+func (p sharedPlay) String() string {
+	s := p.Artist + " - " + p.Song
+	if p.PerformanceTime != nil {
+		s = p.Artist + " - " + p.PerformanceTime.Format("Mon 2-Jan-2006")
+		if p.Location != "" {
+			s += " " + p.Location
+		}
+	}
+	return s
+}
+
+// total is how many times the stations aired p in all.
+func (p sharedPlay) total() int {
+	var n int
+	for _, c := range p.Airings {
+		n += c
+	}
+	return n
+}
+
+// stationSummary counts what a station aired in the window compared.
+type stationSummary struct {
+	Station string `json:"station"`
+	Songs   int    `json:"songs"`
+	Shows   int    `json:"shows"`
+
+	// Shared are how many of the station's songs some other station aired
+	// too.
+	Shared int `json:"shared"`
+}
+
+// comparison is what stations aired in common since a time. Since is the
+// start of the window compared, which is later than asked for when a
+// station's tracks do not reach back that far.
+type comparison struct {
+	Since    time.Time        `json:"since"`
+	Stations []stationSummary `json:"stations"`
+	Songs    []sharedPlay     `json:"songs"`
+	Shows    []sharedPlay     `json:"shows"`
+}
+
+// compareStations finds the songs and the shows that more than one of the
+// stations aired since since, most widely aired first. Songs in a title
+// like "Mercury > Simple" are counted one by one, and station breaks are
+// left out. Every station is compared over the same window, so the window
+// starts no earlier than the oldest track of the station whose tracks
+// reach back the least; tracks without a start time cannot be placed in
+// it, and are left out.
+func compareStations(plays []stationPlays, since time.Time) comparison {
+	since = commonWindowStart(plays, since)
+	c := comparison{Since: since, Songs: []sharedPlay{}, Shows: []sharedPlay{}}
+	var (
+		songs     = make(map[string]*sharedPlay)
+		shows     = make(map[string]*sharedPlay)
+		songOrder []string
+		showOrder []string
+		airedBy   = make(map[string]map[string]bool)
+	)
+	for _, sp := range plays {
+		sum := stationSummary{Station: sp.Station}
+		stationShows := make(map[string]bool)
+		for _, t := range sp.Tracks {
+			if t.StartTime.Before(since) || t.Artist == "" || t.Kind() == trackKindStationBreak {
+				continue
+			}
+			if pt := t.PerformanceTime; !pt.IsZero() {
+				key := strings.ToLower(t.Artist) + "|" + pt.Format("2006-01-02")
+				if shows[key] == nil {
+					pt := pt
+					shows[key] = &sharedPlay{Artist: t.Artist, PerformanceTime: &pt, Location: t.Location, Airings: make(map[string]int)}
+					showOrder = append(showOrder, key)
+				}
+				if !stationShows[key] {
+					stationShows[key] = true
+					shows[key].Airings[sp.Station]++
+					sum.Shows++
+				}
+			}
+			if t.Kind() != trackKindSong {
+				continue
+			}
+			for _, song := range strings.FieldsFunc(t.Title, func(r rune) bool { return r == '>' || r == '→' }) {
+				if song = strings.TrimSpace(song); song == "" {
+					continue
+				}
+				key := strings.ToLower(t.Artist) + "|" + normalizeSongTitle(song)
+				if songs[key] == nil {
+					songs[key] = &sharedPlay{Artist: t.Artist, Song: song, Airings: make(map[string]int)}
+					songOrder = append(songOrder, key)
+					airedBy[key] = make(map[string]bool)
+				}
+				songs[key].Airings[sp.Station]++
+				airedBy[key][sp.Station] = true
+				sum.Songs++
+			}
+		}
+		c.Stations = append(c.Stations, sum)
+	}
+	for i, sum := range c.Stations {
+		for key, by := range airedBy {
+			if by[sum.Station] && len(by) > 1 {
+				c.Stations[i].Shared += songs[key].Airings[sum.Station]
+			}
+		}
+	}
+	c.Songs = sharedPlays(songs, songOrder)
+	c.Shows = sharedPlays(shows, showOrder)
+	return c
+}
+
+// commonWindowStart returns the start of the window that every station's
+// tracks cover since since: the latest of since and the oldest start time
+// of each station's tracks. Stations with no timed tracks are ignored.
+func commonWindowStart(plays []stationPlays, since time.Time) time.Time {
+	start := since
+	for _, sp := range plays {
+		var oldest time.Time
+		for _, t := range sp.Tracks {
+			if st := t.StartTime; !st.IsZero() && (oldest.IsZero() || st.Before(oldest)) {
+				oldest = st
+			}
+		}
+		if oldest.After(start) {
+			start = oldest
+		}
+	}
+	return start
+}
+
+// sharedPlays returns the plays, in order, that more than one station
+// aired, sorted by how many stations aired them, and then by how many
+// times.
+func sharedPlays(plays map[string]*sharedPlay, order []string) []sharedPlay {
+	shared := []sharedPlay{}
+	for _, key := range order {
+		if p := plays[key]; len(p.Airings) > 1 {
+			shared = append(shared, *p)
+		}
+	}
+	sort.SliceStable(shared, func(i, j int) bool {
+		if a, b := len(shared[i].Airings), len(shared[j].Airings); a != b {
+			return a > b
+		}
+		return shared[i].total() > shared[j].total()
+	})
+	return shared
+}
+
+// airingsString lists how many times each station aired a shared play, in
+// the order of stations.
+func airingsString(p sharedPlay, stations []stationSummary) string {
+	var parts []string
+	for _, s := range stations {
+		if n := p.Airings[s.Station]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s ×%d", s.Station, n))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeComparison writes c as text: what each station aired, and then the
+// songs and shows they have in common.
+func writeComparison(b *strings.Builder, c comparison) {
+	if !c.Since.IsZero() {
+		fmt.Fprintf(b, tr("Since %s:")+"\n", c.Since.Local().Format("Mon 15:04"))
+	}
+	for _, s := range c.Stations {
+		fmt.Fprintf(b, tr("%s: %d songs, %d shared; %d shows")+"\n", s.Station, s.Songs, s.Shared, s.Shows)
+	}
+	if len(c.Songs) == 0 && len(c.Shows) == 0 {
+		b.WriteString("\n" + tr("The stations aired nothing in common.") + "\n")
+		return
+	}
+	for _, section := range []struct {
+		title string
+		plays []sharedPlay
+	}{
+		{tr("Songs in common:"), c.Songs},
+		{tr("Shows in common:"), c.Shows},
+	} {
+		if len(section.plays) == 0 {
+			continue
+		}
+		b.WriteString("\n" + section.title + "\n")
+		for _, p := range section.plays {
+			fmt.Fprintf(b, "  %s (%s)\n", p, airingsString(p, c.Stations))
+		}
+	}
+}
+
+// runCompare shows the songs and shows that the station and the stations
+// in the stations setting have aired in common lately.
+func runCompare(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("compare", flag.ExitOnError)
+		since  = fs.Duration("since", 6*time.Hour, "Compare what the stations aired within this long ago")
+		asJSON = fs.Bool("json", false, "Print the comparison as JSON")
+	)
+	fs.Parse(args)
+	stations, err := cfg.stations()
+	if err != nil {
+		return fmt.Errorf("stations: %w", err)
+	}
+	if len(stations) < 2 {
+		return errors.New("no stations to compare with; list them in the stations setting, as name: station ID")
+	}
+
+	var (
+		ctx   = context.Background()
+		from  = time.Now().Add(-*since)
+		plays []stationPlays
+	)
+	for i, s := range stations {
+		sp := stationPlays{Station: s.Name}
+		status, err := getStatus(ctx, newRadioCoClient(httpClient(cfg), cfg), s.ID)
+		if err != nil {
+			warn(fmt.Errorf("unable to get the status of %s: %w", s.Name, err))
+		} else {
+			sp.Tracks = status.tracks()
+		}
+		// radio.co only has the last few tracks a station aired, so the
+		// play history, which ph serve records for the station, fills in
+		// the rest of the window.
+		if i == 0 {
+			sp.Tracks = withRecordedPlays(sp.Tracks, from)
+		}
+		plays = append(plays, sp)
+	}
+	c := compareStations(plays, from)
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(c)
+	}
+	var b strings.Builder
+	writeComparison(&b, c)
+	fmt.Print(b.String())
+	return nil
+}
+
+// withRecordedPlays adds the plays in the play history that started since
+// from, and before the oldest of tracks, to tracks.
+func withRecordedPlays(tracks TrackList, from time.Time) TrackList {
+	st, err := openStore()
+	if err != nil {
+		return tracks
+	}
+	plays, err := st.recordedPlays()
+	if err != nil {
+		warn(fmt.Errorf("read play history: %w", err))
+		return tracks
+	}
+	oldest := time.Now()
+	for _, t := range tracks {
+		if !t.StartTime.IsZero() && t.StartTime.Before(oldest) {
+			oldest = t.StartTime
+		}
+	}
+	for i := len(plays) - 1; i >= 0; i-- {
+		if pt := plays[i].StartTime; !pt.Before(from) && pt.Before(oldest) {
+			tracks = append(tracks, plays[i])
+		}
+	}
+	return tracks
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_Stations(t *testing.T) {
+	c := config{Stations: []string{"Phish Radio: s0a1b2c3d4", "Live: https://example.com"}}
+	got, err := c.stations()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []namedStation{
+		{Name: "JEMP Radio", ID: defaultStation},
+		{Name: "Phish Radio", ID: "s0a1b2c3d4"},
+		{Name: "Live: https", ID: "//example.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+	for _, stations := range [][]string{
+		{"s0a1b2c3d4"},
+		{"Phish Radio: "},
+		{"JEMP: " + defaultStation},
+		{"Phish Radio: s0a1b2c3d4", "Phish Radio: s5e6f7a8b9"},
+	} {
+		if _, err := (config{Stations: stations}).stations(); err == nil {
+			t.Errorf("%v: wanted an error, but got none", stations)
+		}
+	}
+}
+
+func TestCompareStations(t *testing.T) {
+	var (
+		since   = mustParseDate("2020-06-05T18:00:00")
+		hampton = mustParseDate("1997-11-22")
+		at      = func(s string) Track { return Track{StartTime: mustParseDate("2020-06-05T" + s)} }
+		track   = func(start, artist, title string, performed time.Time) Track {
+			t := at(start)
+			t.Artist, t.Title, t.PerformanceTime = artist, title, performed
+			return t
+		}
+		plays = []stationPlays{
+			{Station: "JEMP Radio", Tracks: TrackList{
+				track("21:00:00", "Phish", "Reba", hampton),
+				track("20:30:00", "Phish", "Mercury > Tweezer", hampton),
+				track("20:15:00", "jempradio.com", "Station ID", time.Time{}),
+				track("20:00:00", "Goose", "Arcadia", time.Time{}),
+				track("17:00:00", "Phish", "Harry Hood", time.Time{}),
+			}},
+			{Station: "Phish Radio", Tracks: TrackList{
+				track("21:10:00", "phish", "tweezer", mustParseDate("1995-12-31")),
+				track("20:40:00", "Phish", "Reba", hampton),
+				track("19:00:00", "Phish", "Harry Hood", time.Time{}),
+			}},
+			{Station: "Dead Air", Tracks: TrackList{
+				track("20:00:00", "Goose", "Arcadia", time.Time{}),
+				track("19:30:00", "Phish", "Reba", time.Time{}),
+			}},
+		}
+	)
+	c := compareStations(plays, since)
+
+	var songs []string
+	for _, p := range c.Songs {
+		songs = append(songs, p.String()+" "+airingsString(p, c.Stations))
+	}
+	// Dead Air's tracks only reach back to 19:30, so every station is
+	// compared from then. Reba aired on all three stations, so it comes
+	// first. Harry Hood aired on JEMP Radio and Phish Radio before the
+	// window, and Mercury only on JEMP Radio.
+	want := []string{
+		"Phish - Reba JEMP Radio ×1, Phish Radio ×1, Dead Air ×1",
+		"Phish - Tweezer JEMP Radio ×1, Phish Radio ×1",
+		"Goose - Arcadia JEMP Radio ×1, Dead Air ×1",
+	}
+	if !reflect.DeepEqual(songs, want) {
+		t.Errorf("wanted songs\n%v\nbut got\n%v", strings.Join(want, "\n"), strings.Join(songs, "\n"))
+	}
+	if b, _ := json.Marshal(c.Songs[0]); strings.Contains(string(b), "performance_time") {
+		t.Errorf("wanted no performance time for a shared song, but got %s", b)
+	}
+	if want := mustParseDate("2020-06-05T19:30:00"); !c.Since.Equal(want) {
+		t.Errorf("wanted the window to start at %v, but got %v", want, c.Since)
+	}
+	if len(c.Shows) != 1 || !c.Shows[0].PerformanceTime.Equal(hampton) || c.Shows[0].total() != 2 {
+		t.Errorf("wanted the Hampton show aired on two stations, but got %v", c.Shows)
+	}
+	wantStations := []stationSummary{
+		{Station: "JEMP Radio", Songs: 4, Shows: 1, Shared: 3},
+		{Station: "Phish Radio", Songs: 2, Shows: 2, Shared: 2},
+		{Station: "Dead Air", Songs: 2, Shared: 2},
+	}
+	if !reflect.DeepEqual(c.Stations, wantStations) {
+		t.Errorf("wanted stations %v, but got %v", wantStations, c.Stations)
+	}
+}
+
+func TestWriteComparison(t *testing.T) {
+	c := comparison{Stations: []stationSummary{{Station: "JEMP Radio", Songs: 2}, {Station: "Phish Radio", Songs: 1}}}
+	var b strings.Builder
+	writeComparison(&b, c)
+	want := "JEMP Radio: 2 songs, 0 shared; 0 shows\nPhish Radio: 1 songs, 0 shared; 0 shows\n\nThe stations aired nothing in common.\n"
+	if got := b.String(); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
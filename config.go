@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const configFile = "config.yaml"
+
+// config holds user settings loaded from the ph configuration file. A
+// missing configuration file is not an error; every setting has a usable
+// zero value or a default applied where it is used.
+//
+// The doc tag on each field describes the setting; it is used to generate
+// the commented configuration file written by "ph config init".
+type config struct {
+	Format   string `yaml:"format,omitempty" doc:"Default output format: text, json, yaml, plain, alfred, widget (ph now only), or events (ph set --watch only)"`
+	Station  string `yaml:"station,omitempty" doc:"radio.co station ID to show (default: JEMP Radio)"`
+	CacheDir string `yaml:"cache_dir,omitempty" doc:"Directory for cached data (default: the ph directory in the user cache directory)"`
+	NoColor  bool   `yaml:"no_color,omitempty" doc:"Disable colored output"`
+	Locale   string `yaml:"locale,omitempty" doc:"Language for messages, e.g. es or pt_BR, from a catalog made with ph config translate (default: from LANG)"`
+
+	NoHistory bool `yaml:"no_history,omitempty" doc:"Don't record plays and station events in the data directory when ph shows the station"`
+
+	Stations []string `yaml:"stations,omitempty" doc:"Other radio.co stations to compare the station with in ph compare, as name: station ID, e.g. [\"Phish Radio: s0a1b2c3d4\"]"`
+
+	StationBreaks     bool `yaml:"station_breaks,omitempty" doc:"List station breaks along with music, as --include-breaks does"`
+	DurationPrecision int  `yaml:"duration_precision,omitempty" doc:"How many units to show in how long ago a track started, e.g. 2 for 2d1h ago (default: 2)"`
+
+	ElapsedPrecision string `yaml:"elapsed_precision,omitempty" doc:"Truncate how long ago tracks started to the minute or second (default: minute)"`
+
+	Favorites favoritesConfig `yaml:"favorites,omitempty" doc:"Music to highlight"`
+	Spotify   spotifyConfig   `yaml:"spotify,omitempty" doc:"Spotify integration; log in with ph auth login spotify"`
+	Mastodon  mastodonConfig  `yaml:"mastodon,omitempty" doc:"Mastodon integration; log in with ph auth login mastodon"`
+	Plugins   pluginsConfig   `yaml:"plugins,omitempty" doc:"Plugins (ph-<name> executables on the PATH) to run at extension points"`
+	Serve     serveConfig     `yaml:"serve,omitempty" doc:"Serve mode (ph serve)"`
+	Notify    notifyConfig    `yaml:"notify,omitempty" doc:"Where to send notifications, such as listening digests"`
+	Scrobble  scrobbleConfig  `yaml:"scrobble,omitempty" doc:"Where ph play submits the tracks you listen to"`
+	Catalog   catalogConfig   `yaml:"catalog,omitempty" doc:"Song catalogs used to correct misspelled titles"`
+	Overlay   overlayConfig   `yaml:"overlay,omitempty" doc:"Now-playing text file for streaming overlays (ph overlay)"`
+	Bot       botConfig       `yaml:"bot,omitempty" doc:"Chat bot that announces tracks and answers commands (ph bot)"`
+	Play      playConfig      `yaml:"play,omitempty" doc:"Playing the station's stream (ph play)"`
+	Record    recordConfig    `yaml:"record,omitempty" doc:"Recording the station's stream (ph record)"`
+	Network   networkConfig   `yaml:"network,omitempty" doc:"Proxy and TLS settings for reaching the station and other services"`
+	Programs  programsConfig  `yaml:"programs,omitempty" doc:"Programming blocks, such as themed hours, that the station airs"`
+	Timeouts  timeoutsConfig  `yaml:"timeouts,omitempty" doc:"How long to wait for each service to respond before giving up"`
+	Endpoints endpointsConfig `yaml:"endpoints,omitempty" doc:"Where to reach each service, if not at its usual address"`
+	Relisten  relistenConfig  `yaml:"relisten,omitempty" doc:"Which artists are linked to shows on Relisten"`
+	Setlists  setlistsConfig  `yaml:"setlists,omitempty" doc:"Where setlists are linked to and fetched from"`
+
+	// sources are the artist sources readConfig loaded from
+	// artistSourcesFile, followed by the defaults.
+	sources []artistSource
+}
+
+// favoritesConfig lists the music the user most cares about, which ph
+// highlights in features such as calendar exports.
+type favoritesConfig struct {
+	Artists []string `yaml:"artists,omitempty" doc:"Artists whose plays are highlighted, e.g. [Phish, Goose]"`
+}
+
+// spotifyConfig holds settings for the Spotify integration. Spotify
+// requires each user to register their own application, so the client ID
+// must be provided.
+type spotifyConfig struct {
+	ClientID     string `yaml:"client_id,omitempty" doc:"Client ID of your Spotify developer application"`
+	RedirectPort int    `yaml:"redirect_port,omitempty" doc:"Port of the redirect URI registered with the application (default 8888)"`
+}
+
+// mastodonConfig holds settings for the Mastodon integration. ph registers
+// itself as an application on the instance when logging in, so only the
+// instance URL is needed.
+type mastodonConfig struct {
+	Instance string `yaml:"instance,omitempty" doc:"Your Mastodon instance, e.g. mastodon.social"`
+}
+
+// configDir returns the directory that holds the ph configuration file and
+// other user-specific settings, such as stored credentials.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ph"), nil
+}
+
+// defaultStation is the radio.co station ID of JEMP Radio.
+const defaultStation = "sd71de59b3"
+
+// station returns the radio.co station ID to show.
+func (c config) station() string {
+	if c.Station != "" {
+		return c.Station
+	}
+	return defaultStation
+}
+
+// namedStation is a radio.co station with the name ph shows it by.
+type namedStation struct {
+	Name string
+	ID   string
+}
+
+// stations returns the station, named JEMP Radio if it is the default and
+// by its ID otherwise, followed by those in stations, each given as name:
+// station ID.
+func (c config) stations() ([]namedStation, error) {
+	main := namedStation{Name: c.station(), ID: c.station()}
+	if main.ID == defaultStation {
+		main.Name = "JEMP Radio"
+	}
+	var (
+		ss   = []namedStation{main}
+		seen = map[string]bool{main.ID: true, main.Name: true}
+	)
+	for _, entry := range c.Stations {
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 || strings.TrimSpace(entry[:i]) == "" || strings.TrimSpace(entry[i+1:]) == "" {
+			return nil, fmt.Errorf("%q must be name: station ID", entry)
+		}
+		s := namedStation{Name: strings.TrimSpace(entry[:i]), ID: strings.TrimSpace(entry[i+1:])}
+		if seen[s.ID] || seen[s.Name] {
+			return nil, fmt.Errorf("%q names a station that is already given", entry)
+		}
+		seen[s.ID], seen[s.Name] = true, true
+		ss = append(ss, s)
+	}
+	return ss, nil
+}
+
+// defaultDurationPrecision is how many units are shown in how long ago a
+// track started, unless configured otherwise.
+const defaultDurationPrecision = 2
+
+func (c config) durationPrecision() int {
+	if c.DurationPrecision > 0 {
+		return c.DurationPrecision
+	}
+	return defaultDurationPrecision
+}
+
+// elapsedPrecisions maps the names of the units that how long ago a track
+// started can be truncated to, as given to --elapsed-precision, to the
+// units.
+var elapsedPrecisions = map[string]time.Duration{
+	"minute": time.Minute,
+	"second": time.Second,
+}
+
+const defaultElapsedPrecision = "minute"
+
+// parseElapsedPrecision returns the unit named by s, one of the keys of
+// elapsedPrecisions.
+func parseElapsedPrecision(s string) (time.Duration, error) {
+	d, ok := elapsedPrecisions[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid elapsed precision %q: must be minute or second", s)
+	}
+	return d, nil
+}
+
+func (c config) elapsedPrecision() string {
+	if c.ElapsedPrecision != "" {
+		return c.ElapsedPrecision
+	}
+	return defaultElapsedPrecision
+}
+
+// format returns the default output format.
+func (c config) format() string {
+	if c.Format != "" {
+		return c.Format
+	}
+	return "text"
+}
+
+// cacheDir returns the directory where ph caches data fetched from other
+// services.
+func (c config) cacheDir() (string, error) {
+	if c.CacheDir != "" {
+		return c.CacheDir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ph"), nil
+}
+
+// configPath returns the path to the ph configuration file.
+func configPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configFile), nil
+}
+
+// loadConfig reads the ph configuration file, then applies settings from
+// environment variables, which take precedence over the file. If the file
+// does not exist, only the environment is used. Command line flags take
+// precedence over both, which is handled where flags are parsed. Settings
+// with errors are passed to warn.
+func loadConfig() (config, error) {
+	cfg, warnings, err := loadConfigWarnings()
+	for _, w := range warnings {
+		warn(w)
+	}
+	return cfg, err
+}
+
+// loadConfigWarnings loads the configuration as loadConfig does, but
+// returns the warnings about it rather than passing them to warn, for ph
+// serve, which sets warn from the configuration.
+func loadConfigWarnings() (config, []error, error) {
+	cfg, err := readConfig()
+	if err != nil {
+		return cfg, nil, err
+	}
+	var warnings []error
+	if err := setLocale(cfg); err != nil {
+		warnings = append(warnings, err)
+	}
+	if _, err := parseElapsedPrecision(cfg.elapsedPrecision()); err != nil {
+		warnings = append(warnings, err)
+	}
+	if _, err := cfg.Relisten.artistOverrides(); err != nil {
+		warnings = append(warnings, fmt.Errorf("relisten.artists: %w", err))
+	}
+	if dir, err := configDir(); err == nil {
+		if _, err := loadArtistSources(dir, nil); err != nil {
+			warnings = append(warnings, err)
+		}
+	}
+	if p := cfg.Setlists.Default; p != "" {
+		if err := validateSetlistProvider(p); err != nil {
+			warnings = append(warnings, fmt.Errorf("setlists.default: %w", err))
+		}
+	}
+	// A bad network setting is only a warning, so that it can still be
+	// fixed with ph config set.
+	if err := setProxyEnv(cfg); err != nil {
+		warnings = append(warnings, err)
+	}
+	if cfg.Network.InsecureSkipVerify {
+		warnings = append(warnings, errors.New("TLS certificates are not being verified (network.insecure_skip_verify)"))
+	}
+	return cfg, warnings, nil
+}
+
+// readConfig reads the configuration as loadConfig does, without putting
+// it into effect.
+func readConfig() (config, error) {
+	var cfg config
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	if err := applyEnv(&cfg, os.Getenv); err != nil {
+		return cfg, err
+	}
+	// An artist sources file with errors, which loadConfig warns of, gives
+	// way to the defaults.
+	if dir, err := configDir(); err == nil {
+		cfg.sources, _ = loadArtistSources(dir, builtinArtistSources)
+	}
+	return cfg, nil
+}
+
+// configEnvPrefix is prepended to a setting's key to name the environment
+// variable that overrides it, e.g., PH_SPOTIFY_CLIENT_ID for
+// spotify.client_id.
+const configEnvPrefix = "PH_"
+
+// configEnvVar returns the name of the environment variable that overrides
+// the setting with the given key.
+func configEnvVar(key string) string {
+	return configEnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// applyEnv overrides settings in cfg with values from environment
+// variables. List settings are given as comma-separated values. The
+// conventional NO_COLOR variable is honored as well as PH_NO_COLOR.
+func applyEnv(cfg *config, getenv func(string) string) error {
+	for _, s := range configSettings() {
+		name := configEnvVar(s.key)
+		val := getenv(name)
+		if val == "" {
+			continue
+		}
+		v, err := parseConfigValue(s.typ, []string{val})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		reflect.ValueOf(cfg).Elem().FieldByIndex(s.index).Set(v)
+	}
+	if getenv("NO_COLOR") != "" {
+		cfg.NoColor = true
+	}
+	return nil
+}
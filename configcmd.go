@@ -0,0 +1,583 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// configSetting describes a single setting in the configuration file, as
+// derived from the fields and tags of the config struct.
+type configSetting struct {
+	key   string
+	doc   string
+	index []int
+	typ   reflect.Type
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// configSettings returns every setting in the configuration file, in the
+// order the fields are declared.
+func configSettings() []configSetting {
+	var settings []configSetting
+	walkConfig(reflect.TypeOf(config{}), "", nil, func(key, doc string, index []int, typ reflect.Type, section bool) {
+		if !section {
+			settings = append(settings, configSetting{key: key, doc: doc, index: index, typ: typ})
+		}
+	})
+	return settings
+}
+
+// walkConfig calls fn for each section and setting of the configuration
+// struct type t, depth first.
+func walkConfig(t reflect.Type, prefix string, index []int, fn func(key, doc string, index []int, typ reflect.Type, section bool)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		var (
+			key = prefix + name
+			idx = append(append([]int(nil), index...), i)
+		)
+		if f.Type.Kind() == reflect.Struct {
+			fn(key, f.Tag.Get("doc"), idx, f.Type, true)
+			walkConfig(f.Type, key+".", idx, fn)
+			continue
+		}
+		fn(key, f.Tag.Get("doc"), idx, f.Type, false)
+	}
+}
+
+func lookupConfigSetting(key string) (configSetting, error) {
+	settings := configSettings()
+	keys := make([]string, len(settings))
+	for i, s := range settings {
+		if s.key == key {
+			return s, nil
+		}
+		keys[i] = s.key
+	}
+	if match, ok := closestMatch(key, keys, 4); ok {
+		return configSetting{}, fmt.Errorf("unknown setting %q (did you mean %q?)", key, match)
+	}
+	return configSetting{}, fmt.Errorf("unknown setting %q (see ph config list)", key)
+}
+
+// validate checks the values of the settings in the configuration,
+// returning an error describing each problem found.
+func (c config) validate() []error {
+	var errs []error
+	if f := c.Format; f != "" && !containsString(defaultFormats, f) {
+		errs = append(errs, fmt.Errorf("format: must be one of %s (got %q)", strings.Join(defaultFormats, ", "), f))
+	}
+	for i, a := range c.Favorites.Artists {
+		if strings.TrimSpace(a) == "" {
+			errs = append(errs, fmt.Errorf("favorites.artists[%d]: artist must not be empty", i))
+		}
+	}
+	if p := c.ElapsedPrecision; p != "" {
+		if _, err := parseElapsedPrecision(p); err != nil {
+			errs = append(errs, fmt.Errorf("elapsed_precision: %v", err))
+		}
+	}
+	if p := c.DurationPrecision; p < 0 {
+		errs = append(errs, fmt.Errorf("duration_precision: must not be negative (got %d)", p))
+	}
+	if p := c.Spotify.RedirectPort; p < 0 || p > 65535 {
+		errs = append(errs, fmt.Errorf("spotify.redirect_port: must be between 1 and 65535 (got %d)", p))
+	}
+	if inst := c.Mastodon.Instance; inst != "" {
+		if !strings.Contains(inst, "://") {
+			inst = "https://" + inst
+		}
+		if u, err := url.Parse(inst); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("mastodon.instance: %q is not a valid instance name or URL", c.Mastodon.Instance))
+		}
+	}
+	if _, err := c.stations(); err != nil {
+		errs = append(errs, fmt.Errorf("stations: %v", err))
+	}
+	if f := c.Serve.LogFormat; f != "" && f != "text" && f != "json" {
+		errs = append(errs, fmt.Errorf("serve.log_format: must be text or json (got %q)", f))
+	}
+	if n := c.Serve.HistorySize; n < 0 {
+		errs = append(errs, fmt.Errorf("serve.history_size: must not be negative (got %d)", n))
+	}
+	if c.Serve.PollInterval < 0 {
+		errs = append(errs, fmt.Errorf("serve.poll_interval: must not be negative (got %s)", c.Serve.PollInterval))
+	}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"radio_co", c.Timeouts.RadioCo},
+		{"relisten", c.Timeouts.Relisten},
+		{"phish_net", c.Timeouts.PhishNet},
+		{"setlist_fm", c.Timeouts.SetlistFM},
+		{"spotify", c.Timeouts.Spotify},
+		{"lyrics", c.Timeouts.Lyrics},
+	} {
+		if t.d < 0 {
+			errs = append(errs, fmt.Errorf("timeouts.%s: must not be negative (got %s)", t.name, t.d))
+		}
+	}
+	errs = append(errs, c.Endpoints.validate()...)
+	if c.Serve.RateLimit < 0 || c.Serve.RateBurst < 0 {
+		errs = append(errs, errors.New("serve: rate_limit and rate_burst must not be negative"))
+	}
+	if (c.Serve.TLSCert == "") != (c.Serve.TLSKey == "") {
+		errs = append(errs, errors.New("serve: tls_cert and tls_key must be set together"))
+	}
+	if c.Serve.TLSCert != "" && len(c.Serve.ACMEDomains) > 0 {
+		errs = append(errs, errors.New("serve: set tls_cert and tls_key, or acme_domains, but not both"))
+	}
+	if d := c.Serve.ACMEDirectory; d != "" {
+		if u, err := url.Parse(d); err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("serve.acme_directory: %q is not an https URL", d))
+		}
+	}
+	if _, err := c.Serve.profiles(); err != nil {
+		errs = append(errs, fmt.Errorf("serve.profiles: %v", err))
+	} else if _, err := c.Serve.profileTokens(); err != nil {
+		errs = append(errs, fmt.Errorf("serve.profile_tokens: %v", err))
+	}
+	if (c.Serve.Username == "") != (c.Serve.Password == "") {
+		errs = append(errs, errors.New("serve: username and password must be set together"))
+	}
+	for _, origin := range c.Serve.CORSOrigins {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || u.Scheme == "" || u.Host == "" || u.Path != "") {
+			errs = append(errs, fmt.Errorf("serve.cors_origins: %q is not an origin, such as https://example.com, or *", origin))
+		}
+	}
+	if p := c.Network.Proxy; p != "" {
+		if u, err := url.Parse(p); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("network.proxy: %q is not a proxy URL", p))
+		}
+	}
+	if _, err := c.Programs.schedule(); err != nil {
+		errs = append(errs, fmt.Errorf("programs.schedule: %v", err))
+	}
+	if _, err := c.Relisten.artistOverrides(); err != nil {
+		errs = append(errs, fmt.Errorf("relisten.artists: %v", err))
+	}
+	if p := c.Setlists.Default; p != "" {
+		if err := validateSetlistProvider(p); err != nil {
+			errs = append(errs, fmt.Errorf("setlists.default: %v", err))
+		}
+	}
+	if _, err := c.Network.dialer(); err != nil {
+		errs = append(errs, err)
+	}
+	if f := c.Network.CAFile; f != "" {
+		if _, err := os.Stat(f); err != nil {
+			errs = append(errs, fmt.Errorf("network.ca_file: %v", err))
+		}
+	}
+	errs = append(errs, validatePluginNames("plugins.links", c.Plugins.Links)...)
+	errs = append(errs, validatePluginNames("plugins.now_playing", c.Plugins.NowPlaying)...)
+	if wh := c.Notify.Webhook; wh != "" {
+		if u, err := url.Parse(wh); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("notify.webhook: %q is not an http or https URL", wh))
+		}
+	}
+	errs = append(errs, validatePluginNames("notify.plugins", c.Notify.Plugins)...)
+	if _, err := c.Notify.dndWindows(); err != nil {
+		errs = append(errs, err)
+	}
+	if lfm := c.Scrobble.LastFM; lfm.APIKey != "" && lfm.APISecret == "" {
+		errs = append(errs, errors.New("scrobble.lastfm: api_secret must be set with api_key"))
+	}
+	if (c.Scrobble.LastFM.Username == "") != (c.Scrobble.LastFM.Password == "") {
+		errs = append(errs, errors.New("scrobble.lastfm: username and password must be set together"))
+	}
+	if p := c.Scrobble.MinPercent; p < 0 || p > 100 {
+		errs = append(errs, fmt.Errorf("scrobble.min_percent: must be from 0 to 100 (got %d)", p))
+	}
+	if c.Scrobble.MinListen < 0 || c.Scrobble.MinLength < 0 {
+		errs = append(errs, errors.New("scrobble.min_listen and scrobble.min_length must not be negative"))
+	}
+	if lb := c.Scrobble.ListenBrainz.URL; lb != "" {
+		if u, err := url.Parse(lb); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("scrobble.listenbrainz.url: %q is not an http or https URL", lb))
+		}
+	}
+	if email := c.Notify.Email; email.Server != "" {
+		if _, _, err := net.SplitHostPort(email.Server); err != nil {
+			errs = append(errs, fmt.Errorf("notify.email.server: %q must be host:port", email.Server))
+		}
+		if email.From == "" || len(email.To) == 0 {
+			errs = append(errs, errors.New("notify.email: from and to must be set"))
+		}
+		for _, addr := range append([]string{email.From}, email.To...) {
+			if _, err := mail.ParseAddress(addr); addr != "" && err != nil {
+				errs = append(errs, fmt.Errorf("notify.email: %q is not an email address", addr))
+			}
+		}
+		for _, trigger := range email.Alerts {
+			if !containsString(alertTriggers, trigger) {
+				errs = append(errs, fmt.Errorf("notify.email.alerts: unknown trigger %q: must be one of %s", trigger, strings.Join(alertTriggers, ", ")))
+			}
+		}
+		if _, err := time.Parse("15:04", email.digestTime()); err != nil {
+			errs = append(errs, fmt.Errorf("notify.email.digest_time: %q must be HH:MM", email.DigestTime))
+		}
+	}
+	if irc := c.Bot.IRC; irc.Server != "" {
+		if _, _, err := net.SplitHostPort(irc.Server); err != nil {
+			errs = append(errs, fmt.Errorf("bot.irc.server: %q must be host:port", irc.Server))
+		}
+		if !strings.HasPrefix(irc.Channel, "#") && !strings.HasPrefix(irc.Channel, "&") {
+			errs = append(errs, fmt.Errorf("bot.irc.channel: %q is not a channel name, such as #jemp", irc.Channel))
+		}
+	}
+	if room := c.Bot.Matrix.Room; room != "" && !strings.HasPrefix(room, "!") && !strings.HasPrefix(room, "#") {
+		errs = append(errs, fmt.Errorf("bot.matrix.room: %q is not a room ID (!id:server) or alias (#alias:server)", room))
+	}
+	if token := c.Bot.Telegram.Token; token != "" {
+		if i := strings.IndexByte(token, ':'); i <= 0 || strings.Trim(token[:i], "0123456789") != "" {
+			errs = append(errs, errors.New("bot.telegram.token: not a bot token from @BotFather, such as 123456:ABC-DEF"))
+		}
+	}
+	if c.Overlay.Template != "" {
+		if _, err := template.New("overlay").Parse(c.Overlay.Template); err != nil {
+			errs = append(errs, fmt.Errorf("overlay.template: %v", err))
+		}
+	}
+	return errs
+}
+
+func validatePluginNames(key string, names []string) []error {
+	var errs []error
+	for i, name := range names {
+		if name == "" || strings.ContainsAny(name, `/\`) {
+			errs = append(errs, fmt.Errorf("%s[%d]: %q is not a valid plugin name; use the name without the ph- prefix", key, i, name))
+		}
+	}
+	return errs
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// strictFieldError matches the errors yaml.UnmarshalStrict reports for
+// keys that do not correspond to a field.
+var strictFieldError = regexp.MustCompile(`^line (\d+): field (\S+) not found in type (\S+)$`)
+
+// validateConfig parses and validates the contents of a configuration
+// file, returning an error describing each problem found.
+func validateConfig(b []byte) []error {
+	var cfg config
+	err := yaml.UnmarshalStrict(b, &cfg)
+	var typeErr *yaml.TypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		return []error{err}
+	}
+	var errs []error
+	if typeErr != nil {
+		sections := configSectionsByType()
+		for _, msg := range typeErr.Errors {
+			m := strictFieldError.FindStringSubmatch(msg)
+			if m == nil {
+				errs = append(errs, errors.New(msg))
+				continue
+			}
+			key := sections[m[3]] + m[2]
+			if _, err := lookupConfigSetting(key); err != nil {
+				errs = append(errs, fmt.Errorf("line %s: %v", m[1], err))
+			}
+		}
+	}
+	return append(errs, cfg.validate()...)
+}
+
+// configSectionsByType maps the name of each configuration struct type, as
+// reported in YAML errors, to the key prefix of its section.
+func configSectionsByType() map[string]string {
+	sections := map[string]string{reflect.TypeOf(config{}).String(): ""}
+	walkConfig(reflect.TypeOf(config{}), "", nil, func(key, _ string, _ []int, typ reflect.Type, section bool) {
+		if section {
+			sections[typ.String()] = key + "."
+		}
+	})
+	return sections
+}
+
+// formatConfigValue renders a setting's value as it would be given to
+// "ph config set".
+func formatConfigValue(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// parseConfigValue parses the string form of a value for a setting of type
+// t. List settings accept several values, or a single comma-separated
+// value.
+func parseConfigValue(t reflect.Type, values []string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if t.Kind() == reflect.Slice {
+		var items []string
+		for _, s := range values {
+			for _, item := range strings.Split(s, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+		slice := reflect.MakeSlice(t, 0, len(items))
+		for _, item := range items {
+			ev, err := parseConfigValue(t.Elem(), []string{item})
+			if err != nil {
+				return v, err
+			}
+			slice = reflect.Append(slice, ev)
+		}
+		v.Set(slice)
+		return v, nil
+	}
+	if len(values) != 1 {
+		return v, errors.New("expected a single value")
+	}
+	s := values[0]
+	switch {
+	case t == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return v, fmt.Errorf("%q is not a duration, such as 30s or 5m", s)
+		}
+		v.SetInt(int64(d))
+	case t.Kind() == reflect.String:
+		v.SetString(s)
+	case t.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, fmt.Errorf("%q is not true or false", s)
+		}
+		v.SetBool(b)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return v, fmt.Errorf("%q is not a whole number", s)
+		}
+		v.SetInt(n)
+	case t.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return v, fmt.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(f)
+	default:
+		return v, fmt.Errorf("settings of type %s cannot be set from the command line", t)
+	}
+	return v, nil
+}
+
+// configTemplate renders a configuration file documenting every setting,
+// with the settings themselves commented out.
+func configTemplate() string {
+	var b strings.Builder
+	b.WriteString("# ph configuration. Uncomment and edit settings to change them.\n")
+	walkConfig(reflect.TypeOf(config{}), "", nil, func(key, doc string, _ []int, typ reflect.Type, section bool) {
+		var (
+			depth  = strings.Count(key, ".")
+			indent = strings.Repeat("  ", depth)
+			name   = key[strings.LastIndex(key, ".")+1:]
+		)
+		if section {
+			if depth == 0 {
+				b.WriteString("\n")
+			}
+			if doc != "" {
+				fmt.Fprintf(&b, "%s# %s\n", indent, doc)
+			}
+			fmt.Fprintf(&b, "%s%s:\n", indent, name)
+			return
+		}
+		if doc != "" {
+			fmt.Fprintf(&b, "%s# %s\n", indent, doc)
+		}
+		fmt.Fprintf(&b, "%s# %s: %s\n", indent, name, configExample(typ))
+	})
+	return b.String()
+}
+
+func configExample(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "0s"
+	case t.Kind() == reflect.Slice:
+		return "[]"
+	case t.Kind() == reflect.String:
+		return `""`
+	case t.Kind() == reflect.Bool:
+		return "false"
+	default:
+		return "0"
+	}
+}
+
+// writeConfigFile writes the configuration file. The file may hold
+// credentials, so it is only readable by the current user.
+func writeConfigFile(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0700)); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, b); err != nil {
+		return err
+	}
+	return os.Chmod(path, os.FileMode(0600))
+}
+
+func runConfig(args []string) error {
+	const usage = "usage: ph config init|validate|list|get|set|path|translate"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "path":
+		fmt.Println(path)
+		return nil
+	case "init":
+		return runConfigInit(path, args[1:])
+	case "translate":
+		if len(args) != 2 {
+			return errors.New("usage: ph config translate <locale>")
+		}
+		catalogPath, err := writeCatalogTemplate(filepath.Join(filepath.Dir(path), localesDir), args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s; fill in its translations, then set locale to %s\n", catalogPath, args[1])
+		return nil
+	case "validate":
+		b, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no config file at %s (create one with ph config init)", path)
+		}
+		if err != nil {
+			return err
+		}
+		errs := validateConfig(b)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("config file has %d problem(s)", len(errs))
+		}
+		fmt.Printf("%s is valid.\n", path)
+		return nil
+	case "list":
+		for _, s := range configSettings() {
+			fmt.Printf("%-28s %s\n", s.key, s.doc)
+		}
+		return nil
+	case "get":
+		if len(args) != 2 {
+			return errors.New("usage: ph config get <key>")
+		}
+		s, err := lookupConfigSetting(args[1])
+		if err != nil {
+			return err
+		}
+		cfg, err := readConfig()
+		if err != nil {
+			return err
+		}
+		fmt.Println(formatConfigValue(reflect.ValueOf(cfg).FieldByIndex(s.index)))
+		if name, ok := configEnvOverride(s.key, os.Getenv); ok {
+			fmt.Fprintf(os.Stderr, "(from the %s environment variable, which overrides the config file)\n", name)
+		}
+		return nil
+	case "set":
+		if len(args) < 2 {
+			return errors.New("usage: ph config set <key> [value...]")
+		}
+		s, err := lookupConfigSetting(args[1])
+		if err != nil {
+			return err
+		}
+		values := args[2:]
+		if len(values) == 0 && s.typ.Kind() != reflect.Slice {
+			values = []string{""}
+		}
+		v, err := parseConfigValue(s.typ, values)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.key, err)
+		}
+		// Only the file is read, so that settings from the environment,
+		// which may be secrets, are not written into it.
+		b, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		edited, err := setConfigValue(b, s, v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		var cfg config
+		if err := yaml.Unmarshal(edited, &cfg); err != nil {
+			return err
+		}
+		if errs := cfg.validate(); len(errs) > 0 {
+			return errs[0]
+		}
+		if name, ok := configEnvOverride(s.key, os.Getenv); ok {
+			warn(fmt.Errorf("the %s environment variable overrides %s", name, s.key))
+		}
+		return writeConfigFile(path, edited)
+	default:
+		return errors.New(usage)
+	}
+}
+
+func runConfigInit(path string, args []string) error {
+	var (
+		fs    = flag.NewFlagSet("config init", flag.ExitOnError)
+		force = fs.Bool("force", false, "Overwrite an existing config file")
+	)
+	fs.Parse(args)
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("config file %s already exists (use --force to overwrite)", path)
+	}
+	if err := writeConfigFile(path, []byte(configTemplate())); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return nil
+}
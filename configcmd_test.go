@@ -0,0 +1,148 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tt := []struct {
+		desc     string
+		yaml     string
+		wantErrs []string
+	}{
+		{
+			desc: "valid",
+			yaml: "favorites:\n  artists: [Phish, Goose]\nspotify:\n  client_id: abc\n",
+		},
+		{
+			desc:     "misspelled key",
+			yaml:     "spotify:\n  client_di: abc\n",
+			wantErrs: []string{`line 2: unknown setting "spotify.client_di" (did you mean "spotify.client_id"?)`},
+		},
+		{
+			desc:     "invalid port",
+			yaml:     "spotify:\n  redirect_port: 70000\n",
+			wantErrs: []string{"spotify.redirect_port: must be between 1 and 65535 (got 70000)"},
+		},
+		{
+			desc:     "plugin with prefix path",
+			yaml:     "plugins:\n  links: [/usr/bin/ph-foo]\n",
+			wantErrs: []string{`plugins.links[0]: "/usr/bin/ph-foo" is not a valid plugin name`},
+		},
+		{
+			desc:     "negative timeout",
+			yaml:     "timeouts:\n  relisten: -1s\n",
+			wantErrs: []string{"timeouts.relisten: must not be negative (got -1s)"},
+		},
+		{
+			desc:     "endpoint not a URL",
+			yaml:     "endpoints:\n  relisten: api.relisten.net\n",
+			wantErrs: []string{`endpoints.relisten: "api.relisten.net" is not an HTTP or HTTPS URL`},
+		},
+		{
+			desc:     "station without an ID",
+			yaml:     "stations: [\"Phish Radio\"]\n",
+			wantErrs: []string{`stations: "Phish Radio" must be name: station ID`},
+		},
+		{
+			desc: "command-specific format",
+			yaml: "format: widget\n",
+		},
+		{
+			desc:     "unknown format",
+			yaml:     "format: xml\n",
+			wantErrs: []string{`format: must be one of text, json, yaml, plain, alfred, widget, events (got "xml")`},
+		},
+		{
+			desc: "generated template",
+			yaml: configTemplate(),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			errs := validateConfig([]byte(tc.yaml))
+			if len(errs) != len(tc.wantErrs) {
+				t.Fatalf("wanted %d errors, but got %d: %v", len(tc.wantErrs), len(errs), errs)
+			}
+			for i, err := range errs {
+				if !strings.HasPrefix(err.Error(), tc.wantErrs[i]) {
+					t.Errorf("wanted error starting with %q, but got %q", tc.wantErrs[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConfigValue(t *testing.T) {
+	s, err := lookupConfigSetting("favorites.artists")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v, err := parseConfigValue(s.typ, []string{"Phish, Goose", "Grateful Dead"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"Phish", "Goose", "Grateful Dead"}
+	if got := v.Interface(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+
+	s, err = lookupConfigSetting("spotify.redirect_port")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := parseConfigValue(s.typ, []string{"eighty"}); err == nil {
+		t.Errorf("wanted error parsing non-numeric port")
+	}
+	if _, err := lookupConfigSetting("spotify.port"); err == nil {
+		t.Errorf("wanted error looking up unknown setting")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tt := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"tweezer", "tweezer", 0},
+		{"tweezzer", "tweezer", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+	for _, tc := range tt {
+		if got := editDistance(tc.a, tc.b); got != tc.want {
+			t.Errorf("editDistance(%q, %q): wanted %d, but got %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"PH_FORMAT":            "json",
+		"PH_STATION":           "s123",
+		"PH_FAVORITES_ARTISTS": "Phish,Goose",
+		"NO_COLOR":             "1",
+	}
+	cfg := config{Format: "yaml", CacheDir: "/from/file"}
+	if err := applyEnv(&cfg, func(k string) string { return env[k] }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := config{
+		Format:    "json",
+		Station:   "s123",
+		CacheDir:  "/from/file",
+		NoColor:   true,
+		Favorites: favoritesConfig{Artists: []string{"Phish", "Goose"}},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("wanted %+v, but got %+v", want, cfg)
+	}
+
+	env = map[string]string{"PH_SPOTIFY_REDIRECT_PORT": "eighty"}
+	if err := applyEnv(&cfg, func(k string) string { return env[k] }); err == nil {
+		t.Errorf("wanted error for invalid PH_SPOTIFY_REDIRECT_PORT")
+	}
+}
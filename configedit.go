@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// configKeyLine matches a line that sets a key or opens a section, as in
+// "  client_id: abc" or "spotify:", and configCommentedKeyLine one that
+// does so commented out, as config init writes every setting.
+var (
+	configKeyLine          = regexp.MustCompile(`^( *)([A-Za-z0-9_]+):(\s.*)?$`)
+	configCommentedKeyLine = regexp.MustCompile(`^( *)# ?([A-Za-z0-9_]+): `)
+)
+
+// configLine is a line of a configuration file as editConfigFile sees it:
+// the key it sets, if any, and how deeply it is indented.
+type configLine struct {
+	text      string
+	indent    int
+	key       string
+	commented bool
+	section   bool
+}
+
+// parseConfigLines finds the key that each line of a configuration file
+// sets, from its name and the sections it is indented under.
+func parseConfigLines(b []byte) []configLine {
+	var (
+		lines = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+		out   = make([]configLine, len(lines))
+		stack []configLine
+	)
+	// path returns the key prefix of the sections enclosing a line
+	// indented by indent.
+	path := func(indent int) string {
+		var prefix string
+		for _, s := range stack {
+			if s.indent < indent {
+				prefix = s.key + "."
+			}
+		}
+		return prefix
+	}
+	for i, text := range lines {
+		l := configLine{text: text, indent: len(text) - len(strings.TrimLeft(text, " "))}
+		if m := configCommentedKeyLine.FindStringSubmatch(text); m != nil {
+			l.indent = len(m[1])
+			l.key, l.commented = path(l.indent)+m[2], true
+		} else if m := configKeyLine.FindStringSubmatch(text); m != nil {
+			for len(stack) > 0 && stack[len(stack)-1].indent >= l.indent {
+				stack = stack[:len(stack)-1]
+			}
+			l.key = path(l.indent) + m[2]
+			value := strings.TrimSpace(m[3])
+			if value == "" || strings.HasPrefix(value, "#") {
+				l.section = true
+				stack = append(stack, l)
+			}
+		}
+		out[i] = l
+	}
+	return out
+}
+
+// configValueYAML renders a setting's value as YAML on a single line.
+func configValueYAML(v reflect.Value) (string, error) {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String(), nil
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			item, err := configValueYAML(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			if v.Index(i).Kind() == reflect.String {
+				item = strconv.Quote(v.Index(i).String())
+			}
+			items[i] = item
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	}
+	b, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSuffix(string(b), "\n")
+	if strings.Contains(s, "\n") {
+		s = strconv.Quote(v.String())
+	}
+	return s, nil
+}
+
+// editConfigFile sets the setting s to v in the configuration file b,
+// leaving the rest of the file, including its comments, as it is. The
+// line setting s is replaced, or else the line config init wrote for it,
+// commented out; otherwise the setting is added to the end of its section,
+// which is added if need be.
+func editConfigFile(b []byte, s configSetting, v reflect.Value) ([]byte, error) {
+	value, err := configValueYAML(v)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		lines   = parseConfigLines(b)
+		name    = s.key[strings.LastIndex(s.key, ".")+1:]
+		replace = -1
+	)
+	for i, l := range lines {
+		if l.key != s.key {
+			continue
+		}
+		if !l.commented {
+			replace = i
+			break
+		}
+		if replace < 0 {
+			replace = i
+		}
+	}
+
+	var text []string
+	for _, l := range lines {
+		text = append(text, l.text)
+	}
+	if len(b) == 0 {
+		text = nil
+	}
+	if replace >= 0 {
+		l := lines[replace]
+		end := replace + 1
+		// A list written one item to a line continues past the key.
+		for !l.commented && end < len(lines) {
+			next := lines[end]
+			trimmed := strings.TrimSpace(next.text)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				break
+			}
+			if next.indent < l.indent || next.indent == l.indent && !strings.HasPrefix(trimmed, "- ") && trimmed != "-" {
+				break
+			}
+			end++
+		}
+		line := strings.Repeat(" ", l.indent) + name + ": " + value
+		text = append(text[:replace], append([]string{line}, text[end:]...)...)
+		return []byte(strings.Join(text, "\n") + "\n"), nil
+	}
+
+	// Find the deepest of the setting's sections in the file, and the last
+	// line within it.
+	var (
+		sections = strings.Split(s.key, ".")
+		insert   = len(text)
+		indent   = 0
+		found    = 0
+	)
+	sections = sections[:len(sections)-1]
+	for depth := range sections {
+		key := strings.Join(sections[:depth+1], ".")
+		at := -1
+		for i, l := range lines {
+			if !l.commented && l.key == key {
+				if !l.section {
+					return nil, fmt.Errorf("section %s is not written one setting to a line; edit it by hand", key)
+				}
+				at = i
+				break
+			}
+		}
+		if at < 0 {
+			break
+		}
+		found, indent = depth+1, lines[at].indent+2
+		insert = at + 1
+		for i := at + 1; i < len(lines); i++ {
+			trimmed := strings.TrimSpace(lines[i].text)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if lines[i].indent <= lines[at].indent {
+				break
+			}
+			insert = i + 1
+		}
+	}
+	var added []string
+	for _, section := range sections[found:] {
+		added = append(added, strings.Repeat(" ", indent)+section+":")
+		indent += 2
+	}
+	added = append(added, strings.Repeat(" ", indent)+name+": "+value)
+	text = append(text[:insert], append(added, text[insert:]...)...)
+	return []byte(strings.Join(text, "\n") + "\n"), nil
+}
+
+// setConfigValue sets the setting s to v in the configuration file b, as
+// editConfigFile does, then checks that the edited file has s set to v and
+// every other setting as it was, so that a file laid out in a way the edit
+// misreads is left alone.
+func setConfigValue(b []byte, s configSetting, v reflect.Value) ([]byte, error) {
+	var want config
+	if err := yaml.Unmarshal(b, &want); err != nil {
+		return nil, err
+	}
+	reflect.ValueOf(&want).Elem().FieldByIndex(s.index).Set(v)
+	edited, err := editConfigFile(b, s, v)
+	if err != nil {
+		return nil, err
+	}
+	var got config
+	if err := yaml.Unmarshal(edited, &got); err != nil || !reflect.DeepEqual(normalizeConfig(got), normalizeConfig(want)) {
+		return nil, errors.New("unable to update the config file without rewriting it; edit it by hand")
+	}
+	return edited, nil
+}
+
+// normalizeConfig returns cfg with empty lists set to nil, since a list
+// set to nothing reads back from YAML as either.
+func normalizeConfig(cfg config) config {
+	for _, s := range configSettings() {
+		f := reflect.ValueOf(&cfg).Elem().FieldByIndex(s.index)
+		if f.Kind() == reflect.Slice && f.Len() == 0 {
+			f.Set(reflect.Zero(f.Type()))
+		}
+	}
+	return cfg
+}
+
+// configEnvOverride returns the environment variable that overrides the
+// setting with the given key, if one is set.
+func configEnvOverride(key string, getenv func(string) string) (string, bool) {
+	if name := configEnvVar(key); getenv(name) != "" {
+		return name, true
+	}
+	if key == "no_color" && getenv("NO_COLOR") != "" {
+		return "NO_COLOR", true
+	}
+	return "", false
+}
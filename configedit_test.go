@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetConfigValue(t *testing.T) {
+	tt := []struct {
+		desc   string
+		file   string
+		key    string
+		values []string
+		want   string
+	}{
+		{
+			desc:   "empty file",
+			key:    "spotify.client_id",
+			values: []string{"abc"},
+			want:   "spotify:\n  client_id: abc\n",
+		},
+		{
+			desc:   "replaces the setting, keeping comments",
+			file:   "# My settings.\nformat: json # for scripts\nspotify:\n  # Mine.\n  client_id: old\n",
+			key:    "spotify.client_id",
+			values: []string{"new"},
+			want:   "# My settings.\nformat: json # for scripts\nspotify:\n  # Mine.\n  client_id: new\n",
+		},
+		{
+			desc:   "uncomments the template line",
+			file:   "spotify:\n  # Client ID.\n  # client_id: \"\"\n  # redirect_port: 0\nmastodon:\n",
+			key:    "spotify.client_id",
+			values: []string{"abc"},
+			want:   "spotify:\n  # Client ID.\n  client_id: abc\n  # redirect_port: 0\nmastodon:\n",
+		},
+		{
+			desc:   "adds to the end of the section",
+			file:   "spotify:\n  client_id: abc\n\nformat: json\n",
+			key:    "spotify.redirect_port",
+			values: []string{"9999"},
+			want:   "spotify:\n  client_id: abc\n  redirect_port: 9999\n\nformat: json\n",
+		},
+		{
+			desc:   "adds nested sections",
+			file:   "notify:\n  webhook: https://example.com/hook\n",
+			key:    "notify.email.server",
+			values: []string{"smtp.example.com:587"},
+			want:   "notify:\n  webhook: https://example.com/hook\n  email:\n    server: smtp.example.com:587\n",
+		},
+		{
+			desc:   "replaces a list written an item to a line",
+			file:   "favorites:\n  artists:\n  - Phish\n  - Goose\nformat: json\n",
+			key:    "favorites.artists",
+			values: []string{"Phish, Grateful Dead"},
+			want:   "favorites:\n  artists: [\"Phish\", \"Grateful Dead\"]\nformat: json\n",
+		},
+		{
+			desc:   "duration",
+			key:    "timeouts.relisten",
+			values: []string{"30s"},
+			want:   "timeouts:\n  relisten: 30s\n",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			s, err := lookupConfigSetting(tc.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			v, err := parseConfigValue(s.typ, tc.values)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := setConfigValue([]byte(tc.file), s, v)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("wanted:\n%s\nbut got:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSetConfigValue_Template(t *testing.T) {
+	s, err := lookupConfigSetting("serve.log_format")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v, err := parseConfigValue(s.typ, []string{"json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	template := configTemplate()
+	got, err := setConfigValue([]byte(template), s, v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := strings.Count(template, "#"); strings.Count(string(got), "#") != want-1 {
+		t.Errorf("wanted every comment but the setting's kept, but got:\n%s", got)
+	}
+	if !strings.Contains(string(got), "\n  log_format: json\n") {
+		t.Errorf("wanted serve.log_format set, but got:\n%s", got)
+	}
+}
+
+func TestSetConfigValue_Unrecognized(t *testing.T) {
+	s, err := lookupConfigSetting("spotify.client_id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v, err := parseConfigValue(s.typ, []string{"abc"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A section in flow style is not edited line by line, so the edit is
+	// refused rather than risk changing other settings.
+	if _, err := setConfigValue([]byte("spotify: {redirect_port: 9999}\n"), s, v); err == nil {
+		t.Errorf("wanted an error editing a section in flow style")
+	}
+}
+
+func TestConfigEnvOverride(t *testing.T) {
+	env := map[string]string{"PH_SERVE_TOKEN": "secret", "NO_COLOR": "1"}
+	getenv := func(name string) string { return env[name] }
+	for key, want := range map[string]string{"serve.token": "PH_SERVE_TOKEN", "no_color": "NO_COLOR", "format": ""} {
+		if got, _ := configEnvOverride(key, getenv); got != want {
+			t.Errorf("%s: wanted %q, but got %q", key, want, got)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// defaultDemoSpeed is how many times as fast as on the timeline time passes
+// in ph demo, so that tracks change every minute or two.
+const defaultDemoSpeed = 10
+
+// lookupDemoCommand finds the command that ph demo runs. It is set in init,
+// since initializing it with lookupCommand would make the commands, which
+// include ph demo, depend on themselves.
+var lookupDemoCommand func(name string) (command, bool)
+
+func init() {
+	lookupDemoCommand = lookupCommand
+}
+
+// runDemo runs a ph command, by default the TUI, against a FakeStation
+// replaying the bundled demo timeline or one recorded with ph debug
+// record. The command's play history and other data are kept in a
+// temporary directory, so nothing it does is mixed with the user's own.
+func runDemo(args []string) error {
+	var (
+		fs       = flag.NewFlagSet("demo", flag.ExitOnError)
+		timeline = fs.String("timeline", "", "Replay this timeline, recorded with ph debug record, instead of the bundled one")
+		speed    = fs.Float64("speed", defaultDemoSpeed, "Replay the timeline this many times as fast as it happened")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ph demo [flags] [<command> [<args>]]\n\n"+
+			"Runs a ph command, tui unless another is given, against a simulated\n"+
+			"station.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.SetInterspersed(false)
+	fs.Parse(args)
+
+	entries := demoTimeline()
+	if *timeline != "" {
+		var err error
+		if entries, err = readTimeline(*timeline); err != nil {
+			return err
+		}
+	}
+	name, cmdArgs := "tui", fs.Args()
+	if len(cmdArgs) > 0 {
+		name, cmdArgs = cmdArgs[0], cmdArgs[1:]
+	}
+	cmd, ok := lookupDemoCommand(name)
+	if !ok || name == "demo" {
+		return fmt.Errorf(tr("unknown command %q (see ph --help)"), name)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("listen for demo station: %w", err)
+	}
+	defer ln.Close()
+	go http.Serve(ln, NewFakeStation(entries, *speed, time.Now))
+	// The command reads the endpoint from the environment when it loads
+	// the config.
+	if err := os.Setenv(configEnvVar("endpoints.radio_co"), "http://"+ln.Addr().String()); err != nil {
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "ph-demo")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Setenv("XDG_DATA_HOME", dir); err != nil {
+		return err
+	}
+	log.Printf("Playing a simulated station at %s; nothing is saved to your play history.", ln.Addr())
+	return cmd.run(cmdArgs)
+}
+
+// runDebugRecord records the station's statuses to a timeline that ph demo
+// can replay, adding each status whose tracks differ from the last one's,
+// until interrupted.
+func runDebugRecord(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs       = flag.NewFlagSet("debug record", flag.ExitOnError)
+		interval = fs.Duration("interval", 30*time.Second, "How often to check the station")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ph debug record [flags] <timeline>\n\n"+
+			"Adds the station's statuses to the timeline file as they change, for\n"+
+			"ph demo --timeline to replay.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New(tr("a timeline file is required"))
+	}
+	var (
+		path = fs.Arg(0)
+		st   = &store{dir: filepath.Dir(path)}
+		last TrackList
+	)
+	record := func() {
+		b, err := fetchRawStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+		if err != nil {
+			warn(fmt.Errorf("unable to get station status: %w", err))
+			return
+		}
+		var status statusResponseBody
+		if err := json.Unmarshal(b, &status); err != nil {
+			warn(fmt.Errorf("unable to parse station status: %w", err))
+			return
+		}
+		if tracks := status.tracks(); last == nil || !TrackDiff(last, tracks).Empty() {
+			last = tracks
+			if err := st.appendJSONLine(filepath.Base(path), TimelineEntry{Time: time.Now().UTC(), Status: b}); err != nil {
+				warn(fmt.Errorf("unable to record status: %w", err))
+				return
+			}
+			fmt.Println(trackSummary(status.CurrentTrack))
+		}
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	for {
+		record()
+		select {
+		case <-ticker.C:
+		case <-signals:
+			return nil
+		}
+	}
+}
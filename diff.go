@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// debugStatusFile is where ph debug diff keeps the last status it fetched
+// from the station, as radio.co sent it, to compare the next one with.
+const debugStatusFile = "debug-status.json"
+
+// TrackChange is a track that two lists both have, as it is in each, with
+// the fields that differ, named as in JSON.
+type TrackChange struct {
+	Before Track    `json:"before"`
+	After  Track    `json:"after"`
+	Fields []string `json:"fields"`
+}
+
+// TrackListDiff is how one list of tracks differs from another.
+type TrackListDiff struct {
+	Added   TrackList     `json:"added,omitempty" yaml:"added,omitempty"`
+	Removed TrackList     `json:"removed,omitempty" yaml:"removed,omitempty"`
+	Changed []TrackChange `json:"changed,omitempty" yaml:"changed,omitempty"`
+}
+
+// TrackDiff compares two lists of tracks, such as the tracks of two
+// statuses of the station. Tracks are matched by the keys of their songs,
+// in order, so that a song aired twice is matched twice; those in b alone are
+// added, those in a alone are removed, and those in both whose other
+// fields differ are changed. Added and changed tracks are in b's order,
+// and removed ones in a's.
+func TrackDiff(a, b TrackList) TrackListDiff {
+	var (
+		diff    TrackListDiff
+		key     = func(t Track) TrackKey { return t.Key().Song() }
+		unmatch = make(map[TrackKey][]int)
+	)
+	for i, t := range a {
+		unmatch[key(t)] = append(unmatch[key(t)], i)
+	}
+	matched := make([]bool, len(a))
+	for _, t := range b {
+		k := key(t)
+		if len(unmatch[k]) == 0 {
+			diff.Added = append(diff.Added, t)
+			continue
+		}
+		i := unmatch[k][0]
+		unmatch[k] = unmatch[k][1:]
+		matched[i] = true
+		if fields := trackFieldsDiffering(a[i], t); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, TrackChange{Before: a[i], After: t, Fields: fields})
+		}
+	}
+	for i, t := range a {
+		if !matched[i] {
+			diff.Removed = append(diff.Removed, t)
+		}
+	}
+	return diff
+}
+
+// trackFieldsDiffering returns the JSON names of the fields of a and b
+// that differ. Times are equal if they are the same instant.
+func trackFieldsDiffering(a, b Track) []string {
+	var (
+		fields []string
+		va, vb = reflect.ValueOf(a), reflect.ValueOf(b)
+	)
+	for i := 0; i < va.NumField(); i++ {
+		fa, fb := va.Field(i).Interface(), vb.Field(i).Interface()
+		if ta, ok := fa.(time.Time); ok {
+			if ta.Equal(fb.(time.Time)) {
+				continue
+			}
+		} else if reflect.DeepEqual(fa, fb) {
+			continue
+		}
+		name := strings.Split(va.Type().Field(i).Tag.Get("json"), ",")[0]
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// Empty reports whether the lists are the same.
+func (d TrackListDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d TrackListDiff) String() string {
+	if d.Empty() {
+		return tr("No differences.")
+	}
+	var lines []string
+	for _, t := range d.Added {
+		lines = append(lines, "+ "+trackSummary(t))
+	}
+	for _, t := range d.Removed {
+		lines = append(lines, "- "+trackSummary(t))
+	}
+	for _, c := range d.Changed {
+		lines = append(lines, fmt.Sprintf("~ %s: %s", trackSummary(c.After), strings.Join(c.Fields, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func runDebug(args []string) error {
+	const usage = "usage: ph debug diff|record"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "diff":
+		return runDebugDiff(args[1:])
+	case "record":
+		return runDebugRecord(args[1:])
+	default:
+		return errors.New(usage)
+	}
+}
+
+// runDebugDiff compares two statuses of the station, each read from a file
+// of radio.co's status JSON or fetched live. With no files, it compares
+// the status it fetched last time with the live one.
+func runDebugDiff(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("debug diff", flag.ExitOnError)
+		format = fs.StringP("format", "f", cfg.format(), "Output format (text, json, yaml)")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ph debug diff [flags] [<before> [<after>]]\n\n"+
+			"Each status is a file of the station's status as radio.co sends it, or\n"+
+			"\"live\" to fetch it. The after status defaults to live, and the before\n"+
+			"status to the one fetched the last time ph debug diff ran.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 2 {
+		fs.Usage()
+		return errors.New(tr("too many statuses given"))
+	}
+	writeOutput, err := getRenderer(*format, useColor(cfg, os.Stdout), cfg)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	// load reads a status from a file, or fetches it from the station and
+	// keeps it to compare with next time.
+	load := func(source string) (statusResponseBody, error) {
+		var (
+			b   []byte
+			err error
+		)
+		if source == "live" {
+			if b, err = fetchRawStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station()); err != nil {
+				return statusResponseBody{}, err
+			}
+			if err := st.writeJSON(debugStatusFile, json.RawMessage(b)); err != nil {
+				return statusResponseBody{}, fmt.Errorf("save status: %w", err)
+			}
+		} else if b, err = ioutil.ReadFile(source); err != nil {
+			return statusResponseBody{}, err
+		}
+		var status statusResponseBody
+		if err := json.Unmarshal(b, &status); err != nil {
+			return statusResponseBody{}, fmt.Errorf("parse status %s: %w", source, err)
+		}
+		return status, nil
+	}
+
+	sources := append(fs.Args(), "live", "live")[:2]
+	var (
+		before statusResponseBody
+		saved  json.RawMessage
+	)
+	if fs.NArg() == 0 {
+		// The status fetched last time is read before the live one takes
+		// its place.
+		if err := st.readJSON(debugStatusFile, &saved); err != nil {
+			return fmt.Errorf("read saved status: %w", err)
+		}
+		if saved != nil {
+			if err := json.Unmarshal(saved, &before); err != nil {
+				return fmt.Errorf("parse saved status: %w", err)
+			}
+		}
+	} else if before, err = load(sources[0]); err != nil {
+		return err
+	}
+	after, err := load(sources[1])
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 && saved == nil {
+		fmt.Println(tr("Saved the live status to compare the next one with."))
+		return nil
+	}
+	return writeOutput(TrackDiff(before.tracks(), after.tracks()))
+}
+
+// fetchRawStatus fetches the status of station from radio.co, as sent,
+// giving up after the radio.co timeout.
+func fetchRawStatus(ctx context.Context, radio radioCoClient, station string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutOrDefault(radio.timeout, defaultRadioCoTimeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, radio.statusURL(station), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := radio.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get station status: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get station status: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrackDiff(t *testing.T) {
+	var (
+		reba     = Track{Artist: "Phish", Title: "Reba"}
+		rebaDate = Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22"), Location: "Hampton, VA"}
+		tweezer  = Track{Artist: "Phish", Title: "Tweezer"}
+		arcadia  = Track{Artist: "Goose", Title: "Arcadia"}
+	)
+	tt := []struct {
+		desc string
+		a, b TrackList
+		want TrackListDiff
+	}{
+		{desc: "same", a: TrackList{reba, tweezer}, b: TrackList{reba, tweezer}},
+		{
+			desc: "added and removed",
+			a:    TrackList{reba, tweezer},
+			b:    TrackList{arcadia, reba},
+			want: TrackListDiff{Added: TrackList{arcadia}, Removed: TrackList{tweezer}},
+		},
+		{
+			desc: "changed",
+			a:    TrackList{reba, tweezer},
+			b:    TrackList{rebaDate, tweezer},
+			want: TrackListDiff{Changed: []TrackChange{{Before: reba, After: rebaDate, Fields: []string{"performance_time", "location"}}}},
+		},
+		{
+			desc: "aired twice",
+			a:    TrackList{tweezer, reba},
+			b:    TrackList{tweezer, reba, tweezer},
+			want: TrackListDiff{Added: TrackList{tweezer}},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := TrackDiff(tc.a, tc.b); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %+v, but got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackListDiff_String(t *testing.T) {
+	d := TrackListDiff{
+		Added:   TrackList{{Artist: "Goose", Title: "Arcadia"}},
+		Removed: TrackList{{Artist: "Phish", Title: "Tweezer"}},
+		Changed: []TrackChange{{After: Track{Artist: "Phish", Title: "Reba"}, Fields: []string{"location"}}},
+	}
+	want := "+ Goose - Arcadia\n- Phish - Tweezer\n~ Phish - Reba: location"
+	if got := d.String(); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+	if got := (TrackListDiff{}).String(); got != "No differences." {
+		t.Errorf("wanted %q, but got %q", "No differences.", got)
+	}
+}
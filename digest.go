@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// defaultDigestTop is how many top artists and songs a digest includes.
+const defaultDigestTop = 5
+
+// digest summarizes the plays recorded over a period.
+type digest struct {
+	From       time.Time
+	To         time.Time
+	Plays      int
+	Listening  time.Duration
+	TopArtists []tally
+	TopSongs   []tally
+
+	// Shows are the full shows broadcast, each once, with the sets it
+	// broadcast, however many station breaks there were between them.
+	Shows []airingShow
+}
+
+// tally is a count of plays of something, such as an artist or a song.
+type tally struct {
+	Name  string
+	Count int
+}
+
+// buildDigest summarizes the plays that started in [from, to), keeping the
+// top n artists and songs. Station breaks are not counted.
+func buildDigest(plays TrackList, from, to time.Time, n int) digest {
+	var (
+		d       = digest{From: from, To: to}
+		artists = make(map[string]int)
+		songs   = make(map[string]int)
+	)
+	for i, t := range plays {
+		if t.StartTime.Before(from) || !t.StartTime.Before(to) || t.IsStationBreak() {
+			continue
+		}
+		d.Plays++
+		d.Listening += playLength(plays, i)
+		if t.Artist != "" {
+			artists[t.Artist]++
+		}
+		if t.IsFullShow() {
+			continue
+		}
+		songs[trackSummary(Track{Artist: t.Artist, Title: t.Title})]++
+	}
+	for _, show := range showAirings(plays) {
+		if start := show.Tracks[0].StartTime; show.fullShow() && !start.Before(from) && start.Before(to) {
+			d.Shows = append(d.Shows, show)
+		}
+	}
+	d.TopArtists = topTallies(artists, n)
+	d.TopSongs = topTallies(songs, n)
+	return d
+}
+
+// digestShow names a show in a digest, with the sets broadcast, as in
+// "Phish - Sat 22-Nov-1997 Hampton, VA (Set 1, Set 2 + Encore)".
+func digestShow(s airingShow) string {
+	return fmt.Sprintf("%s (%s)", s.title(), strings.Join(s.sets(), ", "))
+}
+
+// topTallies returns the n highest counts, highest first, breaking ties by
+// name.
+func topTallies(counts map[string]int, n int) []tally {
+	tallies := make([]tally, 0, len(counts))
+	for name, count := range counts {
+		tallies = append(tallies, tally{Name: name, Count: count})
+	}
+	sort.Slice(tallies, func(i, j int) bool {
+		if tallies[i].Count != tallies[j].Count {
+			return tallies[i].Count > tallies[j].Count
+		}
+		return tallies[i].Name < tallies[j].Name
+	})
+	if len(tallies) > n {
+		tallies = tallies[:n]
+	}
+	return tallies
+}
+
+func (d digest) subject() string {
+	const dateFormat = "Mon 2-Jan-2006"
+	return fmt.Sprintf("JEMP Radio digest: %s to %s",
+		d.From.Local().Format(dateFormat), d.To.Add(-time.Second).Local().Format(dateFormat))
+}
+
+func (d digest) summary() string {
+	return fmt.Sprintf("%d plays, %.1f hours of music", d.Plays, d.Listening.Hours())
+}
+
+// text renders the digest as plain text.
+func (d digest) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n", d.subject(), d.summary())
+	section := func(heading string, tallies []tally) {
+		if len(tallies) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s\n", heading)
+		for _, t := range tallies {
+			fmt.Fprintf(&b, "%5d  %s\n", t.Count, t.Name)
+		}
+	}
+	section("Top artists", d.TopArtists)
+	section("Top songs", d.TopSongs)
+	if len(d.Shows) > 0 {
+		b.WriteString("\nShows\n")
+		for _, s := range d.Shows {
+			fmt.Fprintf(&b, "  %s  %s\n", s.Tracks[0].StartTime.Local().Format("Mon 2-Jan 15:04"), digestShow(s))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// markdown renders the digest as Markdown, for posting.
+func (d digest) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n%s\n", d.subject(), d.summary())
+	section := func(heading string, tallies []tally) {
+		if len(tallies) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", heading)
+		for i, t := range tallies {
+			fmt.Fprintf(&b, "%d. %s (%d)\n", i+1, t.Name, t.Count)
+		}
+	}
+	section("Top artists", d.TopArtists)
+	section("Top songs", d.TopSongs)
+	if len(d.Shows) > 0 {
+		b.WriteString("\n### Shows\n\n")
+		for _, s := range d.Shows {
+			line := digestShow(s)
+			show := Track{Artist: s.Artist, PerformanceTime: s.PerformanceTime}
+			if url := show.streamingURL(relistenArtists); url != "" {
+				line = fmt.Sprintf("[%s](%s)", line, url)
+			}
+			fmt.Fprintf(&b, "- %s, aired %s\n", line, s.Tracks[0].StartTime.Local().Format("Mon 2-Jan 15:04"))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// digestPeriod returns the period covered by a daily, weekly, or monthly
+// digest ending at now.
+func digestPeriod(now time.Time, period string) (time.Time, time.Time) {
+	switch period {
+	case "daily":
+		return now.AddDate(0, 0, -1), now
+	case "monthly":
+		return now.AddDate(0, -1, 0), now
+	}
+	return now.AddDate(0, 0, -7), now
+}
+
+// runDigest summarizes the listening over the past week or month.
+func runDigest(args []string) error {
+	var (
+		fs      = flag.NewFlagSet("digest", flag.ExitOnError)
+		daily   = fs.Bool("daily", false, "Summarize the past day")
+		weekly  = fs.Bool("weekly", false, "Summarize the past week (the default)")
+		monthly = fs.Bool("monthly", false, "Summarize the past month")
+		format  = fs.StringP("format", "f", "text", "Output format: text or markdown")
+		top     = fs.Int("top", defaultDigestTop, "Number of top artists and songs to include")
+		send    = fs.Bool("send", false, "Send the digest with the configured notifiers instead of printing it")
+	)
+	fs.Parse(args)
+	period := "weekly"
+	switch {
+	case *daily && (*weekly || *monthly), *weekly && *monthly:
+		return errors.New("only one of --daily, --weekly, and --monthly may be given")
+	case *daily:
+		period = "daily"
+	case *monthly:
+		period = "monthly"
+	}
+	if *format != "text" && *format != "markdown" {
+		return fmt.Errorf("invalid format %q: must be text or markdown", *format)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	plays, err := st.plays()
+	if err != nil {
+		return fmt.Errorf("read play history: %w", err)
+	}
+	if cfg.Catalog.Autocorrect {
+		plays = loadSongCatalog(context.Background(), apiClient(cfg), cfg).correctAll(plays)
+	}
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	from, to := digestPeriod(time.Now(), period)
+	d := buildDigest(plays, from, to, *top)
+	text := d.text()
+	if *format == "markdown" {
+		text = d.markdown()
+	}
+
+	if !*send {
+		fmt.Println(text)
+		return nil
+	}
+	notifiers := cfg.Notify.notifiers(httpClient(cfg))
+	if len(notifiers) == 0 {
+		return errors.New("no notifiers configured; set notify.webhook, notify.email, or notify.plugins in the config file")
+	}
+	return sendNotification(notifiers, notification{Subject: d.subject(), Text: text})
+}
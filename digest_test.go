@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildDigest(t *testing.T) {
+	var (
+		from  = mustParseDate("2020-06-01T00:00:00")
+		to    = from.AddDate(0, 0, 7)
+		plays = TrackList{
+			{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-05-31T23:00:00")},
+			{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-01T20:00:00")},
+			{Artist: "Goose", Title: "Arcadia", StartTime: mustParseDate("2020-06-01T20:15:00")},
+			{Artist: "jempradio.com", Title: "Station ID", StartTime: mustParseDate("2020-06-01T20:25:00")},
+			{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-02T20:00:00")},
+			{Artist: "Phish", Title: "Tweezer", StartTime: mustParseDate("2020-06-02T20:20:00")},
+			{
+				Artist:          "Phish",
+				Title:           "22-Nov-1997 Hampton, VA Set 1",
+				Set:             &ShowSet{Number: 1},
+				PerformanceTime: mustParseDate("1997-11-22T00:00:00"),
+				StartTime:       mustParseDate("2020-06-05T19:00:00"),
+			},
+			{Artist: "jempradio.com", Title: "Station ID", StartTime: mustParseDate("2020-06-05T19:55:00")},
+			{
+				Artist:          "Phish",
+				Title:           "22-Nov-1997 Hampton, VA Set 2",
+				Set:             &ShowSet{Number: 2},
+				PerformanceTime: mustParseDate("1997-11-22T00:00:00"),
+				StartTime:       mustParseDate("2020-06-05T20:00:00"),
+			},
+			{Artist: "Goose", Title: "Arcadia", StartTime: mustParseDate("2020-06-08T01:00:00")},
+		}
+	)
+	d := buildDigest(plays, from, to, 2)
+	if d.Plays != 6 {
+		t.Errorf("wanted 6 plays, but got %d", d.Plays)
+	}
+	// Reba runs until Arcadia (15m), Arcadia until the station break (10m),
+	// Reba until Tweezer (20m), and the first set until the station break
+	// (55m). Tweezer and the second set are followed by long gaps, so they
+	// are assumed to last 10m and 1h.
+	if want := 170 * time.Minute; d.Listening != want {
+		t.Errorf("wanted %v of listening, but got %v", want, d.Listening)
+	}
+	wantArtists := []tally{{"Phish", 5}, {"Goose", 1}}
+	if len(d.TopArtists) != len(wantArtists) {
+		t.Fatalf("wanted top artists %v, but got %v", wantArtists, d.TopArtists)
+	}
+	for i := range wantArtists {
+		if d.TopArtists[i] != wantArtists[i] {
+			t.Errorf("top artist %d: wanted %v, but got %v", i, wantArtists[i], d.TopArtists[i])
+		}
+	}
+	if len(d.TopSongs) != 2 || d.TopSongs[0] != (tally{"Phish - Reba", 2}) {
+		t.Errorf("wanted Reba to be the top song, but got %v", d.TopSongs)
+	}
+	// The sets aired either side of a station break, as one show.
+	if len(d.Shows) != 1 || digestShow(d.Shows[0]) != "Phish - Sat 22-Nov-1997 (Set 1, Set 2)" {
+		t.Errorf("wanted the Hampton sets as the only show, but got %v", d.Shows)
+	}
+}
+
+func TestDigest_Markdown(t *testing.T) {
+	d := digest{
+		From:       mustParseDate("2020-06-01T00:00:00"),
+		To:         mustParseDate("2020-06-08T00:00:00"),
+		Plays:      3,
+		Listening:  90 * time.Minute,
+		TopArtists: []tally{{"Phish", 3}},
+	}
+	md := d.markdown()
+	for _, want := range []string{"## JEMP Radio digest", "3 plays, 1.5 hours", "### Top artists", "1. Phish (3)"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("wanted markdown to contain %q, but got:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "### Top songs") {
+		t.Errorf("wanted empty sections to be omitted, but got:\n%s", md)
+	}
+}
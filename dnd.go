@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const dndFile = "dnd.json"
+
+// dndWindows parses the times to hold notifications.
+func (c notifyConfig) dndWindows() ([]timeWindow, error) {
+	windows := make([]timeWindow, 0, len(c.DND))
+	for _, s := range c.DND {
+		w, err := parseTimeWindow(s)
+		if err != nil {
+			return nil, fmt.Errorf("notify.dnd: %q: %w", s, err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// quiet wraps n so that it respects do not disturb: the configured
+// windows, and any snooze set with ph dnd.
+func (c notifyConfig) quiet(n notifier) notifier {
+	windows, err := c.dndWindows()
+	if err != nil {
+		warn(err)
+	}
+	q := quietNotifier{notifier: n, windows: windows, now: time.Now}
+	if st, err := openStore(); err == nil {
+		q.store = st
+	}
+	return q
+}
+
+// quietNotifier drops the notifications sent while do not disturb is on,
+// rather than delivering them late, since most say what is airing now.
+type quietNotifier struct {
+	notifier
+	windows []timeWindow
+	store   *store // for the snooze; nil if there is no store
+	now     func() time.Time
+}
+
+func (q quietNotifier) notify(n notification) error {
+	if q.quiet(q.now()) {
+		return nil
+	}
+	return q.notifier.notify(n)
+}
+
+// quiet reports whether do not disturb is on at t.
+func (q quietNotifier) quiet(t time.Time) bool {
+	for _, w := range q.windows {
+		if w.contains(t.Local()) {
+			return true
+		}
+	}
+	if q.store == nil {
+		return false
+	}
+	until, err := q.store.snoozedUntil()
+	if err != nil {
+		warn(fmt.Errorf("unable to read snooze: %w", err))
+	}
+	return t.Before(until)
+}
+
+// snoozedUntil returns when the snooze set with ph dnd ends, or the zero
+// time if there is none.
+func (s *store) snoozedUntil() (time.Time, error) {
+	var dnd struct {
+		Until time.Time `json:"until"`
+	}
+	err := s.readJSON(dndFile, &dnd)
+	return dnd.Until, err
+}
+
+// snooze holds notifications until the given time. The zero time ends the
+// snooze.
+func (s *store) snooze(until time.Time) error {
+	return s.writeJSON(dndFile, struct {
+		Until time.Time `json:"until"`
+	}{until})
+}
+
+func runDND(args []string) error {
+	const usage = "usage: ph dnd [<duration>|off]"
+	if len(args) > 1 {
+		return errors.New(usage)
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		until, err := st.snoozedUntil()
+		if err != nil {
+			return err
+		}
+		if time.Now().Before(until) {
+			fmt.Printf(tr("Notifications are snoozed until %s.")+"\n", until.Local().Format("Mon 2-Jan 15:04"))
+		} else {
+			fmt.Println(tr("Notifications are not snoozed."))
+		}
+		for _, w := range cfg.Notify.DND {
+			fmt.Printf(tr("Do not disturb: %s")+"\n", w)
+		}
+		return nil
+	}
+	if args[0] == "off" {
+		if err := st.snooze(time.Time{}); err != nil {
+			return err
+		}
+		fmt.Println(tr("Notifications are no longer snoozed."))
+		return nil
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid duration %q: must be like 30m or 2h", args[0])
+	}
+	until := time.Now().Add(d)
+	if err := st.snooze(until); err != nil {
+		return err
+	}
+	fmt.Printf(tr("Notifications are snoozed until %s.")+"\n", until.Format("Mon 2-Jan 15:04"))
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietNotifier(t *testing.T) {
+	overnight, err := parseTimeWindow("daily 23:00-07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		st = newTestStore(t)
+		at = func(s string) time.Time {
+			return time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local).Add(mustParseDuration(t, s))
+		}
+		now time.Time
+	)
+	if err := st.snooze(at("12h")); err != nil {
+		t.Fatalf("unexpected error snoozing: %v", err)
+	}
+	tt := []struct {
+		desc string
+		now  time.Time
+		want bool
+	}{
+		{desc: "overnight", now: at("2h"), want: false},
+		{desc: "snoozed", now: at("11h59m"), want: false},
+		{desc: "after snooze", now: at("12h"), want: true},
+		{desc: "before window", now: at("22h59m"), want: true},
+		{desc: "in window", now: at("23h"), want: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			var (
+				sent recordingNotifier
+				q    = quietNotifier{notifier: &sent, windows: []timeWindow{overnight}, store: st, now: func() time.Time { return now }}
+			)
+			now = tc.now
+			if err := q.notify(notification{Subject: "JEMP Radio: Phish - Reba"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := len(sent) == 1; got != tc.want {
+				t.Errorf("wanted sent %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func mustParseDuration(t *testing.T, s string) time.Duration {
+	t.Helper()
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
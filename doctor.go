@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// Statuses of the checks ph doctor runs.
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+	doctorSkip = "skip"
+)
+
+// doctorResult is the outcome of one of ph doctor's checks, with what to
+// do about it if it did not pass.
+type doctorResult struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// doctor checks that ph is set up to work: that its configuration is
+// valid, that it can reach and is let in by each service it uses, and that
+// its cache and local data are usable.
+type doctor struct {
+	cfg        config
+	configPath string
+	configDir  string
+	cacheDir   string
+	store      *store
+
+	// client makes requests to the services, bypassing the cache and the
+	// circuit breakers, so that each check asks the service itself.
+	client *http.Client
+}
+
+// run runs each check in turn.
+func (d doctor) run(ctx context.Context) []doctorResult {
+	return []doctorResult{
+		d.checkConfig(),
+		d.checkArtistSources(),
+		d.checkRadioCo(ctx),
+		d.checkRelisten(ctx),
+		d.checkPhishNet(ctx),
+		d.checkSetlistFM(ctx),
+		d.checkTokens(),
+		d.checkCache(),
+		d.checkData(),
+	}
+}
+
+func (d doctor) checkConfig() doctorResult {
+	r := doctorResult{Check: "config"}
+	b, err := ioutil.ReadFile(d.configPath)
+	switch {
+	case os.IsNotExist(err):
+		r.Status, r.Detail = doctorOK, fmt.Sprintf("no config file at %s; using the defaults", d.configPath)
+		return r
+	case err != nil:
+		r.Status, r.Detail = doctorFail, err.Error()
+		return r
+	}
+	errs := validateConfig(b)
+	if len(errs) == 0 {
+		r.Status, r.Detail = doctorOK, d.configPath+" is valid"
+		return r
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	r.Status, r.Detail = doctorFail, strings.Join(msgs, "; ")
+	r.Fix = "fix each setting with ph config set, or edit " + d.configPath
+	return r
+}
+
+func (d doctor) checkArtistSources() doctorResult {
+	r := doctorResult{Check: "artists"}
+	path := filepath.Join(d.configDir, artistSourcesFile)
+	if _, err := loadArtistSources(d.configDir, nil); err != nil {
+		r.Status, r.Detail, r.Fix = doctorFail, err.Error(), "fix or remove "+path
+		return r
+	}
+	r.Status, r.Detail = doctorOK, "artist sources are valid"
+	return r
+}
+
+func (d doctor) checkRadioCo(ctx context.Context) doctorResult {
+	r := doctorResult{Check: "radio.co"}
+	u := d.cfg.Endpoints.radioCo() + fmt.Sprintf("/stations/%s/status", d.cfg.station())
+	code, _, err := d.get(ctx, u, nil, d.cfg.Timeouts.radioCo())
+	switch {
+	case err != nil:
+		r.Status, r.Detail, r.Fix = doctorFail, err.Error(), "check the network settings, or endpoints.radio_co"
+	case code == http.StatusNotFound:
+		r.Status, r.Detail, r.Fix = doctorFail, fmt.Sprintf("no station %q", d.cfg.station()), "check the station setting"
+	case code != http.StatusOK:
+		r.Status, r.Detail = doctorFail, http.StatusText(code)
+	default:
+		r.Status, r.Detail = doctorOK, fmt.Sprintf("station %s is reachable", d.cfg.station())
+	}
+	return r
+}
+
+func (d doctor) checkRelisten(ctx context.Context) doctorResult {
+	r := doctorResult{Check: "Relisten"}
+	code, _, err := d.get(ctx, d.cfg.Endpoints.relisten()+"/artists", nil, d.cfg.Timeouts.relisten())
+	switch {
+	case err != nil:
+		r.Status, r.Detail, r.Fix = doctorFail, err.Error(), "check the network settings, or endpoints.relisten"
+	case code != http.StatusOK:
+		r.Status, r.Detail = doctorFail, http.StatusText(code)
+	default:
+		r.Status, r.Detail = doctorOK, "reachable"
+	}
+	return r
+}
+
+func (d doctor) checkPhishNet(ctx context.Context) doctorResult {
+	r := doctorResult{Check: "phish.net"}
+	key := d.cfg.Catalog.PhishNetAPIKey
+	if key == "" {
+		r.Status, r.Detail = doctorSkip, "no API key; the Phish song catalog and setlists are not fetched"
+		return r
+	}
+	u := d.cfg.Endpoints.phishNet() + fmt.Sprintf(pathPhishNetSetlist, "1997-11-22", url.QueryEscape(key))
+	code, b, err := d.get(ctx, u, nil, d.cfg.Timeouts.phishNet())
+	if err != nil {
+		r.Status, r.Detail, r.Fix = doctorFail, err.Error(), "check the network settings, or endpoints.phish_net"
+		return r
+	}
+	var body struct {
+		Error        bool   `json:"error"`
+		ErrorMessage string `json:"error_message"`
+	}
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		r.Status, r.Detail = doctorFail, "the API key was rejected"
+	case code != http.StatusOK:
+		r.Status, r.Detail = doctorFail, http.StatusText(code)
+	case json.Unmarshal(b, &body) != nil:
+		r.Status, r.Detail = doctorFail, "the response is not from the phish.net API"
+	case body.Error:
+		r.Status, r.Detail = doctorFail, "the API key was rejected: "+body.ErrorMessage
+	default:
+		r.Status, r.Detail = doctorOK, "reachable, and the API key works"
+	}
+	if r.Status == doctorFail && strings.Contains(r.Detail, "API key") {
+		r.Fix = "check catalog.phishnet_api_key; keys are at https://phish.net/api"
+	}
+	return r
+}
+
+func (d doctor) checkSetlistFM(ctx context.Context) doctorResult {
+	r := doctorResult{Check: "setlist.fm"}
+	key := d.cfg.Setlists.SetlistFMAPIKey
+	if key == "" {
+		r.Status, r.Detail = doctorSkip, "no API key; setlists are only linked to"
+		return r
+	}
+	u := d.cfg.Endpoints.setlistFM() + fmt.Sprintf(pathSetlistFMSearch, "Phish", "22-11-1997")
+	header := http.Header{"X-Api-Key": {key}, "Accept": {"application/json"}}
+	code, _, err := d.get(ctx, u, header, d.cfg.Timeouts.setlistFM())
+	switch {
+	case err != nil:
+		r.Status, r.Detail, r.Fix = doctorFail, err.Error(), "check the network settings, or endpoints.setlist_fm"
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		r.Status, r.Detail = doctorFail, "the API key was rejected"
+		r.Fix = "check setlists.setlist_fm_api_key; keys are at https://www.setlist.fm/settings/api"
+	case code != http.StatusOK && code != http.StatusNotFound:
+		r.Status, r.Detail = doctorFail, http.StatusText(code)
+	default:
+		r.Status, r.Detail = doctorOK, "reachable, and the API key works"
+	}
+	return r
+}
+
+// checkTokens checks the logins to services made with ph auth, without
+// using them, since using them can refresh and so change them.
+func (d doctor) checkTokens() doctorResult {
+	r := doctorResult{Check: "logins"}
+	b, err := ioutil.ReadFile(filepath.Join(d.configDir, tokensFile))
+	if os.IsNotExist(err) {
+		b, err = []byte("{}"), nil
+	}
+	var tokens map[string]oauthToken
+	if err == nil {
+		err = json.Unmarshal(b, &tokens)
+	}
+	if err != nil {
+		r.Status, r.Detail = doctorFail, fmt.Sprintf("unable to read %s: %v", tokensFile, err)
+		r.Fix = "log in again with ph auth login"
+		return r
+	}
+	var expired, missing []string
+	for name, t := range tokens {
+		if !t.Expiry.IsZero() && t.Expiry.Before(appClock.Now()) && t.RefreshToken == "" {
+			expired = append(expired, name)
+		}
+	}
+	sort.Strings(expired)
+	if _, ok := tokens["spotify"]; !ok && d.cfg.Spotify.ClientID != "" {
+		missing = append(missing, "spotify")
+	}
+	switch {
+	case len(expired) > 0:
+		r.Status, r.Detail = doctorWarn, "expired: "+strings.Join(expired, ", ")
+		r.Fix = "log in again with ph auth login " + expired[0]
+	case len(missing) > 0:
+		r.Status, r.Detail = doctorWarn, "configured but not logged in: "+strings.Join(missing, ", ")
+		r.Fix = "log in with ph auth login " + missing[0]
+	default:
+		r.Status, r.Detail = doctorOK, fmt.Sprintf("%d logged in", len(tokens))
+	}
+	return r
+}
+
+func (d doctor) checkCache() doctorResult {
+	r := doctorResult{Check: "cache"}
+	if err := checkWritable(d.cacheDir); err != nil {
+		r.Status, r.Detail = doctorFail, err.Error()
+		r.Fix = "make " + d.cacheDir + " writable, or set cache_dir"
+		return r
+	}
+	r.Status, r.Detail = doctorOK, d.cacheDir+" is writable"
+	return r
+}
+
+func (d doctor) checkData() doctorResult {
+	r := doctorResult{Check: "data"}
+	if err := checkWritable(d.store.dir); err != nil {
+		r.Status, r.Detail, r.Fix = doctorFail, err.Error(), "make "+d.store.dir+" writable"
+		return r
+	}
+	pending, err := d.store.pendingMigrations()
+	if err != nil {
+		r.Status, r.Detail = doctorFail, err.Error()
+		return r
+	}
+	var plays, bad int
+	err = d.store.readJSONLines(playsFile, func(line []byte) error {
+		plays++
+		var v map[string]interface{}
+		if json.Unmarshal(line, &v) != nil {
+			bad++
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		r.Status, r.Detail = doctorFail, fmt.Sprintf("unable to read the play history: %v", err)
+	case len(pending) > 0:
+		r.Status, r.Detail = doctorWarn, fmt.Sprintf("%d migrations of the local data are pending", len(pending))
+		r.Fix = "run ph db migrate, with ph serve stopped"
+	case bad > 0:
+		r.Status, r.Detail = doctorWarn, fmt.Sprintf("%d of %d plays in the play history are unreadable, and are skipped", bad, plays)
+		r.Fix = "remove the unreadable lines from " + d.store.path(playsFile)
+	default:
+		r.Status, r.Detail = doctorOK, fmt.Sprintf("%s is up to date, with %d plays", d.store.dir, plays)
+	}
+	return r
+}
+
+// checkWritable checks that files can be created in dir, if it exists. A
+// directory that does not exist yet is created when it is first needed.
+func checkWritable(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	f, err := ioutil.TempFile(dir, ".ph-doctor-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// get requests u with header, giving up after timeout, and returns the
+// response's status code and the start of its body.
+func (d doctor) get(ctx context.Context, u string, header http.Header, timeout time.Duration) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: 64 * 1024})
+	return resp.StatusCode, b, err
+}
+
+// runDoctor checks that ph is set up to work, and says how to fix what is
+// not. It fails if any check fails, but not for warnings.
+func runDoctor(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("doctor", flag.ExitOnError)
+		format = fs.StringP("format", "f", cfg.format(), "Output format (text, json, yaml)")
+	)
+	fs.Parse(args)
+	writeOutput, err := getRenderer(*format, false, cfg)
+	if err != nil {
+		return err
+	}
+
+	d := doctor{cfg: cfg, client: httpClient(cfg)}
+	if d.configDir, err = configDir(); err != nil {
+		return err
+	}
+	if d.configPath, err = configPath(); err != nil {
+		return err
+	}
+	if d.cacheDir, err = cfg.cacheDir(); err != nil {
+		return err
+	}
+	if d.store, err = localStore(); err != nil {
+		return err
+	}
+	results := d.run(context.Background())
+	failed := 0
+	for _, r := range results {
+		if r.Status == doctorFail {
+			failed++
+		}
+	}
+	if *format != "text" {
+		if err := writeOutput(results); err != nil {
+			return err
+		}
+	} else {
+		writeDoctorResults(os.Stdout, results)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// writeDoctorResults writes a line for each result, followed by how to fix
+// it, if there is a fix.
+func writeDoctorResults(w io.Writer, results []doctorResult) {
+	for _, r := range results {
+		fmt.Fprintf(w, "%-4s  %-10s  %s\n", strings.ToUpper(r.Status), r.Check, r.Detail)
+		if r.Fix != "" {
+			fmt.Fprintf(w, "%-4s  %-10s  fix: %s\n", "", "", r.Fix)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestDoctor returns a doctor whose services are stood in for by srv,
+// and whose config, cache, and data are in a temporary directory.
+func newTestDoctor(t *testing.T, srv *httptest.Server) doctor {
+	dir, err := ioutil.TempDir("", "ph-doctor")
+	if err != nil {
+		t.Fatalf("unable to create temporary dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	var cfg config
+	cfg.Endpoints = endpointsConfig{RadioCo: srv.URL, Relisten: srv.URL, PhishNet: srv.URL, SetlistFM: srv.URL}
+	return doctor{
+		cfg:        cfg,
+		configDir:  filepath.Join(dir, "config"),
+		configPath: filepath.Join(dir, "config", configFile),
+		cacheDir:   filepath.Join(dir, "cache"),
+		store:      &store{dir: filepath.Join(dir, "data")},
+		client:     srv.Client(),
+	}
+}
+
+func newDoctorServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/stations/"+defaultStation+"/status":
+			w.Write([]byte(`{"status": "online"}`))
+		case r.URL.Path == "/artists":
+			w.Write([]byte(`[]`))
+		case strings.HasPrefix(r.URL.Path, "/v5/setlists/"):
+			if r.URL.Query().Get("apikey") != "good" {
+				w.Write([]byte(`{"error": true, "error_message": "Invalid API key"}`))
+				return
+			}
+			w.Write([]byte(`{"error": false, "data": []}`))
+		case r.URL.Path == "/search/setlists":
+			if r.Header.Get("x-api-key") != "good" {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			http.NotFound(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func doctorStatuses(results []doctorResult) map[string]string {
+	statuses := make(map[string]string, len(results))
+	for _, r := range results {
+		statuses[r.Check] = r.Status
+	}
+	return statuses
+}
+
+func TestDoctor_Run(t *testing.T) {
+	srv := newDoctorServer()
+	defer srv.Close()
+
+	tt := []struct {
+		desc  string
+		setup func(d *doctor)
+		want  map[string]string
+	}{
+		{
+			desc: "defaults",
+			want: map[string]string{
+				"config":     doctorOK,
+				"artists":    doctorOK,
+				"radio.co":   doctorOK,
+				"Relisten":   doctorOK,
+				"phish.net":  doctorSkip,
+				"setlist.fm": doctorSkip,
+				"logins":     doctorOK,
+				"cache":      doctorOK,
+				"data":       doctorOK,
+			},
+		},
+		{
+			desc: "working API keys",
+			setup: func(d *doctor) {
+				d.cfg.Catalog.PhishNetAPIKey = "good"
+				d.cfg.Setlists.SetlistFMAPIKey = "good"
+			},
+			want: map[string]string{"phish.net": doctorOK, "setlist.fm": doctorOK},
+		},
+		{
+			desc: "rejected API keys",
+			setup: func(d *doctor) {
+				d.cfg.Catalog.PhishNetAPIKey = "bad"
+				d.cfg.Setlists.SetlistFMAPIKey = "bad"
+			},
+			want: map[string]string{"phish.net": doctorFail, "setlist.fm": doctorFail},
+		},
+		{
+			desc: "unknown station",
+			setup: func(d *doctor) {
+				d.cfg.Station = "nope"
+			},
+			want: map[string]string{"radio.co": doctorFail},
+		},
+		{
+			desc: "invalid config file",
+			setup: func(d *doctor) {
+				writeTestFile(t, d.configPath, "timeouts:\n  relisten: -1s\nnot_a_setting: true\n")
+			},
+			want: map[string]string{"config": doctorFail},
+		},
+		{
+			desc: "invalid artist sources",
+			setup: func(d *doctor) {
+				writeTestFile(t, filepath.Join(d.configDir, artistSourcesFile), "- relisten: [Goose]\n")
+			},
+			want: map[string]string{"artists": doctorFail},
+		},
+		{
+			desc: "Spotify configured but not logged in",
+			setup: func(d *doctor) {
+				d.cfg.Spotify.ClientID = "id"
+			},
+			want: map[string]string{"logins": doctorWarn},
+		},
+		{
+			desc: "data from a newer ph",
+			setup: func(d *doctor) {
+				writeTestFile(t, d.store.path(schemaFile), `{"version": 1000}`)
+			},
+			want: map[string]string{"data": doctorFail},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			d := newTestDoctor(t, srv)
+			if tc.setup != nil {
+				tc.setup(&d)
+			}
+			got := doctorStatuses(d.run(context.Background()))
+			for check, want := range tc.want {
+				if got[check] != want {
+					t.Errorf("wanted %s to be %s, but got %s", check, want, got[check])
+				}
+			}
+		})
+	}
+}
+
+func TestDoctor_CheckData_BadPlays(t *testing.T) {
+	srv := newDoctorServer()
+	defer srv.Close()
+	d := newTestDoctor(t, srv)
+	writeTestFile(t, d.store.path(playsFile), "{\"title\": \"Reba\"}\nnot json\n")
+	if err := d.store.setSchemaVersion(migrations[len(migrations)-1].version); err != nil {
+		t.Fatalf("unable to set schema version: %v", err)
+	}
+	r := d.checkData()
+	if r.Status != doctorWarn {
+		t.Errorf("wanted %s, but got %s: %s", doctorWarn, r.Status, r.Detail)
+	}
+	if want := "1 of 2 plays"; !strings.Contains(r.Detail, want) {
+		t.Errorf("wanted detail containing %q, but got %q", want, r.Detail)
+	}
+}
+
+func TestWriteDoctorResults(t *testing.T) {
+	var b bytes.Buffer
+	writeDoctorResults(&b, []doctorResult{
+		{Check: "config", Status: doctorOK, Detail: "config.yaml is valid"},
+		{Check: "phish.net", Status: doctorFail, Detail: "the API key was rejected", Fix: "check catalog.phishnet_api_key"},
+	})
+	want := "OK    config      config.yaml is valid\n" +
+		"FAIL  phish.net   the API key was rejected\n" +
+		"                  fix: check catalog.phishnet_api_key\n"
+	if got := b.String(); got != want {
+		t.Errorf("wanted:\n%s\nbut got:\n%s", want, got)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unable to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unable to write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+	flag "github.com/spf13/pflag"
+)
+
+// runDownload downloads the audio for a show, by default the show that the
+// current track is from. Only sources listed on Relisten are downloaded;
+// Relisten only catalogs recordings from archives that permit free
+// distribution, such as archive.org and phish.in.
+func runDownload(args []string) error {
+	var (
+		fs      = flag.NewFlagSet("download", flag.ExitOnError)
+		dir     = fs.StringP("dir", "d", ".", "Directory to download shows into")
+		artist  = fs.String("artist", "", "Artist to download a show for, instead of the current track's")
+		dateStr = fs.String("date", "", "Date of the show to download (YYYY-MM-DD), instead of the current track's")
+	)
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	show := Track{Artist: *artist}
+	if *dateStr != "" {
+		d, err := time.Parse("2006-01-02", *dateStr)
+		if err != nil {
+			return fmt.Errorf("invalid date %q: %w", *dateStr, err)
+		}
+		show.PerformanceTime = d
+	}
+	if show.Artist == "" || show.PerformanceTime.IsZero() {
+		status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+		if err != nil {
+			return err
+		}
+		if show.Artist == "" {
+			show.Artist = status.CurrentTrack.Artist
+		}
+		if show.PerformanceTime.IsZero() {
+			show.PerformanceTime = status.CurrentTrack.PerformanceTime
+		}
+	}
+	if show.Artist == "" || show.PerformanceTime.IsZero() {
+		return errors.New("the current track is not from a live show; use --artist and --date to choose one")
+	}
+
+	rc := newRelistenClient(cfg)
+	artists, err := relistenGetArtists(rc, cfg)
+	if err != nil {
+		return fmt.Errorf("get Relisten artists: %w", err)
+	}
+	slug, ok := artists[show.Artist]
+	if !ok {
+		return fmt.Errorf("%s is not available on Relisten", show.Artist)
+	}
+	rshow, err := rc.Show(context.Background(), slug, show.PerformanceTime)
+	if err != nil {
+		return err
+	}
+	source, ok := bestSource(rshow)
+	if !ok {
+		return fmt.Errorf("no sources available for %s on %s", show.Artist, show.PerformanceTime.Format("2006-01-02"))
+	}
+	showDir := filepath.Join(*dir, sanitizeFilename(show.Artist), showDirName(rshow))
+	if err := os.MkdirAll(showDir, os.FileMode(0777)); err != nil {
+		return err
+	}
+	tracks := source.Tracks()
+	for i, track := range tracks {
+		if track.MP3URL == "" {
+			continue
+		}
+		var (
+			name  = fmt.Sprintf("%02d %s%s", i+1, sanitizeFilename(track.Title), filepath.Ext(track.MP3URL))
+			label = fmt.Sprintf("[%d/%d] %s", i+1, len(tracks), track.Title)
+		)
+		if err := downloadFile(httpClient(cfg), track.MP3URL, filepath.Join(showDir, name), label); err != nil {
+			return fmt.Errorf("download %q: %w", track.Title, err)
+		}
+	}
+	fmt.Printf("Downloaded to %s\n", showDir)
+	return nil
+}
+
+// showDirName names the directory a show is downloaded into, as in
+// "1997-11-17 McNichols Arena, Denver, CO".
+func showDirName(show relisten.Show) string {
+	name := show.Date.Format("2006-01-02")
+	if v := show.Venue; v != nil {
+		if v.Name != "" {
+			name += " " + v.Name
+		}
+		if v.Location != "" {
+			name += ", " + v.Location
+		}
+	}
+	return sanitizeFilename(name)
+}
+
+var filenameReplacer = strings.NewReplacer(
+	"/", "-", `\`, "-", ":", "-", "*", "", "?", "", `"`, "'", "<", "", ">", "-", "|", "-",
+)
+
+// sanitizeFilename removes characters that are not allowed, or are awkward,
+// in file names on common filesystems.
+func sanitizeFilename(name string) string {
+	return strings.TrimSpace(filenameReplacer.Replace(name))
+}
+
+// downloadFile downloads url to path, showing progress prefixed by label.
+// Data is written to a partial file first, so that an interrupted download
+// can be resumed by running the command again.
+func downloadFile(client *http.Client, url, path, label string) error {
+	if _, err := os.Stat(path); err == nil {
+		fmt.Printf("%s: already downloaded\n", label)
+		return nil
+	}
+	partPath := path + ".part"
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// The server ignored the range request, so start over.
+		offset = 0
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is already complete.
+		return os.Rename(partPath, path)
+	default:
+		return errors.New(resp.Status)
+	}
+	f, err := os.OpenFile(partPath, flags, os.FileMode(0666))
+	if err != nil {
+		return err
+	}
+	pw := &progressWriter{
+		label:   label,
+		written: offset,
+		total:   offset + resp.ContentLength,
+	}
+	if resp.ContentLength < 0 {
+		pw.total = -1
+	}
+	_, err = io.Copy(f, io.TeeReader(resp.Body, pw))
+	pw.finish()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(partPath, path)
+}
+
+// progressWriter displays the progress of a download on a single,
+// continually rewritten line.
+type progressWriter struct {
+	label   string
+	written int64
+	total   int64
+	shown   time.Time
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.written += int64(len(p))
+	if time.Since(pw.shown) >= 200*time.Millisecond {
+		pw.show()
+	}
+	return len(p), nil
+}
+
+func (pw *progressWriter) show() {
+	pw.shown = time.Now()
+	const mb = 1 << 20
+	if pw.total <= 0 {
+		fmt.Printf("\r%s %.1f MB", pw.label, float64(pw.written)/mb)
+		return
+	}
+	fmt.Printf("\r%s %3d%% (%.1f/%.1f MB)",
+		pw.label, pw.written*100/pw.total, float64(pw.written)/mb, float64(pw.total)/mb)
+}
+
+func (pw *progressWriter) finish() {
+	pw.show()
+	fmt.Println()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadFile_Resume(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "track.mp3", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "ph-download")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "01 Track.mp3")
+
+	// Simulate an interrupted download.
+	if err := ioutil.WriteFile(path+".part", content[:4000], 0666); err != nil {
+		t.Fatalf("unable to write partial file: %v", err)
+	}
+	if err := downloadFile(srv.Client(), srv.URL, path, "test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read downloaded file: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("downloaded content does not match: got %d bytes, wanted %d", len(got), len(content))
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("partial file was not removed")
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tt := []struct {
+		in, want string
+	}{
+		{"Mercury", "Mercury"},
+		{"AC/DC Bag", "AC-DC Bag"},
+		{"Who Knows?", "Who Knows"},
+		{"Mike's Song > I Am Hydrogen", "Mike's Song - I Am Hydrogen"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := sanitizeFilename(tc.in); got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
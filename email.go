@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultDigestTime = "08:00"
+
+// Alert triggers for email. The stream triggers are the event types of
+// the same name.
+const (
+	alertFavoriteArtist = "favorite_artist"
+	alertFullShow       = "full_show"
+)
+
+// alertTriggers lists the triggers that email alerts can be sent for.
+var alertTriggers = []string{alertFavoriteArtist, alertFullShow, eventStreamOffline, eventStreamOnline}
+
+// emailConfig holds the settings for sending notifications by email.
+type emailConfig struct {
+	Server      string   `yaml:"server,omitempty" doc:"SMTP server as host:port, e.g. smtp.example.com:587; STARTTLS is used when the server offers it"`
+	Username    string   `yaml:"username,omitempty" doc:"Username for the SMTP server, if it requires authentication"`
+	Password    string   `yaml:"password,omitempty" doc:"Password for the SMTP server"`
+	From        string   `yaml:"from,omitempty" doc:"Sender address"`
+	To          []string `yaml:"to,omitempty" doc:"Recipient addresses"`
+	Alerts      []string `yaml:"alerts,omitempty" doc:"Send an email right away when these happen (ph serve): favorite_artist, full_show, stream_offline, stream_online"`
+	DailyDigest bool     `yaml:"daily_digest,omitempty" doc:"Email a digest of the previous day's plays each day (ph serve)"`
+	DigestTime  string   `yaml:"digest_time,omitempty" doc:"Local time of day to send the daily digest, as HH:MM (default 08:00)"`
+}
+
+func (c emailConfig) digestTime() string {
+	if c.DigestTime != "" {
+		return c.DigestTime
+	}
+	return defaultDigestTime
+}
+
+// emailNotifier delivers notifications as plain text email.
+type emailNotifier struct {
+	cfg emailConfig
+
+	// sendMail sends a message; it is smtp.SendMail except in tests.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func newEmailNotifier(cfg emailConfig) emailNotifier {
+	return emailNotifier{cfg: cfg, sendMail: smtp.SendMail}
+}
+
+func (e emailNotifier) notify(n notification) error {
+	if len(e.cfg.To) == 0 || e.cfg.From == "" {
+		return errors.New("send email: notify.email.from and notify.email.to must be set")
+	}
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(e.cfg.Server)
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, host)
+	}
+	if err := e.sendMail(e.cfg.Server, auth, e.cfg.From, e.cfg.To, emailMessage(e.cfg.From, e.cfg.To, n, time.Now())); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+	return nil
+}
+
+// emailMessage formats n as an email message.
+func emailMessage(from string, to []string, n notification, date time.Time) []byte {
+	var b strings.Builder
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", n.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+	// SMTP requires CRLF line endings, and a line of just "." would end
+	// the message early, so such lines are escaped.
+	for _, line := range strings.Split(n.Text, "\n") {
+		if strings.HasPrefix(line, ".") {
+			line = "." + line
+		}
+		b.WriteString(strings.TrimSuffix(line, "\r") + "\r\n")
+	}
+	return []byte(b.String())
+}
+
+// alerter sends a notification for each event that matches one of its
+// triggers, as it observes station statuses.
+type alerter struct {
+	// mu guards the triggers, favorites, and notifier, which change when
+	// the config is reloaded. Without a notifier, nothing is sent.
+	mu        sync.Mutex
+	triggers  []string
+	favorites favoritesConfig
+	notifier  notifier
+
+	// seen is the state of the station last seen, which is saved in
+	// snapshots.
+	seen lastSeen
+
+	// cfg gives the links sent with each track.
+	cfg config
+}
+
+// alert returns the notification for e, and reports whether e matches one
+// of the triggers.
+func (a *alerter) alert(e event) (notification, bool) {
+	for _, trigger := range a.triggers {
+		if trigger == e.Type {
+			return notification{
+				Subject: "JEMP Radio: " + strings.Replace(e.Type, "_", " ", -1),
+				Text:    fmt.Sprintf("The stream went %s at %s.", strings.TrimPrefix(e.Type, "stream_"), e.Time.Local().Format("Mon 2-Jan 15:04")),
+			}, true
+		}
+		if e.Type != eventTrackStarted {
+			continue
+		}
+		t := Track(*e.Track)
+		if trigger == alertFavoriteArtist && ByArtist(a.favorites.Artists...)(t) ||
+			trigger == alertFullShow && ByKind(trackKindFullShow)(t) {
+			return trackNotification(t, a.cfg), true
+		}
+	}
+	return notification{}, false
+}
+
+// trackNotification announces that t is airing, with links to the show, as
+// cfg gives them.
+func trackNotification(t Track, cfg config) notification {
+	lines := []string{"Now playing: " + trackSummary(t)}
+	lines = append(lines, linkLines(t.Links(cfg, relistenArtists))...)
+	return notification{Subject: "JEMP Radio: " + trackSummary(t), Text: strings.Join(lines, "\n"), PlayID: playID(t)}
+}
+
+// run sends alerts for the statuses published on the bus, until the bus is
+// closed. Unless the state was restored from a snapshot, the first status
+// only establishes what is airing, so that restarting does not repeat an
+// alert. Errors are reported to onError.
+func (a *alerter) run(events <-chan interface{}, onError func(error)) {
+	for e := range events {
+		fetched, ok := e.(statusFetched)
+		if !ok {
+			continue
+		}
+		for _, change := range a.seen.observe(fetched.Status, fetched.Time) {
+			a.mu.Lock()
+			n, ok := a.alert(change)
+			nt := a.notifier
+			a.mu.Unlock()
+			if ok && nt != nil {
+				if err := nt.notify(n); err != nil {
+					onError(err)
+				}
+			}
+		}
+	}
+}
+
+// configure changes the triggers, the favorites they match, and where the
+// alerts are sent.
+func (a *alerter) configure(triggers []string, favorites favoritesConfig, n notifier) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.triggers, a.favorites, a.notifier = triggers, favorites, n
+}
+
+// nextDigestTime returns the first time after now that is at the time of
+// day at, given as HH:MM, in now's location.
+func nextDigestTime(now time.Time, at string) (time.Time, error) {
+	tod, err := time.Parse("15:04", at)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time of day %q: must be HH:MM", at)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), tod.Hour(), tod.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// sendDailyDigests sends a digest of the previous day's plays at the
+// configured time each day, until ctx is canceled. Errors are reported to
+// onError.
+func sendDailyDigests(ctx context.Context, st *store, cfg emailConfig, n notifier, onError func(error)) {
+	for {
+		next, err := nextDigestTime(time.Now(), cfg.digestTime())
+		if err != nil {
+			onError(err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		plays, err := st.plays()
+		if err != nil {
+			onError(fmt.Errorf("read play history: %w", err))
+			continue
+		}
+		d := buildDigest(plays, next.AddDate(0, 0, -1), next, defaultDigestTop)
+		if d.Plays == 0 {
+			continue
+		}
+		if err := n.notify(notification{Subject: d.subject(), Text: d.text()}); err != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"net/smtp"
+	"testing"
+	"time"
+)
+
+func TestEmailMessage(t *testing.T) {
+	var (
+		date = time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+		n    = notification{Subject: "JEMP Radio digest: Fri 31-May-2024 to Fri 31-May-2024", Text: "12 plays\n.\nTop artists"}
+		got  = string(emailMessage("ph@example.com", []string{"a@example.com", "b@example.com"}, n, date))
+		want = "From: ph@example.com\r\n" +
+			"To: a@example.com, b@example.com\r\n" +
+			"Subject: JEMP Radio digest: Fri 31-May-2024 to Fri 31-May-2024\r\n" +
+			"Date: Sat, 01 Jun 2024 08:00:00 +0000\r\n" +
+			"MIME-Version: 1.0\r\n" +
+			"Content-Type: text/plain; charset=\"utf-8\"\r\n" +
+			"Content-Transfer-Encoding: 8bit\r\n" +
+			"\r\n" +
+			"12 plays\r\n..\r\nTop artists\r\n"
+	)
+	if got != want {
+		t.Errorf("wanted\n%q\nbut got\n%q", want, got)
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	var (
+		gotAddr string
+		gotTo   []string
+		gotAuth bool
+		cfg     = emailConfig{
+			Server:   "smtp.example.com:587",
+			Username: "ph",
+			Password: "secret",
+			From:     "ph@example.com",
+			To:       []string{"me@example.com"},
+		}
+		e = newEmailNotifier(cfg)
+	)
+	e.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotTo, gotAuth = addr, to, a != nil
+		return nil
+	}
+	if err := e.notify(notification{Subject: "hi", Text: "there"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotAddr != cfg.Server || len(gotTo) != 1 || gotTo[0] != "me@example.com" || !gotAuth {
+		t.Errorf("unexpected delivery to %s %v (auth %v)", gotAddr, gotTo, gotAuth)
+	}
+
+	e.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		return errors.New("535 authentication failed")
+	}
+	if err := e.notify(notification{Subject: "hi"}); err == nil {
+		t.Error("wanted an error, but got none")
+	}
+}
+
+type recordingNotifier []notification
+
+func (r *recordingNotifier) notify(n notification) error {
+	*r = append(*r, n)
+	return nil
+}
+
+func TestAlerter(t *testing.T) {
+	var (
+		sent recordingNotifier
+		a    = &alerter{
+			triggers:  []string{alertFavoriteArtist, eventStreamOffline},
+			favorites: favoritesConfig{Artists: []string{"Goose"}},
+			notifier:  &sent,
+		}
+		events = make(chan interface{}, 5)
+		at     = func(clock string) time.Time { return mustParseDate("2024-06-01T" + clock) }
+		status = func(state string, t Track) statusFetched {
+			return statusFetched{Status: statusResponseBody{Status: state, CurrentTrack: t}, Time: t.StartTime}
+		}
+	)
+	events <- status("online", Track{Artist: "Goose", Title: "Arcadia", StartTime: at("20:00:00")})
+	events <- status("online", Track{Artist: "Phish", Title: "Reba", StartTime: at("20:10:00")})
+	events <- status("online", Track{Artist: "Goose", Title: "Hungersite", StartTime: at("20:25:00")})
+	events <- statusFetched{Status: statusResponseBody{Status: "offline"}, Time: at("20:40:00")}
+	close(events)
+	a.run(events, func(err error) { t.Errorf("unexpected error: %v", err) })
+
+	want := []string{"JEMP Radio: Goose - Hungersite", "JEMP Radio: stream offline"}
+	if len(sent) != len(want) {
+		t.Fatalf("wanted alerts %q, but got %v", want, sent)
+	}
+	for i, n := range sent {
+		if n.Subject != want[i] {
+			t.Errorf("alert %d: wanted %q, but got %q", i, want[i], n.Subject)
+		}
+	}
+}
+
+func TestNextDigestTime(t *testing.T) {
+	loc := time.FixedZone("PDT", -7*60*60)
+	tt := []struct {
+		desc string
+		now  time.Time
+		want time.Time
+	}{
+		{
+			desc: "later today",
+			now:  time.Date(2024, 6, 1, 7, 30, 0, 0, loc),
+			want: time.Date(2024, 6, 1, 8, 0, 0, 0, loc),
+		},
+		{
+			desc: "tomorrow",
+			now:  time.Date(2024, 6, 1, 8, 0, 0, 0, loc),
+			want: time.Date(2024, 6, 2, 8, 0, 0, 0, loc),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := nextDigestTime(tc.now, "08:00")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+	if _, err := nextDigestTime(time.Now(), "8am"); err == nil {
+		t.Error("wanted an error for an invalid time, but got none")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	flag "github.com/spf13/pflag"
+)
+
+// enrichPlay returns a play recorded by an older ph as a newer one would
+// record it, and reports whether anything changed. A play whose title the
+// parser could not make sense of, and so kept whole, is parsed again, and a
+// title corrected against the song catalog is corrected again from the
+// title as broadcast. The play keeps the ID it was first recorded with.
+func enrichPlay(t Track, catalog songCatalog) (Track, bool) {
+	before := t
+	if t.PlayID == "" {
+		t.PlayID = playID(t)
+	}
+	if t.Artist == "" && t.PerformanceTime.IsZero() && t.Set == nil {
+		var parsed Track
+		parsed.parseRawTitle(t.Title)
+		t.Artist, t.Title, t.PerformanceTime, t.Location, t.Set, t.Break =
+			parsed.Artist, parsed.Title, parsed.PerformanceTime, parsed.Location, parsed.Set, parsed.Break
+	}
+	if len(catalog) > 0 {
+		if t.RawTitle != "" {
+			t.Title, t.RawTitle = t.RawTitle, ""
+		}
+		t = catalog.correct(t)
+	}
+	return t, t != before
+}
+
+func runDB(args []string) error {
+	const usage = "usage: ph db enrich|migrate"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "enrich":
+		return runDBEnrich(args[1:])
+	case "migrate":
+		return runDBMigrate(args[1:])
+	default:
+		return errors.New(usage)
+	}
+}
+
+func runDBEnrich(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("db enrich", flag.ExitOnError)
+		dryRun = fs.BoolP("dry-run", "n", false, "List the plays that would change without changing them")
+	)
+	fs.Parse(args)
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	plays, err := st.recordedPlays()
+	if err != nil {
+		return err
+	}
+	var catalog songCatalog
+	if cfg.Catalog.Autocorrect {
+		catalog = loadSongCatalog(context.Background(), apiClient(cfg), cfg)
+	}
+
+	var (
+		enriched = make(TrackList, len(plays))
+		changed  TrackList
+		was      = make(map[string]Track)
+	)
+	for i, t := range plays {
+		e, ok := enrichPlay(t, catalog)
+		enriched[i] = e
+		if ok && trackSummary(e) != trackSummary(t) {
+			changed = append(changed, e)
+			was[e.PlayID] = t
+		}
+	}
+	if len(changed) == 0 {
+		fmt.Println(tr("Every play is up to date."))
+		return nil
+	}
+
+	// Show where each upgraded play can now be heard, since a play whose
+	// show is newly known links to the recording of it.
+	rc := newRelistenClient(cfg)
+	if relistenArtists, err = relistenGetArtists(rc, cfg); err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	links, err := (&LinkResolver{Relisten: rc, cfg: cfg}).ResolveLinks(context.Background(), changed)
+	if err != nil {
+		warn(fmt.Errorf("unable to check links: %w", err))
+	}
+	for _, t := range changed {
+		fmt.Printf("%s\n    %s %s\n", trackSummary(t), tr("was"), trackSummary(was[t.PlayID]))
+		for _, l := range links[t.ID()] {
+			if l.Skipped == "" {
+				fmt.Printf("    %s: %s\n", l.Service, l.URL)
+			}
+		}
+	}
+	if *dryRun {
+		fmt.Printf(tr("%d plays would be updated.")+"\n", len(changed))
+		return nil
+	}
+	if err := st.replacePlays(enriched); err != nil {
+		return fmt.Errorf("update play history: %w", err)
+	}
+	fmt.Printf(tr("Updated %d plays.")+"\n", len(changed))
+	return nil
+}
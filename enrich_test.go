@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnrichPlay(t *testing.T) {
+	start := time.Date(2020, 5, 28, 8, 1, 32, 0, time.UTC)
+	catalog := make(songCatalog)
+	catalog.add("Phish", "Chalk Dust Torture", "Tweezer")
+	tt := []struct {
+		desc        string
+		play        Track
+		catalog     songCatalog
+		wantArtist  string
+		wantTitle   string
+		wantRaw     string
+		wantChanged bool
+	}{
+		{
+			desc:        "unparsed title",
+			play:        Track{Title: "Phish - Chalk Dust Torture (7-18-14)", StartTime: start},
+			wantArtist:  "Phish",
+			wantTitle:   "Chalk Dust Torture",
+			wantChanged: true,
+		},
+		{
+			desc:        "unparsed title with a typo",
+			play:        Track{Title: "Phish - Chalk Dust Tortur (7-18-14)", StartTime: start},
+			catalog:     catalog,
+			wantArtist:  "Phish",
+			wantTitle:   "Chalk Dust Torture",
+			wantRaw:     "Chalk Dust Tortur",
+			wantChanged: true,
+		},
+		{
+			desc:       "parsed title",
+			play:       Track{Artist: "Phish", Title: "Tweezer", PerformanceTime: start, StartTime: start},
+			catalog:    catalog,
+			wantArtist: "Phish",
+			wantTitle:  "Tweezer",
+		},
+		{
+			desc:       "corrected title",
+			play:       Track{Artist: "Phish", Title: "Tweezer", RawTitle: "Tweezzer", PerformanceTime: start, StartTime: start},
+			catalog:    catalog,
+			wantArtist: "Phish",
+			wantTitle:  "Tweezer",
+			wantRaw:    "Tweezzer",
+		},
+		{
+			desc:      "title still not understood",
+			play:      Track{Title: "The Phishsonian Hour", StartTime: start},
+			wantTitle: "The Phishsonian Hour",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			tc.play.PlayID = playID(tc.play)
+			got, changed := enrichPlay(tc.play, tc.catalog)
+			if got.Artist != tc.wantArtist || got.Title != tc.wantTitle || got.RawTitle != tc.wantRaw {
+				t.Errorf("wanted %q - %q (from %q), but got %q - %q (from %q)",
+					tc.wantArtist, tc.wantTitle, tc.wantRaw, got.Artist, got.Title, got.RawTitle)
+			}
+			if changed != tc.wantChanged {
+				t.Errorf("wanted changed=%t, but got %t", tc.wantChanged, changed)
+			}
+			if got.PlayID != tc.play.PlayID {
+				t.Errorf("wanted play ID %q to be kept, but got %q", tc.play.PlayID, got.PlayID)
+			}
+		})
+	}
+}
+
+func TestStore_ReplacePlays(t *testing.T) {
+	st := newTestStore(t)
+	start := time.Now().Truncate(time.Second)
+	raw := Track{Title: "Phish - Chalk Dust Torture (7-18-14)", StartTime: start}
+	if _, err := st.recordPlay(raw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plays, err := st.recordedPlays()
+	if err != nil {
+		t.Fatalf("unexpected error reading plays: %v", err)
+	}
+	enriched, _ := enrichPlay(plays[0], nil)
+	if err := st.replacePlays(TrackList{enriched}); err != nil {
+		t.Fatalf("unexpected error replacing plays: %v", err)
+	}
+	plays, err = st.recordedPlays()
+	if err != nil {
+		t.Fatalf("unexpected error reading plays: %v", err)
+	}
+	if len(plays) != 1 || plays[0].Artist != "Phish" || plays[0].Title != "Chalk Dust Torture" {
+		t.Errorf("unexpected plays: %v", plays)
+	}
+
+	// The track is still playing, and must not be recorded again now that
+	// its title has changed.
+	if recorded, _ := st.recordPlay(raw); recorded {
+		t.Errorf("wanted enriched play not to be recorded again")
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	eventsFile = "events.jsonl"
+
+	// eventsStateFile keeps the state of the station as of the last status
+	// the event recorder observed, so that a later run can pick up from it
+	// without reading the event log.
+	eventsStateFile = "events.json"
+
+	// recentEventsTail is how much of the end of the event log is read to
+	// restore the state of the station when there is no saved state, as
+	// after upgrading from a version of ph that did not save it.
+	recentEventsTail = 16 * 1024
+)
+
+// Types of events recorded in the event log.
+const (
+	eventTrackStarted  = "track_started"
+	eventTrackEnded    = "track_ended"
+	eventStreamOffline = "stream_offline"
+	eventStreamOnline  = "stream_online"
+	eventBreakStarted  = "break_started"
+	eventBreakEnded    = "break_ended"
+)
+
+// event is a change in the state of the station. Unlike the play history,
+// which records snapshots of what was playing, the event log records each
+// transition, so a track that airs twice in a row is still seen twice.
+type event struct {
+	Time  time.Time   `json:"time"`
+	Type  string      `json:"type"`
+	Track *playRecord `json:"track,omitempty"`
+}
+
+func (e event) String() string {
+	s := fmt.Sprintf("%s  %-14s", e.Time.Local().Format("2006-01-02 15:04:05"), e.Type)
+	if e.Track != nil {
+		s += "  " + trackSummary(Track(*e.Track))
+		if b := e.Track.Break; b != nil && b.Kind != breakKindStationID {
+			s += "  [" + b.String() + "]"
+		}
+	}
+	return s
+}
+
+// trackSummary renders a track on a single line, without links or timing.
+func trackSummary(t Track) string {
+	s := t.Title
+	if t.Artist != "" {
+		s = t.Artist + " - " + s
+	}
+	if pt := t.PerformanceTime; !pt.IsZero() {
+		s += fmt.Sprintf(" (%s)", pt.Format("Mon 2-Jan-2006"))
+	}
+	return s
+}
+
+// stationState is what is known about the station as of the most recent
+// event, and when a status last showed it so.
+type stationState struct {
+	Offline bool
+	Track   *Track
+	Seen    time.Time
+}
+
+// apply updates the state to reflect that e happened.
+func (s *stationState) apply(e event) {
+	switch e.Type {
+	case eventTrackStarted, eventBreakStarted:
+		t := Track(*e.Track)
+		s.Track = &t
+		s.Offline = false
+	case eventTrackEnded, eventBreakEnded:
+		s.Track = nil
+	case eventStreamOffline:
+		s.Offline = true
+		s.Track = nil
+	case eventStreamOnline:
+		s.Offline = false
+	}
+}
+
+// statusEvents returns the events implied by the station moving from state
+// to status. Events are timed by track start times when available, and
+// otherwise by now. A track ends when the next one starts; if that is not
+// known, it is taken to have ended when it was last seen airing, if that
+// is known, since much time may have passed between runs of ph. Station
+// breaks start and end like tracks, but with their own event types, so
+// that they can be told apart from music.
+func statusEvents(state stationState, status statusResponseBody, now time.Time) []event {
+	var (
+		events  []event
+		offline = status.Status == "offline"
+		current = status.CurrentTrack
+	)
+	if offline && state.Offline {
+		return nil
+	}
+	if !offline && state.Track != nil && state.Track.Key().Equal(current.Key()) {
+		return nil
+	}
+	if state.Track != nil {
+		ended := now
+		if !state.Seen.IsZero() && state.Seen.Before(now) {
+			ended = state.Seen
+		}
+		if st := current.StartTime; !offline && !st.IsZero() && st.After(state.Track.StartTime) {
+			ended = st
+		}
+		typ := eventTrackEnded
+		if state.Track.IsStationBreak() {
+			typ = eventBreakEnded
+		}
+		r := playRecord(*state.Track)
+		events = append(events, event{Time: ended, Type: typ, Track: &r})
+	}
+	if offline {
+		return append(events, event{Time: now, Type: eventStreamOffline})
+	}
+	if state.Offline {
+		events = append(events, event{Time: now, Type: eventStreamOnline})
+	}
+	if current.Title == "" {
+		return events
+	}
+	started := now
+	if st := current.StartTime; !st.IsZero() {
+		started = st
+	}
+	typ := eventTrackStarted
+	if current.IsStationBreak() {
+		typ = eventBreakStarted
+	}
+	r := playRecord(current)
+	return append(events, event{Time: started, Type: typ, Track: &r})
+}
+
+// events returns the events in the log that happened at or after since,
+// oldest first.
+func (s *store) events(since time.Time) ([]event, error) {
+	var events []event
+	err := s.readJSONLines(eventsFile, func(line []byte) error {
+		var e event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil
+		}
+		if (e.Type == eventTrackStarted || e.Type == eventBreakStarted) && e.Track == nil {
+			return nil
+		}
+		if !e.Time.Before(since) {
+			events = append(events, e)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// eventRecorder appends events to the event log as it observes station
+// statuses. The state of the station is restored the first time a status
+// is observed, so transitions are detected across runs, and saved after
+// each status.
+type eventRecorder struct {
+	store  *store
+	state  stationState
+	loaded bool
+}
+
+// observe records the events implied by status, and returns them.
+func (r *eventRecorder) observe(status statusResponseBody, now time.Time) ([]event, error) {
+	if !r.loaded {
+		state, err := r.store.eventsState()
+		if err != nil {
+			return nil, err
+		}
+		r.state, r.loaded = state, true
+	}
+	events := statusEvents(r.state, status, now)
+	for _, e := range events {
+		if err := r.store.appendJSONLine(eventsFile, e); err != nil {
+			return nil, err
+		}
+		r.state.apply(e)
+	}
+	r.state.Seen = now
+	if err := r.store.writeJSON(eventsStateFile, saveState(r.state)); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// eventsState returns the state of the station the event recorder last
+// saved. Without one, it is restored from the end of the event log.
+func (s *store) eventsState() (stationState, error) {
+	var saved *savedState
+	if err := s.readJSON(eventsStateFile, &saved); err != nil {
+		return stationState{}, err
+	}
+	if saved != nil {
+		return saved.stationState(), nil
+	}
+	var state stationState
+	err := s.readJSONLinesTail(eventsFile, recentEventsTail, func(line []byte) error {
+		var e event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil
+		}
+		if (e.Type == eventTrackStarted || e.Type == eventBreakStarted) && e.Track == nil {
+			return nil
+		}
+		state.apply(e)
+		return nil
+	})
+	return state, err
+}
+
+// run records the events implied by each status published on the bus,
+// until the bus is closed. Errors are reported to onError.
+func (r *eventRecorder) run(events <-chan interface{}, onError func(error)) {
+	for e := range events {
+		if e, ok := e.(statusFetched); ok {
+			if _, err := r.observe(e.Status, e.Time); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// eventWriter writes the events implied by the station statuses it
+// observes to w, as JSON lines, for other programs to read as they happen.
+// Unlike eventRecorder, it starts from knowing nothing of the station, so
+// the first status observed starts the track airing.
+type eventWriter struct {
+	w     io.Writer
+	state stationState
+}
+
+// observe writes the events implied by status.
+func (ew *eventWriter) observe(status statusResponseBody, now time.Time) error {
+	enc := json.NewEncoder(ew.w)
+	for _, e := range statusEvents(ew.state, status, now) {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("write event: %w", err)
+		}
+		ew.state.apply(e)
+	}
+	return nil
+}
+
+// runEvents replays events from the event log.
+func runEvents(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("events", flag.ExitOnError)
+		since  = fs.Duration("since", 24*time.Hour, "Show events within this long ago; 0 shows all events")
+		asJSON = fs.Bool("json", false, "Print events as JSON lines")
+	)
+	fs.Parse(args)
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	events, err := st.events(from)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if *asJSON {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			continue
+		}
+		fmt.Println(e)
+	}
+	return nil
+}
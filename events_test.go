@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusEvents(t *testing.T) {
+	var (
+		now     = mustParseDate("2020-06-05T20:30:00")
+		mercury = Track{Artist: "Phish", Title: "Mercury", StartTime: mustParseDate("2020-06-05T20:00:00")}
+		reba    = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:12:00")}
+		// The same song aired again later is a distinct airing.
+		mercuryAgain = Track{Artist: "Phish", Title: "Mercury", StartTime: mustParseDate("2020-06-05T20:25:00")}
+		stationID    = Track{Artist: "jempradio.com", Title: "JEMP Radio", StartTime: mustParseDate("2020-06-05T20:20:00")}
+	)
+	tt := []struct {
+		desc   string
+		state  stationState
+		status statusResponseBody
+		want   []string
+	}{
+		{
+			desc:   "first track",
+			status: statusResponseBody{Status: "online", CurrentTrack: mercury},
+			want:   []string{eventTrackStarted},
+		},
+		{
+			desc:   "same track",
+			state:  stationState{Track: &mercury},
+			status: statusResponseBody{Status: "online", CurrentTrack: mercury},
+		},
+		{
+			desc:   "next track",
+			state:  stationState{Track: &mercury},
+			status: statusResponseBody{Status: "online", CurrentTrack: reba},
+			want:   []string{eventTrackEnded, eventTrackStarted},
+		},
+		{
+			desc:   "repeated track",
+			state:  stationState{Track: &mercury},
+			status: statusResponseBody{Status: "online", CurrentTrack: mercuryAgain},
+			want:   []string{eventTrackEnded, eventTrackStarted},
+		},
+		{
+			desc:   "station break",
+			state:  stationState{Track: &reba},
+			status: statusResponseBody{Status: "online", CurrentTrack: stationID},
+			want:   []string{eventTrackEnded, eventBreakStarted},
+		},
+		{
+			desc:   "after station break",
+			state:  stationState{Track: &stationID},
+			status: statusResponseBody{Status: "online", CurrentTrack: mercuryAgain},
+			want:   []string{eventBreakEnded, eventTrackStarted},
+		},
+		{
+			desc:   "stream goes offline",
+			state:  stationState{Track: &reba},
+			status: statusResponseBody{Status: "offline", CurrentTrack: reba},
+			want:   []string{eventTrackEnded, eventStreamOffline},
+		},
+		{
+			desc:   "stream stays offline",
+			state:  stationState{Offline: true},
+			status: statusResponseBody{Status: "offline"},
+		},
+		{
+			desc:   "stream comes back",
+			state:  stationState{Offline: true},
+			status: statusResponseBody{Status: "online", CurrentTrack: mercuryAgain},
+			want:   []string{eventStreamOnline, eventTrackStarted},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			events := statusEvents(tc.state, tc.status, now)
+			if len(events) != len(tc.want) {
+				t.Fatalf("wanted events %v, but got %v", tc.want, events)
+			}
+			for i, e := range events {
+				if e.Type != tc.want[i] {
+					t.Errorf("event %d: wanted %s, but got %s", i, tc.want[i], e.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestEventRecorder(t *testing.T) {
+	var (
+		st      = newTestStore(t)
+		now     = mustParseDate("2020-06-05T20:30:00")
+		mercury = Track{Artist: "Phish", Title: "Mercury", StartTime: mustParseDate("2020-06-05T20:00:00")}
+		reba    = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:12:00")}
+	)
+	r := &eventRecorder{store: st}
+	if _, err := r.observe(statusResponseBody{Status: "online", CurrentTrack: mercury}, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A new recorder, as in a later run of ph, picks up where the log left
+	// off.
+	r = &eventRecorder{store: st}
+	for _, track := range []Track{mercury, reba} {
+		if _, err := r.observe(statusResponseBody{Status: "online", CurrentTrack: track}, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	events, err := st.events(time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error reading events: %v", err)
+	}
+	want := []string{eventTrackStarted, eventTrackEnded, eventTrackStarted}
+	if len(events) != len(want) {
+		t.Fatalf("wanted events %v, but got %v", want, events)
+	}
+	for i, e := range events {
+		if e.Type != want[i] {
+			t.Errorf("event %d: wanted %s, but got %s", i, want[i], e.Type)
+		}
+	}
+	if got := events[1].Time; !got.Equal(reba.StartTime) {
+		t.Errorf("wanted track to end when the next began (%v), but got %v", reba.StartTime, got)
+	}
+	if since, _ := st.events(mustParseDate("2020-06-05T20:10:00")); len(since) != 2 {
+		t.Errorf("wanted 2 events since 20:10, but got %d", len(since))
+	}
+}
+
+func TestEventRecorder_EndsWhenLastSeen(t *testing.T) {
+	var (
+		st   = newTestStore(t)
+		seen = mustParseDate("2020-06-05T20:30:00")
+		reba = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:12:00")}
+	)
+	r := &eventRecorder{store: st}
+	if _, err := r.observe(statusResponseBody{Status: "online", CurrentTrack: reba}, seen); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Hours later, the stream is offline, so when Reba ended is not known;
+	// it was last seen airing at the last run.
+	r = &eventRecorder{store: st}
+	events, err := r.observe(statusResponseBody{Status: "offline"}, seen.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != eventTrackEnded {
+		t.Fatalf("wanted the track to end and the stream to go offline, but got %v", events)
+	}
+	if got := events[0].Time; !got.Equal(seen) {
+		t.Errorf("wanted the track to end when last seen (%v), but got %v", seen, got)
+	}
+}
+
+func TestStore_EventsState_FromLog(t *testing.T) {
+	var (
+		st   = newTestStore(t)
+		reba = playRecord{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:12:00")}
+	)
+	for _, e := range []event{
+		{Time: reba.StartTime, Type: eventTrackStarted, Track: &reba},
+		{Time: reba.StartTime.Add(time.Hour), Type: eventStreamOffline},
+	} {
+		if err := st.appendJSONLine(eventsFile, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state, err := st.eventsState()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !state.Offline || state.Track != nil {
+		t.Errorf("wanted the state restored from the log as offline, but got %+v", state)
+	}
+}
+
+func TestEventWriter(t *testing.T) {
+	var (
+		buf     bytes.Buffer
+		now     = mustParseDate("2020-06-05T20:30:00")
+		mercury = Track{Artist: "Phish", Title: "Mercury", StartTime: mustParseDate("2020-06-05T20:00:00")}
+		reba    = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:12:00")}
+	)
+	ew := &eventWriter{w: &buf}
+	statuses := []statusResponseBody{
+		{Status: "online", CurrentTrack: mercury},
+		{Status: "online", CurrentTrack: mercury},
+		{Status: "online", CurrentTrack: reba},
+		{Status: "offline"},
+	}
+	for _, status := range statuses {
+		if err := ew.observe(status, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := []string{eventTrackStarted, eventTrackEnded, eventTrackStarted, eventTrackEnded, eventStreamOffline}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("wanted %d lines, but got %d:\n%s", len(want), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var e event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %d: unable to decode %q: %v", i, line, err)
+		}
+		if e.Type != want[i] {
+			t.Errorf("line %d: wanted %s, but got %s", i, want[i], e.Type)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("line %d: wanted a timestamp, but got none", i)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimelineEntry is the status of the station at a point in time, as
+// radio.co sent it. A timeline is a sequence of them, oldest first, stored
+// one to a line.
+type TimelineEntry struct {
+	Time   time.Time       `json:"time"`
+	Status json.RawMessage `json:"status"`
+}
+
+// FakeStation stands in for radio.co, replaying a timeline of statuses as
+// though they were happening now. Time passes speed times as fast as on the
+// timeline, and the start times of tracks are moved to match, so that a
+// track that started a minute before a status was recorded started a
+// minute, divided by speed, before it is served. Once the timeline runs
+// out, the last status is served. It is safe for concurrent use.
+type FakeStation struct {
+	timeline []TimelineEntry
+	speed    float64
+	now      func() time.Time
+	start    time.Time
+}
+
+// NewFakeStation returns a station that starts replaying timeline now. A
+// speed of zero or less is taken as 1.
+func NewFakeStation(timeline []TimelineEntry, speed float64, now func() time.Time) *FakeStation {
+	if speed <= 0 {
+		speed = 1
+	}
+	timeline = append([]TimelineEntry(nil), timeline...)
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].Time.Before(timeline[j].Time) })
+	return &FakeStation{timeline: timeline, speed: speed, now: now, start: now()}
+}
+
+// Status returns the status the station has now, in radio.co's format.
+func (f *FakeStation) Status() (json.RawMessage, error) {
+	if len(f.timeline) == 0 {
+		return nil, errors.New("the timeline has no statuses")
+	}
+	var (
+		first   = f.timeline[0].Time
+		elapsed = time.Duration(float64(f.now().Sub(f.start)) * f.speed)
+		i       = sort.Search(len(f.timeline), func(i int) bool { return f.timeline[i].Time.After(first.Add(elapsed)) })
+	)
+	if i > 0 {
+		i--
+	}
+	// replayed maps a time on the timeline to when it happens in the replay.
+	replayed := func(t time.Time) time.Time {
+		return f.start.Add(time.Duration(float64(t.Sub(first)) / f.speed))
+	}
+	return shiftStartTimes(f.timeline[i].Status, replayed)
+}
+
+// ServeHTTP serves the station's status at any path ending in /status, as
+// radio.co does at /stations/<id>/status.
+func (f *FakeStation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/status") {
+		http.NotFound(w, r)
+		return
+	}
+	b, err := f.Status()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// shiftStartTimes returns status with the start time of each of its tracks
+// replaced by shift of it. Tracks without a start time that parses are
+// left as they are.
+func shiftStartTimes(status json.RawMessage, shift func(time.Time) time.Time) (json.RawMessage, error) {
+	var s map[string]interface{}
+	if err := json.Unmarshal(status, &s); err != nil {
+		return nil, fmt.Errorf("parse status: %w", err)
+	}
+	shiftTrack := func(v interface{}) {
+		track, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		start, _ := track["start_time"].(string)
+		if t, err := time.Parse(time.RFC3339, start); err == nil {
+			track["start_time"] = shift(t).Format(time.RFC3339)
+		}
+	}
+	shiftTrack(s["current_track"])
+	if history, ok := s["history"].([]interface{}); ok {
+		for _, t := range history {
+			shiftTrack(t)
+		}
+	}
+	return json.Marshal(s)
+}
+
+// readTimeline reads a timeline from the file at path, one entry to a line.
+func readTimeline(path string) ([]TimelineEntry, error) {
+	var (
+		timeline []TimelineEntry
+		st       = &store{dir: filepath.Dir(path)}
+	)
+	err := st.readJSONLines(filepath.Base(path), func(b []byte) error {
+		var e TimelineEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return err
+		}
+		timeline = append(timeline, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read timeline %s: %w", path, err)
+	}
+	if len(timeline) == 0 {
+		return nil, fmt.Errorf("read timeline %s: no statuses", path)
+	}
+	return timeline, nil
+}
+
+// demoTrack is a track of the bundled demo timeline, as titled on the
+// station, and how long it plays.
+type demoTrack struct {
+	title  string
+	length time.Duration
+}
+
+// demoSet is what the bundled demo timeline airs: part of a set from a
+// show on Relisten, so that its setlist can be shown, a station break, and
+// a few other bands.
+var demoSet = []demoTrack{
+	{"Phish - Wolfman's Brother (11-17-97)", 17 * time.Minute},
+	{"Phish - Piper (11-17-97)", 9 * time.Minute},
+	{"Phish - Reba (11-17-97)", 14 * time.Minute},
+	{"Phish - Bathtub Gin (11-17-97)", 15 * time.Minute},
+	{"jempradio.com - Station ID", time.Minute},
+	{"Goose - Arcadia (6-27-21)", 11 * time.Minute},
+	{"Grateful Dead - Scarlet Begonias (5-8-77)", 10 * time.Minute},
+	{"Grateful Dead - Fire on the Mountain (5-8-77)", 13 * time.Minute},
+}
+
+// demoTimeline returns the bundled demo timeline: a status for each track
+// of demoSet as it starts, with the tracks before it in the history, most
+// recent first, as radio.co lists them.
+func demoTimeline() []TimelineEntry {
+	type track struct {
+		Title     string `json:"title"`
+		StartTime string `json:"start_time"`
+	}
+	var (
+		timeline []TimelineEntry
+		history  []track
+		at       = time.Date(2020, 7, 4, 18, 0, 0, 0, time.UTC)
+	)
+	for _, d := range demoSet {
+		current := track{Title: d.title, StartTime: at.Format(time.RFC3339)}
+		history = append([]track{current}, history...)
+		b, _ := json.Marshal(map[string]interface{}{
+			"status":        "online",
+			"current_track": current,
+			"history":       history,
+		})
+		timeline = append(timeline, TimelineEntry{Time: at, Status: b})
+		at = at.Add(d.length)
+	}
+	return timeline
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFakeStation_Status(t *testing.T) {
+	var (
+		start = mustParseDate("2026-10-16T12:00:00")
+		now   = start
+		f     = NewFakeStation(demoTimeline(), 10, func() time.Time { return now })
+	)
+	tt := []struct {
+		desc      string
+		elapsed   time.Duration
+		wantTitle string
+		wantStart time.Time
+	}{
+		{"first track", 0, "Wolfman's Brother", start},
+		{"still first track", 100 * time.Second, "Wolfman's Brother", start},
+		// Wolfman's Brother plays for 17 minutes, a tenth of that replayed.
+		{"second track", 102 * time.Second, "Piper", start.Add(102 * time.Second)},
+		// The last track starts 77 minutes in.
+		{"after the timeline", 24 * time.Hour, "Fire on the Mountain", start.Add(462 * time.Second)},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			now = start.Add(tc.elapsed)
+			b, err := f.Status()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var status statusResponseBody
+			if err := json.Unmarshal(b, &status); err != nil {
+				t.Fatalf("unable to parse status: %v", err)
+			}
+			if got := status.CurrentTrack.Title; got != tc.wantTitle {
+				t.Errorf("wanted %q, but got %q", tc.wantTitle, got)
+			}
+			if got := status.CurrentTrack.StartTime; !got.Equal(tc.wantStart) {
+				t.Errorf("wanted start time %s, but got %s", tc.wantStart, got)
+			}
+		})
+	}
+}
+
+func TestFakeStation_GetStatus(t *testing.T) {
+	srv := httptest.NewServer(NewFakeStation(demoTimeline(), 1, time.Now))
+	defer srv.Close()
+
+	status, err := getStatus(context.Background(), radioCoClient{client: srv.Client(), endpoint: srv.URL}, "demo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := status.CurrentTrack.Artist, "Phish"; got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+	if d := status.CurrentTrack.Elapsed(); d < 0 || d > time.Minute {
+		t.Errorf("wanted the track to have just started, but it started %s ago", d)
+	}
+}
+
+func TestReadTimeline(t *testing.T) {
+	st := newTestStore(t)
+	want := demoTimeline()
+	for _, e := range want {
+		if err := st.appendJSONLine("timeline.jsonl", e); err != nil {
+			t.Fatalf("unable to write timeline: %v", err)
+		}
+	}
+	got, err := readTimeline(filepath.Join(st.dir, "timeline.jsonl"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wanted %d entries, but got %d", len(want), len(got))
+	}
+	for i := range got {
+		if !got[i].Time.Equal(want[i].Time) || string(got[i].Status) != string(want[i].Status) {
+			t.Errorf("entry %d: wanted %+v, but got %+v", i, want[i], got[i])
+		}
+	}
+	if _, err := readTimeline(filepath.Join(st.dir, "missing.jsonl")); err == nil {
+		t.Error("wanted an error for a missing timeline")
+	}
+}
+
+func TestFakeStation_ServeHTTP(t *testing.T) {
+	f := NewFakeStation(demoTimeline(), 1, time.Now)
+	for path, want := range map[string]int{
+		"/stations/demo/status": http.StatusOK,
+		"/stations/demo":        http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		f.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: wanted %d, but got %d", path, want, rec.Code)
+		}
+	}
+}
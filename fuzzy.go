@@ -0,0 +1,50 @@
+package main
+
+// editDistance returns the Levenshtein distance between a and b: the
+// number of single-character insertions, deletions, and substitutions
+// needed to turn one into the other.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// closestMatch returns the candidate with the smallest edit distance from
+// s, provided the distance is no more than maxDistance.
+func closestMatch(s string, candidates []string, maxDistance int) (string, bool) {
+	var (
+		best     string
+		bestDist = maxDistance + 1
+	)
+	for _, c := range candidates {
+		if d := editDistance(s, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best, bestDist <= maxDistance
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
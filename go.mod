@@ -1,9 +1,11 @@
 module github.com/ianfoo/ph
 
-go 1.14
+go 1.16
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/google/go-cmp v0.4.1
 	github.com/spf13/pflag v1.0.5
+	golang.org/x/crypto v0.21.0
 	gopkg.in/yaml.v2 v2.3.0
 )
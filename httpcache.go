@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiClient returns an HTTP client for third-party APIs, such as phish.net,
+// that caches responses in the ph cache directory as their cache headers
+// allow. The Relisten client caches its responses itself. If the cache directory cannot be determined,
+// responses are not cached.
+func apiClient(cfg config) *http.Client {
+	dir, err := cfg.cacheDir()
+	if err != nil {
+		return httpClient(cfg)
+	}
+	return &http.Client{Transport: &cachingTransport{
+		dir:  filepath.Join(dir, "http"),
+		base: cfg.Network.roundTripper(),
+		now:  appClock.Now,
+	}}
+}
+
+// cachingTransport is an http.RoundTripper that stores responses to GET
+// requests on disk, honoring Cache-Control and Expires. A response is
+// reused while it is fresh, and once stale is revalidated with its ETag or
+// Last-Modified time, so that an unchanged resource is not downloaded
+// again. It is a private cache, but requests with credentials in an
+// Authorization header are never cached.
+type cachingTransport struct {
+	dir  string
+	base http.RoundTripper
+	now  func() time.Time
+}
+
+func (c *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Authorization") != "" {
+		return c.base.RoundTrip(req)
+	}
+	var (
+		path        = c.path(req)
+		cached, err = c.read(path, req)
+		_, noCache  = cacheControl(req.Header)["no-cache"]
+	)
+	if err == nil && !noCache && c.fresh(cached) {
+		return cached, nil
+	}
+	if err == nil {
+		// Ask the server whether the cached copy is still good, without
+		// changing the caller's request.
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm := cached.Header.Get("Last-Modified"); lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+	}
+	resp, rerr := c.base.RoundTrip(req)
+	if rerr != nil {
+		return nil, rerr
+	}
+	if resp.StatusCode == http.StatusNotModified && err == nil {
+		resp.Body.Close()
+		for k, v := range resp.Header {
+			cached.Header[k] = v
+		}
+		cached.Header.Set("Date", c.now().UTC().Format(http.TimeFormat))
+		cached.Header.Del("Age")
+		c.write(path, cached)
+		return cached, nil
+	}
+	if !storable(resp) {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if resp.Header.Get("Date") == "" {
+		resp.Header.Set("Date", c.now().UTC().Format(http.TimeFormat))
+	}
+	c.write(path, resp)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// path returns the file a response to req is cached in.
+func (c *cachingTransport) path(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16]))
+}
+
+// read returns the response to req cached at path. A response that varies
+// on request headers is only returned if req has the same values for them
+// as the request it answered.
+func (c *cachingTransport) read(path string, req *http.Request) (*http.Response, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	// The request headers named in Vary are stored before the response,
+	// in the wire format of an HTTP header.
+	mh, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	varied := http.Header(mh)
+	for k := range varied {
+		if req.Header.Get(k) != varied.Get(k) {
+			return nil, os.ErrNotExist
+		}
+	}
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// write caches resp, which must have a body that can be read again, at
+// path. Failing to write the cache is not fatal, since the response can
+// always be fetched again.
+func (c *cachingTransport) write(path string, resp *http.Response) {
+	var b bytes.Buffer
+	varied := make(http.Header)
+	for _, v := range resp.Header.Values("Vary") {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				varied.Set(k, resp.Request.Header.Get(k))
+			}
+		}
+	}
+	varied.Write(&b)
+	b.WriteString("\r\n")
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	stored := *resp
+	stored.Body = ioutil.NopCloser(bytes.NewReader(body))
+	stored.ContentLength = int64(len(body))
+	stored.TransferEncoding = nil
+	stored.Header = resp.Header.Clone()
+	stored.Header.Del("Transfer-Encoding")
+	if err := stored.Write(&b); err != nil {
+		return
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := os.MkdirAll(c.dir, os.FileMode(0777)); err != nil {
+		return
+	}
+	writeFileAtomic(path, b.Bytes())
+}
+
+// fresh reports whether a cached response can be used without asking the
+// server.
+func (c *cachingTransport) fresh(resp *http.Response) bool {
+	directives := cacheControl(resp.Header)
+	if _, ok := directives["no-cache"]; ok {
+		return false
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return false
+	}
+	age := c.now().Sub(date)
+	if s, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+		age += time.Duration(s) * time.Second
+	}
+	if v, ok := directives["max-age"]; ok {
+		s, err := strconv.Atoi(v)
+		return err == nil && age < time.Duration(s)*time.Second
+	}
+	if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+		return date.Add(age).Before(expires)
+	}
+	return false
+}
+
+// storable reports whether resp can be cached: a successful response that
+// the server allows to be stored, and that is either fresh for a while or
+// can be revalidated.
+func storable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	directives := cacheControl(resp.Header)
+	if _, ok := directives["no-store"]; ok {
+		return false
+	}
+	if resp.Header.Get("Vary") == "*" {
+		return false
+	}
+	_, maxAge := directives["max-age"]
+	return maxAge || resp.Header.Get("Expires") != "" ||
+		resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// cacheControl parses the directives of a Cache-Control header, such as
+// "public, max-age=300", into a map from directive to value.
+func cacheControl(h http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			var value string
+			if i := strings.Index(d, "="); i >= 0 {
+				d, value = d[:i], strings.Trim(d[i+1:], `"`)
+			}
+			directives[strings.ToLower(d)] = value
+		}
+	}
+	return directives
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCachingTransport(t *testing.T) {
+	tt := []struct {
+		desc    string
+		headers map[string]string
+		elapsed time.Duration
+
+		// wantFetches is how many times the body is sent by the server for
+		// two requests, and wantRevalidations how many times the server
+		// answers that the cached body is unchanged.
+		wantFetches       int
+		wantRevalidations int
+	}{
+		{
+			desc:        "fresh by max-age",
+			headers:     map[string]string{"Cache-Control": "public, max-age=300"},
+			elapsed:     time.Minute,
+			wantFetches: 1,
+		},
+		{
+			desc:        "stale by max-age",
+			headers:     map[string]string{"Cache-Control": "max-age=300"},
+			elapsed:     10 * time.Minute,
+			wantFetches: 2,
+		},
+		{
+			desc:              "stale, revalidated by ETag",
+			headers:           map[string]string{"Cache-Control": "max-age=300", "ETag": `"v1"`},
+			elapsed:           10 * time.Minute,
+			wantFetches:       1,
+			wantRevalidations: 1,
+		},
+		{
+			desc:              "no-cache, revalidated by ETag",
+			headers:           map[string]string{"Cache-Control": "no-cache", "ETag": `"v1"`},
+			wantFetches:       1,
+			wantRevalidations: 1,
+		},
+		{
+			desc:        "no-store",
+			headers:     map[string]string{"Cache-Control": "no-store", "ETag": `"v1"`},
+			wantFetches: 2,
+		},
+		{
+			desc:        "fresh by Expires",
+			headers:     map[string]string{"Expires": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)},
+			elapsed:     time.Minute,
+			wantFetches: 1,
+		},
+		{
+			desc:        "no cache headers",
+			wantFetches: 2,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			var fetches, revalidations int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if etag := tc.headers["ETag"]; etag != "" && r.Header.Get("If-None-Match") == etag {
+					revalidations++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				fetches++
+				for k, v := range tc.headers {
+					w.Header().Set(k, v)
+				}
+				w.Write([]byte("Tweezer"))
+			}))
+			defer srv.Close()
+
+			now := time.Now()
+			client := &http.Client{Transport: &cachingTransport{
+				dir:  newTestStore(t).dir,
+				base: http.DefaultTransport,
+				now:  func() time.Time { return now },
+			}}
+			for i := 0; i < 2; i++ {
+				resp, err := client.Get(srv.URL + "/songs")
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != http.StatusOK || string(b) != "Tweezer" {
+					t.Fatalf("wanted 200 Tweezer, but got %d %s", resp.StatusCode, b)
+				}
+				now = now.Add(tc.elapsed)
+			}
+			if fetches != tc.wantFetches {
+				t.Errorf("wanted %d fetches, but got %d", tc.wantFetches, fetches)
+			}
+			if revalidations != tc.wantRevalidations {
+				t.Errorf("wanted %d revalidations, but got %d", tc.wantRevalidations, revalidations)
+			}
+		})
+	}
+}
+
+func TestCachingTransport_Vary(t *testing.T) {
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Cache-Control", "max-age=300")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &cachingTransport{
+		dir:  newTestStore(t).dir,
+		base: http.DefaultTransport,
+		now:  time.Now,
+	}}
+	for _, lang := range []string{"en", "en", "es"} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Language", lang)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != lang {
+			t.Errorf("wanted %q, but got %q", lang, b)
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("wanted 2 fetches, but got %d", fetches)
+	}
+}
@@ -0,0 +1,64 @@
+// Package humanize formats quantities for people to read, such as how long
+// ago a track started.
+package humanize
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Day is 24 hours, ignoring changes to and from daylight saving time.
+	Day = 24 * time.Hour
+
+	// Week is the longest unit Duration uses. Months and years vary in
+	// length, so they are left out.
+	Week = 7 * Day
+)
+
+var units = []struct {
+	d      time.Duration
+	suffix string
+}{
+	{Week, "w"},
+	{Day, "d"},
+	{time.Hour, "h"},
+	{time.Minute, "m"},
+	{time.Second, "s"},
+}
+
+// Duration formats d in weeks, days, hours, minutes, and seconds, such as
+// "2d1h" for 49h12m3s. Only precision units are given, starting from the
+// largest that d has any of, and the rest is truncated. Units with none
+// are left out and don't count toward the precision, so that 1h0m7s is
+// "1h7s" with a precision of 2. A precision of 0 or less gives every unit.
+// Durations under a second are "0s".
+func Duration(d time.Duration, precision int) string {
+	var b strings.Builder
+	if d < 0 {
+		b.WriteString("-")
+		d = -d
+	}
+	if precision <= 0 {
+		precision = len(units)
+	}
+	var started bool
+	for _, u := range units {
+		if precision == 0 {
+			break
+		}
+		n := d / u.d
+		d -= n * u.d
+		if n == 0 {
+			continue
+		}
+		started = true
+		precision--
+		b.WriteString(strconv.FormatInt(int64(n), 10) + u.suffix)
+	}
+	if !started {
+		return "0s"
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package humanize
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	tt := []struct {
+		desc      string
+		in        time.Duration
+		precision int
+		want      string
+	}{
+		{desc: "seconds", in: 7 * time.Second, precision: 2, want: "7s"},
+		{desc: "minutes and seconds", in: 67 * time.Second, precision: 2, want: "1m7s"},
+		{desc: "hours and minutes", in: 67 * time.Minute, precision: 2, want: "1h7m"},
+		{desc: "days and hours", in: 49*time.Hour + 12*time.Minute + 3*time.Second, precision: 2, want: "2d1h"},
+		{desc: "weeks and days", in: 9 * Day, precision: 2, want: "1w2d"},
+		{desc: "zero units left out", in: time.Hour + 7*time.Second, precision: 3, want: "1h7s"},
+		{desc: "zero units don't count toward precision", in: time.Hour + 7*time.Second, precision: 2, want: "1h7s"},
+		{desc: "every unit", in: 49*time.Hour + 12*time.Minute + 3*time.Second, want: "2d1h12m3s"},
+		{desc: "precision of one", in: 49 * time.Hour, precision: 1, want: "2d"},
+		{desc: "truncated", in: 119 * time.Second, precision: 1, want: "1m"},
+		{desc: "under a second", in: 999 * time.Millisecond, precision: 2, want: "0s"},
+		{desc: "zero", want: "0s"},
+		{desc: "negative", in: -67 * time.Second, precision: 2, want: "-1m7s"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := Duration(tc.in, tc.precision); got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
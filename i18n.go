@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// localesDir is the directory, in the config directory, holding message
+// catalogs, each named for its locale, such as es.yaml or pt_BR.yaml. A
+// catalog maps each message, in English, to its translation.
+const localesDir = "locales"
+
+// catalog holds the translations of messages into the user's language. It
+// is empty for English.
+var catalog map[string]string
+
+// messages lists every message that can be translated, so that
+// "ph config translate" can write a catalog for translators to fill in.
+var messages = []string{
+	// Tracks
+	" (started %s)",
+	"%s ago",
+	"just now",
+	"unknown command %q (see ph --help)",
+
+	// Alfred output
+	"Open the setlist",
+
+	// Plain output
+	"Artist",
+	"Title",
+	"Performance date",
+	"Location",
+	"Set",
+	"Station break",
+	"Program",
+	"New to me",
+	"Jam chart",
+	"Jam chart: '%s'",
+	"Started",
+	"Setlist",
+	"Buy",
+	"Recordings",
+	"Search",
+	"Full setlist, from %s:",
+	"Position in show",
+	"Lyrics from %s",
+	"Performed %d times, from %s to %s",
+	"Aired %d times, last %s",
+	"Not aired yet",
+
+	// ph recommend
+	"After %s:",
+	"No related shows found.",
+	"Same tour",
+	"a day later",
+	"a day earlier",
+	"%d days later",
+	"%d days earlier",
+	"rated %.1f on Relisten",
+	"No lyrics found for %s.",
+	"Link",
+	"Nothing to show.",
+	"yes",
+	"no",
+
+	// Links
+	"the track has no artist",
+	"the track is not from a live show, so it has no performance date",
+	"only Phish shows are listed",
+	"%s is not streamable on Relisten",
+	"Phish does not permit its shows on archive.org",
+	"%s shows are not sold on LivePhish",
+	"%s shows are not sold on nugs.net",
+	"the track has no title",
+	"station breaks are not on Spotify",
+	"the track is a full set, not a single song",
+	"the plugin returned no links",
+	"skipped: %s",
+
+	// Station info
+	"Name",
+	"Station ID",
+	"Genre",
+	"Status",
+	"Listeners",
+	"Playing",
+	"Stream",
+	"Formats",
+	"Logo",
+	"Showing the station as of %s: %v",
+
+	// ph compare
+	"Since %s:",
+	"%s: %d songs, %d shared; %d shows",
+	"The stations aired nothing in common.",
+	"Songs in common:",
+	"Shows in common:",
+
+	// ph at
+	"invalid time %q: use a date and time, such as \"2024-06-01 21:00\", or a time of day, such as 21:00 or 9pm",
+	"no time given",
+	"that time has not happened yet",
+	"no record of what was airing at %s",
+	"At %s: %s",
+	"Started %s, %s in",
+
+	// ph now
+	"Now playing:",
+	"Nothing is playing.",
+	"Off the air",
+	"Before that:",
+
+	// ph missed
+	"no record of where playback stopped; use ph play --sleep to record it",
+	"Since playback stopped at %s:",
+
+	// ph queue
+	"no show number %d in the queue",
+	"the current track is not from a live show; use --artist and --date to choose one",
+	"Marked %s as listened.",
+	"%s is already in the queue.",
+	"Queued %s.",
+	"The queue is empty.",
+
+	// ph alert
+	"no alert number %d",
+	"Removed the alert for %s.",
+	"use --date, --artist, or --song to choose what to be alerted about",
+	"There is already an alert for %s.",
+	"Added an alert for %s.",
+	"There are no alerts.",
+	"last aired %s",
+
+	// ph dnd
+	"Notifications are snoozed until %s.",
+	"Notifications are not snoozed.",
+	"Do not disturb: %s",
+	"Notifications are no longer snoozed.",
+
+	// ph db
+	"Every play is up to date.",
+	"was",
+	"%d plays would be updated.",
+	"Updated %d plays.",
+	"The local data is up to date.",
+	"%d migrations would be applied.",
+	"Applied %d migrations.",
+
+	// ph scrobble
+	"No listens are waiting to be scrobbled.",
+	"%d attempts: %s",
+	"no scrobblers are configured; set scrobble.lastfm, scrobble.listenbrainz, or scrobble.file",
+	"Scrobbled %d listens.",
+
+	// ph debug
+	"No differences.",
+	"too many statuses given",
+	"Saved the live status to compare the next one with.",
+	"a timeline file is required",
+
+	// ph stats
+	"No plays recorded yet. Plays are recorded each time ph checks the station.",
+	"%d plays",
+	"Plays by hour of day",
+	"Plays by day of week",
+	"Top artists",
+	"Plays by program",
+	"Top venues",
+	"Age of music aired (%d plays with a performance date)",
+	"Full show broadcasts: %d, of %d sets, %d with the encore",
+	"Station breaks: %d, averaging %s each and %s a day",
+	"%s  %2d breaks, avg %s",
+	"Minutes of breaks by day",
+}
+
+// tr returns the translation of a message into the user's language, or the
+// message itself if it has no translation. Formatting verbs are kept in
+// translations, so the result can be used as a format string.
+func tr(message string) string {
+	if t := catalog[message]; t != "" {
+		return t
+	}
+	return message
+}
+
+// localeCandidates returns the catalog names to look for, most specific
+// first, for a locale such as "pt_BR.UTF-8". English, and the C and POSIX
+// locales, need no catalog.
+func localeCandidates(locale string) []string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	locale = strings.Replace(locale, "-", "_", 1)
+	lang := locale
+	if i := strings.IndexByte(locale, '_'); i >= 0 {
+		lang = locale[:i]
+	}
+	switch strings.ToLower(lang) {
+	case "", "c", "posix", "en":
+		return nil
+	}
+	if lang == locale {
+		return []string{lang}
+	}
+	return []string{locale, lang}
+}
+
+// userLocale returns the locale configured, or, if none is, the one set in
+// the environment as for gettext.
+func userLocale(cfg config) string {
+	if cfg.Locale != "" {
+		return cfg.Locale
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// loadCatalog reads the catalog for a locale from dir. It returns nil if
+// the locale needs no catalog, and reports whether one was found.
+func loadCatalog(dir, locale string) (map[string]string, bool, error) {
+	candidates := localeCandidates(locale)
+	if len(candidates) == 0 {
+		return nil, true, nil
+	}
+	for _, name := range candidates {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name+".yaml"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		var c map[string]string
+		if err := yaml.Unmarshal(b, &c); err != nil {
+			return nil, false, fmt.Errorf("parse message catalog %s: %w", name, err)
+		}
+		return c, true, nil
+	}
+	return nil, false, nil
+}
+
+// setLocale loads the catalog for the user's locale. Only a locale set in
+// the configuration is an error to have no catalog for, since most
+// environments set a locale whether or not ph has been translated into it.
+func setLocale(cfg config) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	c, ok, err := loadCatalog(filepath.Join(dir, localesDir), userLocale(cfg))
+	if err != nil {
+		return err
+	}
+	if !ok && cfg.Locale != "" {
+		return fmt.Errorf("no message catalog for locale %q; create one with ph config translate %s", cfg.Locale, cfg.Locale)
+	}
+	catalog = c
+	return nil
+}
+
+// writeCatalogTemplate writes the catalog for a locale to dir with every
+// message in it, keeping the translations it already has, so translators
+// can fill in the rest. It returns the path of the catalog.
+func writeCatalogTemplate(dir, locale string) (string, error) {
+	path := filepath.Join(dir, locale+".yaml")
+	existing := make(map[string]string)
+	if b, err := ioutil.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(b, &existing); err != nil {
+			return "", fmt.Errorf("parse message catalog %s: %w", locale, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Translations of ph's messages for %s. Leave a translation empty to\n", locale)
+	b.WriteString("# use the English message. Keep formatting verbs such as %s and %d, in\n")
+	b.WriteString("# the same order.\n")
+	sorted := append([]string(nil), messages...)
+	sort.Strings(sorted)
+	for _, m := range sorted {
+		entry, err := yaml.Marshal(map[string]string{m: existing[m]})
+		if err != nil {
+			return "", err
+		}
+		b.Write(entry)
+	}
+	if err := os.MkdirAll(dir, os.FileMode(0777)); err != nil {
+		return "", err
+	}
+	return path, writeFileAtomic(path, []byte(b.String()))
+}
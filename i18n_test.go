@@ -0,0 +1,154 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestLocaleCandidates(t *testing.T) {
+	tt := []struct {
+		locale string
+		want   []string
+	}{
+		{locale: "pt_BR.UTF-8", want: []string{"pt_BR", "pt"}},
+		{locale: "pt-BR", want: []string{"pt_BR", "pt"}},
+		{locale: "de_DE@euro", want: []string{"de_DE", "de"}},
+		{locale: "es", want: []string{"es"}},
+		{locale: "en_US.UTF-8"},
+		{locale: "C.UTF-8"},
+		{locale: "POSIX"},
+		{locale: ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.locale, func(t *testing.T) {
+			if got := localeCandidates(tc.locale); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-locales")
+	if err != nil {
+		t.Fatalf("unable to create dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "pt.yaml"), []byte(`"just now": "agora mesmo"`), 0644); err != nil {
+		t.Fatalf("unable to write catalog: %v", err)
+	}
+
+	c, ok, err := loadCatalog(dir, "pt_BR.UTF-8")
+	if err != nil || !ok {
+		t.Fatalf("wanted the catalog to be found, but got %t (%v)", ok, err)
+	}
+	if want, got := "agora mesmo", c["just now"]; got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+	if c, ok, err := loadCatalog(dir, "en_US.UTF-8"); err != nil || !ok || c != nil {
+		t.Errorf("wanted no catalog needed for English, but got %v, %t (%v)", c, ok, err)
+	}
+	if _, ok, err := loadCatalog(dir, "fr_FR"); err != nil || ok {
+		t.Errorf("wanted no catalog found for French, but got %t (%v)", ok, err)
+	}
+}
+
+func TestTr(t *testing.T) {
+	defer func(c map[string]string) { catalog = c }(catalog)
+	catalog = map[string]string{"%s ago": "hace %s", "just now": ""}
+	if want, got := "hace 5m", StartedString(5*time.Minute); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+	if want, got := "just now", StartedString(0); got != want {
+		t.Errorf("wanted an empty translation to fall back to %q, but got %q", want, got)
+	}
+}
+
+func TestWriteCatalogTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-locales")
+	if err != nil {
+		t.Fatalf("unable to create dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "es.yaml"), []byte(`"just now": "ahora mismo"`), 0644); err != nil {
+		t.Fatalf("unable to write catalog: %v", err)
+	}
+	path, err := writeCatalogTemplate(dir, "es")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read catalog: %v", err)
+	}
+	var c map[string]string
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		t.Fatalf("unable to parse catalog: %v", err)
+	}
+	if len(c) != len(messages) {
+		t.Errorf("wanted %d messages, but got %d", len(messages), len(c))
+	}
+	if want, got := "ahora mismo", c["just now"]; got != want {
+		t.Errorf("wanted the existing translation %q to be kept, but got %q", want, got)
+	}
+}
+
+// TestMessagesListed checks that the messages list has every message
+// passed to tr, and no others, so catalogs written for translators are
+// complete.
+func TestMessagesListed(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("unable to parse package: %v", err)
+	}
+	used := make(map[string]bool)
+	for _, f := range pkgs["main"].Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 1 {
+				return true
+			}
+			if fn, ok := call.Fun.(*ast.Ident); !ok || fn.Name != "tr" {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok {
+				t.Errorf("%s: wanted tr to be passed a string literal", fset.Position(call.Pos()))
+				return true
+			}
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Errorf("%s: %v", fset.Position(lit.Pos()), err)
+				return true
+			}
+			used[s] = true
+			return true
+		})
+	}
+	listed := make(map[string]bool, len(messages))
+	for _, m := range messages {
+		listed[m] = true
+		if !used[m] {
+			t.Errorf("message %q is listed but not used", m)
+		}
+	}
+	for m := range used {
+		if !listed[m] {
+			t.Errorf("message %q is used but not listed", m)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// runICS writes an iCalendar file of notable plays from the local play
+// history: full show broadcasts and plays by favorite artists.
+func runICS(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("ics", flag.ExitOnError)
+		output = fs.StringP("output", "o", "-", "File to write the calendar to, or - for standard output")
+		since  = fs.Duration("since", 0, "Only include plays within this long ago (e.g., 168h); 0 includes all")
+	)
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	plays, err := st.plays()
+	if err != nil {
+		return fmt.Errorf("read play history: %w", err)
+	}
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	var cutoff time.Time
+	if *since > 0 {
+		cutoff = time.Now().Add(-*since)
+	}
+	notable := All(
+		ByDateRange(cutoff, time.Time{}),
+		Any(ByKind(trackKindFullShow), ByArtist(cfg.Favorites.Artists...)),
+	)
+
+	w := io.Writer(os.Stdout)
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return writeICS(w, plays, notable, time.Now(), cfg)
+}
+
+// writeICS writes an iCalendar document with an event for each play for
+// which include returns true. Plays must be in chronological order, since
+// each play is assumed to end when the next begins. cfg gives the links in
+// each event.
+func writeICS(w io.Writer, plays TrackList, include func(Track) bool, now time.Time, cfg config) error {
+	var b strings.Builder
+	icsLine(&b, "BEGIN:VCALENDAR")
+	icsLine(&b, "VERSION:2.0")
+	icsLine(&b, "PRODID:-//ianfoo//ph//EN")
+	icsLine(&b, "CALSCALE:GREGORIAN")
+	icsLine(&b, "X-WR-CALNAME:JEMP Radio plays")
+	for i, t := range plays {
+		if !include(t) {
+			continue
+		}
+		summary := t.Title
+		if t.Artist != "" {
+			summary = t.Artist + " - " + summary
+		}
+		var (
+			end  = t.StartTime.Add(playLength(plays, i))
+			desc []string
+		)
+		if pt := t.PerformanceTime; !pt.IsZero() {
+			desc = append(desc, "Performed "+pt.Format("Mon 2-Jan-2006"))
+		}
+		links := t.Links(cfg, relistenArtists)
+		desc = append(desc, linkLines(links)...)
+		url := linkURL(links, linkKindStream)
+
+		icsLine(&b, "BEGIN:VEVENT")
+		icsLine(&b, "UID:"+icsUID(t))
+		icsLine(&b, "DTSTAMP:"+now.UTC().Format(icsTimeFormat))
+		icsLine(&b, "DTSTART:"+t.StartTime.UTC().Format(icsTimeFormat))
+		icsLine(&b, "DTEND:"+end.UTC().Format(icsTimeFormat))
+		icsLine(&b, "SUMMARY:"+icsEscape(summary))
+		if len(desc) > 0 {
+			icsLine(&b, "DESCRIPTION:"+icsEscape(strings.Join(desc, "\n")))
+		}
+		if url != "" {
+			icsLine(&b, "URL:"+url)
+		}
+		icsLine(&b, "END:VEVENT")
+	}
+	icsLine(&b, "END:VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// icsUID derives a stable identifier for a play, so that calendar clients
+// update rather than duplicate events when a calendar is imported again.
+func icsUID(t Track) string {
+	sum := sha1.Sum([]byte(t.StartTime.UTC().Format(time.RFC3339) + "|" + t.Artist + "|" + t.Title))
+	return hex.EncodeToString(sum[:10]) + "@ph"
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// icsEscape escapes text for use in an iCalendar property value.
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// icsLine writes a content line, folding it so that no line is longer than
+// 75 octets, as required by RFC 5545. Lines are only folded between UTF-8
+// sequences.
+func icsLine(b *strings.Builder, line string) {
+	// Continuation lines begin with a space, which counts toward the limit.
+	maxLen := 75
+	for len(line) > maxLen {
+		cut := maxLen
+		for cut > 0 && !isUTF8Start(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		maxLen = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isUTF8Start(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteICS(t *testing.T) {
+	plays := TrackList{
+		{
+			Artist:          "Phish",
+			Title:           "17-Nov-1997 Denver, CO Set 2",
+			StartTime:       mustParseDate("2020-06-05T20:00:00"),
+			PerformanceTime: mustParseDate("1997-11-17"),
+			Location:        "Denver, CO",
+			Set:             &ShowSet{Number: 2},
+		},
+		{
+			Artist:    "Cream",
+			Title:     "Crossroads",
+			StartTime: mustParseDate("2020-06-05T21:10:00"),
+		},
+	}
+	var b strings.Builder
+	if err := writeICS(&b, plays, Track.IsFullShow, mustParseDate("2020-06-06"), config{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART:20200605T200000Z\r\n",
+		// The set ends when the next play begins.
+		"DTEND:20200605T211000Z\r\n",
+		`SUMMARY:Phish - 17-Nov-1997 Denver\, CO Set 2` + "\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("calendar does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Crossroads") {
+		t.Errorf("calendar contains excluded play:\n%s", got)
+	}
+}
+
+func TestICSLine(t *testing.T) {
+	var b strings.Builder
+	icsLine(&b, "DESCRIPTION:"+strings.Repeat("é", 100))
+	for i, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line %d is %d octets long", i, len(line))
+		}
+		if i > 0 && !strings.HasPrefix(line, " ") {
+			t.Errorf("continuation line %d does not begin with a space", i)
+		}
+	}
+}
+
+func TestStore_RecordPlay(t *testing.T) {
+	st := newTestStore(t)
+	track := Track{Artist: "Phish", Title: "Mercury", StartTime: time.Now().Truncate(time.Second)}
+	for i, want := range []bool{true, false} {
+		recorded, err := st.recordPlay(track)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if recorded != want {
+			t.Errorf("attempt %d: wanted recorded=%t, but got %t", i+1, want, recorded)
+		}
+	}
+	if recorded, _ := st.recordPlay(Track{Title: "No start time"}); recorded {
+		t.Errorf("wanted track without a start time not to be recorded")
+	}
+	plays, err := st.plays()
+	if err != nil {
+		t.Fatalf("unexpected error reading plays: %v", err)
+	}
+	if len(plays) != 1 || plays[0].Artist != "Phish" || !plays[0].StartTime.Equal(track.StartTime) {
+		t.Errorf("unexpected plays: %v", plays)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+)
+
+// id3Frame is a text frame of an ID3v2 tag, such as TIT2 for the title.
+// User-defined text frames, TXXX, also have a description, which names
+// the field.
+type id3Frame struct {
+	ID   string
+	Desc string
+	Text string
+}
+
+// trackID3Frames returns the ID3v2 frames describing t: its title, artist,
+// and album, which, as for now-playing helpers, is the show's date and
+// location, the performance date, and, since ID3 has no frames for them,
+// the location and set as user-defined frames.
+func trackID3Frames(t Track) []id3Frame {
+	info := newNowPlayingInfo(t, false)
+	frames := []id3Frame{
+		{ID: "TIT2", Text: info.Title},
+		{ID: "TPE1", Text: info.Artist},
+		{ID: "TALB", Text: info.Album},
+	}
+	if pt := t.PerformanceTime; !pt.IsZero() {
+		frames = append(frames, id3Frame{ID: "TDRC", Text: pt.Format("2006-01-02")})
+	}
+	return append(frames,
+		id3Frame{ID: "TXXX", Desc: "LOCATION", Text: t.Location},
+		id3Frame{ID: "TXXX", Desc: "SET", Text: t.Set.String()},
+	)
+}
+
+// id3v2Tag encodes frames as an ID3v2.4 tag, to be written at the start of
+// an MP3 or AAC file. Frames with no text are left out.
+func id3v2Tag(frames []id3Frame) []byte {
+	var body bytes.Buffer
+	for _, f := range frames {
+		if f.Text == "" {
+			continue
+		}
+		// Text is encoded as UTF-8, which ID3v2.4 marks with encoding 3.
+		text := f.Text
+		if f.ID == "TXXX" {
+			text = f.Desc + "\x00" + text
+		}
+		size := syncsafe(len(text) + 1)
+		body.WriteString(f.ID)
+		body.Write(size[:])
+		body.Write([]byte{0, 0, 3})
+		body.WriteString(text)
+	}
+	var tag bytes.Buffer
+	size := syncsafe(body.Len())
+	tag.WriteString("ID3")
+	tag.Write([]byte{4, 0, 0})
+	tag.Write(size[:])
+	tag.Write(body.Bytes())
+	return tag.Bytes()
+}
+
+// stripID3v2 returns b without the ID3v2 tag at its start, if it has one.
+func stripID3v2(b []byte) []byte {
+	if len(b) < 10 || string(b[:3]) != "ID3" {
+		return b
+	}
+	size := int(b[6])<<21 | int(b[7])<<14 | int(b[8])<<7 | int(b[9])
+	size += 10
+	// A footer, flagged in the header, repeats the header at the end.
+	if b[5]&0x10 != 0 {
+		size += 10
+	}
+	if size > len(b) {
+		return b
+	}
+	return b[size:]
+}
+
+// syncsafe encodes n as an ID3v2 synchsafe integer, with seven bits in each
+// byte, so that no byte of it can be mistaken for the start of an audio
+// frame.
+func syncsafe(n int) [4]byte {
+	return [4]byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
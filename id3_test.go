@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestID3v2Tag(t *testing.T) {
+	got := id3v2Tag([]id3Frame{{ID: "TIT2", Text: "Reba"}, {ID: "TALB"}, {ID: "TPE1", Text: "Phish"}})
+	want := []byte("ID3\x04\x00\x00\x00\x00\x00\x1f" +
+		"TIT2\x00\x00\x00\x05\x00\x00\x03Reba" +
+		"TPE1\x00\x00\x00\x06\x00\x00\x03Phish")
+	if !bytes.Equal(got, want) {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
+
+func TestSyncsafe(t *testing.T) {
+	tt := []struct {
+		n    int
+		want [4]byte
+	}{
+		{n: 0, want: [4]byte{0, 0, 0, 0}},
+		{n: 127, want: [4]byte{0, 0, 0, 127}},
+		{n: 128, want: [4]byte{0, 0, 1, 0}},
+		{n: 1<<21 + 3, want: [4]byte{1, 0, 0, 3}},
+	}
+	for _, tc := range tt {
+		if got := syncsafe(tc.n); got != tc.want {
+			t.Errorf("%d: wanted %v, but got %v", tc.n, tc.want, got)
+		}
+	}
+}
+
+func TestID3v2TagUserDefined(t *testing.T) {
+	got := id3v2Tag([]id3Frame{{ID: "TXXX", Desc: "SET", Text: "Set 2"}})
+	want := []byte("ID3\x04\x00\x00\x00\x00\x00\x14" +
+		"TXXX\x00\x00\x00\x0a\x00\x00\x03SET\x00Set 2")
+	if !bytes.Equal(got, want) {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
+
+func TestStripID3v2(t *testing.T) {
+	tag := id3v2Tag([]id3Frame{{ID: "TIT2", Text: "Reba"}})
+	tt := []struct {
+		desc string
+		in   []byte
+		want []byte
+	}{
+		{desc: "tagged", in: append(tag, "\xff\xfbaudio"...), want: []byte("\xff\xfbaudio")},
+		{desc: "untagged", in: []byte("\xff\xfbaudio"), want: []byte("\xff\xfbaudio")},
+		{desc: "truncated tag", in: tag[:12], want: tag[:12]},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := stripID3v2(tc.in); !bytes.Equal(got, tc.want) {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
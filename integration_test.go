@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The integration tests run the ph binary, built once for all of them,
+// against fixtures standing in for radio.co, Relisten, and phish.net, which
+// it is pointed at with the endpoints settings. They are skipped with
+// -short, since building ph takes a while.
+
+var (
+	phBuild    sync.Once
+	phBinary   string
+	phBuildErr error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if phBinary != "" {
+		os.RemoveAll(filepath.Dir(phBinary))
+	}
+	os.Exit(code)
+}
+
+// buildPH builds the ph binary, the first time it is called, and returns
+// its path.
+func buildPH(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	phBuild.Do(func() {
+		dir, err := ioutil.TempDir("", "ph-bin")
+		if err != nil {
+			phBuildErr = err
+			return
+		}
+		phBinary = filepath.Join(dir, "ph")
+		out, err := exec.Command("go", "build", "-o", phBinary, ".").CombinedOutput()
+		if err != nil {
+			phBuildErr = &buildError{err: err, output: out}
+		}
+	})
+	if phBuildErr != nil {
+		t.Fatalf("unable to build ph: %v", phBuildErr)
+	}
+	return phBinary
+}
+
+type buildError struct {
+	err    error
+	output []byte
+}
+
+func (e *buildError) Error() string {
+	return e.err.Error() + "\n" + string(e.output)
+}
+
+// integrationEnv runs ph with its own config, data, and cache directories,
+// against a FakeStation on a fake clock, a Relisten that has only Phish,
+// and a phish.net whose catalog is integrationPhishNetSongs.
+type integrationEnv struct {
+	t     *testing.T
+	ph    string
+	clock *fakeClock
+	env   []string
+}
+
+var integrationPhishNetSongs = []string{"Wolfman's Brother", "Piper", "Reba", "Bathtub Gin"}
+
+// newIntegrationEnv returns an environment whose station is ago into
+// replaying timeline. Start times on the timeline agree with ph's clock
+// until the station's clock is advanced.
+func newIntegrationEnv(t *testing.T, timeline []TimelineEntry, ago time.Duration) *integrationEnv {
+	t.Helper()
+	e := &integrationEnv{t: t, ph: buildPH(t), clock: &fakeClock{now: time.Now().Add(-ago)}}
+
+	radioCo := httptest.NewServer(NewFakeStation(timeline, 1, e.clock.Now))
+	t.Cleanup(radioCo.Close)
+	e.clock.Advance(ago)
+	relisten := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/artists" {
+			w.Write([]byte(`[{"id":1,"name":"Phish","slug":"phish"}]`))
+			return
+		}
+		w.Write([]byte(`null`))
+	}))
+	t.Cleanup(relisten.Close)
+	phishNet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Data []map[string]string `json:"data"`
+		}
+		for _, s := range integrationPhishNetSongs {
+			body.Data = append(body.Data, map[string]string{"song": s})
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(phishNet.Close)
+
+	home, err := ioutil.TempDir("", "ph-home")
+	if err != nil {
+		t.Fatalf("unable to create home dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(home) })
+	e.env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + home,
+		"XDG_CONFIG_HOME=" + filepath.Join(home, "config"),
+		"XDG_DATA_HOME=" + filepath.Join(home, "data"),
+		"XDG_CACHE_HOME=" + filepath.Join(home, "cache"),
+		"LANG=C",
+		"NO_COLOR=1",
+		configEnvVar("endpoints.radio_co") + "=" + radioCo.URL,
+		configEnvVar("endpoints.relisten") + "=" + relisten.URL,
+		configEnvVar("endpoints.phish_net") + "=" + phishNet.URL,
+	}
+	return e
+}
+
+// command returns a command that runs ph with args.
+func (e *integrationEnv) command(args ...string) *exec.Cmd {
+	cmd := exec.Command(e.ph, args...)
+	cmd.Env = e.env
+	return cmd
+}
+
+// run runs ph with args to completion, failing the test if it fails, and
+// returns what it wrote to standard output.
+func (e *integrationEnv) run(args ...string) string {
+	e.t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := e.command(args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		e.t.Fatalf("ph %s: %v\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestIntegration_Formats(t *testing.T) {
+	// Reba is airing half an hour into the demo timeline.
+	e := newIntegrationEnv(t, demoTimeline(), 30*time.Minute)
+
+	tt := []struct {
+		desc string
+		args []string
+		want []string
+	}{
+		{
+			desc: "text",
+			args: nil,
+			want: []string{"Phish - Reba (Mon 17-Nov-1997)", "https://relisten.net/phish/1997/11/17"},
+		},
+		{
+			desc: "plain",
+			args: []string{"--plain"},
+			want: []string{"Artist: Phish", "Title: Reba"},
+		},
+		{
+			desc: "json",
+			args: []string{"--format", "json"},
+			want: []string{`"artist":"Phish"`, `"title":"Reba"`},
+		},
+		{
+			desc: "yaml",
+			args: []string{"--format", "yaml"},
+			want: []string{"artist: Phish", "title: Reba"},
+		},
+		{
+			desc: "history",
+			args: []string{"--last", "3"},
+			want: []string{"Reba", "Piper", "Wolfman's Brother"},
+		},
+		{
+			desc: "now",
+			args: []string{"now"},
+			want: []string{"Now playing:", "Phish - Reba", "Before that:", "Piper"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			out := e.run(tc.args...)
+			for _, want := range tc.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("wanted output containing %q, but got:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestIntegration_Autocorrect(t *testing.T) {
+	timeline := []TimelineEntry{{
+		Time:   time.Now(),
+		Status: json.RawMessage(`{"status":"online","current_track":{"title":"Phish - Wolfmans Brother (11-17-97)"},"history":[]}`),
+	}}
+	e := newIntegrationEnv(t, timeline, 0)
+	e.env = append(e.env,
+		configEnvVar("catalog.autocorrect")+"=true",
+		configEnvVar("catalog.phishnet_api_key")+"=key",
+	)
+	out := e.run("--format", "json")
+	if !strings.Contains(out, `"title":"Wolfman's Brother"`) {
+		t.Errorf("wanted the title corrected from the phish.net catalog, but got:\n%s", out)
+	}
+}
+
+func TestIntegration_Watch(t *testing.T) {
+	e := newIntegrationEnv(t, demoTimeline(), 0)
+	var stdout syncBuffer
+	cmd := e.command("set", "--watch", "--interval", "50ms")
+	cmd.Stdout = &stdout
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("unable to start ph: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for !strings.Contains(stdout.String(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %q in output:\n%s", want, stdout.String())
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	waitFor("Wolfman's Brother")
+	// The set goes on to Piper once Wolfman's Brother has played.
+	e.clock.Advance(18 * time.Minute)
+	waitFor("Piper")
+
+	cmd.Process.Signal(os.Interrupt)
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("wanted ph to exit cleanly when interrupted, but got %v", err)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written by a command while the
+// test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIRCNick = "phbot"
+
+	// ircMaxMessage is the most text sent in a single PRIVMSG, leaving room
+	// for the command and the prefix the server adds within IRC's 512-byte
+	// line limit.
+	ircMaxMessage = 400
+)
+
+// ircConfig holds the settings for joining an IRC channel.
+type ircConfig struct {
+	Server   string `yaml:"server,omitempty" doc:"IRC server as host:port, e.g. irc.libera.chat:6697"`
+	TLS      bool   `yaml:"tls,omitempty" doc:"Connect to the server with TLS"`
+	Password string `yaml:"password,omitempty" doc:"Server password, if the server requires one"`
+	Nick     string `yaml:"nick,omitempty" doc:"Nickname of the bot (default phbot)"`
+	Channel  string `yaml:"channel,omitempty" doc:"Channel to join, e.g. #jemp"`
+}
+
+// ircMessage is a single line of the IRC protocol, as in
+// ":nick!user@host PRIVMSG #channel :hello".
+type ircMessage struct {
+	Prefix  string
+	Command string
+	Params  []string
+}
+
+// parseIRCMessage parses a line received from an IRC server.
+func parseIRCMessage(line string) ircMessage {
+	var m ircMessage
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, ":") {
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return ircMessage{Prefix: line[1:]}
+		}
+		m.Prefix, line = line[1:i], strings.TrimLeft(line[i+1:], " ")
+	}
+	for line != "" {
+		if strings.HasPrefix(line, ":") && m.Command != "" {
+			m.Params = append(m.Params, line[1:])
+			break
+		}
+		var field string
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			field, line = line[:i], strings.TrimLeft(line[i+1:], " ")
+		} else {
+			field, line = line, ""
+		}
+		if m.Command == "" {
+			m.Command = strings.ToUpper(field)
+		} else {
+			m.Params = append(m.Params, field)
+		}
+	}
+	return m
+}
+
+// ircClient is a minimal IRC client that joins a single channel.
+type ircClient struct {
+	cfg  ircConfig
+	dial func() (net.Conn, error)
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newIRCClient(cfg ircConfig) (*ircClient, error) {
+	if cfg.Server == "" || cfg.Channel == "" {
+		return nil, errors.New("bot.irc.server and bot.irc.channel must be set")
+	}
+	if cfg.Nick == "" {
+		cfg.Nick = defaultIRCNick
+	}
+	c := &ircClient{cfg: cfg}
+	c.dial = func() (net.Conn, error) {
+		dialer := &net.Dialer{Timeout: 30 * time.Second}
+		if cfg.TLS {
+			host, _, _ := net.SplitHostPort(cfg.Server)
+			return tls.DialWithDialer(dialer, "tcp", cfg.Server, &tls.Config{ServerName: host})
+		}
+		return dialer.Dial("tcp", cfg.Server)
+	}
+	return c, nil
+}
+
+// writeLine sends a line of the IRC protocol.
+func (c *ircClient) writeLine(format string, args ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return errors.New("not connected to IRC")
+	}
+	_, err := fmt.Fprintf(c.conn, format+"\r\n", args...)
+	return err
+}
+
+func (c *ircClient) serve(ctx context.Context, onMessage func(text string, reply func(string) error)) error {
+	conn, err := c.dial()
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", c.cfg.Server, err)
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+	}()
+	go func() {
+		<-ctx.Done()
+		c.writeLine("QUIT :ph bot shutting down")
+		conn.Close()
+	}()
+
+	nick := c.cfg.Nick
+	if c.cfg.Password != "" {
+		c.writeLine("PASS %s", c.cfg.Password)
+	}
+	c.writeLine("NICK %s", nick)
+	c.writeLine("USER %s 0 * :ph bot", nick)
+
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		m := parseIRCMessage(sc.Text())
+		switch m.Command {
+		case "PING":
+			c.writeLine("PONG :%s", strings.Join(m.Params, " "))
+		case "001":
+			c.writeLine("JOIN %s", c.cfg.Channel)
+		case "433":
+			// The nickname is in use, so try another.
+			nick += "_"
+			c.writeLine("NICK %s", nick)
+		case "PRIVMSG":
+			if len(m.Params) == 2 && strings.EqualFold(m.Params[0], c.cfg.Channel) {
+				onMessage(m.Params[1], c.send)
+			}
+		case "ERROR":
+			return fmt.Errorf("IRC server: %s", strings.Join(m.Params, " "))
+		}
+	}
+	if err := sc.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	if ctx.Err() == nil {
+		return errors.New("IRC server closed the connection")
+	}
+	return nil
+}
+
+// send posts text to the channel, a line at a time, since IRC messages
+// cannot span lines.
+func (c *ircClient) send(text string) error {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if len(line) > ircMaxMessage {
+			n := ircMaxMessage
+			for n > 0 && !isUTF8Start(line[n]) {
+				n--
+			}
+			line = line[:n]
+		}
+		if err := c.writeLine("PRIVMSG %s :%s", c.cfg.Channel, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIRCMessage(t *testing.T) {
+	tt := []struct {
+		line string
+		want ircMessage
+	}{
+		{
+			line: "PING :irc.example.com",
+			want: ircMessage{Command: "PING", Params: []string{"irc.example.com"}},
+		},
+		{
+			line: ":nick!user@host PRIVMSG #jemp :!np please\r\n",
+			want: ircMessage{Prefix: "nick!user@host", Command: "PRIVMSG", Params: []string{"#jemp", "!np please"}},
+		},
+		{
+			line: ":irc.example.com 001 phbot :Welcome",
+			want: ircMessage{Prefix: "irc.example.com", Command: "001", Params: []string{"phbot", "Welcome"}},
+		},
+		{
+			line: "join #jemp",
+			want: ircMessage{Command: "JOIN", Params: []string{"#jemp"}},
+		},
+	}
+	for _, tc := range tt {
+		if got := parseIRCMessage(tc.line); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: wanted %+v, but got %+v", tc.line, tc.want, got)
+		}
+	}
+}
+
+func TestIRCClient(t *testing.T) {
+	client, server := net.Pipe()
+	c, err := newIRCClient(ircConfig{Server: "irc.example.com:6667", Channel: "#jemp"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.dial = func() (net.Conn, error) { return client, nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		served <- c.serve(ctx, func(text string, reply func(string) error) {
+			if text == "!np" {
+				reply("Now playing: Phish - Reba\nhttps://relisten.net/phish/1997/11/22")
+			}
+		})
+	}()
+
+	var (
+		r      = bufio.NewReader(server)
+		expect = func(want string) {
+			t.Helper()
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("unable to read from client: %v", err)
+			}
+			if got := strings.TrimRight(line, "\r\n"); got != want {
+				t.Fatalf("wanted %q, but got %q", want, got)
+			}
+		}
+		say = func(line string) {
+			if _, err := server.Write([]byte(line + "\r\n")); err != nil {
+				t.Fatalf("unable to write to client: %v", err)
+			}
+		}
+	)
+	expect("NICK phbot")
+	expect("USER phbot 0 * :ph bot")
+	say(":irc.example.com 433 * phbot :Nickname is already in use")
+	expect("NICK phbot_")
+	say(":irc.example.com 001 phbot_ :Welcome")
+	expect("JOIN #jemp")
+	say("PING :irc.example.com")
+	expect("PONG :irc.example.com")
+	say(":fan!fan@host PRIVMSG #jemp :!np")
+	expect("PRIVMSG #jemp :Now playing: Phish - Reba")
+	expect("PRIVMSG #jemp :https://relisten.net/phish/1997/11/22")
+
+	cancel()
+	expect("QUIT :ph bot shutting down")
+	if err := <-served; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// jamChartsFile caches the jam charts of each show in the cache
+	// directory, by date, for jamChartsTTL.
+	jamChartsFile = "phishnet-jamcharts.json"
+	jamChartsTTL  = 7 * 24 * time.Hour
+
+	// jamChartMarker marks the tracks on the jam charts in text output.
+	jamChartMarker = "🔥"
+)
+
+// JamChart is phish.net's jam chart entry for a performance of a song,
+// which marks it as one of the notable versions of the song.
+type JamChart struct {
+	// Description is what the jam chart says about the version, if
+	// anything.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// String returns the note shown with a track on the jam charts, as in
+// "Jam chart: 'The Tweezerfest'", or "" if j is nil.
+func (j *JamChart) String() string {
+	switch {
+	case j == nil:
+		return ""
+	case j.Description == "":
+		return tr("Jam chart")
+	}
+	return fmt.Sprintf(tr("Jam chart: '%s'"), j.Description)
+}
+
+// plain returns the jam chart as the value of a field of plain output.
+func (j *JamChart) plain() string {
+	switch {
+	case j == nil:
+		return ""
+	case j.Description == "":
+		return tr("yes")
+	}
+	return j.Description
+}
+
+// showJamCharts are the jam charts of a show, by normalized song title,
+// with when they were fetched.
+type showJamCharts struct {
+	Fetched time.Time         `json:"fetched"`
+	Songs   map[string]string `json:"songs"`
+}
+
+// jamCharts finds the tracks on phish.net's jam charts. The jam charts of
+// each show are fetched with its setlist, and kept in the cache directory.
+type jamCharts struct {
+	client *http.Client
+	cfg    config
+	shows  map[string]showJamCharts
+	path   string
+	dirty  bool
+}
+
+func newJamCharts(client *http.Client, cfg config) *jamCharts {
+	jc := &jamCharts{client: client, cfg: cfg, shows: make(map[string]showJamCharts)}
+	if dir, err := cfg.cacheDir(); err == nil {
+		jc.path = filepath.Join(dir, jamChartsFile)
+		if b, err := ioutil.ReadFile(jc.path); err == nil {
+			json.Unmarshal(b, &jc.shows)
+		}
+	}
+	return jc
+}
+
+// hasJamCharts reports whether t is a song from a show whose setlists come
+// from phish.net, the only setlists with jam charts, as cfg gives its
+// artist's setlist provider.
+func hasJamCharts(t Track, cfg config) bool {
+	if t.Artist == "" || t.PerformanceTime.IsZero() || t.IsFullShow() || t.IsStationBreak() {
+		return false
+	}
+	return cfg.setlistProvider(t.Artist).Name() == "phish.net"
+}
+
+// show returns the jam charts of the show on date, from the cache while
+// they are fresh, and otherwise from phish.net. If they cannot be fetched,
+// cached jam charts are used however old they are.
+func (jc *jamCharts) show(ctx context.Context, date time.Time) (map[string]string, error) {
+	key := date.Format("2006-01-02")
+	cached, ok := jc.shows[key]
+	if ok && appClock.Now().Sub(cached.Fetched) < jamChartsTTL {
+		return cached.Songs, nil
+	}
+	entries, err := fetchPhishNetSetlist(ctx, jc.client, jc.cfg, date)
+	if err != nil {
+		if ok {
+			return cached.Songs, nil
+		}
+		return nil, err
+	}
+	songs := make(map[string]string)
+	for _, e := range entries {
+		if e.IsJamChart {
+			songs[normalizeSongTitle(e.Song)] = e.JamChartDescription
+		}
+	}
+	jc.shows[key] = showJamCharts{Fetched: appClock.Now(), Songs: songs}
+	jc.dirty = true
+	return songs, nil
+}
+
+// lookup returns the jam chart entry of t, or nil if it is not on the jam
+// charts. Of a title with several songs, as in "Mercury > Simple", the
+// first song on the jam charts is used.
+func (jc *jamCharts) lookup(ctx context.Context, t Track) (*JamChart, error) {
+	if !hasJamCharts(t, jc.cfg) {
+		return nil, nil
+	}
+	songs, err := jc.show(ctx, t.PerformanceTime)
+	if err != nil {
+		return nil, err
+	}
+	for _, song := range strings.FieldsFunc(t.Title, func(r rune) bool { return r == '>' || r == '→' }) {
+		if desc, ok := songs[normalizeSongTitle(song)]; ok {
+			return &JamChart{Description: desc}, nil
+		}
+	}
+	return nil, nil
+}
+
+// mark returns a copy of tl with the tracks on the jam charts marked.
+// Tracks whose jam charts cannot be fetched are left unmarked, and the
+// first error is reported as a warning.
+func (jc *jamCharts) mark(ctx context.Context, tl TrackList) TrackList {
+	var (
+		marked = make(TrackList, len(tl))
+		warned bool
+	)
+	for i, t := range tl {
+		marked[i] = t
+		jam, err := jc.lookup(ctx, t)
+		if err != nil && !warned {
+			warn(fmt.Errorf("unable to get jam charts: %w", err))
+			warned = true
+		}
+		marked[i].JamChart = jam
+	}
+	jc.save()
+	return marked
+}
+
+// save writes the jam charts fetched to the cache directory.
+func (jc *jamCharts) save() {
+	if !jc.dirty || jc.path == "" {
+		return
+	}
+	b, err := json.Marshal(jc.shows)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(jc.path), os.FileMode(0777)); err == nil {
+		writeFileAtomic(jc.path, b)
+	}
+	jc.dirty = false
+}
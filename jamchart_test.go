@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJamCharts_Mark(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v5/setlists/showdate/1997-11-22.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"error": false, "data": [
+			{"song": "Tweezer", "position": 1, "isjamchart": "1", "jamchart_description": "The Tweezerfest"},
+			{"song": "Reba", "position": 2, "isjamchart": 0, "jamchart_description": ""},
+			{"song": "Simple", "position": 3, "isjamchart": 1, "jamchart_description": ""}
+		]}`))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "ph-jamcharts")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	var cfg config
+	cfg.Endpoints.PhishNet = srv.URL
+	cfg.Catalog.PhishNetAPIKey = "key"
+	cfg.CacheDir = dir
+
+	var (
+		hampton = mustParseDate("1997-11-22")
+		tracks  = TrackList{
+			{Artist: "Phish", Title: "Tweezer", PerformanceTime: hampton},
+			{Artist: "Phish", Title: "Reba", PerformanceTime: hampton},
+			{Artist: "Phish", Title: "Mercury > Simple", PerformanceTime: hampton},
+			{Artist: "Goose", Title: "Tweezer", PerformanceTime: hampton},
+			{Artist: "Phish", Title: "Tweezer"},
+		}
+		want = []string{"Jam chart: 'The Tweezerfest'", "", "Jam chart", "", ""}
+	)
+	got := newJamCharts(srv.Client(), cfg).mark(context.Background(), tracks)
+	for i, track := range got {
+		if note := track.JamChart.String(); note != want[i] {
+			t.Errorf("%s (%s): wanted %q, but got %q", track.Title, track.Artist, want[i], note)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("wanted the show's setlist fetched once, but it was fetched %d times", requests)
+	}
+
+	// Later runs find the jam charts in the cache.
+	newJamCharts(srv.Client(), cfg).mark(context.Background(), tracks)
+	if requests != 1 {
+		t.Errorf("wanted cached jam charts used, but the setlist was fetched %d times", requests)
+	}
+}
+
+func TestTrack_String_JamChart(t *testing.T) {
+	track := Track{
+		Artist:          "Phish",
+		Title:           "Tweezer",
+		PerformanceTime: mustParseDate("1997-11-22"),
+		JamChart:        &JamChart{Description: "The Tweezerfest"},
+	}
+	lines := strings.Split(track.String(), "\n")
+	if want := jamChartMarker + " Phish - Tweezer (Sat 22-Nov-1997)"; lines[0] != want {
+		t.Errorf("wanted %q, but got %q", want, lines[0])
+	}
+	if want := "Jam chart: 'The Tweezerfest'"; len(lines) < 2 || lines[1] != want {
+		t.Errorf("wanted a line %q, but got %q", want, lines)
+	}
+}
+
+func TestTrackList_Table_JamChart(t *testing.T) {
+	tl := TrackList{
+		{Artist: "Phish", Title: "Tweezer", JamChart: &JamChart{Description: "The Tweezerfest"}},
+		{Artist: "Phish", Title: "Reba"},
+	}
+	got := tl.table(nil, tableLayout{numbering: numberNone, columns: []string{columnTitle}})
+	want := "   TITLE\n" +
+		jamChartMarker + " Tweezer (Jam chart: 'The Tweezerfest')\n" +
+		"   Reba"
+	if got != want {
+		t.Errorf("wanted\n%s\nbut got\n%s", want, got)
+	}
+}
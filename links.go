@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+
+	flag "github.com/spf13/pflag"
+)
+
+// Link is a link to a track on a service, or, when no link could be
+// made, the reason it was skipped. Kind says what the link is for, so
+// that consumers can pick the links they want, and Label names it for
+// people, as text output and buttons show it.
+type Link struct {
+	Kind    string `json:"kind"`
+	Label   string `json:"label"`
+	Service string `json:"service"`
+	URL     string `json:"url,omitempty"`
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// Kinds of links.
+const (
+	linkKindStream  = "stream"
+	linkKindSetlist = "setlist"
+	linkKindBuy     = "buy"
+	linkKindArchive = "archive"
+	linkKindSearch  = "search"
+	linkKindPlugin  = "plugin"
+)
+
+// linkLabel returns the label of links of a kind.
+func linkLabel(kind string) string {
+	switch kind {
+	case linkKindStream:
+		return tr("Stream")
+	case linkKindSetlist:
+		return tr("Setlist")
+	case linkKindBuy:
+		return tr("Buy")
+	case linkKindArchive:
+		return tr("Recordings")
+	case linkKindSearch:
+		return tr("Search")
+	}
+	return tr("Link")
+}
+
+// Links returns the links shown with t wherever it is described: to stream
+// its show on Relisten, and to the show's setlist. Only links that could be
+// made are returned. relistenArtists maps the artists streamable on Relisten
+// to their slugs, and cfg gives the artist's setlist provider.
+func (t Track) Links(cfg config, relistenArtists map[string]string) []Link {
+	var links []Link
+	if u := t.streamingURL(relistenArtists); u != "" {
+		links = append(links, Link{Kind: linkKindStream, Label: linkLabel(linkKindStream), Service: "Relisten", URL: u})
+	}
+	if u := t.setlistURL(cfg); u != "" {
+		links = append(links, Link{Kind: linkKindSetlist, Label: linkLabel(linkKindSetlist), Service: cfg.setlistProvider(t.Artist).Name(), URL: u})
+	}
+	return links
+}
+
+// linkURL returns the URL of the first of links of the given kind, or the
+// empty string if there is none.
+func linkURL(links []Link, kind string) string {
+	for _, l := range links {
+		if l.Kind == kind && l.URL != "" {
+			return l.URL
+		}
+	}
+	return ""
+}
+
+// String returns the link as text output shows it, as in "Setlist: URL".
+func (l Link) String() string {
+	if l.URL == "" {
+		return ""
+	}
+	return l.Label + ": " + l.URL
+}
+
+// linkLines returns each of links that has a URL, as String returns it.
+func linkLines(links []Link) []string {
+	var lines []string
+	for _, l := range links {
+		if s := l.String(); s != "" {
+			lines = append(lines, s)
+		}
+	}
+	return lines
+}
+
+// TrackID identifies a track by what its links depend on: its artist,
+// title, and show. Every airing of a track has the same ID.
+type TrackID string
+
+// ID returns the track's ID.
+func (t Track) ID() TrackID {
+	id := t.Artist + " - " + t.Title
+	if pt := t.PerformanceTime; !pt.IsZero() {
+		id += " (" + pt.Format("2006-01-02") + ")"
+	}
+	if t.Set != nil {
+		id += " [" + t.Set.String() + "]"
+	}
+	return TrackID(id)
+}
+
+// trackLinks returns a link to t on each service ph knows of, in the order
+// they are shown, explaining why any service has no link. relistenArtists
+// maps the artists streamable on Relisten to their slugs, and cfg gives the
+// sources of the artist's shows.
+func trackLinks(t Track, cfg config, relistenArtists map[string]string) []Link {
+	var (
+		noArtist = tr("the track has no artist")
+		noDate   = tr("the track is not from a live show, so it has no performance date")
+		notPhish = tr("only Phish shows are listed")
+	)
+	// liveShow explains why a service listing live shows cannot link to t,
+	// if it cannot.
+	liveShow := func() string {
+		switch {
+		case t.Artist == "":
+			return noArtist
+		case t.PerformanceTime.IsZero():
+			return noDate
+		}
+		return ""
+	}
+	var links []Link
+	add := func(kind, service, url, skipped string) {
+		if skipped != "" {
+			url = ""
+		}
+		links = append(links, Link{Kind: kind, Label: linkLabel(kind), Service: service, URL: url, Skipped: skipped})
+	}
+
+	skipped := liveShow()
+	if _, ok := relistenArtists[t.Artist]; skipped == "" && !ok {
+		skipped = fmt.Sprintf(tr("%s is not streamable on Relisten"), t.Artist)
+	}
+	add(linkKindStream, "Relisten", t.streamingURL(relistenArtists), skipped)
+
+	skipped = liveShow()
+	add(linkKindSetlist, cfg.setlistProvider(t.Artist).Name(), t.setlistURL(cfg), skipped)
+
+	if skipped == "" && t.Artist != "Phish" {
+		skipped = notPhish
+	}
+	add(linkKindStream, "phish.in", "https://phish.in/"+t.PerformanceTime.Format("2006-01-02"), skipped)
+
+	source, _ := cfg.lookupArtistSource(t.Artist)
+	search := t.Artist + " " + t.PerformanceTime.Format("2006-01-02")
+	skipped = liveShow()
+	if skipped == "" && !source.LivePhish {
+		skipped = fmt.Sprintf(tr("%s shows are not sold on LivePhish"), t.Artist)
+	}
+	add(linkKindBuy, "LivePhish", "https://www.livephish.com/search?q="+url.QueryEscape(search), skipped)
+
+	skipped = liveShow()
+	if skipped == "" && !source.Nugs {
+		skipped = fmt.Sprintf(tr("%s shows are not sold on nugs.net"), t.Artist)
+	}
+	add(linkKindBuy, "nugs.net", "https://www.nugs.net/search/?searchText="+url.QueryEscape(search), skipped)
+
+	skipped = liveShow()
+	if skipped == "" && t.Artist == "Phish" {
+		skipped = tr("Phish does not permit its shows on archive.org")
+	}
+	query := fmt.Sprintf("creator:%q AND date:%s", t.Artist, t.PerformanceTime.Format("2006-01-02"))
+	if c := source.ArchiveCollection; c != "" {
+		query = fmt.Sprintf("collection:%s AND date:%s", c, t.PerformanceTime.Format("2006-01-02"))
+	}
+	add(linkKindArchive, "archive.org", "https://archive.org/search?query="+url.QueryEscape(query), skipped)
+
+	switch {
+	case t.Artist == "":
+		skipped = noArtist
+	case t.Title == "":
+		skipped = tr("the track has no title")
+	case t.IsStationBreak():
+		skipped = tr("station breaks are not on Spotify")
+	case t.IsFullShow():
+		skipped = tr("the track is a full set, not a single song")
+	default:
+		skipped = ""
+	}
+	add(linkKindSearch, "Spotify", "https://open.spotify.com/search/"+url.PathEscape(t.Artist+" "+t.Title), skipped)
+	return links
+}
+
+// LinkResolver resolves the links to tracks, checking with Relisten that a
+// show is there before linking to it, rather than only that its artist is.
+// Each show is looked up once, however many of its tracks are resolved, and
+// the result is remembered, so a LinkResolver is best kept for as long as
+// ph runs. A LinkResolver is safe for concurrent use.
+type LinkResolver struct {
+	Relisten *relisten.Client
+
+	// cfg has the settings the links are made with, such as the Relisten
+	// slugs configured for artists.
+	cfg config
+
+	// Concurrency is how many shows are looked up at once (default 4).
+	Concurrency int
+
+	mu      sync.Mutex
+	artists map[string]string
+	shows   map[string]bool // whether each show, by showKey, is on Relisten
+}
+
+// ResolveLinks returns the links to each of tracks, by ID, leaving out
+// services that have none. Lookups that fail are reported in the returned
+// error, but do not prevent links from being returned; a Relisten link is
+// given for a show that could not be looked up, as it may well be there.
+func (r *LinkResolver) ResolveLinks(ctx context.Context, tracks []Track) (map[TrackID][]Link, error) {
+	var errs []string
+	artists, err := r.relistenArtists()
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("get Relisten artists: %v", err))
+	}
+
+	type show struct {
+		slug string
+		date time.Time
+	}
+	pending := make(map[string]show)
+	r.mu.Lock()
+	for _, t := range tracks {
+		slug, ok := artists[t.Artist]
+		if !ok || t.PerformanceTime.IsZero() {
+			continue
+		}
+		key := showKey(t)
+		if _, known := r.shows[key]; !known {
+			pending[key] = show{slug, t.PerformanceTime}
+		}
+	}
+	r.mu.Unlock()
+
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	var (
+		sem = make(chan struct{}, concurrency)
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+	)
+	for key, s := range pending {
+		key, s := key, s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			_, err := r.Relisten.Show(ctx, s.slug, s.date)
+			if err != nil && !errors.Is(err, relisten.ErrNotFound) {
+				mu.Lock()
+				errs = append(errs, err.Error())
+				mu.Unlock()
+				return
+			}
+			r.mu.Lock()
+			if r.shows == nil {
+				r.shows = make(map[string]bool)
+			}
+			r.shows[key] = err == nil
+			r.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	resolved := make(map[TrackID][]Link, len(tracks))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range tracks {
+		var links []Link
+		for _, l := range trackLinks(t, r.cfg, artists) {
+			if l.URL == "" {
+				continue
+			}
+			if l.Service == "Relisten" {
+				if found, known := r.shows[showKey(t)]; known && !found {
+					continue
+				}
+			}
+			links = append(links, l)
+		}
+		resolved[t.ID()] = links
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return resolved, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return resolved, nil
+}
+
+// relistenArtists returns the artists on Relisten, fetching them the
+// first time they are needed.
+func (r *LinkResolver) relistenArtists() (map[string]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.artists != nil {
+		return r.artists, nil
+	}
+	artists, err := relistenGetArtists(r.Relisten, r.cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.artists = artists
+	return artists, nil
+}
+
+// pluginTrackLinks returns the links to t from each of the named plugins,
+// explaining which plugins failed.
+func pluginTrackLinks(names []string, t Track) []Link {
+	var links []Link
+	for _, name := range names {
+		service := "plugin " + name
+		pl, err := pluginLinks([]string{name}, t)
+		if err != nil {
+			links = append(links, Link{Kind: linkKindPlugin, Label: linkLabel(linkKindPlugin), Service: service, Skipped: err.Error()})
+			continue
+		}
+		if len(pl) == 0 {
+			links = append(links, Link{Kind: linkKindPlugin, Label: linkLabel(linkKindPlugin), Service: service, Skipped: tr("the plugin returned no links")})
+		}
+		for _, l := range pl {
+			s, label := service, linkLabel(linkKindPlugin)
+			if l.Label != "" {
+				s += " (" + l.Label + ")"
+				label = l.Label
+			}
+			links = append(links, Link{Kind: linkKindPlugin, Label: label, Service: s, URL: l.URL})
+		}
+	}
+	return links
+}
+
+// runLinks lists every link ph can make to the current track, and why any
+// service has no link, to show how links are resolved.
+func runLinks(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("links", flag.ExitOnError)
+		format = fs.StringP("format", "f", cfg.format(), "Output format (text, json, yaml, plain)")
+	)
+	fs.Parse(args)
+	writeOutput, err := getRenderer(*format, useColor(cfg, os.Stdout), cfg)
+	if err != nil {
+		return err
+	}
+
+	status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+	if err != nil {
+		return err
+	}
+	t := status.CurrentTrack
+	if cfg.Catalog.Autocorrect {
+		t = loadSongCatalog(context.Background(), apiClient(cfg), cfg).correct(t)
+	}
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	links := append(trackLinks(t, cfg, relistenArtists), pluginTrackLinks(cfg.Plugins.Links, t)...)
+	if *format != "text" {
+		return writeOutput(links)
+	}
+	fmt.Println(trackSummary(t))
+	for _, l := range links {
+		if l.URL != "" {
+			fmt.Printf("  %-12s %s\n", l.Service, l.URL)
+			continue
+		}
+		fmt.Printf("  %-12s "+tr("skipped: %s")+"\n", l.Service, l.Skipped)
+	}
+	return nil
+}
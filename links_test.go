@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ianfoo/ph/relisten"
+)
+
+func TestTrackLinks(t *testing.T) {
+	artists := map[string]string{
+		"Phish":               "phish",
+		"Grateful Dead":       "grateful-dead",
+		"Trey Anastasio Band": "trey",
+		"Jerry Garcia Band":   "jgb",
+		"Dead & Company":      "dead-and-company",
+		"Billy Strings":       "billy-strings",
+	}
+	tt := []struct {
+		desc  string
+		track Track
+		want  []Link
+	}{
+		{
+			desc:  "Phish show",
+			track: Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/phish/1997/11/22"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "phish.net", URL: "https://phish.net/setlists/?d=1997-11-22"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", URL: "https://phish.in/1997-11-22"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", URL: "https://www.livephish.com/search?q=Phish+1997-11-22"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "nugs.net", Skipped: "Phish shows are not sold on nugs.net"},
+				{Kind: linkKindArchive, Label: "Recordings", Service: "archive.org", Skipped: "Phish does not permit its shows on archive.org"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Phish%20Reba"},
+			},
+		},
+		{
+			desc:  "other artist's show",
+			track: Track{Artist: "Grateful Dead", Title: "Scarlet Begonias", PerformanceTime: mustParseDate("1977-05-08")},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/grateful-dead/1977/05/08"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "jerrybase", URL: "https://jerrybase.com/search?q=1977-05-08"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", Skipped: "only Phish shows are listed"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", Skipped: "Grateful Dead shows are not sold on LivePhish"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "nugs.net", Skipped: "Grateful Dead shows are not sold on nugs.net"},
+				{Kind: linkKindArchive, Label: "Recordings", Service: "archive.org", URL: "https://archive.org/search?query=creator%3A%22Grateful+Dead%22+AND+date%3A1977-05-08"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Grateful%20Dead%20Scarlet%20Begonias"},
+			},
+		},
+		{
+			desc:  "Trey Anastasio Band show",
+			track: Track{Artist: "Trey Anastasio Band", Title: "Sand", PerformanceTime: mustParseDate("2019-02-15")},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/trey/2019/02/15"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "setlist.fm", URL: "https://www.setlist.fm/search?query=Trey+Anastasio+Band+2019-02-15"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", Skipped: "only Phish shows are listed"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", URL: "https://www.livephish.com/search?q=Trey+Anastasio+Band+2019-02-15"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "nugs.net", Skipped: "Trey Anastasio Band shows are not sold on nugs.net"},
+				{Kind: linkKindArchive, Label: "Recordings", Service: "archive.org", URL: "https://archive.org/search?query=creator%3A%22Trey+Anastasio+Band%22+AND+date%3A2019-02-15"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Trey%20Anastasio%20Band%20Sand"},
+			},
+		},
+		{
+			desc:  "Jerry Garcia Band show",
+			track: Track{Artist: "Jerry Garcia Band", Title: "Tangled Up in Blue", PerformanceTime: mustParseDate("1990-11-15")},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/jgb/1990/11/15"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "jerrybase", URL: "https://jerrybase.com/search?q=1990-11-15"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", Skipped: "only Phish shows are listed"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", Skipped: "Jerry Garcia Band shows are not sold on LivePhish"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "nugs.net", Skipped: "Jerry Garcia Band shows are not sold on nugs.net"},
+				{Kind: linkKindArchive, Label: "Recordings", Service: "archive.org", URL: "https://archive.org/search?query=collection%3AJerryGarcia+AND+date%3A1990-11-15"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Jerry%20Garcia%20Band%20Tangled%20Up%20in%20Blue"},
+			},
+		},
+		{
+			desc:  "Dead & Company show",
+			track: Track{Artist: "Dead & Company", Title: "Althea", PerformanceTime: mustParseDate("2023-07-16")},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/dead-and-company/2023/07/16"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "setlist.fm", URL: "https://www.setlist.fm/search?query=Dead+%26+Company+2023-07-16"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", Skipped: "only Phish shows are listed"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", Skipped: "Dead & Company shows are not sold on LivePhish"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "nugs.net", URL: "https://www.nugs.net/search/?searchText=Dead+%26+Company+2023-07-16"},
+				{Kind: linkKindArchive, Label: "Recordings", Service: "archive.org", URL: "https://archive.org/search?query=collection%3ADeadAndCompany+AND+date%3A2023-07-16"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Dead%20&%20Company%20Althea"},
+			},
+		},
+		{
+			desc:  "Billy Strings show",
+			track: Track{Artist: "Billy Strings", Title: "Dust in a Baggie", PerformanceTime: mustParseDate("2022-12-31")},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/billy-strings/2022/12/31"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "setlist.fm", URL: "https://www.setlist.fm/search?query=Billy+Strings+2022-12-31"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", Skipped: "only Phish shows are listed"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", Skipped: "Billy Strings shows are not sold on LivePhish"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "nugs.net", URL: "https://www.nugs.net/search/?searchText=Billy+Strings+2022-12-31"},
+				{Kind: linkKindArchive, Label: "Recordings", Service: "archive.org", URL: "https://archive.org/search?query=creator%3A%22Billy+Strings%22+AND+date%3A2022-12-31"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Billy%20Strings%20Dust%20in%20a%20Baggie"},
+			},
+		},
+		{
+			desc:  "artist not on Relisten",
+			track: Track{Artist: "Goose", Title: "Arcadia", PerformanceTime: mustParseDate("2021-10-01")},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", Skipped: "Goose is not streamable on Relisten"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "setlist.fm", URL: "https://www.setlist.fm/search?query=Goose+2021-10-01"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", Skipped: "only Phish shows are listed"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", Skipped: "Goose shows are not sold on LivePhish"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "nugs.net", Skipped: "Goose shows are not sold on nugs.net"},
+				{Kind: linkKindArchive, Label: "Recordings", Service: "archive.org", URL: "https://archive.org/search?query=creator%3A%22Goose%22+AND+date%3A2021-10-01"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Goose%20Arcadia"},
+			},
+		},
+		{
+			desc:  "studio track",
+			track: Track{Artist: "Phish", Title: "Free"},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", Skipped: "the track is not from a live show, so it has no performance date"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "phish.net", Skipped: "the track is not from a live show, so it has no performance date"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", Skipped: "the track is not from a live show, so it has no performance date"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", Skipped: "the track is not from a live show, so it has no performance date"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "nugs.net", Skipped: "the track is not from a live show, so it has no performance date"},
+				{Kind: linkKindArchive, Label: "Recordings", Service: "archive.org", Skipped: "the track is not from a live show, so it has no performance date"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Phish%20Free"},
+			},
+		},
+		{
+			desc:  "full set",
+			track: Track{Artist: "Phish", Title: "Set 2", Set: &ShowSet{Number: 2}, PerformanceTime: mustParseDate("1997-11-22")},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/phish/1997/11/22"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "phish.net", URL: "https://phish.net/setlists/?d=1997-11-22"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", URL: "https://phish.in/1997-11-22"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", URL: "https://www.livephish.com/search?q=Phish+1997-11-22"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "nugs.net", Skipped: "Phish shows are not sold on nugs.net"},
+				{Kind: linkKindArchive, Label: "Recordings", Service: "archive.org", Skipped: "Phish does not permit its shows on archive.org"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", Skipped: "the track is a full set, not a single song"},
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := trackLinks(tc.track, config{}, artists); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %+v, but got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLinkResolver_ResolveLinks(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/artists":
+			w.Write([]byte(`[{"name": "Phish", "slug": "phish"}]`))
+		case "/artists/phish/shows/1997-11-22":
+			w.Write([]byte(`{"display_date": "1997-11-22", "date": "1997-11-22T00:00:00Z"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	rc := relisten.New(srv.Client(), "")
+	rc.BaseURL = srv.URL
+	rc.MinInterval = 0
+
+	var (
+		tweezer = Track{Artist: "Phish", Title: "Tweezer", PerformanceTime: mustParseDate("1997-11-22")}
+		reba    = Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")}
+		missing = Track{Artist: "Phish", Title: "Bathtub Gin", PerformanceTime: mustParseDate("1997-11-23")}
+		studio  = Track{Artist: "Cream", Title: "Crossroads"}
+		r       = &LinkResolver{Relisten: rc}
+	)
+	for i := 0; i < 2; i++ {
+		got, err := r.ResolveLinks(context.Background(), []Track{tweezer, reba, missing, studio})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := map[TrackID][]Link{
+			tweezer.ID(): {
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/phish/1997/11/22"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "phish.net", URL: "https://phish.net/setlists/?d=1997-11-22"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", URL: "https://phish.in/1997-11-22"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", URL: "https://www.livephish.com/search?q=Phish+1997-11-22"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Phish%20Tweezer"},
+			},
+			reba.ID(): {
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/phish/1997/11/22"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "phish.net", URL: "https://phish.net/setlists/?d=1997-11-22"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", URL: "https://phish.in/1997-11-22"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", URL: "https://www.livephish.com/search?q=Phish+1997-11-22"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Phish%20Reba"},
+			},
+			missing.ID(): {
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "phish.net", URL: "https://phish.net/setlists/?d=1997-11-23"},
+				{Kind: linkKindStream, Label: "Stream", Service: "phish.in", URL: "https://phish.in/1997-11-23"},
+				{Kind: linkKindBuy, Label: "Buy", Service: "LivePhish", URL: "https://www.livephish.com/search?q=Phish+1997-11-23"},
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Phish%20Bathtub%20Gin"},
+			},
+			studio.ID(): {
+				{Kind: linkKindSearch, Label: "Search", Service: "Spotify", URL: "https://open.spotify.com/search/Cream%20Crossroads"},
+			},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("run %d: wanted %+v, but got %+v", i+1, want, got)
+		}
+	}
+	// Each show is looked up once, for both of its tracks and both runs.
+	wantRequests := map[string]int{
+		"/artists":                        1,
+		"/artists/phish/shows/1997-11-22": 1,
+		"/artists/phish/shows/1997-11-23": 1,
+	}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("wanted requests %v, but got %v", wantRequests, requests)
+	}
+}
+
+func TestTrack_Links(t *testing.T) {
+	artists := map[string]string{"Phish": "phish"}
+	tt := []struct {
+		desc  string
+		track Track
+		want  []Link
+	}{
+		{
+			desc:  "show on Relisten",
+			track: Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")},
+			want: []Link{
+				{Kind: linkKindStream, Label: "Stream", Service: "Relisten", URL: "https://relisten.net/phish/1997/11/22"},
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "phish.net", URL: "https://phish.net/setlists/?d=1997-11-22"},
+			},
+		},
+		{
+			desc:  "show not on Relisten",
+			track: Track{Artist: "Goose", Title: "Arcadia", PerformanceTime: mustParseDate("2021-10-01")},
+			want: []Link{
+				{Kind: linkKindSetlist, Label: "Setlist", Service: "setlist.fm", URL: "https://www.setlist.fm/search?query=Goose+2021-10-01"},
+			},
+		},
+		{
+			desc:  "studio track",
+			track: Track{Artist: "Phish", Title: "Free"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := tc.track.Links(config{}, artists)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %+v, but got %+v", tc.want, got)
+			}
+			if want, got := "", linkURL(got, linkKindBuy); got != want {
+				t.Errorf("wanted no buy link, but got %q", got)
+			}
+		})
+	}
+}
+
+func TestLinkLines(t *testing.T) {
+	links := []Link{
+		{Kind: linkKindStream, Label: "Stream", URL: "https://relisten.net/phish/1997/11/22"},
+		{Kind: linkKindBuy, Label: "Buy", Skipped: "Phish shows are not sold on nugs.net"},
+		{Kind: linkKindSetlist, Label: "Setlist", URL: "https://phish.net/setlists/?d=1997-11-22"},
+	}
+	want := "Stream: https://relisten.net/phish/1997/11/22\nSetlist: https://phish.net/setlists/?d=1997-11-22"
+	if got := strings.Join(linkLines(links), "\n"); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+	if want, got := "https://phish.net/setlists/?d=1997-11-22", linkURL(links, linkKindSetlist); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structuredLogger writes log entries made of a message and key/value
+// fields, either as human-readable text or as one JSON object per line,
+// which is what log collectors expect from a containerized service.
+type structuredLogger struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+	now  func() time.Time
+}
+
+func newStructuredLogger(w io.Writer, format string) (*structuredLogger, error) {
+	switch format {
+	case "", "text":
+		return &structuredLogger{w: w, now: time.Now}, nil
+	case "json":
+		return &structuredLogger{w: w, json: true, now: time.Now}, nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+}
+
+func (l *structuredLogger) Info(msg string, fields ...interface{}) {
+	l.log("info", msg, fields)
+}
+
+func (l *structuredLogger) Warn(msg string, fields ...interface{}) {
+	l.log("warn", msg, fields)
+}
+
+func (l *structuredLogger) Error(msg string, fields ...interface{}) {
+	l.log("error", msg, fields)
+}
+
+// log writes an entry. Fields are alternating keys and values; errors are
+// logged by their message.
+func (l *structuredLogger) log(level, msg string, fields []interface{}) {
+	now := l.now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.json {
+		entry := map[string]interface{}{
+			"time":  now.Format(time.RFC3339Nano),
+			"level": level,
+			"msg":   msg,
+		}
+		for i := 0; i+1 < len(fields); i += 2 {
+			entry[fmt.Sprint(fields[i])] = logValue(fields[i+1])
+		}
+		b, err := json.Marshal(entry)
+		if err != nil {
+			b = []byte(fmt.Sprintf(`{"level":"error","msg":"cannot encode log entry: %v"}`, err))
+		}
+		l.w.Write(append(b, '\n'))
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s %s", now.Format(time.RFC3339), strings.ToUpper(level), msg)
+	for i := 0; i+1 < len(fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%q", fields[i], fmt.Sprint(logValue(fields[i+1])))
+	}
+	b.WriteString("\n")
+	io.WriteString(l.w, b.String())
+}
+
+func logValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	recordingsFile = "recordings.json"
+
+	// replayGainReference is the loudness ReplayGain 2.0 adjusts tracks
+	// to, in LUFS.
+	replayGainReference = -18.0
+
+	// r128Reference is the loudness Opus's R128 gain tags adjust tracks
+	// to, in LUFS.
+	r128Reference = -23.0
+)
+
+// loudness is the measured loudness of a recording.
+type loudness struct {
+	// Integrated is the loudness of the whole recording, in LUFS.
+	Integrated float64 `json:"lufs"`
+
+	// TruePeak is the highest level of the audio, in dBFS.
+	TruePeak float64 `json:"true_peak_dbfs"`
+}
+
+// gain is the ReplayGain adjustment that brings the recording to the
+// reference loudness, in dB.
+func (l loudness) gain() float64 {
+	return replayGainReference - l.Integrated
+}
+
+// peak is the true peak as a fraction of full scale, as ReplayGain tags
+// give it.
+func (l loudness) peak() float64 {
+	return math.Pow(10, l.TruePeak/20)
+}
+
+// replayGainFields returns the ReplayGain tags for the loudness, as field
+// names and values.
+func (l loudness) replayGainFields() [][2]string {
+	return [][2]string{
+		{"REPLAYGAIN_TRACK_GAIN", fmt.Sprintf("%.2f dB", l.gain())},
+		{"REPLAYGAIN_TRACK_PEAK", fmt.Sprintf("%.6f", l.peak())},
+	}
+}
+
+// r128Gain returns the gain for Opus's R128_TRACK_GAIN tag, in 1/256 dB
+// relative to the gain in the Opus header, which ph leaves at 0.
+func (l loudness) r128Gain() int {
+	return int(math.Round((r128Reference - l.Integrated) * 256))
+}
+
+// measureLoudness measures a recording's loudness, as defined by EBU R 128,
+// with ffmpeg.
+func measureLoudness(path string) (loudness, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return loudness{}, errors.New("measuring loudness needs ffmpeg on the PATH")
+	}
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostats", "-i", path, "-filter_complex", "ebur128=peak=true", "-f", "null", "-")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return loudness{}, fmt.Errorf("measure loudness of %s: %w", path, err)
+	}
+	return parseEBUR128(string(out))
+}
+
+// parseEBUR128 parses the summary ffmpeg's ebur128 filter logs at the end
+// of its output.
+func parseEBUR128(out string) (loudness, error) {
+	i := strings.LastIndex(out, "Summary:")
+	if i < 0 {
+		return loudness{}, errors.New("no loudness summary in ffmpeg's output")
+	}
+	var (
+		l                        loudness
+		haveIntegrated, havePeak bool
+	)
+	for _, line := range strings.Split(out[i:], "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case fields[0] == "I:" && fields[2] == "LUFS":
+			l.Integrated, haveIntegrated = v, true
+		case fields[0] == "Peak:" && fields[2] == "dBFS":
+			l.TruePeak, havePeak = v, true
+		}
+	}
+	if !haveIntegrated || !havePeak {
+		return loudness{}, errors.New("incomplete loudness summary in ffmpeg's output")
+	}
+	return l, nil
+}
+
+// recordingEntry is a recording in the recording index. Started and
+// Recorded are when the recording started and was completed.
+type recordingEntry struct {
+	Path     string     `json:"path"`
+	Track    playRecord `json:"track"`
+	Started  time.Time  `json:"started,omitempty"`
+	Recorded time.Time  `json:"recorded"`
+	Loudness *loudness  `json:"loudness,omitempty"`
+}
+
+func (s *store) recordings() ([]recordingEntry, error) {
+	var r []recordingEntry
+	err := s.readJSON(recordingsFile, &r)
+	return r, err
+}
+
+// saveRecording adds a recording to the index, replacing any entry for the
+// same file.
+func (s *store) saveRecording(e recordingEntry) error {
+	r, err := s.recordings()
+	if err != nil {
+		return err
+	}
+	for i := range r {
+		if r[i].Path == e.Path {
+			r[i] = e
+			return s.writeJSON(recordingsFile, r)
+		}
+	}
+	return s.writeJSON(recordingsFile, append(r, e))
+}
+
+// recording returns the index entry for the file at path, and reports
+// whether there is one.
+func (s *store) recording(path string) (recordingEntry, bool, error) {
+	r, err := s.recordings()
+	if err != nil {
+		return recordingEntry{}, false, err
+	}
+	for _, e := range r {
+		if e.Path == path {
+			return e, true, nil
+		}
+	}
+	return recordingEntry{}, false, nil
+}
+
+// scanRecording measures a recording's loudness and tags it with the
+// ReplayGain, returning its entry with the loudness set.
+func scanRecording(e recordingEntry) (recordingEntry, error) {
+	l, err := measureLoudness(e.Path)
+	if err != nil {
+		return e, err
+	}
+	e.Loudness = &l
+	return e, tagFile(e.Path, Track(e.Track), e.Loudness)
+}
+
+// runRecordings lists the recordings made by ph record, with their
+// loudness, measuring the loudness of any that have not been with --scan.
+func runRecordings(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("recordings", flag.ExitOnError)
+		scan   = fs.Bool("scan", false, "Measure the loudness of recordings not yet measured, and tag them with ReplayGain")
+		format = fs.StringP("format", "f", cfg.format(), "Output format (text, json, yaml)")
+	)
+	fs.Parse(args)
+	writeOutput, err := getRenderer(*format, useColor(cfg, os.Stdout), cfg)
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	recordings, err := st.recordings()
+	if err != nil {
+		return fmt.Errorf("read recording index: %w", err)
+	}
+	if *scan {
+		for i, e := range recordings {
+			if e.Loudness != nil {
+				continue
+			}
+			if _, err := os.Stat(e.Path); err != nil {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Scanning %s\n", filepath.Base(e.Path))
+			e, err := scanRecording(e)
+			if err != nil {
+				return err
+			}
+			recordings[i] = e
+			if err := st.saveRecording(e); err != nil {
+				return fmt.Errorf("update recording index: %w", err)
+			}
+		}
+	}
+	if *format != "text" {
+		return writeOutput(recordings)
+	}
+	if len(recordings) == 0 {
+		fmt.Println("No recordings yet; use ph record to make some.")
+		return nil
+	}
+	fmt.Printf("%-16s  %6s  %8s  %s\n", "Recorded", "LUFS", "Gain", "File")
+	for _, e := range recordings {
+		lufs, gain := "-", "-"
+		if l := e.Loudness; l != nil {
+			lufs, gain = fmt.Sprintf("%.1f", l.Integrated), fmt.Sprintf("%+.1f dB", l.gain())
+		}
+		fmt.Printf("%-16s  %6s  %8s  %s\n", e.Recorded.Local().Format("2006-01-02 15:04"), lufs, gain, e.Path)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const ebur128Output = `Input #0, mp3, from 'Phish - 1997-11-22 - Reba.mp3':
+  Duration: 00:16:02.12, start: 0.025057, bitrate: 128 kb/s
+[Parsed_ebur128_0 @ 0x7f8c] t: 0.0999773  TARGET:-23 LUFS    M:-120.7 S:-120.7     I: -70.0 LUFS       LRA:   0.0 LU  FTPK: -31.2 dBFS  TPK: -31.2 dBFS
+[Parsed_ebur128_0 @ 0x7f8c] Summary:
+
+  Integrated loudness:
+    I:         -14.6 LUFS
+    Threshold: -24.8 LUFS
+
+  Loudness range:
+    LRA:         6.1 LU
+    Threshold: -34.9 LUFS
+    LRA low:   -19.2 LUFS
+    LRA high:  -13.1 LUFS
+
+  True peak:
+    Peak:       -0.4 dBFS
+`
+
+func TestParseEBUR128(t *testing.T) {
+	got, err := parseEBUR128(ebur128Output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (loudness{Integrated: -14.6, TruePeak: -0.4}); got != want {
+		t.Errorf("wanted %+v, but got %+v", want, got)
+	}
+	if _, err := parseEBUR128("Summary:\n    I:         -14.6 LUFS\n"); err == nil {
+		t.Error("wanted an error for a summary without a peak, but got none")
+	}
+	if _, err := parseEBUR128("ffmpeg version 6.0"); err == nil {
+		t.Error("wanted an error for output without a summary, but got none")
+	}
+}
+
+func TestLoudnessGain(t *testing.T) {
+	l := loudness{Integrated: -14.6, TruePeak: -0.4}
+	want := [][2]string{
+		{"REPLAYGAIN_TRACK_GAIN", "-3.40 dB"},
+		{"REPLAYGAIN_TRACK_PEAK", "0.954993"},
+	}
+	got := l.replayGainFields()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("wanted %v, but got %v", want[i], got[i])
+		}
+	}
+	if want, got := -2150, l.r128Gain(); got != want {
+		t.Errorf("wanted R128 gain %d, but got %d", want, got)
+	}
+}
+
+func TestStore_Recordings(t *testing.T) {
+	st := newTestStore(t)
+	reba := recordingEntry{Path: "/music/Phish - 1997-11-22 - Reba.mp3", Track: playRecord{Artist: "Phish", Title: "Reba"}}
+	ghost := recordingEntry{Path: "/music/Phish - 1997-11-22 - Ghost.mp3", Track: playRecord{Artist: "Phish", Title: "Ghost"}}
+	for _, e := range []recordingEntry{reba, ghost} {
+		if err := st.saveRecording(e); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	reba.Loudness = &loudness{Integrated: -14.6, TruePeak: -0.4}
+	if err := st.saveRecording(reba); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recordings, err := st.recordings()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recordings) != 2 {
+		t.Fatalf("wanted 2 recordings, but got %d", len(recordings))
+	}
+	got, ok, err := st.recording(reba.Path)
+	if err != nil || !ok {
+		t.Fatalf("wanted the recording to be found, but got %t (%v)", ok, err)
+	}
+	if got.Loudness == nil || *got.Loudness != *reba.Loudness {
+		t.Errorf("wanted loudness %+v, but got %+v", reba.Loudness, got.Loudness)
+	}
+	if _, ok, _ := st.recording("/music/elsewhere.mp3"); ok {
+		t.Error("wanted no recording for a file not in the index")
+	}
+}
+
+func TestTagFileReplayGain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-loudness")
+	if err != nil {
+		t.Fatalf("unable to create dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		reba = Track{Artist: "Phish", Title: "Reba"}
+		l    = &loudness{Integrated: -14.6, TruePeak: -0.4}
+		mp3  = filepath.Join(dir, "Reba.mp3")
+		opus = filepath.Join(dir, "Reba.opus")
+	)
+	if err := ioutil.WriteFile(mp3, []byte("\xff\xfbaudio"), 0644); err != nil {
+		t.Fatalf("unable to write recording: %v", err)
+	}
+	if err := tagFile(mp3, reba, l); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(mp3)
+	if err != nil {
+		t.Fatalf("unable to read recording: %v", err)
+	}
+	if want := []byte("REPLAYGAIN_TRACK_GAIN\x00-3.40 dB"); !bytes.Contains(b, want) {
+		t.Errorf("wanted the tags to contain %q, but got %q", want, b)
+	}
+
+	if err := ioutil.WriteFile(opus, testOggFile(nil), 0644); err != nil {
+		t.Fatalf("unable to write recording: %v", err)
+	}
+	if err := tagFile(opus, reba, l); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b, err = ioutil.ReadFile(opus); err != nil {
+		t.Fatalf("unable to read recording: %v", err)
+	}
+	if got, err := oggComment(b, "R128_TRACK_GAIN"); err != nil || got != "-2150" {
+		t.Errorf("wanted R128 gain %q, but got %q (%v)", "-2150", got, err)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	// pathPhishNetSongData is the data of a song, including its lyrics,
+	// relative to the phish.net endpoint, given the song's slug and an API
+	// key.
+	pathPhishNetSongData = "/v5/songdata/slug/%s.json?apikey=%s"
+
+	// pathLyrics is the lyrics of a song, relative to the lyrics endpoint,
+	// given the artist and the title.
+	pathLyrics = "/v1/%s/%s"
+)
+
+// errNoLyrics is returned when no lyrics of a song can be found.
+var errNoLyrics = errors.New("no lyrics found")
+
+// songLyrics are the lyrics of a song, with where they came from.
+type songLyrics struct {
+	Artist string
+	Song   string
+	Text   string
+	Source string
+}
+
+func (l songLyrics) String() string {
+	return fmt.Sprintf("%s - %s\n\n%s\n\n%s", l.Artist, l.Song, strings.TrimSpace(l.Text), fmt.Sprintf(tr("Lyrics from %s"), l.Source))
+}
+
+// fetchLyrics fetches the lyrics of the song that artist played. The songs
+// of artists whose setlists come from phish.net are looked up there first,
+// with the API key used for the song catalog; a song phish.net has no
+// lyrics for, such as a cover, is looked up in the lyrics API by its
+// original artist. Anything else is looked up in the lyrics API.
+func fetchLyrics(ctx context.Context, client *http.Client, cfg config, artist, song string) (songLyrics, error) {
+	if cfg.setlistProvider(artist).Name() == "phish.net" && cfg.Catalog.PhishNetAPIKey != "" {
+		l, original, err := phishNetLyrics(ctx, client, cfg, song)
+		switch {
+		case err == nil:
+			l.Artist = artist
+			return l, nil
+		case !errors.Is(err, errNoLyrics):
+			return songLyrics{}, err
+		case original != "":
+			artist = original
+		}
+	}
+	return apiLyrics(ctx, client, cfg, artist, song)
+}
+
+// phishNetSongSlug returns the slug phish.net gives song in its URLs, as in
+// "wolfmans-brother" for "Wolfman's Brother".
+func phishNetSongSlug(song string) string {
+	song = strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(strings.TrimSpace(song)))
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(song, "-"), "-")
+}
+
+// phishNetLyrics asks phish.net for the lyrics of song, through the
+// phish.net circuit breaker. When phish.net has none, it returns
+// errNoLyrics with the song's original artist, if phish.net knows it.
+func phishNetLyrics(ctx context.Context, client *http.Client, cfg config, song string) (songLyrics, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeouts.phishNet())
+	defer cancel()
+	u := cfg.Endpoints.phishNet() + fmt.Sprintf(pathPhishNetSongData, url.PathEscape(phishNetSongSlug(song)), url.QueryEscape(cfg.Catalog.PhishNetAPIKey))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return songLyrics{}, "", err
+	}
+	resp, err := withBreaker(client, phishNetBreaker).Do(req)
+	if err != nil {
+		return songLyrics{}, "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return songLyrics{}, "", errNoLyrics
+	case resp.StatusCode != http.StatusOK:
+		return songLyrics{}, "", fmt.Errorf("phish.net: %s", resp.Status)
+	}
+	var body struct {
+		Error        bool   `json:"error"`
+		ErrorMessage string `json:"error_message"`
+		Data         []struct {
+			Song   string `json:"song"`
+			Artist string `json:"artist"`
+			Lyrics string `json:"lyrics"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return songLyrics{}, "", fmt.Errorf("decode phish.net song data: %w", err)
+	}
+	if body.Error {
+		return songLyrics{}, "", fmt.Errorf("phish.net: %s", body.ErrorMessage)
+	}
+	if len(body.Data) == 0 {
+		return songLyrics{}, "", errNoLyrics
+	}
+	d := body.Data[0]
+	text := htmlToText(d.Lyrics)
+	if text == "" {
+		return songLyrics{}, d.Artist, errNoLyrics
+	}
+	return songLyrics{Song: d.Song, Text: text, Source: "phish.net"}, "", nil
+}
+
+// apiLyrics asks the lyrics API for the lyrics of song by artist, through
+// its circuit breaker.
+func apiLyrics(ctx context.Context, client *http.Client, cfg config, artist, song string) (songLyrics, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeouts.lyrics())
+	defer cancel()
+	endpoint := cfg.Endpoints.lyrics()
+	u := endpoint + fmt.Sprintf(pathLyrics, url.PathEscape(artist), url.PathEscape(strings.TrimSpace(song)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return songLyrics{}, err
+	}
+	resp, err := withBreaker(client, lyricsBreaker).Do(req)
+	if err != nil {
+		return songLyrics{}, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return songLyrics{}, errNoLyrics
+	case resp.StatusCode != http.StatusOK:
+		return songLyrics{}, fmt.Errorf("lyrics: %s", resp.Status)
+	}
+	var body struct {
+		Lyrics string `json:"lyrics"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return songLyrics{}, fmt.Errorf("decode lyrics: %w", err)
+	}
+	text := strings.TrimSpace(strings.Replace(body.Lyrics, "\r\n", "\n", -1))
+	if text == "" {
+		return songLyrics{}, errNoLyrics
+	}
+	source := endpoint
+	if eu, err := url.Parse(endpoint); err == nil && eu.Host != "" {
+		source = strings.TrimPrefix(eu.Host, "api.")
+	}
+	return songLyrics{Artist: artist, Song: strings.TrimSpace(song), Text: text, Source: source}, nil
+}
+
+var (
+	htmlNewline   = regexp.MustCompile(`[ \t]*\r?\n[ \t]*`)
+	htmlLineBreak = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlParagraph = regexp.MustCompile(`(?i)</p>|</div>`)
+	htmlTag       = regexp.MustCompile(`<[^>]*>`)
+	blankLines    = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText returns lyrics formatted as HTML as plain text, with a line
+// for each line break and a blank line after each paragraph.
+func htmlToText(s string) string {
+	s = htmlNewline.ReplaceAllString(s, " ")
+	s = htmlLineBreak.ReplaceAllString(s, "\n")
+	s = htmlParagraph.ReplaceAllString(s, "\n\n")
+	s = html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// page shows s in the user's pager, $PAGER or else less, when stdout is a
+// terminal, and otherwise writes it to stdout. If the pager cannot be run,
+// s is written to stdout instead.
+func page(s string) error {
+	info, err := os.Stdout.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		_, err := fmt.Println(s)
+		return err
+	}
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{"less", "-FRX"}
+	}
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = strings.NewReader(s + "\n")
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil
+		}
+		_, err := fmt.Println(s)
+		return err
+	}
+	return nil
+}
+
+// runLyrics shows the lyrics of the current track, in the pager. Each song
+// of a title with several, as in "Mercury > Simple", has its own lyrics.
+func runLyrics(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs      = flag.NewFlagSet("lyrics", flag.ExitOnError)
+		noPager = fs.Bool("no-pager", false, "Write the lyrics to stdout instead of showing them in the pager")
+	)
+	fs.Parse(args)
+
+	status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+	if err != nil {
+		return err
+	}
+	t := status.CurrentTrack
+	if cfg.Catalog.Autocorrect {
+		t = loadSongCatalog(context.Background(), apiClient(cfg), cfg).correct(t)
+	}
+	switch {
+	case status.Status == "offline" || t.Title == "":
+		return errors.New("nothing is playing")
+	case t.Kind() != trackKindSong:
+		return fmt.Errorf("%s is not a song", trackSummary(t))
+	}
+	var found []string
+	for _, song := range strings.FieldsFunc(t.Title, func(r rune) bool { return r == '>' || r == '→' }) {
+		if song = strings.TrimSpace(song); song == "" {
+			continue
+		}
+		l, err := fetchLyrics(context.Background(), apiClient(cfg), cfg, t.Artist, song)
+		if errors.Is(err, errNoLyrics) {
+			found = append(found, fmt.Sprintf(tr("No lyrics found for %s."), song))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("get lyrics of %s: %w", song, err)
+		}
+		found = append(found, l.String())
+	}
+	out := strings.Join(found, "\n\n\n")
+	if *noPager {
+		_, err := fmt.Println(out)
+		return err
+	}
+	return page(out)
+}
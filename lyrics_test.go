@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPhishNetSongSlug(t *testing.T) {
+	tt := []struct {
+		song string
+		want string
+	}{
+		{song: "Tweezer", want: "tweezer"},
+		{song: "Wolfman's Brother", want: "wolfmans-brother"},
+		{song: "Slave to the Traffic Light", want: "slave-to-the-traffic-light"},
+		{song: " Down with Disease ", want: "down-with-disease"},
+		{song: "Guyute (Reprise)", want: "guyute-reprise"},
+	}
+	for _, tc := range tt {
+		if got := phishNetSongSlug(tc.song); got != tc.want {
+			t.Errorf("%s: wanted %s, but got %s", tc.song, tc.want, got)
+		}
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	in := "<p>Won&#39;t you step into the freezer<br />\r\nWon't you step into the freezer</p>\n<p><br>\n<br>\n<br>Tweezer</p>"
+	want := "Won't you step into the freezer\nWon't you step into the freezer\n\nTweezer"
+	if got := htmlToText(in); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
+
+func TestFetchLyrics(t *testing.T) {
+	phishNet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v5/songdata/slug/wolfmans-brother.json":
+			w.Write([]byte(`{"error": false, "data": [{"song": "Wolfman's Brother", "artist": "Phish", "lyrics": "<p>Wolfman's brother<br>Back in town</p>"}]}`))
+		case "/v5/songdata/slug/crossroads.json":
+			w.Write([]byte(`{"error": false, "data": [{"song": "Crossroads", "artist": "Robert Johnson", "lyrics": ""}]}`))
+		default:
+			w.Write([]byte(`{"error": false, "data": []}`))
+		}
+	}))
+	defer phishNet.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/Robert Johnson/Crossroads":
+			w.Write([]byte(`{"lyrics": "I went down to the crossroads"}`))
+		case "/v1/Goose/Arcadia":
+			w.Write([]byte(`{"lyrics": "Arcadia\r\nArcadia"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "No lyrics found"}`))
+		}
+	}))
+	defer api.Close()
+
+	var cfg config
+	cfg.Endpoints.PhishNet = phishNet.URL
+	cfg.Endpoints.Lyrics = api.URL
+	cfg.Catalog.PhishNetAPIKey = "key"
+	tt := []struct {
+		desc       string
+		artist     string
+		song       string
+		wantText   string
+		wantSource string
+		wantErr    error
+	}{
+		{desc: "Phish original", artist: "Phish", song: "Wolfman's Brother", wantText: "Wolfman's brother\nBack in town", wantSource: "phish.net"},
+		{desc: "Phish cover", artist: "Phish", song: "Crossroads", wantText: "I went down to the crossroads", wantSource: "127.0.0.1"},
+		{desc: "other artist", artist: "Goose", song: "Arcadia", wantText: "Arcadia\nArcadia", wantSource: "127.0.0.1"},
+		{desc: "not found", artist: "Goose", song: "Hot Tea", wantErr: errNoLyrics},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := fetchLyrics(context.Background(), http.DefaultClient, cfg, tc.artist, tc.song)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("wanted error %v, but got %v", tc.wantErr, err)
+			}
+			if got.Text != tc.wantText {
+				t.Errorf("wanted lyrics %q, but got %q", tc.wantText, got.Text)
+			}
+			if !strings.HasPrefix(got.Source, tc.wantSource) {
+				t.Errorf("wanted lyrics from %s, but got them from %s", tc.wantSource, got.Source)
+			}
+		})
+	}
+}
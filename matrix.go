@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// matrixSyncTimeout is how long the homeserver holds a sync request open
+// waiting for new events.
+const matrixSyncTimeout = 30 * time.Second
+
+// matrixConfig holds the settings for joining a Matrix room.
+type matrixConfig struct {
+	Homeserver  string `yaml:"homeserver,omitempty" doc:"URL of the bot account's homeserver, e.g. https://matrix.org"`
+	AccessToken string `yaml:"access_token,omitempty" doc:"Access token of the bot account"`
+	Room        string `yaml:"room,omitempty" doc:"Room ID or alias to join, e.g. #jemp:matrix.org"`
+}
+
+// matrixClient is a minimal Matrix client that joins a single room, using
+// the client-server API.
+type matrixClient struct {
+	client     *http.Client
+	homeserver string
+	token      string
+	room       string
+
+	mu     sync.Mutex
+	roomID string
+	userID string
+	txn    int64
+}
+
+func newMatrixClient(client *http.Client, cfg matrixConfig) (*matrixClient, error) {
+	if cfg.Homeserver == "" || cfg.AccessToken == "" || cfg.Room == "" {
+		return nil, errors.New("bot.matrix.homeserver, bot.matrix.access_token, and bot.matrix.room must be set")
+	}
+	hs := cfg.Homeserver
+	if !strings.Contains(hs, "://") {
+		hs = "https://" + hs
+	}
+	return &matrixClient{
+		client:     client,
+		homeserver: strings.TrimSuffix(hs, "/"),
+		token:      cfg.AccessToken,
+		room:       cfg.Room,
+		txn:        time.Now().UnixNano(),
+	}, nil
+}
+
+// do makes a request to the client-server API, decoding the JSON response
+// into v if it is not nil.
+func (c *matrixClient) do(ctx context.Context, method, path string, body, v interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.homeserver+"/_matrix/client/v3"+path, r)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var merr struct {
+			Error string `json:"error"`
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		if json.Unmarshal(b, &merr) == nil && merr.Error != "" {
+			return fmt.Errorf("matrix %s: %s", resp.Status, merr.Error)
+		}
+		return fmt.Errorf("matrix: %s", resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// matrixSync is the part of a sync response the bot uses.
+type matrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []struct {
+					Type    string `json:"type"`
+					Sender  string `json:"sender"`
+					Content struct {
+						MsgType string `json:"msgtype"`
+						Body    string `json:"body"`
+					} `json:"content"`
+				} `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+func (c *matrixClient) sync(ctx context.Context, since string, timeout time.Duration) (matrixSync, error) {
+	q := url.Values{"timeout": {strconv.Itoa(int(timeout / time.Millisecond))}}
+	if since != "" {
+		q.Set("since", since)
+	}
+	var s matrixSync
+	err := c.do(ctx, http.MethodGet, "/sync?"+q.Encode(), nil, &s)
+	return s, err
+}
+
+func (c *matrixClient) serve(ctx context.Context, onMessage func(text string, reply func(string) error)) error {
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/account/whoami", nil, &whoami); err != nil {
+		return fmt.Errorf("check Matrix access token: %w", err)
+	}
+	var joined struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/join/"+url.PathEscape(c.room), struct{}{}, &joined); err != nil {
+		return fmt.Errorf("join %s: %w", c.room, err)
+	}
+	c.mu.Lock()
+	c.userID, c.roomID = whoami.UserID, joined.RoomID
+	c.mu.Unlock()
+
+	// The first sync returns recent history, which has already been
+	// answered, so only its position is kept.
+	s, err := c.sync(ctx, "", 0)
+	if err != nil {
+		return err
+	}
+	since := s.NextBatch
+	for {
+		s, err := c.sync(ctx, since, matrixSyncTimeout)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		since = s.NextBatch
+		for _, e := range s.Rooms.Join[joined.RoomID].Timeline.Events {
+			if e.Type == "m.room.message" && e.Sender != whoami.UserID && e.Content.MsgType == "m.text" {
+				onMessage(e.Content.Body, c.send)
+			}
+		}
+	}
+}
+
+// send posts text to the room as a notice, the message type intended for
+// bots.
+func (c *matrixClient) send(text string) error {
+	c.mu.Lock()
+	roomID := c.roomID
+	c.txn++
+	txn := c.txn
+	c.mu.Unlock()
+	if roomID == "" {
+		return errors.New("not joined to a Matrix room")
+	}
+	path := fmt.Sprintf("/rooms/%s/send/m.room.message/%d", url.PathEscape(roomID), txn)
+	return c.do(context.Background(), http.MethodPut, path, map[string]string{"msgtype": "m.notice", "body": text}, nil)
+}
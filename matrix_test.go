@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestMatrixClient(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		syncs       int
+		sent        []map[string]string
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("wanted bearer token, but got %q", got)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/_matrix/client/v3/account/whoami":
+			w.Write([]byte(`{"user_id": "@phbot:example.com"}`))
+		case r.URL.Path == "/_matrix/client/v3/join/#jemp:example.com":
+			w.Write([]byte(`{"room_id": "!room:example.com"}`))
+		case r.URL.Path == "/_matrix/client/v3/sync":
+			syncs++
+			switch syncs {
+			case 1:
+				// History from before the bot started is ignored.
+				w.Write([]byte(`{"next_batch": "s1", "rooms": {"join": {"!room:example.com": {"timeline": {"events": [
+					{"type": "m.room.message", "sender": "@fan:example.com", "content": {"msgtype": "m.text", "body": "!old"}}
+				]}}}}}`))
+			case 2:
+				if since := r.URL.Query().Get("since"); since != "s1" {
+					t.Errorf("wanted since s1, but got %q", since)
+				}
+				w.Write([]byte(`{"next_batch": "s2", "rooms": {"join": {"!room:example.com": {"timeline": {"events": [
+					{"type": "m.room.message", "sender": "@phbot:example.com", "content": {"msgtype": "m.notice", "body": "!np"}},
+					{"type": "m.room.message", "sender": "@fan:example.com", "content": {"msgtype": "m.text", "body": "!np"}}
+				]}}}}}`))
+			default:
+				cancel()
+				w.Write([]byte(`{"next_batch": "s3"}`))
+			}
+		case r.Method == http.MethodPut:
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			sent = append(sent, body)
+			w.Write([]byte(`{"event_id": "$1"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := newMatrixClient(srv.Client(), matrixConfig{Homeserver: srv.URL, AccessToken: "secret", Room: "#jemp:example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var received []string
+	err = c.serve(ctx, func(text string, reply func(string) error) {
+		received = append(received, text)
+		if err := reply("Now playing: Phish - Reba"); err != nil {
+			t.Errorf("unexpected error sending: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received) != 1 || received[0] != "!np" {
+		t.Errorf("wanted to receive only the new !np, but got %q", received)
+	}
+	if len(sent) != 1 || sent[0]["body"] != "Now playing: Phish - Reba" || sent[0]["msgtype"] != "m.notice" {
+		t.Errorf("unexpected messages sent: %v", sent)
+	}
+}
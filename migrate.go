@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	flag "github.com/spf13/pflag"
+)
+
+const schemaFile = "schema.json"
+
+// migration upgrades the store from the version before it to its own.
+// Migrations must write each file atomically, and must be safe to apply
+// again, since ph may be interrupted before it records that one was
+// applied.
+type migration struct {
+	version int
+	summary string
+	apply   func(*store) error
+}
+
+// migrations lists every change to the layout of the store, oldest first.
+// Add new ones at the end, and never change one that has been released.
+var migrations = []migration{
+	{
+		version: 1,
+		summary: "Store the ID of every play",
+		apply: func(s *store) error {
+			plays, err := s.recordedPlays()
+			if err != nil || len(plays) == 0 {
+				return err
+			}
+			return s.replacePlays(plays)
+		},
+	},
+}
+
+// latestSchemaVersion returns the version of the layout of the store that
+// this version of ph writes.
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// schemaVersion returns the version of the layout of the store, which is
+// zero for a store written before versions were recorded.
+func (s *store) schemaVersion() (int, error) {
+	var schema struct {
+		Version int `json:"version"`
+	}
+	err := s.readJSON(schemaFile, &schema)
+	return schema.Version, err
+}
+
+func (s *store) setSchemaVersion(version int) error {
+	return s.writeJSON(schemaFile, map[string]int{"version": version})
+}
+
+// pendingMigrations returns the migrations the store needs, oldest first.
+// A store that does not exist yet needs none, and one written by a newer
+// version of ph is an error, since this one cannot know its layout.
+func (s *store) pendingMigrations() ([]migration, error) {
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	version, err := s.schemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("read schema version: %w", err)
+	}
+	latest := latestSchemaVersion()
+	if version > latest {
+		return nil, fmt.Errorf("data in %s is from a newer version of ph (schema version %d, but this one knows up to %d)", s.dir, version, latest)
+	}
+	var pending []migration
+	for _, m := range migrations {
+		if m.version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// migrate applies the migrations the store needs, recording the version
+// after each, and returns those it applied.
+func (s *store) migrate() ([]migration, error) {
+	pending, err := s.pendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range pending {
+		if err := m.apply(s); err != nil {
+			return pending[:i], fmt.Errorf("migrate data to version %d (%s): %w", m.version, m.summary, err)
+		}
+		if err := s.setSchemaVersion(m.version); err != nil {
+			return pending[:i], fmt.Errorf("record schema version %d: %w", m.version, err)
+		}
+	}
+	return pending, nil
+}
+
+func runDBMigrate(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("db migrate", flag.ExitOnError)
+		dryRun = fs.BoolP("dry-run", "n", false, "List the migrations that would be applied without applying them")
+	)
+	fs.Parse(args)
+	st, err := localStore()
+	if err != nil {
+		return err
+	}
+	pending, err := st.pendingMigrations()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Println(tr("The local data is up to date."))
+		return nil
+	}
+	if *dryRun {
+		for _, m := range pending {
+			fmt.Printf("%3d. %s\n", m.version, m.summary)
+		}
+		fmt.Printf(tr("%d migrations would be applied.")+"\n", len(pending))
+		return nil
+	}
+	applied, err := st.migrate()
+	for _, m := range applied {
+		fmt.Printf("%3d. %s\n", m.version, m.summary)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf(tr("Applied %d migrations.")+"\n", len(applied))
+	return nil
+}
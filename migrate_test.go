@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_Migrate(t *testing.T) {
+	st := newTestStore(t)
+	// A play as recorded before play IDs were stored.
+	old := playRecord{Artist: "Phish", Title: "Reba", StartTime: time.Date(2024, 6, 1, 20, 55, 0, 0, time.UTC)}
+	if err := st.appendJSONLine(playsFile, old); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pending, err := st.pendingMigrations()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pending) != len(migrations) {
+		t.Errorf("wanted %d pending migrations, but got %d", len(migrations), len(pending))
+	}
+	applied, err := st.migrate()
+	if err != nil {
+		t.Fatalf("unexpected error migrating: %v", err)
+	}
+	if len(applied) != len(pending) {
+		t.Errorf("wanted %d migrations applied, but got %d", len(pending), len(applied))
+	}
+	b, err := ioutil.ReadFile(st.path(playsFile))
+	if err != nil {
+		t.Fatalf("unexpected error reading plays: %v", err)
+	}
+	if want := `"play_id":"` + playID(Track(old)) + `"`; !strings.Contains(string(b), want) {
+		t.Errorf("wanted plays to contain %s, but got %s", want, b)
+	}
+	version, err := st.schemaVersion()
+	if err != nil || version != migrations[len(migrations)-1].version {
+		t.Errorf("wanted the latest schema version, but got %d (%v)", version, err)
+	}
+
+	if applied, err := st.migrate(); err != nil || len(applied) != 0 {
+		t.Errorf("wanted no migrations applied again, but got %d (%v)", len(applied), err)
+	}
+}
+
+func TestOpenStore_New(t *testing.T) {
+	dir := newTestStore(t).dir
+	defer os.Setenv("XDG_DATA_HOME", os.Getenv("XDG_DATA_HOME"))
+	os.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+
+	for i := 0; i < 2; i++ {
+		st, err := openStore()
+		if err != nil {
+			t.Fatalf("unexpected error opening store: %v", err)
+		}
+		if pending, err := st.pendingMigrations(); err != nil || len(pending) != 0 {
+			t.Errorf("open %d: wanted no pending migrations, but got %d (%v)", i+1, len(pending), err)
+		}
+	}
+}
+
+func TestStore_PendingMigrations(t *testing.T) {
+	t.Run("no store", func(t *testing.T) {
+		st := &store{dir: filepath.Join(newTestStore(t).dir, "missing")}
+		if pending, err := st.pendingMigrations(); err != nil || len(pending) != 0 {
+			t.Errorf("wanted no migrations for a new store, but got %d (%v)", len(pending), err)
+		}
+	})
+	t.Run("newer version", func(t *testing.T) {
+		st := newTestStore(t)
+		if err := st.setSchemaVersion(migrations[len(migrations)-1].version + 1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := st.pendingMigrations(); err == nil {
+			t.Errorf("wanted an error for a store from a newer version of ph")
+		}
+	})
+}
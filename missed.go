@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const playbackStopFile = "stopped.json"
+
+// playbackStop records where ph play stopped when its sleep timer ended,
+// so ph missed can pick up from there.
+type playbackStop struct {
+	Time  time.Time  `json:"time"`
+	Track playRecord `json:"track"`
+}
+
+// missedTracks returns the tracks in a history, oldest first, that aired
+// since playback stopped, beginning with the track that was playing when
+// it stopped.
+func missedTracks(history TrackList, stop playbackStop) TrackList {
+	stopped := Track(stop.Track)
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Key().Equal(stopped.Key()) {
+			return append(TrackList{}, history[i:]...)
+		}
+	}
+	return history.Since(stop.Time)
+}
+
+// runMissed lists the tracks that aired since ph play was stopped by its
+// sleep timer.
+func runMissed(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("missed", flag.ExitOnError)
+		format = fs.StringP("format", "f", cfg.format(), "Output format (text, json, yaml)")
+	)
+	fs.Parse(args)
+	writeOutput, err := getRenderer(*format, useColor(cfg, os.Stdout), cfg)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	var stop playbackStop
+	if err := st.readJSON(playbackStopFile, &stop); err != nil {
+		return fmt.Errorf("read where playback stopped: %w", err)
+	}
+	if stop.Time.IsZero() {
+		return errors.New(tr("no record of where playback stopped; use ph play --sleep to record it"))
+	}
+	plays, err := st.plays()
+	if err != nil {
+		return fmt.Errorf("read play history: %w", err)
+	}
+	status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+	if err != nil {
+		return err
+	}
+	// The station's history may not include the current track.
+	remote := status.History
+	if len(remote) == 0 || !remote[0].Key().Equal(status.CurrentTrack.Key()) {
+		remote = append(TrackList{status.CurrentTrack}, remote...)
+	}
+	missed := missedTracks(Merge(remote, plays), stop)
+	if cfg.Catalog.Autocorrect {
+		missed = loadSongCatalog(context.Background(), apiClient(cfg), cfg).correctAll(missed)
+	}
+	if *format != "text" {
+		return writeOutput(missed)
+	}
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	fmt.Printf(tr("Since playback stopped at %s:")+"\n", stop.Time.Format("Mon 2-Jan-2006 15:04"))
+	for _, t := range missed {
+		fmt.Printf("  %s\n", trackSummary(t))
+		if url := t.streamingURL(relistenArtists); url != "" {
+			fmt.Printf("    %s\n", url)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMissedTracks(t *testing.T) {
+	var (
+		at      = func(clock string) Track { return Track{Title: clock, StartTime: mustParseDate("2024-06-01T" + clock)} }
+		history = TrackList{at("23:00:00"), at("23:20:00"), at("23:45:00"), {Title: "untimed"}}
+	)
+	tt := []struct {
+		desc string
+		stop playbackStop
+		want TrackList
+	}{
+		{
+			desc: "from the track playing when stopped",
+			stop: playbackStop{Time: mustParseDate("2024-06-01T23:30:00"), Track: playRecord(at("23:20:00"))},
+			want: history[1:],
+		},
+		{
+			desc: "stopped track not in the history",
+			stop: playbackStop{Time: mustParseDate("2024-06-01T23:10:00"), Track: playRecord(at("23:05:00"))},
+			want: TrackList{at("23:20:00"), at("23:45:00")},
+		},
+		{
+			desc: "stopped after the history",
+			stop: playbackStop{Time: mustParseDate("2024-06-02T01:00:00")},
+			want: TrackList{},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := missedTracks(history, tc.stop); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
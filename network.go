@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+)
+
+// networkConfig holds settings for reaching the station and other services
+// from networks that need a proxy, inspect TLS traffic, or have unreliable
+// DNS.
+type networkConfig struct {
+	Resolve            []string `yaml:"resolve,omitempty" doc:"Addresses to use for hosts instead of looking them up, as host:address or host:port:address, e.g. [public.radio.co:203.0.113.7]"`
+	DNS                []string `yaml:"dns,omitempty" doc:"DNS servers to look up hosts with when the system's resolver fails, e.g. [1.1.1.1, 2606:4700:4700::1111]"`
+	Proxy              string   `yaml:"proxy,omitempty" doc:"Proxy URL for all requests, e.g. http://proxy.example.com:3128 (default: from HTTPS_PROXY and HTTP_PROXY)"`
+	CAFile             string   `yaml:"ca_file,omitempty" doc:"PEM file of certificate authorities to trust in addition to the system's, such as a corporate proxy's"`
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify,omitempty" doc:"Do not verify TLS certificates; only for testing, as anyone on the network can read and change the traffic"`
+}
+
+// endpointsConfig holds the base URLs of the services ph talks to, for
+// mirrors of them, or stand-ins when testing.
+type endpointsConfig struct {
+	RadioCo   string `yaml:"radio_co,omitempty" doc:"Base URL of the radio.co API (default: https://public.radio.co)"`
+	Relisten  string `yaml:"relisten,omitempty" doc:"Base URL of the Relisten API (default: https://api.relisten.net/api/v3)"`
+	PhishNet  string `yaml:"phish_net,omitempty" doc:"Base URL of the phish.net API (default: https://api.phish.net)"`
+	SetlistFM string `yaml:"setlist_fm,omitempty" doc:"Base URL of the setlist.fm API (default: https://api.setlist.fm/rest/1.0)"`
+	Lyrics    string `yaml:"lyrics,omitempty" doc:"Base URL of a lyrics API that works like lyrics.ovh, serving {\"lyrics\": ...} at /v1/<artist>/<title> (default: https://api.lyrics.ovh)"`
+}
+
+// Base URLs of the public services.
+const (
+	defaultRadioCoEndpoint   = "https://public.radio.co"
+	defaultPhishNetEndpoint  = "https://api.phish.net"
+	defaultSetlistFMEndpoint = "https://api.setlist.fm/rest/1.0"
+	defaultLyricsEndpoint    = "https://api.lyrics.ovh"
+)
+
+func (c endpointsConfig) radioCo() string {
+	return endpointOrDefault(c.RadioCo, defaultRadioCoEndpoint)
+}
+
+func (c endpointsConfig) relisten() string {
+	return endpointOrDefault(c.Relisten, relisten.DefaultBaseURL)
+}
+
+func (c endpointsConfig) phishNet() string {
+	return endpointOrDefault(c.PhishNet, defaultPhishNetEndpoint)
+}
+
+func (c endpointsConfig) setlistFM() string {
+	return endpointOrDefault(c.SetlistFM, defaultSetlistFMEndpoint)
+}
+
+func (c endpointsConfig) lyrics() string {
+	return endpointOrDefault(c.Lyrics, defaultLyricsEndpoint)
+}
+
+func endpointOrDefault(u, def string) string {
+	if u != "" {
+		return strings.TrimSuffix(u, "/")
+	}
+	return def
+}
+
+// validate checks that each endpoint configured is an HTTP or HTTPS URL.
+func (c endpointsConfig) validate() []error {
+	var errs []error
+	for _, e := range []struct{ name, url string }{
+		{"radio_co", c.RadioCo},
+		{"relisten", c.Relisten},
+		{"phish_net", c.PhishNet},
+		{"setlist_fm", c.SetlistFM},
+		{"lyrics", c.Lyrics},
+	} {
+		if e.url == "" {
+			continue
+		}
+		if u, err := url.Parse(e.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("endpoints.%s: %q is not an HTTP or HTTPS URL", e.name, e.url))
+		}
+	}
+	return errs
+}
+
+// transport returns an HTTP transport with the proxy and TLS settings of
+// the config. Without a proxy configured, the proxy is taken from the
+// HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables.
+func (c networkConfig) transport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("network.proxy: %q is not a proxy URL", c.Proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if len(c.Resolve) > 0 || len(c.DNS) > 0 {
+		d, err := c.dialer()
+		if err != nil {
+			return nil, err
+		}
+		t.DialContext = d.DialContext
+	}
+	if c.CAFile == "" && !c.InsecureSkipVerify {
+		return t, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		b, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read network.ca_file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("network.ca_file: no PEM certificates found in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// roundTripper returns the transport for the network settings of the
+// config: the default transport if there are none, or if they have errors,
+// which loadConfig warns of.
+func (c networkConfig) roundTripper() http.RoundTripper {
+	if c.Proxy == "" && len(c.Resolve) == 0 && len(c.DNS) == 0 && c.CAFile == "" && !c.InsecureSkipVerify {
+		return http.DefaultTransport
+	}
+	t, err := c.transport()
+	if err != nil {
+		return http.DefaultTransport
+	}
+	return t
+}
+
+// httpClient returns an HTTP client with the network settings of cfg.
+func httpClient(cfg config) *http.Client {
+	return &http.Client{Transport: cfg.Network.roundTripper()}
+}
+
+// setProxyEnv sets a configured proxy in the environment, for the players
+// and other programs that ph runs, and reports any error in the network
+// settings of cfg.
+func setProxyEnv(cfg config) error {
+	if _, err := cfg.Network.transport(); err != nil {
+		return err
+	}
+	if p := cfg.Network.Proxy; p != "" {
+		os.Setenv("http_proxy", p)
+		os.Setenv("https_proxy", p)
+	}
+	return nil
+}
+
+// happyEyeballsDelay is how long to wait for a connection to one address
+// before also trying the next, as recommended by RFC 8305.
+const happyEyeballsDelay = 250 * time.Millisecond
+
+// dialer connects to hosts by the addresses given for them in a network
+// config, or else by looking them up, falling back to other DNS servers
+// if the system's resolver fails. When a host has more than one address,
+// they are tried in turn, alternating between IPv6 and IPv4, each
+// happyEyeballsDelay after the last, and the first to connect is used, so
+// that a network where one of them is broken is not much slower than one
+// where both work.
+type dialer struct {
+	net.Dialer
+	resolve  map[string][]net.IP
+	fallback []*net.Resolver
+}
+
+// dialer returns a dialer for the overrides and DNS servers of the config.
+func (c networkConfig) dialer() (*dialer, error) {
+	d := &dialer{
+		Dialer:  net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		resolve: make(map[string][]net.IP),
+	}
+	for _, r := range c.Resolve {
+		host, ip, err := parseResolve(r)
+		if err != nil {
+			return nil, err
+		}
+		d.resolve[host] = append(d.resolve[host], ip)
+	}
+	for _, server := range c.DNS {
+		addr := server
+		if net.ParseIP(server) != nil {
+			addr = net.JoinHostPort(server, "53")
+		} else if _, _, err := net.SplitHostPort(server); err != nil {
+			return nil, fmt.Errorf("network.dns: %q is not an IP address or address:port", server)
+		}
+		d.fallback = append(d.fallback, &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return d.Dialer.DialContext(ctx, network, addr)
+			},
+		})
+	}
+	return d, nil
+}
+
+// parseResolve parses an address override, such as
+// "public.radio.co:203.0.113.7", returning the host, or host and port, it
+// applies to and the address to use.
+func parseResolve(s string) (string, net.IP, error) {
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return "", nil, fmt.Errorf("network.resolve: %q must be host:address or host:port:address", s)
+	}
+	host, rest := strings.ToLower(s[:i]), s[i+1:]
+	if ip := net.ParseIP(strings.Trim(rest, "[]")); ip != nil {
+		return host, ip, nil
+	}
+	if j := strings.Index(rest, ":"); j > 0 {
+		if _, err := strconv.ParseUint(rest[:j], 10, 16); err == nil {
+			if ip := net.ParseIP(strings.Trim(rest[j+1:], "[]")); ip != nil {
+				return net.JoinHostPort(host, rest[:j]), ip, nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("network.resolve: %q must be host:address or host:port:address", s)
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := d.lookup(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+	var usable []net.IP
+	for _, ip := range ips {
+		if network == "tcp4" && ip.To4() == nil || network == "tcp6" && ip.To4() != nil {
+			continue
+		}
+		usable = append(usable, ip)
+	}
+	if len(usable) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	return d.dialAddrs(ctx, network, interleaveAddrs(usable), port)
+}
+
+// lookup returns the addresses to connect to host on port with.
+func (d *dialer) lookup(ctx context.Context, host, port string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	host = strings.ToLower(host)
+	if ips, ok := d.resolve[net.JoinHostPort(host, port)]; ok {
+		return ips, nil
+	}
+	if ips, ok := d.resolve[host]; ok {
+		return ips, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	for _, r := range d.fallback {
+		if err == nil {
+			break
+		}
+		var fallbackErr error
+		if addrs, fallbackErr = r.LookupIPAddr(ctx, host); fallbackErr == nil {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		ips[i] = a.IP
+	}
+	return ips, nil
+}
+
+// dialAddrs connects to the first of ips to accept a connection, starting
+// to dial each after the one before it fails or has not connected within
+// happyEyeballsDelay.
+func (d *dialer) dialAddrs(ctx context.Context, network string, ips []net.IP, port string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result)
+	start := func(ip net.IP) {
+		go func() {
+			conn, err := d.Dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			select {
+			case results <- result{conn, err}:
+			case <-ctx.Done():
+				if conn != nil {
+					conn.Close()
+				}
+			}
+		}()
+	}
+	var (
+		firstErr error
+		next     = 1
+		running  = 1
+	)
+	start(ips[0])
+	for running > 0 {
+		var delay <-chan time.Time
+		if next < len(ips) {
+			delay = time.After(happyEyeballsDelay)
+		}
+		select {
+		case r := <-results:
+			running--
+			if r.err == nil {
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(ips) {
+				start(ips[next])
+				next++
+				running++
+			}
+		case <-delay:
+			start(ips[next])
+			next++
+			running++
+		}
+	}
+	return nil, firstErr
+}
+
+// interleaveAddrs orders addresses to alternate between IPv6 and IPv4,
+// starting with the family of the first, as RFC 8305 recommends.
+func interleaveAddrs(ips []net.IP) []net.IP {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	first, second := v6, v4
+	if ips[0].To4() != nil {
+		first, second = v4, v6
+	}
+	ordered := make([]net.IP, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			ordered = append(ordered, first[i])
+		}
+		if i < len(second) {
+			ordered = append(ordered, second[i])
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNetworkConfig_Transport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	dir := newTestStore(t).dir
+	caFile := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, cert, 0666); err != nil {
+		t.Fatal(err)
+	}
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		desc       string
+		cfg        networkConfig
+		wantErr    bool
+		wantVerify bool
+	}{
+		{desc: "system CAs only", cfg: networkConfig{}},
+		{desc: "custom CA", cfg: networkConfig{CAFile: caFile}, wantVerify: true},
+		{desc: "skip verify", cfg: networkConfig{InsecureSkipVerify: true}, wantVerify: true},
+		{desc: "missing CA file", cfg: networkConfig{CAFile: filepath.Join(dir, "missing.pem")}, wantErr: true},
+		{desc: "CA file without certificates", cfg: networkConfig{CAFile: notPEM}, wantErr: true},
+		{desc: "invalid proxy", cfg: networkConfig{Proxy: "proxy"}, wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			transport, err := tc.cfg.transport()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("wanted error %v, but got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if got := err == nil; got != tc.wantVerify {
+				t.Errorf("wanted request to succeed %v, but got error %v", tc.wantVerify, err)
+			}
+		})
+	}
+}
+
+func TestNetworkConfig_TransportProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("ok"))
+	}))
+	defer proxy.Close()
+
+	transport, err := networkConfig{Proxy: proxy.URL}.transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get("http://public.radio.co/stations/x/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := "http://public.radio.co/stations/x/status"; proxied != want {
+		t.Errorf("wanted %q, but got %q", want, proxied)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("ok"))
+	}))
+	defer proxy.Close()
+
+	var cfg config
+	cfg.Network.Proxy = proxy.URL
+	resp, err := httpClient(cfg).Get("http://public.radio.co/stations/x/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := "http://public.radio.co/stations/x/status"; proxied != want {
+		t.Errorf("wanted %q, but got %q", want, proxied)
+	}
+	if (config{}).Network.roundTripper() != http.DefaultTransport {
+		t.Errorf("wanted the default transport without network settings")
+	}
+	cfg.Network.Proxy = "proxy"
+	if cfg.Network.roundTripper() != http.DefaultTransport {
+		t.Errorf("wanted the default transport with an invalid proxy")
+	}
+}
+
+func TestParseResolve(t *testing.T) {
+	tt := []struct {
+		in       string
+		wantHost string
+		wantIP   string
+		wantErr  bool
+	}{
+		{in: "public.radio.co:203.0.113.7", wantHost: "public.radio.co", wantIP: "203.0.113.7"},
+		{in: "Public.Radio.co:2001:db8::7", wantHost: "public.radio.co", wantIP: "2001:db8::7"},
+		{in: "public.radio.co:[2001:db8::7]", wantHost: "public.radio.co", wantIP: "2001:db8::7"},
+		{in: "public.radio.co:443:203.0.113.7", wantHost: "public.radio.co:443", wantIP: "203.0.113.7"},
+		{in: "public.radio.co:443:[2001:db8::7]", wantHost: "public.radio.co:443", wantIP: "2001:db8::7"},
+		{in: "public.radio.co", wantErr: true},
+		{in: "public.radio.co:localhost", wantErr: true},
+		{in: ":203.0.113.7", wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			host, ip, err := parseResolve(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("wanted error %v, but got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if host != tc.wantHost || ip.String() != tc.wantIP {
+				t.Errorf("wanted %s %s, but got %s %s", tc.wantHost, tc.wantIP, host, ip)
+			}
+		})
+	}
+}
+
+func TestInterleaveAddrs(t *testing.T) {
+	var (
+		ips  = parseIPs("2001:db8::1", "2001:db8::2", "192.0.2.1", "192.0.2.2", "192.0.2.3")
+		want = parseIPs("2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2", "192.0.2.3")
+	)
+	if got := interleaveAddrs(ips); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+}
+
+func TestDialer_Resolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	// Nothing listens on 127.0.0.2, so the dialer must fall back to the
+	// address that works.
+	transport, err := networkConfig{Resolve: []string{"station.invalid:127.0.0.2", "station.invalid:127.0.0.1"}}.transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get("http://station.invalid:" + port + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if want := "station.invalid:" + port; string(b) != want {
+		t.Errorf("wanted %q, but got %q", want, b)
+	}
+}
+
+func parseIPs(addrs ...string) []net.IP {
+	ips := make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		ips[i] = net.ParseIP(a)
+	}
+	return ips
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// What about a track is new to the user, as set in Track.New.
+const (
+	newArtist = "artist"
+	newSong   = "song"
+)
+
+// newMarker marks tracks that are new to the user in text output.
+const newMarker = "✨"
+
+// heardBefore records when each artist and song first appears in the play
+// history, to tell which tracks are new to the user.
+type heardBefore struct {
+	artists map[string]time.Time
+	songs   map[TrackKey]time.Time
+}
+
+func newHeardBefore(plays TrackList) heardBefore {
+	h := heardBefore{artists: make(map[string]time.Time), songs: make(map[TrackKey]time.Time)}
+	for _, t := range plays {
+		h.add(t)
+	}
+	return h
+}
+
+// add records that t aired.
+func (h heardBefore) add(t Track) {
+	if t.Kind() == trackKindStationBreak || h.artists == nil {
+		return
+	}
+	k := t.Key()
+	if at, ok := h.artists[k.Artist]; !ok || t.StartTime.Before(at) {
+		h.artists[k.Artist] = t.StartTime
+	}
+	// A full show's title is its date and set, which says nothing about
+	// whether its songs are familiar.
+	if at, ok := h.songs[k.Song()]; !t.IsFullShow() && (!ok || t.StartTime.Before(at)) {
+		h.songs[k.Song()] = t.StartTime
+	}
+}
+
+// novelty returns newArtist if t's artist had not aired before t, newSong
+// if its song had not, and otherwise the empty string. A track without a
+// start time is new only if it has never aired.
+func (h heardBefore) novelty(t Track) string {
+	if t.Kind() == trackKindStationBreak || h.artists == nil {
+		return ""
+	}
+	heard := func(at time.Time, ok bool) bool {
+		return ok && (t.StartTime.IsZero() || at.Before(t.StartTime))
+	}
+	k := t.Key()
+	if at, ok := h.artists[k.Artist]; !heard(at, ok) {
+		return newArtist
+	}
+	if at, ok := h.songs[k.Song()]; !t.IsFullShow() && !heard(at, ok) {
+		return newSong
+	}
+	return ""
+}
+
+// mark sets what is new to the user about each track.
+func (h heardBefore) mark(tl TrackList) TrackList {
+	return tl.Map(func(t Track) Track {
+		t.New = h.novelty(t)
+		return t
+	})
+}
+
+// loadHeardBefore reads the play history from st, which may be nil, to
+// tell which tracks are new. Without a history, no track is marked new,
+// since every one would be.
+func loadHeardBefore(st *store) heardBefore {
+	if st == nil {
+		return heardBefore{}
+	}
+	plays, err := st.plays()
+	if err != nil {
+		warn(fmt.Errorf("unable to read play history: %w", err))
+		return heardBefore{}
+	}
+	if len(plays) == 0 {
+		return heardBefore{}
+	}
+	return newHeardBefore(plays)
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestHeardBefore_Novelty(t *testing.T) {
+	heard := newHeardBefore(TrackList{
+		{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2024-06-01T20:00:00")},
+		{Artist: "Goose", Title: "Arcadia", StartTime: mustParseDate("2024-06-02T20:00:00")},
+		{Artist: "jempradio.com", Title: "Station ID", StartTime: mustParseDate("2024-06-02T21:00:00")},
+	})
+	tt := []struct {
+		desc  string
+		track Track
+		want  string
+	}{
+		{
+			desc:  "heard before",
+			track: Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2024-06-03T20:00:00")},
+		},
+		{
+			desc:  "ignores case",
+			track: Track{Artist: "goose", Title: "ARCADIA", StartTime: mustParseDate("2024-06-03T20:00:00")},
+		},
+		{
+			desc:  "new song",
+			track: Track{Artist: "Phish", Title: "Harry Hood", StartTime: mustParseDate("2024-06-03T20:00:00")},
+			want:  newSong,
+		},
+		{
+			desc:  "new artist",
+			track: Track{Artist: "Billy Strings", Title: "Dust in a Baggie", StartTime: mustParseDate("2024-06-03T20:00:00")},
+			want:  newArtist,
+		},
+		{
+			desc:  "first airing is new",
+			track: Track{Artist: "Goose", Title: "Arcadia", StartTime: mustParseDate("2024-06-02T20:00:00")},
+			want:  newArtist,
+		},
+		{
+			desc:  "no start time, heard",
+			track: Track{Artist: "Phish", Title: "Reba"},
+		},
+		{
+			desc:  "no start time, never heard",
+			track: Track{Artist: "Phish", Title: "Tweezer"},
+			want:  newSong,
+		},
+		{
+			desc:  "full show by an artist heard before",
+			track: Track{Artist: "Phish", Title: "22-Nov-1997 Set 2", Set: &ShowSet{Number: 2}, StartTime: mustParseDate("2024-06-03T20:00:00")},
+		},
+		{
+			desc:  "station break",
+			track: Track{Artist: "jempradio.com", Title: "Station ID", StartTime: mustParseDate("2024-06-01T19:00:00")},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := heard.novelty(tc.track); got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestHeardBefore_NoHistory(t *testing.T) {
+	// Without a play history, every track would be new, so none is marked.
+	var heard heardBefore
+	heard.add(Track{Artist: "Phish", Title: "Reba"})
+	if got := heard.novelty(Track{Artist: "Phish", Title: "Reba"}); got != "" {
+		t.Errorf("wanted no novelty, but got %q", got)
+	}
+}
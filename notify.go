@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// notifyConfig configures where ph sends notifications, such as listening
+// digests.
+type notifyConfig struct {
+	Webhook string      `yaml:"webhook,omitempty" doc:"URL to POST notifications to as JSON ({\"subject\": ..., \"text\": ...})"`
+	Plugins []string    `yaml:"plugins,omitempty" doc:"Plugins that deliver notifications"`
+	Email   emailConfig `yaml:"email,omitempty" doc:"Email notifications, sent through an SMTP server"`
+	DND     []string    `yaml:"dnd,omitempty" doc:"Times not to send notifications, in local time, as days start-end, e.g. [\"daily 23:00-07:00\"]; ph dnd also snoozes them for a while"`
+}
+
+// notification is a message for the user, such as a listening digest.
+type notification struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+
+	// PlayID is the ID of the play the notification is about, if any, as
+	// served at /track/{id} by ph serve.
+	PlayID string `json:"play_id,omitempty"`
+}
+
+// notifier delivers notifications somewhere the user will see them.
+type notifier interface {
+	notify(n notification) error
+}
+
+// notifiers returns a notifier for each destination in the config, each
+// respecting do not disturb, and recording what it delivers in the audit
+// log.
+func (c notifyConfig) notifiers(client *http.Client) []notifier {
+	var (
+		ns    []notifier
+		audit = openAuditLog()
+	)
+	if c.Webhook != "" {
+		ns = append(ns, auditedNotifier{webhookNotifier{client: client, url: c.Webhook}, audit, "webhook", urlHost(c.Webhook)})
+	}
+	if c.Email.Server != "" {
+		ns = append(ns, auditedNotifier{newEmailNotifier(c.Email), audit, "email", strings.Join(c.Email.To, ", ")})
+	}
+	for _, name := range c.Plugins {
+		ns = append(ns, auditedNotifier{pluginNotifier{name: name}, audit, "plugin", name})
+	}
+	for i, n := range ns {
+		ns[i] = c.quiet(n)
+	}
+	return ns
+}
+
+// webhookNotifier posts notifications as JSON to a URL.
+type webhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (w webhookNotifier) notify(n notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post notification: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("post notification: %s", resp.Status)
+	}
+	return nil
+}
+
+// pluginNotifier delivers notifications by invoking a plugin at the
+// "notify" extension point, with the notification as JSON on standard
+// input.
+type pluginNotifier struct {
+	name string
+}
+
+func (p pluginNotifier) notify(n notification) error {
+	_, err := callPlugin(p.name, "notify", n)
+	return err
+}
+
+// sendNotification delivers n with each notifier. A failing notifier does
+// not prevent delivery by the others.
+func sendNotification(ns []notifier, n notification) error {
+	var errs []string
+	for _, nt := range ns {
+		if err := nt.notify(n); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
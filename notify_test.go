@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var got notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("wanted content type application/json, but got %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("unable to decode notification: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	want := notification{Subject: "JEMP Radio digest", Text: "3 plays"}
+	if err := (webhookNotifier{client: srv.Client(), url: srv.URL}).notify(want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("wanted %+v, but got %+v", want, got)
+	}
+}
+
+func TestWebhookNotifier_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := (webhookNotifier{client: srv.Client(), url: srv.URL}).notify(notification{})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("wanted a 403 error, but got %v", err)
+	}
+}
+
+func TestPluginNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-notify")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// Keep a snooze in the user's data directory from holding the
+	// notification.
+	defer os.Setenv("XDG_DATA_HOME", os.Getenv("XDG_DATA_HOME"))
+	os.Setenv("XDG_DATA_HOME", dir)
+	out := filepath.Join(dir, "notification.json")
+	installTestPlugin(t, "testnotify", `
+[ "$1" = notify ] || exit 1
+cat > `+out+`
+`)
+	n := notification{Subject: "JEMP Radio digest", Text: "3 plays"}
+	ns := notifyConfig{Plugins: []string{"testnotify"}}.notifiers(http.DefaultClient)
+	if err := sendNotification(ns, n); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("plugin did not write its input: %v", err)
+	}
+	var got notification
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unable to decode plugin input: %v", err)
+	}
+	if got != n {
+		t.Errorf("wanted %+v, but got %+v", n, got)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// defaultNowContext is how many of the tracks before the current one ph now
+// shows.
+const defaultNowContext = 3
+
+// nowView is what ph now shows: the track airing now, and those that aired
+// before it, most recent first.
+type nowView struct {
+	Playing *Track    `json:"current_track,omitempty" yaml:"current_track,omitempty"`
+	Recent  TrackList `json:"recent" yaml:"recent"`
+
+	// links are the links to the recent tracks' shows, for text output.
+	links map[TrackID][]Link
+}
+
+// newNowView builds the view of status with up to n of the tracks before
+// the current one that keep selects. The current track is shown whatever
+// it is, unless the station is offline.
+func newNowView(status statusResponseBody, n int, keep func(Track) bool) nowView {
+	var v nowView
+	recent := status.tracks()
+	if t := status.CurrentTrack; t.Title != "" && status.Status != "offline" {
+		v.Playing = &t
+	}
+	if status.CurrentTrack.Title != "" {
+		recent = recent[1:]
+	}
+	v.Recent = recent.Filter(keep).Take(n)
+	return v
+}
+
+// markJamCharts marks the tracks of the view on the jam charts.
+func (v *nowView) markJamCharts(ctx context.Context, jc *jamCharts) {
+	tl := v.Recent
+	if v.Playing != nil {
+		tl = append(TrackList{*v.Playing}, tl...)
+	}
+	tl = jc.mark(ctx, tl)
+	if v.Playing != nil {
+		v.Playing, tl = &tl[0], tl[1:]
+	}
+	v.Recent = tl
+}
+
+func (v nowView) String() string {
+	return v.text(config{})
+}
+
+// text is String with the output settings of cfg.
+func (v nowView) text(cfg config) string {
+	var b strings.Builder
+	if v.Playing != nil {
+		fmt.Fprintf(&b, "%s %s\n", tr("Now playing:"), v.Playing.text(cfg))
+	} else {
+		b.WriteString(tr("Nothing is playing.") + "\n")
+	}
+	if len(v.Recent) > 0 {
+		layout := defaultTableLayout
+		layout.elapsed = cfg.elapsedFormat()
+		fmt.Fprintf(&b, "\n%s\n%s", tr("Before that:"), v.Recent.table(v.links, layout))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// nowFields are the fields ph now --field can print.
+var nowFields = []string{"artist", "title", "url", "elapsed"}
+
+// nowField is the raw value of field of the track t, with nothing around
+// it for scripts to strip: its artist, title, how many seconds ago it
+// started, or a URL to listen to it, which is the link to stream its show
+// if there is one, and otherwise listen, the station's stream. A value the
+// track does not have is empty.
+func nowField(field string, t Track, links []Link, listen string) string {
+	switch field {
+	case "artist":
+		return t.Artist
+	case "title":
+		return t.Title
+	case "url":
+		if u := linkURL(links, linkKindStream); u != "" {
+			return u
+		}
+		return listen
+	case "elapsed":
+		if t.StartTime.IsZero() {
+			return ""
+		}
+		return strconv.Itoa(int(t.Elapsed().Seconds()))
+	}
+	return ""
+}
+
+// runNow shows the current track along with the tracks that aired before
+// it, for context.
+func runNow(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs        = flag.NewFlagSet("now", flag.ExitOnError)
+		n         = fs.UintP("context", "c", defaultNowContext, "Show this many of the tracks before the current one")
+		format    = fs.StringP("format", "f", cfg.format(), "Output format (text, json, yaml, plain, alfred, or widget for home screen widgets)")
+		breaks    = fs.Bool("include-breaks", cfg.StationBreaks, "Include station breaks among the tracks before the current one")
+		precision = fs.String("elapsed-precision", cfg.elapsedPrecision(), "Truncate how long ago tracks started to the minute or second")
+		jamCharts = fs.Bool("jam-charts", false, "Mark tracks on the phish.net jam charts (needs catalog.phishnet_api_key)")
+		field     = fs.String("field", "", "Print only this field of the current track: "+strings.Join(nowFields, ", "))
+	)
+	fs.BoolVar(breaks, "breaks", cfg.StationBreaks, "Same as --include-breaks")
+	fs.MarkHidden("breaks")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ph now [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *field != "" && !containsString(nowFields, *field) {
+		return fmt.Errorf("invalid field %q: use one of %s", *field, strings.Join(nowFields, ", "))
+	}
+	if _, err := parseElapsedPrecision(*precision); err != nil {
+		return err
+	}
+	cfg.ElapsedPrecision = *precision
+	// The widget format has a layout of its own, rather than a renderer.
+	asWidget := *format == "widget"
+	writeOutput := func(v interface{}) error { return json.NewEncoder(os.Stdout).Encode(v) }
+	if !asWidget {
+		writeOutput, err = getRenderer(*format, useColor(cfg, os.Stdout), cfg)
+		if err != nil {
+			return err
+		}
+	}
+	var kinds []string
+	if *breaks {
+		kinds = trackKinds
+	}
+	keep, err := historyFilter(nil, kinds, "", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	rc := newRelistenClient(cfg)
+	relistenArtists, err = relistenGetArtists(rc, cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+	if err != nil {
+		return err
+	}
+	status = observeStatus(cfg, status)
+	if asWidget || *field != "" {
+		var links []Link
+		if ls, err := (&LinkResolver{Relisten: rc, cfg: cfg}).ResolveLinks(context.Background(), TrackList{status.CurrentTrack}); err == nil {
+			links = ls[status.CurrentTrack.ID()]
+		}
+		listen := newStationReport(cfg.station(), cfg.Play.StreamURL, status).StreamURL
+		if *field != "" {
+			var t Track
+			if status.Status != "offline" {
+				t = status.CurrentTrack
+			}
+			_, err := fmt.Println(nowField(*field, t, links, listen))
+			return err
+		}
+		return writeOutput(newWidget(status, links, listen, time.Now()))
+	}
+	v := newNowView(status, int(*n), keep)
+	if v.Playing != nil {
+		t := withShowPosition(context.Background(), rc, relistenArtists, *v.Playing)
+		v.Playing = &t
+	}
+	if *jamCharts {
+		v.markJamCharts(context.Background(), newJamCharts(apiClient(cfg), cfg))
+	}
+	if *format == "text" {
+		// Only link to the shows that are on Relisten.
+		v.links, err = (&LinkResolver{Relisten: rc, cfg: cfg}).ResolveLinks(context.Background(), v.Recent)
+		if err != nil {
+			warn(fmt.Errorf("unable to check links: %w", err))
+		}
+	}
+	return writeOutput(v)
+}
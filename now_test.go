@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewNowView(t *testing.T) {
+	var (
+		reba    = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-07-04T18:00:00")}
+		tweezer = Track{Artist: "Phish", Title: "Tweezer Reprise"}
+		station = Track{Artist: "jempradio.com", Title: "Station ID"}
+		arcadia = Track{Artist: "Goose", Title: "Arcadia"}
+		history = TrackList{{Artist: "Phish", Title: "Reba"}, station, tweezer, arcadia}
+		music   = Not(ByKind(trackKindStationBreak))
+	)
+	tt := []struct {
+		desc        string
+		status      statusResponseBody
+		n           int
+		keep        func(Track) bool
+		wantPlaying *Track
+		wantRecent  TrackList
+	}{
+		{
+			desc:        "current track and context",
+			status:      statusResponseBody{Status: "online", CurrentTrack: reba, History: history},
+			n:           2,
+			keep:        music,
+			wantPlaying: &reba,
+			wantRecent:  TrackList{tweezer, arcadia},
+		},
+		{
+			desc:        "station breaks",
+			status:      statusResponseBody{Status: "online", CurrentTrack: reba, History: history},
+			n:           2,
+			keep:        ByKind(trackKinds...),
+			wantPlaying: &reba,
+			wantRecent:  TrackList{station, tweezer},
+		},
+		{
+			desc:        "station break airing",
+			status:      statusResponseBody{Status: "online", CurrentTrack: station, History: TrackList{station, reba}},
+			n:           3,
+			keep:        music,
+			wantPlaying: &station,
+			wantRecent:  TrackList{reba},
+		},
+		{
+			desc:       "offline",
+			status:     statusResponseBody{Status: "offline", CurrentTrack: reba, History: history},
+			n:          1,
+			keep:       music,
+			wantRecent: TrackList{tweezer},
+		},
+		{
+			desc:        "no context",
+			status:      statusResponseBody{Status: "online", CurrentTrack: reba, History: history},
+			keep:        music,
+			wantPlaying: &reba,
+			wantRecent:  TrackList{},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			v := newNowView(tc.status, tc.n, tc.keep)
+			if !reflect.DeepEqual(v.Playing, tc.wantPlaying) {
+				t.Errorf("wanted playing %v, but got %v", tc.wantPlaying, v.Playing)
+			}
+			if !reflect.DeepEqual(v.Recent, tc.wantRecent) {
+				t.Errorf("wanted recent %v, but got %v", tc.wantRecent, v.Recent)
+			}
+		})
+	}
+}
+
+func TestNowView_String(t *testing.T) {
+	v := nowView{
+		Playing: &Track{Artist: "Phish", Title: "Reba", Position: &showPosition{Set: "Set 2", Track: 4, SetTracks: 8}},
+		Recent:  TrackList{{Artist: "Goose", Title: "Arcadia"}},
+	}
+	got := v.String()
+	for _, want := range []string{"Now playing: Phish - Reba\nPosition in show: Set 2, track 4 of 8\n", "\nBefore that:\n", "1 Goose"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("wanted %q in %q", want, got)
+		}
+	}
+	if got := (nowView{}).String(); got != "Nothing is playing." {
+		t.Errorf("wanted %q, but got %q", "Nothing is playing.", got)
+	}
+
+	var b bytes.Buffer
+	if err := writePlain(&b, v, config{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "Current track: Phish - Reba\n"; !strings.HasPrefix(b.String(), want) {
+		t.Errorf("wanted plain output to start with %q, but got %q", want, b.String())
+	}
+}
+
+func TestNowField(t *testing.T) {
+	useFakeClock(t, mustParseDate("2020-06-05T20:04:12"))
+	var (
+		listen = "https://stream.radio.co/sd71de59b3/listen"
+		reba   = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:00:00")}
+		links  = []Link{
+			{Kind: linkKindStream, Service: "Relisten", URL: "https://relisten.net/phish/1997/11/22"},
+			{Kind: linkKindSetlist, Service: "phish.net", URL: "https://phish.net/setlists/?d=1997-11-22"},
+		}
+	)
+	tt := []struct {
+		desc  string
+		field string
+		track Track
+		links []Link
+		want  string
+	}{
+		{desc: "artist", field: "artist", track: reba, want: "Phish"},
+		{desc: "title", field: "title", track: reba, want: "Reba"},
+		{desc: "elapsed", field: "elapsed", track: reba, want: "252"},
+		{desc: "elapsed without a start time", field: "elapsed", track: Track{Title: "Reba"}, want: ""},
+		{desc: "url to the show", field: "url", track: reba, links: links, want: "https://relisten.net/phish/1997/11/22"},
+		{desc: "url to the station", field: "url", track: reba, want: listen},
+		{desc: "nothing playing", field: "title", want: ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := nowField(tc.field, tc.track, tc.links, listen); got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
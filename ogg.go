@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// oggPage is a page of an Ogg stream, the container for Vorbis and Opus
+// audio.
+type oggPage struct {
+	HeaderType byte
+	Granule    uint64
+	Serial     uint32
+	Sequence   uint32
+	Segments   []byte // the lacing values, each the length of a segment
+	Data       []byte
+}
+
+const (
+	oggContinued = 0x01 // the page begins with the rest of a packet
+	oggBOS       = 0x02 // the first page of a stream
+)
+
+// oggCRCTable is for the CRC-32 of Ogg pages, which, unlike the more common
+// CRC-32, is not bit-reflected.
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+func oggCRC(b []byte) uint32 {
+	var crc uint32
+	for _, c := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^c]
+	}
+	return crc
+}
+
+// parseOggPages splits an Ogg stream into its pages.
+func parseOggPages(b []byte) ([]oggPage, error) {
+	var pages []oggPage
+	for len(b) > 0 {
+		if len(b) < 27 || string(b[:4]) != "OggS" {
+			return nil, errors.New("not an Ogg file")
+		}
+		n := int(b[26])
+		if len(b) < 27+n {
+			return nil, errors.New("truncated Ogg page")
+		}
+		p := oggPage{
+			HeaderType: b[5],
+			Granule:    binary.LittleEndian.Uint64(b[6:]),
+			Serial:     binary.LittleEndian.Uint32(b[14:]),
+			Sequence:   binary.LittleEndian.Uint32(b[18:]),
+			Segments:   b[27 : 27+n],
+		}
+		size := 0
+		for _, s := range p.Segments {
+			size += int(s)
+		}
+		if len(b) < 27+n+size {
+			return nil, errors.New("truncated Ogg page")
+		}
+		p.Data = b[27+n : 27+n+size]
+		pages = append(pages, p)
+		b = b[27+n+size:]
+	}
+	return pages, nil
+}
+
+// bytes encodes the page, with its checksum.
+func (p oggPage) bytes() []byte {
+	b := make([]byte, 27, 27+len(p.Segments)+len(p.Data))
+	copy(b, "OggS")
+	b[5] = p.HeaderType
+	binary.LittleEndian.PutUint64(b[6:], p.Granule)
+	binary.LittleEndian.PutUint32(b[14:], p.Serial)
+	binary.LittleEndian.PutUint32(b[18:], p.Sequence)
+	b[26] = byte(len(p.Segments))
+	b = append(b, p.Segments...)
+	b = append(b, p.Data...)
+	binary.LittleEndian.PutUint32(b[22:], oggCRC(b))
+	return b
+}
+
+// oggHeaderPackets reads the first n packets of a stream from its pages,
+// returning them with the number of pages they take. The header packets of
+// Vorbis and Opus end at a page boundary, so the pages after them hold
+// only audio.
+func oggHeaderPackets(pages []oggPage, n int) ([][]byte, int, error) {
+	var (
+		packets [][]byte
+		packet  []byte
+	)
+	for i, p := range pages {
+		if p.Serial != pages[0].Serial {
+			return nil, 0, errors.New("Ogg files with more than one stream are not supported")
+		}
+		data := p.Data
+		for _, s := range p.Segments {
+			packet, data = append(packet, data[:s]...), data[s:]
+			if s < 255 {
+				packets, packet = append(packets, packet), nil
+			}
+		}
+		if len(packets) >= n {
+			if len(packets) > n || packet != nil {
+				return nil, 0, errors.New("audio shares a page with the Ogg headers")
+			}
+			return packets, i + 1, nil
+		}
+	}
+	return nil, 0, errors.New("truncated Ogg headers")
+}
+
+// oggPaginate lays packets out in pages, beginning with the given
+// sequence number, with the first packet on a page of its own if
+// firstAlone is true.
+func oggPaginate(packets [][]byte, serial, sequence uint32, firstAlone bool) []oggPage {
+	var (
+		pages []oggPage
+		page  = oggPage{Serial: serial, Sequence: sequence}
+		flush = func() {
+			pages = append(pages, page)
+			page = oggPage{Serial: serial, Sequence: page.Sequence + 1}
+		}
+	)
+	for i, packet := range packets {
+		for {
+			if len(page.Segments) == 255 {
+				flush()
+			}
+			n := len(packet)
+			if n > 255 {
+				n = 255
+			}
+			page.Segments = append(page.Segments, byte(n))
+			page.Data, packet = append(page.Data, packet[:n]...), packet[n:]
+			if n < 255 {
+				break
+			}
+		}
+		if (i == 0 && firstAlone) || i == len(packets)-1 {
+			flush()
+		}
+	}
+	// Header pages have a granule position of 0, except that one on which
+	// no packet ends has none, marked as -1. A page that begins with the
+	// rest of a packet is flagged as continued.
+	for i := range pages {
+		p := &pages[i]
+		p.Granule = ^uint64(0)
+		for _, s := range p.Segments {
+			if s < 255 {
+				p.Granule = 0
+				break
+			}
+		}
+		if i > 0 {
+			prev := pages[i-1].Segments
+			if prev[len(prev)-1] == 255 {
+				p.HeaderType |= oggContinued
+			}
+		}
+	}
+	if len(pages) > 0 && sequence == 0 {
+		pages[0].HeaderType |= oggBOS
+	}
+	return pages
+}
+
+// oggCodec describes how a codec stores its comments in an Ogg stream.
+type oggCodec struct {
+	// magic starts the identification packet, and comment starts the
+	// comment packet.
+	magic, comment string
+
+	// headers is the number of header packets.
+	headers int
+
+	// framing is whether the comment packet ends with a framing bit.
+	framing bool
+}
+
+var oggCodecs = []oggCodec{
+	{magic: "\x01vorbis", comment: "\x03vorbis", headers: 3, framing: true},
+	{magic: "OpusHead", comment: "OpusTags", headers: 2},
+}
+
+// parseVorbisComments parses the body of a comment packet, after its
+// codec's prefix, returning the vendor string and the comments.
+func parseVorbisComments(b []byte) (string, []string, error) {
+	next := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(b)
+		if uint64(len(b)-4) < uint64(n) {
+			return "", false
+		}
+		s := string(b[4 : 4+n])
+		b = b[4+n:]
+		return s, true
+	}
+	vendor, ok := next()
+	if !ok || len(b) < 4 {
+		return "", nil, errors.New("invalid comment header")
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	var comments []string
+	for i := uint32(0); i < count; i++ {
+		c, ok := next()
+		if !ok {
+			return "", nil, errors.New("invalid comment header")
+		}
+		comments = append(comments, c)
+	}
+	return vendor, comments, nil
+}
+
+// vorbisCommentsPacket encodes a comment packet.
+func vorbisCommentsPacket(codec oggCodec, vendor string, comments []string) []byte {
+	var b bytes.Buffer
+	b.WriteString(codec.comment)
+	binary.Write(&b, binary.LittleEndian, uint32(len(vendor)))
+	b.WriteString(vendor)
+	binary.Write(&b, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&b, binary.LittleEndian, uint32(len(c)))
+		b.WriteString(c)
+	}
+	if codec.framing {
+		b.WriteByte(1)
+	}
+	return b.Bytes()
+}
+
+// trackVorbisComments returns the Vorbis comments describing t, which Opus
+// uses as well. Comments with no value are left out.
+func trackVorbisComments(t Track) []string {
+	info := newNowPlayingInfo(t, false)
+	fields := [][2]string{
+		{"TITLE", info.Title},
+		{"ARTIST", info.Artist},
+		{"ALBUM", info.Album},
+		{"LOCATION", t.Location},
+		{"SET", t.Set.String()},
+	}
+	if pt := t.PerformanceTime; !pt.IsZero() {
+		fields = append(fields, [2]string{"DATE", pt.Format("2006-01-02")})
+	}
+	var comments []string
+	for _, f := range fields {
+		if f[1] != "" {
+			comments = append(comments, f[0]+"="+f[1])
+		}
+	}
+	return comments
+}
+
+// retagOgg returns an Ogg Vorbis or Opus file with comments replacing any
+// it has for the same fields. Other comments are kept.
+func retagOgg(b []byte, comments []string) ([]byte, error) {
+	pages, err := parseOggPages(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("empty Ogg file")
+	}
+	var codec oggCodec
+	for _, c := range oggCodecs {
+		if bytes.HasPrefix(pages[0].Data, []byte(c.magic)) {
+			codec = c
+		}
+	}
+	if codec.headers == 0 {
+		return nil, errors.New("only Vorbis and Opus in Ogg files are supported")
+	}
+	packets, headerPages, err := oggHeaderPackets(pages, codec.headers)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(packets[1], []byte(codec.comment)) {
+		return nil, errors.New("missing comment header")
+	}
+	vendor, old, err := parseVorbisComments(packets[1][len(codec.comment):])
+	if err != nil {
+		return nil, err
+	}
+	replaced := make(map[string]bool, len(comments))
+	for _, c := range comments {
+		replaced[strings.ToUpper(c[:strings.IndexByte(c+"=", '=')])] = true
+	}
+	var kept []string
+	for _, c := range old {
+		if !replaced[strings.ToUpper(c[:strings.IndexByte(c+"=", '=')])] {
+			kept = append(kept, c)
+		}
+	}
+	packets[1] = vorbisCommentsPacket(codec, vendor, append(kept, comments...))
+
+	var (
+		out    bytes.Buffer
+		serial = pages[0].Serial
+		header = oggPaginate(packets, serial, 0, true)
+	)
+	for _, p := range header {
+		out.Write(p.bytes())
+	}
+	// The pages after the headers are renumbered to follow the new ones.
+	for i, p := range pages[headerPages:] {
+		p.Sequence = uint32(len(header) + i)
+		out.Write(p.bytes())
+	}
+	return out.Bytes(), nil
+}
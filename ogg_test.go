@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testOggFile builds an Ogg Vorbis file with the given comments and a page
+// of audio.
+func testOggFile(comments []string) []byte {
+	codec := oggCodecs[0]
+	packets := [][]byte{
+		[]byte(codec.magic + "ident"),
+		vorbisCommentsPacket(codec, "test vendor", comments),
+		[]byte("\x05vorbis" + strings.Repeat("setup", 100)),
+	}
+	var b bytes.Buffer
+	header := oggPaginate(packets, 7, 0, true)
+	for _, p := range header {
+		b.Write(p.bytes())
+	}
+	audio := oggPage{Granule: 4096, Serial: 7, Sequence: uint32(len(header)), Segments: []byte{5}, Data: []byte("audio")}
+	b.Write(audio.bytes())
+	return b.Bytes()
+}
+
+func TestRetagOgg(t *testing.T) {
+	tt := []struct {
+		desc     string
+		comments []string
+	}{
+		{desc: "short comments", comments: []string{"TITLE=old", "comment=keep me"}},
+		{desc: "comments spanning pages", comments: []string{"TITLE=old", "comment=keep me", "PADDING=" + strings.Repeat("x", 70000)}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := retagOgg(testOggFile(tc.comments), []string{"TITLE=Reba", "ARTIST=Phish"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			pages, err := parseOggPages(got)
+			if err != nil {
+				t.Fatalf("unable to parse retagged file: %v", err)
+			}
+			// Encoding a page computes its checksum, so the file has valid
+			// checksums if encoding its pages again gives the same bytes.
+			var encoded []byte
+			for i, p := range pages {
+				if p.Sequence != uint32(i) {
+					t.Errorf("wanted page %d to have sequence number %d, but got %d", i, i, p.Sequence)
+				}
+				encoded = append(encoded, p.bytes()...)
+			}
+			if !bytes.Equal(encoded, got) {
+				t.Error("wanted every page to have a valid checksum")
+			}
+			if last := pages[len(pages)-1]; string(last.Data) != "audio" || last.Granule != 4096 {
+				t.Errorf("wanted the audio page unchanged, but got %q at granule %d", last.Data, last.Granule)
+			}
+			for field, want := range map[string]string{"TITLE": "Reba", "ARTIST": "Phish", "COMMENT": "keep me"} {
+				if got, err := oggComment(got, field); err != nil || got != want {
+					t.Errorf("%s: wanted %q, but got %q (%v)", field, want, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRetagOggInvalid(t *testing.T) {
+	if _, err := retagOgg([]byte("ID3 not an ogg file at all, no sir"), nil); err == nil {
+		t.Error("wanted an error for a file that is not Ogg, but got none")
+	}
+	speex := oggPage{Serial: 1, Segments: []byte{8}, Data: []byte("Speex   ")}
+	if _, err := retagOgg(speex.bytes(), nil); err == nil {
+		t.Error("wanted an error for an unsupported codec, but got none")
+	}
+}
+
+// oggComment returns the value of a comment in an Ogg file, or "" if there
+// is none.
+func oggComment(b []byte, field string) (string, error) {
+	pages, err := parseOggPages(b)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range oggCodecs {
+		if len(pages) == 0 || !bytes.HasPrefix(pages[0].Data, []byte(c.magic)) {
+			continue
+		}
+		packets, _, err := oggHeaderPackets(pages, c.headers)
+		if err != nil {
+			return "", err
+		}
+		_, comments, err := parseVorbisComments(packets[1][len(c.comment):])
+		if err != nil {
+			return "", err
+		}
+		for _, cm := range comments {
+			if strings.HasPrefix(strings.ToUpper(cm), field+"=") {
+				return cm[len(field)+1:], nil
+			}
+		}
+		return "", nil
+	}
+	return "", errors.New("unsupported Ogg codec")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	defaultOverlayTemplate = `{{if .Artist}}{{.Artist}} - {{end}}{{.Title}}{{if .Date}} ({{.Date}}){{end}}`
+	defaultOverlayDebounce = 2 * time.Second
+)
+
+// overlayConfig holds settings for ph overlay, which keeps a file up to
+// date with the current track for streaming software such as OBS.
+type overlayConfig struct {
+	File     string        `yaml:"file,omitempty" doc:"File to write the now-playing text to"`
+	Template string        `yaml:"template,omitempty" doc:"Go template for the text; fields are Artist, Title, Date, Location, Set, and URL"`
+	Debounce time.Duration `yaml:"debounce,omitempty" doc:"How long the text must be unchanged before it is written (default 2s)"`
+}
+
+func (c overlayConfig) template() string {
+	if c.Template != "" {
+		return c.Template
+	}
+	return defaultOverlayTemplate
+}
+
+func (c overlayConfig) debounce() time.Duration {
+	if c.Debounce > 0 {
+		return c.Debounce
+	}
+	return defaultOverlayDebounce
+}
+
+// overlayData is what an overlay template is executed with.
+type overlayData struct {
+	Track
+
+	// Date is the performance date, formatted for display.
+	Date string
+
+	// URL is the Relisten link for the track, if there is one.
+	URL string
+}
+
+// overlayWriter writes the current track, rendered with a template, to a
+// file. The file is replaced atomically, so streaming software never reads
+// a partly written file.
+type overlayWriter struct {
+	path     string
+	tmpl     *template.Template
+	debounce time.Duration
+
+	written string
+}
+
+func newOverlayWriter(path, tmpl string, debounce time.Duration) (*overlayWriter, error) {
+	t, err := template.New("overlay").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("parse overlay template: %w", err)
+	}
+	return &overlayWriter{path: path, tmpl: t, debounce: debounce}, nil
+}
+
+// render returns the text for t.
+func (w *overlayWriter) render(t Track) (string, error) {
+	data := overlayData{Track: t, URL: t.streamingURL(relistenArtists)}
+	if pt := t.PerformanceTime; !pt.IsZero() {
+		data.Date = pt.Format("Mon 2-Jan-2006")
+	}
+	var b strings.Builder
+	if err := w.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render overlay: %w", err)
+	}
+	return b.String(), nil
+}
+
+// write writes text to the file, unless it is what was last written.
+func (w *overlayWriter) write(text string) error {
+	if text == w.written {
+		return nil
+	}
+	if err := writeFileAtomic(w.path, []byte(text)); err != nil {
+		return err
+	}
+	w.written = text
+	return nil
+}
+
+// run writes the current track of each status published on the bus, until
+// the bus is closed. A change is only written once the text has been
+// unchanged for the debounce interval, so that a briefly reported track
+// does not flash on screen.
+func (w *overlayWriter) run(events <-chan interface{}, onError func(error)) {
+	var (
+		pending string
+		settled <-chan time.Time
+	)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				if settled != nil {
+					if err := w.write(pending); err != nil {
+						onError(err)
+					}
+				}
+				return
+			}
+			fetched, ok := e.(statusFetched)
+			if !ok {
+				continue
+			}
+			text, err := w.render(fetched.Status.CurrentTrack)
+			if err != nil {
+				onError(err)
+				continue
+			}
+			if text != pending {
+				pending = text
+				settled = time.After(w.debounce)
+			}
+		case <-settled:
+			settled = nil
+			if err := w.write(pending); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// runOverlay keeps a file up to date with the current track, for use as a
+// text source in streaming software.
+func runOverlay(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs       = flag.NewFlagSet("overlay", flag.ExitOnError)
+		output   = fs.StringP("output", "o", cfg.Overlay.File, "File to write the now-playing text to")
+		tmpl     = fs.StringP("template", "t", cfg.Overlay.template(), "Go template for the text")
+		debounce = fs.Duration("debounce", cfg.Overlay.debounce(), "How long the text must be unchanged before it is written")
+		interval = fs.Duration("interval", 15*time.Second, "How often to check the station")
+	)
+	fs.Parse(args)
+	if *output == "" {
+		return errors.New("no output file; use --output or set overlay.file in the config file")
+	}
+	w, err := newOverlayWriter(*output, *tmpl, *debounce)
+	if err != nil {
+		return err
+	}
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+
+	var (
+		b      = &bus{}
+		events = b.subscribe(1)
+		done   = make(chan struct{})
+	)
+	go func() {
+		w.run(events, func(err error) {
+			warn(fmt.Errorf("unable to write overlay: %w", err))
+		})
+		close(done)
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &poller{
+		radioCo:  newRadioCoClient(httpClient(cfg), cfg),
+		station:  cfg.station(),
+		interval: *interval,
+		bus:      b,
+	}
+	polling := make(chan struct{})
+	go func() {
+		p.run(ctx)
+		close(polling)
+	}()
+	fmt.Fprintf(os.Stderr, "Writing now playing to %s; press Ctrl-C to stop\n", *output)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	cancel()
+	<-polling
+	b.close()
+	<-done
+	return nil
+}
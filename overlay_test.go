@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOverlayWriter_Render(t *testing.T) {
+	tt := []struct {
+		desc  string
+		tmpl  string
+		track Track
+		want  string
+	}{
+		{
+			desc:  "default template",
+			tmpl:  defaultOverlayTemplate,
+			track: Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")},
+			want:  "Phish - Reba (Sat 22-Nov-1997)",
+		},
+		{
+			desc:  "default template without artist",
+			tmpl:  defaultOverlayTemplate,
+			track: Track{Title: "JEMP Radio"},
+			want:  "JEMP Radio",
+		},
+		{
+			desc:  "custom template",
+			tmpl:  `♪ {{.Title}} | {{.Location}}`,
+			track: Track{Artist: "Phish", Title: "Reba", Location: "Hampton, VA"},
+			want:  "♪ Reba | Hampton, VA",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			w, err := newOverlayWriter("", tc.tmpl, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := w.render(tc.track)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOverlayWriter_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-overlay")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "now.txt")
+	w, err := newOverlayWriter(path, "{{.Title}}", 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var (
+		events = make(chan interface{})
+		done   = make(chan struct{})
+		status = func(title string) statusFetched {
+			return statusFetched{Status: statusResponseBody{CurrentTrack: Track{Title: title}}}
+		}
+		read = func() string {
+			b, _ := ioutil.ReadFile(path)
+			return string(b)
+		}
+	)
+	go func() {
+		w.run(events, func(err error) { t.Errorf("unexpected error: %v", err) })
+		close(done)
+	}()
+
+	// A track that is replaced before the debounce interval passes is never
+	// written.
+	events <- status("Tweezer")
+	events <- status("Reba")
+	time.Sleep(100 * time.Millisecond)
+	if got := read(); got != "Reba" {
+		t.Errorf("wanted Reba, but got %q", got)
+	}
+	// A pending change is written when the bus closes.
+	events <- status("Wilson")
+	close(events)
+	<-done
+	if got := read(); got != "Wilson" {
+		t.Errorf("wanted Wilson, but got %q", got)
+	}
+}
@@ -1,36 +1,31 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"math"
 	"net/http"
 	"os"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/ianfoo/ph/humanize"
 	flag "github.com/spf13/pflag"
 	"gopkg.in/yaml.v2"
 )
 
 const (
-	urlJEMP = "https://public.radio.co/stations/sd71de59b3/status"
-
 	// TODO Update Date to account for "extra information" that now shows inside the parentheses
 	patJEMPDate         = `(?P<date>\d{1,2}(?P<separator>[-./])\d{1,2}[-./]\d{2})`
 	patJEMPRegularTrack = `^((?P<artist>.+)\s+-\s+)?(?P<title>.+?)(?:\s+\(` + patJEMPDate + `(?:\s+(?P<location>.+))?\))?$`
 	patJEMPFullShow     = `^(?P<artist>.+)\s+-\s+` + patJEMPDate +
-		`\s+(?P<set>(?:Set \d+(?:\s?\+\s?E)?)|Encore)\s+\((?P<location>.+)\)$`
+		`\s+(?P<set>(?:Set \d+(?:\s?\+\s?E(?:ncore)?)?)|Encore)\s+\((?P<location>.+)\)$`
 	patJEMPStationArtist = `^(?:www\.)?jempradio\.com`
 )
 
-// zeros regexp detects cases zero-value units in duration strings, so
-// that, for example, the duration "1h0m30s," as would be rendered by
-// default, can be presented more compactly as "1h30s."
-var zeroes = regexp.MustCompile(`(?:^|(\D))0[hms]`)
-
 var (
 	// jempStationBreak matches text that likely indicates a JEMP station break,
 	// such as the hourly-ish announcements and ads.
@@ -58,147 +53,385 @@ func main() {
 }
 
 func run() error {
-	var err error
-	relistenArtists, err = relistenGetArtists(http.DefaultClient)
+	if len(os.Args) > 1 {
+		if cmd, ok := lookupCommand(os.Args[1]); ok {
+			return cmd.run(os.Args[2:])
+		}
+	}
+	cfg, err := loadConfig()
 	if err != nil {
-		log.Printf("warning: unable to get Relisten artists: %v", err)
+		return err
 	}
+	if len(os.Args) > 1 {
+		if name := os.Args[1]; !strings.HasPrefix(name, "-") {
+			path, ok := lookupPlugin(name)
+			if !ok {
+				return fmt.Errorf(tr("unknown command %q (see ph --help)"), name)
+			}
+			status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+			if err != nil {
+				return err
+			}
+			return runPluginCommand(path, os.Args[2:], status.CurrentTrack)
+		}
+	}
+
 	var (
-		lastN   uint
-		history bool
-		format  string
+		lastN     uint
+		history   bool
+		format    string
+		verbose   bool
+		artists   []string
+		kinds     []string
+		title     string
+		plain     bool
+		resolve   []string
+		programs  []string
+		venues    []string
+		newOnly   bool
+		breaks    bool
+		numbering string
+		columns   []string
+		precision string
+		jamCharts bool
 	)
 	flag.UintVarP(&lastN, "last", "l", 1, "Show this many latest songs")
 	flag.BoolVar(&history, "history", false, "Show entire available history")
-	flag.StringVarP(&format, "format", "f", "text", "output format (text, json, yaml)")
+	flag.StringVarP(&format, "format", "f", cfg.format(), "output format (text, json, yaml, plain, alfred)")
+	flag.BoolVar(&plain, "plain", false, "Show a line for each field, labeled, with no color, for screen readers (same as --format plain)")
+	flag.BoolVarP(&verbose, "verbose", "v", false, "Show more detail about the current track, such as its place in the original show")
+	flag.StringSliceVar(&artists, "artist", nil, "Only list tracks by these artists")
+	flag.StringSliceVar(&kinds, "kind", nil, "Only list these kinds of tracks: "+strings.Join(trackKinds, ", ")+" (default: all but station breaks)")
+	flag.StringVar(&title, "title", "", "Only list tracks whose title matches this regular expression")
+	flag.StringSliceVar(&programs, "program", nil, "Only list tracks that aired in these programs (see programs.schedule)")
+	flag.StringSliceVar(&venues, "venue", nil, "Only list tracks performed at these venues, by name or alias (e.g., MSG)")
+	flag.BoolVar(&newOnly, "new-only", false, "Only list tracks by artists or of songs not in the play history before")
+	flag.BoolVar(&breaks, "include-breaks", cfg.StationBreaks, "Include station breaks, with what they announce, which are otherwise left out unless asked for with --kind")
+	flag.BoolVar(&breaks, "breaks", cfg.StationBreaks, "Same as --include-breaks")
+	flag.CommandLine.MarkHidden("breaks")
+	flag.StringVar(&numbering, "number", numberForward, "How to number listed tracks: "+strings.Join(numberings, ", ")+" (reverse numbers the oldest 1)")
+	flag.StringSliceVar(&columns, "columns", tableColumns, "Columns to show when listing tracks, in order: "+strings.Join(tableColumns, ", "))
+	flag.StringVar(&precision, "elapsed-precision", cfg.elapsedPrecision(), "Truncate how long ago tracks started to the minute or second")
+	flag.BoolVar(&jamCharts, "jam-charts", false, "Mark tracks on the phish.net jam charts (needs catalog.phishnet_api_key)")
+	flag.StringArrayVar(&resolve, "resolve", nil, "Connect to a host at this address instead of looking it up, as host:address or host:port:address (see network.resolve)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ph [flags]\n       ph <command> [args]\n\nFlags:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n%s", commandsUsage())
+	}
 	flag.Parse()
+	if plain {
+		format = "plain"
+	}
+	if len(resolve) > 0 {
+		cfg.Network.Resolve = append(cfg.Network.Resolve, resolve...)
+		if _, err := cfg.Network.transport(); err != nil {
+			return err
+		}
+	}
 
-	writeOutput, err := getRenderer(format)
+	rc := newRelistenClient(cfg)
+	relistenArtists, err = relistenGetArtists(rc, cfg)
 	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+
+	if _, err := parseElapsedPrecision(precision); err != nil {
 		return err
 	}
-	resp, err := http.Get(urlJEMP)
+	cfg.ElapsedPrecision = precision
+	writeOutput, err := getRenderer(format, useColor(cfg, os.Stdout), cfg)
 	if err != nil {
-		return fmt.Errorf("get JEMP Radio status: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
-	var status statusResponseBody
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
-		return fmt.Errorf("parsing status response: %w", err)
+	if err := checkNumbering(numbering); err != nil {
+		return err
+	}
+	if err := checkColumns(columns); err != nil {
+		return err
+	}
+	// Filtering lists tracks from the whole history, unless told how many.
+	filtering := len(artists) > 0 || len(kinds) > 0 || title != "" || len(programs) > 0 || len(venues) > 0 || newOnly
+	if filtering && !flag.CommandLine.Changed("last") {
+		history = true
+	}
+	if breaks && len(kinds) == 0 {
+		kinds = trackKinds
+	}
+	keep, err := historyFilter(artists, kinds, title, programs, venues)
+	if err != nil {
+		return err
+	}
+	if newOnly {
+		keep = All(keep, ByNew())
+	}
+	status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+	if err != nil {
+		return err
 	}
+	status = observeStatus(cfg, status)
 
 	if history {
 		lastN = 0
 	}
-	// NOTE Current track might be a JEMP station break.
-	if lastN == 1 {
-		writeOutput(status.CurrentTrack)
+	// The current track goes through the same filters as the history, so
+	// that a station break airing now is left out like any other.
+	tracks := status.tracks().Filter(keep)
+	if lastN > 0 {
+		tracks = tracks.Take(int(lastN))
+	}
+	if jamCharts {
+		tracks = newJamCharts(apiClient(cfg), cfg).mark(context.Background(), tracks)
+	}
+	if lastN == 1 && len(tracks) == 1 {
+		t := tracks[0]
+		if verbose {
+			t = withShowPosition(context.Background(), rc, relistenArtists, t)
+		}
+		writeOutput(t)
+		if len(cfg.Plugins.Links) > 0 && (format == "text" || format == "plain") {
+			links, err := pluginLinks(cfg.Plugins.Links, t)
+			if err != nil {
+				warn(err)
+			}
+			for _, l := range links {
+				// Plain output labels every line, like its fields.
+				if format == "plain" && l.Label == "" {
+					l.Label = tr("Link")
+				}
+				fmt.Println(l)
+			}
+		}
 		return nil
 	}
 
-	noJEMPStationBreaks := func(artist string) bool {
-		return !jempStationBreak.MatchString(artist)
+	if format == "text" {
+		// Only link to the shows that are on Relisten.
+		links, err := (&LinkResolver{Relisten: rc, cfg: cfg}).ResolveLinks(context.Background(), tracks)
+		if err != nil {
+			warn(fmt.Errorf("unable to check links: %w", err))
+		}
+		return writeOutput(linkedTrackList{tracks, links, tableLayout{numbering: numbering, columns: columns}})
 	}
-	lastNTracks := status.History.FilterArtist(noJEMPStationBreaks).LastN(lastN)
-	writeOutput(lastNTracks)
+	writeOutput(tracks)
 	return nil
 }
 
-type statusResponseBody struct {
-	CurrentTrack Track     `json:"current_track"`
-	History      TrackList `json:"history"`
+// observeStatus records the current track in the play history and any
+// change in the event log, unless no_history is set, then returns status
+// with its tracks corrected against the song catalog and marked with their
+// programs and novelty.
+func observeStatus(cfg config, status statusResponseBody) statusResponseBody {
+	st, err := openStore()
+	if err == nil && !cfg.NoHistory {
+		if _, err := st.recordPlay(status.CurrentTrack); err != nil {
+			warn(fmt.Errorf("unable to record play: %w", err))
+		}
+		events := &eventRecorder{store: st}
+		if _, err := events.observe(status, time.Now()); err != nil {
+			warn(fmt.Errorf("unable to record events: %w", err))
+		}
+	}
+
+	if cfg.Catalog.Autocorrect {
+		catalog := loadSongCatalog(context.Background(), apiClient(cfg), cfg)
+		status.CurrentTrack = catalog.correct(status.CurrentTrack)
+		status.History = catalog.correctAll(status.History)
+	}
+	schedule := loadPrograms(cfg)
+	status.CurrentTrack.Program = schedule.program(status.CurrentTrack)
+	status.History = schedule.assign(status.History)
+	heard := loadHeardBefore(st)
+	status.CurrentTrack.New = heard.novelty(status.CurrentTrack)
+	status.History = heard.mark(status.History)
+	return status
 }
 
-type TrackList []Track
+// tracks returns the current track followed by those aired before it, most
+// recent first. The history usually begins with the current track, without
+// its start time, so that entry gives way to the current track.
+func (s statusResponseBody) tracks() TrackList {
+	history := s.History
+	if current := s.CurrentTrack; len(history) > 0 && history[0].Key().Song() == current.Key().Song() {
+		history = history[1:]
+	}
+	if s.CurrentTrack.Title == "" {
+		return append(TrackList{}, history...)
+	}
+	return append(TrackList{s.CurrentTrack}, history...)
+}
 
-// LastN returns the last n tracks from the TrackList. If n is zero, then the
-// entire TrackList is returned.
-func (tl TrackList) LastN(n uint) TrackList {
-	if n == 0 {
-		return tl
+// historyFilter builds the predicate that selects the tracks to list from
+// the command line filters. Station breaks are left out unless asked for by
+// kind.
+func historyFilter(artists, kinds []string, title string, programs, venues []string) (func(Track) bool, error) {
+	preds := []func(Track) bool{Not(ByKind(trackKindStationBreak))}
+	if len(kinds) > 0 {
+		for _, k := range kinds {
+			if !containsString(trackKinds, k) {
+				return nil, fmt.Errorf("invalid kind %q: must be one of %s", k, strings.Join(trackKinds, ", "))
+			}
+		}
+		preds[0] = ByKind(kinds...)
+	}
+	if len(artists) > 0 {
+		preds = append(preds, ByArtist(artists...))
+	}
+	if len(programs) > 0 {
+		preds = append(preds, ByProgram(programs...))
 	}
-	if l := uint(len(tl)); n > l {
-		n = l
+	if len(venues) > 0 {
+		preds = append(preds, ByVenue(venues...))
+	}
+	if title != "" {
+		re, err := regexp.Compile("(?i)" + title)
+		if err != nil {
+			return nil, fmt.Errorf("invalid title pattern: %w", err)
+		}
+		preds = append(preds, ByTitleRegex(re))
 	}
-	return tl[:n]
+	return All(preds...), nil
 }
 
-// FilterArtist will return a TrackList of those tracks for which filterFunc
-// returns true when passed the artist name.
-func (tl TrackList) FilterArtist(filterFuncs ...func(string) bool) TrackList {
-	out := make(TrackList, 0, len(tl))
-	for _, t := range tl {
-		appendTrack := true
-		for _, currentFilter := range filterFuncs {
-			if !currentFilter(t.Artist) {
-				appendTrack = false
-			}
-		}
-		if appendTrack {
-			out = append(out, t)
+// getStatus fetches the current status of a radio.co station, which
+// includes the current track and recent track history. Unless configured
+// otherwise, the station is JEMP Radio. Concurrent requests for the same
+// station share one request to radio.co, and requests are rate limited
+// across the process; see statusGroup. The status's warnings are passed to
+// warn as well as returned with it. The request is abandoned when ctx is
+// done, or after the radio.co timeout.
+//
+// Requests go through the radio.co circuit breaker. While it is open, the
+// last status fetched is returned instead, with Stale set.
+func getStatus(ctx context.Context, radio radioCoClient, station string) (statusResponseBody, error) {
+	radio.client = withBreaker(radio.client, radioCoBreaker)
+	status, err := statusRequests.do(ctx, station, func() (statusResponseBody, error) {
+		return fetchStatus(ctx, radio, station)
+	})
+	var open *circuitOpenError
+	if errors.As(err, &open) {
+		if last, fetched, ok := statusRequests.lastStatus(station); ok {
+			last.Stale = &staleStatus{Since: fetched, Err: err}
+			last.Warnings = nil
+			return last, nil
 		}
 	}
-	return out
+	for _, w := range status.Warnings {
+		warn(w)
+	}
+	return status, err
 }
 
-// String renders the tracklist as a text table.
-func (tl TrackList) String() string {
-	if len(tl) == 0 {
-		return ""
+// radioCoClient asks radio.co for the statuses of stations, with the
+// radio.co settings of the config.
+type radioCoClient struct {
+	client *http.Client
+
+	// endpoint is the base URL of the radio.co API; defaultRadioCoEndpoint
+	// if empty.
+	endpoint string
+
+	// timeout is how long to wait for a status; defaultRadioCoTimeout if
+	// zero.
+	timeout time.Duration
+}
+
+// newRadioCoClient returns a radio.co client that makes its requests with
+// client, to the radio.co endpoint of cfg, with its radio.co timeout.
+func newRadioCoClient(client *http.Client, cfg config) radioCoClient {
+	return radioCoClient{client: client, endpoint: cfg.Endpoints.radioCo(), timeout: cfg.Timeouts.radioCo()}
+}
+
+// statusURL returns the URL of the status of station.
+func (c radioCoClient) statusURL(station string) string {
+	return endpointOrDefault(c.endpoint, defaultRadioCoEndpoint) + "/stations/" + station + "/status"
+}
+
+// fetchStatus asks radio.co for the status of station, giving up after the
+// radio.co timeout.
+func fetchStatus(ctx context.Context, radio radioCoClient, station string) (statusResponseBody, error) {
+	var status statusResponseBody
+	ctx, cancel := context.WithTimeout(ctx, timeoutOrDefault(radio.timeout, defaultRadioCoTimeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, radio.statusURL(station), nil)
+	if err != nil {
+		return status, err
 	}
-	const (
-		headingArtist         = "ARTIST"
-		headingTitle          = "TITLE"
-		headingPeformanceTime = "PERFORMED ON"
-		headlingStreamingURL  = "STREAM"
-	)
-	const (
-		dateFormat = "Mon _2-Jan-2006"
-		maxLenDate = len(dateFormat) + 1
-	)
-	var (
-		maxLenArtist = len(headingArtist)
-		maxLenTitle  = len(headingTitle)
-	)
-	for _, t := range tl {
-		if l := len(t.Artist); l > maxLenArtist {
-			maxLenArtist = l
-		}
-		if l := len(t.Title); l > maxLenTitle {
-			maxLenTitle = l
-		}
+	resp, err := radio.client.Do(req)
+	if err != nil {
+		return status, fmt.Errorf("get JEMP Radio status: %w", err)
 	}
-	var (
-		numTracks     = float64(len(tl))
-		maxLenIndex   = int(math.Floor(math.Log10(numTracks))) + 1
-		baseFormat    = fmt.Sprintf("%%-%ds  %%-%ds  %%-%ds  %%s\n", maxLenArtist, maxLenTitle, maxLenDate)
-		headingFormat = strings.Repeat(" ", maxLenIndex+1) + baseFormat
-		itemFormat    = fmt.Sprintf("%%%dd %s", maxLenIndex, baseFormat)
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return status, fmt.Errorf("parsing status response: %w", err)
+	}
+	return status, nil
+}
 
-		builder strings.Builder
-	)
-	builder.WriteString(fmt.Sprintf(
-		headingFormat,
-		headingArtist,
-		headingTitle,
-		headingPeformanceTime,
-		headlingStreamingURL))
-	for i, t := range tl {
-		var perfTimeStr string
-		if pt := t.PerformanceTime; !pt.IsZero() {
-			perfTimeStr = pt.Format(dateFormat)
+type statusResponseBody struct {
+	// Status is "online" when the station is streaming, or "offline".
+	Status       string    `json:"status"`
+	CurrentTrack Track     `json:"current_track"`
+	History      TrackList `json:"history"`
+
+	// Warnings describe the parts of tracks that could not be parsed, and
+	// were left out of them, as *parseWarning.
+	Warnings []error `json:"-" yaml:"-"`
+
+	// Stale is set when the station could not be asked, and this is the
+	// last status it gave.
+	Stale *staleStatus `json:"-" yaml:"-"`
+
+	stationInfo
+}
+
+// staleStatus describes a status that is not current: when it was fetched,
+// and why it could not be fetched since.
+type staleStatus struct {
+	Since time.Time
+	Err   error
+}
+
+func (s *staleStatus) String() string {
+	return fmt.Sprintf(tr("Showing the station as of %s: %v"), s.Since.Local().Format("15:04"), s.Err)
+}
+
+// UnmarshalJSON decodes a status from radio.co. A track that is not
+// entirely valid, such as one with a start time that does not parse, is
+// kept with what could be parsed, and a warning.
+func (s *statusResponseBody) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Status       string            `json:"status"`
+		CurrentTrack json.RawMessage   `json:"current_track"`
+		History      []json.RawMessage `json:"history"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &s.stationInfo); err != nil {
+		return err
+	}
+	s.Status = raw.Status
+	decode := func(b json.RawMessage) (Track, error) {
+		t, warnings, err := decodeTrack(b)
+		s.Warnings = append(s.Warnings, warnings...)
+		return t, err
+	}
+	var err error
+	if len(raw.CurrentTrack) > 0 {
+		if s.CurrentTrack, err = decode(raw.CurrentTrack); err != nil {
+			return err
+		}
+	}
+	if raw.History != nil {
+		s.History = make(TrackList, len(raw.History))
+		for i, b := range raw.History {
+			if s.History[i], err = decode(b); err != nil {
+				return err
+			}
 		}
-		builder.WriteString(fmt.Sprintf(
-			itemFormat,
-			i+1,
-			t.Artist,
-			t.Title,
-			perfTimeStr,
-			t.StreamingURL(relistenArtists)),
-		)
 	}
-	s := builder.String()
-	return s[:len(s)-1]
+	return nil
 }
 
 // Track represents a track being played on radio.co.
@@ -207,32 +440,104 @@ type Track struct {
 	Title           string    `json:"title"`
 	StartTime       time.Time `json:"start_time,omitempty" yaml:"start_time,omitempty"`
 	PerformanceTime time.Time `json:"performance_time,omitempty" yaml:"performance_time,omitempty"`
+	Location        string    `json:"location,omitempty" yaml:"location,omitempty"`
+
+	// Set is the set of a show, such as "Set 2" or "Encore", when the track
+	// is a full set broadcast rather than a single song.
+	Set *ShowSet `json:"set,omitempty" yaml:"set,omitempty"`
+
+	// Break is what a station break airs, when the track is one.
+	Break *StationBreak `json:"break,omitempty" yaml:"break,omitempty"`
+
+	// RawTitle is the title as broadcast, when Title has been corrected
+	// against a song catalog.
+	RawTitle string `json:"raw_title,omitempty" yaml:"raw_title,omitempty"`
+
+	// ArtworkURL is the cover art radio.co shows for the track.
+	ArtworkURL string `json:"artwork_url,omitempty" yaml:"artwork_url,omitempty"`
+
+	// Program is the programming block, such as a themed hour, that the
+	// track aired in, if known.
+	Program string `json:"program,omitempty" yaml:"program,omitempty"`
+
+	// PlayID identifies the airing in the play history, as served at
+	// /track/{id} in serve mode. It is the same each time the airing is
+	// seen, so it is set on tracks with a start time whether or not they
+	// have been recorded yet.
+	PlayID string `json:"play_id,omitempty" yaml:"play_id,omitempty"`
+
+	// New is what about the track is new to the user, by their play
+	// history: "artist", "song", or empty if neither.
+	New string `json:"new,omitempty" yaml:"new,omitempty"`
+
+	// JamChart is the phish.net jam chart entry of the performance, when
+	// jam charts were asked for and it is on them.
+	JamChart *JamChart `json:"jam_chart,omitempty" yaml:"jam_chart,omitempty"`
+
+	// Position is where the track falls in the show it was performed at,
+	// when that was asked for and could be found on Relisten.
+	Position *showPosition `json:"position,omitempty" yaml:"position,omitempty"`
 }
 
 // UnmarshalJSON implementes json.Unmarshaler in order to handle
-// the conversion of JSON data into a Track struct.
+// the conversion of JSON data into a Track struct. A start time that does
+// not parse is an error, though the rest of the track is still decoded;
+// other parts that do not parse are left out.
 func (t *Track) UnmarshalJSON(b []byte) error {
+	track, warnings, err := decodeTrack(b)
+	if err != nil {
+		return err
+	}
+	*t = track
+	for _, w := range warnings {
+		if pw, ok := w.(*parseWarning); ok && pw.Field == "start_time" {
+			return pw.Err
+		}
+	}
+	return nil
+}
+
+// decodeTrack decodes a track from radio.co's JSON. Parts of the track that
+// do not parse are left out, and described by the warnings returned with
+// it; only JSON that does not decode at all is an error.
+func decodeTrack(b []byte) (Track, []error, error) {
 	var respTrack struct {
-		Title     string `json:"title"`
-		StartTime string `json:"start_time"`
+		Title           string `json:"title"`
+		StartTime       string `json:"start_time"`
+		ArtworkURL      string `json:"artwork_url"`
+		ArtworkURLLarge string `json:"artwork_url_large"`
 	}
 	if err := json.Unmarshal(b, &respTrack); err != nil {
-		return err
+		return Track{}, nil, err
+	}
+	var (
+		t        Track
+		warnings []error
+	)
+	if w := t.parseRawTitle(respTrack.Title); w != nil {
+		warnings = append(warnings, w)
+	}
+	t.ArtworkURL = respTrack.ArtworkURLLarge
+	if t.ArtworkURL == "" {
+		t.ArtworkURL = respTrack.ArtworkURL
 	}
-	t.parseRawTitle(respTrack.Title)
 
 	if respTrack.StartTime == "" {
-		return nil
+		return t, warnings, nil
 	}
 	startTime, err := time.Parse(time.RFC3339, respTrack.StartTime)
 	if err != nil {
-		return err
+		warnings = append(warnings, &parseWarning{Title: respTrack.Title, Field: "start_time", Value: respTrack.StartTime, Err: err})
+		return t, warnings, nil
 	}
 	t.StartTime = startTime
-	return nil
+	return t, warnings, nil
 }
 
-func (t *Track) parseRawTitle(title string) {
+// parseRawTitle sets the fields of t from a title as broadcast. A
+// performance date that does not parse is left out, and described by the
+// warning returned.
+func (t *Track) parseRawTitle(title string) *parseWarning {
 	var (
 		matches       []string
 		matchedRegexp *regexp.Regexp
@@ -249,7 +554,7 @@ func (t *Track) parseRawTitle(title string) {
 	// Didn't match any of our expected formats.
 	if matchedRegexp == nil {
 		t.Title = title
-		return
+		return nil
 	}
 	var (
 		perfTimeStr string
@@ -273,24 +578,70 @@ func (t *Track) parseRawTitle(title string) {
 			set = strings.TrimSpace(matches[i])
 		}
 	}
+	var warning *parseWarning
 	if perfTimeStr != "" && perfTimeSep != "" {
 		parseFormat := fmt.Sprintf("1%s2%s06", perfTimeSep, perfTimeSep)
 		perfTime, err := time.Parse(parseFormat, perfTimeStr)
 		if err == nil {
 			t.PerformanceTime = perfTime
+		} else {
+			warning = &parseWarning{Title: title, Field: "performance_time", Value: perfTimeStr, Err: err}
 		}
 	}
 
+	t.Location = location
+	if t.IsStationBreak() {
+		b := parseStationBreak(t.Title)
+		t.Break = &b
+	}
+
 	// We are finished if this is not a full show title.
-	if set == "" || t.PerformanceTime.IsZero() {
-		return
+	showSet, ok := parseShowSet(set)
+	if !ok || t.PerformanceTime.IsZero() {
+		return warning
 	}
+	t.Set = &showSet
 	perfTimeStr = t.PerformanceTime.Format("2-Jan-2006")
 	if location != "" {
-		t.Title = perfTimeStr + " " + location + " " + set
-		return
+		t.Title = perfTimeStr + " " + location + " " + showSet.String()
+		return warning
 	}
-	t.Title = perfTimeStr + " " + set
+	t.Title = perfTimeStr + " " + showSet.String()
+	return warning
+}
+
+// IsFullShow reports whether the track is a full set broadcast, as aired
+// during JEMP Radio's Full Show Fridays, rather than a single song.
+func (t Track) IsFullShow() bool {
+	return t.Set != nil
+}
+
+// IsStationBreak reports whether the track is a JEMP Radio station break,
+// such as a station identification, rather than music.
+func (t Track) IsStationBreak() bool {
+	return jempStationBreak.MatchString(t.Artist)
+}
+
+// Kinds of tracks.
+const (
+	trackKindSong         = "song"
+	trackKindFullShow     = "full_show"
+	trackKindStationBreak = "station_break"
+)
+
+// trackKinds lists the kinds of tracks.
+var trackKinds = []string{trackKindSong, trackKindFullShow, trackKindStationBreak}
+
+// Kind returns what kind of track t is: a song, a full show broadcast, or a
+// station break.
+func (t Track) Kind() string {
+	switch {
+	case t.IsStationBreak():
+		return trackKindStationBreak
+	case t.IsFullShow():
+		return trackKindFullShow
+	}
+	return trackKindSong
 }
 
 // Elapsed returns a duration indicating how long ago playback of the track
@@ -298,16 +649,16 @@ func (t *Track) parseRawTitle(title string) {
 // is returned.
 func (t Track) Elapsed() time.Duration {
 	if st := t.StartTime; !st.IsZero() {
-		return time.Since(st).Round(time.Second)
+		return appClock.Now().Sub(st).Round(time.Second)
 	}
 	return 0
 }
 
-// StreamingURL returns a link to the streaming page for the currently-playing
+// streamingURL returns a link to the streaming page for the currently-playing
 // show, if the track has a perfomance date set and the band is one of a set of
 // selected bands. There is no guarantee that the link will refer to a valid
 // show, since it is possible that a given show is not available for streaming.
-func (t Track) StreamingURL(relistenArtists map[string]string) string {
+func (t Track) streamingURL(relistenArtists map[string]string) string {
 	if t.Artist == "" || t.PerformanceTime.IsZero() {
 		return ""
 	}
@@ -322,20 +673,23 @@ func (t Track) StreamingURL(relistenArtists map[string]string) string {
 	return url
 }
 
-// PhishNetURL returns a URL pointing to the setlist on phish.net for the show
-// that this track is from, if the track is a live Phish track.
-func (t Track) PhishNetURL() string {
-	if t.Artist != "Phish" || t.PerformanceTime.IsZero() {
-		return ""
-	}
-	return "https://phish.net/setlists/?d=" + t.PerformanceTime.Format("2006-01-02")
-}
-
 // String returns a string representation of a track, including the title,
 // and--if a start time is defined--how long ago the track started playing.
 func (t Track) String() string {
-	str := t.Artist
-	if str != "" {
+	return t.text(config{})
+}
+
+// text is String with the output settings of cfg.
+func (t Track) text(cfg config) string {
+	var str string
+	if t.New != "" {
+		str = newMarker + " "
+	}
+	if t.JamChart != nil {
+		str += jamChartMarker + " "
+	}
+	str += t.Artist
+	if t.Artist != "" {
 		str += " - "
 	}
 	str += t.Title
@@ -343,32 +697,97 @@ func (t Track) String() string {
 		str += fmt.Sprintf(" (%s)", d.Format("Mon 2-Jan-2006"))
 	}
 	if elapsed := t.Elapsed(); elapsed != 0 {
-		str += fmt.Sprintf(" (started %s)", StartedString(elapsed))
+		str += fmt.Sprintf(tr(" (started %s)"), cfg.elapsedFormat().started(elapsed))
+	}
+	if t.JamChart != nil {
+		str += "\n" + t.JamChart.String()
 	}
-	if stream := t.StreamingURL(relistenArtists); stream != "" {
-		str += "\n" + stream
+	if t.Position != nil {
+		str += "\n" + tr("Position in show") + ": " + t.Position.String()
 	}
-	if pnet := t.PhishNetURL(); pnet != "" {
-		str += "\n" + pnet
+	for _, line := range linkLines(t.Links(cfg, relistenArtists)) {
+		str += "\n" + line
 	}
 	return str
 }
 
+// elapsedFormat is how precisely elapsed times, such as how long ago a
+// track started, are given: in up to precision units, or
+// defaultDurationPrecision if zero, truncated to truncate, or to the
+// minute if zero.
+type elapsedFormat struct {
+	precision int
+	truncate  time.Duration
+}
+
+// elapsedFormat returns how precisely the config says to give elapsed
+// times. An invalid elapsed_precision, which loadConfig warns of, gives way
+// to the default.
+func (c config) elapsedFormat() elapsedFormat {
+	truncate, _ := parseElapsedPrecision(c.elapsedPrecision())
+	return elapsedFormat{precision: c.durationPrecision(), truncate: truncate}
+}
+
 // StartedString converts a duration into a human-friendly string represntation
-// of how long ago the duration was.
+// of how long ago the duration was, such as "2d1h ago", as precisely as the
+// defaults give it.
 func StartedString(d time.Duration) string {
-	dstr := zeroes.ReplaceAllString(d.Truncate(time.Second).String(), "$1")
-	if dstr != "" {
-		return dstr + " ago"
+	return elapsedFormat{}.started(d)
+}
+
+// started converts a duration into how long ago the duration was, as
+// StartedString does, as precisely as f says.
+func (f elapsedFormat) started(d time.Duration) string {
+	if d.Truncate(f.truncateTo()) == 0 {
+		return tr("just now")
 	}
-	return "just now"
+	return fmt.Sprintf(tr("%s ago"), f.elapsed(d))
+}
+
+// elapsed formats an elapsed time, truncated as f says.
+func (f elapsedFormat) elapsed(d time.Duration) string {
+	precision := f.precision
+	if precision <= 0 {
+		precision = defaultDurationPrecision
+	}
+	return humanize.Duration(d.Truncate(f.truncateTo()), precision)
+}
+
+func (f elapsedFormat) truncateTo() time.Duration {
+	if f.truncate > 0 {
+		return f.truncate
+	}
+	return time.Minute
+}
+
+// texter is implemented by values whose text output depends on the output
+// settings of the config, such as how precisely how long ago tracks started
+// is given. Their String methods give it as the defaults have it.
+type texter interface {
+	text(cfg config) string
 }
 
-func getRenderer(format string) (func(interface{}) error, error) {
+// outputFormats lists the formats that getRenderer supports.
+var outputFormats = []string{"text", "json", "yaml", "plain", "alfred"}
+
+// defaultFormats lists the formats the format setting may name: those of
+// outputFormats, and those that only some commands support.
+var defaultFormats = append(append([]string(nil), outputFormats...), "widget", "events")
+
+// getRenderer returns a function that writes values to stdout in format,
+// with the output settings of cfg.
+func getRenderer(format string, color bool, cfg config) (func(interface{}) error, error) {
 	switch format {
 	case "text":
 		f := func(v interface{}) error {
-			_, err := fmt.Println(v)
+			s := fmt.Sprint(v)
+			if t, ok := v.(texter); ok {
+				s = t.text(cfg)
+			}
+			if color {
+				s = colorize(s)
+			}
+			_, err := fmt.Println(s)
 			return err
 		}
 		return f, nil
@@ -382,6 +801,16 @@ func getRenderer(format string) (func(interface{}) error, error) {
 			return yaml.NewEncoder(os.Stdout).Encode(v)
 		}
 		return f, nil
+	case "plain":
+		f := func(v interface{}) error {
+			return writePlain(os.Stdout, v, cfg)
+		}
+		return f, nil
+	case "alfred":
+		f := func(v interface{}) error {
+			return writeAlfred(os.Stdout, v, cfg)
+		}
+		return f, nil
 	default:
 		return nil, fmt.Errorf("invalid output format %q", format)
 	}
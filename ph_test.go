@@ -3,13 +3,15 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/ianfoo/ph/relisten"
 )
 
 func TestTrack_UnmarshalJSON(t *testing.T) {
@@ -83,6 +85,17 @@ func TestTrack_UnmarshalJSON(t *testing.T) {
 				Title:  "The Phishsonian Hour 5-28-20",
 			},
 		},
+		{
+			desc:    "full show set",
+			payload: `{"title": "Phish - 5-28-89 Set 2 (Hebron, NY)"}`,
+			want: Track{
+				Artist:          "Phish",
+				Title:           "28-May-1989 Hebron, NY Set 2",
+				PerformanceTime: mustParseDate("1989-05-28"),
+				Location:        "Hebron, NY",
+				Set:             &ShowSet{Number: 2},
+			},
+		},
 		{
 			desc:    "no identifiable artist name field",
 			payload: `{"title": "No Separator Band Foo Foo (1-1-20)"}`,
@@ -116,12 +129,15 @@ func TestTrack_UnmarshalJSON(t *testing.T) {
 }
 
 func TestTrack_Elapsed(t *testing.T) {
-	dur := time.Duration(30 * time.Second)
+	var (
+		dur   = time.Duration(30 * time.Second)
+		clock = useFakeClock(t, mustParseDate("2020-07-04T18:00:00"))
+	)
 	tt := []struct {
 		start time.Time
 		want  time.Duration
 	}{
-		{start: time.Now().Add(-dur), want: dur},
+		{start: clock.Now().Add(-dur), want: dur},
 		{want: 0},
 	}
 	for _, tc := range tt {
@@ -137,7 +153,7 @@ func TestTrack_Elapsed(t *testing.T) {
 	}
 }
 
-func TestTrack_StreamingURL(t *testing.T) {
+func TestTrack_streamingURL(t *testing.T) {
 	tt := []struct {
 		desc  string
 		track Track
@@ -178,15 +194,10 @@ func TestTrack_StreamingURL(t *testing.T) {
 			want: "https://relisten.net/grateful-dead/1985/03/26",
 		},
 	}
-	// TODO Use a locally-persisted "golden" copy of the artists map.
-	// TODO Make an artists map from a byte slice, to decouple it from the HTTP client.
-	relistenArtists, err := relistenGetArtists(http.DefaultClient)
-	if err != nil {
-		t.Fatalf("unable to get relisten artists: %v", err)
-	}
+	relistenArtists := goldenRelistenArtists(t)
 	for _, tc := range tt {
 		t.Run(tc.desc, func(t *testing.T) {
-			if got := tc.track.StreamingURL(relistenArtists); tc.want != got {
+			if got := tc.track.streamingURL(relistenArtists); tc.want != got {
 				t.Errorf("wanted %q, but got %q", tc.want, got)
 			}
 		})
@@ -194,7 +205,10 @@ func TestTrack_StreamingURL(t *testing.T) {
 }
 
 func TestTrack_String(t *testing.T) {
-	dur := time.Duration(90 * time.Second)
+	var (
+		dur   = time.Duration(90 * time.Second)
+		clock = useFakeClock(t, mustParseDate("2020-07-04T18:00:00"))
+	)
 	tt := []struct {
 		desc  string
 		track Track
@@ -205,12 +219,12 @@ func TestTrack_String(t *testing.T) {
 			track: Track{
 				Artist:          "Phish",
 				Title:           "Mercury",
-				StartTime:       time.Now().Add(-dur),
+				StartTime:       clock.Now().Add(-dur),
 				PerformanceTime: mustParseDate("2019-07-14"),
 			},
-			want: "Phish - Mercury (Sun 14-Jul-2019) (started 1m30s ago)\n" +
-				"https://relisten.net/phish/2019/07/14\n" +
-				"https://phish.net/setlists/?d=2019-07-14",
+			want: "Phish - Mercury (Sun 14-Jul-2019) (started 1m ago)\n" +
+				"Stream: https://relisten.net/phish/2019/07/14\n" +
+				"Setlist: https://phish.net/setlists/?d=2019-07-14",
 		},
 		{
 			desc: "no start time",
@@ -220,8 +234,8 @@ func TestTrack_String(t *testing.T) {
 				PerformanceTime: mustParseDate("2019-07-14"),
 			},
 			want: "Phish - Mercury (Sun 14-Jul-2019)\n" +
-				"https://relisten.net/phish/2019/07/14\n" +
-				"https://phish.net/setlists/?d=2019-07-14",
+				"Stream: https://relisten.net/phish/2019/07/14\n" +
+				"Setlist: https://phish.net/setlists/?d=2019-07-14",
 		},
 		{
 			desc: "no performance time",
@@ -240,11 +254,7 @@ func TestTrack_String(t *testing.T) {
 
 	// TODO Get rid of the package-level variable for relistenArtists.
 	// Allow tracks to be stringified without it.
-	var err error
-	relistenArtists, err = relistenGetArtists(http.DefaultClient)
-	if err != nil {
-		t.Fatalf("unable to get relisten artists: %v", err)
-	}
+	relistenArtists = goldenRelistenArtists(t)
 	for _, tc := range tt {
 		t.Run(tc.desc, func(t *testing.T) {
 			if got := tc.track.String(); got != tc.want {
@@ -256,21 +266,25 @@ func TestTrack_String(t *testing.T) {
 
 func TestStartedString(t *testing.T) {
 	tt := []struct {
-		in   time.Duration
-		want string
+		in        time.Duration
+		precision time.Duration
+		want      string
 	}{
-		{time.Second, "1s ago"},
-		{time.Minute, "1m ago"},
-		{67 * time.Second, "1m7s ago"},
-		{90 * time.Second, "1m30s ago"},
-		{67 * time.Minute, "1h7m ago"},
-		{3607 * time.Second, "1h7s ago"},
-		{0, "just now"},
-		{1000, "just now"},
+		{time.Second, time.Second, "1s ago"},
+		{time.Minute, time.Second, "1m ago"},
+		{67 * time.Second, time.Second, "1m7s ago"},
+		{90 * time.Second, time.Second, "1m30s ago"},
+		{67 * time.Minute, time.Second, "1h7m ago"},
+		{3607 * time.Second, time.Second, "1h7s ago"},
+		{49*time.Hour + 12*time.Minute + 3*time.Second, time.Second, "2d1h ago"},
+		{0, time.Second, "just now"},
+		{1000, time.Second, "just now"},
+		{90 * time.Second, time.Minute, "1m ago"},
+		{59 * time.Second, time.Minute, "just now"},
 	}
 	for _, tc := range tt {
-		t.Run(tc.in.String(), func(t *testing.T) {
-			got := StartedString(tc.in)
+		t.Run(tc.in.String()+"/"+tc.precision.String(), func(t *testing.T) {
+			got := elapsedFormat{truncate: tc.precision}.started(tc.in)
 			if got != tc.want {
 				t.Fatalf("%s: wanted %q, but got %q", tc.in, tc.want, got)
 			}
@@ -278,65 +292,163 @@ func TestStartedString(t *testing.T) {
 	}
 }
 
-func TestTrackList_FilterArtist(t *testing.T) {
+func TestElapsedFormat(t *testing.T) {
+	d := 49*time.Hour + 12*time.Minute
+	if got, want := (config{}).elapsedFormat().started(d), "2d1h ago"; got != want {
+		t.Errorf("wanted %q by default, but got %q", want, got)
+	}
+	cfg := config{DurationPrecision: 1}
+	if got, want := cfg.elapsedFormat().started(d), "2d ago"; got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+	if got, want := (config{ElapsedPrecision: "second"}).elapsedFormat().started(67*time.Second), "1m7s ago"; got != want {
+		t.Errorf("wanted %q to the second, but got %q", want, got)
+	}
+	now := useFakeClock(t, mustParseDate("2020-06-05T20:00:00")).Now()
+	tl := TrackList{{Artist: "Phish", Title: "Reba", StartTime: now.Add(-d)}}
+	if got := tl.text(cfg); !strings.Contains(got, "2d ago") {
+		t.Errorf("wanted the table to give how long ago as configured, but got:\n%s", got)
+	}
+}
+
+// goldenRelistenArtists returns an artists map built from a locally
+// persisted copy of part of the Relisten artists API response.
+func goldenRelistenArtists(t *testing.T) map[string]string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "relisten-artists.json"))
+	if err != nil {
+		t.Fatalf("unable to read golden Relisten artists: %v", err)
+	}
+	var artistsList []relisten.Artist
+	if err := json.Unmarshal(b, &artistsList); err != nil {
+		t.Fatalf("unable to parse golden Relisten artists: %v", err)
+	}
+	return relistenMakeArtistsMap(artistsList, config{})
+}
+
+func mustParseDate(dateStr string) time.Time {
+	if !strings.Contains(dateStr, "T") {
+		dateStr += "T00:00:00"
+	}
+	if !strings.Contains(dateStr, "+") {
+		dateStr += "+00:00"
+	}
+	d, err := time.Parse(time.RFC3339, dateStr)
+	if err != nil {
+		panic(fmt.Sprintf("unable to parse test date %q: %v", dateStr, err))
+	}
+	return d
+}
+
+func TestHistoryFilter(t *testing.T) {
+	var (
+		reba    = Track{Artist: "Phish", Title: "Reba"}
+		tweezer = Track{Artist: "Phish", Title: "Tweezer Reprise"}
+		station = Track{Artist: "jempradio.com", Title: "Station ID"}
+		arcadia = Track{Artist: "Goose", Title: "Arcadia", Program: "Morning Jams", Location: "MSG, New York, NY"}
+		tracks  = TrackList{reba, station, tweezer, arcadia}
+	)
+	tt := []struct {
+		desc     string
+		artists  []string
+		kinds    []string
+		title    string
+		programs []string
+		venues   []string
+		want     TrackList
+		wantErr  bool
+	}{
+		{desc: "no filters leaves out station breaks", want: TrackList{reba, tweezer, arcadia}},
+		{desc: "station breaks by kind", kinds: []string{"station_break"}, want: TrackList{station}},
+		{desc: "artist and title", artists: []string{"phish"}, title: "tweezer", want: TrackList{tweezer}},
+		{desc: "program", programs: []string{"morning jams"}, want: TrackList{arcadia}},
+		{desc: "venue", venues: []string{"madison square garden"}, want: TrackList{arcadia}},
+		{desc: "invalid kind", kinds: []string{"jam"}, wantErr: true},
+		{desc: "invalid title", title: "(", wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			keep, err := historyFilter(tc.artists, tc.kinds, tc.title, tc.programs, tc.venues)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("wanted an error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := tracks.Filter(keep); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStatusResponseBody_Tracks(t *testing.T) {
+	var (
+		start   = mustParseDate("2020-07-04T18:00:00")
+		reba    = Track{Artist: "Phish", Title: "Reba", StartTime: start}
+		tweezer = Track{Artist: "Phish", Title: "Tweezer Reprise"}
+		station = Track{Artist: "jempradio.com", Title: "Station ID"}
+	)
 	tt := []struct {
-		desc       string
-		in         TrackList
-		filterFunc func(string) bool
-		want       TrackList
+		desc   string
+		status statusResponseBody
+		want   TrackList
 	}{
 		{
-			desc: "Phish only",
-			in: TrackList{
-				Track{Artist: "Phish"},
-				Track{Artist: "Grateful Dead"},
-				Track{Artist: "Phish"},
-			},
-			filterFunc: func(s string) bool {
-				return s == "Phish"
-			},
-			want: TrackList{
-				Track{Artist: "Phish"},
-				Track{Artist: "Phish"},
-			},
+			desc:   "history starting with the current track",
+			status: statusResponseBody{CurrentTrack: reba, History: TrackList{{Artist: "Phish", Title: "Reba"}, tweezer}},
+			want:   TrackList{reba, tweezer},
 		},
 		{
-			desc: "Exclude JEMP Radio meta-tracks",
-			in: TrackList{
-				Track{Artist: "Phish"},
-				Track{Artist: "www.jempradio.com"},
-				Track{Artist: "jempradio.com"},
-				Track{Artist: "Phish"},
-			},
-			filterFunc: func(s string) bool {
-				return s != "jempradio.com" && s != "www.jempradio.com"
-			},
-			want: TrackList{
-				Track{Artist: "Phish"},
-				Track{Artist: "Phish"},
-			},
+			desc:   "history without the current track",
+			status: statusResponseBody{CurrentTrack: station, History: TrackList{reba, tweezer}},
+			want:   TrackList{station, reba, tweezer},
+		},
+		{
+			desc:   "no current track",
+			status: statusResponseBody{History: TrackList{tweezer}},
+			want:   TrackList{tweezer},
 		},
 	}
 	for _, tc := range tt {
 		t.Run(tc.desc, func(t *testing.T) {
-			got := tc.in.FilterArtist(tc.filterFunc)
-			if !reflect.DeepEqual(got, tc.want) {
+			if got := tc.status.tracks(); !reflect.DeepEqual(got, tc.want) {
 				t.Errorf("wanted %v but got %v", tc.want, got)
 			}
 		})
 	}
 }
 
-func mustParseDate(dateStr string) time.Time {
-	if !strings.Contains(dateStr, "T") {
-		dateStr += "T00:00:00"
+func BenchmarkTrack_ParseRawTitle(b *testing.B) {
+	titles := []string{
+		"Phish - Chalk Dust Torture (7-18-14)",
+		"Phish - Lushington (5/20/87)",
+		"Phish - 5-28-89 Set 2 (Hebron, NY)",
+		"Alex Grosby - The Phishsonian Hour 5-28-20",
+		"jempradio.com - Station ID",
 	}
-	if !strings.Contains(dateStr, "+") {
-		dateStr += "+00:00"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var t Track
+		t.parseRawTitle(titles[i%len(titles)])
 	}
-	d, err := time.Parse(time.RFC3339, dateStr)
+}
+
+func BenchmarkStatusResponseBody_Tracks(b *testing.B) {
+	status := statusResponseBody{CurrentTrack: Track{Artist: "Phish", Title: "Reba"}}
+	for i := 0; i < 20; i++ {
+		status.History = append(status.History, Track{Artist: "Phish", Title: fmt.Sprintf("Song %d", i)})
+	}
+	status.History[0] = status.CurrentTrack
+	keep, err := historyFilter(nil, nil, "", nil, nil)
 	if err != nil {
-		panic(fmt.Sprintf("unable to parse test date %q: %v", dateStr, err))
+		b.Fatalf("unexpected error: %v", err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		status.tracks().Filter(keep).Take(10)
 	}
-	return d
 }
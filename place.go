@@ -0,0 +1,127 @@
+package main
+
+import "strings"
+
+// Place is where a show was performed, split from the location given in a
+// track's title, such as "Madison Square Garden, New York, NY". Any part
+// that is not known is empty.
+type Place struct {
+	Venue string
+	City  string
+
+	// State is the state or province, or for shows outside the US and
+	// Canada, usually the country.
+	State string
+}
+
+// Place returns where the track was performed, from its location.
+func (t Track) Place() Place {
+	return parsePlace(t.Location)
+}
+
+// parsePlace splits a location into its venue, city, and state. Locations
+// are titled inconsistently, so it goes by the shape of the location: the
+// last part, if it is a state or province, follows the city, and whatever
+// is before the city is the venue. A location of two parts is a city and
+// state unless the first part names a venue, and a location of one part is
+// a venue only if it names one. Venues are given the names venueAliases
+// maps them to.
+func parsePlace(location string) Place {
+	var parts []string
+	for _, p := range strings.Split(location, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	var p Place
+	switch n := len(parts); {
+	case n == 0:
+		return p
+	case n == 1:
+		if isVenueName(parts[0]) {
+			p.Venue = parts[0]
+		} else {
+			p.City = parts[0]
+		}
+	case n == 2 && !isStateName(parts[1]) && isVenueName(parts[0]):
+		p.Venue, p.City = parts[0], parts[1]
+	default:
+		p.Venue = strings.Join(parts[:n-2], ", ")
+		p.City, p.State = parts[n-2], parts[n-1]
+	}
+	p.Venue = canonicalVenue(p.Venue)
+	return p
+}
+
+// venueAliases maps the other names venues go by, in lower case, to the
+// name each is known by.
+var venueAliases = map[string]string{
+	"msg":                               "Madison Square Garden",
+	"the garden":                        "Madison Square Garden",
+	"spac":                              "Saratoga Performing Arts Center",
+	"the gorge":                         "The Gorge Amphitheatre",
+	"gorge amphitheatre":                "The Gorge Amphitheatre",
+	"the mothership":                    "Hampton Coliseum",
+	"hampton coliseum":                  "Hampton Coliseum",
+	"red rocks":                         "Red Rocks Amphitheatre",
+	"red rocks amphitheater":            "Red Rocks Amphitheatre",
+	"alpine valley":                     "Alpine Valley Music Theatre",
+	"deer creek":                        "Deer Creek Music Center",
+	"dick's":                            "Dick's Sporting Goods Park",
+	"dicks":                             "Dick's Sporting Goods Park",
+	"the spectrum":                      "The Spectrum",
+	"winterland":                        "Winterland Arena",
+	"winterland ballroom":               "Winterland Arena",
+	"fillmore east":                     "Fillmore East",
+	"the fillmore east":                 "Fillmore East",
+	"the capitol theatre":               "Capitol Theatre",
+	"the cap":                           "Capitol Theatre",
+	"nassau coliseum":                   "Nassau Veterans Memorial Coliseum",
+	"nassau veterans memorial coliseum": "Nassau Veterans Memorial Coliseum",
+}
+
+// canonicalVenue returns the name a venue is known by, which is the name
+// given unless venueAliases has another.
+func canonicalVenue(name string) string {
+	if canonical, ok := venueAliases[strings.ToLower(name)]; ok {
+		return canonical
+	}
+	return name
+}
+
+// venueWords are words that suggest a name is a venue's, rather than a
+// city's.
+var venueWords = []string{
+	"amphitheater", "amphitheatre", "arena", "auditorium", "ballroom",
+	"center", "centre", "club", "coliseum", "field", "fillmore", "garden",
+	"gardens", "hall", "music", "park", "pavilion", "stadium", "theater",
+	"theatre",
+}
+
+// isVenueName reports whether name looks like a venue's name.
+func isVenueName(name string) bool {
+	if _, ok := venueAliases[strings.ToLower(name)]; ok {
+		return true
+	}
+	for _, w := range strings.Fields(strings.ToLower(name)) {
+		if containsString(venueWords, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// stateCodes are the postal codes of the US states and DC, and of the
+// Canadian provinces and territories.
+var stateCodes = strings.Fields(`
+	AL AK AZ AR CA CO CT DE DC FL GA HI ID IL IN IA KS KY LA ME MD MA MI MN
+	MS MO MT NE NV NH NJ NM NY NC ND OH OK OR PA RI SC SD TN TX UT VT VA WA
+	WV WI WY
+	AB BC MB NB NL NS NT NU ON PE QC SK YT
+`)
+
+// isStateName reports whether name is the postal code of a state or
+// province.
+func isStateName(name string) bool {
+	return containsString(stateCodes, strings.ToUpper(name))
+}
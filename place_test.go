@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestParsePlace(t *testing.T) {
+	tt := []struct {
+		location string
+		want     Place
+	}{
+		{"Madison Square Garden, New York, NY", Place{Venue: "Madison Square Garden", City: "New York", State: "NY"}},
+		{"MSG, New York, NY", Place{Venue: "Madison Square Garden", City: "New York", State: "NY"}},
+		{"Hampton, VA", Place{City: "Hampton", State: "VA"}},
+		{"Hampton Coliseum, Hampton", Place{Venue: "Hampton Coliseum", City: "Hampton"}},
+		{"Hebron", Place{City: "Hebron"}},
+		{"Red Rocks", Place{Venue: "Red Rocks Amphitheatre"}},
+		{"Paradiso, Amsterdam, Netherlands", Place{Venue: "Paradiso", City: "Amsterdam", State: "Netherlands"}},
+		{"Nectar's, Main Street, Burlington, VT", Place{Venue: "Nectar's, Main Street", City: "Burlington", State: "VT"}},
+		{"", Place{}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.location, func(t *testing.T) {
+			if got := parsePlace(tc.location); got != tc.want {
+				t.Errorf("wanted %+v, but got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestByVenue(t *testing.T) {
+	var (
+		msg     = Track{Artist: "Phish", Title: "Tweezer", Location: "Madison Square Garden, New York, NY"}
+		hampton = Track{Artist: "Phish", Title: "Reba", Location: "Hampton, VA"}
+		tracks  = TrackList{msg, hampton}
+	)
+	for _, venue := range []string{"Madison Square Garden", "msg", "The Garden"} {
+		if got := tracks.Filter(ByVenue(venue)); len(got) != 1 || got[0].Title != "Tweezer" {
+			t.Errorf("%s: wanted the MSG track, but got %v", venue, got)
+		}
+	}
+	if got := tracks.Filter(ByVenue("Hampton")); len(got) != 0 {
+		t.Errorf("wanted no tracks for a city, but got %v", got)
+	}
+}
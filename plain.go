@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// plainField is a labeled value in plain output.
+type plainField struct {
+	Label string
+	Value string
+}
+
+// trackPlainFields describes a track as labeled fields, leaving out those
+// it does not have, with the output settings of cfg.
+func trackPlainFields(t Track, cfg config) []plainField {
+	fields := []plainField{
+		{tr("Artist"), t.Artist},
+		{tr("Title"), t.Title},
+	}
+	if pt := t.PerformanceTime; !pt.IsZero() {
+		fields = append(fields, plainField{tr("Performance date"), pt.Format("Monday, 2 January 2006")})
+	}
+	fields = append(fields,
+		plainField{tr("Location"), t.Location},
+		plainField{tr("Set"), t.Set.String()},
+		plainField{tr("Station break"), t.Break.String()},
+		plainField{tr("Program"), t.Program},
+		plainField{tr("New to me"), t.New},
+		plainField{tr("Jam chart"), t.JamChart.plain()},
+		plainField{tr("Position in show"), t.Position.String()},
+	)
+	if elapsed := t.Elapsed(); elapsed != 0 {
+		fields = append(fields, plainField{tr("Started"), cfg.elapsedFormat().started(elapsed)})
+	}
+	for _, l := range t.Links(cfg, relistenArtists) {
+		fields = append(fields, plainField{l.Label, l.URL})
+	}
+	return fields
+}
+
+// writePlain writes v as a line for each field, labeled, as in
+// "Artist: Phish", with no tables, box drawing, or color, for screen
+// readers and grep. Tracks are described by trackPlainFields, and other
+// values by their exported fields. The items of a list are separated by
+// blank lines. Tracks are described with the output settings of cfg.
+func writePlain(w io.Writer, v interface{}, cfg config) error {
+	var b strings.Builder
+	writePlainValue(&b, reflect.ValueOf(v), cfg)
+	out := strings.TrimRight(b.String(), "\n")
+	if out == "" {
+		out = tr("Nothing to show.")
+	}
+	_, err := fmt.Fprintln(w, out)
+	return err
+}
+
+var (
+	trackType = reflect.TypeOf(Track{})
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+func writePlainValue(b *strings.Builder, v reflect.Value, cfg config) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.Struct && v.Type().ConvertibleTo(trackType):
+		writePlainFields(b, trackPlainFields(v.Convert(trackType).Interface().(Track), cfg))
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			writePlainValue(b, v.Index(i), cfg)
+		}
+	case v.Kind() == reflect.Struct && v.Type() != timeType:
+		writePlainFields(b, structPlainFields(v))
+	default:
+		b.WriteString(plainString(v) + "\n")
+	}
+}
+
+func writePlainFields(b *strings.Builder, fields []plainField) {
+	for _, f := range fields {
+		if f.Value != "" {
+			fmt.Fprintf(b, "%s: %s\n", f.Label, f.Value)
+		}
+	}
+}
+
+// structPlainFields labels the exported fields of a struct, flattening
+// those that are themselves structs, other than times and tracks, into
+// their own fields.
+func structPlainFields(v reflect.Value) []plainField {
+	var fields []plainField
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType && !fv.Type().ConvertibleTo(trackType) {
+			fields = append(fields, structPlainFields(fv)...)
+			continue
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+			fields = append(fields, plainField{plainLabel(f), trackSummary(fv.Convert(trackType).Interface().(Track))})
+			continue
+		}
+		fields = append(fields, plainField{plainLabel(f), plainString(fv)})
+	}
+	return fields
+}
+
+// plainLabel labels a struct field from the name it has in JSON, such as
+// "Performance time" for performance_time, or its Go name.
+func plainLabel(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		name = f.Name
+	}
+	words := strings.Split(name, "_")
+	for i, w := range words {
+		if w == "url" || w == "id" {
+			words[i] = strings.ToUpper(w)
+		}
+	}
+	name = strings.Join(words, " ")
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// plainString formats a simple value, returning "" for one that is zero,
+// so that it is left out.
+func plainString(v reflect.Value) string {
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return ""
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Local().Format("Monday, 2 January 2006, 15:04")
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, plainString(v.Index(i)))
+		}
+		return strings.Join(items, ", ")
+	}
+	if v.Kind() == reflect.Bool {
+		if v.Bool() {
+			return tr("yes")
+		}
+		return tr("no")
+	}
+	if reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface()) {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWritePlain(t *testing.T) {
+	relistenArtists = goldenRelistenArtists(t)
+	reba := Track{
+		Artist:          "Phish",
+		Title:           "Reba",
+		PerformanceTime: mustParseDate("1997-11-22"),
+		Location:        "Hampton, VA",
+	}
+	tt := []struct {
+		desc string
+		v    interface{}
+		want string
+	}{
+		{
+			desc: "track",
+			v:    reba,
+			want: "Artist: Phish\n" +
+				"Title: Reba\n" +
+				"Performance date: Saturday, 22 November 1997\n" +
+				"Location: Hampton, VA\n" +
+				"Stream: https://relisten.net/phish/1997/11/22\n" +
+				"Setlist: https://phish.net/setlists/?d=1997-11-22\n",
+		},
+		{
+			desc: "track list",
+			v:    TrackList{{Artist: "Phish", Title: "Tweezer"}, {Title: "Station ID"}},
+			want: "Artist: Phish\nTitle: Tweezer\n\nTitle: Station ID\n",
+		},
+		{
+			desc: "struct with a track",
+			v:    recordingEntry{Path: "/tmp/reba.mp3", Track: playRecord(reba)},
+			want: "Path: /tmp/reba.mp3\nTrack: Phish - Reba (Sat 22-Nov-1997)\n",
+		},
+		{
+			desc: "nothing",
+			v:    TrackList{},
+			want: "Nothing to show.\n",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			var b strings.Builder
+			if err := writePlain(&b, tc.v, config{}); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// defaultStreamURLFormat is the URL of a radio.co station's audio stream,
+// given its station ID.
+const defaultStreamURLFormat = "https://streaming.radio.co/%s/listen"
+
+// defaultPlayers are the players ph play looks for on the PATH, in order of
+// preference, with the arguments that play a stream without a window.
+var defaultPlayers = [][]string{
+	{"mpv", "--no-video", "--really-quiet"},
+	{"ffplay", "-nodisp", "-loglevel", "error"},
+}
+
+// playConfig holds settings for ph play, which plays the station's stream.
+type playConfig struct {
+	Player    string `yaml:"player,omitempty" doc:"Command to play the stream with, given the stream URL as its last argument (default: mpv or ffplay, whichever is on the PATH)"`
+	StreamURL string `yaml:"stream_url,omitempty" doc:"URL of the station's audio stream (default: the station's radio.co stream)"`
+}
+
+// streamURL returns the URL of the audio stream of station.
+func (c playConfig) streamURL(station string) string {
+	if c.StreamURL != "" {
+		return c.StreamURL
+	}
+	return fmt.Sprintf(defaultStreamURLFormat, station)
+}
+
+// playerCommand returns the command line that plays url.
+func (c playConfig) playerCommand(url string) ([]string, error) {
+	if c.Player != "" {
+		return append(strings.Fields(c.Player), url), nil
+	}
+	for _, p := range defaultPlayers {
+		if _, err := exec.LookPath(p[0]); err == nil {
+			return append(append([]string(nil), p...), url), nil
+		}
+	}
+	return nil, errors.New("no player found; install mpv or ffplay, or set play.player in the config file")
+}
+
+// player runs an external player for the stream. Since the stream is live,
+// pausing stops the player, and playing starts it again at the live point.
+//
+// The volume can be set for mpv and ffplay when the player starts, and
+// changed while it plays for mpv, through its IPC socket.
+type player struct {
+	argv []string
+	name string
+
+	// ipcPath is the path of mpv's IPC socket, if the player is mpv.
+	ipcPath string
+
+	// exited receives the result of the player exiting other than by
+	// being stopped.
+	exited chan error
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	volume int // 0 to 100, or -1 for the player's default
+	muted  bool
+}
+
+func newPlayer(argv []string) *player {
+	p := &player{
+		argv:   argv,
+		name:   strings.TrimSuffix(filepath.Base(argv[0]), ".exe"),
+		exited: make(chan error, 1),
+		volume: -1,
+	}
+	if p.name == "mpv" && runtime.GOOS != "windows" {
+		p.ipcPath = filepath.Join(os.TempDir(), fmt.Sprintf("ph-mpv-%d.sock", os.Getpid()))
+	}
+	return p
+}
+
+// command returns the command line that starts the player at the current
+// volume, muted if it is muted. The stream URL stays the last argument.
+func (p *player) command() []string {
+	var (
+		n    = len(p.argv) - 1
+		args = append([]string(nil), p.argv[:n]...)
+	)
+	switch {
+	case p.name == "mpv":
+		if p.ipcPath != "" {
+			args = append(args, "--input-ipc-server="+p.ipcPath)
+		}
+		if p.volume >= 0 {
+			args = append(args, fmt.Sprintf("--volume=%d", p.volume))
+		}
+		if p.muted {
+			args = append(args, "--mute=yes")
+		}
+	case p.name == "ffplay" && p.muted:
+		args = append(args, "-volume", "0")
+	case p.name == "ffplay" && p.volume >= 0:
+		args = append(args, "-volume", strconv.Itoa(p.volume))
+	}
+	return append(args, p.argv[n])
+}
+
+// canChangeVolume reports whether the volume can be changed while the
+// player is playing.
+func (p *player) canChangeVolume() bool {
+	return p.ipcPath != ""
+}
+
+// setVolume sets the volume, from 0 to 100. If the player is playing, the
+// change takes effect immediately if the player supports it; otherwise, it
+// takes effect when the player next starts.
+func (p *player) setVolume(v int) error {
+	if v < 0 {
+		v = 0
+	}
+	if v > 100 {
+		v = 100
+	}
+	p.mu.Lock()
+	p.volume = v
+	playing := p.cmd != nil
+	p.mu.Unlock()
+	if !playing {
+		return nil
+	}
+	if !p.canChangeVolume() {
+		return fmt.Errorf("%s does not support changing the volume while playing", p.name)
+	}
+	return mpvCommand(p.ipcPath, "set_property", "volume", v)
+}
+
+// volumeLevel returns the volume, taking the player's default to be full
+// volume.
+func (p *player) volumeLevel() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.volume < 0 {
+		return 100
+	}
+	return p.volume
+}
+
+// setMuted mutes or unmutes the player, which, like setVolume, takes effect
+// immediately if the player supports it.
+func (p *player) setMuted(muted bool) error {
+	p.mu.Lock()
+	p.muted = muted
+	playing := p.cmd != nil
+	p.mu.Unlock()
+	if !playing {
+		return nil
+	}
+	if !p.canChangeVolume() {
+		return fmt.Errorf("%s does not support muting while playing", p.name)
+	}
+	return mpvCommand(p.ipcPath, "set_property", "mute", muted)
+}
+
+// isMuted reports whether the player is muted.
+func (p *player) isMuted() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.muted
+}
+
+// volumeRampSteps is how many steps the volume is changed in while ramping
+// it up or down.
+const volumeRampSteps = 30
+
+// rampVolume changes the player's volume evenly from one level to another
+// over the given duration.
+func rampVolume(p *player, from, to int, over time.Duration) {
+	step := over / volumeRampSteps
+	for i := 1; i <= volumeRampSteps; i++ {
+		time.Sleep(step)
+		if !p.playing() {
+			return
+		}
+		// The player may not be ready for commands at first, so a failed
+		// step is left for the next one to catch up.
+		if err := p.setVolume(from + (to-from)*i/volumeRampSteps); err != nil && i == volumeRampSteps {
+			warn(fmt.Errorf("unable to set volume: %w", err))
+		}
+	}
+}
+
+// mpvCommand sends a command to mpv through its IPC socket, and waits for
+// mpv to acknowledge it.
+func mpvCommand(path string, command ...interface{}) error {
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		return fmt.Errorf("connect to mpv: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	const requestID = 1
+	req := map[string]interface{}{"command": command, "request_id": requestID}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("send mpv command: %w", err)
+	}
+	// mpv may send events on the socket before the reply.
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		var reply struct {
+			Error     string `json:"error"`
+			RequestID int    `json:"request_id"`
+		}
+		if json.Unmarshal(sc.Bytes(), &reply) != nil || reply.Error == "" || reply.RequestID != requestID {
+			continue
+		}
+		if reply.Error != "success" {
+			return fmt.Errorf("mpv %v: %s", command[0], reply.Error)
+		}
+		return nil
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read mpv reply: %w", err)
+	}
+	return errors.New("mpv closed the connection without replying")
+}
+
+func (p *player) start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd != nil {
+		return nil
+	}
+	argv := p.command()
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start %s: %w", p.argv[0], err)
+	}
+	p.cmd = cmd
+	go func() {
+		err := cmd.Wait()
+		p.mu.Lock()
+		stopped := p.cmd != cmd
+		if !stopped {
+			p.cmd = nil
+		}
+		p.mu.Unlock()
+		if !stopped {
+			select {
+			case p.exited <- err:
+			default:
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *player) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return
+	}
+	p.cmd.Process.Kill()
+	p.cmd = nil
+}
+
+func (p *player) playing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cmd != nil
+}
+
+// nowPlayingInfo is what ph play tells now-playing helpers about the
+// stream.
+type nowPlayingInfo struct {
+	Title      string    `json:"title"`
+	Artist     string    `json:"artist,omitempty"`
+	Album      string    `json:"album,omitempty"`
+	ArtworkURL string    `json:"artwork_url,omitempty"`
+	StartTime  time.Time `json:"start_time,omitempty"`
+	Playing    bool      `json:"playing"`
+}
+
+// newNowPlayingInfo describes t for a now-playing display. A track from a
+// show is shown with the show as its album; otherwise, the station is.
+func newNowPlayingInfo(t Track, playing bool) nowPlayingInfo {
+	info := nowPlayingInfo{
+		Title:      t.Title,
+		Artist:     t.Artist,
+		Album:      "JEMP Radio",
+		ArtworkURL: t.ArtworkURL,
+		StartTime:  t.StartTime,
+		Playing:    playing,
+	}
+	if pt := t.PerformanceTime; !pt.IsZero() {
+		info.Album = strings.TrimSpace(pt.Format("2006-01-02") + " " + t.Location)
+	}
+	return info
+}
+
+// nowPlayingHelper is a plugin that keeps running alongside ph play,
+// publishing the current track to the system's now-playing display, such
+// as the macOS Now Playing widget, which only a native helper can reach.
+// The helper is run as "ph-<name> now_playing", and receives a
+// nowPlayingInfo as a line of JSON on standard input each time the track or
+// playback state changes. It can write "play", "pause", "toggle", or "stop"
+// on a line of standard output when the user presses a media key.
+// Standard input is closed when ph play exits.
+type nowPlayingHelper struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	enc   *json.Encoder
+}
+
+// startNowPlayingHelper starts the named helper, passing each command it
+// writes to onCommand.
+func startNowPlayingHelper(name string, onCommand func(string)) (*nowPlayingHelper, error) {
+	path, ok := lookupPlugin(name)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s not found on PATH", name)
+	}
+	cmd := exec.Command(path, "now_playing")
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin %s now_playing: %w", name, err)
+	}
+	go func() {
+		sc := bufio.NewScanner(stdout)
+		for sc.Scan() {
+			if c := strings.TrimSpace(sc.Text()); c != "" {
+				onCommand(c)
+			}
+		}
+	}()
+	return &nowPlayingHelper{name: name, cmd: cmd, stdin: stdin, enc: json.NewEncoder(stdin)}, nil
+}
+
+func (h *nowPlayingHelper) update(info nowPlayingInfo) error {
+	if err := h.enc.Encode(info); err != nil {
+		return fmt.Errorf("plugin %s now_playing: %w", h.name, err)
+	}
+	return nil
+}
+
+// close closes the helper's input, giving it a moment to clear the display
+// and exit before it is killed.
+func (h *nowPlayingHelper) close() {
+	h.stdin.Close()
+	done := make(chan struct{})
+	go func() {
+		h.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(pluginTimeout):
+		h.cmd.Process.Kill()
+		<-done
+	}
+}
+
+// runPlay plays the station's stream with an external player, printing each
+// new track and publishing it to any now-playing helpers, until
+// interrupted. Given a command, such as pause, it instead sends the command
+// to the ph play already running.
+func runPlay(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	// Commands are checked for before flags are parsed, so that a volume
+	// change such as -5 is not taken for a flag.
+	if len(args) > 0 && containsString(playCommands, args[0]) {
+		st, err := openStore()
+		if err != nil {
+			return err
+		}
+		status, err := sendPlayCommand(st, strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		fmt.Println(status)
+		return nil
+	}
+	var (
+		fs       = flag.NewFlagSet("play", flag.ExitOnError)
+		interval = fs.Duration("interval", 15*time.Second, "How often to check the station for a new track")
+		sleep    = fs.Duration("sleep", 0, "Fade out and stop after this long, recording where playback stopped for ph missed")
+		volume   = fs.Int("volume", 100, "Volume to play at, from 0 to 100")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ph play [flags]\n       ph play <%s> [argument]\n\nFlags:\n", strings.Join(playCommands, "|"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *volume < 0 || *volume > 100 {
+		return fmt.Errorf("invalid volume %d: must be from 0 to 100", *volume)
+	}
+
+	argv, err := cfg.Play.playerCommand(cfg.Play.streamURL(cfg.station()))
+	if err != nil {
+		return err
+	}
+	p := newPlayer(argv)
+	if fs.Changed("volume") {
+		p.setVolume(*volume)
+	}
+	return playStream(cfg, p, *interval, nil, *sleep)
+}
+
+// sleepFade is how long playback fades out for when the sleep timer ends.
+const sleepFade = 30 * time.Second
+
+// playStream plays the stream with p, printing each new track and
+// publishing it to any now-playing helpers, until interrupted. If started
+// is not nil, it is run once the player has started. If sleep is not zero,
+// playback fades out and stops after that long, and where it stopped is
+// recorded for ph missed.
+func playStream(cfg config, p *player, interval time.Duration, started func(), sleep time.Duration) error {
+	var err error
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	var (
+		requests = make(chan playRequest, 1)
+		helpers  []*nowPlayingHelper
+	)
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	// Tracks new to the user are marked as they air.
+	heard := loadHeardBefore(st)
+	l, err := listenPlayControl(st, requests)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	for _, name := range cfg.Plugins.NowPlaying {
+		h, err := startNowPlayingHelper(name, func(c string) { requests <- playRequest{command: c} })
+		if err != nil {
+			warn(err)
+			continue
+		}
+		defer h.close()
+		helpers = append(helpers, h)
+	}
+	var (
+		mu      sync.Mutex
+		current Track
+		publish = func() {
+			mu.Lock()
+			info := newNowPlayingInfo(current, p.playing())
+			mu.Unlock()
+			for _, h := range helpers {
+				if err := h.update(info); err != nil {
+					warn(err)
+				}
+			}
+		}
+	)
+	if err := p.start(); err != nil {
+		return err
+	}
+	defer p.stop()
+
+	// Each track listened to long enough is scrobbled when the next starts,
+	// or when playback stops, heard until end, and length long if known.
+	// Listens that could not be scrobbled before are retried first.
+	var (
+		playbackStarted = time.Now()
+		scrobbling      sync.WaitGroup
+		scrobble        = func(t Track, end time.Time, length time.Duration) {}
+	)
+	if scrobblers := cfg.Scrobble.scrobblers(&http.Client{Transport: cfg.Network.roundTripper(), Timeout: scrobbleTimeout}); len(scrobblers) > 0 {
+		var (
+			q  = &scrobbleQueue{store: st, scrobblers: scrobblers, now: time.Now}
+			rc = newRelistenClient(cfg)
+		)
+		scrobble = func(t Track, end time.Time, length time.Duration) {
+			scrobbling.Add(1)
+			go func() {
+				defer scrobbling.Done()
+				ls, err := cfg.Scrobble.listens(context.Background(), rc, relistenArtists, t, playbackStarted, end, length)
+				if err != nil {
+					warn(fmt.Errorf("unable to scrobble: %w", err))
+				}
+				if len(ls) == 0 {
+					return
+				}
+				if _, err := q.submit(ls...); err != nil {
+					warn(fmt.Errorf("unable to scrobble, will retry: %w", err))
+				}
+			}()
+		}
+		scrobbling.Add(1)
+		go func() {
+			defer scrobbling.Done()
+			if _, err := q.retry(); err != nil {
+				warn(fmt.Errorf("unable to scrobble, will retry: %w", err))
+			}
+		}()
+	}
+	defer func() {
+		mu.Lock()
+		t := current
+		mu.Unlock()
+		scrobble(t, time.Now(), 0)
+		scrobbling.Wait()
+	}()
+	defer readPlayKeys(requests)()
+	if started != nil {
+		go started()
+	}
+	var slept chan struct{}
+	if sleep > 0 {
+		slept = make(chan struct{})
+		go func() {
+			fade := sleepFade
+			if fade > sleep || !p.canChangeVolume() {
+				fade = 0
+			}
+			time.Sleep(sleep - fade)
+			if fade > 0 {
+				rampVolume(p, p.volumeLevel(), 0, fade)
+			}
+			close(slept)
+		}()
+	}
+
+	var (
+		b      = &bus{}
+		events = b.subscribe(1)
+	)
+	go func() {
+		for e := range events {
+			fetched, ok := e.(statusFetched)
+			if !ok {
+				continue
+			}
+			t := fetched.Status.CurrentTrack
+			mu.Lock()
+			previous := current
+			changed := !previous.Key().Equal(t.Key())
+			current = t
+			mu.Unlock()
+			if changed {
+				// The previous track was heard until this one started,
+				// which is also the only measure of how long it was.
+				var (
+					end    = fetched.Time
+					length time.Duration
+				)
+				if !t.StartTime.IsZero() && !previous.StartTime.IsZero() && t.StartTime.After(previous.StartTime) {
+					end, length = t.StartTime, t.StartTime.Sub(previous.StartTime)
+				}
+				scrobble(previous, end, length)
+				line := trackSummary(t)
+				if heard.novelty(t) != "" {
+					line = newMarker + " " + line
+				}
+				heard.add(t)
+				fmt.Println(line)
+				publish()
+			}
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	poll := &poller{
+		radioCo:  newRadioCoClient(httpClient(cfg), cfg),
+		station:  cfg.station(),
+		interval: interval,
+		bus:      b,
+	}
+	go func() {
+		poll.run(ctx)
+		b.close()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-slept:
+			p.stop()
+			mu.Lock()
+			stopped := playbackStop{Time: time.Now(), Track: playRecord(current)}
+			mu.Unlock()
+			if err := st.writeJSON(playbackStopFile, stopped); err != nil {
+				return fmt.Errorf("record where playback stopped: %w", err)
+			}
+			fmt.Printf("Stopped at %s; run ph missed to see what aired since\n", stopped.Time.Format("15:04"))
+			return nil
+		case err := <-p.exited:
+			// An interrupt from the terminal also reaches the player, so
+			// its exit is not an error if ph is being interrupted too.
+			select {
+			case <-signals:
+				return nil
+			case <-time.After(100 * time.Millisecond):
+			}
+			if err != nil {
+				return fmt.Errorf("%s exited: %w", p.argv[0], err)
+			}
+			return nil
+		case r := <-requests:
+			status, stop, err := doPlayCommand(p, r.command)
+			if r.reply != nil {
+				r.reply(status, err)
+			} else if err != nil {
+				warn(err)
+			}
+			if stop {
+				return nil
+			}
+			publish()
+		}
+	}
+}
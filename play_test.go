@@ -0,0 +1,152 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlayConfig(t *testing.T) {
+	var c playConfig
+	if got, want := c.streamURL("sd71de59b3"), "https://streaming.radio.co/sd71de59b3/listen"; got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+	c = playConfig{Player: "mpv --no-video --volume=50", StreamURL: "https://example.com/stream"}
+	got, err := c.playerCommand(c.streamURL("sd71de59b3"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"mpv", "--no-video", "--volume=50", "https://example.com/stream"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
+
+func TestNewNowPlayingInfo(t *testing.T) {
+	tt := []struct {
+		desc  string
+		track Track
+		want  nowPlayingInfo
+	}{
+		{
+			desc: "live track",
+			track: Track{
+				Artist:          "Phish",
+				Title:           "Reba",
+				PerformanceTime: mustParseDate("1997-11-22"),
+				Location:        "Hampton, VA",
+				ArtworkURL:      "https://images.radio.co/reba.jpg",
+			},
+			want: nowPlayingInfo{
+				Title:      "Reba",
+				Artist:     "Phish",
+				Album:      "1997-11-22 Hampton, VA",
+				ArtworkURL: "https://images.radio.co/reba.jpg",
+				Playing:    true,
+			},
+		},
+		{
+			desc:  "studio track",
+			track: Track{Artist: "Cream", Title: "Crossroads"},
+			want:  nowPlayingInfo{Title: "Crossroads", Artist: "Cream", Album: "JEMP Radio", Playing: true},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := newNowPlayingInfo(tc.track, true); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %+v, but got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNowPlayingHelper(t *testing.T) {
+	// The helper acknowledges each update by asking to pause, echoing the
+	// title it received, and exits when its input is closed.
+	installTestPlugin(t, "testnowplaying", `
+[ "$1" = now_playing ] || exit 1
+while read -r line; do
+	echo "pause $(echo "$line" | sed 's/.*"title":"\([^"]*\)".*/\1/')"
+done
+`)
+	commands := make(chan string, 1)
+	h, err := startNowPlayingHelper("testnowplaying", func(c string) { commands <- c })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := h.update(newNowPlayingInfo(Track{Artist: "Phish", Title: "Reba"}, true)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case c := <-commands:
+		if c != "pause Reba" {
+			t.Errorf("wanted %q, but got %q", "pause Reba", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the helper")
+	}
+	h.close()
+	if !h.cmd.ProcessState.Exited() {
+		t.Error("wanted the helper to exit when its input was closed")
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	p := newPlayer(strings.Fields("sleep 60"))
+	if err := p.start(); err != nil {
+		t.Skipf("unable to run sleep: %v", err)
+	}
+	if !p.playing() {
+		t.Error("wanted the player to be playing after start")
+	}
+	p.stop()
+	if p.playing() {
+		t.Error("wanted the player to be stopped after stop")
+	}
+	select {
+	case err := <-p.exited:
+		t.Errorf("wanted stopping not to be reported as an exit, but got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	p = newPlayer(strings.Fields("false"))
+	if err := p.start(); err != nil {
+		t.Skipf("unable to run false: %v", err)
+	}
+	select {
+	case err := <-p.exited:
+		if err == nil {
+			t.Error("wanted the player's failure to be reported, but got nil")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the player to exit")
+	}
+}
+
+func TestPlayerCommand(t *testing.T) {
+	tt := []struct {
+		desc   string
+		argv   string
+		volume int
+		want   string
+	}{
+		{desc: "mpv default volume", argv: "mpv --no-video URL", volume: -1, want: "mpv --no-video --input-ipc-server=IPC URL"},
+		{desc: "mpv volume", argv: "mpv URL", volume: 40, want: "mpv --input-ipc-server=IPC --volume=40 URL"},
+		{desc: "ffplay volume", argv: "/usr/bin/ffplay -nodisp URL", volume: 0, want: "/usr/bin/ffplay -nodisp -volume 0 URL"},
+		{desc: "other player", argv: "vlc URL", volume: 40, want: "vlc URL"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			p := newPlayer(strings.Fields(tc.argv))
+			p.volume = tc.volume
+			got := strings.Join(p.command(), " ")
+			if p.ipcPath != "" {
+				got = strings.Replace(got, p.ipcPath, "IPC", 1)
+			}
+			if got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
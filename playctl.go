@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// playControlSocket is the name of the socket, in the data directory, that
+// a running ph play listens on for commands from other terminals.
+const playControlSocket = "play.sock"
+
+// playCommands are the commands ph play accepts from other terminals, from
+// now-playing helpers, and, through playKeys, from the keyboard.
+var playCommands = []string{"play", "resume", "pause", "toggle", "stop", "mute", "unmute", "volume", "status"}
+
+// playKeys maps the keys ph play responds to to their commands.
+var playKeys = map[byte]string{
+	' ': "toggle",
+	'p': "toggle",
+	'm': "mute",
+	'+': "volume +5",
+	'=': "volume +5",
+	'-': "volume -5",
+	'_': "volume -5",
+	'q': "stop",
+}
+
+// playRequest is a command for a running ph play. If reply is not nil, it
+// is called with the result.
+type playRequest struct {
+	command string
+	reply   func(text string, err error)
+}
+
+// parseVolume parses a volume given to the volume command: a level from 0
+// to 100, or a change to the current level, such as +5 or -10.
+func parseVolume(arg string, current int) (int, error) {
+	v, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid volume %q: use a level from 0 to 100, or a change such as +5 or -5", arg)
+	}
+	if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		v += current
+	}
+	if v < 0 {
+		v = 0
+	}
+	if v > 100 {
+		v = 100
+	}
+	return v, nil
+}
+
+// playerStatus describes whether the player is playing, and at what volume.
+func playerStatus(p *player) string {
+	state := "paused"
+	if p.playing() {
+		state = "playing"
+	}
+	status := fmt.Sprintf("%s at volume %d", state, p.volumeLevel())
+	if p.isMuted() {
+		status += " (muted)"
+	}
+	return status
+}
+
+// doPlayCommand carries out a command on the player, returning the
+// player's status afterward. It reports whether the command was to stop
+// playing altogether.
+//
+// "mute" with no argument toggles muting; "mute on" and "mute off", like
+// "unmute", set it. "volume" with no argument leaves the volume unchanged.
+func doPlayCommand(p *player, command string) (status string, stop bool, err error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "", false, errors.New("no command given")
+	}
+	name, args := fields[0], fields[1:]
+	switch name {
+	case "play", "resume":
+		err = p.start()
+	case "pause":
+		p.stop()
+	case "toggle":
+		if p.playing() {
+			p.stop()
+		} else {
+			err = p.start()
+		}
+	case "stop":
+		return "stopped", true, nil
+	case "mute":
+		muted := !p.isMuted()
+		if len(args) > 0 {
+			switch args[0] {
+			case "on":
+				muted = true
+			case "off":
+				muted = false
+			default:
+				return "", false, fmt.Errorf("invalid mute setting %q: must be on or off", args[0])
+			}
+		}
+		err = p.setMuted(muted)
+	case "unmute":
+		err = p.setMuted(false)
+	case "volume":
+		if len(args) == 0 {
+			break
+		}
+		var v int
+		if v, err = parseVolume(args[0], p.volumeLevel()); err == nil {
+			err = p.setVolume(v)
+		}
+	case "status":
+	default:
+		return "", false, fmt.Errorf("unknown command %q: must be one of %s", name, strings.Join(playCommands, ", "))
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return playerStatus(p), false, nil
+}
+
+// listenPlayControl listens on ph play's control socket, passing each
+// command received to requests. It fails if another ph play is already
+// listening.
+func listenPlayControl(st *store, requests chan<- playRequest) (net.Listener, error) {
+	if err := os.MkdirAll(st.dir, os.FileMode(0777)); err != nil {
+		return nil, err
+	}
+	path := st.path(playControlSocket)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		// A socket left behind by a ph play that did not exit cleanly
+		// refuses connections, and can be replaced.
+		if conn, dialErr := net.Dial("unix", path); dialErr == nil {
+			conn.Close()
+			return nil, errors.New("ph play is already running")
+		}
+		os.Remove(path)
+		if l, err = net.Listen("unix", path); err != nil {
+			return nil, fmt.Errorf("listen for ph play commands: %w", err)
+		}
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go servePlayControl(conn, requests)
+		}
+	}()
+	return l, nil
+}
+
+// servePlayControl answers a single command on a connection to the control
+// socket. The command is a line of text, and the answer is a line starting
+// with "ok" or "error".
+func servePlayControl(conn net.Conn, requests chan<- playRequest) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	done := make(chan string, 1)
+	requests <- playRequest{
+		command: strings.TrimSpace(line),
+		reply: func(text string, err error) {
+			if err != nil {
+				done <- "error " + err.Error()
+				return
+			}
+			done <- "ok " + text
+		},
+	}
+	fmt.Fprintln(conn, <-done)
+}
+
+// sendPlayCommand sends a command to a running ph play, returning its
+// answer.
+func sendPlayCommand(st *store, command string) (string, error) {
+	conn, err := net.DialTimeout("unix", st.path(playControlSocket), 2*time.Second)
+	if err != nil {
+		return "", errors.New("ph play is not running")
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := fmt.Fprintln(conn, command); err != nil {
+		return "", fmt.Errorf("send command to ph play: %w", err)
+	}
+	answer, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("read answer from ph play: %w", err)
+	}
+	answer = strings.TrimSpace(answer)
+	if strings.HasPrefix(answer, "error ") {
+		return "", errors.New(strings.TrimPrefix(answer, "error "))
+	}
+	return strings.TrimPrefix(answer, "ok "), nil
+}
+
+// readPlayKeys passes the commands for keys pressed in the terminal to
+// requests, with their results printed to standard error. It puts the
+// terminal in a mode that reads keys as they are pressed, and returns a
+// function that restores it. If standard input is not a terminal, keys are
+// not read.
+func readPlayKeys(requests chan<- playRequest) (restore func()) {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return func() {}
+	}
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return func() {}
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return func() {}
+	}
+	fmt.Fprintln(os.Stderr, "Keys: space pause/resume, m mute, + and - volume, q quit")
+	go func() {
+		b := make([]byte, 1)
+		for {
+			if _, err := os.Stdin.Read(b); err != nil {
+				return
+			}
+			command, ok := playKeys[b[0]]
+			if !ok {
+				continue
+			}
+			requests <- playRequest{command: command, reply: func(text string, err error) {
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					return
+				}
+				fmt.Fprintf(os.Stderr, "[%s]\n", text)
+			}}
+		}
+	}()
+	return func() { stty(saved) }
+}
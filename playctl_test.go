@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseVolume(t *testing.T) {
+	tt := []struct {
+		arg     string
+		want    int
+		wantErr bool
+	}{
+		{arg: "40", want: 40},
+		{arg: "+5", want: 55},
+		{arg: "-10", want: 40},
+		{arg: "+60", want: 100},
+		{arg: "-60", want: 0},
+		{arg: "150", want: 100},
+		{arg: "loud", wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.arg, func(t *testing.T) {
+			got, err := parseVolume(tc.arg, 50)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wanted an error, but got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("wanted %d, but got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDoPlayCommand(t *testing.T) {
+	// A player that is not playing takes volume changes for when it next
+	// starts, so no player needs to be run.
+	p := newPlayer(strings.Fields("ffplay URL"))
+	tt := []struct {
+		command  string
+		want     string
+		wantStop bool
+		wantErr  bool
+	}{
+		{command: "status", want: "paused at volume 100"},
+		{command: "volume 40", want: "paused at volume 40"},
+		{command: "volume -5", want: "paused at volume 35"},
+		{command: "mute", want: "paused at volume 35 (muted)"},
+		{command: "mute", want: "paused at volume 35"},
+		{command: "mute on", want: "paused at volume 35 (muted)"},
+		{command: "unmute", want: "paused at volume 35"},
+		{command: "mute loudly", wantErr: true},
+		{command: "rewind", wantErr: true},
+		{command: "stop", want: "stopped", wantStop: true},
+	}
+	for _, tc := range tt {
+		got, stop, err := doPlayCommand(p, tc.command)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: wanted an error, but got %q", tc.command, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.command, err)
+			continue
+		}
+		if got != tc.want || stop != tc.wantStop {
+			t.Errorf("%s: wanted %q (stop %t), but got %q (stop %t)", tc.command, tc.want, tc.wantStop, got, stop)
+		}
+	}
+	if want, got := "ffplay -volume 35 URL", strings.Join(p.command(), " "); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
+
+func TestPlayControl(t *testing.T) {
+	st := newTestStore(t)
+	if _, err := sendPlayCommand(st, "status"); err == nil {
+		t.Error("wanted an error with ph play not running, but got none")
+	}
+	var (
+		requests = make(chan playRequest)
+		errTest  = errors.New("cannot rewind live radio")
+	)
+	l, err := listenPlayControl(st, requests)
+	if err != nil {
+		t.Skipf("unable to listen on a unix socket: %v", err)
+	}
+	defer l.Close()
+	if _, err := listenPlayControl(st, requests); err == nil {
+		t.Error("wanted an error listening twice, but got none")
+	}
+	go func() {
+		for r := range requests {
+			if r.command == "rewind" {
+				r.reply("", errTest)
+				continue
+			}
+			r.reply("got "+r.command, nil)
+		}
+	}()
+	defer close(requests)
+
+	got, err := sendPlayCommand(st, "volume -5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "got volume -5"; got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+	if _, err := sendPlayCommand(st, "rewind"); err == nil || err.Error() != errTest.Error() {
+		t.Errorf("wanted error %v, but got %v", errTest, err)
+	}
+}
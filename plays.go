@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const playsFile = "plays.jsonl"
+
+// maxPlayLength is the longest a play is assumed to last when inferring its
+// end from the next recorded play. Gaps longer than this most likely mean
+// plays in between were not recorded.
+const maxPlayLength = 3 * time.Hour
+
+// playRecord is the stored form of a Track. Track implements
+// json.Unmarshaler to parse radio.co's raw titles, so plays are stored as
+// this type, which shares Track's fields but uses the default decoding.
+type playRecord Track
+
+// playID returns the ID of an airing, from its start time and title as the
+// station reported them, or the empty string if it has no start time.
+// Plays recorded before IDs were stored get the same ID when read back.
+func playID(t Track) string {
+	if t.StartTime.IsZero() {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(t.StartTime.UTC().Format(time.RFC3339) + "\x00" + t.Title))
+	return hex.EncodeToString(sum[:6])
+}
+
+// plays returns every play recorded in the store, oldest first, with
+// start times corrected by any track boundaries detected in recordings.
+func (s *store) plays() (TrackList, error) {
+	plays, err := s.recordedPlays()
+	if err != nil {
+		return nil, err
+	}
+	boundaries, err := s.boundaries()
+	if err != nil {
+		return nil, err
+	}
+	return adjustStartTimes(plays, boundaries, maxBoundaryShift), nil
+}
+
+// recordedPlays returns every play recorded in the store, oldest first, as
+// the station reported them. Lines that cannot be decoded, such as one
+// partially written when ph was interrupted, are skipped.
+func (s *store) recordedPlays() (TrackList, error) {
+	var plays TrackList
+	err := s.readJSONLines(playsFile, func(line []byte) error {
+		var r playRecord
+		if err := json.Unmarshal(line, &r); err == nil {
+			if r.PlayID == "" {
+				r.PlayID = playID(Track(r))
+			}
+			plays = append(plays, Track(r))
+		}
+		return nil
+	})
+	return plays, err
+}
+
+// recentPlaysTail is how much of the end of the play history recordPlay
+// reads to check whether a track was already recorded, enough for dozens
+// of plays.
+const recentPlaysTail = 16 * 1024
+
+// recordPlay appends a track to the play history, unless it has no start
+// time or was already recorded. It reports whether the track was recorded.
+func (s *store) recordPlay(t Track) (bool, error) {
+	if t.StartTime.IsZero() {
+		return false, nil
+	}
+	if t.PlayID == "" {
+		t.PlayID = playID(t)
+	}
+	// The same track is seen on every run while it is playing, so only the
+	// most recent plays need to be checked, and only the end of the history
+	// is read. They are compared by ID, which ph db enrich leaves alone when
+	// it corrects a title.
+	var seen bool
+	err := s.readJSONLinesTail(playsFile, recentPlaysTail, func(line []byte) error {
+		var r playRecord
+		if err := json.Unmarshal(line, &r); err == nil {
+			if r.PlayID == "" {
+				r.PlayID = playID(Track(r))
+			}
+			seen = seen || r.PlayID == t.PlayID
+		}
+		return nil
+	})
+	if err != nil || seen {
+		return false, err
+	}
+	if err := s.appendJSONLine(playsFile, playRecord(t)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// replacePlays replaces the play history with plays, oldest first, for
+// upgrading plays recorded by older versions of ph.
+func (s *store) replacePlays(plays TrackList) error {
+	var b []byte
+	for _, t := range plays {
+		line, err := json.Marshal(playRecord(t))
+		if err != nil {
+			return err
+		}
+		b = append(append(b, line...), '\n')
+	}
+	if err := os.MkdirAll(s.dir, os.FileMode(0777)); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(playsFile), b)
+}
+
+// play returns the recorded play with the given ID, and reports whether
+// there is one.
+func (s *store) play(id string) (Track, bool, error) {
+	plays, err := s.plays()
+	if err != nil {
+		return Track{}, false, err
+	}
+	for i := len(plays) - 1; i >= 0; i-- {
+		if plays[i].PlayID == id {
+			return plays[i], true, nil
+		}
+	}
+	return Track{}, false, nil
+}
+
+// playDuration estimates how long each play lasted, from the start time of
+// the play that followed it. The last play, or a play followed by a gap
+// longer than max, is assumed to have lasted fallback.
+func playDuration(plays TrackList, i int, max, fallback time.Duration) time.Duration {
+	if i+1 < len(plays) {
+		if d := plays[i+1].StartTime.Sub(plays[i].StartTime); d > 0 && d <= max {
+			return d
+		}
+	}
+	return fallback
+}
+
+// playLength estimates how long the play at index i lasted, assuming ten
+// minutes for a song and an hour for a full show when it cannot be inferred.
+func playLength(plays TrackList, i int) time.Duration {
+	fallback := 10 * time.Minute
+	if plays[i].IsFullShow() {
+		fallback = time.Hour
+	}
+	return playDuration(plays, i, maxPlayLength, fallback)
+}
+
+// recordPlays records the current track of each status published on the
+// bus, until the bus is closed. Errors are reported to onError.
+func (s *store) recordPlays(recent *playRing, events <-chan interface{}, onError func(error)) {
+	for e := range events {
+		if e, ok := e.(statusFetched); ok {
+			if _, err := s.recordRecentPlay(recent, e.Status.CurrentTrack); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plugins are executables named "ph-<name>" found on the PATH. A plugin can
+// be run as a ph subcommand ("ph <name>"), in which case it receives the
+// current track as JSON on standard input, as git does for "git-<name>"
+// executables. Plugins listed in the config file are also invoked at
+// extension points, with the name of the extension point as the first
+// argument and the track as JSON on standard input. At the "links"
+// extension point, the plugin prints a JSON array of {"label": ..., "url":
+// ...} objects linking to the track elsewhere. At the "notify" extension
+// point, the plugin instead receives a {"subject": ..., "text": ...}
+// notification to deliver. At the "now_playing" extension point, the plugin
+// runs for as long as ph play does; see nowPlayingHelper.
+const pluginPrefix = "ph-"
+
+// pluginTimeout bounds how long ph waits for a plugin at an extension
+// point, so that a misbehaving plugin cannot hang ph.
+const pluginTimeout = 5 * time.Second
+
+// pluginsConfig lists the plugins to invoke at each extension point.
+type pluginsConfig struct {
+	Links      []string `yaml:"links,omitempty" doc:"Plugins that provide links for the current track"`
+	NowPlaying []string `yaml:"now_playing,omitempty" doc:"Plugins that show the current track in the system's now-playing display while ph play runs, such as the macOS Now Playing widget"`
+}
+
+// pluginLink is a link to a track, provided by a plugin.
+type pluginLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// String returns the link as text output shows it, as in "Label: URL", or
+// just the URL if the plugin did not label it.
+func (l pluginLink) String() string {
+	if l.Label == "" {
+		return l.URL
+	}
+	return l.Label + ": " + l.URL
+}
+
+// findPlugins returns the names of all plugins on the PATH, without the
+// "ph-" prefix. When more than one executable has the same name, the first
+// on the PATH wins, as it would when executed.
+func findPlugins() map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !strings.HasPrefix(name, pluginPrefix) || e.IsDir() || e.Mode()&0111 == 0 {
+				continue
+			}
+			name = strings.TrimSuffix(strings.TrimPrefix(name, pluginPrefix), ".exe")
+			if _, ok := plugins[name]; !ok {
+				plugins[name] = filepath.Join(dir, e.Name())
+			}
+		}
+	}
+	return plugins
+}
+
+// lookupPlugin finds the executable for the named plugin on the PATH.
+func lookupPlugin(name string) (string, bool) {
+	path, err := exec.LookPath(pluginPrefix + name)
+	return path, err == nil
+}
+
+// runPluginCommand runs a plugin as a subcommand, passing through args and
+// providing the current track on standard input.
+func runPluginCommand(path string, args []string, track Track) error {
+	input, err := json.Marshal(track)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// callPlugin invokes a plugin at an extension point, passing v as JSON on
+// standard input, and returns what the plugin writes to standard output.
+func callPlugin(name, hook string, v interface{}) ([]byte, error) {
+	path, ok := lookupPlugin(name)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s not found on PATH", name)
+	}
+	input, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, hook)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("plugin %s %s: %w: %s", name, hook, err, msg)
+		}
+		return nil, fmt.Errorf("plugin %s %s: %w", name, hook, err)
+	}
+	return out, nil
+}
+
+// pluginLinks asks each of the named plugins for links to t. Plugins that
+// fail are reported in the returned error, but do not prevent links from
+// other plugins from being returned.
+func pluginLinks(names []string, t Track) ([]pluginLink, error) {
+	var (
+		links []pluginLink
+		errs  []string
+	)
+	for _, name := range names {
+		out, err := callPlugin(name, "links", t)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		var pl []pluginLink
+		if len(bytes.TrimSpace(out)) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(out, &pl); err != nil {
+			errs = append(errs, fmt.Sprintf("plugin %s links: invalid output: %v", name, err))
+			continue
+		}
+		for _, l := range pl {
+			if l.URL != "" {
+				links = append(links, l)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return links, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return links, nil
+}
+
+// runPlugins lists the plugins available on the PATH.
+func runPlugins(args []string) error {
+	plugins := findPlugins()
+	if len(plugins) == 0 {
+		fmt.Println("No plugins found. Plugins are executables named ph-<name> on your PATH.")
+		return nil
+	}
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%-16s %s\n", name, plugins[name])
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// installTestPlugin writes a shell script plugin into a temporary directory
+// and puts that directory on the PATH for the duration of the test.
+func installTestPlugin(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins are not supported on Windows")
+	}
+	dir, err := ioutil.TempDir("", "ph-plugins")
+	if err != nil {
+		t.Fatalf("unable to create plugin dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, pluginPrefix+name)
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("unable to write plugin: %v", err)
+	}
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+	t.Cleanup(func() { os.Setenv("PATH", oldPath) })
+}
+
+func TestPluginLinks(t *testing.T) {
+	// The plugin echoes the artist it received back in its link, to show
+	// that the track is passed on standard input.
+	installTestPlugin(t, "testlinks", `
+[ "$1" = links ] || exit 1
+artist=$(sed -n 's/.*"artist":"\([^"]*\)".*/\1/p')
+echo "[{\"label\": \"Test\", \"url\": \"https://example.com/$artist\"}]"
+`)
+	installTestPlugin(t, "testbroken", `echo "not json"`)
+
+	if plugins := findPlugins(); plugins["testlinks"] == "" {
+		t.Errorf("plugin not found on PATH: %v", plugins)
+	}
+	links, err := pluginLinks([]string{"testlinks", "testbroken", "testmissing"}, Track{Artist: "Phish", Title: "Mercury"})
+	if err == nil {
+		t.Errorf("wanted an error for broken and missing plugins")
+	}
+	want := []pluginLink{{Label: "Test", URL: "https://example.com/Phish"}}
+	if len(links) != 1 || links[0] != want[0] {
+		t.Errorf("wanted links %v, but got %v", want, links)
+	}
+}
+
+func TestPluginLink_String(t *testing.T) {
+	tt := []struct {
+		link pluginLink
+		want string
+	}{
+		{pluginLink{Label: "Lyrics", URL: "https://example.com/lyrics"}, "Lyrics: https://example.com/lyrics"},
+		{pluginLink{URL: "https://example.com/lyrics"}, "https://example.com/lyrics"},
+	}
+	for _, tc := range tt {
+		if got := tc.link.String(); got != tc.want {
+			t.Errorf("wanted %q, but got %q", tc.want, got)
+		}
+	}
+}
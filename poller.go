@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// poller periodically fetches the status of a station, publishing each
+// status, or the error fetching it, to a bus.
+type poller struct {
+	radioCo  radioCoClient
+	station  string
+	interval time.Duration
+	bus      *bus
+
+	// clock schedules the polls and times the events; appClock if nil.
+	clock clock
+
+	// mu guards the station and interval, which update changes while the
+	// poller runs, and changed tells run that they have.
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+// run polls until ctx is canceled, starting immediately, and again as soon
+// as the station or interval is changed by update.
+func (p *poller) run(ctx context.Context) {
+	for {
+		p.mu.Lock()
+		interval, changed := p.interval, p.changes()
+		p.mu.Unlock()
+		if !p.pollEvery(ctx, interval, changed) {
+			return
+		}
+	}
+}
+
+// pollEvery polls at interval until ctx is canceled, when it returns
+// false, or until changed receives, when it returns true.
+func (p *poller) pollEvery(ctx context.Context, interval time.Duration, changed <-chan struct{}) bool {
+	ticker := p.clockOrDefault().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+			return true
+		case <-ticker.C():
+		}
+	}
+}
+
+// update changes the station polled and how often, taking effect with a
+// poll right away.
+func (p *poller) update(station string, interval time.Duration) {
+	p.mu.Lock()
+	p.station, p.interval = station, interval
+	changed := p.changes()
+	p.mu.Unlock()
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+// changes returns the channel that tells run of changes, making it if
+// need be. p.mu must be held.
+func (p *poller) changes() chan struct{} {
+	if p.changed == nil {
+		p.changed = make(chan struct{}, 1)
+	}
+	return p.changed
+}
+
+func (p *poller) clockOrDefault() clock {
+	if p.clock != nil {
+		return p.clock
+	}
+	return appClock
+}
+
+func (p *poller) poll(ctx context.Context) {
+	p.mu.Lock()
+	station := p.station
+	p.mu.Unlock()
+	status, err := getStatus(ctx, p.radioCo, station)
+	if err != nil {
+		p.bus.publish(statusFailed{Err: err, Time: p.clockOrDefault().Now()})
+		return
+	}
+	p.bus.publish(statusFetched{Status: status, Time: p.clockOrDefault().Now()})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoller_Run(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"status":"online","current_track":{"title":"Phish - Reba"},"history":[]}`))
+	}))
+	defer srv.Close()
+
+	var (
+		start  = mustParseDate("2020-07-04T18:00:00")
+		clock  = &fakeClock{now: start}
+		b      = &bus{}
+		events = b.subscribe(1)
+		p      = &poller{radioCo: radioCoClient{client: srv.Client(), endpoint: srv.URL}, station: "s1", interval: time.Minute, bus: b, clock: clock}
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.run(ctx)
+
+	// next waits for the next status the poller publishes.
+	next := func() statusFetched {
+		t.Helper()
+		select {
+		case e := <-events:
+			fetched, ok := e.(statusFetched)
+			if !ok {
+				t.Fatalf("wanted a status, but got %#v", e)
+			}
+			return fetched
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a poll")
+		}
+		return statusFetched{}
+	}
+	if got := next(); !got.Time.Equal(start) {
+		t.Errorf("wanted the first poll at %s, but got %s", start, got.Time)
+	}
+	clock.Advance(30 * time.Second)
+	select {
+	case e := <-events:
+		t.Fatalf("wanted no poll before the interval, but got %#v", e)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(30 * time.Second)
+	if got, want := next().Time, start.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("wanted the second poll at %s, but got %s", want, got)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("wanted 2 requests, but got %d", n)
+	}
+}
+
+func TestPoller_Update(t *testing.T) {
+	stations := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stations <- r.URL.Path
+		w.Write([]byte(`{"status":"online","current_track":{"title":"Phish - Reba"},"history":[]}`))
+	}))
+	defer srv.Close()
+
+	var (
+		clock = &fakeClock{now: mustParseDate("2020-07-04T18:00:00")}
+		b     = &bus{}
+		p     = &poller{radioCo: radioCoClient{client: srv.Client(), endpoint: srv.URL}, station: "s1", interval: time.Minute, bus: b, clock: clock}
+	)
+	b.subscribe(4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.run(ctx)
+
+	next := func() string {
+		t.Helper()
+		select {
+		case s := <-stations:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a poll")
+		}
+		return ""
+	}
+	if got, want := next(), "/stations/s1/status"; got != want {
+		t.Errorf("wanted the first poll of %s, but got %s", want, got)
+	}
+	// A new station is polled right away, without waiting for the interval.
+	p.update("s2", time.Hour)
+	if got, want := next(), "/stations/s2/status"; got != want {
+		t.Errorf("wanted a poll of %s after the update, but got %s", want, got)
+	}
+	clock.Advance(time.Minute)
+	select {
+	case s := <-stations:
+		t.Fatalf("wanted no poll before the new interval, but got %s", s)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ianfoo/ph/relisten"
+)
+
+// showPosition describes where a track falls within the show it was
+// performed at, e.g., the fourth of eight tracks in the second set.
+type showPosition struct {
+	Set       string `json:"set"`
+	Track     int    `json:"track"`
+	SetTracks int    `json:"set_tracks" yaml:"set_tracks"`
+}
+
+func (p *showPosition) String() string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s, track %d of %d", p.Set, p.Track, p.SetTracks)
+}
+
+// nonAlphanumeric matches runs of characters that are ignored when
+// comparing song titles.
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// normalizeSongTitle reduces a song title to a form that can be compared
+// across sources that punctuate, capitalize, or abbreviate differently.
+func normalizeSongTitle(title string) string {
+	title = strings.ToLower(title)
+	title = strings.TrimSuffix(strings.TrimSpace(title), "...")
+	return strings.TrimSpace(nonAlphanumeric.ReplaceAllString(title, " "))
+}
+
+// firstSong returns the first song of a broadcast title, which may contain
+// several songs joined by segue markers, as in "Mercury>thru>Death Don't".
+func firstSong(title string) string {
+	if i := strings.IndexAny(title, ">→"); i > 0 {
+		return title[:i]
+	}
+	return title
+}
+
+// findShowPosition finds the track with the given title in the show's best
+// rated source.
+func findShowPosition(show relisten.Show, title string) (showPosition, bool) {
+	source, ok := bestSource(show)
+	if !ok {
+		return showPosition{}, false
+	}
+	set, track, ok := findSourceTrack(source, title)
+	if !ok {
+		return showPosition{}, false
+	}
+	return showPosition{
+		Set:       setName(source.Sets[set]),
+		Track:     track + 1,
+		SetTracks: len(source.Sets[set].Tracks),
+	}, true
+}
+
+// findSourceTrack finds the track with the given title in a source,
+// returning the indexes of its set and of the track within the set. Titles
+// must be equal after normalization. A broadcast title marked as truncated,
+// as in "Death Don't...", matches a track whose title begins with its whole
+// words.
+func findSourceTrack(source relisten.Source, title string) (int, int, bool) {
+	song := strings.TrimSpace(firstSong(title))
+	truncated := strings.HasSuffix(song, "...") || strings.HasSuffix(song, "…")
+	want := normalizeSongTitle(song)
+	if want == "" {
+		return 0, 0, false
+	}
+	for s, set := range source.Sets {
+		for i, track := range set.Tracks {
+			got := normalizeSongTitle(track.Title)
+			if got == "" {
+				continue
+			}
+			if got == want || (truncated && strings.HasPrefix(got, want+" ")) {
+				return s, i, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// bestSource returns the highest rated source for a show, preferring
+// soundboards when ratings are equal.
+func bestSource(show relisten.Show) (relisten.Source, bool) {
+	if len(show.Sources) == 0 {
+		return relisten.Source{}, false
+	}
+	best := show.Sources[0]
+	for _, s := range show.Sources[1:] {
+		if s.AvgRating > best.AvgRating || (s.AvgRating == best.AvgRating && s.IsSoundboard && !best.IsSoundboard) {
+			best = s
+		}
+	}
+	return best, true
+}
+
+func setName(set relisten.Set) string {
+	if set.Name != "" {
+		return set.Name
+	}
+	if set.IsEncore {
+		return "Encore"
+	}
+	return fmt.Sprintf("Set %d", set.Index+1)
+}
+
+// lookupShowPosition fetches the show that t was performed at from Relisten
+// and finds where t falls within it. It returns false if the track is not
+// from a show available on Relisten, or if it cannot be found in the show.
+func lookupShowPosition(ctx context.Context, rc *relisten.Client, artists map[string]string, t Track) (showPosition, bool, error) {
+	if t.Artist == "" || t.PerformanceTime.IsZero() {
+		return showPosition{}, false, nil
+	}
+	slug, ok := artists[t.Artist]
+	if !ok {
+		return showPosition{}, false, nil
+	}
+	show, err := rc.Show(ctx, slug, t.PerformanceTime)
+	if errors.Is(err, relisten.ErrNotFound) {
+		return showPosition{}, false, nil
+	}
+	if err != nil {
+		return showPosition{}, false, err
+	}
+	pos, ok := findShowPosition(show, t.Title)
+	return pos, ok, nil
+}
+
+// withShowPosition returns t with its position in its original show, if it
+// can be found. Failing to look it up is only logged.
+func withShowPosition(ctx context.Context, rc *relisten.Client, artists map[string]string, t Track) Track {
+	pos, ok, err := lookupShowPosition(ctx, rc, artists, t)
+	if err != nil {
+		warn(fmt.Errorf("unable to find track in original show: %w", err))
+	}
+	if ok {
+		t.Position = &pos
+	}
+	return t
+}
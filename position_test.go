@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ianfoo/ph/relisten"
+)
+
+func TestFindShowPosition(t *testing.T) {
+	show := relisten.Show{
+		Sources: []relisten.Source{
+			{
+				AvgRating: 3,
+				Sets: []relisten.Set{
+					{Index: 0, Name: "Set 1", Tracks: []relisten.Track{{Title: "Wrong Source"}}},
+				},
+			},
+			{
+				AvgRating: 4.5,
+				Sets: []relisten.Set{
+					{Index: 0, Name: "Set 1", Tracks: []relisten.Track{
+						{Title: "Wilson"},
+						{Title: "Maze"},
+						{Title: "Yarmouth Road"},
+					}},
+					{Index: 1, Name: "Set 2", Tracks: []relisten.Track{
+						{Title: "Mercury"},
+						{Title: "Death Don't Hurt Very Long"},
+						{Title: "Punch You in the Eye"},
+					}},
+					{Index: 2, IsEncore: true, Tracks: []relisten.Track{
+						{Title: "Tweezer Reprise"},
+					}},
+				},
+			},
+		},
+	}
+	tt := []struct {
+		title  string
+		want   showPosition
+		wantOK bool
+	}{
+		{"Maze", showPosition{Set: "Set 1", Track: 2, SetTracks: 3}, true},
+		{"Ya Mar", showPosition{}, false},
+		{"Yarmouth", showPosition{}, false},
+		{"Death Don't Hurt", showPosition{}, false},
+		{"Mercury>thru>Death Don't...", showPosition{Set: "Set 2", Track: 1, SetTracks: 3}, true},
+		{"Death Don't...", showPosition{Set: "Set 2", Track: 2, SetTracks: 3}, true},
+		{"Punch You In The Eye", showPosition{Set: "Set 2", Track: 3, SetTracks: 3}, true},
+		{"Tweezer Reprise", showPosition{Set: "Encore", Track: 1, SetTracks: 1}, true},
+		{"Wrong Source", showPosition{}, false},
+		{"Harry Hood", showPosition{}, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.title, func(t *testing.T) {
+			got, ok := findShowPosition(show, tc.title)
+			if ok != tc.wantOK {
+				t.Fatalf("wanted found=%t, but got %t", tc.wantOK, ok)
+			}
+			if got != tc.want {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLookupShowPosition_NotOnRelisten(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	rc := relisten.New(srv.Client(), "")
+	rc.BaseURL = srv.URL
+	rc.MinInterval = 0
+
+	track := Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")}
+	_, ok, err := lookupShowPosition(context.Background(), rc, map[string]string{"Phish": "phish"}, track)
+	if err != nil {
+		t.Errorf("wanted no error for a show not on Relisten, but got %v", err)
+	}
+	if ok {
+		t.Errorf("wanted no position for a show not on Relisten")
+	}
+}
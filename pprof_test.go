@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestStartPprof(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, err := startPprof(ctx, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := http.Get("http://" + addr.String() + "/debug/pprof/cmdline")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("wanted status %d, but got %d", http.StatusOK, resp.StatusCode)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// profilesDir is the directory of the store that each profile keeps its
+// own data, such as its listening queue, in.
+const profilesDir = "profiles"
+
+// serveProfile is one of the people sharing a ph server, with their own
+// favorite artists and listening queue.
+type serveProfile struct {
+	Name      string   `json:"name"`
+	Favorites []string `json:"favorites"`
+}
+
+// validProfileName matches the names profiles can have, which name their
+// directories in the store.
+var validProfileName = regexp.MustCompile(`^[\w-]+$`)
+
+// profiles parses serve.profiles, each given as name: favorite artists.
+func (c serveConfig) profiles() ([]serveProfile, error) {
+	var (
+		ps   []serveProfile
+		seen = make(map[string]bool)
+	)
+	for _, entry := range c.Profiles {
+		name, artists := entry, ""
+		if i := strings.Index(entry, ":"); i >= 0 {
+			name, artists = entry[:i], entry[i+1:]
+		}
+		name = strings.TrimSpace(name)
+		if !validProfileName.MatchString(name) {
+			return nil, fmt.Errorf("%q must be name: artists, with a name of letters, digits, - and _", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("profile %q is given more than once", name)
+		}
+		seen[name] = true
+		p := serveProfile{Name: name, Favorites: []string{}}
+		for _, a := range strings.Split(artists, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				p.Favorites = append(p.Favorites, a)
+			}
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// profileTokens parses serve.profile_tokens, each given as name: token,
+// into the profile each token chooses.
+func (c serveConfig) profileTokens() (map[string]string, error) {
+	ps, err := c.profiles()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(ps))
+	for _, p := range ps {
+		names[p.Name] = true
+	}
+	tokens := make(map[string]string, len(c.ProfileTokens))
+	for _, entry := range c.ProfileTokens {
+		i := strings.Index(entry, ":")
+		if i <= 0 || strings.TrimSpace(entry[i+1:]) == "" {
+			return nil, errors.New("each must be name: token")
+		}
+		name, token := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		if !names[name] {
+			return nil, fmt.Errorf("%q is not one of serve.profiles", name)
+		}
+		if _, ok := tokens[token]; ok {
+			return nil, fmt.Errorf("the token for %q is also another profile's", name)
+		}
+		tokens[token] = name
+	}
+	return tokens, nil
+}
+
+// tokenProfile returns the name of the profile whose token r carries, if
+// any.
+func tokenProfile(r *http.Request, tokens map[string]string) (string, bool) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return "", false
+	}
+	for t, name := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// bearerToken returns the bearer token in r's Authorization header, if
+// there is one.
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len("bearer ") || !strings.EqualFold(auth[:len("bearer ")], "bearer ") {
+		return "", false
+	}
+	return auth[len("bearer "):], true
+}
+
+// errUnknownProfile is returned for a request for a profile that is not
+// configured.
+var errUnknownProfile = errors.New("unknown profile")
+
+// profileFor returns the profile r is made for: the one its token is for,
+// or else the one named by its profile query parameter. It reports false
+// if r is for no profile. A request with one profile's token may not ask
+// for another's.
+func profileFor(r *http.Request, profiles []serveProfile, tokens map[string]string) (serveProfile, bool, error) {
+	name := r.URL.Query().Get("profile")
+	if tokenName, ok := tokenProfile(r, tokens); ok {
+		if name != "" && name != tokenName {
+			return serveProfile{}, false, fmt.Errorf("the token is for profile %q, not %q", tokenName, name)
+		}
+		name = tokenName
+	}
+	if name == "" {
+		return serveProfile{}, false, nil
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, true, nil
+		}
+	}
+	return serveProfile{}, false, fmt.Errorf("%w %q", errUnknownProfile, name)
+}
+
+// forProfile returns the store that the profile named name keeps its own
+// data in, or s itself if name is empty.
+func (s *store) forProfile(name string) *store {
+	if name == "" {
+		return s
+	}
+	return &store{dir: filepath.Join(s.dir, profilesDir, name)}
+}
+
+// checkProfile returns an error if name is not empty and is not one of the
+// profiles configured.
+func checkProfile(cfg config, name string) error {
+	if name == "" {
+		return nil
+	}
+	ps, err := cfg.Serve.profiles()
+	if err != nil {
+		return fmt.Errorf("serve.profiles: %w", err)
+	}
+	for _, p := range ps {
+		if p.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q: add it to serve.profiles", errUnknownProfile, name)
+}
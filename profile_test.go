@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestServeConfig_Profiles(t *testing.T) {
+	c := serveConfig{Profiles: []string{"alice: Phish, Goose", "bob:", "carol"}}
+	got, err := c.profiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []serveProfile{
+		{Name: "alice", Favorites: []string{"Phish", "Goose"}},
+		{Name: "bob", Favorites: []string{}},
+		{Name: "carol", Favorites: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		cfg  serveConfig
+	}{
+		{desc: "bad name", cfg: serveConfig{Profiles: []string{"../alice: Phish"}}},
+		{desc: "no name", cfg: serveConfig{Profiles: []string{": Phish"}}},
+		{desc: "duplicate", cfg: serveConfig{Profiles: []string{"alice: Phish", "alice: Goose"}}},
+		{desc: "token for unknown profile", cfg: serveConfig{Profiles: []string{"alice"}, ProfileTokens: []string{"bob: s3cret"}}},
+		{desc: "token without name", cfg: serveConfig{Profiles: []string{"alice"}, ProfileTokens: []string{"s3cret"}}},
+		{desc: "shared token", cfg: serveConfig{Profiles: []string{"alice", "bob"}, ProfileTokens: []string{"alice: s3cret", "bob: s3cret"}}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := tc.cfg.profileTokens(); err == nil {
+				t.Errorf("wanted an error, but got none")
+			}
+		})
+	}
+}
+
+func TestProfileFor(t *testing.T) {
+	var (
+		alice    = serveProfile{Name: "alice", Favorites: []string{"Phish"}}
+		bob      = serveProfile{Name: "bob", Favorites: []string{"Goose"}}
+		profiles = []serveProfile{alice, bob}
+		tokens   = map[string]string{"alic3": "alice"}
+	)
+	tt := []struct {
+		desc    string
+		path    string
+		token   string
+		want    serveProfile
+		wantOK  bool
+		wantErr bool
+	}{
+		{desc: "none", path: "/now"},
+		{desc: "query", path: "/now?profile=bob", want: bob, wantOK: true},
+		{desc: "token", path: "/now", token: "alic3", want: alice, wantOK: true},
+		{desc: "token and same query", path: "/now?profile=alice", token: "alic3", want: alice, wantOK: true},
+		{desc: "token and other query", path: "/now?profile=bob", token: "alic3", wantErr: true},
+		{desc: "other token", path: "/now?profile=bob", token: "s3cret", want: bob, wantOK: true},
+		{desc: "unknown", path: "/now?profile=carol", wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			got, ok, err := profileFor(r, profiles, tokens)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("wanted error %v, but got %v", tc.wantErr, err)
+			}
+			if ok != tc.wantOK || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v (%v), but got %v (%v)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+	r := httptest.NewRequest(http.MethodGet, "/now?profile=carol", nil)
+	if _, _, err := profileFor(r, profiles, tokens); !errors.Is(err, errUnknownProfile) {
+		t.Errorf("wanted an unknown profile error, but got %v", err)
+	}
+}
+
+func TestStore_ForProfile(t *testing.T) {
+	st := newTestStore(t)
+	if st.forProfile("") != st {
+		t.Errorf("wanted the store itself without a profile")
+	}
+	e := queueEntry{Artist: "Phish", PerformanceTime: mustParseDate("1997-11-22")}
+	if _, err := st.forProfile("alice").queueAdd(e); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q, _ := st.queue(); len(q) != 0 {
+		t.Errorf("wanted the main queue empty, but got %v", q)
+	}
+	if q, _ := st.forProfile("alice").queue(); len(q) != 1 {
+		t.Errorf("wanted alice's queue to have the show, but got %v", q)
+	}
+}
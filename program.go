@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fullShowProgram is the program that full set broadcasts air in, unless
+// the schedule says otherwise.
+const fullShowProgram = "Full Show Fridays"
+
+// programsConfig describes the named programming blocks, such as themed
+// hours, that the station airs.
+type programsConfig struct {
+	Schedule []string `yaml:"schedule,omitempty" doc:"Programs by when they air, in local time, as name: days start-end, e.g. [\"Morning Jams: Mon-Fri 06:00-09:00\", \"Late Night Dead: Sat 22:00-02:00\"]"`
+}
+
+// program is a programming block that airs at the same times each week.
+type program struct {
+	Name string
+	timeWindow
+}
+
+// timeWindow is a span of time that recurs on some days of each week, such
+// as when a program airs.
+type timeWindow struct {
+	days       [7]bool // indexed by time.Weekday
+	start, end time.Duration
+}
+
+// weekdays maps the abbreviated names of days to their time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseProgram parses a scheduled program, such as
+// "Morning Jams: Mon-Fri 06:00-09:00".
+func parseProgram(s string) (program, error) {
+	var p program
+	// The times have colons too, so the name ends at the first colon
+	// followed by a space.
+	i := strings.Index(s, ": ")
+	if i <= 0 {
+		return p, fmt.Errorf("program %q must be name: days start-end", s)
+	}
+	p.Name = strings.TrimSpace(s[:i])
+	w, err := parseTimeWindow(s[i+1:])
+	if err != nil {
+		return p, fmt.Errorf("program %q: %w", s, err)
+	}
+	p.timeWindow = w
+	return p, nil
+}
+
+// parseTimeWindow parses a weekly span of time, such as "Mon-Fri
+// 06:00-09:00". Days are a day, a range of days, or "daily". A window that
+// ends before it starts runs past midnight, into the next day.
+func parseTimeWindow(s string) (timeWindow, error) {
+	var w timeWindow
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return w, errors.New("must be days start-end, e.g. Mon-Fri 06:00-09:00")
+	}
+	if err := w.parseDays(strings.ToLower(fields[0])); err != nil {
+		return w, err
+	}
+	times := strings.Split(fields[1], "-")
+	if len(times) != 2 {
+		return w, errors.New("times must be start-end, e.g. 18:00-24:00")
+	}
+	var err error
+	if w.start, err = parseTimeOfDay(times[0]); err != nil {
+		return w, err
+	}
+	if w.end, err = parseTimeOfDay(times[1]); err != nil {
+		return w, err
+	}
+	if w.start == w.end {
+		return w, errors.New("must end at a different time than it starts")
+	}
+	return w, nil
+}
+
+func (w *timeWindow) parseDays(s string) error {
+	if s == "daily" {
+		for i := range w.days {
+			w.days[i] = true
+		}
+		return nil
+	}
+	from, to := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		from, to = s[:i], s[i+1:]
+	}
+	first, ok := weekdays[from]
+	last, ok2 := weekdays[to]
+	if !ok || !ok2 {
+		return fmt.Errorf("%q is not a day, such as Fri, a range of days, such as Mon-Fri, or daily", s)
+	}
+	for d := first; ; d = (d + 1) % 7 {
+		w.days[d] = true
+		if d == last {
+			return nil
+		}
+	}
+}
+
+// parseTimeOfDay parses a time of day, such as 18:00, as the time since
+// midnight. 24:00 is midnight at the end of the day.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) == 2 && len(parts[1]) == 2 {
+		h, err := strconv.Atoi(parts[0])
+		m, err2 := strconv.Atoi(parts[1])
+		if err == nil && err2 == nil && h >= 0 && m >= 0 && m < 60 && (h < 24 || h == 24 && m == 0) {
+			return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a time of day, such as 18:00", s)
+}
+
+// contains reports whether t falls in the window.
+func (w timeWindow) contains(t time.Time) bool {
+	var (
+		day       = t.Weekday()
+		yesterday = (day + 6) % 7
+		since     = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	)
+	if w.start < w.end {
+		return w.days[day] && since >= w.start && since < w.end
+	}
+	return w.days[day] && since >= w.start || w.days[yesterday] && since < w.end
+}
+
+// programSchedule is the programs the station airs, in order of
+// precedence.
+type programSchedule []program
+
+// schedule parses the scheduled programs.
+func (c programsConfig) schedule() (programSchedule, error) {
+	s := make(programSchedule, 0, len(c.Schedule))
+	for _, entry := range c.Schedule {
+		p, err := parseProgram(entry)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, p)
+	}
+	return s, nil
+}
+
+// program returns the name of the program t aired in: the first scheduled
+// program on the air when it started, or for a full set broadcast,
+// fullShowProgram. Tracks that aired at no known time, such as those in
+// the station's history, are only placed in a program by their title.
+func (s programSchedule) program(t Track) string {
+	if !t.StartTime.IsZero() {
+		local := t.StartTime.Local()
+		for _, p := range s {
+			if p.contains(local) {
+				return p.Name
+			}
+		}
+	}
+	if t.IsFullShow() {
+		return fullShowProgram
+	}
+	return ""
+}
+
+// assign sets the program of each track.
+func (s programSchedule) assign(tl TrackList) TrackList {
+	return tl.Map(func(t Track) Track {
+		t.Program = s.program(t)
+		return t
+	})
+}
+
+// loadPrograms returns the configured program schedule. An invalid
+// schedule is reported, and programs are then only found by title.
+func loadPrograms(cfg config) programSchedule {
+	s, err := cfg.Programs.schedule()
+	if err != nil {
+		warn(fmt.Errorf("programs.schedule: %w", err))
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseProgram(t *testing.T) {
+	tt := []struct {
+		in        string
+		wantName  string
+		wantDays  []time.Weekday
+		wantStart time.Duration
+		wantEnd   time.Duration
+		wantErr   bool
+	}{
+		{
+			in:        "Morning Jams: Mon-Fri 06:00-09:00",
+			wantName:  "Morning Jams",
+			wantDays:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			wantStart: 6 * time.Hour,
+			wantEnd:   9 * time.Hour,
+		},
+		{
+			in:        "Late Night Dead: sat 22:30-02:00",
+			wantName:  "Late Night Dead",
+			wantDays:  []time.Weekday{time.Saturday},
+			wantStart: 22*time.Hour + 30*time.Minute,
+			wantEnd:   2 * time.Hour,
+		},
+		{
+			in:        "Weekend: Sat-Sun 00:00-24:00",
+			wantName:  "Weekend",
+			wantDays:  []time.Weekday{time.Sunday, time.Saturday},
+			wantStart: 0,
+			wantEnd:   24 * time.Hour,
+		},
+		{
+			in:        "Top of the Hour: daily 12:00-12:05",
+			wantName:  "Top of the Hour",
+			wantDays:  []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+			wantStart: 12 * time.Hour,
+			wantEnd:   12*time.Hour + 5*time.Minute,
+		},
+		{in: "Morning Jams", wantErr: true},
+		{in: "Morning Jams: Mon-Fri", wantErr: true},
+		{in: "Morning Jams: Monday 06:00-09:00", wantErr: true},
+		{in: "Morning Jams: Mon 6-9", wantErr: true},
+		{in: "Morning Jams: Mon 06:00-25:00", wantErr: true},
+		{in: "Morning Jams: Mon 06:00-06:00", wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			p, err := parseProgram(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("wanted error %v, but got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			var days []time.Weekday
+			for d, ok := range p.days {
+				if ok {
+					days = append(days, time.Weekday(d))
+				}
+			}
+			if p.Name != tc.wantName || !reflect.DeepEqual(days, tc.wantDays) || p.start != tc.wantStart || p.end != tc.wantEnd {
+				t.Errorf("wanted %s %v %s-%s, but got %s %v %s-%s",
+					tc.wantName, tc.wantDays, tc.wantStart, tc.wantEnd, p.Name, days, p.start, p.end)
+			}
+		})
+	}
+}
+
+func TestProgramSchedule_Program(t *testing.T) {
+	schedule, err := programsConfig{Schedule: []string{
+		"Morning Jams: Mon-Fri 06:00-09:00",
+		"Late Night Dead: Sat 22:00-02:00",
+	}}.schedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(s string) Track {
+		d, err := time.ParseInLocation("2006-01-02T15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Track{Artist: "Phish", Title: "Reba", StartTime: d}
+	}
+	fullShow := Track{Artist: "Phish", Title: "22-Nov-1997 Set 2", Set: &ShowSet{Number: 2}}
+	tt := []struct {
+		desc  string
+		track Track
+		want  string
+	}{
+		{desc: "weekday morning", track: at("2024-06-03T07:15"), want: "Morning Jams"},
+		{desc: "as it ends", track: at("2024-06-03T09:00"), want: ""},
+		{desc: "weekend morning", track: at("2024-06-01T07:15"), want: ""},
+		{desc: "Saturday night", track: at("2024-06-01T23:00"), want: "Late Night Dead"},
+		{desc: "past midnight into Sunday", track: at("2024-06-02T01:30"), want: "Late Night Dead"},
+		{desc: "past midnight into Saturday", track: at("2024-06-01T01:30"), want: ""},
+		{desc: "full show, by title", track: fullShow, want: fullShowProgram},
+		{desc: "no start time", track: Track{Artist: "Phish", Title: "Reba"}, want: ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := schedule.program(tc.track); got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
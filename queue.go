@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const queueFile = "queue.json"
+
+// queueEntry is a show saved to listen to later.
+type queueEntry struct {
+	Artist          string    `json:"artist"`
+	PerformanceTime time.Time `json:"performance_time"`
+
+	// Title is the track that was airing when the show was queued, to help
+	// jog the user's memory about why they queued it.
+	Title string    `json:"title,omitempty"`
+	Added time.Time `json:"added"`
+
+	// Done is when the show was listened to, if it has been.
+	Done *time.Time `json:"done,omitempty"`
+}
+
+// pending reports whether the show is still waiting to be listened to.
+// Queues written by older versions of ph have a zero time for shows that
+// are pending.
+func (e queueEntry) pending() bool {
+	return e.Done == nil || e.Done.IsZero()
+}
+
+// show returns a Track representing the queued show, which can be used to
+// build links to it.
+func (e queueEntry) show() Track {
+	return Track{Artist: e.Artist, PerformanceTime: e.PerformanceTime}
+}
+
+func (e queueEntry) String() string {
+	str := fmt.Sprintf("%s - %s", e.Artist, e.PerformanceTime.Format("Mon 2-Jan-2006"))
+	if e.Title != "" {
+		str += fmt.Sprintf(" (heard %s)", e.Title)
+	}
+	return str
+}
+
+func (s *store) queue() ([]queueEntry, error) {
+	var q []queueEntry
+	err := s.readJSON(queueFile, &q)
+	return q, err
+}
+
+func (s *store) saveQueue(q []queueEntry) error {
+	return s.writeJSON(queueFile, q)
+}
+
+// queueAdd adds a show to the queue, unless it is already waiting in the
+// queue. It reports whether the show was added.
+func (s *store) queueAdd(e queueEntry) (bool, error) {
+	q, err := s.queue()
+	if err != nil {
+		return false, err
+	}
+	for _, existing := range q {
+		if existing.pending() && existing.Artist == e.Artist && existing.PerformanceTime.Equal(e.PerformanceTime) {
+			return false, nil
+		}
+	}
+	return true, s.saveQueue(append(q, e))
+}
+
+// queueDone marks the nth (starting at 1) show still waiting in the queue
+// as listened to, and returns it.
+func (s *store) queueDone(n int, at time.Time) (queueEntry, error) {
+	q, err := s.queue()
+	if err != nil {
+		return queueEntry{}, err
+	}
+	var pending int
+	for i := range q {
+		if !q[i].pending() {
+			continue
+		}
+		if pending++; pending == n {
+			q[i].Done = &at
+			return q[i], s.saveQueue(q)
+		}
+	}
+	return queueEntry{}, fmt.Errorf(tr("no show number %d in the queue"), n)
+}
+
+func runQueue(args []string) error {
+	const usage = "usage: ph queue add|list|done"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "add":
+		return runQueueAdd(st, cfg, args[1:])
+	case "list", "ls":
+		return runQueueList(st, cfg, args[1:])
+	case "done":
+		return runQueueDone(st, cfg, args[1:])
+	default:
+		return errors.New(usage)
+	}
+}
+
+// profileFlag adds the --profile flag, which picks the listening queue of
+// one of the profiles of ph serve, to fs.
+func profileFlag(fs *flag.FlagSet) *string {
+	return fs.String("profile", "", "Use the queue of this profile of ph serve (see serve.profiles)")
+}
+
+func runQueueDone(st *store, cfg config, args []string) error {
+	var (
+		fs      = flag.NewFlagSet("queue done", flag.ExitOnError)
+		profile = profileFlag(fs)
+	)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: ph queue done <number>")
+	}
+	if err := checkProfile(cfg, *profile); err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid queue number %q", fs.Arg(0))
+	}
+	e, err := st.forProfile(*profile).queueDone(n, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf(tr("Marked %s as listened.")+"\n", e)
+	return nil
+}
+
+func runQueueAdd(st *store, cfg config, args []string) error {
+	var (
+		fs      = flag.NewFlagSet("queue add", flag.ExitOnError)
+		artist  = fs.String("artist", "", "Artist of the show to queue, instead of the current track's")
+		dateStr = fs.String("date", "", "Date of the show to queue (YYYY-MM-DD), instead of the current track's")
+		profile = profileFlag(fs)
+	)
+	fs.Parse(args)
+	if err := checkProfile(cfg, *profile); err != nil {
+		return err
+	}
+	st = st.forProfile(*profile)
+
+	e := queueEntry{Artist: *artist, Added: time.Now()}
+	if *dateStr != "" {
+		d, err := time.Parse("2006-01-02", *dateStr)
+		if err != nil {
+			return fmt.Errorf("invalid date %q: %w", *dateStr, err)
+		}
+		e.PerformanceTime = d
+	}
+	if e.Artist == "" || e.PerformanceTime.IsZero() {
+		status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+		if err != nil {
+			return err
+		}
+		current := status.CurrentTrack
+		if e.Artist == "" {
+			e.Artist = current.Artist
+		}
+		if e.PerformanceTime.IsZero() {
+			e.PerformanceTime = current.PerformanceTime
+			e.Title = current.Title
+		}
+	}
+	if e.Artist == "" || e.PerformanceTime.IsZero() {
+		return errors.New(tr("the current track is not from a live show; use --artist and --date to choose one"))
+	}
+	added, err := st.queueAdd(e)
+	if err != nil {
+		return err
+	}
+	if !added {
+		fmt.Printf(tr("%s is already in the queue.")+"\n", e)
+		return nil
+	}
+	fmt.Printf(tr("Queued %s.")+"\n", e)
+	return nil
+}
+
+func runQueueList(st *store, cfg config, args []string) error {
+	var (
+		fs      = flag.NewFlagSet("queue list", flag.ExitOnError)
+		all     = fs.BoolP("all", "a", false, "Include shows already listened to")
+		profile = profileFlag(fs)
+	)
+	fs.Parse(args)
+	if err := checkProfile(cfg, *profile); err != nil {
+		return err
+	}
+	st = st.forProfile(*profile)
+
+	q, err := st.queue()
+	if err != nil {
+		return err
+	}
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	var (
+		b       strings.Builder
+		pending int
+	)
+	for _, e := range q {
+		var prefix string
+		if e.pending() {
+			pending++
+			prefix = fmt.Sprintf("%3d.", pending)
+		} else if *all {
+			prefix = "  ✓ "
+		} else {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s", prefix, e)
+		if url := e.show().streamingURL(relistenArtists); url != "" {
+			fmt.Fprintf(&b, " - %s", url)
+		}
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		fmt.Println(tr("The queue is empty."))
+		return nil
+	}
+	fmt.Print(b.String())
+	return nil
+}
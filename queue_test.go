@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStore returns a store in a temporary directory that is removed
+// when the test finishes.
+func newTestStore(t *testing.T) *store {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "ph-store")
+	if err != nil {
+		t.Fatalf("unable to create store dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return &store{dir: dir}
+}
+
+func TestStore_Queue(t *testing.T) {
+	st := newTestStore(t)
+	shows := []queueEntry{
+		{Artist: "Phish", PerformanceTime: mustParseDate("1997-11-17")},
+		{Artist: "Grateful Dead", PerformanceTime: mustParseDate("1977-05-08")},
+		{Artist: "Phish", PerformanceTime: mustParseDate("1995-12-31")},
+	}
+	for _, e := range shows {
+		added, err := st.queueAdd(e)
+		if err != nil {
+			t.Fatalf("unexpected error adding %s: %v", e, err)
+		}
+		if !added {
+			t.Fatalf("wanted %s to be added", e)
+		}
+	}
+	if added, _ := st.queueAdd(shows[0]); added {
+		t.Errorf("wanted duplicate show not to be added")
+	}
+
+	done, err := st.queueDone(2, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error marking show done: %v", err)
+	}
+	if done.Artist != "Grateful Dead" {
+		t.Errorf("wanted Grateful Dead show marked done, but got %s", done)
+	}
+	// Numbering only counts pending shows, so the third show is now second.
+	done, err = st.queueDone(2, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error marking show done: %v", err)
+	}
+	if !done.PerformanceTime.Equal(shows[2].PerformanceTime) {
+		t.Errorf("wanted %s marked done, but got %s", shows[2], done)
+	}
+	if _, err := st.queueDone(2, time.Now()); err == nil {
+		t.Errorf("wanted error marking nonexistent show done")
+	}
+
+	// A show that has been listened to can be queued again.
+	if added, _ := st.queueAdd(shows[1]); !added {
+		t.Errorf("wanted show that was already listened to to be added again")
+	}
+}
+
+func TestStore_Queue_OldPendingEntries(t *testing.T) {
+	st := newTestStore(t)
+	old := `[{"artist": "Phish", "performance_time": "1997-11-17T00:00:00Z", "added": "2020-07-04T18:00:00Z", "done": "0001-01-01T00:00:00Z"}]`
+	if err := ioutil.WriteFile(st.path(queueFile), []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	done, err := st.queueDone(1, time.Now())
+	if err != nil {
+		t.Fatalf("wanted the show with a zero done time to be pending, but got %v", err)
+	}
+	if done.Done == nil {
+		t.Errorf("wanted the show marked done")
+	}
+
+	b, err := json.Marshal(queueEntry{Artist: "Goose"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), `"done"`) {
+		t.Errorf("wanted no done time for a pending show, but got %s", b)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+	flag "github.com/spf13/pflag"
+)
+
+// randomShow picks a show by the artist with the given slug at random from
+// Relisten, from the given year, or from any year if year is 0. Every show
+// is equally likely to be picked, so years with more shows are picked more
+// often.
+func randomShow(ctx context.Context, rc *relisten.Client, slug string, year int, rnd *rand.Rand) (relisten.Show, error) {
+	if year == 0 {
+		years, err := rc.Years(ctx, slug)
+		if err != nil {
+			return relisten.Show{}, err
+		}
+		total := 0
+		for _, y := range years {
+			total += y.ShowCount
+		}
+		if total == 0 {
+			return relisten.Show{}, errors.New("no shows available")
+		}
+		n := rnd.Intn(total)
+		for _, y := range years {
+			if n < y.ShowCount {
+				year, _ = strconv.Atoi(y.Year)
+				break
+			}
+			n -= y.ShowCount
+		}
+	}
+	ys, err := rc.Year(ctx, slug, year)
+	if err != nil {
+		return relisten.Show{}, err
+	}
+	if len(ys.Shows) == 0 {
+		return relisten.Show{}, fmt.Errorf("no shows available in %d", year)
+	}
+	return ys.Shows[rnd.Intn(len(ys.Shows))], nil
+}
+
+// showTrack returns a Track standing in for a whole Relisten show, for
+// rendering and linking.
+func showTrack(artist string, show relisten.Show) Track {
+	t := Track{Artist: artist, PerformanceTime: show.Date.Time}
+	if v := show.Venue; v != nil {
+		t.Location = v.Name
+		if v.Location != "" {
+			t.Location += ", " + v.Location
+		}
+	}
+	return t
+}
+
+// runRandom picks a random show from Relisten to listen to.
+func runRandom(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("random", flag.ExitOnError)
+		artist = fs.String("artist", "Phish", "Artist to pick a show by")
+		year   = fs.Int("year", 0, "Year to pick a show from; 0 picks from any year")
+	)
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	rc := newRelistenClient(cfg)
+	relistenArtists, err = relistenGetArtists(rc, cfg)
+	if err != nil {
+		return fmt.Errorf("get Relisten artists: %w", err)
+	}
+	slug, err := relistenArtistSlug(relistenArtists, *artist)
+	if err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	show, err := randomShow(context.Background(), rc, slug, *year, rnd)
+	if errors.Is(err, relisten.ErrNotFound) && *year != 0 {
+		return fmt.Errorf("no %s shows available in %d", *artist, *year)
+	}
+	if err != nil {
+		return err
+	}
+	for name, s := range relistenArtists {
+		if s == slug {
+			*artist = name
+		}
+	}
+	t := showTrack(*artist, show)
+	fmt.Printf("%s - %s", t.Artist, t.PerformanceTime.Format("Mon 2-Jan-2006"))
+	if t.Location != "" {
+		fmt.Printf(" %s", t.Location)
+	}
+	fmt.Println()
+	for _, line := range linkLines(t.Links(cfg, relistenArtists)) {
+		fmt.Println(line)
+	}
+	return nil
+}
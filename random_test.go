@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ianfoo/ph/relisten"
+)
+
+// newTestRelistenClient returns a Relisten client for a test server that
+// serves responses from a map of paths to JSON bodies.
+func newTestRelistenClient(t *testing.T, responses map[string]string) *relisten.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	rc := relisten.New(srv.Client(), "")
+	rc.BaseURL = srv.URL
+	rc.MinInterval = 0
+	return rc
+}
+
+func TestRandomShow(t *testing.T) {
+	rc := newTestRelistenClient(t, map[string]string{
+		"/artists/phish/years": `[{"year": "1995", "show_count": 0}, {"year": "1997", "show_count": 2}]`,
+		"/artists/phish/years/1997": `{"year": "1997", "shows": [
+			{"display_date": "1997-11-17", "date": "1997-11-17T00:00:00Z", "venue": {"name": "McNichols Arena", "location": "Denver, CO"}},
+			{"display_date": "1997-11-22", "date": "1997-11-22T00:00:00Z", "venue": {"name": "Hampton Coliseum", "location": "Hampton, VA"}}
+		]}`,
+	})
+	rnd := rand.New(rand.NewSource(1))
+	for _, year := range []int{0, 1997} {
+		show, err := randomShow(context.Background(), rc, "phish", year, rnd)
+		if err != nil {
+			t.Fatalf("year %d: unexpected error: %v", year, err)
+		}
+		if got := show.Date.Year(); got != 1997 {
+			t.Errorf("year %d: wanted a show from 1997, but got one from %d", year, got)
+		}
+	}
+	if _, err := randomShow(context.Background(), rc, "phish", 1995, rnd); err == nil {
+		t.Errorf("wanted an error for a year with no shows, but got none")
+	}
+
+	track := showTrack("Phish", relisten.Show{Venue: &relisten.Venue{Name: "Hampton Coliseum", Location: "Hampton, VA"}})
+	if want := "Hampton Coliseum, Hampton, VA"; track.Location != want {
+		t.Errorf("wanted location %q, but got %q", want, track.Location)
+	}
+}
+
+func TestRelistenArtistSlug(t *testing.T) {
+	artists := map[string]string{"Phish": "phish", "Grateful Dead": "grateful-dead"}
+	tt := []struct {
+		desc    string
+		name    string
+		want    string
+		wantErr string
+	}{
+		{desc: "exact", name: "Phish", want: "phish"},
+		{desc: "any case", name: "grateful dead", want: "grateful-dead"},
+		{desc: "typo", name: "Phsih", wantErr: "Phsih is not available on Relisten; did you mean Phish?"},
+		{desc: "unknown", name: "Pearl Jam", wantErr: "Pearl Jam is not available on Relisten"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := relistenArtistSlug(artists, tc.name)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("wanted error %q, but got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("wanted %q, but got %q (error %v)", tc.want, got, err)
+			}
+		})
+	}
+}
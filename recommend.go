@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+	flag "github.com/spf13/pflag"
+)
+
+// tourGap is the longest gap between two shows on the same tour. Relisten
+// does not know tours, so a tour is taken to be the shows around a show
+// with no longer gaps between them.
+const tourGap = 10 * 24 * time.Hour
+
+// songVersion is a performance of a song, as on phish.net's jam charts.
+type songVersion struct {
+	Song        string
+	Date        time.Time
+	Location    string
+	Description string
+}
+
+// recommendation is a show recommended after another show aired, with why.
+type recommendation struct {
+	Artist          string    `json:"artist"`
+	PerformanceTime time.Time `json:"performance_time"`
+	Location        string    `json:"location,omitempty"`
+	Rating          float64   `json:"rating,omitempty"`
+	Reasons         []string  `json:"reasons"`
+	Links           []Link    `json:"links,omitempty"`
+
+	score float64
+}
+
+func (r recommendation) String() string {
+	var b strings.Builder
+	b.WriteString(r.Artist + " - " + r.PerformanceTime.Format("Mon 2-Jan-2006"))
+	if r.Location != "" {
+		b.WriteString(" " + r.Location)
+	}
+	reasons := strings.Join(r.Reasons, "; ")
+	if r.Rating > 0 {
+		reasons += fmt.Sprintf("; "+tr("rated %.1f on Relisten"), r.Rating)
+	}
+	b.WriteString("\n" + reasons)
+	for _, line := range linkLines(r.Links) {
+		b.WriteString("\n" + line)
+	}
+	return b.String()
+}
+
+// tourShows returns the shows on the same tour as the show on date: those
+// reached from it through gaps of no more than tourGap, in order, without
+// the show itself.
+func tourShows(date time.Time, shows []relisten.Show) []relisten.Show {
+	sorted := make([]relisten.Show, len(shows))
+	copy(sorted, shows)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date.Time) })
+	var before, after []relisten.Show
+	for _, s := range sorted {
+		if s.Date.Before(date) {
+			before = append(before, s)
+		} else if s.Date.After(date) {
+			after = append(after, s)
+		}
+	}
+	var tour []relisten.Show
+	prev := date
+	for i := len(before) - 1; i >= 0 && prev.Sub(before[i].Date.Time) <= tourGap; i-- {
+		tour = append([]relisten.Show{before[i]}, tour...)
+		prev = before[i].Date.Time
+	}
+	prev = date
+	for _, s := range after {
+		if s.Date.Sub(prev) > tourGap {
+			break
+		}
+		tour = append(tour, s)
+		prev = s.Date.Time
+	}
+	return tour
+}
+
+// daysApart describes how far date is from base, as in "2 days later".
+func daysApart(base, date time.Time) string {
+	days := int(math.Round(date.Sub(base).Hours() / 24))
+	switch {
+	case days == 1:
+		return tr("a day later")
+	case days == -1:
+		return tr("a day earlier")
+	case days > 0:
+		return fmt.Sprintf(tr("%d days later"), days)
+	}
+	return fmt.Sprintf(tr("%d days earlier"), -days)
+}
+
+// recommendShows ranks the shows on the same tour as the show by artist
+// on date, by their ratings, along with those with jam chart versions of
+// its songs, by how many, and returns the best n. A show found both ways
+// has both reasons, and ranks higher for them. cfg gives the links to each
+// show.
+func recommendShows(artist string, date time.Time, tour []relisten.Show, versions []songVersion, n int, cfg config) []recommendation {
+	var (
+		byDate = make(map[string]*recommendation)
+		order  []string
+	)
+	recommend := func(d time.Time, location string) *recommendation {
+		key := d.Format("2006-01-02")
+		if r, ok := byDate[key]; ok {
+			if r.Location == "" {
+				r.Location = location
+			}
+			return r
+		}
+		r := &recommendation{Artist: artist, PerformanceTime: d, Location: location}
+		byDate[key] = r
+		order = append(order, key)
+		return r
+	}
+	for _, s := range tour {
+		var location string
+		if v := s.Venue; v != nil {
+			location = v.Name
+			if v.Location != "" {
+				location += ", " + v.Location
+			}
+		}
+		r := recommend(s.Date.Time, location)
+		r.Rating = s.AvgRating
+		r.score += 1 + s.AvgRating
+		r.Reasons = append(r.Reasons, tr("Same tour")+", "+daysApart(date, s.Date.Time))
+	}
+	for _, v := range versions {
+		if v.Date.Equal(date) {
+			continue
+		}
+		r := recommend(v.Date, v.Location)
+		r.score += 3
+		reason := tr("Jam chart") + " " + v.Song
+		if v.Description != "" {
+			reason += ": '" + v.Description + "'"
+		}
+		r.Reasons = append(r.Reasons, reason)
+	}
+
+	recs := make([]recommendation, 0, len(order))
+	for _, key := range order {
+		recs = append(recs, *byDate[key])
+	}
+	sort.SliceStable(recs, func(i, j int) bool {
+		if recs[i].score != recs[j].score {
+			return recs[i].score > recs[j].score
+		}
+		return recs[i].PerformanceTime.Before(recs[j].PerformanceTime)
+	})
+	if n > 0 && len(recs) > n {
+		recs = recs[:n]
+	}
+	for i := range recs {
+		t := Track{Artist: recs[i].Artist, PerformanceTime: recs[i].PerformanceTime}
+		recs[i].Links = t.Links(cfg, relistenArtists)
+	}
+	return recs
+}
+
+// jamChartVersions returns the versions of songs by artist on phish.net's
+// jam charts.
+func jamChartVersions(ctx context.Context, client *http.Client, cfg config, artist string, songs []string) ([]songVersion, error) {
+	var versions []songVersion
+	for _, song := range songs {
+		entries, err := getPhishNetSetlists(ctx, client, cfg, pathPhishNetSongSetlists, phishNetSongSlug(song))
+		if err != nil {
+			return versions, err
+		}
+		for _, e := range entries {
+			if !bool(e.IsJamChart) || (e.ArtistName != "" && !strings.EqualFold(e.ArtistName, artist)) {
+				continue
+			}
+			d, err := time.Parse("2006-01-02", e.ShowDate)
+			if err != nil {
+				continue
+			}
+			location := e.Venue
+			for _, part := range []string{e.City, e.State} {
+				if part != "" {
+					location += ", " + part
+				}
+			}
+			versions = append(versions, songVersion{Song: e.Song, Date: d, Location: strings.TrimPrefix(location, ", "), Description: e.JamChartDescription})
+		}
+	}
+	return versions, nil
+}
+
+// airedShow is a live show that aired, with the songs aired from it.
+type airedShow struct {
+	Artist          string
+	PerformanceTime time.Time
+	Songs           []string
+}
+
+// lastShow finds the most recent live show in tracks, which are most recent
+// first, with the songs aired from it. An artist or date that is not empty
+// or zero picks the show by that artist, or on that date.
+func lastShow(tracks TrackList, artist string, date time.Time) (airedShow, bool) {
+	var show airedShow
+	seen := make(map[string]bool)
+	for _, t := range tracks {
+		if t.Artist == "" || t.PerformanceTime.IsZero() || t.Kind() != trackKindSong {
+			continue
+		}
+		if show.Artist == "" {
+			if (artist != "" && !strings.EqualFold(t.Artist, artist)) || (!date.IsZero() && !sameDay(t.PerformanceTime, date)) {
+				continue
+			}
+			show.Artist, show.PerformanceTime = t.Artist, t.PerformanceTime
+		}
+		if t.Artist != show.Artist || !sameDay(t.PerformanceTime, show.PerformanceTime) {
+			continue
+		}
+		for _, song := range strings.FieldsFunc(t.Title, func(r rune) bool { return r == '>' || r == '→' }) {
+			if song = strings.TrimSpace(song); song != "" && !seen[normalizeSongTitle(song)] {
+				seen[normalizeSongTitle(song)] = true
+				show.Songs = append(show.Songs, song)
+			}
+		}
+	}
+	return show, show.Artist != ""
+}
+
+// sameDay reports whether a and b fall on the same date.
+func sameDay(a, b time.Time) bool {
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
+
+// runRecommend recommends shows to listen to after the show that aired
+// most recently: shows from the same tour, and shows with jam chart
+// versions of the songs that aired.
+func runRecommend(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("recommend", flag.ExitOnError)
+		artist = fs.String("artist", "", "Recommend shows after the latest show by this artist aired")
+		date   = fs.String("date", "", "Recommend shows after the show performed on this date (YYYY-MM-DD) aired")
+		count  = fs.IntP("count", "n", 5, "How many shows to recommend")
+		asJSON = fs.Bool("json", false, "Print the recommendations as JSON")
+	)
+	fs.Parse(args)
+	var on time.Time
+	if *date != "" {
+		if on, err = time.Parse("2006-01-02", *date); err != nil {
+			return fmt.Errorf("invalid date %q: use YYYY-MM-DD", *date)
+		}
+	}
+
+	var tracks TrackList
+	if status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station()); err == nil {
+		tracks = status.tracks()
+	}
+	if st, err := openStore(); err == nil {
+		plays, _ := st.recordedPlays()
+		for i := len(plays) - 1; i >= 0; i-- {
+			tracks = append(tracks, plays[i])
+		}
+	}
+	show, ok := lastShow(tracks, *artist, on)
+	switch {
+	case !ok && on.IsZero():
+		return errors.New("no live show has aired yet; pick one with --date")
+	case !ok:
+		show = airedShow{Artist: *artist, PerformanceTime: on}
+		if show.Artist == "" {
+			show.Artist = "Phish"
+		}
+	}
+
+	ctx := context.Background()
+	rc := newRelistenClient(cfg)
+	relistenArtists, err = relistenGetArtists(rc, cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	var tour []relisten.Show
+	if slug, err := relistenArtistSlug(relistenArtists, show.Artist); err == nil {
+		// A tour can run into the year before or after.
+		year := show.PerformanceTime.Year()
+		for _, y := range []int{year - 1, year, year + 1} {
+			ys, err := rc.Year(ctx, slug, y)
+			if err != nil && !errors.Is(err, relisten.ErrNotFound) {
+				warn(fmt.Errorf("unable to get %d shows from Relisten: %w", y, err))
+			}
+			tour = append(tour, ys.Shows...)
+		}
+		tour = tourShows(show.PerformanceTime, tour)
+	}
+	var versions []songVersion
+	if cfg.setlistProvider(show.Artist).Name() == "phish.net" && cfg.Catalog.PhishNetAPIKey != "" {
+		versions, err = jamChartVersions(ctx, apiClient(cfg), cfg, show.Artist, show.Songs)
+		if err != nil {
+			warn(fmt.Errorf("unable to get jam charts: %w", err))
+		}
+	}
+	recs := recommendShows(show.Artist, show.PerformanceTime, tour, versions, *count, cfg)
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(recs)
+	}
+	fmt.Printf(tr("After %s:")+"\n", show.Artist+" - "+show.PerformanceTime.Format("Mon 2-Jan-2006"))
+	if len(recs) == 0 {
+		fmt.Println(tr("No related shows found."))
+		return nil
+	}
+	for i, r := range recs {
+		fmt.Printf("\n%d. %s\n", i+1, strings.Replace(r.String(), "\n", "\n   ", -1))
+	}
+	return nil
+}
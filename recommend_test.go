@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+)
+
+func TestTourShows(t *testing.T) {
+	show := func(date string) relisten.Show {
+		return relisten.Show{Date: relisten.Date{Time: mustParseDate(date)}}
+	}
+	shows := []relisten.Show{
+		show("1997-12-31"),
+		show("1997-11-14"),
+		show("1997-11-22"),
+		show("1997-11-21"),
+		show("1997-12-29"),
+		show("1997-11-29"),
+		show("1997-08-17"),
+	}
+	var got []string
+	for _, s := range tourShows(mustParseDate("1997-11-22"), shows) {
+		got = append(got, s.Date.Format("2006-01-02"))
+	}
+	want := []string{"1997-11-14", "1997-11-21", "1997-11-29"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+}
+
+func TestDaysApart(t *testing.T) {
+	base := mustParseDate("1997-11-22")
+	tt := []struct {
+		date string
+		want string
+	}{
+		{date: "1997-11-23", want: "a day later"},
+		{date: "1997-11-21", want: "a day earlier"},
+		{date: "1997-11-29", want: "7 days later"},
+		{date: "1997-11-14", want: "8 days earlier"},
+	}
+	for _, tc := range tt {
+		if got := daysApart(base, mustParseDate(tc.date)); got != tc.want {
+			t.Errorf("%s: wanted %q, but got %q", tc.date, tc.want, got)
+		}
+	}
+}
+
+func TestRecommendShows(t *testing.T) {
+	var (
+		base = mustParseDate("1997-11-22")
+		tour = []relisten.Show{
+			{Date: relisten.Date{Time: mustParseDate("1997-11-21")}, AvgRating: 9.1, Venue: &relisten.Venue{Name: "Hampton Coliseum", Location: "Hampton, VA"}},
+			{Date: relisten.Date{Time: mustParseDate("1997-11-29")}, AvgRating: 7.5},
+		}
+		versions = []songVersion{
+			{Song: "Tweezer", Date: mustParseDate("1997-12-06"), Location: "Auburn Hills, MI", Description: "Funky"},
+			{Song: "Reba", Date: mustParseDate("1997-11-29")},
+			{Song: "Tweezer", Date: base},
+		}
+	)
+	recs := recommendShows("Phish", base, tour, versions, 0, config{})
+	var got []string
+	for _, r := range recs {
+		got = append(got, r.PerformanceTime.Format("2006-01-02"))
+	}
+	// The show on the tour with a jam chart version ranks first, and the
+	// show that aired is not recommended.
+	want := []string{"1997-11-29", "1997-11-21", "1997-12-06"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted %v, but got %v", want, got)
+	}
+	if want := []string{"Same tour, 7 days later", "Jam chart Reba"}; !reflect.DeepEqual(recs[0].Reasons, want) {
+		t.Errorf("wanted reasons %v, but got %v", want, recs[0].Reasons)
+	}
+	if want := "Hampton Coliseum, Hampton, VA"; recs[1].Location != want {
+		t.Errorf("wanted location %q, but got %q", want, recs[1].Location)
+	}
+	if want := []string{"Jam chart Tweezer: 'Funky'"}; !reflect.DeepEqual(recs[2].Reasons, want) {
+		t.Errorf("wanted reasons %v, but got %v", want, recs[2].Reasons)
+	}
+	if n := len(recommendShows("Phish", base, tour, versions, 2, config{})); n != 2 {
+		t.Errorf("wanted 2 recommendations, but got %d", n)
+	}
+}
+
+func TestLastShow(t *testing.T) {
+	var (
+		hampton = mustParseDate("1997-11-22")
+		tracks  = TrackList{
+			{Artist: "jempradio.com", Title: "Station ID"},
+			{Artist: "Phish", Title: "Reba", PerformanceTime: hampton},
+			{Artist: "Phish", Title: "Mercury > Tweezer", PerformanceTime: hampton},
+			{Artist: "Goose", Title: "Arcadia", PerformanceTime: mustParseDate("2021-08-01")},
+			{Artist: "Phish", Title: "Reba", PerformanceTime: hampton},
+		}
+	)
+	show, ok := lastShow(tracks, "", time.Time{})
+	if !ok {
+		t.Fatalf("wanted a show, but got none")
+	}
+	if want := []string{"Reba", "Mercury", "Tweezer"}; !reflect.DeepEqual(show.Songs, want) {
+		t.Errorf("wanted songs %v, but got %v", want, show.Songs)
+	}
+	if show, ok := lastShow(tracks, "goose", time.Time{}); !ok || show.Artist != "Goose" {
+		t.Errorf("wanted the Goose show, but got %v", show)
+	}
+	if _, ok := lastShow(tracks, "", mustParseDate("1999-07-04")); ok {
+		t.Errorf("wanted no show on a date that did not air, but got one")
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// recordExtensions maps the content types of streams that can be recorded
+// to the extensions of the files they are recorded to. Both MP3 and AAC
+// streams are a series of frames that can be split between, and take ID3
+// tags at the start of a file.
+var recordExtensions = map[string]string{
+	"audio/mpeg": ".mp3",
+	"audio/mp3":  ".mp3",
+	"audio/aac":  ".aac",
+	"audio/aacp": ".aac",
+}
+
+// frameStart returns the index of the first audio frame header in b, or -1
+// if there is none. MP3 and AAC frames both begin with at least eleven set
+// bits.
+func frameStart(b []byte) int {
+	for i := 0; i+1 < len(b); i++ {
+		if b[i] == 0xff && b[i+1]&0xe0 == 0xe0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// unsafeFilenameChars are replaced in file names, since they are not
+// allowed in them on one system or another.
+var unsafeFilenameChars = strings.NewReplacer(
+	"/", "-", "\\", "-", ":", "-", "*", "-", "?", "", "\"", "'", "<", "", ">", "", "|", "-",
+)
+
+// recordingName returns the name of the file t is recorded to, without an
+// extension: its artist, date, and title. The date is the performance date
+// if it is known, and otherwise the date the track aired.
+func recordingName(t Track) string {
+	date := t.PerformanceTime
+	if date.IsZero() {
+		date = t.StartTime.Local()
+	}
+	parts := make([]string, 0, 3)
+	if t.Artist != "" {
+		parts = append(parts, t.Artist)
+	}
+	if !date.IsZero() {
+		parts = append(parts, date.Format("2006-01-02"))
+	}
+	title := t.Title
+	if title == "" {
+		title = "Unknown"
+	}
+	parts = append(parts, title)
+	return strings.TrimSpace(unsafeFilenameChars.Replace(strings.Join(parts, " - ")))
+}
+
+// streamRecorder writes a stream to a file for each track, starting a new
+// file at the first frame after the track changes.
+type streamRecorder struct {
+	dir string
+	ext string
+
+	// onFile, if not nil, is called with the path of each file as it is
+	// created.
+	onFile func(path string)
+
+	// onDone, if not nil, is called with each recording once it is
+	// complete.
+	onDone func(e recordingEntry)
+
+	mu      sync.Mutex
+	file    *os.File
+	path    string
+	track   Track
+	started time.Time
+	next    *Track // the track to start a file for at the next frame
+}
+
+// setTrack starts a new file for t at the next frame of the stream.
+func (r *streamRecorder) setTrack(t Track) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next = &t
+}
+
+// Write writes stream data to the current track's file. Data before the
+// first track is known is discarded.
+func (r *streamRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(b)
+	if r.next != nil {
+		i := frameStart(b)
+		if i < 0 {
+			if r.file == nil {
+				return n, nil
+			}
+			_, err := r.file.Write(b)
+			return n, err
+		}
+		if r.file != nil {
+			if _, err := r.file.Write(b[:i]); err != nil {
+				return 0, err
+			}
+		}
+		if err := r.create(*r.next); err != nil {
+			return 0, err
+		}
+		r.next, b = nil, b[i:]
+	}
+	if r.file == nil {
+		return n, nil
+	}
+	if _, err := r.file.Write(b); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// create closes the current file and starts a file for t, beginning with
+// its tags. A number is added to the name if the file already exists, as
+// when a track airs twice in a day.
+func (r *streamRecorder) create(t Track) error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	var (
+		name = recordingName(t)
+		path = filepath.Join(r.dir, name+r.ext)
+	)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(r.dir, fmt.Sprintf("%s (%d)%s", name, i, r.ext))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create recording: %w", err)
+	}
+	if _, err := f.Write(id3v2Tag(trackID3Frames(t))); err != nil {
+		f.Close()
+		return fmt.Errorf("write tags: %w", err)
+	}
+	r.file, r.path, r.track, r.started = f, path, t, time.Now()
+	if r.onFile != nil {
+		r.onFile(path)
+	}
+	return nil
+}
+
+func (r *streamRecorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	if err == nil && r.onDone != nil {
+		path := r.path
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		r.onDone(recordingEntry{Path: path, Track: playRecord(r.track), Started: r.started, Recorded: time.Now()})
+	}
+	return err
+}
+
+// close closes the file being recorded to.
+func (r *streamRecorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeFile()
+}
+
+// runRecord records the station's stream to a file for each track, until
+// interrupted.
+func runRecord(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs         = flag.NewFlagSet("record", flag.ExitOnError)
+		dir        = fs.StringP("dir", "d", cfg.Record.dir(), "Directory to record to")
+		interval   = fs.Duration("interval", 5*time.Second, "How often to check the station for a new track")
+		scan       = fs.Bool("scan", false, "Measure the loudness of each track as it is recorded, and tag it with ReplayGain")
+		boundaries = fs.Bool("boundaries", false, "Listen for the start of each track to correct start times in the play history")
+		window     = fs.Duration("boundary-window", defaultBoundaryWindow, "How much of the end of each recording to listen to for the start of the next track")
+	)
+	fs.Parse(args)
+	if err := os.MkdirAll(*dir, os.FileMode(0777)); err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	var jingle []float64
+	if *boundaries && cfg.Record.Jingle != "" {
+		if jingle, err = decodeEnvelope(cfg.Record.Jingle, 0); err != nil {
+			return fmt.Errorf("load jingle: %w", err)
+		}
+	}
+
+	url := cfg.Play.streamURL(cfg.station())
+	resp, err := httpClient(cfg).Get(url)
+	if err != nil {
+		return fmt.Errorf("connect to stream: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("connect to stream: %s", resp.Status)
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	ext, ok := recordExtensions[contentType]
+	if !ok {
+		return fmt.Errorf("unable to record the stream: %q streams are not supported", contentType)
+	}
+	// Recordings are added to the index, scanned, and listened to for
+	// track boundaries one at a time as they are completed.
+	var (
+		completed = make(chan recordingEntry, 4)
+		indexed   = make(chan struct{})
+	)
+	go func() {
+		defer close(indexed)
+		for e := range completed {
+			if *scan {
+				var err error
+				if e, err = scanRecording(e); err != nil {
+					warn(err)
+				}
+			}
+			if err := st.saveRecording(e); err != nil {
+				warn(fmt.Errorf("unable to update recording index: %w", err))
+			}
+			if !*boundaries {
+				continue
+			}
+			b, ok, err := detectBoundary(e, jingle, *window)
+			if err != nil {
+				warn(err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if err := st.recordBoundary(b); err != nil {
+				warn(fmt.Errorf("unable to record track boundary: %w", err))
+			}
+		}
+	}()
+	rec := &streamRecorder{
+		dir:    *dir,
+		ext:    ext,
+		onFile: func(path string) { fmt.Println("Recording", path) },
+		onDone: func(e recordingEntry) { completed <- e },
+	}
+	defer func() {
+		rec.close()
+		close(completed)
+		<-indexed
+	}()
+
+	var (
+		b      = &bus{}
+		events = b.subscribe(1)
+	)
+	go func() {
+		var current Track
+		for e := range events {
+			fetched, ok := e.(statusFetched)
+			if !ok {
+				continue
+			}
+			if t := fetched.Status.CurrentTrack; !current.Key().Equal(t.Key()) {
+				current = t
+				rec.setTrack(t)
+			}
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &poller{
+		radioCo:  newRadioCoClient(httpClient(cfg), cfg),
+		station:  cfg.station(),
+		interval: *interval,
+		bus:      b,
+	}
+	go func() {
+		p.run(ctx)
+		b.close()
+	}()
+
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(rec, resp.Body)
+		copied <- err
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-signals:
+		return nil
+	case err := <-copied:
+		if err == nil {
+			err = errors.New("the station closed the stream")
+		}
+		return fmt.Errorf("record stream: %w", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordingName(t *testing.T) {
+	tt := []struct {
+		desc  string
+		track Track
+		want  string
+	}{
+		{
+			desc:  "live track",
+			track: Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")},
+			want:  "Phish - 1997-11-22 - Reba",
+		},
+		{
+			desc:  "studio track",
+			track: Track{Artist: "Goose", Title: "Arcadia", StartTime: mustParseDate("2024-06-01T21:00:00")},
+			want:  "Goose - 2024-06-01 - Arcadia",
+		},
+		{
+			desc:  "unsafe characters",
+			track: Track{Artist: "Phish", Title: "Free/Bathtub Gin?", PerformanceTime: mustParseDate("1997-11-22")},
+			want:  "Phish - 1997-11-22 - Free-Bathtub Gin",
+		},
+		{desc: "station break", track: Track{}, want: "Unknown"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := recordingName(tc.track); got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStreamRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-record")
+	if err != nil {
+		t.Fatalf("unable to create dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		reba  = Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")}
+		ghost = Track{Artist: "Phish", Title: "Ghost", PerformanceTime: mustParseDate("1997-11-22")}
+		files []string
+		done  []string
+		rec   = &streamRecorder{
+			dir:    dir,
+			ext:    ".mp3",
+			onFile: func(path string) { files = append(files, filepath.Base(path)) },
+			onDone: func(e recordingEntry) { done = append(done, e.Track.Title) },
+		}
+	)
+	write := func(b string) {
+		if _, err := rec.Write([]byte(b)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	write("before the first track\xff\xfbreba")
+	rec.setTrack(reba)
+	write("rest of a frame\xff\xfbreba")
+	write(" more reba")
+	rec.setTrack(ghost)
+	write(" end of reba\xff\xfbghost")
+	rec.setTrack(reba)
+	write("\xff\xfbreba again")
+	if err := rec.close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := strings.Join(done, ","), "Reba,Ghost,Reba"; got != want {
+		t.Errorf("wanted %s to be completed, but got %s", want, got)
+	}
+
+	want := []struct {
+		name, audio string
+		track       Track
+	}{
+		{name: "Phish - 1997-11-22 - Reba.mp3", audio: "\xff\xfbreba more reba end of reba", track: reba},
+		{name: "Phish - 1997-11-22 - Ghost.mp3", audio: "\xff\xfbghost", track: ghost},
+		{name: "Phish - 1997-11-22 - Reba (2).mp3", audio: "\xff\xfbreba again", track: reba},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("wanted %d files, but got %v", len(want), files)
+	}
+	for i, w := range want {
+		if files[i] != w.name {
+			t.Errorf("wanted file %q, but got %q", w.name, files[i])
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, w.name))
+		if err != nil {
+			t.Fatalf("unable to read recording: %v", err)
+		}
+		tag := id3v2Tag(trackID3Frames(w.track))
+		if !bytes.HasPrefix(b, tag) {
+			t.Errorf("%s: wanted it to start with its tags", w.name)
+		}
+		if got := string(bytes.TrimPrefix(b, tag)); got != w.audio {
+			t.Errorf("%s: wanted audio %q, but got %q", w.name, w.audio, got)
+		}
+	}
+}
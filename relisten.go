@@ -1,122 +1,121 @@
 package main
 
 import (
-	"encoding/json"
-	"io"
-	"log"
-	"net/http"
-	"os"
+	"context"
+	"fmt"
 	"path/filepath"
-	"time"
+	"sort"
+	"strings"
+
+	"github.com/ianfoo/ph/relisten"
 )
 
-const relistenArtistsCacheFile = "relisten-artists.json"
+// newRelistenClient returns a Relisten API client that caches responses in
+// the ph cache directory, and gives up on requests after the configured
+// Relisten timeout. Requests go through the Relisten circuit breaker, but
+// not through the cache of apiClient, since the client keeps its own,
+// which also answers when Relisten cannot be reached. If the cache
+// directory cannot be determined, responses are not cached.
+func newRelistenClient(cfg config) *relisten.Client {
+	var relistenCacheDir string
+	if dir, err := cfg.cacheDir(); err == nil {
+		relistenCacheDir = filepath.Join(dir, "relisten")
+	}
+	rc := relisten.New(withBreaker(httpClient(cfg), relistenBreaker), relistenCacheDir)
+	rc.Timeout = cfg.Timeouts.relisten()
+	rc.BaseURL = cfg.Endpoints.relisten()
+	rc.Now = appClock.Now
+	return rc
+}
 
-// relistenArtist describes part of the entries that are returned
-// from Relisten's artists API. There is much more data contained
-// in the response, but we are only concerned with the artist name
-// and the "slug" which is used in building a URL for a particular
-// artist on Relisten. E.g., For the artist "Umphrey's McGee" the
-// slug is "umphreys", and the resultant Relisten URL would be
-// https://relisten.net/umphreys/...
-type relistenArtist struct {
-	Name string `json:"name"`
-	Slug string `json:"slug"`
+// relistenConfig holds settings for linking to shows on Relisten.
+type relistenConfig struct {
+	Artists []string `yaml:"artists,omitempty" doc:"Relisten slugs for artists, as artist: slug, taking precedence over Relisten's own list, or artist: none to never link an artist, e.g. [\"Dead & Company: dead-and-company\", \"Phish: none\"]"`
 }
 
-// relistenGetArtists fetches the list of artists available on Relisten from
-// either a local cache or the Relisten artists API and returns a map from the
-// readable name to the "slug" used in the Relisten URL.
-func relistenGetArtists(client *http.Client) (map[string]string, error) {
-	var artistsList []relistenArtist
-	cachePath, err := relistenArtistsCachePath()
-	if err != nil {
-		// TODO Fall through to API fetch
-		return nil, err
-	}
-	cacheFile, err := relistenGetArtistsCache(cachePath)
-	if err != nil {
-		return nil, err
-	}
-	if cacheFile != nil {
-		defer cacheFile.Close()
-		if err := json.NewDecoder(cacheFile).Decode(&artistsList); err != nil {
-			log.Printf("warning: cannot decode Relisten artists cache: %v", err)
+// relistenNoSlug is the slug that keeps an artist from being linked.
+const relistenNoSlug = "none"
+
+// artistOverrides parses the artist overrides, mapping each artist to its
+// slug, or to relistenNoSlug if it is never to be linked.
+func (c relistenConfig) artistOverrides() (map[string]string, error) {
+	overrides := make(map[string]string, len(c.Artists))
+	for _, entry := range c.Artists {
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("%q must be artist: slug", entry)
 		}
-		if len(artistsList) > 0 {
-			return relistenMakeArtistsMap(artistsList), nil
+		artist, slug := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		if artist == "" || slug == "" || strings.ContainsAny(slug, "/ ") {
+			return nil, fmt.Errorf("%q must be artist: slug", entry)
 		}
+		overrides[artist] = slug
 	}
-	apiRespBody, err := relistenFetchArtists(client)
-	if err != nil {
-		return nil, err
-	}
-	defer apiRespBody.Close()
-	if err := json.NewDecoder(apiRespBody).Decode(&artistsList); err != nil {
-		return nil, err
-	}
-	if err := relistenWriteAristsCache(cachePath, artistsList); err != nil {
-		log.Printf("warning: could not write Relisten artists cache: %v", err)
-	}
-	return relistenMakeArtistsMap(artistsList), nil
+	return overrides, nil
 }
 
-// relistenFetchArtists gets the list of artists that Relisten supports from
-// the Relisten artists API.
-func relistenFetchArtists(client *http.Client) (io.ReadCloser, error) {
-	const relistenArtistsAPI = "https://api.relisten.net/api/v2/artists"
-	resp, err := client.Get(relistenArtistsAPI)
+// relistenGetArtists fetches the list of artists available on Relisten and
+// returns a map from the readable name to the "slug" used in the Relisten
+// URL. E.g., For the artist "Umphrey's McGee" the slug is "umphreys", and
+// the resultant Relisten URL would be https://relisten.net/umphreys/...
+// The overrides configured with relisten.artists take precedence, and are
+// returned even if Relisten cannot be reached, along with the error.
+func relistenGetArtists(rc *relisten.Client, cfg config) (map[string]string, error) {
+	// Overrides with errors are left out; loadConfig warns of them.
+	overrides, _ := cfg.Relisten.artistOverrides()
+	artistsList, err := rc.Artists(context.Background())
 	if err != nil {
-		return nil, err
+		return overrideRelistenArtists(nil, overrides), err
 	}
-	return resp.Body, nil
+	return overrideRelistenArtists(relistenMakeArtistsMap(artistsList, cfg), overrides), nil
 }
 
-// relistenGetArtistsCache returns an io.ReadCloser for the local Relisten
-// artists cache, if it exists and if it has been modified within the last
-// week. If it doesn't exist or is older than one week, a nil ReadCloser is
-// returned. This is simpler than creating a sentinel error that must be
-// interpreted by the caller, rather allowing it to just check for nil and look
-// elsewhere for Relisten artists.
-func relistenGetArtistsCache(path string) (io.ReadCloser, error) {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return nil, nil
+// overrideRelistenArtists applies overrides to the artists on Relisten,
+// replacing the slug of each artist named, ignoring case, or removing the
+// artist if its slug is relistenNoSlug.
+func overrideRelistenArtists(artists, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return artists
 	}
-	if err != nil {
-		return nil, err
-	}
-	if invalidateBefore := time.Now().Add(-7 * 24 * time.Hour); info.ModTime().Before(invalidateBefore) {
-		return nil, nil
+	out := make(map[string]string, len(artists)+len(overrides))
+	for name, slug := range artists {
+		out[name] = slug
 	}
-	return os.Open(path)
-}
-
-func relistenWriteAristsCache(path string, artistsList []relistenArtist) error {
-	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0777)); err != nil {
-		return err
-	}
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	return json.NewEncoder(f).Encode(artistsList)
-}
-
-func relistenArtistsCachePath() (string, error) {
-	cacheDir, err := os.UserCacheDir()
-	if err != nil {
-		return "", err
+	for artist, slug := range overrides {
+		for name := range out {
+			if strings.EqualFold(name, artist) {
+				delete(out, name)
+			}
+		}
+		if slug != relistenNoSlug {
+			out[artist] = slug
+		}
 	}
-	p := filepath.Join(cacheDir, "ph", relistenArtistsCacheFile)
-	return p, nil
+	return out
 }
 
-func relistenMakeArtistsMap(artistsList []relistenArtist) map[string]string {
+func relistenMakeArtistsMap(artistsList []relisten.Artist, cfg config) map[string]string {
 	artists := make(map[string]string, len(artistsList))
 	for _, a := range artistsList {
 		artists[a.Name] = a.Slug
 	}
+	cfg.addRelistenAliases(artists)
 	return artists
 }
+
+// relistenArtistSlug returns the slug of the named artist, ignoring case.
+// If there is no such artist, the error suggests the closest name.
+func relistenArtistSlug(artists map[string]string, name string) (string, error) {
+	names := make([]string, 0, len(artists))
+	for a, slug := range artists {
+		if strings.EqualFold(a, name) {
+			return slug, nil
+		}
+		names = append(names, a)
+	}
+	sort.Strings(names)
+	if match, ok := closestMatch(name, names, 3); ok {
+		return "", fmt.Errorf("%s is not available on Relisten; did you mean %s?", name, match)
+	}
+	return "", fmt.Errorf("%s is not available on Relisten", name)
+}
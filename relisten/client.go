@@ -0,0 +1,229 @@
+// Package relisten is a client for version 3 of the Relisten API, which
+// catalogs live recordings of many bands and provides the streaming links
+// that ph shows for tracks with a performance date.
+package relisten
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the base URL of version 3 of the public Relisten API.
+const DefaultBaseURL = "https://api.relisten.net/api/v3"
+
+// ErrNotFound is returned when the requested artist, year, or show does not
+// exist on Relisten.
+var ErrNotFound = errors.New("not found on Relisten")
+
+// Cache lifetimes for each kind of resource. The artist list rarely
+// changes; shows gain sources and ratings over time, but slowly.
+const (
+	artistsTTL = 7 * 24 * time.Hour
+	yearsTTL   = 24 * time.Hour
+	showTTL    = 24 * time.Hour
+)
+
+// Client fetches data from the Relisten API. Responses are cached on disk
+// when CacheDir is set, and requests are spaced at least MinInterval apart
+// to be polite to the service. Each request is abandoned after Timeout, if
+// it is set. When the API cannot be reached or fails, a cached response is
+// used however old it is. Cached responses are aged by Now, which is
+// time.Now if not set. A Client is safe for concurrent use.
+type Client struct {
+	BaseURL     string
+	HTTPClient  *http.Client
+	CacheDir    string
+	MinInterval time.Duration
+	Timeout     time.Duration
+	Now         func() time.Time
+
+	mu          sync.Mutex
+	lastRequest time.Time
+}
+
+// New returns a Client for the public Relisten API that caches responses in
+// cacheDir. If cacheDir is empty, responses are not cached.
+func New(httpClient *http.Client, cacheDir string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		BaseURL:     DefaultBaseURL,
+		HTTPClient:  httpClient,
+		CacheDir:    cacheDir,
+		MinInterval: 250 * time.Millisecond,
+	}
+}
+
+// Artists returns every artist available on Relisten.
+func (c *Client) Artists(ctx context.Context) ([]Artist, error) {
+	var artists []Artist
+	err := c.get(ctx, "/artists", artistsTTL, &artists)
+	return artists, err
+}
+
+// Years returns a summary of each year that has shows for the artist
+// identified by slug.
+func (c *Client) Years(ctx context.Context, slug string) ([]Year, error) {
+	var years []Year
+	err := c.get(ctx, "/artists/"+slug+"/years", yearsTTL, &years)
+	return years, err
+}
+
+// Year returns the shows the artist identified by slug performed in year.
+func (c *Client) Year(ctx context.Context, slug string, year int) (YearShows, error) {
+	var ys YearShows
+	err := c.get(ctx, fmt.Sprintf("/artists/%s/years/%d", slug, year), yearsTTL, &ys)
+	return ys, err
+}
+
+// Show returns the show the artist identified by slug performed on date,
+// including all of its sources and their tracks.
+func (c *Client) Show(ctx context.Context, slug string, date time.Time) (Show, error) {
+	var show Show
+	err := c.get(ctx, fmt.Sprintf("/artists/%s/shows/%s", slug, date.Format("2006-01-02")), showTTL, &show)
+	return show, err
+}
+
+// get fetches path from the API, or from the cache if a fresh enough copy
+// exists, and decodes the JSON response into v.
+func (c *Client) get(ctx context.Context, path string, ttl time.Duration, v interface{}) error {
+	if b, ok := c.readCache(path, ttl); ok {
+		if err := json.Unmarshal(b, v); err == nil {
+			return nil
+		}
+	}
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(c.BaseURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if c.useStale(path, v) {
+			return nil
+		}
+		return fmt.Errorf("get Relisten %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("get Relisten %s: %w", path, ErrNotFound)
+	case resp.StatusCode >= http.StatusInternalServerError && c.useStale(path, v):
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("get Relisten %s: %s", path, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read Relisten %s: %w", path, err)
+	}
+	// The API responds with a JSON null, rather than a 404, for some
+	// resources that do not exist.
+	if string(b) == "null" {
+		return fmt.Errorf("get Relisten %s: %w", path, ErrNotFound)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("parse Relisten %s: %w", path, err)
+	}
+	c.writeCache(path, b)
+	return nil
+}
+
+func (c *Client) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// wait blocks until at least MinInterval has passed since the previous
+// request made by the client.
+func (c *Client) wait(ctx context.Context) error {
+	c.mu.Lock()
+	next := c.lastRequest.Add(c.MinInterval)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	c.lastRequest = next
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cachePath returns where the response for path is cached. Responses are
+// cached by their full URL, so that those of another version of the API,
+// or of a mirror, are not mistaken for them.
+func (c *Client) cachePath(path string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSuffix(c.BaseURL, "/") + path))
+	return filepath.Join(c.CacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// useStale decodes the cached response for path into v, however old it
+// is, reporting whether there was one.
+func (c *Client) useStale(path string, v interface{}) bool {
+	b, ok := c.readCache(path, -1)
+	return ok && json.Unmarshal(b, v) == nil
+}
+
+// readCache returns the cached response for path if it was written within
+// ttl, or at any time if ttl is negative.
+func (c *Client) readCache(path string, ttl time.Duration) ([]byte, bool) {
+	if c.CacheDir == "" {
+		return nil, false
+	}
+	p := c.cachePath(path)
+	info, err := os.Stat(p)
+	if err != nil || (ttl >= 0 && c.now().Sub(info.ModTime()) > ttl) {
+		return nil, false
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// writeCache stores a response for path. Failing to write the cache is not
+// fatal, since the response can always be fetched again.
+func (c *Client) writeCache(path string, b []byte) {
+	if c.CacheDir == "" {
+		return
+	}
+	if err := os.MkdirAll(c.CacheDir, os.FileMode(0777)); err != nil {
+		return
+	}
+	p := c.cachePath(path)
+	tmp := p + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, os.FileMode(0666)); err != nil {
+		return
+	}
+	os.Rename(tmp, p)
+}
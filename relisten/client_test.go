@@ -0,0 +1,144 @@
+package relisten
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *int) {
+	t.Helper()
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	dir, err := ioutil.TempDir("", "relisten")
+	if err != nil {
+		t.Fatalf("unable to create cache dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	c := New(srv.Client(), dir)
+	c.BaseURL = srv.URL
+	c.MinInterval = 0
+	return c, &requests
+}
+
+func TestClient_Artists(t *testing.T) {
+	c, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/artists" {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+		w.Write([]byte(`[{"id":1,"name":"Phish","slug":"phish"},{"id":2,"name":"Grateful Dead","slug":"grateful-dead"}]`))
+	})
+	for i := 0; i < 2; i++ {
+		artists, err := c.Artists(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(artists) != 2 || artists[1].Slug != "grateful-dead" {
+			t.Fatalf("unexpected artists: %+v", artists)
+		}
+	}
+	if *requests != 1 {
+		t.Errorf("wanted 1 request with caching, but got %d", *requests)
+	}
+}
+
+func TestClient_Show(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/artists/phish/shows/1997-11-17":
+			w.Write([]byte(`{
+				"id": 10,
+				"uuid": "9c2b1b7e-5d4e-4a5a-8f0e-1d2c3b4a5f60",
+				"artist_uuid": "d4c9f5a2-1b3e-4f6a-9c8d-7e6f5a4b3c21",
+				"display_date": "1997-11-17",
+				"date": "1997-11-17T00:00:00Z",
+				"has_soundboard_source": true,
+				"venue": {"uuid": "0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b", "name": "McNichols Arena", "location": "Denver, CO"},
+				"sources": [{
+					"id": 20,
+					"is_soundboard": true,
+					"taper": "Kevin Shapiro",
+					"sets": [
+						{"index": 0, "name": "Set 1", "tracks": [{"title": "Timber (Jerry)", "duration": 600}]},
+						{"index": 1, "name": "Set 2", "tracks": [{"title": "Punch You in the Eye", "duration": 420}, {"title": "Bathtub Gin", "duration": 900, "flac_url": "https://example.com/gin.flac"}]}
+					]
+				}]
+			}`))
+		default:
+			w.Write([]byte(`null`))
+		}
+	})
+	show, err := c.Show(context.Background(), "phish", time.Date(1997, 11, 17, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := show.Date.Format("2006-01-02"), "1997-11-17"; got != want {
+		t.Errorf("wanted date %s, but got %s", want, got)
+	}
+	if show.Venue == nil || show.Venue.Name != "McNichols Arena" {
+		t.Errorf("unexpected venue: %+v", show.Venue)
+	}
+	tracks := show.Sources[0].Tracks()
+	if len(tracks) != 3 {
+		t.Fatalf("wanted 3 tracks, but got %d", len(tracks))
+	}
+	if got, want := tracks[2].Length(), 15*time.Minute; got != want {
+		t.Errorf("wanted length %v, but got %v", want, got)
+	}
+	if !show.HasSoundboardSource || show.Sources[0].Taper == "" || tracks[2].FLACURL == "" {
+		t.Errorf("wanted the fields of version 3 of the API, but got %+v", show)
+	}
+
+	_, err = c.Show(context.Background(), "phish", time.Date(1997, 11, 18, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("wanted ErrNotFound, but got %v", err)
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	c.Timeout = 10 * time.Millisecond
+	if _, err := c.Artists(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wanted %v, but got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestClient_Stale(t *testing.T) {
+	fail := false
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`[{"id":1,"name":"Phish","slug":"phish"}]`))
+	})
+	if _, err := c.Artists(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Age the cached copy past its lifetime, then fail to fetch a new one.
+	old := time.Now().Add(-2 * artistsTTL)
+	if err := os.Chtimes(c.cachePath("/artists"), old, old); err != nil {
+		t.Fatalf("unable to age cache: %v", err)
+	}
+	fail = true
+	artists, err := c.Artists(context.Background())
+	if err != nil {
+		t.Fatalf("wanted the stale copy, but got error: %v", err)
+	}
+	if len(artists) != 1 || artists[0].Slug != "phish" {
+		t.Errorf("unexpected artists: %+v", artists)
+	}
+}
@@ -0,0 +1,145 @@
+package relisten
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Artist is an artist whose shows are available on Relisten.
+type Artist struct {
+	ID            int    `json:"id"`
+	UUID          string `json:"uuid"`
+	MusicBrainzID string `json:"musicbrainz_id,omitempty"`
+	Name          string `json:"name"`
+	SortName      string `json:"sort_name,omitempty"`
+	Slug          string `json:"slug"`
+	ShowCount     int    `json:"show_count,omitempty"`
+	SourceCount   int    `json:"source_count,omitempty"`
+}
+
+// Year summarizes the shows available for an artist in a single year.
+type Year struct {
+	ID          int    `json:"id"`
+	UUID        string `json:"uuid,omitempty"`
+	Year        string `json:"year"`
+	ShowCount   int    `json:"show_count"`
+	SourceCount int    `json:"source_count"`
+}
+
+// YearShows is a year along with the shows available in it.
+type YearShows struct {
+	Year
+	Shows []Show `json:"shows"`
+}
+
+// Venue is where a show was performed.
+type Venue struct {
+	ID       int    `json:"id"`
+	UUID     string `json:"uuid,omitempty"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	Slug     string `json:"slug,omitempty"`
+}
+
+// Show is a single performance by an artist. Sources are only populated
+// when a show is fetched individually.
+type Show struct {
+	ID                  int      `json:"id"`
+	UUID                string   `json:"uuid,omitempty"`
+	ArtistUUID          string   `json:"artist_uuid,omitempty"`
+	DisplayDate         string   `json:"display_date"`
+	Date                Date     `json:"date"`
+	Venue               *Venue   `json:"venue,omitempty"`
+	AvgRating           float64  `json:"avg_rating"`
+	Duration            float64  `json:"avg_duration,omitempty"`
+	SourceCount         int      `json:"source_count,omitempty"`
+	HasSoundboardSource bool     `json:"has_soundboard_source,omitempty"`
+	Sources             []Source `json:"sources,omitempty"`
+}
+
+// Source is one recording of a show, such as a particular soundboard or
+// audience taping.
+type Source struct {
+	ID                 int     `json:"id"`
+	UUID               string  `json:"uuid,omitempty"`
+	UpstreamIdentifier string  `json:"upstream_identifier,omitempty"`
+	IsSoundboard       bool    `json:"is_soundboard"`
+	IsRemaster         bool    `json:"is_remaster,omitempty"`
+	AvgRating          float64 `json:"avg_rating"`
+	NumRatings         int     `json:"num_ratings"`
+	Duration           float64 `json:"duration"`
+	Description        string  `json:"description,omitempty"`
+	Taper              string  `json:"taper,omitempty"`
+	Transferrer        string  `json:"transferrer,omitempty"`
+	Lineage            string  `json:"lineage,omitempty"`
+	Sets               []Set   `json:"sets"`
+}
+
+// Tracks returns every track in the source, in order, across all sets.
+func (s Source) Tracks() []Track {
+	var tracks []Track
+	for _, set := range s.Sets {
+		tracks = append(tracks, set.Tracks...)
+	}
+	return tracks
+}
+
+// Set is a set within a source, such as "Set 1" or "Encore".
+type Set struct {
+	ID       int     `json:"id"`
+	UUID     string  `json:"uuid,omitempty"`
+	Index    int     `json:"index"`
+	IsEncore bool    `json:"is_encore"`
+	Name     string  `json:"name"`
+	Tracks   []Track `json:"tracks"`
+}
+
+// Track is a single track of a source.
+type Track struct {
+	ID            int     `json:"id"`
+	UUID          string  `json:"uuid,omitempty"`
+	Title         string  `json:"title"`
+	Slug          string  `json:"slug"`
+	TrackPosition int     `json:"track_position"`
+	Duration      float64 `json:"duration"`
+	MP3URL        string  `json:"mp3_url,omitempty"`
+	FLACURL       string  `json:"flac_url,omitempty"`
+}
+
+// Length returns the track duration as a time.Duration.
+func (t Track) Length() time.Duration {
+	return time.Duration(t.Duration * float64(time.Second))
+}
+
+// Date is a calendar date as returned by the Relisten API, which encodes
+// dates as RFC 3339 timestamps at midnight UTC.
+type Date struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting both full
+// timestamps and bare dates.
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			d.Time = t
+			return nil
+		}
+	}
+	return &time.ParseError{Layout: time.RFC3339, Value: s}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(d.Format(time.RFC3339))
+}
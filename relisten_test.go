@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRelistenConfig_ArtistOverrides(t *testing.T) {
+	tt := []struct {
+		desc    string
+		artists []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{desc: "none", want: map[string]string{}},
+		{
+			desc:    "slugs and none",
+			artists: []string{"Dead & Company: dead-and-company", "Phish: none", "Artist: The Band: artist-the-band"},
+			want:    map[string]string{"Dead & Company": "dead-and-company", "Phish": "none", "Artist: The Band": "artist-the-band"},
+		},
+		{desc: "no slug", artists: []string{"Phish"}, wantErr: true},
+		{desc: "empty slug", artists: []string{"Phish: "}, wantErr: true},
+		{desc: "slug with a path", artists: []string{"Phish: phish/1997"}, wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := relistenConfig{Artists: tc.artists}.artistOverrides()
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("wanted an error, but got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOverrideRelistenArtists(t *testing.T) {
+	var (
+		fetched   = map[string]string{"Phish": "phish", "Grateful Dead": "grateful-dead"}
+		overrides = map[string]string{"phish": "none", "Dead & Company": "dead-and-company", "Grateful Dead": "gd"}
+		want      = map[string]string{"Grateful Dead": "gd", "Dead & Company": "dead-and-company"}
+	)
+	if got := overrideRelistenArtists(fetched, overrides); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+	if len(fetched) != 2 || fetched["Phish"] != "phish" {
+		t.Errorf("wanted the fetched artists left alone, but got %v", fetched)
+	}
+	if got := overrideRelistenArtists(nil, overrides); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted the overrides without Relisten's list, but got %v", got)
+	}
+
+	reba := Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")}
+	if url := reba.streamingURL(overrideRelistenArtists(fetched, overrides)); url != "" {
+		t.Errorf("wanted no link for an artist overridden with none, but got %s", url)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configReloadDelay is how long ph serve waits after its config file
+// changes before reloading it, so that a file an editor writes in several
+// steps is read once it is complete.
+const configReloadDelay = 100 * time.Millisecond
+
+// reloadableSettings are the settings ph serve applies as soon as its
+// config file changes, by key, or by section for keys ending in a dot.
+// The others take effect when it restarts.
+var reloadableSettings = []string{"station", "play.stream_url", "serve.poll_interval", "serve.profiles", "serve.profile_tokens", "serve.token", "serve.username", "serve.password", "serve.cors_origins", "favorites.", "notify."}
+
+// reloadable reports whether ph serve applies a change to the setting key
+// without restarting.
+func reloadable(key string) bool {
+	for _, r := range reloadableSettings {
+		if key == r || (strings.HasSuffix(r, ".") && strings.HasPrefix(key, r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// configChanges returns the keys of the settings that differ between old
+// and new, in the order they are declared.
+func configChanges(old, new config) []string {
+	var (
+		changed []string
+		o, n    = reflect.ValueOf(old), reflect.ValueOf(new)
+	)
+	for _, s := range configSettings() {
+		if !reflect.DeepEqual(o.FieldByIndex(s.index).Interface(), n.FieldByIndex(s.index).Interface()) {
+			changed = append(changed, s.key)
+		}
+	}
+	return changed
+}
+
+// configWatcher watches the config file for changes. Each time its
+// contents change, it validates them, and if they are valid, loads the
+// config and passes it to apply, along with the config it replaces, if any
+// of the settings that can be changed while running have changed.
+type configWatcher struct {
+	path  string
+	delay time.Duration
+	log   *structuredLogger
+
+	// load reads the config, from the file and the environment, without
+	// putting it into effect, which is left to apply.
+	load  func() (config, error)
+	apply func(old, new config)
+
+	current  config
+	contents []byte
+}
+
+// newConfigWatcher returns a watcher of the config file at path, which cfg
+// was loaded from.
+func newConfigWatcher(path string, cfg config, log *structuredLogger, apply func(old, new config)) *configWatcher {
+	w := &configWatcher{
+		path:    filepath.Clean(path),
+		delay:   configReloadDelay,
+		log:     log,
+		load:    readConfig,
+		apply:   apply,
+		current: cfg,
+	}
+	w.contents, _ = ioutil.ReadFile(path)
+	return w
+}
+
+// run watches the config file for changes until ctx is canceled. The
+// directory holding the file is watched, rather than the file itself, since
+// editors and config set replace the file, which ends a watch on it.
+func (w *configWatcher) run(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.log.Warn("unable to watch config file; changes need a restart", "path", w.path, "error", err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(w.path)); err != nil {
+		w.log.Warn("unable to watch config file; changes need a restart", "path", w.path, "error", err)
+		return
+	}
+
+	// reload fires once the file has gone unchanged for w.delay.
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(e.Name) == w.path {
+				reload = time.After(w.delay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			w.log.Warn("error watching config file", "path", w.path, "error", err)
+		case <-reload:
+			reload = nil
+			w.check()
+		}
+	}
+}
+
+// check reloads the config if the file has changed since it was last
+// read. A file with errors is not applied, and is not checked again
+// until it changes.
+func (w *configWatcher) check() {
+	b, err := ioutil.ReadFile(w.path)
+	if err != nil && !os.IsNotExist(err) {
+		w.log.Warn("unable to read config file", "path", w.path, "error", err)
+		return
+	}
+	if bytes.Equal(b, w.contents) {
+		return
+	}
+	w.contents = b
+	if errs := validateConfig(b); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		w.log.Warn("config file has errors; not reloaded", "path", w.path, "errors", strings.Join(msgs, "; "))
+		return
+	}
+	cfg, err := w.load()
+	if err != nil {
+		w.log.Warn("unable to reload config", "path", w.path, "error", err)
+		return
+	}
+	var applied, deferred []string
+	for _, key := range configChanges(w.current, cfg) {
+		if reloadable(key) {
+			applied = append(applied, key)
+		} else {
+			deferred = append(deferred, key)
+		}
+	}
+	if len(applied) > 0 {
+		w.apply(w.current, cfg)
+		w.log.Info("config reloaded", "changed", strings.Join(applied, ", "))
+	}
+	if len(deferred) > 0 {
+		w.log.Warn("config changed; restart to apply", "changed", strings.Join(deferred, ", "))
+	}
+	w.current = cfg
+}
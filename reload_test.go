@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestReloadable(t *testing.T) {
+	tt := []struct {
+		key  string
+		want bool
+	}{
+		{key: "station", want: true},
+		{key: "serve.poll_interval", want: true},
+		{key: "favorites.artists", want: true},
+		{key: "notify.email.to", want: true},
+		{key: "serve.profile_tokens", want: true},
+		{key: "serve.listen"},
+		{key: "play.player"},
+	}
+	for _, tc := range tt {
+		if got := reloadable(tc.key); got != tc.want {
+			t.Errorf("%s: wanted %v, but got %v", tc.key, tc.want, got)
+		}
+	}
+}
+
+func TestConfigChanges(t *testing.T) {
+	var old, new config
+	old.Favorites.Artists = []string{"Phish"}
+	new.Favorites.Artists = []string{"Phish", "Goose"}
+	new.Station = "s2"
+	new.Serve.PollInterval = time.Minute
+	want := []string{"station", "favorites.artists", "serve.poll_interval"}
+	got := configChanges(old, new)
+	// The order is the order the settings are declared in, so compare them
+	// as sets.
+	if len(got) != len(want) {
+		t.Fatalf("wanted %v, but got %v", want, got)
+	}
+	for _, key := range want {
+		if !containsString(got, key) {
+			t.Errorf("wanted %s among the changes, but got %v", key, got)
+		}
+	}
+	if got := configChanges(old, old); len(got) != 0 {
+		t.Errorf("wanted no changes, but got %v", got)
+	}
+}
+
+func TestConfigWatcher_Check(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-reload")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	write := func(s string) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatalf("unable to write config: %v", err)
+		}
+	}
+	write("station: s1\n")
+
+	var (
+		logs    strings.Builder
+		logger  = &structuredLogger{w: &logs, now: time.Now}
+		applied []config
+		w       = newConfigWatcher(path, config{Station: "s1"}, logger, func(old, new config) {
+			applied = append(applied, new)
+		})
+	)
+	w.load = func() (config, error) {
+		var cfg config
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		return cfg, yaml.Unmarshal(b, &cfg)
+	}
+
+	// Nothing happens while the file is unchanged.
+	w.check()
+	if len(applied) != 0 {
+		t.Fatalf("wanted nothing applied, but got %v", applied)
+	}
+
+	write("station: s2\nfavorites:\n  artists: [Phish]\n")
+	w.check()
+	if len(applied) != 1 || applied[0].Station != "s2" || !reflect.DeepEqual(applied[0].Favorites.Artists, []string{"Phish"}) {
+		t.Fatalf("wanted the new config applied, but got %v", applied)
+	}
+	if !strings.Contains(logs.String(), "config reloaded") || !strings.Contains(logs.String(), "station, favorites.artists") {
+		t.Errorf("wanted the changes logged, but got %q", logs.String())
+	}
+
+	// A config with errors is not applied.
+	logs.Reset()
+	write("station: s3\nformat: fancy\n")
+	w.check()
+	if len(applied) != 1 {
+		t.Errorf("wanted an invalid config not applied, but got %v", applied[1:])
+	}
+	if !strings.Contains(logs.String(), "not reloaded") || !strings.Contains(logs.String(), "format") {
+		t.Errorf("wanted the errors logged, but got %q", logs.String())
+	}
+
+	// Settings that need a restart are logged, but not applied.
+	logs.Reset()
+	write("station: s2\nfavorites:\n  artists: [Phish]\nserve:\n  listen: :9090\n")
+	w.check()
+	if len(applied) != 1 {
+		t.Errorf("wanted nothing applied, but got %v", applied[1:])
+	}
+	if !strings.Contains(logs.String(), "restart to apply") || !strings.Contains(logs.String(), "serve.listen") {
+		t.Errorf("wanted the change that needs a restart logged, but got %q", logs.String())
+	}
+}
+
+func TestConfigWatcher_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-reload")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte("station: s1\n"), 0644); err != nil {
+		t.Fatalf("unable to write config: %v", err)
+	}
+
+	var (
+		logs    strings.Builder
+		logger  = &structuredLogger{w: &logs, now: time.Now}
+		applied = make(chan config, 1)
+		w       = newConfigWatcher(path, config{Station: "s1"}, logger, func(old, new config) {
+			applied <- new
+		})
+	)
+	w.load = func() (config, error) {
+		var cfg config
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		return cfg, yaml.Unmarshal(b, &cfg)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Replace the file, as an editor does, once the watch has started.
+	time.Sleep(50 * time.Millisecond)
+	tmp := filepath.Join(dir, "config.yaml.tmp")
+	if err := ioutil.WriteFile(tmp, []byte("station: s2\n"), 0644); err != nil {
+		t.Fatalf("unable to write config: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("unable to replace config: %v", err)
+	}
+	select {
+	case cfg := <-applied:
+		if cfg.Station != "s2" {
+			t.Errorf("wanted station s2, but got %q", cfg.Station)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("wanted the new config applied, but it wasn't; logs: %q", logs.String())
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// playRing holds the most recent plays in memory, up to a fixed number.
+// Once it is full, each play added takes the place of the oldest, so that
+// however long ph serve runs it holds no more history in memory than that;
+// older plays are only in the store. It is safe for concurrent use.
+type playRing struct {
+	mu    sync.RWMutex
+	plays []Track
+	next  int
+	n     int
+}
+
+// newPlayRing returns a ring that holds up to size plays, or one play if
+// size is less than one.
+func newPlayRing(size int) *playRing {
+	if size < 1 {
+		size = 1
+	}
+	return &playRing{plays: make([]Track, size)}
+}
+
+// add adds a play, in constant time, taking the place of the oldest if the
+// ring is full.
+func (r *playRing) add(t Track) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.plays[r.next] = t
+	r.next = (r.next + 1) % len(r.plays)
+	if r.n < len(r.plays) {
+		r.n++
+	}
+}
+
+// find returns the play with the given ID, and reports whether the ring
+// has it.
+func (r *playRing) find(id string) (Track, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := 1; i <= r.n; i++ {
+		if t := r.plays[(r.next-i+len(r.plays))%len(r.plays)]; t.PlayID == id {
+			return t, true
+		}
+	}
+	return Track{}, false
+}
+
+// recent returns the plays in the ring, oldest first.
+func (r *playRing) recent() TrackList {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	plays := make(TrackList, r.n)
+	for i := range plays {
+		plays[i] = r.plays[(r.next-r.n+i+len(r.plays))%len(r.plays)]
+	}
+	return plays
+}
+
+// loadRecentPlays adds the plays in the play history to r, oldest first,
+// so that it ends up with the most recent. The history is read a line at
+// a time, so only r's worth of plays is held in memory.
+func (s *store) loadRecentPlays(r *playRing) error {
+	return s.readJSONLines(playsFile, func(line []byte) error {
+		var p playRecord
+		if err := json.Unmarshal(line, &p); err == nil {
+			if p.PlayID == "" {
+				p.PlayID = playID(Track(p))
+			}
+			r.add(Track(p))
+		}
+		return nil
+	})
+}
+
+// recordRecentPlay records t in the play history, as recordPlay does, but
+// checks for it among the recent plays in r instead of reading the whole
+// history, and adds it to them.
+func (s *store) recordRecentPlay(r *playRing, t Track) (bool, error) {
+	if t.StartTime.IsZero() {
+		return false, nil
+	}
+	if t.PlayID == "" {
+		t.PlayID = playID(t)
+	}
+	if _, ok := r.find(t.PlayID); ok {
+		return false, nil
+	}
+	if err := s.appendJSONLine(playsFile, playRecord(t)); err != nil {
+		return false, err
+	}
+	r.add(t)
+	return true, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlayRing(t *testing.T) {
+	var (
+		r     = newPlayRing(3)
+		plays TrackList
+	)
+	for i, title := range []string{"Reba", "Tweezer", "Possum", "Bowie"} {
+		p := Track{Title: title, StartTime: mustParseDate("2020-06-05T20:00:00").Add(time.Duration(i) * 10 * time.Minute)}
+		p.PlayID = playID(p)
+		plays = append(plays, p)
+		r.add(p)
+	}
+	if got, want := r.recent(), plays[1:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+	if _, ok := r.find(plays[0].PlayID); ok {
+		t.Error("wanted the oldest play to have been overwritten")
+	}
+	if got, ok := r.find(plays[2].PlayID); !ok || got.Title != "Possum" {
+		t.Errorf("wanted to find Possum, but got %v (%v)", got, ok)
+	}
+	if got := newPlayRing(0).recent(); len(got) != 0 {
+		t.Errorf("wanted an empty ring, but got %v", got)
+	}
+}
+
+func TestStore_RecordRecentPlay(t *testing.T) {
+	var (
+		st    = newTestStore(t)
+		reba  = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:00:00")}
+		bowie = Track{Artist: "Phish", Title: "Bowie", StartTime: mustParseDate("2020-06-05T20:15:00")}
+	)
+	if _, err := st.recordPlay(reba); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A restart picks up the plays already recorded, so the track still
+	// airing is not recorded again.
+	r := newPlayRing(1)
+	if err := st.loadRecentPlays(r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		track Track
+		want  bool
+	}{{reba, false}, {bowie, true}, {bowie, false}} {
+		recorded, err := st.recordRecentPlay(r, tc.track)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if recorded != tc.want {
+			t.Errorf("%s: wanted recorded %v, but got %v", tc.track.Title, tc.want, recorded)
+		}
+	}
+	plays, err := st.plays()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plays) != 2 || plays[1].Title != "Bowie" {
+		t.Errorf("wanted plays of Reba and Bowie, but got %v", plays)
+	}
+}
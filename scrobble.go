@@ -0,0 +1,639 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+)
+
+const (
+	scrobbleQueueFile = "scrobbles.json"
+
+	// The default scrobble rules are Last.fm's: a track is scrobbled once
+	// half of it, or four minutes of it, has been heard, unless it is
+	// shorter than 30 seconds.
+	defaultScrobbleMinPercent = 50
+	defaultScrobbleMinListen  = 4 * time.Minute
+	defaultScrobbleMinLength  = 30 * time.Second
+
+	// maxScrobbleAge is how long a listen is kept to retry. Last.fm does
+	// not accept listens older than this.
+	maxScrobbleAge = 14 * 24 * time.Hour
+
+	// scrobbleBatchSize is the most listens submitted in one request,
+	// which is Last.fm's limit.
+	scrobbleBatchSize = 50
+
+	// scrobbleTimeout is how long to wait for a service to accept listens
+	// before leaving them to retry, so that ph play is not held up on
+	// exit while offline.
+	scrobbleTimeout = 15 * time.Second
+
+	defaultLastFMURL       = "https://ws.audioscrobbler.com/2.0/"
+	defaultListenBrainzURL = "https://api.listenbrainz.org"
+)
+
+// scrobbleConfig configures where ph play submits the tracks the user
+// listens to.
+type scrobbleConfig struct {
+	LastFM       lastFMConfig       `yaml:"lastfm,omitempty" doc:"Scrobbling to Last.fm"`
+	ListenBrainz listenBrainzConfig `yaml:"listenbrainz,omitempty" doc:"Submitting listens to ListenBrainz"`
+	File         string             `yaml:"file,omitempty" doc:"File to append listens to, as JSON lines"`
+
+	MinPercent int           `yaml:"min_percent,omitempty" doc:"Percent of a track to hear for it to be scrobbled (default 50)"`
+	MinListen  time.Duration `yaml:"min_listen,omitempty" doc:"How long to hear a track for it to be scrobbled however long it is (default 4m)"`
+	MinLength  time.Duration `yaml:"min_length,omitempty" doc:"Shortest track to scrobble (default 30s)"`
+
+	ExpandShows bool `yaml:"expand_shows,omitempty" doc:"Scrobble each song heard of a full show broadcast, timed by its setlist on Relisten, instead of nothing"`
+}
+
+func (c scrobbleConfig) minPercent() int {
+	if c.MinPercent > 0 {
+		return c.MinPercent
+	}
+	return defaultScrobbleMinPercent
+}
+
+func (c scrobbleConfig) minListen() time.Duration {
+	if c.MinListen > 0 {
+		return c.MinListen
+	}
+	return defaultScrobbleMinListen
+}
+
+func (c scrobbleConfig) minLength() time.Duration {
+	if c.MinLength > 0 {
+		return c.MinLength
+	}
+	return defaultScrobbleMinLength
+}
+
+// lastFMConfig holds the Last.fm API account ph scrobbles with, and the
+// user's credentials, which are used once to get a session key.
+type lastFMConfig struct {
+	APIKey    string `yaml:"api_key,omitempty" doc:"API key of your Last.fm API account"`
+	APISecret string `yaml:"api_secret,omitempty" doc:"Shared secret of your Last.fm API account"`
+	Username  string `yaml:"username,omitempty" doc:"Your Last.fm username"`
+	Password  string `yaml:"password,omitempty" doc:"Your Last.fm password"`
+}
+
+type listenBrainzConfig struct {
+	Token string `yaml:"token,omitempty" doc:"Your ListenBrainz user token, from your profile settings"`
+	URL   string `yaml:"url,omitempty" doc:"API root of the ListenBrainz server (default: https://api.listenbrainz.org)"`
+}
+
+// listen is a track the user listened to, as submitted to scrobblers.
+type listen struct {
+	Artist     string    `json:"artist"`
+	Title      string    `json:"title"`
+	Album      string    `json:"album,omitempty"`
+	ListenedAt time.Time `json:"listened_at"`
+	PlayID     string    `json:"play_id,omitempty"`
+}
+
+// listens returns the listens to t, heard from when playback started, or
+// when t started if later, until end, that can be scrobbled: the listen to
+// a song, or with expand_shows, those to the songs of a full show
+// broadcast that were heard, from its setlist on Relisten. Nothing else
+// can be scrobbled; station breaks are not music, and a full show
+// broadcast is not one song. length is how long t aired, as listenTo takes
+// it.
+func (c scrobbleConfig) listens(ctx context.Context, rc *relisten.Client, artists map[string]string, t Track, playbackStarted, end time.Time, length time.Duration) ([]listen, error) {
+	if !t.IsFullShow() {
+		if l, ok := c.listenTo(t, playbackStarted, end, length); ok {
+			return []listen{l}, nil
+		}
+		return nil, nil
+	}
+	slug, ok := artists[t.Artist]
+	if !c.ExpandShows || !ok || t.PerformanceTime.IsZero() || t.StartTime.IsZero() {
+		return nil, nil
+	}
+	show, err := rc.Show(ctx, slug, t.PerformanceTime)
+	if err != nil {
+		return nil, fmt.Errorf("get setlist of %s: %w", trackSummary(t), err)
+	}
+	source, ok := bestSource(show)
+	if !ok {
+		return nil, nil
+	}
+	return c.showListens(t, source, playbackStarted, end), nil
+}
+
+// listenTo returns the listen to the song t, heard from when playback
+// started, or when t started if later, until end, and reports whether it
+// can be scrobbled. The station does not report how long tracks are, so
+// length is how long t aired, from its start to the next track's, or zero
+// if that is not known, such as when playback stops before t ends. A song
+// of known length must be at least min_length long, and have been heard
+// for min_percent of it or for min_listen; one of unknown length must have
+// been heard for min_listen.
+func (c scrobbleConfig) listenTo(t Track, playbackStarted, end time.Time, length time.Duration) (listen, bool) {
+	if t.Kind() != trackKindSong || t.Artist == "" || t.Title == "" {
+		return listen{}, false
+	}
+	from := t.StartTime
+	if from.IsZero() || from.Before(playbackStarted) {
+		from = playbackStarted
+	}
+	if !c.heardEnough(end.Sub(from), length) {
+		return listen{}, false
+	}
+	// A track from a show has the show as its album, as in the
+	// now-playing display.
+	info := newNowPlayingInfo(t, true)
+	return listen{Artist: t.Artist, Title: t.Title, Album: info.Album, ListenedAt: from, PlayID: playID(t)}, true
+}
+
+// heardEnough reports whether hearing a song of the given length, or of
+// unknown length if it is zero, for heard is enough to scrobble it.
+func (c scrobbleConfig) heardEnough(heard, length time.Duration) bool {
+	switch {
+	case heard >= c.minListen():
+		return true
+	case length <= 0 || length < c.minLength():
+		return false
+	}
+	return heard*100 >= length*time.Duration(c.minPercent())
+}
+
+// showListens returns the listens to the songs of the full show broadcast
+// t heard from when playback started, or when t started if later, until
+// end. The broadcast is taken to be the sets of source it names, played
+// through from when t started, so each song starts when the songs before
+// it would have ended. Each song is scrobbled by the same rules as a track
+// of known length.
+func (c scrobbleConfig) showListens(t Track, source relisten.Source, playbackStarted, end time.Time) []listen {
+	var (
+		sets []relisten.Set
+		n    int
+	)
+	if t.Set == nil {
+		return nil
+	}
+	for _, set := range source.Sets {
+		switch {
+		case set.IsEncore:
+			if t.Set.HasEncore() {
+				sets = append(sets, set)
+			}
+		default:
+			n++
+			if n == t.Set.Number {
+				sets = append(sets, set)
+			}
+		}
+	}
+	from := t.StartTime
+	if from.Before(playbackStarted) {
+		from = playbackStarted
+	}
+	var (
+		listens []listen
+		start   = t.StartTime
+		album   = newNowPlayingInfo(t, true).Album
+	)
+	for _, set := range sets {
+		for _, song := range set.Tracks {
+			songEnd := start.Add(song.Length())
+			heardFrom, heardTo := start, songEnd
+			if heardFrom.Before(from) {
+				heardFrom = from
+			}
+			if heardTo.After(end) {
+				heardTo = end
+			}
+			if heardTo.After(heardFrom) && c.heardEnough(heardTo.Sub(heardFrom), song.Length()) {
+				listens = append(listens, listen{Artist: t.Artist, Title: song.Title, Album: album, ListenedAt: heardFrom, PlayID: playID(t)})
+			}
+			start = songEnd
+		}
+	}
+	return listens
+}
+
+// scrobbler submits listens to a service that keeps a record of them.
+type scrobbler interface {
+	// name identifies the scrobbler among the listens queued to retry.
+	name() string
+
+	// scrobble submits listens, oldest first. Either all are accepted or
+	// none are.
+	scrobble(ls []listen) error
+}
+
+// scrobblers returns a scrobbler for each service in the config.
+func (c scrobbleConfig) scrobblers(client *http.Client) []scrobbler {
+	var ss []scrobbler
+	if c.LastFM.APIKey != "" {
+		ss = append(ss, &lastFMScrobbler{cfg: c.LastFM, client: client, url: defaultLastFMURL})
+	}
+	if c.ListenBrainz.Token != "" {
+		u := c.ListenBrainz.URL
+		if u == "" {
+			u = defaultListenBrainzURL
+		}
+		ss = append(ss, listenBrainzScrobbler{token: c.ListenBrainz.Token, client: client, url: strings.TrimSuffix(u, "/")})
+	}
+	if c.File != "" {
+		ss = append(ss, fileScrobbler{path: c.File})
+	}
+	return ss
+}
+
+// lastFMScrobbler scrobbles to Last.fm. The session key Last.fm issues for
+// the user's credentials is stored with the tokens from ph auth login, so
+// the password is only sent once.
+type lastFMScrobbler struct {
+	cfg    lastFMConfig
+	client *http.Client
+	url    string
+
+	sessionKey string
+}
+
+func (s *lastFMScrobbler) name() string { return "lastfm" }
+
+func (s *lastFMScrobbler) scrobble(ls []listen) error {
+	sk, err := s.session()
+	if err != nil {
+		return err
+	}
+	params := url.Values{"method": {"track.scrobble"}, "sk": {sk}}
+	for i, l := range ls {
+		params.Set(fmt.Sprintf("artist[%d]", i), l.Artist)
+		params.Set(fmt.Sprintf("track[%d]", i), l.Title)
+		params.Set(fmt.Sprintf("timestamp[%d]", i), strconv.FormatInt(l.ListenedAt.Unix(), 10))
+		if l.Album != "" {
+			params.Set(fmt.Sprintf("album[%d]", i), l.Album)
+		}
+	}
+	if err := s.call(params, nil); err != nil {
+		var apiErr lastFMError
+		if errors.As(err, &apiErr) && apiErr.Code == lastFMInvalidSession {
+			// Get a new session next time.
+			s.sessionKey = ""
+			if tokens, err := loadTokens(); err == nil {
+				delete(tokens, s.name())
+				writeTokens(tokens)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// session returns the user's session key, getting one with their
+// credentials if there is none stored yet.
+func (s *lastFMScrobbler) session() (string, error) {
+	if s.sessionKey != "" {
+		return s.sessionKey, nil
+	}
+	tokens, err := loadTokens()
+	if err != nil {
+		return "", err
+	}
+	if t, ok := tokens[s.name()]; ok && t.AccessToken != "" {
+		s.sessionKey = t.AccessToken
+		return s.sessionKey, nil
+	}
+	if s.cfg.Username == "" || s.cfg.Password == "" {
+		return "", errors.New("set scrobble.lastfm.username and scrobble.lastfm.password to scrobble to Last.fm")
+	}
+	var resp struct {
+		Session struct {
+			Key string `json:"key"`
+		} `json:"session"`
+	}
+	params := url.Values{"method": {"auth.getMobileSession"}, "username": {s.cfg.Username}, "password": {s.cfg.Password}}
+	if err := s.call(params, &resp); err != nil {
+		return "", fmt.Errorf("log in to Last.fm: %w", err)
+	}
+	if err := saveToken(s.name(), oauthToken{AccessToken: resp.Session.Key}); err != nil {
+		return "", fmt.Errorf("save Last.fm session: %w", err)
+	}
+	s.sessionKey = resp.Session.Key
+	return s.sessionKey, nil
+}
+
+// lastFMInvalidSession is the Last.fm error code for a session key that
+// is no longer valid, such as one the user revoked.
+const lastFMInvalidSession = 9
+
+type lastFMError struct {
+	Code    int    `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e lastFMError) Error() string {
+	return fmt.Sprintf("Last.fm error %d: %s", e.Code, e.Message)
+}
+
+// call makes a signed call to the Last.fm API, decoding the response into
+// v if it is not nil.
+func (s *lastFMScrobbler) call(params url.Values, v interface{}) error {
+	params.Set("api_key", s.cfg.APIKey)
+	params.Set("api_sig", lastFMSignature(params, s.cfg.APISecret))
+	params.Set("format", "json")
+	resp, err := s.client.PostForm(s.url, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var apiErr lastFMError
+	if json.Unmarshal(b, &apiErr) == nil && apiErr.Code != 0 {
+		return apiErr
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Last.fm: %s", resp.Status)
+	}
+	if v != nil {
+		return json.Unmarshal(b, v)
+	}
+	return nil
+}
+
+// lastFMSignature signs the parameters of a Last.fm API call: the MD5 of
+// each name and value, ordered by name, followed by the shared secret.
+func lastFMSignature(params url.Values, secret string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if name != "format" && name != "api_sig" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteString(params.Get(name))
+	}
+	b.WriteString(secret)
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// listenBrainzScrobbler submits listens to ListenBrainz.
+type listenBrainzScrobbler struct {
+	token  string
+	client *http.Client
+	url    string
+}
+
+func (s listenBrainzScrobbler) name() string { return "listenbrainz" }
+
+func (s listenBrainzScrobbler) scrobble(ls []listen) error {
+	type trackMetadata struct {
+		ArtistName  string `json:"artist_name"`
+		TrackName   string `json:"track_name"`
+		ReleaseName string `json:"release_name,omitempty"`
+	}
+	type payload struct {
+		ListenedAt    int64         `json:"listened_at"`
+		TrackMetadata trackMetadata `json:"track_metadata"`
+	}
+	body := struct {
+		ListenType string    `json:"listen_type"`
+		Payload    []payload `json:"payload"`
+	}{ListenType: "single"}
+	if len(ls) > 1 {
+		body.ListenType = "import"
+	}
+	for _, l := range ls {
+		body.Payload = append(body.Payload, payload{
+			ListenedAt:    l.ListenedAt.Unix(),
+			TrackMetadata: trackMetadata{ArtistName: l.Artist, TrackName: l.Title, ReleaseName: l.Album},
+		})
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url+"/1/submit-listens", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("ListenBrainz: %s: %s", resp.Status, e.Error)
+		}
+		return fmt.Errorf("ListenBrainz: %s", resp.Status)
+	}
+	return nil
+}
+
+// fileScrobbler appends listens to a file as JSON lines, for keeping a
+// record without an account anywhere.
+type fileScrobbler struct {
+	path string
+}
+
+func (s fileScrobbler) name() string { return "file" }
+
+func (s fileScrobbler) scrobble(ls []listen) error {
+	var b []byte
+	for _, l := range ls {
+		line, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		b = append(append(b, line...), '\n')
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// queuedListen is a listen waiting to be submitted to a scrobbler.
+type queuedListen struct {
+	Scrobbler string `json:"scrobbler"`
+	Listen    listen `json:"listen"`
+	Attempts  int    `json:"attempts,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (s *store) scrobbleQueue() ([]queuedListen, error) {
+	var q []queuedListen
+	err := s.readJSON(scrobbleQueueFile, &q)
+	return q, err
+}
+
+func (s *store) saveScrobbleQueue(q []queuedListen) error {
+	return s.writeJSON(scrobbleQueueFile, q)
+}
+
+// scrobbleQueue submits listens to scrobblers. Each listen is queued in
+// the store before it is submitted, and stays there until it is accepted,
+// so that listens a scrobbler cannot take, such as while offline, are
+// retried later instead of lost. It is safe for concurrent use.
+type scrobbleQueue struct {
+	store      *store
+	scrobblers []scrobbler
+	now        func() time.Time
+
+	mu sync.Mutex
+}
+
+// submit submits ls to each scrobbler, along with any listens queued
+// before, and returns how many listens were accepted.
+func (q *scrobbleQueue) submit(ls ...listen) (int, error) {
+	return q.flush(ls)
+}
+
+// retry submits the queued listens, and returns how many were accepted.
+func (q *scrobbleQueue) retry() (int, error) {
+	return q.flush(nil)
+}
+
+func (q *scrobbleQueue) flush(ls []listen) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queued, err := q.store.scrobbleQueue()
+	if err != nil {
+		return 0, err
+	}
+	if len(ls) > 0 {
+		for _, s := range q.scrobblers {
+			for _, l := range ls {
+				queued = append(queued, queuedListen{Scrobbler: s.name(), Listen: l})
+			}
+		}
+		if err := q.store.saveScrobbleQueue(queued); err != nil {
+			return 0, err
+		}
+	}
+
+	var (
+		kept  []queuedListen
+		sent  int
+		errs  []string
+		audit = &auditLog{store: q.store}
+	)
+	for _, ql := range queued {
+		if q.now().Sub(ql.Listen.ListenedAt) <= maxScrobbleAge {
+			kept = append(kept, ql)
+		}
+	}
+	for _, s := range q.scrobblers {
+		var pending, others []queuedListen
+		for _, ql := range kept {
+			if ql.Scrobbler == s.name() {
+				pending = append(pending, ql)
+			} else {
+				others = append(others, ql)
+			}
+		}
+		// Once a batch fails, the rest are left for next time, so that
+		// listens are submitted in order.
+		for len(pending) > 0 {
+			n := len(pending)
+			if n > scrobbleBatchSize {
+				n = scrobbleBatchSize
+			}
+			ls := make([]listen, n)
+			for i := range ls {
+				ls[i] = pending[i].Listen
+			}
+			err := s.scrobble(ls)
+			audit.record(auditScrobble, s.name(), "", listensSummary(ls), err)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", s.name(), err))
+				for i := range pending {
+					pending[i].Attempts++
+					pending[i].Error = err.Error()
+				}
+				break
+			}
+			sent += n
+			pending = pending[n:]
+		}
+		kept = append(others, pending...)
+	}
+	if err := q.store.saveScrobbleQueue(kept); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return sent, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return sent, nil
+}
+
+func runScrobble(args []string) error {
+	const usage = "usage: ph scrobble status|retry"
+	if len(args) != 1 {
+		return errors.New(usage)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "status":
+		queued, err := st.scrobbleQueue()
+		if err != nil {
+			return err
+		}
+		if len(queued) == 0 {
+			fmt.Println(tr("No listens are waiting to be scrobbled."))
+			return nil
+		}
+		for _, ql := range queued {
+			fmt.Printf("%-12s %s %s - %s", ql.Scrobbler, ql.Listen.ListenedAt.Local().Format("Mon 2-Jan 15:04"), ql.Listen.Artist, ql.Listen.Title)
+			if ql.Error != "" {
+				fmt.Printf(" ("+tr("%d attempts: %s")+")", ql.Attempts, ql.Error)
+			}
+			fmt.Println()
+		}
+		return nil
+	case "retry":
+		scrobblers := cfg.Scrobble.scrobblers(&http.Client{Transport: cfg.Network.roundTripper(), Timeout: scrobbleTimeout})
+		if len(scrobblers) == 0 {
+			return errors.New(tr("no scrobblers are configured; set scrobble.lastfm, scrobble.listenbrainz, or scrobble.file"))
+		}
+		q := &scrobbleQueue{store: st, scrobblers: scrobblers, now: time.Now}
+		sent, err := q.retry()
+		fmt.Printf(tr("Scrobbled %d listens.")+"\n", sent)
+		return err
+	default:
+		return errors.New(usage)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+)
+
+func TestScrobbleConfig_ListenTo(t *testing.T) {
+	var (
+		playbackStarted = mustParseDate("2020-06-05T20:00:00")
+		reba            = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:10:00"), PerformanceTime: mustParseDate("1997-11-22T00:00:00"), Location: "Hampton, VA"}
+		at              = func(minutes, seconds int) time.Time {
+			return reba.StartTime.Add(time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second)
+		}
+	)
+	tt := []struct {
+		desc     string
+		cfg      scrobbleConfig
+		track    Track
+		end      time.Time
+		length   time.Duration
+		wantOK   bool
+		wantFrom time.Time
+	}{
+		{desc: "heard whole", track: reba, end: at(3, 0), length: 3 * time.Minute, wantOK: true, wantFrom: reba.StartTime},
+		{desc: "heard half", track: reba, end: at(1, 30), length: 3 * time.Minute, wantOK: true, wantFrom: reba.StartTime},
+		{desc: "heard under half", track: reba, end: at(1, 29), length: 3 * time.Minute},
+		{desc: "heard four minutes of a long track", track: reba, end: at(4, 0), length: 20 * time.Minute, wantOK: true, wantFrom: reba.StartTime},
+		{desc: "heard three minutes of a long track", track: reba, end: at(3, 0), length: 20 * time.Minute},
+		{desc: "too short a track", track: reba, end: at(0, 20), length: 20 * time.Second},
+		{desc: "unknown length", track: reba, end: at(3, 0)},
+		{desc: "unknown length heard four minutes", track: reba, end: at(4, 0), wantOK: true, wantFrom: reba.StartTime},
+		{
+			desc:     "configured rules",
+			cfg:      scrobbleConfig{MinPercent: 90, MinListen: 10 * time.Minute, MinLength: 10 * time.Second},
+			track:    reba,
+			end:      at(0, 18),
+			length:   20 * time.Second,
+			wantOK:   true,
+			wantFrom: reba.StartTime,
+		},
+		{
+			desc:     "airing when playback started",
+			track:    Track{Artist: "Phish", Title: "Tweezer", StartTime: mustParseDate("2020-06-05T19:55:00")},
+			end:      mustParseDate("2020-06-05T20:05:00"),
+			length:   10 * time.Minute,
+			wantOK:   true,
+			wantFrom: playbackStarted,
+		},
+		{
+			desc:   "joined near the end",
+			track:  Track{Artist: "Phish", Title: "Tweezer", StartTime: mustParseDate("2020-06-05T19:55:00")},
+			end:    mustParseDate("2020-06-05T20:01:00"),
+			length: 6 * time.Minute,
+		},
+		{desc: "station break", track: Track{Artist: "jempradio.com", Title: "JEMP Radio", StartTime: reba.StartTime}, end: at(5, 0), length: 5 * time.Minute},
+		{desc: "full show", track: Track{Artist: "Phish", Title: "28-May-1989 Hebron, NY Set 2", Set: &ShowSet{Number: 2}, StartTime: reba.StartTime}, end: at(60, 0), length: time.Hour},
+		{desc: "no track", end: at(5, 0), length: 5 * time.Minute},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := tc.cfg.listenTo(tc.track, playbackStarted, tc.end, tc.length)
+			if ok != tc.wantOK {
+				t.Fatalf("wanted ok=%t, but got %t", tc.wantOK, ok)
+			}
+			if ok && !got.ListenedAt.Equal(tc.wantFrom) {
+				t.Errorf("wanted listen from %v, but got %v", tc.wantFrom, got.ListenedAt)
+			}
+		})
+	}
+	if l, _ := (scrobbleConfig{}).listenTo(reba, playbackStarted, at(5, 0), 5*time.Minute); l.Album != "1997-11-22 Hampton, VA" {
+		t.Errorf("wanted the show as the album, but got %q", l.Album)
+	}
+}
+
+func TestScrobbleConfig_ShowListens(t *testing.T) {
+	var (
+		start = mustParseDate("2020-06-05T20:00:00")
+		at    = func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+		song  = func(title string, minutes int) relisten.Track {
+			return relisten.Track{Title: title, Duration: float64(minutes * 60)}
+		}
+		source = relisten.Source{Sets: []relisten.Set{
+			{Name: "Set 1", Tracks: []relisten.Track{song("Chalk Dust Torture", 5), song("Reba", 10)}},
+			{Name: "Set 2", Tracks: []relisten.Track{song("Tweezer", 6), song("Piper", 8)}},
+			{Name: "Encore", IsEncore: true, Tracks: []relisten.Track{song("Tweezer Reprise", 4)}},
+		}}
+	)
+	tt := []struct {
+		desc            string
+		set             string
+		playbackStarted time.Time
+		end             time.Time
+		want            []string
+	}{
+		{desc: "whole set", set: "Set 1", end: at(15), want: []string{"Chalk Dust Torture@0", "Reba@5"}},
+		{desc: "part of a set", set: "Set 1", end: at(8), want: []string{"Chalk Dust Torture@0"}},
+		{desc: "set and encore", set: "Set 2 + E", end: at(18), want: []string{"Tweezer@0", "Piper@6", "Tweezer Reprise@14"}},
+		{desc: "joined partway", set: "Set 2 + E", playbackStarted: at(3), end: at(16), want: []string{"Tweezer@3", "Piper@6", "Tweezer Reprise@14"}},
+		{desc: "joined too late", set: "Set 2", playbackStarted: at(4), end: at(14), want: []string{"Piper@6"}},
+		{desc: "encore", set: "Encore", end: at(4), want: []string{"Tweezer Reprise@0"}},
+		{desc: "unknown set", set: "Set 3", end: at(30)},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			set, _ := parseShowSet(tc.set)
+			show := Track{Artist: "Phish", Title: "Hampton, VA " + tc.set, Set: &set, StartTime: start}
+			var got []string
+			for _, l := range (scrobbleConfig{}).showListens(show, source, tc.playbackStarted, tc.end) {
+				got = append(got, fmt.Sprintf("%s@%d", l.Title, int(l.ListenedAt.Sub(start).Minutes())))
+			}
+			if strings.Join(got, ", ") != strings.Join(tc.want, ", ") {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+
+	// Without expand_shows, a full show broadcast is not scrobbled, and
+	// Relisten is not asked for its setlist.
+	show := Track{Artist: "Phish", Title: "Hampton, VA Set 1", Set: &ShowSet{Number: 1}, StartTime: start, PerformanceTime: start}
+	if ls, err := (scrobbleConfig{}).listens(context.Background(), nil, map[string]string{"Phish": "phish"}, show, start, at(15), 15*time.Minute); err != nil || len(ls) != 0 {
+		t.Errorf("wanted no listens, but got %v (%v)", ls, err)
+	}
+}
+
+// fakeScrobbler records the listens it accepts, failing while offline.
+type fakeScrobbler struct {
+	offline bool
+	batches [][]listen
+}
+
+func (s *fakeScrobbler) name() string { return "fake" }
+
+func (s *fakeScrobbler) scrobble(ls []listen) error {
+	if s.offline {
+		return errors.New("network is unreachable")
+	}
+	s.batches = append(s.batches, ls)
+	return nil
+}
+
+func TestScrobbleQueue(t *testing.T) {
+	var (
+		st  = newTestStore(t)
+		now = mustParseDate("2020-06-05T20:00:00")
+		fs  = &fakeScrobbler{offline: true}
+		q   = &scrobbleQueue{store: st, scrobblers: []scrobbler{fs}, now: func() time.Time { return now }}
+	)
+	listenAt := func(minutes int) listen {
+		return listen{Artist: "Phish", Title: "Reba", ListenedAt: now.Add(time.Duration(minutes) * time.Minute)}
+	}
+	for i := 0; i < 60; i++ {
+		if _, err := q.submit(listenAt(i - 60)); err == nil {
+			t.Fatalf("wanted an error while offline")
+		}
+	}
+	queued, err := st.scrobbleQueue()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queued) != 60 || queued[0].Attempts != 60 || queued[59].Attempts != 1 || queued[0].Error == "" {
+		t.Fatalf("wanted 60 queued listens with their attempts, but got %+v", queued)
+	}
+
+	// Back online, the queued listens are sent in order, in batches, before
+	// the new one.
+	fs.offline = false
+	sent, err := q.submit(listenAt(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sent != 61 || len(fs.batches) != 2 || len(fs.batches[0]) != scrobbleBatchSize {
+		t.Errorf("wanted 61 listens sent in batches of %d, but got %d in %d batches", scrobbleBatchSize, sent, len(fs.batches))
+	}
+	if last := fs.batches[len(fs.batches)-1]; !last[len(last)-1].ListenedAt.Equal(now) {
+		t.Errorf("wanted the new listen sent last, but got %v", last[len(last)-1])
+	}
+	if queued, _ := st.scrobbleQueue(); len(queued) != 0 {
+		t.Errorf("wanted an empty queue, but got %d listens", len(queued))
+	}
+
+	// Listens too old to be accepted are dropped.
+	fs.offline = true
+	q.submit(listenAt(0))
+	now = now.Add(maxScrobbleAge + time.Hour)
+	fs.offline, fs.batches = false, nil
+	if sent, err := q.retry(); err != nil || sent != 0 || len(fs.batches) != 0 {
+		t.Errorf("wanted an old listen dropped, but got %d sent (%v)", sent, err)
+	}
+}
+
+func TestLastFMSignature(t *testing.T) {
+	params := url.Values{"method": {"track.scrobble"}, "api_key": {"key"}, "sk": {"session"}, "format": {"json"}}
+	sum := md5.Sum([]byte("api_keykeymethodtrack.scrobblesksessionsecret"))
+	if got, want := lastFMSignature(params, "secret"), hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("wanted %s, but got %s", want, got)
+	}
+}
+
+func TestLastFMScrobbler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	var logins int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sig := r.PostForm.Get("api_sig")
+		if sig != lastFMSignature(r.PostForm, "secret") {
+			json.NewEncoder(w).Encode(lastFMError{Code: 13, Message: "Invalid method signature supplied"})
+			return
+		}
+		switch r.PostForm.Get("method") {
+		case "auth.getMobileSession":
+			logins++
+			json.NewEncoder(w).Encode(map[string]interface{}{"session": map[string]string{"key": "session"}})
+		case "track.scrobble":
+			if r.PostForm.Get("sk") != "session" || r.PostForm.Get("artist[0]") != "Phish" || r.PostForm.Get("timestamp[0]") != "1591387200" {
+				http.Error(w, "bad scrobble", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"scrobbles": {}}`))
+		}
+	}))
+	defer srv.Close()
+
+	cfg := lastFMConfig{APIKey: "key", APISecret: "secret", Username: "user", Password: "pass"}
+	l := listen{Artist: "Phish", Title: "Reba", ListenedAt: mustParseDate("2020-06-05T20:00:00").UTC()}
+	for i := 0; i < 2; i++ {
+		s := &lastFMScrobbler{cfg: cfg, client: srv.Client(), url: srv.URL}
+		if err := s.scrobble([]listen{l}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("wanted the session key reused, but logged in %d times", logins)
+	}
+}
+
+func TestListenBrainzScrobbler(t *testing.T) {
+	var got struct {
+		ListenType string `json:"listen_type"`
+		Payload    []struct {
+			ListenedAt    int64             `json:"listened_at"`
+			TrackMetadata map[string]string `json:"track_metadata"`
+		} `json:"payload"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/submit-listens" || r.Header.Get("Authorization") != "Token token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code": 401, "error": "Invalid authorization token."}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	l := listen{Artist: "Phish", Title: "Reba", Album: "1997-11-22 Hampton, VA", ListenedAt: time.Unix(1591387200, 0)}
+	s := listenBrainzScrobbler{token: "token", client: srv.Client(), url: srv.URL}
+	if err := s.scrobble([]listen{l}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ListenType != "single" || len(got.Payload) != 1 || got.Payload[0].ListenedAt != 1591387200 || got.Payload[0].TrackMetadata["release_name"] != l.Album {
+		t.Errorf("unexpected submission: %+v", got)
+	}
+	s.token = "wrong"
+	if err := s.scrobble([]listen{l}); err == nil || !strings.Contains(err.Error(), "Invalid authorization token") {
+		t.Errorf("wanted the server's error, but got %v", err)
+	}
+}
+
+func TestFileScrobbler(t *testing.T) {
+	path := filepath.Join(newTestStore(t).dir, "listens.jsonl")
+	s := fileScrobbler{path: path}
+	for _, title := range []string{"Reba", "Tweezer"} {
+		if err := s.scrobble([]listen{{Artist: "Phish", Title: title}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"title":"Tweezer"`) {
+		t.Errorf("unexpected listens: %s", b)
+	}
+}
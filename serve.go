@@ -0,0 +1,923 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	defaultServeListen       = "localhost:8080"
+	defaultServePollInterval = 30 * time.Second
+	defaultServeRateBurst    = 10
+	defaultServeHistorySize  = 500
+)
+
+// serveConfig holds settings for serve mode. Like all settings, these can
+// be given as environment variables (e.g., PH_SERVE_LISTEN), which is the
+// usual way to configure ph when running it in a container.
+type serveConfig struct {
+	Listen       string        `yaml:"listen,omitempty" doc:"Address for the HTTP server to listen on (default localhost:8080)"`
+	PollInterval time.Duration `yaml:"poll_interval,omitempty" doc:"How often to check the station for a new track (default 30s)"`
+	LogFormat    string        `yaml:"log_format,omitempty" doc:"Log format: text or json"`
+	Token        string        `yaml:"token,omitempty" doc:"Require this bearer token (Authorization: Bearer <token>) for the API, except /healthz and /readyz"`
+	Username     string        `yaml:"username,omitempty" doc:"Require HTTP basic auth with this username and serve.password for the API, except /healthz and /readyz"`
+	Password     string        `yaml:"password,omitempty" doc:"Password for HTTP basic auth"`
+	CORSOrigins  []string      `yaml:"cors_origins,omitempty" doc:"Web page origins allowed to call the API, e.g. [https://example.com], or [\"*\"] for any"`
+	RateLimit    int           `yaml:"rate_limit,omitempty" doc:"Requests a minute to allow from each client address, beyond which requests get 429 Too Many Requests (default: no limit)"`
+	RateBurst    int           `yaml:"rate_burst,omitempty" doc:"Requests a client may make at once before rate_limit applies (default 10)"`
+	TrustProxy   bool          `yaml:"trust_proxy,omitempty" doc:"Take client addresses from X-Forwarded-For, when ph serve is behind a reverse proxy that sets it"`
+	HistorySize  int           `yaml:"history_size,omitempty" doc:"How many recent plays to keep in memory; older plays are read from the play history when needed (default 500)"`
+
+	Profiles      []string `yaml:"profiles,omitempty" doc:"People sharing the server, each with their own favorites and listening queue, as name: favorite artists, e.g. [\"alice: Phish, Goose\", \"bob: Grateful Dead\"]; a request chooses one with ?profile=name or its token"`
+	ProfileTokens []string `yaml:"profile_tokens,omitempty" doc:"Bearer tokens that choose a profile, as name: token, e.g. [\"alice: s3cret\"]; each is accepted for the API as serve.token is"`
+
+	TLSCert        string   `yaml:"tls_cert,omitempty" doc:"PEM certificate file, with any intermediates, to serve HTTPS with, along with tls_key"`
+	TLSKey         string   `yaml:"tls_key,omitempty" doc:"PEM private key file for tls_cert"`
+	ACMEDomains    []string `yaml:"acme_domains,omitempty" doc:"Domains to get a certificate for from Let's Encrypt and serve HTTPS with, e.g. [ph.example.com]; port 80 must be reachable for its challenges"`
+	ACMEEmail      string   `yaml:"acme_email,omitempty" doc:"Email address for Let's Encrypt to send notices about the certificate to"`
+	ACMEDirectory  string   `yaml:"acme_directory,omitempty" doc:"ACME directory URL of the certificate authority (default: Let's Encrypt)"`
+	ACMEHTTPListen string   `yaml:"acme_http_listen,omitempty" doc:"Address to answer ACME challenges and redirect to HTTPS on (default :80)"`
+}
+
+func (c serveConfig) listen() string {
+	if c.Listen != "" {
+		return c.Listen
+	}
+	return defaultServeListen
+}
+
+func (c serveConfig) historySize() int {
+	if c.HistorySize > 0 {
+		return c.HistorySize
+	}
+	return defaultServeHistorySize
+}
+
+// tlsEnabled reports whether serve mode serves HTTPS.
+func (c serveConfig) tlsEnabled() bool {
+	return c.TLSCert != "" || len(c.ACMEDomains) > 0
+}
+
+func (c serveConfig) acmeDirectory() string {
+	if c.ACMEDirectory != "" {
+		return c.ACMEDirectory
+	}
+	return acme.LetsEncryptURL
+}
+
+func (c serveConfig) acmeHTTPListen() string {
+	if c.ACMEHTTPListen != "" {
+		return c.ACMEHTTPListen
+	}
+	return ":80"
+}
+
+// tlsConfig returns the TLS config for serving HTTPS, or nil if the config
+// does not ask for it. With ACME domains, certificates are got and renewed
+// as they are needed by autocert, which keeps them in the acme directory of
+// the cache directory, and answers the CA's HTTP-01 challenges on
+// acme_http_listen, redirecting other requests there to HTTPS, until ctx is
+// canceled.
+func (c config) tlsConfig(ctx context.Context) (*tls.Config, error) {
+	if c.Serve.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.Serve.TLSCert, c.Serve.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("load serve.tls_cert: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+	if len(c.Serve.ACMEDomains) == 0 {
+		return nil, nil
+	}
+	m, err := c.acmeManager()
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", c.Serve.acmeHTTPListen())
+	if err != nil {
+		return nil, fmt.Errorf("listen for ACME challenges: %w", err)
+	}
+	challenges := &http.Server{Handler: m.HTTPHandler(nil)}
+	go challenges.Serve(ln)
+	go func() {
+		<-ctx.Done()
+		challenges.Close()
+	}()
+	return &tls.Config{GetCertificate: m.GetCertificate}, nil
+}
+
+// acmeManager returns the autocert manager of the certificates for the
+// ACME domains, which asks the CA for them through the network settings.
+func (c config) acmeManager() (*autocert.Manager, error) {
+	dir, err := c.cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(filepath.Join(dir, "acme")),
+		HostPolicy: autocert.HostWhitelist(c.Serve.ACMEDomains...),
+		Email:      c.Serve.ACMEEmail,
+		Client: &acme.Client{
+			DirectoryURL: c.Serve.acmeDirectory(),
+			HTTPClient:   httpClient(c),
+		},
+	}, nil
+}
+
+func (c serveConfig) pollInterval() time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return defaultServePollInterval
+}
+
+// server serves the station status, which it keeps up to date by polling
+// the station in the background.
+type server struct {
+	log *structuredLogger
+	now func() time.Time
+
+	// links resolves the links served with each track, if set.
+	links *LinkResolver
+
+	// cfg is the config the server started with, which gives the links
+	// served without resolving them.
+	cfg config
+
+	// store has the play history that /track serves plays from, if set,
+	// and recent the most recent of them, which are served without reading
+	// the store.
+	store  *store
+	recent *playRing
+
+	mu       sync.RWMutex
+	status   statusResponseBody
+	lastPoll time.Time
+	lastErr  error
+
+	// station is the ID of the station polled, and streamURL the stream
+	// configured for it, if any. They, pollInterval, profiles, and auth
+	// change when the config is reloaded.
+	station      string
+	streamURL    string
+	pollInterval time.Duration
+	profiles     []serveProfile
+	auth         apiAuth
+}
+
+// reconfigure changes the station the server reports on, how often it is
+// polled, the profiles it serves, and the credentials it requires.
+func (s *server) reconfigure(station, streamURL string, pollInterval time.Duration, profiles []serveProfile, auth apiAuth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.station, s.streamURL, s.pollInterval, s.profiles, s.auth = station, streamURL, pollInterval, profiles, auth
+}
+
+// currentAuth returns the credentials the server requires, for protect.
+func (s *server) currentAuth() apiAuth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.auth
+}
+
+// profile returns the profile r is made for, which has no name if r is for
+// none. If r asks for a profile it cannot have, profile writes the error
+// and reports false.
+func (s *server) profile(w http.ResponseWriter, r *http.Request) (serveProfile, bool) {
+	s.mu.RLock()
+	profiles, tokens := s.profiles, s.auth.profileTokens
+	s.mu.RUnlock()
+	p, _, err := profileFor(r, profiles, tokens)
+	switch {
+	case errors.Is(err, errUnknownProfile):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return p, false
+	case err != nil:
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return p, false
+	}
+	return p, true
+}
+
+// updateStatus records a newly fetched status.
+func (s *server) updateStatus(status statusResponseBody) {
+	s.mu.Lock()
+	previous := s.status.CurrentTrack
+	s.status = status
+	s.lastPoll = s.now()
+	s.lastErr = nil
+	s.mu.Unlock()
+
+	current := status.CurrentTrack
+	if !current.Key().Equal(previous.Key()) {
+		s.log.Info("track changed", "artist", current.Artist, "title", current.Title)
+	}
+}
+
+// pollFailed records a failure to fetch the station status.
+func (s *server) pollFailed(err error) {
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+	s.log.Warn("unable to get station status", "error", err)
+}
+
+// staleStatus records that the station is not being asked for its status,
+// without logging each poll skipped.
+func (s *server) staleStatus(err error) {
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+}
+
+// ready reports whether the server has a sufficiently recent status to
+// serve. A few missed polls are tolerated before the server is considered
+// unready.
+func (s *server) ready() (bool, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	switch {
+	case s.lastPoll.IsZero() && s.lastErr != nil:
+		return false, "station status unavailable: " + s.lastErr.Error()
+	case s.lastPoll.IsZero():
+		return false, "waiting for first station status"
+	case s.now().Sub(s.lastPoll) > 3*s.pollInterval:
+		msg := "station status is stale"
+		if s.lastErr != nil {
+			msg += ": " + s.lastErr.Error()
+		}
+		return false, msg
+	}
+	return true, "ok"
+}
+
+// run keeps the server's status up to date with the statuses published on
+// the bus, until the bus is closed.
+func (s *server) run(events <-chan interface{}) {
+	for e := range events {
+		switch e := e.(type) {
+		case statusFetched:
+			if e.Status.Stale != nil {
+				// The status is the one the server already has; the
+				// breaker has logged why the station is not being asked.
+				s.staleStatus(e.Status.Stale.Err)
+				continue
+			}
+			s.updateStatus(e.Status)
+		case statusFailed:
+			s.pollFailed(e.Err)
+		}
+	}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/now", s.handleNow)
+	mux.HandleFunc("/history", s.handleHistory)
+	mux.HandleFunc("/station", s.handleStation)
+	mux.HandleFunc("/track/", s.handleTrack)
+	mux.HandleFunc("/widget", s.handleWidget)
+	mux.HandleFunc("/queue", s.handleQueue)
+	mux.HandleFunc("/profiles", s.handleProfiles)
+	return mux
+}
+
+// handleHealthz reports that the server is alive. It succeeds as long as
+// the server can handle requests at all.
+func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether the server has a current station status to
+// serve.
+func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ready, msg := s.ready()
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]string{"status": msg})
+}
+
+func (s *server) handleNow(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.profile(w, r)
+	if !ok {
+		return
+	}
+	s.mu.RLock()
+	status, lastPoll := s.status, s.lastPoll
+	s.mu.RUnlock()
+	if lastPoll.IsZero() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "station status not yet available"})
+		return
+	}
+	writeJSON(w, http.StatusOK, markFavorites(s.withLinks(r.Context(), TrackList{status.CurrentTrack}), p)[0])
+}
+
+// handleHistory serves the recent tracks, filtered, sorted, and paged by
+// the query parameters, as parsed by parseHistoryQuery. The total number
+// of tracks that match is given in the X-Total-Count header, and the next
+// page, if there is one, in the Link header.
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	q, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	p, ok := s.profile(w, r)
+	if !ok {
+		return
+	}
+	s.mu.RLock()
+	status, lastPoll := s.status, s.lastPoll
+	s.mu.RUnlock()
+	if lastPoll.IsZero() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "station status not yet available"})
+		return
+	}
+	page, total := q.apply(status.History)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if next := q.offset + len(page); q.limit > 0 && next < total {
+		values := r.URL.Query()
+		values.Set("offset", strconv.Itoa(next))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, values.Encode()))
+	}
+	writeJSON(w, http.StatusOK, markFavorites(s.withLinks(r.Context(), page), p))
+}
+
+// historySorts are the orders /history can list tracks in.
+var historySorts = []string{"recent", "oldest", "performed", "artist"}
+
+// historyQuery selects the tracks /history serves.
+type historyQuery struct {
+	keep          func(Track) bool
+	sort          string
+	limit, offset int
+}
+
+// parseHistoryQuery parses the query parameters of /history, which mirror
+// the flags of ph: artist, kind, title, program, and venue filter the
+// tracks as they do there, and date selects those performed on a day
+// (YYYY-MM-DD). artist, kind, program, and venue can be given more than
+// once, or as comma-separated lists. sort is one of historySorts, most
+// recent first by default, and limit and offset page through the tracks.
+func parseHistoryQuery(values url.Values) (historyQuery, error) {
+	q := historyQuery{sort: "recent"}
+	for name := range values {
+		switch name {
+		case "artist", "kind", "title", "program", "venue", "date", "sort", "limit", "offset", "profile":
+		default:
+			return q, fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+	list := func(name string) []string {
+		var l []string
+		for _, v := range values[name] {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					l = append(l, item)
+				}
+			}
+		}
+		return l
+	}
+	keep, err := historyFilter(list("artist"), list("kind"), values.Get("title"), list("program"), list("venue"))
+	if err != nil {
+		return q, err
+	}
+	if d := values.Get("date"); d != "" {
+		date, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			return q, fmt.Errorf("invalid date %q: must be YYYY-MM-DD", d)
+		}
+		keep = All(keep, ByPerformanceDate(date))
+	}
+	q.keep = keep
+	if s := values.Get("sort"); s != "" {
+		if !containsString(historySorts, s) {
+			return q, fmt.Errorf("invalid sort %q: must be one of %s", s, strings.Join(historySorts, ", "))
+		}
+		q.sort = s
+	}
+	count := func(name string) (int, error) {
+		v := values.Get(name)
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid %s %q: must be a whole number", name, v)
+		}
+		return n, nil
+	}
+	if q.limit, err = count("limit"); err != nil {
+		return q, err
+	}
+	if q.offset, err = count("offset"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// apply returns the page of history, most recent first as radio.co lists
+// it, that the query selects, and how many tracks match in all.
+func (q historyQuery) apply(history TrackList) (TrackList, int) {
+	tracks := history.Filter(q.keep)
+	switch q.sort {
+	case "oldest":
+		for i, j := 0, len(tracks)-1; i < j; i, j = i+1, j-1 {
+			tracks[i], tracks[j] = tracks[j], tracks[i]
+		}
+	case "performed":
+		// Tracks not from a show, with no performance date, go last.
+		sort.SliceStable(tracks, func(i, j int) bool {
+			a, b := tracks[i].PerformanceTime, tracks[j].PerformanceTime
+			return !a.IsZero() && (b.IsZero() || a.Before(b))
+		})
+	case "artist":
+		sort.SliceStable(tracks, func(i, j int) bool {
+			return strings.ToLower(tracks[i].Artist) < strings.ToLower(tracks[j].Artist)
+		})
+	}
+	total := len(tracks)
+	if q.offset >= total {
+		return TrackList{}, total
+	}
+	tracks = tracks[q.offset:]
+	if q.limit > 0 {
+		tracks = tracks.Take(q.limit)
+	}
+	return tracks, total
+}
+
+func (s *server) handleStation(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	status, lastPoll, station, streamURL := s.status, s.lastPoll, s.station, s.streamURL
+	s.mu.RUnlock()
+	if lastPoll.IsZero() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "station status not yet available"})
+		return
+	}
+	writeJSON(w, http.StatusOK, newStationReport(station, streamURL, status))
+}
+
+// handleWidget serves what is playing in the stable widget format, for
+// home screen widgets.
+func (s *server) handleWidget(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	status, lastPoll, station, streamURL := s.status, s.lastPoll, s.station, s.streamURL
+	s.mu.RUnlock()
+	if lastPoll.IsZero() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "station status not yet available"})
+		return
+	}
+	links := status.CurrentTrack.Links(s.cfg, relistenArtists)
+	if s.links != nil {
+		links = s.withLinks(r.Context(), TrackList{status.CurrentTrack})[0].Links
+	}
+	listen := newStationReport(station, streamURL, status).StreamURL
+	writeJSON(w, http.StatusOK, newWidget(status, links, listen, s.now()))
+}
+
+// handleTrack serves a play from the play history by its ID, as
+// /track/{id}, with its links.
+func (s *server) handleTrack(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/track/")
+	p, allowed := s.profile(w, r)
+	if !allowed {
+		return
+	}
+	if s.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "play history not available"})
+		return
+	}
+	var (
+		t   Track
+		ok  bool
+		err error
+	)
+	if s.recent != nil {
+		t, ok = s.recent.find(id)
+	}
+	if !ok {
+		t, ok, err = s.store.play(id)
+	}
+	if err != nil {
+		s.log.Warn("unable to read play history", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "unable to read play history"})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no play with ID %q", id)})
+		return
+	}
+	writeJSON(w, http.StatusOK, markFavorites(s.withLinks(r.Context(), TrackList{t}), p)[0])
+}
+
+// queuedShow is a show in a listening queue, as /queue serves it.
+type queuedShow struct {
+	queueEntry
+	Links []Link `json:"links,omitempty"`
+}
+
+// handleQueue serves the shows waiting in the listening queue of the
+// request's profile, or without one, the queue of ph queue.
+func (s *server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.profile(w, r)
+	if !ok {
+		return
+	}
+	if s.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "listening queue not available"})
+		return
+	}
+	q, err := s.store.forProfile(p.Name).queue()
+	if err != nil {
+		s.log.Warn("unable to read listening queue", "profile", p.Name, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "unable to read listening queue"})
+		return
+	}
+	shows := []queuedShow{}
+	for _, e := range q {
+		if e.pending() {
+			shows = append(shows, queuedShow{queueEntry: e, Links: e.show().Links(s.cfg, relistenArtists)})
+		}
+	}
+	writeJSON(w, http.StatusOK, shows)
+}
+
+// handleProfiles serves the profiles, with their favorites.
+func (s *server) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	profiles := s.profiles
+	s.mu.RUnlock()
+	if profiles == nil {
+		profiles = []serveProfile{}
+	}
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+// linkedTrack is a track as served, with its links.
+type linkedTrack struct {
+	Track
+	Links []Link `json:"links,omitempty"`
+
+	// Favorite is whether the track is by one of the favorite artists of
+	// the profile it is served for.
+	Favorite bool `json:"favorite,omitempty"`
+}
+
+// markFavorites marks the tracks by p's favorite artists, if p is a
+// profile, and returns them.
+func markFavorites(tracks []linkedTrack, p serveProfile) []linkedTrack {
+	if p.Name == "" {
+		return tracks
+	}
+	favorite := ByArtist(p.Favorites...)
+	for i := range tracks {
+		tracks[i].Favorite = favorite(tracks[i].Track)
+	}
+	return tracks
+}
+
+// withLinks returns tracks with their links, if the server resolves them,
+// and their play IDs.
+func (s *server) withLinks(ctx context.Context, tracks TrackList) []linkedTrack {
+	var links map[TrackID][]Link
+	if s.links != nil {
+		var err error
+		if links, err = s.links.ResolveLinks(ctx, tracks); err != nil {
+			s.log.Warn("unable to resolve links", "error", err)
+		}
+	}
+	linked := make([]linkedTrack, len(tracks))
+	for i, t := range tracks {
+		if t.PlayID == "" {
+			t.PlayID = playID(t)
+		}
+		linked[i] = linkedTrack{Track: t, Links: links[t.ID()]}
+	}
+	return linked
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// runServe runs ph as a long-lived service, polling the station and serving
+// its status over HTTP.
+func runServe(args []string) error {
+	cfg, warnings, err := loadConfigWarnings()
+	if err != nil {
+		return err
+	}
+	var (
+		fs           = flag.NewFlagSet("serve", flag.ExitOnError)
+		listen       = fs.String("listen", cfg.Serve.listen(), "Address to listen on")
+		pollInterval = fs.Duration("poll-interval", cfg.Serve.pollInterval(), "How often to check the station")
+		logFormat    = fs.String("log-format", cfg.Serve.LogFormat, "Log format: text or json")
+		pprofAddr    = fs.String("pprof", "", "Address to serve net/http/pprof profiles on, such as localhost:6060")
+	)
+	fs.MarkHidden("pprof")
+	fs.Parse(args)
+
+	logger, err := newStructuredLogger(os.Stdout, *logFormat)
+	if err != nil {
+		return err
+	}
+	// Warnings go to the log, each only once, since the same tracks are in
+	// the station's history poll after poll.
+	warn = (&onceWarner{warn: logger.warning}).warning
+	for _, w := range warnings {
+		warn(w)
+	}
+	s := &server{
+		log:          logger,
+		pollInterval: *pollInterval,
+		now:          time.Now,
+		cfg:          cfg,
+		links:        &LinkResolver{Relisten: newRelistenClient(cfg), cfg: cfg},
+		station:      cfg.station(),
+		streamURL:    cfg.Play.StreamURL,
+	}
+	// Bad profiles are only warned about, as other bad settings are, and
+	// leave the server with none.
+	if s.profiles, err = cfg.Serve.profiles(); err != nil {
+		logger.Warn("profiles disabled", "error", err)
+	}
+	if s.auth, err = cfg.Serve.auth(); err != nil {
+		logger.Warn("profile tokens disabled", "error", err)
+	}
+	state := serveState{server: s}
+	// The alerters always run, so that notifications set up in the config
+	// while the server runs take effect; without any, they send nothing.
+	var (
+		showAlerts *showAlerter
+		alerts     = &alerter{cfg: cfg}
+	)
+	state.alerts = &alerts.seen
+
+	// The poller publishes each status to the bus, and the server, play
+	// history, and event log each consume them independently.
+	var (
+		b           = &bus{}
+		subscribers sync.WaitGroup
+		subscribe   = func(consume func(<-chan interface{})) {
+			events := b.subscribe(16)
+			subscribers.Add(1)
+			go func() {
+				defer subscribers.Done()
+				consume(events)
+			}()
+		}
+	)
+	subscribe(s.run)
+	if st, err := openStore(); err != nil {
+		logger.Warn("play history disabled", "error", err)
+	} else {
+		s.store, s.recent = st, newPlayRing(cfg.Serve.historySize())
+		if err := st.loadRecentPlays(s.recent); err != nil {
+			logger.Warn("unable to read play history", "error", err)
+		}
+		subscribe(func(events <-chan interface{}) {
+			st.recordPlays(s.recent, events, func(err error) {
+				logger.Warn("unable to record play", "error", err)
+			})
+		})
+		subscribe(func(events <-chan interface{}) {
+			(&eventRecorder{store: st}).run(events, func(err error) {
+				logger.Warn("unable to record events", "error", err)
+			})
+		})
+		showAlerts = &showAlerter{store: st, cfg: cfg}
+		state.showAlerts = &showAlerts.seen
+		subscribe(func(events <-chan interface{}) {
+			showAlerts.run(events, func(err error) {
+				logger.Warn("unable to send show alert", "error", err)
+			})
+		})
+	}
+	subscribe(func(events <-chan interface{}) {
+		alerts.run(events, func(err error) {
+			logger.Warn("unable to send alert", "error", err)
+		})
+	})
+
+	// Pick up where the last run left off before the first status is
+	// fetched, and save the state as it changes, and once more on the way
+	// out.
+	snapshotting := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	if s.store != nil {
+		if snap, ok, err := s.store.snapshot(); err != nil {
+			logger.Warn("unable to restore state", "error", err)
+		} else if ok {
+			state.restore(snap)
+			logger.Info("restored state", "saved", snap.Saved)
+		}
+		go func() {
+			state.saveSnapshots(ctx, s.store, snapshotInterval, time.Now, func(err error) {
+				logger.Warn("unable to save state", "error", err)
+			})
+			close(snapshotting)
+		}()
+	} else {
+		close(snapshotting)
+	}
+	// Notifications are set up from the config before the first status is
+	// fetched, and set up again each time the config is reloaded.
+	stopDigests := func() {}
+	notify := func(cfg config) {
+		if showAlerts != nil {
+			showAlerts.setNotifiers(cfg.Notify.notifiers(httpClient(cfg)))
+		}
+		email := cfg.Notify.Email
+		if email.Server != "" && len(email.Alerts) > 0 {
+			alerts.configure(email.Alerts, cfg.Favorites, cfg.Notify.quiet(newEmailNotifier(email)))
+		} else {
+			alerts.configure(nil, cfg.Favorites, nil)
+		}
+		stopDigests()
+		stopDigests = func() {}
+		if email.Server != "" && email.DailyDigest {
+			if st, err := openStore(); err != nil {
+				logger.Warn("daily digest disabled", "error", err)
+			} else {
+				digestCtx, cancelDigests := context.WithCancel(ctx)
+				stopDigests = cancelDigests
+				go sendDailyDigests(digestCtx, st, email, cfg.Notify.quiet(newEmailNotifier(email)), func(err error) {
+					logger.Warn("unable to send daily digest", "error", err)
+				})
+			}
+		}
+	}
+	notify(cfg)
+	p := &poller{
+		radioCo:  newRadioCoClient(httpClient(cfg), cfg),
+		station:  cfg.station(),
+		interval: *pollInterval,
+		bus:      b,
+	}
+	polling := make(chan struct{})
+	go func() {
+		p.run(ctx)
+		close(polling)
+	}()
+	// The station, and how often it is polled, are changed on reload too.
+	if path, err := configPath(); err == nil {
+		w := newConfigWatcher(path, cfg, logger, func(old, new config) {
+			notify(new)
+			interval := new.Serve.pollInterval()
+			if fs.Changed("poll-interval") {
+				interval = *pollInterval
+			}
+			if new.station() != old.station() || new.Serve.pollInterval() != old.Serve.pollInterval() {
+				p.update(new.station(), interval)
+			}
+			profiles, err := new.Serve.profiles()
+			if err != nil {
+				logger.Warn("profiles disabled", "error", err)
+			}
+			auth, err := new.Serve.auth()
+			if err != nil {
+				logger.Warn("profile tokens disabled", "error", err)
+			}
+			s.reconfigure(new.station(), new.Play.StreamURL, interval, profiles, auth)
+		})
+		go w.run(ctx)
+	}
+	defer func() {
+		// Stop polling before closing the bus, then let the subscribers
+		// finish with the events already published.
+		cancel()
+		<-polling
+		b.close()
+		subscribers.Wait()
+		<-snapshotting
+		if s.store != nil {
+			if err := s.store.saveSnapshot(state.snapshot(time.Now())); err != nil {
+				logger.Warn("unable to save state", "error", err)
+			}
+		}
+	}()
+
+	if *pprofAddr != "" {
+		addr, err := startPprof(ctx, *pprofAddr)
+		if err != nil {
+			return fmt.Errorf("serve profiles: %w", err)
+		}
+		logger.Info("serving profiles", "address", addr.String())
+	}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	// Requests are logged, then limited, and only then authenticated, so
+	// that guessing credentials is limited too.
+	handler := protect(s.handler(), s.currentAuth)
+	handler = cfg.Serve.limitRequests(handler, time.Now)
+	handler = cfg.Serve.logRequests(logger, handler)
+	srv := &http.Server{Handler: handler}
+	if srv.TLSConfig, err = cfg.tlsConfig(ctx); err != nil {
+		ln.Close()
+		return err
+	}
+	errs := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errs <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		errs <- srv.Serve(ln)
+	}()
+	logger.Info("serving", "address", ln.Addr().String(), "station", cfg.station(), "poll_interval", *pollInterval, "https", srv.TLSConfig != nil)
+	if ip := ln.Addr().(*net.TCPAddr).IP; !ip.IsLoopback() && !s.currentAuth().required() {
+		logger.Warn("serving beyond localhost without authentication; set serve.token or serve.username and serve.password")
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		logger.Info("shutting down", "signal", sig.String())
+	}
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// runHealthcheck checks that a ph server is healthy, for use as a
+// container health check where no HTTP client is available.
+func runHealthcheck(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs       = flag.NewFlagSet("healthcheck", flag.ExitOnError)
+		listen   = fs.String("listen", cfg.Serve.listen(), "Address the server listens on")
+		endpoint = fs.String("endpoint", "/healthz", "Endpoint to check")
+	)
+	fs.Parse(args)
+
+	host, port, err := net.SplitHostPort(*listen)
+	if err != nil {
+		return err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	var (
+		client = &http.Client{Timeout: 5 * time.Second}
+		scheme = "http"
+	)
+	if cfg.Serve.tlsEnabled() {
+		// The server's certificate is for its public name, not localhost,
+		// and this only checks that it is up.
+		scheme = "https"
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, port), *endpoint), nil)
+	if err != nil {
+		return err
+	}
+	cfg.Serve.setCredentials(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", *endpoint, resp.Status)
+	}
+	return nil
+}
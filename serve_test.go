@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+func newTestServer(now *time.Time) *server {
+	return &server{
+		log:          &structuredLogger{w: ioutil.Discard, now: time.Now},
+		pollInterval: 30 * time.Second,
+		now:          func() time.Time { return *now },
+	}
+}
+
+func TestServer_Readyz(t *testing.T) {
+	now := mustParseDate("2020-06-05T20:00:00")
+	s := newTestServer(&now)
+	h := s.handler()
+	get := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+
+	if code := get(); code != http.StatusServiceUnavailable {
+		t.Errorf("before first poll: wanted %d, but got %d", http.StatusServiceUnavailable, code)
+	}
+	s.updateStatus(statusResponseBody{CurrentTrack: Track{Artist: "Phish", Title: "Mercury"}})
+	if code := get(); code != http.StatusOK {
+		t.Errorf("after poll: wanted %d, but got %d", http.StatusOK, code)
+	}
+	now = now.Add(time.Minute)
+	s.pollFailed(errors.New("connection refused"))
+	if code := get(); code != http.StatusOK {
+		t.Errorf("after one failed poll: wanted %d, but got %d", http.StatusOK, code)
+	}
+	now = now.Add(5 * time.Minute)
+	if code := get(); code != http.StatusServiceUnavailable {
+		t.Errorf("with stale status: wanted %d, but got %d", http.StatusServiceUnavailable, code)
+	}
+}
+
+func TestServer_Now(t *testing.T) {
+	now := mustParseDate("2020-06-05T20:00:00")
+	s := newTestServer(&now)
+	h := s.handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/now", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before first poll: wanted %d, but got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	s.updateStatus(statusResponseBody{CurrentTrack: Track{Artist: "Phish", Title: "Mercury"}})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/now", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("wanted %d, but got %d", http.StatusOK, rec.Code)
+	}
+	var got Track
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unable to decode response: %v", err)
+	}
+	// Track decodes JSON as a raw radio.co title, so only the title is compared.
+	if got.Title != "Mercury" {
+		t.Errorf("unexpected track: %+v", got)
+	}
+}
+
+func TestServer_Widget(t *testing.T) {
+	now := mustParseDate("2020-06-05T20:00:00")
+	s := newTestServer(&now)
+	s.station = "sd71de59b3"
+	h := s.handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widget", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before first poll: wanted %d, but got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	s.updateStatus(statusResponseBody{Status: "online", CurrentTrack: Track{Artist: "Phish", Title: "Mercury"}})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widget", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("wanted %d, but got %d", http.StatusOK, rec.Code)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unable to decode response: %v", err)
+	}
+	if got["version"] != float64(widgetVersion) || got["line1"] != "Mercury" || got["line2"] != "Phish" {
+		t.Errorf("unexpected widget: %v", got)
+	}
+	if links, _ := got["links"].(map[string]interface{}); links["listen"] == "" || links["listen"] == nil {
+		t.Errorf("wanted a link to listen to the station, but got %v", got["links"])
+	}
+}
+
+func TestServer_Profiles(t *testing.T) {
+	now := mustParseDate("2020-06-05T20:00:00")
+	s := newTestServer(&now)
+	s.store = newTestStore(t)
+	s.profiles = []serveProfile{{Name: "alice", Favorites: []string{"Phish"}}, {Name: "bob", Favorites: []string{"Goose"}}}
+	s.auth.profileTokens = map[string]string{"alic3": "alice"}
+	h := s.handler()
+	s.updateStatus(statusResponseBody{
+		CurrentTrack: Track{Artist: "Phish", Title: "Reba"},
+		History:      TrackList{{Artist: "Phish", Title: "Reba"}, {Artist: "Goose", Title: "Arcadia"}},
+	})
+	e := queueEntry{Artist: "Goose", PerformanceTime: mustParseDate("2021-08-01")}
+	if _, err := s.store.forProfile("bob").queueAdd(e); err != nil {
+		t.Fatalf("unable to queue show: %v", err)
+	}
+
+	get := func(path, token string, v interface{}) int {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("unable to decode response: %v", err)
+		}
+		return rec.Code
+	}
+	favorites := func(path, token string) []bool {
+		t.Helper()
+		var tracks []map[string]interface{}
+		if code := get(path, token, &tracks); code != http.StatusOK {
+			t.Fatalf("%s: wanted %d, but got %d", path, http.StatusOK, code)
+		}
+		fs := make([]bool, len(tracks))
+		for i, t := range tracks {
+			fs[i], _ = t["favorite"].(bool)
+		}
+		return fs
+	}
+	if got, want := favorites("/history?profile=bob", ""), []bool{false, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bob: wanted favorites %v, but got %v", want, got)
+	}
+	if got, want := favorites("/history", "alic3"), []bool{true, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("alice: wanted favorites %v, but got %v", want, got)
+	}
+	if got, want := favorites("/history", ""), []bool{false, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("no profile: wanted favorites %v, but got %v", want, got)
+	}
+
+	var body map[string]interface{}
+	if code := get("/now?profile=carol", "", &body); code != http.StatusNotFound {
+		t.Errorf("unknown profile: wanted %d, but got %d", http.StatusNotFound, code)
+	}
+	if code := get("/now?profile=bob", "alic3", &body); code != http.StatusForbidden {
+		t.Errorf("another's profile: wanted %d, but got %d", http.StatusForbidden, code)
+	}
+
+	var queue []map[string]interface{}
+	if code := get("/queue?profile=bob", "", &queue); code != http.StatusOK || len(queue) != 1 || queue[0]["artist"] != "Goose" {
+		t.Errorf("bob's queue: wanted the Goose show, but got %d %v", code, queue)
+	}
+	if code := get("/queue", "alic3", &queue); code != http.StatusOK || len(queue) != 0 {
+		t.Errorf("alice's queue: wanted it empty, but got %d %v", code, queue)
+	}
+	var profiles []serveProfile
+	if code := get("/profiles", "", &profiles); code != http.StatusOK || !reflect.DeepEqual(profiles, s.profiles) {
+		t.Errorf("wanted profiles %v, but got %d %v", s.profiles, code, profiles)
+	}
+}
+
+func TestServer_History(t *testing.T) {
+	now := mustParseDate("2020-06-05T20:00:00")
+	s := newTestServer(&now)
+	s.updateStatus(statusResponseBody{History: TrackList{
+		{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")},
+		{Artist: "jempradio.com", Title: "Station ID"},
+		{Artist: "Goose", Title: "Arcadia"},
+		{Artist: "Phish", Title: "Mike's Song", PerformanceTime: mustParseDate("1995-12-31")},
+		{Artist: "Grateful Dead", Title: "Scarlet Begonias", PerformanceTime: mustParseDate("1977-05-08")},
+	}})
+	h := s.handler()
+
+	tt := []struct {
+		query     string
+		wantCode  int
+		want      []string
+		wantTotal string
+		wantNext  string
+	}{
+		{query: "", wantCode: http.StatusOK, want: []string{"Reba", "Arcadia", "Mike's Song", "Scarlet Begonias"}, wantTotal: "4"},
+		{query: "artist=phish", wantCode: http.StatusOK, want: []string{"Reba", "Mike's Song"}, wantTotal: "2"},
+		{query: "artist=Goose,Grateful+Dead", wantCode: http.StatusOK, want: []string{"Arcadia", "Scarlet Begonias"}, wantTotal: "2"},
+		{query: "artist=Goose&artist=Grateful+Dead", wantCode: http.StatusOK, want: []string{"Arcadia", "Scarlet Begonias"}, wantTotal: "2"},
+		{query: "kind=station_break", wantCode: http.StatusOK, want: []string{"Station ID"}, wantTotal: "1"},
+		{query: "title=^m", wantCode: http.StatusOK, want: []string{"Mike's Song"}, wantTotal: "1"},
+		{query: "date=1995-12-31", wantCode: http.StatusOK, want: []string{"Mike's Song"}, wantTotal: "1"},
+		{query: "sort=oldest", wantCode: http.StatusOK, want: []string{"Scarlet Begonias", "Mike's Song", "Arcadia", "Reba"}, wantTotal: "4"},
+		{query: "sort=performed", wantCode: http.StatusOK, want: []string{"Scarlet Begonias", "Mike's Song", "Reba", "Arcadia"}, wantTotal: "4"},
+		{query: "sort=artist", wantCode: http.StatusOK, want: []string{"Arcadia", "Scarlet Begonias", "Reba", "Mike's Song"}, wantTotal: "4"},
+		{
+			query:     "limit=2",
+			wantCode:  http.StatusOK,
+			want:      []string{"Reba", "Arcadia"},
+			wantTotal: "4",
+			wantNext:  `</history?limit=2&offset=2>; rel="next"`,
+		},
+		{query: "limit=2&offset=2", wantCode: http.StatusOK, want: []string{"Mike's Song", "Scarlet Begonias"}, wantTotal: "4"},
+		{query: "offset=10", wantCode: http.StatusOK, want: []string{}, wantTotal: "4"},
+		{query: "kind=song_and_dance", wantCode: http.StatusBadRequest},
+		{query: "title=(", wantCode: http.StatusBadRequest},
+		{query: "date=31-Dec-1995", wantCode: http.StatusBadRequest},
+		{query: "sort=loudest", wantCode: http.StatusBadRequest},
+		{query: "limit=-1", wantCode: http.StatusBadRequest},
+		{query: "offset=two", wantCode: http.StatusBadRequest},
+		{query: "page=2", wantCode: http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		t.Run(tc.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/history?"+tc.query, nil))
+			if rec.Code != tc.wantCode {
+				t.Fatalf("wanted %d, but got %d: %s", tc.wantCode, rec.Code, rec.Body)
+			}
+			if tc.wantCode != http.StatusOK {
+				var body map[string]string
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] == "" {
+					t.Errorf("wanted an error message, but got %s", rec.Body)
+				}
+				return
+			}
+			var tracks []struct {
+				Title string `json:"title"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &tracks); err != nil {
+				t.Fatalf("unable to decode response: %v", err)
+			}
+			got := make([]string, len(tracks))
+			for i, track := range tracks {
+				got[i] = track.Title
+			}
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+			if total := rec.Header().Get("X-Total-Count"); total != tc.wantTotal {
+				t.Errorf("wanted total %s, but got %s", tc.wantTotal, total)
+			}
+			if next := rec.Header().Get("Link"); next != tc.wantNext {
+				t.Errorf("wanted link %q, but got %q", tc.wantNext, next)
+			}
+		})
+	}
+}
+
+func TestServer_Track(t *testing.T) {
+	now := mustParseDate("2020-06-05T20:00:00")
+	s := newTestServer(&now)
+	s.store = newTestStore(t)
+	h := s.handler()
+
+	var (
+		mercury = Track{Artist: "Phish", Title: "Mercury", StartTime: mustParseDate("2020-06-05T19:50:00")}
+		// A play recorded before plays had IDs.
+		reba = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T19:30:00")}
+	)
+	if err := s.store.appendJSONLine(playsFile, playRecord(reba)); err != nil {
+		t.Fatalf("unable to record play: %v", err)
+	}
+	if _, err := s.store.recordPlay(mercury); err != nil {
+		t.Fatalf("unable to record play: %v", err)
+	}
+	s.updateStatus(statusResponseBody{CurrentTrack: mercury})
+
+	get := func(path string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unable to decode response: %v", err)
+		}
+		return rec.Code, body
+	}
+	// The current track links to its play.
+	_, current := get("/now")
+	id, _ := current["play_id"].(string)
+	if id == "" {
+		t.Fatalf("wanted a play ID for the current track, but got %v", current)
+	}
+	for _, tc := range []struct {
+		id    string
+		title string
+	}{
+		{id: id, title: "Mercury"},
+		{id: playID(reba), title: "Reba"},
+	} {
+		code, body := get("/track/" + tc.id)
+		if code != http.StatusOK {
+			t.Fatalf("%s: wanted %d, but got %d", tc.title, http.StatusOK, code)
+		}
+		if body["title"] != tc.title || body["play_id"] != tc.id {
+			t.Errorf("wanted %s with ID %s, but got %v", tc.title, tc.id, body)
+		}
+	}
+	if code, _ := get("/track/000000000000"); code != http.StatusNotFound {
+		t.Errorf("unknown play: wanted %d, but got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestStructuredLogger_JSON(t *testing.T) {
+	var b strings.Builder
+	l, err := newStructuredLogger(&b, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.now = func() time.Time { return mustParseDate("2020-06-05T20:00:00") }
+	l.Warn("unable to get station status", "error", errors.New("timeout"), "attempt", 2)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(b.String()), &entry); err != nil {
+		t.Fatalf("log entry is not JSON: %v: %q", err, b.String())
+	}
+	want := map[string]interface{}{
+		"time":    "2020-06-05T20:00:00Z",
+		"level":   "warn",
+		"msg":     "unable to get station status",
+		"error":   "timeout",
+		"attempt": float64(2),
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s: wanted %v, but got %v", k, v, entry[k])
+		}
+	}
+}
+
+func TestConfig_ACMEManager(t *testing.T) {
+	var cfg config
+	cfg.CacheDir = newTestStore(t).dir
+	cfg.Serve.ACMEDomains = []string{"ph.example.com"}
+	m, err := cfg.acmeManager()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := m.Client.DirectoryURL, acme.LetsEncryptURL; got != want {
+		t.Errorf("wanted directory %q, but got %q", want, got)
+	}
+	if err := m.HostPolicy(context.Background(), "ph.example.com"); err != nil {
+		t.Errorf("wanted ph.example.com allowed, but got %v", err)
+	}
+	if err := m.HostPolicy(context.Background(), "other.example.com"); err == nil {
+		t.Errorf("wanted other.example.com refused")
+	}
+
+	rec := httptest.NewRecorder()
+	m.HTTPHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://ph.example.com/now?x=1", nil))
+	if want := "https://ph.example.com/now?x=1"; rec.Header().Get("Location") != want {
+		t.Errorf("wanted a redirect to %s, but got %d %q", want, rec.Code, rec.Header().Get("Location"))
+	}
+}
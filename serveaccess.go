@@ -0,0 +1,172 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// isHealthCheck reports whether r is for one of the health endpoints,
+// which are checked too often to be worth logging or limiting.
+func isHealthCheck(r *http.Request) bool {
+	return r.URL.Path == "/healthz" || r.URL.Path == "/readyz"
+}
+
+// clientIP returns the address of the client that made r: the first
+// address in X-Forwarded-For if the config trusts a proxy to set it, and
+// otherwise the address the request came from.
+func (c serveConfig) clientIP(r *http.Request) string {
+	if c.TrustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			if ip := strings.TrimSpace(strings.Split(fwd, ",")[0]); net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitRequests wraps h to allow each client address serve.rate_limit
+// requests a minute, in bursts of up to serve.rate_burst, answering any
+// more with 429 Too Many Requests and how long to wait.
+func (c serveConfig) limitRequests(h http.Handler, now func() time.Time) http.Handler {
+	if c.RateLimit <= 0 {
+		return h
+	}
+	limiter := newRateLimiter(float64(c.RateLimit)/60, c.rateBurst(), now)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isHealthCheck(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if wait, ok := limiter.allow(c.clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "too many requests; try again later"})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (c serveConfig) rateBurst() int {
+	if c.RateBurst > 0 {
+		return c.RateBurst
+	}
+	return defaultServeRateBurst
+}
+
+// rateLimiter keeps a token bucket for each client: a client may make as
+// many requests at once as it has tokens, and gains rate tokens a second,
+// up to burst.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	pruned  time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int, now func() time.Time) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), now: now, buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token from client's bucket, reporting whether it had one,
+// and if not, how long until it will.
+func (l *rateLimiter) allow(client string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.prune(now)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// prune forgets the clients whose buckets have refilled, since they are
+// the same as new ones, at most once a minute, so that the buckets of
+// clients that come and go do not pile up.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+	l.pruned = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// statusRecorder records the status and size of a response, for the
+// access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// logRequests wraps h to log each request other than health checks, with
+// its client, response status and size, and how long it took.
+func (c serveConfig) logRequests(logger *structuredLogger, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isHealthCheck(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		var (
+			start = time.Now()
+			rec   = &statusRecorder{ResponseWriter: w}
+		)
+		h.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.RequestURI(),
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start),
+			"client", c.clientIP(r),
+			"user_agent", r.UserAgent())
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeConfig_LimitRequests(t *testing.T) {
+	var (
+		now = mustParseDate("2020-06-05T20:00:00")
+		cfg = serveConfig{RateLimit: 60, RateBurst: 2}
+		h   = cfg.limitRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), func() time.Time { return now })
+	)
+	get := func(path, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = client + ":51234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	steps := []struct {
+		desc      string
+		advance   time.Duration
+		path      string
+		client    string
+		wantCode  int
+		wantRetry string
+	}{
+		{desc: "first of burst", path: "/now", client: "192.0.2.1", wantCode: http.StatusOK},
+		{desc: "second of burst", path: "/now", client: "192.0.2.1", wantCode: http.StatusOK},
+		{desc: "over the limit", path: "/now", client: "192.0.2.1", wantCode: http.StatusTooManyRequests, wantRetry: "1"},
+		{desc: "another client", path: "/now", client: "192.0.2.2", wantCode: http.StatusOK},
+		{desc: "health check", path: "/healthz", client: "192.0.2.1", wantCode: http.StatusOK},
+		{desc: "still over", advance: 500 * time.Millisecond, path: "/history", client: "192.0.2.1", wantCode: http.StatusTooManyRequests, wantRetry: "1"},
+		{desc: "refilled", advance: 500 * time.Millisecond, path: "/history", client: "192.0.2.1", wantCode: http.StatusOK},
+		{desc: "over again", path: "/history", client: "192.0.2.1", wantCode: http.StatusTooManyRequests, wantRetry: "1"},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		rec := get(step.path, step.client)
+		if rec.Code != step.wantCode {
+			t.Errorf("%s: wanted %d, but got %d", step.desc, step.wantCode, rec.Code)
+		}
+		if retry := rec.Header().Get("Retry-After"); retry != step.wantRetry {
+			t.Errorf("%s: wanted Retry-After %q, but got %q", step.desc, step.wantRetry, retry)
+		}
+	}
+}
+
+func TestRateLimiter_Prune(t *testing.T) {
+	now := mustParseDate("2020-06-05T20:00:00")
+	// Buckets take 100s to refill.
+	l := newRateLimiter(0.1, 10, func() time.Time { return now })
+	l.allow("192.0.2.1")
+	now = now.Add(30 * time.Second)
+	l.allow("192.0.2.2")
+	now = now.Add(71 * time.Second)
+	l.allow("192.0.2.3")
+	if _, ok := l.buckets["192.0.2.1"]; ok {
+		t.Errorf("wanted the refilled bucket to be pruned")
+	}
+	if _, ok := l.buckets["192.0.2.2"]; !ok {
+		t.Errorf("wanted the partly empty bucket to be kept")
+	}
+}
+
+func TestServeConfig_ClientIP(t *testing.T) {
+	tt := []struct {
+		desc       string
+		trustProxy bool
+		forwarded  string
+		want       string
+	}{
+		{desc: "remote address", want: "192.0.2.1"},
+		{desc: "untrusted forwarded", forwarded: "198.51.100.7", want: "192.0.2.1"},
+		{desc: "trusted forwarded", trustProxy: true, forwarded: "198.51.100.7, 192.0.2.1", want: "198.51.100.7"},
+		{desc: "trusted but invalid", trustProxy: true, forwarded: "unknown", want: "192.0.2.1"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/now", nil)
+			req.RemoteAddr = "192.0.2.1:51234"
+			if tc.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			if got := (serveConfig{TrustProxy: tc.trustProxy}).clientIP(req); got != tc.want {
+				t.Errorf("wanted %s, but got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeConfig_LogRequests(t *testing.T) {
+	var b strings.Builder
+	logger, err := newStructuredLogger(&b, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := serveConfig{}.logRequests(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	for _, path := range []string{"/healthz", "/now?x=1", "/missing"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:51234"
+		req.Header.Set("User-Agent", "curl/8.0")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wanted 2 log entries, without the health check, but got %q", lines)
+	}
+	want := []map[string]interface{}{
+		{"msg": "request", "path": "/now?x=1", "status": float64(200), "bytes": float64(2), "client": "192.0.2.1", "user_agent": "curl/8.0"},
+		{"msg": "request", "path": "/missing", "status": float64(404)},
+	}
+	for i, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log entry is not JSON: %v: %q", err, line)
+		}
+		for k, v := range want[i] {
+			if entry[k] != v {
+				t.Errorf("entry %d: wanted %s=%v, but got %v", i, k, v, entry[k])
+			}
+		}
+	}
+}
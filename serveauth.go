@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// apiAuth is the authentication and CORS settings of the config, parsed
+// once so that each request is checked against them as they are.
+type apiAuth struct {
+	token, username, password string
+	corsOrigins               []string
+
+	// profileTokens maps the tokens that choose a profile to its name.
+	profileTokens map[string]string
+}
+
+// auth returns the authentication and CORS settings of the config. If
+// serve.profile_tokens has errors, the profile tokens are left out, and the
+// error returned with the rest.
+func (c serveConfig) auth() (apiAuth, error) {
+	a := apiAuth{
+		token:       c.Token,
+		username:    c.Username,
+		password:    c.Password,
+		corsOrigins: c.CORSOrigins,
+	}
+	tokens, err := c.profileTokens()
+	if err != nil {
+		return a, err
+	}
+	a.profileTokens = tokens
+	return a, nil
+}
+
+// protect wraps the API handler h with the authentication and CORS settings
+// auth returns for each request. The health endpoints are always open, so
+// that container health checks need no credentials, and so are CORS
+// preflight requests, which browsers send without them.
+func protect(h http.Handler, auth func() apiAuth) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := auth()
+		if origin := r.Header.Get("Origin"); origin != "" && len(a.corsOrigins) > 0 {
+			w.Header().Add("Vary", "Origin")
+			if containsString(a.corsOrigins, "*") {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else if containsString(a.corsOrigins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, Link")
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || a.authorized(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if a.token != "" || len(a.profileTokens) > 0 {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="ph"`)
+		}
+		if a.username != "" {
+			w.Header().Add("WWW-Authenticate", `Basic realm="ph", charset="UTF-8"`)
+		}
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid credentials"})
+	})
+}
+
+// required reports whether the API requires credentials.
+func (a apiAuth) required() bool {
+	return a.token != "" || a.username != "" || len(a.profileTokens) > 0
+}
+
+// authorized reports whether r has the credentials the API requires: the
+// bearer token, a profile's token, or the basic auth username and
+// password. If more than one is configured, any is accepted.
+func (a apiAuth) authorized(r *http.Request) bool {
+	if !a.required() {
+		return true
+	}
+	equal := func(x, y string) bool {
+		return subtle.ConstantTimeCompare([]byte(x), []byte(y)) == 1
+	}
+	if token, ok := bearerToken(r); ok && a.token != "" && equal(token, a.token) {
+		return true
+	}
+	if _, ok := tokenProfile(r, a.profileTokens); ok {
+		return true
+	}
+	if a.username != "" {
+		if user, pass, ok := r.BasicAuth(); ok && equal(user, a.username) && equal(pass, a.password) {
+			return true
+		}
+	}
+	return false
+}
+
+// setCredentials adds the credentials the config requires to r, for ph
+// healthcheck.
+func (c serveConfig) setCredentials(r *http.Request) {
+	switch {
+	case c.Token != "":
+		r.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "":
+		r.SetBasicAuth(c.Username, c.Password)
+	}
+}
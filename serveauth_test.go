@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeConfig_Protect(t *testing.T) {
+	var (
+		ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		both = serveConfig{
+			Token:       "s3cret",
+			Username:    "ph",
+			Password:    "hunter2",
+			CORSOrigins: []string{"https://example.com"},
+		}
+		profiles = serveConfig{
+			Profiles:      []string{"alice: Phish", "bob: Goose"},
+			ProfileTokens: []string{"alice: alic3"},
+		}
+	)
+	tt := []struct {
+		desc       string
+		cfg        serveConfig
+		method     string
+		path       string
+		header     map[string]string
+		basic      []string
+		wantCode   int
+		wantOrigin string
+	}{
+		{desc: "no auth configured", path: "/now", wantCode: http.StatusOK},
+		{desc: "no credentials", cfg: both, path: "/now", wantCode: http.StatusUnauthorized},
+		{desc: "bearer token", cfg: both, path: "/now", header: map[string]string{"Authorization": "Bearer s3cret"}, wantCode: http.StatusOK},
+		{desc: "bearer ignores scheme case", cfg: both, path: "/now", header: map[string]string{"Authorization": "bearer s3cret"}, wantCode: http.StatusOK},
+		{desc: "wrong token", cfg: both, path: "/now", header: map[string]string{"Authorization": "Bearer s3cre"}, wantCode: http.StatusUnauthorized},
+		{desc: "basic auth", cfg: both, path: "/history", basic: []string{"ph", "hunter2"}, wantCode: http.StatusOK},
+		{desc: "wrong password", cfg: both, path: "/history", basic: []string{"ph", "hunter3"}, wantCode: http.StatusUnauthorized},
+		{desc: "token only, basic given", cfg: serveConfig{Token: "s3cret"}, path: "/now", basic: []string{"ph", "s3cret"}, wantCode: http.StatusUnauthorized},
+		{desc: "profile token", cfg: profiles, path: "/now", header: map[string]string{"Authorization": "Bearer alic3"}, wantCode: http.StatusOK},
+		{desc: "profile tokens require one", cfg: profiles, path: "/now", wantCode: http.StatusUnauthorized},
+		{desc: "health without credentials", cfg: both, path: "/healthz", wantCode: http.StatusOK},
+		{desc: "readiness without credentials", cfg: both, path: "/readyz", wantCode: http.StatusOK},
+		{
+			desc:       "allowed origin",
+			cfg:        both,
+			path:       "/now",
+			header:     map[string]string{"Origin": "https://example.com", "Authorization": "Bearer s3cret"},
+			wantCode:   http.StatusOK,
+			wantOrigin: "https://example.com",
+		},
+		{
+			desc:     "other origin",
+			cfg:      both,
+			path:     "/now",
+			header:   map[string]string{"Origin": "https://example.org", "Authorization": "Bearer s3cret"},
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:       "any origin",
+			cfg:        serveConfig{CORSOrigins: []string{"*"}},
+			path:       "/now",
+			header:     map[string]string{"Origin": "https://example.org"},
+			wantCode:   http.StatusOK,
+			wantOrigin: "*",
+		},
+		{
+			desc:       "preflight without credentials",
+			cfg:        both,
+			method:     http.MethodOptions,
+			path:       "/now",
+			header:     map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "GET"},
+			wantCode:   http.StatusNoContent,
+			wantOrigin: "https://example.com",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tc.path, nil)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			if tc.basic != nil {
+				req.SetBasicAuth(tc.basic[0], tc.basic[1])
+			}
+			rec := httptest.NewRecorder()
+			auth, err := tc.cfg.auth()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			protect(ok, func() apiAuth { return auth }).ServeHTTP(rec, req)
+			if rec.Code != tc.wantCode {
+				t.Errorf("wanted %d, but got %d", tc.wantCode, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tc.wantOrigin {
+				t.Errorf("wanted allowed origin %q, but got %q", tc.wantOrigin, got)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("wanted a WWW-Authenticate challenge, but got none")
+			}
+		})
+	}
+}
+
+func TestServeConfig_SetCredentials(t *testing.T) {
+	for _, cfg := range []serveConfig{{Token: "s3cret"}, {Username: "ph", Password: "hunter2"}} {
+		req := httptest.NewRequest(http.MethodGet, "/now", nil)
+		cfg.setCredentials(req)
+		if auth, _ := cfg.auth(); !auth.authorized(req) {
+			t.Errorf("wanted request with credentials for %+v to be authorized", cfg)
+		}
+	}
+}
+
+func TestServer_ReconfigureAuth(t *testing.T) {
+	var (
+		now = mustParseDate("2020-06-05T20:00:00")
+		s   = newTestServer(&now)
+		h   = protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), s.currentAuth)
+	)
+	get := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/now", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	cfg := serveConfig{
+		Profiles:      []string{"alice: Phish"},
+		ProfileTokens: []string{"alice: alic3"},
+	}
+	auth, err := cfg.auth()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.reconfigure("", "", time.Minute, nil, auth)
+	if code := get("alic3"); code != http.StatusOK {
+		t.Errorf("wanted the profile token accepted, but got %d", code)
+	}
+
+	// A reloaded config takes the place of the credentials already in use.
+	cfg.ProfileTokens = []string{"alice: n3w"}
+	if auth, err = cfg.auth(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.reconfigure("", "", time.Minute, nil, auth)
+	if code := get("alic3"); code != http.StatusUnauthorized {
+		t.Errorf("wanted the old profile token refused, but got %d", code)
+	}
+	if code := get("n3w"); code != http.StatusOK {
+		t.Errorf("wanted the new profile token accepted, but got %d", code)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// airingShow is the show that the station is currently airing tracks from,
+// with the tracks aired from it so far.
+type airingShow struct {
+	Artist          string    `json:"artist"`
+	PerformanceTime time.Time `json:"performance_time"`
+	Location        string    `json:"location,omitempty"`
+	Tracks          TrackList `json:"tracks"`
+
+	// OnBreak is whether the station is airing a station break in the
+	// middle of the show.
+	OnBreak bool `json:"on_break,omitempty"`
+
+	// Setlist is the show's full setlist, as fetched from SetlistSource
+	// with --setlist.
+	Setlist       []string `json:"setlist,omitempty"`
+	SetlistSource string   `json:"setlist_source,omitempty"`
+}
+
+// currentShow groups the current track with the tracks aired immediately
+// before it from the same performance, as showAirings does. The history is
+// most recent first, as radio.co returns it, and may begin with the current
+// track. A station break is taken to interrupt the show aired before it,
+// which is still airing. It reports false if the current track is not from
+// a live performance, or a break in one.
+func currentShow(current Track, history TrackList) (airingShow, bool) {
+	if current.Artist == "" {
+		return airingShow{}, false
+	}
+	var (
+		tracks  = statusResponseBody{CurrentTrack: current, History: history}.tracks()
+		onBreak = current.IsStationBreak()
+	)
+	for len(tracks) > 0 && tracks[0].IsStationBreak() {
+		tracks = tracks[1:]
+	}
+	if len(tracks) == 0 || showKey(tracks[0]) == "" {
+		return airingShow{}, false
+	}
+	// Put the tracks in the order they aired.
+	aired := make(TrackList, len(tracks))
+	for i, t := range tracks {
+		aired[len(tracks)-1-i] = t
+	}
+	airings := showAirings(aired)
+	show := airings[len(airings)-1]
+	show.OnBreak = onBreak
+	return show, true
+}
+
+func (s airingShow) String() string {
+	return s.text(config{})
+}
+
+// text returns the show as text output shows it, with the links cfg gives
+// it.
+func (s airingShow) text(cfg config) string {
+	var b strings.Builder
+	b.WriteString(s.title())
+	show := Track{Artist: s.Artist, PerformanceTime: s.PerformanceTime}
+	for _, line := range linkLines(show.Links(cfg, relistenArtists)) {
+		b.WriteString("\n" + line)
+	}
+	b.WriteString("\n")
+	for i, t := range s.Tracks {
+		fmt.Fprintf(&b, "\n%2d. %s", i+1, t.Title)
+		if i == len(s.Tracks)-1 && !s.OnBreak {
+			b.WriteString("  (now playing)")
+		}
+	}
+	if s.OnBreak {
+		b.WriteString("\n    (station break)")
+	}
+	if len(s.Setlist) > 0 {
+		fmt.Fprintf(&b, "\n\n"+tr("Full setlist, from %s:"), s.SetlistSource)
+		for i, title := range s.Setlist {
+			fmt.Fprintf(&b, "\n%2d. %s", i+1, title)
+		}
+	}
+	return b.String()
+}
+
+// clearScreen moves the cursor home and clears the terminal, for redrawing
+// a live view.
+const clearScreen = "\033[H\033[2J"
+
+// runSet shows the show the station is airing and its setlist so far. With
+// --watch, the view is redrawn as new tracks air.
+func runSet(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs       = flag.NewFlagSet("set", flag.ExitOnError)
+		format   = fs.StringP("format", "f", cfg.format(), "Output format (text, json, yaml, or events with --watch)")
+		watch    = fs.BoolP("watch", "w", false, "Keep running, updating the setlist as new tracks air")
+		interval = fs.Duration("interval", 30*time.Second, "How often to check the station when watching")
+		full     = fs.Bool("setlist", false, "Fetch the show's full setlist from the artist's setlist provider")
+	)
+	fs.Parse(args)
+
+	// The events format is a stream of the changes at the station, so it
+	// only makes sense when watching, and has no renderer of its own.
+	asEvents := *format == "events"
+	if asEvents && !*watch {
+		return errors.New("--format events requires --watch")
+	}
+	writeOutput := func(interface{}) error { return nil }
+	if !asEvents {
+		writeOutput, err = getRenderer(*format, useColor(cfg, os.Stdout), cfg)
+		if err != nil {
+			return err
+		}
+	}
+	relistenArtists, err = relistenGetArtists(newRelistenClient(cfg), cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	var catalog songCatalog
+	if cfg.Catalog.Autocorrect {
+		catalog = loadSongCatalog(context.Background(), apiClient(cfg), cfg)
+	}
+	// setlists are the full setlists fetched, by show, so that watching
+	// fetches each once.
+	setlists := make(map[string]airingShow)
+	show := func(status statusResponseBody) error {
+		if catalog != nil {
+			status.CurrentTrack = catalog.correct(status.CurrentTrack)
+			status.History = catalog.correctAll(status.History)
+		}
+		s, ok := currentShow(status.CurrentTrack, status.History)
+		if !ok {
+			if *format != "text" {
+				return writeOutput(nil)
+			}
+			return writeOutput("Not airing a live show right now: " + trackSummary(status.CurrentTrack))
+		}
+		if *full {
+			key := s.Artist + " " + s.PerformanceTime.Format("2006-01-02")
+			fetched, ok := setlists[key]
+			if !ok {
+				songs, source, err := fetchSetlist(context.Background(), apiClient(cfg), cfg, status.CurrentTrack)
+				if err != nil {
+					warn(err)
+				}
+				if len(songs) > 0 {
+					fetched = airingShow{Setlist: songs, SetlistSource: source}
+				}
+				setlists[key] = fetched
+			}
+			s.Setlist, s.SetlistSource = fetched.Setlist, fetched.SetlistSource
+		}
+		return writeOutput(s)
+	}
+
+	if !*watch {
+		status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+		if err != nil {
+			return err
+		}
+		return show(status)
+	}
+	if *format != "text" && !asEvents {
+		return errors.New("--watch only supports text and events output")
+	}
+	var (
+		b      = &bus{}
+		events = b.subscribe(1)
+		ew     = &eventWriter{w: os.Stdout}
+	)
+	go func() {
+		for e := range events {
+			switch e := e.(type) {
+			case statusFetched:
+				if asEvents {
+					status := e.Status
+					if catalog != nil {
+						status.CurrentTrack = catalog.correct(status.CurrentTrack)
+					}
+					if err := ew.observe(status, e.Time); err != nil {
+						warn(err)
+					}
+					continue
+				}
+				fmt.Print(clearScreen)
+				if err := show(e.Status); err != nil {
+					warn(err)
+				}
+				if e.Status.Stale != nil {
+					fmt.Printf("\n%s\n", e.Status.Stale)
+				}
+			case statusFailed:
+				warn(fmt.Errorf("unable to get station status: %w", e.Err))
+			}
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &poller{
+		radioCo:  newRadioCoClient(httpClient(cfg), cfg),
+		station:  cfg.station(),
+		interval: *interval,
+		bus:      b,
+	}
+	go p.run(ctx)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCurrentShow(t *testing.T) {
+	var (
+		hampton   = mustParseDate("1997-11-22")
+		denver    = mustParseDate("1997-11-17")
+		current   = Track{Artist: "Phish", Title: "Reba", PerformanceTime: hampton, Location: "Hampton, VA"}
+		tweezer   = Track{Artist: "Phish", Title: "Tweezer", PerformanceTime: hampton}
+		mike      = Track{Artist: "Phish", Title: "Mike's Song", PerformanceTime: hampton}
+		stationID = Track{Artist: "jempradio.com", Title: "JEMP Radio"}
+		other     = Track{Artist: "Phish", Title: "Ghost", PerformanceTime: denver}
+	)
+	tt := []struct {
+		desc    string
+		current Track
+		history TrackList
+		want    []string
+		wantOK  bool
+		onBreak bool
+	}{
+		{
+			desc:    "running setlist",
+			current: current,
+			history: TrackList{current, tweezer, stationID, mike, other, tweezer},
+			want:    []string{"Mike's Song", "Tweezer", "Reba"},
+			wantOK:  true,
+		},
+		{
+			desc:    "first track of show",
+			current: current,
+			history: TrackList{current, other},
+			want:    []string{"Reba"},
+			wantOK:  true,
+		},
+		{
+			desc:    "station break in the show",
+			current: stationID,
+			history: TrackList{stationID, current, tweezer, other},
+			want:    []string{"Tweezer", "Reba"},
+			wantOK:  true,
+			onBreak: true,
+		},
+		{
+			desc:    "station break after a studio track",
+			current: stationID,
+			history: TrackList{stationID, {Artist: "Cream", Title: "Crossroads"}, tweezer},
+		},
+		{
+			desc:    "studio track",
+			current: Track{Artist: "Cream", Title: "Crossroads"},
+			history: TrackList{tweezer},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			show, ok := currentShow(tc.current, tc.history)
+			if ok != tc.wantOK {
+				t.Fatalf("wanted ok %v, but got %v", tc.wantOK, ok)
+			}
+			if show.OnBreak != tc.onBreak {
+				t.Errorf("wanted on break %v, but got %v", tc.onBreak, show.OnBreak)
+			}
+			var got []string
+			for _, track := range show.Tracks {
+				got = append(got, track.Title)
+			}
+			if strings.Join(got, ", ") != strings.Join(tc.want, ", ") {
+				t.Errorf("wanted setlist %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAiringShow_String(t *testing.T) {
+	relistenArtists = goldenRelistenArtists(t)
+	show := airingShow{
+		Artist:          "Phish",
+		PerformanceTime: mustParseDate("1997-11-22"),
+		Location:        "Hampton, VA",
+		Tracks:          TrackList{{Title: "Tweezer"}, {Title: "Reba"}},
+	}
+	want := "Phish - Sat 22-Nov-1997 Hampton, VA\n" +
+		"Stream: https://relisten.net/phish/1997/11/22\n" +
+		"Setlist: https://phish.net/setlists/?d=1997-11-22\n" +
+		"\n" +
+		" 1. Tweezer\n" +
+		" 2. Reba  (now playing)"
+	if got := show.String(); got != want {
+		t.Errorf("wanted:\n%s\nbut got:\n%s", want, got)
+	}
+}
+
+func TestAiringShow_String_Setlist(t *testing.T) {
+	relistenArtists = goldenRelistenArtists(t)
+	show := airingShow{
+		Artist:          "Phish",
+		PerformanceTime: mustParseDate("1997-11-22"),
+		Tracks:          TrackList{{Title: "Tweezer"}},
+		Setlist:         []string{"Tweezer", "Reba"},
+		SetlistSource:   "phish.net",
+	}
+	want := "Phish - Sat 22-Nov-1997\n" +
+		"Stream: https://relisten.net/phish/1997/11/22\n" +
+		"Setlist: https://phish.net/setlists/?d=1997-11-22\n" +
+		"\n" +
+		" 1. Tweezer  (now playing)\n" +
+		"\n" +
+		"Full setlist, from phish.net:\n" +
+		" 1. Tweezer\n" +
+		" 2. Reba"
+	if got := show.String(); got != want {
+		t.Errorf("wanted:\n%s\nbut got:\n%s", want, got)
+	}
+}
+
+func TestAiringShow_StringOnBreak(t *testing.T) {
+	relistenArtists = goldenRelistenArtists(t)
+	show := airingShow{
+		Artist:          "Phish",
+		PerformanceTime: mustParseDate("1997-11-22"),
+		Tracks:          TrackList{{Title: "Tweezer"}, {Title: "Reba"}},
+		OnBreak:         true,
+	}
+	want := " 1. Tweezer\n 2. Reba\n    (station break)"
+	if got := show.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("wanted a setlist ending:\n%s\nbut got:\n%s", want, got)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// pathPhishNetSetlist is the setlist of the Phish show on a date,
+	// relative to the phish.net endpoint, given the date and an API key.
+	pathPhishNetSetlist = "/v5/setlists/showdate/%s.json?apikey=%s"
+
+	// pathPhishNetSongSetlists is every performance of a song, relative to
+	// the phish.net endpoint, given the song's slug and an API key.
+	pathPhishNetSongSetlists = "/v5/setlists/slug/%s.json?apikey=%s"
+
+	// pathSetlistFMSearch finds the setlists of an artist's shows on a
+	// date, relative to the setlist.fm endpoint, given the artist and the
+	// date as DD-MM-YYYY.
+	pathSetlistFMSearch = "/search/setlists?artistName=%s&date=%s"
+)
+
+// setlistsConfig configures where setlists are linked to and fetched from.
+// Which provider an artist's setlists come from is set in artists.yaml.
+type setlistsConfig struct {
+	Default         string `yaml:"default,omitempty" doc:"Setlist provider for artists that artists.yaml gives none: phish.net, setlist.fm, jerrybase, or etree (default setlist.fm)"`
+	SetlistFMAPIKey string `yaml:"setlist_fm_api_key,omitempty" doc:"setlist.fm API key, used to fetch setlists from setlist.fm"`
+}
+
+// defaultSetlistProvider is the provider of the setlists of artists that
+// the artist sources give none, unless setlists.default is set.
+const defaultSetlistProvider = "setlist.fm"
+
+// defaultProvider returns the name of the provider of the setlists of
+// artists that the artist sources give none.
+func (c setlistsConfig) defaultProvider() string {
+	if c.Default == "" {
+		return defaultSetlistProvider
+	}
+	return c.Default
+}
+
+// errNoSetlistAPI is returned by providers whose setlists can be linked to,
+// but not fetched.
+var errNoSetlistAPI = errors.New("setlists can only be linked to, not fetched")
+
+// SetlistProvider links to, and where it can, fetches, the setlists of the
+// shows tracks are from.
+type SetlistProvider interface {
+	// Name is the name of the provider, as it is configured.
+	Name() string
+
+	// URL returns the URL of the setlist of the show t is from, which
+	// must be a live track with an artist.
+	URL(t Track) string
+
+	// Setlist fetches the titles of the songs of the show t is from, in
+	// the order they were played, using the API keys and endpoints in cfg.
+	Setlist(ctx context.Context, client *http.Client, cfg config, t Track) ([]string, error)
+}
+
+// setlistProviders are the setlist providers, by name.
+var setlistProviders = map[string]SetlistProvider{
+	"phish.net":  phishNetSetlists{},
+	"setlist.fm": setlistFMSetlists{},
+	"jerrybase":  jerryBaseSetlists{},
+	"etree":      etreeSetlists{},
+}
+
+// setlistProviderNames returns the names of the setlist providers, sorted.
+func setlistProviderNames() []string {
+	names := make([]string, 0, len(setlistProviders))
+	for name := range setlistProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setlistProvider returns the provider of the setlists of artist: the one
+// the config's artist sources give the artist, or else the default one. An
+// unknown provider, which loadConfig warns of, gives way to setlist.fm.
+func (c config) setlistProvider(artist string) SetlistProvider {
+	name := c.Setlists.defaultProvider()
+	if s, ok := c.lookupArtistSource(artist); ok && s.Setlist != "" {
+		name = s.Setlist
+	}
+	if p, ok := setlistProviders[name]; ok {
+		return p
+	}
+	return setlistFMSetlists{}
+}
+
+// setlistURL returns a URL pointing to the setlist of the show that this
+// track is from, from the artist's setlist provider, if the track is live.
+func (t Track) setlistURL(cfg config) string {
+	if t.Artist == "" || t.PerformanceTime.IsZero() {
+		return ""
+	}
+	return cfg.setlistProvider(t.Artist).URL(t)
+}
+
+// phishNetSetlists are the setlists of Phish shows on phish.net.
+type phishNetSetlists struct{}
+
+func (phishNetSetlists) Name() string { return "phish.net" }
+
+func (phishNetSetlists) URL(t Track) string {
+	return "https://phish.net/setlists/?d=" + t.PerformanceTime.Format("2006-01-02")
+}
+
+// Setlist asks phish.net for the setlist, through the phish.net circuit
+// breaker, with the API key used for the song catalog.
+func (phishNetSetlists) Setlist(ctx context.Context, client *http.Client, cfg config, t Track) ([]string, error) {
+	entries, err := fetchPhishNetSetlist(ctx, client, cfg, t.PerformanceTime)
+	if err != nil {
+		return nil, err
+	}
+	songs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		songs = append(songs, e.Song)
+	}
+	return songs, nil
+}
+
+// phishNetSetlistEntry is a performance of a song in a setlist on
+// phish.net.
+type phishNetSetlistEntry struct {
+	Song                string       `json:"song"`
+	ShowDate            string       `json:"showdate"`
+	ArtistName          string       `json:"artist_name"`
+	Venue               string       `json:"venue"`
+	City                string       `json:"city"`
+	State               string       `json:"state"`
+	Position            int          `json:"position"`
+	IsJamChart          phishNetFlag `json:"isjamchart"`
+	JamChartDescription string       `json:"jamchart_description"`
+}
+
+// phishNetFlag is a yes-or-no field of the phish.net API, which is sent
+// as 1 or 0, quoted or not.
+type phishNetFlag bool
+
+func (f *phishNetFlag) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	*f = phishNetFlag(s == "1" || s == "true")
+	return nil
+}
+
+// fetchPhishNetSetlist asks phish.net for the setlist of the Phish show on
+// date, through the phish.net circuit breaker, with the API key used for
+// the song catalog, and returns its entries in the order they were played.
+func fetchPhishNetSetlist(ctx context.Context, client *http.Client, cfg config, date time.Time) ([]phishNetSetlistEntry, error) {
+	entries, err := getPhishNetSetlists(ctx, client, cfg, pathPhishNetSetlist, date.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Position < entries[j].Position })
+	return entries, nil
+}
+
+// getPhishNetSetlists asks phish.net, through the phish.net circuit
+// breaker, for the setlist entries at path, which is given the value the
+// entries are selected by and the API key used for the song catalog.
+func getPhishNetSetlists(ctx context.Context, client *http.Client, cfg config, path, by string) ([]phishNetSetlistEntry, error) {
+	apiKey := cfg.Catalog.PhishNetAPIKey
+	if apiKey == "" {
+		return nil, errors.New("phish.net: no API key; set catalog.phishnet_api_key")
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeouts.phishNet())
+	defer cancel()
+	u := cfg.Endpoints.phishNet() + fmt.Sprintf(path, url.PathEscape(by), url.QueryEscape(apiKey))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := withBreaker(client, phishNetBreaker).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("phish.net: %s", resp.Status)
+	}
+	var body struct {
+		Error        bool                   `json:"error"`
+		ErrorMessage string                 `json:"error_message"`
+		Data         []phishNetSetlistEntry `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode phish.net setlist: %w", err)
+	}
+	if body.Error {
+		return nil, fmt.Errorf("phish.net: %s", body.ErrorMessage)
+	}
+	return body.Data, nil
+}
+
+// setlistFMSetlists are the setlists of any artist's shows on setlist.fm.
+type setlistFMSetlists struct{}
+
+func (setlistFMSetlists) Name() string { return "setlist.fm" }
+
+func (setlistFMSetlists) URL(t Track) string {
+	return "https://www.setlist.fm/search?query=" + url.QueryEscape(t.Artist+" "+t.PerformanceTime.Format("2006-01-02"))
+}
+
+// Setlist asks setlist.fm for the setlists of the artist's shows on the
+// date, through the setlist.fm circuit breaker, and returns the songs of
+// the first.
+func (setlistFMSetlists) Setlist(ctx context.Context, client *http.Client, cfg config, t Track) ([]string, error) {
+	apiKey := cfg.Setlists.SetlistFMAPIKey
+	if apiKey == "" {
+		return nil, errors.New("setlist.fm: no API key; set setlists.setlist_fm_api_key")
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeouts.setlistFM())
+	defer cancel()
+	u := cfg.Endpoints.setlistFM() + fmt.Sprintf(pathSetlistFMSearch, url.QueryEscape(t.Artist), t.PerformanceTime.Format("02-01-2006"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("Accept", "application/json")
+	resp, err := withBreaker(client, setlistFMBreaker).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("setlist.fm: %s", resp.Status)
+	}
+	var body struct {
+		Setlist []struct {
+			Sets struct {
+				Set []struct {
+					Song []struct {
+						Name string `json:"name"`
+					} `json:"song"`
+				} `json:"set"`
+			} `json:"sets"`
+		} `json:"setlist"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode setlist.fm setlists: %w", err)
+	}
+	if len(body.Setlist) == 0 {
+		return nil, nil
+	}
+	var songs []string
+	for _, set := range body.Setlist[0].Sets.Set {
+		for _, s := range set.Song {
+			songs = append(songs, s.Name)
+		}
+	}
+	return songs, nil
+}
+
+// jerryBaseSetlists are the setlists of Jerry Garcia's shows, with the
+// Grateful Dead and his own bands, on JerryBase.
+type jerryBaseSetlists struct{}
+
+func (jerryBaseSetlists) Name() string { return "jerrybase" }
+
+func (jerryBaseSetlists) URL(t Track) string {
+	return "https://jerrybase.com/search?q=" + t.PerformanceTime.Format("2006-01-02")
+}
+
+func (jerryBaseSetlists) Setlist(context.Context, *http.Client, config, Track) ([]string, error) {
+	return nil, errNoSetlistAPI
+}
+
+// etreeSetlists are the setlists of the shows in the etree database of
+// circulating recordings.
+type etreeSetlists struct{}
+
+func (etreeSetlists) Name() string { return "etree" }
+
+func (etreeSetlists) URL(t Track) string {
+	return "https://db.etree.org/shninfo_search.php?artist=" + url.QueryEscape(t.Artist) + "&date=" + t.PerformanceTime.Format("2006-01-02")
+}
+
+func (etreeSetlists) Setlist(context.Context, *http.Client, config, Track) ([]string, error) {
+	return nil, errNoSetlistAPI
+}
+
+// validateSetlistProvider checks that name is the name of a setlist
+// provider.
+func validateSetlistProvider(name string) error {
+	if _, ok := setlistProviders[name]; !ok {
+		return fmt.Errorf("unknown setlist provider %q; use one of %v", name, setlistProviderNames())
+	}
+	return nil
+}
+
+// fetchSetlist fetches the setlist of the show t is from, from the
+// artist's setlist provider, and returns it with the provider's name.
+func fetchSetlist(ctx context.Context, client *http.Client, cfg config, t Track) ([]string, string, error) {
+	p := cfg.setlistProvider(t.Artist)
+	songs, err := p.Setlist(ctx, client, cfg, t)
+	if err != nil {
+		return nil, p.Name(), fmt.Errorf("get setlist of %s from %s: %w", trackSummary(t), p.Name(), err)
+	}
+	return songs, p.Name(), nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrack_setlistURL(t *testing.T) {
+	tt := []struct {
+		desc  string
+		track Track
+		want  string
+	}{
+		{
+			desc:  "Phish",
+			track: Track{Artist: "Phish", PerformanceTime: mustParseDate("1997-11-22")},
+			want:  "https://phish.net/setlists/?d=1997-11-22",
+		},
+		{
+			desc:  "Grateful Dead",
+			track: Track{Artist: "Grateful Dead", PerformanceTime: mustParseDate("1977-05-08")},
+			want:  "https://jerrybase.com/search?q=1977-05-08",
+		},
+		{
+			desc:  "Jerry Garcia Band by another name",
+			track: Track{Artist: "JGB", PerformanceTime: mustParseDate("1990-11-15")},
+			want:  "https://jerrybase.com/search?q=1990-11-15",
+		},
+		{
+			desc:  "other artist",
+			track: Track{Artist: "Goose", PerformanceTime: mustParseDate("2021-10-01")},
+			want:  "https://www.setlist.fm/search?query=Goose+2021-10-01",
+		},
+		{
+			desc:  "studio track",
+			track: Track{Artist: "Phish", Title: "Free"},
+		},
+		{
+			desc:  "no artist",
+			track: Track{PerformanceTime: mustParseDate("1997-11-22")},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := tc.track.setlistURL(config{}); got != tc.want {
+				t.Errorf("wanted %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTrack_setlistURL_Default(t *testing.T) {
+	var cfg config
+	cfg.Setlists.Default = "etree"
+	track := Track{Artist: "Goose", PerformanceTime: mustParseDate("2021-10-01")}
+	want := "https://db.etree.org/shninfo_search.php?artist=Goose&date=2021-10-01"
+	if got := track.setlistURL(cfg); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
+
+func TestTrack_setlistURL_ArtistSources(t *testing.T) {
+	cfg := config{sources: mustParseArtistSources([]byte("- names: [Goose]\n  setlist: etree\n"))}
+	track := Track{Artist: "Goose", PerformanceTime: mustParseDate("2021-10-01")}
+	want := "https://db.etree.org/shninfo_search.php?artist=Goose&date=2021-10-01"
+	if got := track.setlistURL(cfg); got != want {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+}
+
+func TestValidateSetlistProvider(t *testing.T) {
+	for _, name := range setlistProviderNames() {
+		if err := validateSetlistProvider(name); err != nil {
+			t.Errorf("unexpected error for %s: %v", name, err)
+		}
+	}
+	if err := validateSetlistProvider("phish.in"); err == nil {
+		t.Errorf("wanted an error for an unknown provider, but got none")
+	}
+}
+
+func TestPhishNetSetlists_Setlist(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v5/setlists/showdate/1997-11-22.json" || r.URL.Query().Get("apikey") != "key" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"error": false, "data": [
+			{"song": "Reba", "position": 2},
+			{"song": "Tweezer", "position": 1},
+			{"song": "Slave to the Traffic Light", "position": 3}
+		]}`))
+	}))
+	defer srv.Close()
+
+	var cfg config
+	cfg.Endpoints.PhishNet = srv.URL
+	track := Track{Artist: "Phish", PerformanceTime: mustParseDate("1997-11-22")}
+	if _, err := (phishNetSetlists{}).Setlist(context.Background(), srv.Client(), cfg, track); err == nil {
+		t.Errorf("wanted an error with no API key, but got none")
+	}
+	cfg.Catalog.PhishNetAPIKey = "key"
+	got, err := (phishNetSetlists{}).Setlist(context.Background(), srv.Client(), cfg, track)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "Tweezer, Reba, Slave to the Traffic Light"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("wanted %s, but got %v", want, got)
+	}
+}
+
+func TestSetlistFMSetlists_Setlist(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/search/setlists" || r.Header.Get("x-api-key") != "key" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if q.Get("artistName") != "Goose" || q.Get("date") != "01-10-2021" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"setlist": [{"sets": {"set": [
+			{"song": [{"name": "Arcadia"}, {"name": "Hot Tea"}]},
+			{"song": [{"name": "Madhuvan"}]}
+		]}}]}`))
+	}))
+	defer srv.Close()
+
+	var cfg config
+	cfg.Endpoints.SetlistFM = srv.URL
+	cfg.Setlists.SetlistFMAPIKey = "key"
+	tt := []struct {
+		desc  string
+		track Track
+		want  string
+	}{
+		{
+			desc:  "show on setlist.fm",
+			track: Track{Artist: "Goose", PerformanceTime: mustParseDate("2021-10-01")},
+			want:  "Arcadia, Hot Tea, Madhuvan",
+		},
+		{
+			desc:  "show not on setlist.fm",
+			track: Track{Artist: "Goose", PerformanceTime: mustParseDate("2021-10-02")},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := (setlistFMSetlists{}).Setlist(context.Background(), srv.Client(), cfg, tc.track)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(got, ", ") != tc.want {
+				t.Errorf("wanted %s, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFetchSetlist_LinkOnly(t *testing.T) {
+	track := Track{Artist: "Grateful Dead", PerformanceTime: mustParseDate("1977-05-08")}
+	_, source, err := fetchSetlist(context.Background(), http.DefaultClient, config{}, track)
+	if !errors.Is(err, errNoSetlistAPI) {
+		t.Errorf("wanted %v, but got %v", errNoSetlistAPI, err)
+	}
+	if source != "jerrybase" {
+		t.Errorf("wanted source jerrybase, but got %s", source)
+	}
+}
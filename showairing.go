@@ -0,0 +1,96 @@
+package main
+
+import "time"
+
+// showAirings groups plays, oldest first, into the airings of the shows
+// they are from: runs of tracks from the same performance with nothing but
+// station breaks between them, so that a show the station breaks into,
+// such as between the sets of a full show broadcast, is aired once rather
+// than once for each part. Tracks not from a live performance are left
+// out, and end the airing before them.
+func showAirings(plays TrackList) []airingShow {
+	var (
+		airings []airingShow
+		airing  string
+	)
+	for _, t := range plays {
+		if t.IsStationBreak() {
+			continue
+		}
+		key := showKey(t)
+		switch {
+		case key == "":
+		case key == airing:
+			s := &airings[len(airings)-1]
+			s.Tracks = append(s.Tracks, t)
+			if s.Location == "" {
+				s.Location = t.Location
+			}
+		default:
+			airings = append(airings, airingShow{
+				Artist:          t.Artist,
+				PerformanceTime: t.PerformanceTime,
+				Location:        t.Location,
+				Tracks:          TrackList{t},
+			})
+		}
+		airing = key
+	}
+	return airings
+}
+
+// title names the show, as in "Phish - Sat 22-Nov-1997 Hampton, VA".
+func (s airingShow) title() string {
+	title := s.Artist + " - " + s.PerformanceTime.Format("Mon 2-Jan-2006")
+	if s.Location != "" {
+		title += " " + s.Location
+	}
+	return title
+}
+
+// fullShow reports whether the airing broadcast any full sets of the show.
+func (s airingShow) fullShow() bool {
+	for _, t := range s.Tracks {
+		if t.IsFullShow() {
+			return true
+		}
+	}
+	return false
+}
+
+// sets names the full sets the airing broadcast, in the order they aired.
+func (s airingShow) sets() []string {
+	var sets []string
+	for _, t := range s.Tracks {
+		if t.IsFullShow() {
+			sets = append(sets, t.Set.String())
+		}
+	}
+	return sets
+}
+
+// showAiringTracker follows the show the station is airing from status to
+// status, station breaks included, so that it knows when the airing
+// started even after its first tracks have left radio.co's short history.
+type showAiringTracker struct {
+	key     string
+	started time.Time
+}
+
+// observe notes the show status is airing, if any, and returns its key and
+// when the airing started, as first seen. A status seen at now with its
+// current track's start time unknown is taken to have started at now.
+func (a *showAiringTracker) observe(status statusResponseBody, now time.Time) (string, time.Time) {
+	show, ok := currentShow(status.CurrentTrack, status.History)
+	if !ok {
+		a.key, a.started = "", time.Time{}
+		return "", time.Time{}
+	}
+	if key := showKey(show.Tracks[0]); key != a.key {
+		a.key, a.started = key, show.Tracks[0].StartTime
+		if a.started.IsZero() {
+			a.started = now
+		}
+	}
+	return a.key, a.started
+}
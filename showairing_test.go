@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShowAirings(t *testing.T) {
+	var (
+		hampton   = mustParseDate("1997-11-22")
+		denver    = mustParseDate("1997-11-17")
+		stationID = Track{Artist: "jempradio.com", Title: "Station ID"}
+		plays     = TrackList{
+			{Artist: "Phish", Title: "Ghost", PerformanceTime: denver},
+			{Artist: "Phish", Title: "22-Nov-1997 Set 1", Set: &ShowSet{Number: 1}, PerformanceTime: hampton},
+			stationID,
+			{Artist: "Phish", Title: "22-Nov-1997 Set 2 + E", Set: &ShowSet{Number: 2, IncludesEncore: true}, PerformanceTime: hampton, Location: "Hampton, VA"},
+			{Artist: "Cream", Title: "Crossroads"},
+			stationID,
+			{Artist: "Phish", Title: "Reba", PerformanceTime: hampton},
+		}
+	)
+	type airing struct {
+		show   string
+		tracks int
+		sets   []string
+	}
+	var got []airing
+	for _, s := range showAirings(plays) {
+		got = append(got, airing{show: s.title(), tracks: len(s.Tracks), sets: s.sets()})
+	}
+	// The sets either side of the station break are one airing, but the
+	// show airing again after another track is another.
+	want := []airing{
+		{show: "Phish - Mon 17-Nov-1997", tracks: 1},
+		{show: "Phish - Sat 22-Nov-1997 Hampton, VA", tracks: 2, sets: []string{"Set 1", "Set 2 + Encore"}},
+		{show: "Phish - Sat 22-Nov-1997", tracks: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v, but got %v", want, got)
+	}
+}
+
+func TestShowAiringTracker(t *testing.T) {
+	var (
+		a         showAiringTracker
+		now       = mustParseDate("2024-06-01T21:00:00")
+		hampton   = mustParseDate("1997-11-22")
+		reba      = Track{Artist: "Phish", Title: "Reba", PerformanceTime: hampton, StartTime: now.Add(-10 * time.Minute)}
+		tweezer   = Track{Artist: "Phish", Title: "Tweezer", PerformanceTime: hampton, StartTime: now.Add(-30 * time.Minute)}
+		ghost     = Track{Artist: "Phish", Title: "Ghost", PerformanceTime: hampton, StartTime: now}
+		stationID = Track{Artist: "jempradio.com", Title: "Station ID", StartTime: now.Add(-5 * time.Minute)}
+	)
+	if key, started := a.observe(statusResponseBody{CurrentTrack: reba, History: TrackList{reba, tweezer}}, now); key == "" || !started.Equal(tweezer.StartTime) {
+		t.Fatalf("wanted the airing to start with Tweezer, but got %q at %v", key, started)
+	}
+	// Once Tweezer has left the history, the airing still started with it.
+	if _, started := a.observe(statusResponseBody{CurrentTrack: ghost, History: TrackList{ghost, stationID, reba}}, now); !started.Equal(tweezer.StartTime) {
+		t.Errorf("wanted the airing to have started with Tweezer, but got %v", started)
+	}
+	if key, _ := a.observe(statusResponseBody{CurrentTrack: Track{Artist: "Cream", Title: "Crossroads"}}, now); key != "" {
+		t.Errorf("wanted no show airing, but got %q", key)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ShowSet is the part of a show that a full set broadcast airs: a set, a
+// set followed by the encore, as broadcast in titles like "Set 2 + E", or
+// the encore alone. It is stored as its name, as String returns it.
+type ShowSet struct {
+	// Number is the number of the set, starting at 1, or zero if only the
+	// encore airs.
+	Number int
+
+	// IncludesEncore is whether the encore airs after the set.
+	IncludesEncore bool
+
+	// EncoreOnly is whether the encore airs alone.
+	EncoreOnly bool
+}
+
+// showSetName matches the name of a set as broadcast, or as String
+// returns it, capturing the set number and any encore after it.
+var showSetName = regexp.MustCompile(`(?i)^(?:Set (\d+)(\s?\+\s?E(?:ncore)?)?|(Encore|E))$`)
+
+// parseShowSet parses the name of a set, such as "Set 2", "Set 2 + E", or
+// "Encore", and reports whether it is one.
+func parseShowSet(name string) (ShowSet, bool) {
+	m := showSetName.FindStringSubmatch(name)
+	if m == nil {
+		return ShowSet{}, false
+	}
+	if m[3] != "" {
+		return ShowSet{EncoreOnly: true}, true
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n < 1 {
+		return ShowSet{}, false
+	}
+	return ShowSet{Number: n, IncludesEncore: m[2] != ""}, true
+}
+
+// String returns the name of the set, as in "Set 2", "Set 2 + Encore", or
+// "Encore", or the empty string if s is nil.
+func (s *ShowSet) String() string {
+	switch {
+	case s == nil:
+		return ""
+	case s.EncoreOnly:
+		return "Encore"
+	case s.IncludesEncore:
+		return fmt.Sprintf("Set %d + Encore", s.Number)
+	}
+	return fmt.Sprintf("Set %d", s.Number)
+}
+
+// HasEncore reports whether the encore airs, alone or after the set.
+func (s *ShowSet) HasEncore() bool {
+	return s != nil && (s.EncoreOnly || s.IncludesEncore)
+}
+
+// MarshalText implements encoding.TextMarshaler, so that a set is stored as
+// its name.
+func (s ShowSet) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, reading the name of a
+// set in any form parseShowSet accepts.
+func (s *ShowSet) UnmarshalText(b []byte) error {
+	set, ok := parseShowSet(string(b))
+	if !ok {
+		return fmt.Errorf("invalid set %q", b)
+	}
+	*s = set
+	return nil
+}
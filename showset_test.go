@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseShowSet(t *testing.T) {
+	tt := []struct {
+		name     string
+		want     ShowSet
+		wantOK   bool
+		wantName string
+	}{
+		{name: "Set 2", want: ShowSet{Number: 2}, wantOK: true, wantName: "Set 2"},
+		{name: "Set 1 +E", want: ShowSet{Number: 1, IncludesEncore: true}, wantOK: true, wantName: "Set 1 + Encore"},
+		{name: "Set 1+E", want: ShowSet{Number: 1, IncludesEncore: true}, wantOK: true, wantName: "Set 1 + Encore"},
+		{name: "Set 3 + Encore", want: ShowSet{Number: 3, IncludesEncore: true}, wantOK: true, wantName: "Set 3 + Encore"},
+		{name: "Encore", want: ShowSet{EncoreOnly: true}, wantOK: true, wantName: "Encore"},
+		{name: "Set 0"},
+		{name: "Set Two"},
+		{name: ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseShowSet(tc.name)
+			if ok != tc.wantOK || got != tc.want {
+				t.Fatalf("wanted %+v (%t), but got %+v (%t)", tc.want, tc.wantOK, got, ok)
+			}
+			if ok && got.String() != tc.wantName {
+				t.Errorf("wanted %q, but got %q", tc.wantName, got.String())
+			}
+		})
+	}
+}
+
+func TestShowSet_Encoding(t *testing.T) {
+	want := Track{Title: "22-Nov-1997 Set 2 + Encore", Set: &ShowSet{Number: 2, IncludesEncore: true}}
+	// set returns the set of t as encoded in JSON, if it has one.
+	set := func(t Track) (interface{}, bool) {
+		b, err := json.Marshal(t)
+		if err != nil {
+			return nil, false
+		}
+		var fields map[string]interface{}
+		json.Unmarshal(b, &fields)
+		s, ok := fields["set"]
+		return s, ok
+	}
+	if got, _ := set(want); got != "Set 2 + Encore" {
+		t.Errorf("wanted the set stored as its name, but got %v", got)
+	}
+	if got, ok := set(Track{Title: "Reba"}); ok {
+		t.Errorf("wanted no set for a song, but got %v", got)
+	}
+	// Plays recorded before sets were typed stored them as broadcast.
+	var r playRecord
+	if err := json.Unmarshal([]byte(`{"title":"22-Nov-1997 Set 2 +E","set":"Set 2 +E"}`), &r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Set == nil || *r.Set != *want.Set {
+		t.Errorf("wanted %+v, but got %+v", want.Set, r.Set)
+	}
+
+	b, err := yaml.Marshal(want)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got Track
+	if err := yaml.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Set == nil || *got.Set != *want.Set {
+		t.Errorf("wanted %+v after a round trip through YAML, but got %+v", want.Set, got.Set)
+	}
+}
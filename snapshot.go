@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotFile = "serve.json"
+
+	// snapshotInterval is how often ph serve saves a snapshot of its state,
+	// besides when it stops, so that little is lost if it crashes.
+	snapshotInterval = time.Minute
+)
+
+// lastSeen is the state of the station a subscriber last saw. It is safe
+// for concurrent use, so that it can be snapshotted while the subscriber
+// runs.
+type lastSeen struct {
+	mu     sync.Mutex
+	state  stationState
+	primed bool
+}
+
+// observe updates the state from status, and returns the changes it
+// implies. The first status seen, unless the state was restored from a
+// snapshot, only establishes what is airing, so it implies no changes.
+func (l *lastSeen) observe(status statusResponseBody, now time.Time) []event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changes := statusEvents(l.state, status, now)
+	for _, change := range changes {
+		l.state.apply(change)
+	}
+	if !l.primed {
+		l.primed = true
+		return nil
+	}
+	return changes
+}
+
+// snapshot returns the state for a snapshot, or nil if no status has been
+// seen yet.
+func (l *lastSeen) snapshot() *savedState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.primed {
+		return nil
+	}
+	return saveState(l.state)
+}
+
+// restore sets the state from a snapshot, if it has one.
+func (l *lastSeen) restore(s *savedState) {
+	if s == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.state = s.stationState()
+	l.primed = true
+}
+
+// savedState is the stored form of a stationState, since a Track decodes
+// radio.co's JSON rather than its own.
+type savedState struct {
+	Offline bool        `json:"offline,omitempty"`
+	Track   *playRecord `json:"track,omitempty"`
+	Seen    *time.Time  `json:"seen,omitempty"`
+}
+
+func saveState(s stationState) *savedState {
+	saved := &savedState{Offline: s.Offline}
+	if s.Track != nil {
+		t := playRecord(*s.Track)
+		saved.Track = &t
+	}
+	if !s.Seen.IsZero() {
+		seen := s.Seen
+		saved.Seen = &seen
+	}
+	return saved
+}
+
+func (s *savedState) stationState() stationState {
+	state := stationState{Offline: s.Offline}
+	if s.Track != nil {
+		t := Track(*s.Track)
+		state.Track = &t
+	}
+	if s.Seen != nil {
+		state.Seen = *s.Seen
+	}
+	return state
+}
+
+// serveSnapshot is the state ph serve keeps in memory, saved so that a
+// restart picks up where the last run left off: the track the server last
+// saw, and what the alerters last saw, so that they neither repeat alerts
+// nor miss those for tracks that started while ph serve was down.
+type serveSnapshot struct {
+	Saved      time.Time   `json:"saved"`
+	Server     *savedState `json:"server,omitempty"`
+	Alerts     *savedState `json:"alerts,omitempty"`
+	ShowAlerts *savedState `json:"show_alerts,omitempty"`
+}
+
+// serveState gathers the state of ph serve's components for snapshots.
+// Components that are not running are nil.
+type serveState struct {
+	server     *server
+	alerts     *lastSeen
+	showAlerts *lastSeen
+}
+
+func (s serveState) snapshot(now time.Time) serveSnapshot {
+	snap := serveSnapshot{Saved: now}
+	if s.server != nil {
+		s.server.mu.RLock()
+		if !s.server.lastPoll.IsZero() {
+			state := stationState{Offline: s.server.status.Status == "offline"}
+			if t := s.server.status.CurrentTrack; t.Title != "" {
+				state.Track = &t
+			}
+			snap.Server = saveState(state)
+		}
+		s.server.mu.RUnlock()
+	}
+	if s.alerts != nil {
+		snap.Alerts = s.alerts.snapshot()
+	}
+	if s.showAlerts != nil {
+		snap.ShowAlerts = s.showAlerts.snapshot()
+	}
+	return snap
+}
+
+// restore sets the state of each component from snap. The server only
+// takes the track it last saw, so that it does not log it as a change;
+// it still waits for a fresh status before serving one.
+func (s serveState) restore(snap serveSnapshot) {
+	if s.server != nil && snap.Server != nil && snap.Server.Track != nil {
+		s.server.mu.Lock()
+		s.server.status.CurrentTrack = Track(*snap.Server.Track)
+		s.server.mu.Unlock()
+	}
+	if s.alerts != nil {
+		s.alerts.restore(snap.Alerts)
+	}
+	if s.showAlerts != nil {
+		s.showAlerts.restore(snap.ShowAlerts)
+	}
+}
+
+// snapshot returns the last snapshot saved, and reports whether there is
+// one.
+func (s *store) snapshot() (serveSnapshot, bool, error) {
+	var snap serveSnapshot
+	if err := s.readJSON(snapshotFile, &snap); err != nil {
+		return serveSnapshot{}, false, err
+	}
+	return snap, !snap.Saved.IsZero(), nil
+}
+
+func (s *store) saveSnapshot(snap serveSnapshot) error {
+	return s.writeJSON(snapshotFile, snap)
+}
+
+// saveSnapshots saves a snapshot of the state every interval until ctx is
+// canceled. Errors are reported to onError. The final snapshot is left to
+// the caller, once the components have finished.
+func (s serveState) saveSnapshots(ctx context.Context, st *store, interval time.Duration, now func() time.Time, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := st.saveSnapshot(s.snapshot(now())); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastSeen(t *testing.T) {
+	var (
+		now     = mustParseDate("2020-06-05T20:30:00")
+		mercury = statusResponseBody{Status: "online", CurrentTrack: Track{Artist: "Phish", Title: "Mercury", StartTime: mustParseDate("2020-06-05T20:00:00")}}
+		reba    = statusResponseBody{Status: "online", CurrentTrack: Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:12:00")}}
+	)
+	tt := []struct {
+		desc     string
+		restored *savedState
+		statuses []statusResponseBody
+		want     []string
+	}{
+		{
+			desc:     "first status",
+			statuses: []statusResponseBody{mercury},
+		},
+		{
+			desc:     "track change",
+			statuses: []statusResponseBody{mercury, reba},
+			want:     []string{eventTrackEnded, eventTrackStarted},
+		},
+		{
+			desc:     "restored with the same track",
+			restored: saveState(stationState{Track: &mercury.CurrentTrack}),
+			statuses: []statusResponseBody{mercury},
+		},
+		{
+			desc:     "restored with an earlier track",
+			restored: saveState(stationState{Track: &mercury.CurrentTrack}),
+			statuses: []statusResponseBody{reba},
+			want:     []string{eventTrackEnded, eventTrackStarted},
+		},
+		{
+			desc:     "restored while offline",
+			restored: saveState(stationState{Offline: true}),
+			statuses: []statusResponseBody{reba},
+			want:     []string{eventStreamOnline, eventTrackStarted},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			var seen lastSeen
+			seen.restore(tc.restored)
+			var got []string
+			for _, status := range tc.statuses {
+				for _, e := range seen.observe(status, now) {
+					got = append(got, e.Type)
+				}
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("wanted events %v, but got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("wanted events %v, but got %v", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestServeState_Snapshot(t *testing.T) {
+	var (
+		st      = newTestStore(t)
+		now     = mustParseDate("2020-06-05T20:30:00")
+		mercury = statusResponseBody{Status: "online", CurrentTrack: Track{Artist: "Phish", Title: "Mercury", StartTime: mustParseDate("2020-06-05T20:00:00")}}
+	)
+	if _, ok, err := st.snapshot(); err != nil || ok {
+		t.Fatalf("wanted no snapshot yet, but got ok=%t (%v)", ok, err)
+	}
+
+	before := serveState{server: newTestServer(&now), alerts: &lastSeen{}, showAlerts: &lastSeen{}}
+	before.server.updateStatus(mercury)
+	before.alerts.observe(mercury, now)
+	if err := st.saveSnapshot(before.snapshot(now)); err != nil {
+		t.Fatalf("unexpected error saving snapshot: %v", err)
+	}
+
+	snap, ok, err := st.snapshot()
+	if err != nil || !ok {
+		t.Fatalf("wanted a snapshot, but got ok=%t (%v)", ok, err)
+	}
+	if !snap.Saved.Equal(now) {
+		t.Errorf("wanted snapshot saved at %v, but got %v", now, snap.Saved)
+	}
+	after := serveState{server: newTestServer(&now), alerts: &lastSeen{}, showAlerts: &lastSeen{}}
+	after.restore(snap)
+	if got := after.server.status.CurrentTrack; got.Title != "Mercury" || !got.StartTime.Equal(mercury.CurrentTrack.StartTime) {
+		t.Errorf("wanted the server to have Mercury, but got %+v", got)
+	}
+	if ready, _ := after.server.ready(); ready {
+		t.Errorf("wanted the server to wait for a fresh status")
+	}
+	if events := after.alerts.observe(mercury, now.Add(time.Minute)); len(events) != 0 {
+		t.Errorf("wanted no events for the track already seen, but got %v", events)
+	}
+	// The show alerter had seen nothing, so it is not primed by the
+	// snapshot.
+	if after.showAlerts.primed {
+		t.Errorf("wanted the show alerter to wait for its first status")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	flag "github.com/spf13/pflag"
+)
+
+// songYear counts the performances of a song in a year, and the airings of
+// the versions performed that year.
+type songYear struct {
+	Year      int `json:"year"`
+	Performed int `json:"performed"`
+	Aired     int `json:"aired"`
+}
+
+// songHistory is the history of a song: when the artist performed it, by
+// phish.net, and when the station aired it, by the play history.
+type songHistory struct {
+	Song   string `json:"song"`
+	Artist string `json:"artist"`
+
+	// Performances is how many times the artist performed the song, or
+	// zero if the performances are not known.
+	Performances   int       `json:"performances"`
+	FirstPerformed time.Time `json:"first_performed,omitempty"`
+	LastPerformed  time.Time `json:"last_performed,omitempty"`
+
+	// Airings is how many times the station aired the song, as recorded
+	// in the play history.
+	Airings   int       `json:"airings"`
+	LastAired time.Time `json:"last_aired,omitempty"`
+
+	// Years are the years from the first performance, or performance
+	// aired, to the last, oldest first. Airings of versions with no
+	// performance date are not counted in any year.
+	Years []songYear `json:"years"`
+}
+
+// playsSong reports whether t is artist playing song, alone or as one of
+// the songs of a title like "Mercury > Simple".
+func playsSong(t Track, artist, song string) bool {
+	if !strings.EqualFold(t.Artist, artist) || t.Kind() != trackKindSong {
+		return false
+	}
+	want := normalizeSongTitle(song)
+	for _, s := range strings.FieldsFunc(t.Title, func(r rune) bool { return r == '>' || r == '→' }) {
+		if normalizeSongTitle(s) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// buildSongHistory builds the history of song by artist from the dates it
+// was performed and the play history.
+func buildSongHistory(artist, song string, performed []time.Time, plays TrackList) songHistory {
+	h := songHistory{Song: song, Artist: artist, Performances: len(performed)}
+	byYear := make(map[int]*songYear)
+	year := func(y int) *songYear {
+		if byYear[y] == nil {
+			byYear[y] = &songYear{Year: y}
+		}
+		return byYear[y]
+	}
+	for _, d := range performed {
+		year(d.Year()).Performed++
+		if h.FirstPerformed.IsZero() || d.Before(h.FirstPerformed) {
+			h.FirstPerformed = d
+		}
+		if d.After(h.LastPerformed) {
+			h.LastPerformed = d
+		}
+	}
+	for _, t := range plays {
+		if !playsSong(t, artist, song) {
+			continue
+		}
+		h.Airings++
+		if t.StartTime.After(h.LastAired) {
+			h.LastAired = t.StartTime
+		}
+		if pt := t.PerformanceTime; !pt.IsZero() {
+			year(pt.Year()).Aired++
+		}
+	}
+	if len(byYear) == 0 {
+		return h
+	}
+	var first, last int
+	for y := range byYear {
+		if first == 0 || y < first {
+			first = y
+		}
+		if y > last {
+			last = y
+		}
+	}
+	for y := first; y <= last; y++ {
+		h.Years = append(h.Years, *year(y))
+	}
+	return h
+}
+
+// summary describes the song's history in a couple of lines, giving how
+// long ago it last aired as elapsed says.
+func (h songHistory) summary(elapsed elapsedFormat) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - %s\n", h.Artist, h.Song)
+	if h.Performances > 0 {
+		fmt.Fprintf(&b, tr("Performed %d times, from %s to %s")+"\n",
+			h.Performances, h.FirstPerformed.Format("2-Jan-2006"), h.LastPerformed.Format("2-Jan-2006"))
+	}
+	if h.Airings > 0 {
+		fmt.Fprintf(&b, tr("Aired %d times, last %s"), h.Airings, elapsed.started(appClock.Now().Sub(h.LastAired)))
+	} else {
+		b.WriteString(tr("Not aired yet"))
+	}
+	return b.String()
+}
+
+// timeline renders the song's history as a row for each year, with bars of
+// the performances that year and the airings of versions from that year,
+// each at most width characters long.
+func (h songHistory) timeline(width int) string {
+	var maxPerformed, maxAired int
+	for _, y := range h.Years {
+		if y.Performed > maxPerformed {
+			maxPerformed = y.Performed
+		}
+		if y.Aired > maxAired {
+			maxAired = y.Aired
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-4s  %-*s  %s\n", "YEAR", width+5, "PERFORMED", "AIRED")
+	for _, y := range h.Years {
+		// Bars are padded by hand, since their blocks are wider in bytes
+		// than on the terminal.
+		bar := textBar(y.Performed, maxPerformed, width)
+		pad := strings.Repeat(" ", width-utf8.RuneCountInString(bar))
+		line := fmt.Sprintf("%4d  %4d %s%s  %4d %s", y.Year, y.Performed, bar, pad, y.Aired, textBar(y.Aired, maxAired, width))
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// phishNetPerformances returns the dates song was performed by the artist
+// phish.net calls artist, through the phish.net circuit breaker.
+func phishNetPerformances(ctx context.Context, cfg config, artist, song string) ([]time.Time, error) {
+	entries, err := getPhishNetSetlists(ctx, apiClient(cfg), cfg, pathPhishNetSongSetlists, phishNetSongSlug(song))
+	if err != nil {
+		return nil, err
+	}
+	var dates []time.Time
+	for _, e := range entries {
+		if e.ArtistName != "" && !strings.EqualFold(e.ArtistName, artist) {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", e.ShowDate)
+		if err != nil {
+			continue
+		}
+		dates = append(dates, d)
+	}
+	return dates, nil
+}
+
+// runSong shows how often a song has been performed and aired, and with
+// --timeline, how often in each year.
+func runSong(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs       = flag.NewFlagSet("song", flag.ExitOnError)
+		artist   = fs.String("artist", "Phish", "Artist who plays the song")
+		timeline = fs.Bool("timeline", false, "Chart the performances and airings of the song by year")
+		width    = fs.Int("width", 30, "Width of the longest bar in the timeline")
+		asJSON   = fs.Bool("json", false, "Print the song's history as JSON")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ph song [flags] <title>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	song := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if song == "" {
+		return errors.New("usage: ph song [flags] <title>")
+	}
+
+	var performed []time.Time
+	if cfg.setlistProvider(*artist).Name() == "phish.net" {
+		performed, err = phishNetPerformances(context.Background(), cfg, *artist, song)
+		if err != nil {
+			warn(fmt.Errorf("unable to get performances from phish.net: %w", err))
+		}
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	plays, err := st.plays()
+	if err != nil {
+		return fmt.Errorf("read play history: %w", err)
+	}
+	h := buildSongHistory(*artist, song, performed, plays)
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(h)
+	}
+	fmt.Println(h.summary(cfg.elapsedFormat()))
+	if *timeline && len(h.Years) > 0 {
+		fmt.Printf("\n%s\n", h.timeline(*width))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlaysSong(t *testing.T) {
+	tt := []struct {
+		desc  string
+		track Track
+		want  bool
+	}{
+		{desc: "same title", track: Track{Artist: "Phish", Title: "Tweezer"}, want: true},
+		{desc: "case and punctuation", track: Track{Artist: "phish", Title: "tweezer!"}, want: true},
+		{desc: "in a segue", track: Track{Artist: "Phish", Title: "Mercury > Tweezer → Simple"}, want: true},
+		{desc: "reprise", track: Track{Artist: "Phish", Title: "Tweezer Reprise"}},
+		{desc: "other artist", track: Track{Artist: "Goose", Title: "Tweezer"}},
+		{desc: "full set", track: Track{Artist: "Phish", Title: "Tweezer", Set: &ShowSet{Number: 2}}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := playsSong(tc.track, "Phish", "Tweezer"); got != tc.want {
+				t.Errorf("wanted %v, but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBuildSongHistory(t *testing.T) {
+	var (
+		performed = []time.Time{
+			mustParseDate("1997-11-22"),
+			mustParseDate("1995-12-31"),
+			mustParseDate("1997-12-29"),
+		}
+		plays = TrackList{
+			{Artist: "Phish", Title: "Tweezer", PerformanceTime: mustParseDate("1997-11-22"), StartTime: mustParseDate("2020-06-05T20:00:00")},
+			{Artist: "Phish", Title: "Tweezer", PerformanceTime: mustParseDate("1999-07-04"), StartTime: mustParseDate("2020-06-06T20:00:00")},
+			{Artist: "Phish", Title: "Tweezer", StartTime: mustParseDate("2020-06-07T20:00:00")},
+			{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22"), StartTime: mustParseDate("2020-06-08T20:00:00")},
+		}
+	)
+	h := buildSongHistory("Phish", "Tweezer", performed, plays)
+	if h.Performances != 3 || h.Airings != 3 {
+		t.Errorf("wanted 3 performances and 3 airings, but got %d and %d", h.Performances, h.Airings)
+	}
+	if want := mustParseDate("1995-12-31"); !h.FirstPerformed.Equal(want) {
+		t.Errorf("wanted first performed %v, but got %v", want, h.FirstPerformed)
+	}
+	if want := mustParseDate("2020-06-07T20:00:00"); !h.LastAired.Equal(want) {
+		t.Errorf("wanted last aired %v, but got %v", want, h.LastAired)
+	}
+	// Years with neither performances nor airings are kept, so the
+	// timeline has no gaps.
+	want := []songYear{
+		{Year: 1995, Performed: 1},
+		{Year: 1996},
+		{Year: 1997, Performed: 2, Aired: 1},
+		{Year: 1998},
+		{Year: 1999, Aired: 1},
+	}
+	if !reflect.DeepEqual(h.Years, want) {
+		t.Errorf("wanted years %v, but got %v", want, h.Years)
+	}
+}
+
+func TestSongHistory_Timeline(t *testing.T) {
+	h := songHistory{Years: []songYear{
+		{Year: 1997, Performed: 4, Aired: 2},
+		{Year: 1998, Performed: 2},
+	}}
+	want := strings.Join([]string{
+		"YEAR  PERFORMED  AIRED",
+		"1997     4 ████     2 ████",
+		"1998     2 ██       0",
+	}, "\n")
+	if got := h.timeline(4); got != want {
+		t.Errorf("wanted\n%s\nbut got\n%s", want, got)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// stationInfo describes a radio.co station, as reported along with its
+// status. radio.co reports the name, genre, and listener count for only
+// some stations; those it leaves out are left empty.
+type stationInfo struct {
+	Name              string          `json:"name,omitempty"`
+	Genre             string          `json:"genre,omitempty"`
+	LogoURL           string          `json:"logo_url,omitempty" yaml:"logo_url,omitempty"`
+	StreamingHostname string          `json:"streaming_hostname,omitempty" yaml:"streaming_hostname,omitempty"`
+	Outputs           []stationOutput `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+	Listeners         *int            `json:"listeners,omitempty" yaml:"listeners,omitempty"`
+	Source            struct {
+		// Type is "automated" when the station plays from its playlists,
+		// or "live" when someone is broadcasting to it.
+		Type string `json:"type,omitempty"`
+	} `json:"source" yaml:"source"`
+}
+
+// stationOutput is one of the encodings a station streams in.
+type stationOutput struct {
+	Name    string `json:"name,omitempty"`
+	Format  string `json:"format,omitempty"`
+	Bitrate int    `json:"bitrate,omitempty"`
+}
+
+func (o stationOutput) String() string {
+	s := o.Format
+	if o.Bitrate > 0 {
+		s += fmt.Sprintf(" %d kbps", o.Bitrate)
+	}
+	return strings.TrimSpace(s)
+}
+
+// stationReport is what ph station info shows about a station.
+type stationReport struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name,omitempty"`
+	Genre     string          `json:"genre,omitempty"`
+	Status    string          `json:"status"`
+	Source    string          `json:"source,omitempty"`
+	Listeners *int            `json:"listeners,omitempty" yaml:"listeners,omitempty"`
+	StreamURL string          `json:"stream_url" yaml:"stream_url"`
+	Outputs   []stationOutput `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+	LogoURL   string          `json:"logo_url,omitempty" yaml:"logo_url,omitempty"`
+	Playing   *playRecord     `json:"current_track,omitempty" yaml:"current_track,omitempty"`
+}
+
+// newStationReport describes the station with ID station from its status.
+// streamURL is the stream ph plays, when one is configured.
+func newStationReport(station, streamURL string, status statusResponseBody) stationReport {
+	info := status.stationInfo
+	r := stationReport{
+		ID:        station,
+		Name:      info.Name,
+		Genre:     info.Genre,
+		Status:    status.Status,
+		Source:    info.Source.Type,
+		Listeners: info.Listeners,
+		StreamURL: streamURL,
+		Outputs:   info.Outputs,
+		LogoURL:   info.LogoURL,
+	}
+	if r.Name == "" && station == defaultStation {
+		r.Name = "JEMP Radio"
+	}
+	if r.StreamURL == "" {
+		host := info.StreamingHostname
+		if host == "" {
+			host = "streaming.radio.co"
+		}
+		r.StreamURL = fmt.Sprintf("https://%s/%s/listen", host, station)
+	}
+	if t := status.CurrentTrack; t.Title != "" && status.Status != "offline" {
+		pr := playRecord(t)
+		r.Playing = &pr
+	}
+	return r
+}
+
+func (r stationReport) String() string {
+	var b strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%-12s %s\n", label+":", value)
+		}
+	}
+	line(tr("Name"), r.Name)
+	line(tr("Station ID"), r.ID)
+	line(tr("Genre"), r.Genre)
+	status := r.Status
+	if r.Source != "" {
+		status += " (" + r.Source + ")"
+	}
+	line(tr("Status"), status)
+	if r.Listeners != nil {
+		line(tr("Listeners"), fmt.Sprint(*r.Listeners))
+	}
+	if r.Playing != nil {
+		line(tr("Playing"), trackSummary(Track(*r.Playing)))
+	}
+	line(tr("Stream"), r.StreamURL)
+	formats := make([]string, 0, len(r.Outputs))
+	for _, o := range r.Outputs {
+		if s := o.String(); s != "" {
+			formats = append(formats, s)
+		}
+	}
+	line(tr("Formats"), strings.Join(formats, ", "))
+	line(tr("Logo"), r.LogoURL)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func runStation(args []string) error {
+	const usage = "usage: ph station info"
+	if len(args) == 0 || args[0] != "info" {
+		return errors.New(usage)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("station info", flag.ExitOnError)
+		format = fs.StringP("format", "f", cfg.format(), "Output format (text, json, yaml, plain)")
+	)
+	fs.Parse(args[1:])
+	writeOutput, err := getRenderer(*format, useColor(cfg, os.Stdout), cfg)
+	if err != nil {
+		return err
+	}
+	status, err := getStatus(context.Background(), newRadioCoClient(httpClient(cfg), cfg), cfg.station())
+	if err != nil {
+		return err
+	}
+	return writeOutput(newStationReport(cfg.station(), cfg.Play.StreamURL, status))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testStationStatus = `{
+	"status": "online",
+	"source": {"type": "automated", "collaborator": null, "relay": null},
+	"current_track": {"title": "Phish - Reba (11-22-97 Hampton, VA)", "start_time": "2020-06-05T20:00:00+00:00"},
+	"history": [{"title": "Phish - Reba (11-22-97 Hampton, VA)"}],
+	"logo_url": "https://images.radio.co/station_logos/sd71de59b3.png",
+	"streaming_hostname": "stream.radio.co",
+	"outputs": [{"name": "listen", "format": "MP3", "bitrate": 192}, {"name": "aac", "format": "AAC", "bitrate": 64}]
+}`
+
+func TestNewStationReport(t *testing.T) {
+	var status statusResponseBody
+	if err := json.Unmarshal([]byte(testStationStatus), &status); err != nil {
+		t.Fatalf("unable to decode status: %v", err)
+	}
+	tt := []struct {
+		desc      string
+		station   string
+		streamURL string
+		status    statusResponseBody
+		want      string
+	}{
+		{
+			desc:    "JEMP Radio",
+			station: defaultStation,
+			status:  status,
+			want: strings.Join([]string{
+				"Name:        JEMP Radio",
+				"Station ID:  sd71de59b3",
+				"Status:      online (automated)",
+				"Playing:     Phish - Reba (Sat 22-Nov-1997)",
+				"Stream:      https://stream.radio.co/sd71de59b3/listen",
+				"Formats:     MP3 192 kbps, AAC 64 kbps",
+				"Logo:        https://images.radio.co/station_logos/sd71de59b3.png",
+			}, "\n"),
+		},
+		{
+			desc:      "offline, with a configured stream",
+			station:   "s0000000",
+			streamURL: "https://example.com/stream.mp3",
+			status:    statusResponseBody{Status: "offline", CurrentTrack: Track{Title: "Reba"}},
+			want: strings.Join([]string{
+				"Station ID:  s0000000",
+				"Status:      offline",
+				"Stream:      https://example.com/stream.mp3",
+			}, "\n"),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := newStationReport(tc.station, tc.streamURL, tc.status).String(); got != tc.want {
+				t.Errorf("wanted\n%s\nbut got\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServer_Station(t *testing.T) {
+	var status statusResponseBody
+	if err := json.Unmarshal([]byte(testStationStatus), &status); err != nil {
+		t.Fatalf("unable to decode status: %v", err)
+	}
+	now := time.Now()
+	s := newTestServer(&now)
+	s.station = defaultStation
+	s.updateStatus(status)
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/station", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("wanted %d, but got %d", http.StatusOK, rec.Code)
+	}
+	var got struct {
+		Name      string          `json:"name"`
+		Status    string          `json:"status"`
+		StreamURL string          `json:"stream_url"`
+		Outputs   []stationOutput `json:"outputs"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unable to decode response: %v", err)
+	}
+	if got.Name != "JEMP Radio" || got.Status != "online" || got.StreamURL != "https://stream.radio.co/sd71de59b3/listen" {
+		t.Errorf("unexpected station: %+v", got)
+	}
+	if want := status.Outputs; !reflect.DeepEqual(got.Outputs, want) {
+		t.Errorf("wanted outputs %v, but got %v", want, got.Outputs)
+	}
+}
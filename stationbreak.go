@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of station breaks.
+const (
+	breakKindStationID    = "station_id"
+	breakKindPromo        = "promo"
+	breakKindAnnouncement = "announcement"
+)
+
+// StationBreak is what a station break airs, as far as its title tells: a
+// station identification, a promo for one of the station's programs, or
+// some other announcement, whose title is its message.
+type StationBreak struct {
+	Kind    string `json:"kind"`
+	Program string `json:"program,omitempty" yaml:"program,omitempty"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// breakFormats are the titles of the station breaks that say more than
+// that they are a break, in the order they are tried. Named groups set the
+// fields of the StationBreak of the same name.
+var breakFormats = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{breakKindStationID, regexp.MustCompile(`(?i)^(?:www\.)?(?:station id|legal id|jemp radio|jempradio\.com)$`)},
+	{breakKindPromo, regexp.MustCompile(`(?i)^(?:promo|coming up|up next|next up|tune in)\s*[:-]\s*(?P<program>.+?)(?:\s+-\s+(?P<message>.+))?$`)},
+	{breakKindPromo, regexp.MustCompile(`(?i)^(?P<program>.+?)\s+promo$`)},
+}
+
+// parseStationBreak parses the title of a station break, as broadcast
+// after the station's name. A title in none of breakFormats is an
+// announcement, with the title as its message.
+func parseStationBreak(title string) StationBreak {
+	title = strings.TrimSpace(title)
+	for _, f := range breakFormats {
+		m := f.re.FindStringSubmatch(title)
+		if m == nil {
+			continue
+		}
+		b := StationBreak{Kind: f.kind}
+		for i, name := range f.re.SubexpNames() {
+			switch name {
+			case "program":
+				b.Program = strings.TrimSpace(m[i])
+			case "message":
+				b.Message = strings.TrimSpace(m[i])
+			}
+		}
+		return b
+	}
+	return StationBreak{Kind: breakKindAnnouncement, Message: title}
+}
+
+// String describes the break, or returns the empty string if b is nil.
+func (b *StationBreak) String() string {
+	switch {
+	case b == nil:
+		return ""
+	case b.Kind == breakKindStationID:
+		return "Station ID"
+	case b.Kind == breakKindPromo && b.Message != "":
+		return "Promo for " + b.Program + ": " + b.Message
+	case b.Kind == breakKindPromo:
+		return "Promo for " + b.Program
+	}
+	return b.Message
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStationBreak(t *testing.T) {
+	tt := []struct {
+		title string
+		want  StationBreak
+	}{
+		{"Station ID", StationBreak{Kind: breakKindStationID}},
+		{"JEMP Radio", StationBreak{Kind: breakKindStationID}},
+		{"Coming Up: Late Night Dead - Saturdays at 10pm", StationBreak{Kind: breakKindPromo, Program: "Late Night Dead", Message: "Saturdays at 10pm"}},
+		{"Promo - Morning Jams", StationBreak{Kind: breakKindPromo, Program: "Morning Jams"}},
+		{"Full Show Fridays Promo", StationBreak{Kind: breakKindPromo, Program: "Full Show Fridays"}},
+		{"Support listener-supported radio", StationBreak{Kind: breakKindAnnouncement, Message: "Support listener-supported radio"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.title, func(t *testing.T) {
+			if got := parseStationBreak(tc.title); got != tc.want {
+				t.Errorf("wanted %+v, but got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStationBreak_Track(t *testing.T) {
+	var track Track
+	if err := json.Unmarshal([]byte(`{"title":"jempradio.com - Up Next: Late Night Dead"}`), &track); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := StationBreak{Kind: breakKindPromo, Program: "Late Night Dead"}
+	if track.Break == nil || *track.Break != want {
+		t.Fatalf("wanted break %+v, but got %+v", want, track.Break)
+	}
+	if err := json.Unmarshal([]byte(`{"title":"Phish - Reba"}`), &track); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if track.Break != nil {
+		t.Errorf("wanted no break for a song, but got %+v", track.Break)
+	}
+
+	r := playRecord{Artist: "jempradio.com", Title: "Up Next: Late Night Dead", Break: &want}
+	e := event{Time: time.Now(), Type: eventBreakStarted, Track: &r}
+	if s := e.String(); !strings.Contains(s, "[Promo for Late Night Dead]") {
+		t.Errorf("wanted the event to say what the break promotes, but got %q", s)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// maxBreakLength is the longest a station break is assumed to last when
+// inferring its end from the next play. Longer gaps most likely mean plays
+// were not recorded, so the break is not measured.
+const maxBreakLength = 30 * time.Minute
+
+// playStats counts plays in the play history by when they aired, by
+// artist, by program, by venue, and by when the music was performed. Days are indexed by
+// time.Weekday, so Sunday is first.
+type playStats struct {
+	Plays   int            `json:"plays"`
+	Hours   [24]int        `json:"by_hour"`
+	Days    [7]int         `json:"by_weekday"`
+	Artists map[string]int `json:"by_artist"`
+
+	// Programs counts the plays that aired in each program, for plays
+	// whose program is known.
+	Programs map[string]int `json:"by_program,omitempty"`
+
+	// Venues counts the plays performed at each venue, for plays whose
+	// location names one.
+	Venues map[string]int `json:"by_venue,omitempty"`
+
+	// Dated is the number of plays with a performance date, which Decades
+	// breaks down by the decade of the performance.
+	Dated   int           `json:"dated"`
+	Decades []decadeShare `json:"by_decade"`
+
+	// Sets is the number of full set broadcasts, and Encores the number of
+	// them that aired the encore, alone or after a set. Shows is the number
+	// of shows they broadcast, counting the sets of a show aired together,
+	// station breaks and all, as one.
+	Sets    int `json:"full_sets"`
+	Encores int `json:"encores"`
+	Shows   int `json:"full_shows"`
+
+	// Breaks measures the station breaks on each day, oldest first.
+	Breaks []breakDay `json:"breaks_by_day"`
+}
+
+// breakDay measures the station breaks on a single day. Only breaks whose
+// length could be inferred from the following play are counted.
+type breakDay struct {
+	Date    string        `json:"date"`
+	Count   int           `json:"count"`
+	Total   time.Duration `json:"-"`
+	Seconds float64       `json:"total_seconds"`
+}
+
+// average returns the average length of a break on the day.
+func (d breakDay) average() time.Duration {
+	if d.Count == 0 {
+		return 0
+	}
+	return d.Total / time.Duration(d.Count)
+}
+
+// decadeShare is the portion of dated plays performed in a decade.
+type decadeShare struct {
+	Decade  int     `json:"decade"`
+	Plays   int     `json:"plays"`
+	Percent float64 `json:"percent"`
+}
+
+// buildPlayStats counts the plays that started at or after since, in the
+// given time zone. Station breaks are measured separately from plays.
+func buildPlayStats(plays TrackList, since time.Time, loc *time.Location) playStats {
+	var (
+		s       = playStats{Artists: make(map[string]int), Programs: make(map[string]int), Venues: make(map[string]int)}
+		decades = make(map[int]int)
+		breaks  = make(map[string]*breakDay)
+	)
+	for i, t := range plays {
+		if t.StartTime.Before(since) {
+			continue
+		}
+		st := t.StartTime.In(loc)
+		if t.IsStationBreak() {
+			if l := playDuration(plays, i, maxBreakLength, 0); l > 0 {
+				date := st.Format("2006-01-02")
+				if breaks[date] == nil {
+					breaks[date] = &breakDay{Date: date}
+				}
+				breaks[date].Count++
+				breaks[date].Total += l
+			}
+			continue
+		}
+		s.Plays++
+		s.Hours[st.Hour()]++
+		s.Days[st.Weekday()]++
+		if t.Artist != "" {
+			s.Artists[t.Artist]++
+		}
+		if t.Program != "" {
+			s.Programs[t.Program]++
+		}
+		if v := t.Place().Venue; v != "" {
+			s.Venues[v]++
+		}
+		if pt := t.PerformanceTime; !pt.IsZero() {
+			s.Dated++
+			decades[pt.Year()/10*10]++
+		}
+		if t.IsFullShow() {
+			s.Sets++
+			if t.Set.HasEncore() {
+				s.Encores++
+			}
+		}
+	}
+	for _, show := range showAirings(plays) {
+		if last := show.Tracks[len(show.Tracks)-1]; show.fullShow() && !last.StartTime.Before(since) {
+			s.Shows++
+		}
+	}
+	for decade, n := range decades {
+		s.Decades = append(s.Decades, decadeShare{
+			Decade:  decade,
+			Plays:   n,
+			Percent: float64(n) * 100 / float64(s.Dated),
+		})
+	}
+	sort.Slice(s.Decades, func(i, j int) bool { return s.Decades[i].Decade < s.Decades[j].Decade })
+	for _, d := range breaks {
+		d.Seconds = d.Total.Seconds()
+		s.Breaks = append(s.Breaks, *d)
+	}
+	sort.Slice(s.Breaks, func(i, j int) bool { return s.Breaks[i].Date < s.Breaks[j].Date })
+	return s
+}
+
+// text renders the stats with charts: a sparkline of plays by hour of day,
+// and bar charts of plays by weekday, of the top n artists and venues, of
+// the decades the music aired was performed in, and of station break time
+// over the last two weeks, along with how many full sets aired.
+func (s playStats) text(n, width int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, tr("%d plays")+"\n", s.Plays)
+
+	b.WriteString("\n" + tr("Plays by hour of day") + "\n")
+	fmt.Fprintf(&b, "%s\n", sparkline(s.Hours[:]))
+	b.WriteString("0     6     12    18   23\n")
+
+	b.WriteString("\n" + tr("Plays by day of week") + "\n")
+	days := make([]tally, 7)
+	for i := range days {
+		// Start the week on Monday.
+		d := time.Weekday((i + 1) % 7)
+		days[i] = tally{Name: d.String()[:3], Count: s.Days[d]}
+	}
+	writeBarChart(&b, days, width)
+
+	if top := topTallies(s.Artists, n); len(top) > 0 {
+		b.WriteString("\n" + tr("Top artists") + "\n")
+		writeBarChart(&b, top, width)
+	}
+
+	if top := topTallies(s.Programs, n); len(top) > 0 {
+		b.WriteString("\n" + tr("Plays by program") + "\n")
+		writeBarChart(&b, top, width)
+	}
+
+	if top := topTallies(s.Venues, n); len(top) > 0 {
+		b.WriteString("\n" + tr("Top venues") + "\n")
+		writeBarChart(&b, top, width)
+	}
+
+	if len(s.Decades) > 0 {
+		fmt.Fprintf(&b, "\n"+tr("Age of music aired (%d plays with a performance date)")+"\n", s.Dated)
+		rows := make([]tally, len(s.Decades))
+		for i, d := range s.Decades {
+			rows[i] = tally{Name: fmt.Sprintf("%ds %3.0f%%", d.Decade, d.Percent), Count: d.Plays}
+		}
+		writeBarChart(&b, rows, width)
+	}
+
+	if s.Sets > 0 {
+		fmt.Fprintf(&b, "\n"+tr("Full show broadcasts: %d, of %d sets, %d with the encore")+"\n", s.Shows, s.Sets, s.Encores)
+	}
+
+	if len(s.Breaks) > 0 {
+		var total breakDay
+		for _, d := range s.Breaks {
+			total.Count += d.Count
+			total.Total += d.Total
+		}
+		fmt.Fprintf(&b, "\n"+tr("Station breaks: %d, averaging %s each and %s a day")+"\n",
+			total.Count, total.average().Round(time.Second), (total.Total / time.Duration(len(s.Breaks))).Round(time.Second))
+		recent := s.Breaks
+		if len(recent) > 14 {
+			recent = recent[len(recent)-14:]
+		}
+		rows := make([]tally, len(recent))
+		for i, d := range recent {
+			rows[i] = tally{
+				Name:  fmt.Sprintf(tr("%s  %2d breaks, avg %s"), d.Date, d.Count, d.average().Round(time.Second)),
+				Count: int(d.Total.Minutes() + 0.5),
+			}
+		}
+		b.WriteString(tr("Minutes of breaks by day") + "\n")
+		writeBarChart(&b, rows, width)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// runStats shows charts of the play history.
+func runStats(args []string) error {
+	var (
+		fs       = flag.NewFlagSet("stats", flag.ExitOnError)
+		since    = fs.Duration("since", 0, "Only include plays within this long ago (e.g., 720h); 0 includes all")
+		top      = fs.Int("top", 10, "Number of artists and venues to chart")
+		width    = fs.Int("width", 40, "Width of the longest bar in bar charts")
+		asJSON   = fs.Bool("json", false, "Print the stats as JSON")
+		programs = fs.StringSlice("program", nil, "Only include plays that aired in these programs (see programs.schedule)")
+		venues   = fs.StringSlice("venue", nil, "Only include plays performed at these venues, by name or alias")
+	)
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	plays, err := st.plays()
+	if err != nil {
+		return fmt.Errorf("read play history: %w", err)
+	}
+	plays = loadPrograms(cfg).assign(plays)
+	if len(*programs) > 0 {
+		plays = plays.Filter(ByProgram(*programs...))
+	}
+	if len(*venues) > 0 {
+		plays = plays.Filter(ByVenue(*venues...))
+	}
+	var cutoff time.Time
+	if *since > 0 {
+		cutoff = time.Now().Add(-*since)
+	}
+	s := buildPlayStats(plays, cutoff, time.Local)
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(s)
+	}
+	if s.Plays == 0 {
+		fmt.Println(tr("No plays recorded yet. Plays are recorded each time ph checks the station."))
+		return nil
+	}
+	fmt.Println(s.text(*top, *width))
+	return nil
+}
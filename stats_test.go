@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPlayStats(t *testing.T) {
+	plays := TrackList{
+		{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-01T20:00:00")},
+		{Artist: "jempradio.com", Title: "Station ID", StartTime: mustParseDate("2020-06-05T20:10:00")},
+		{Artist: "Phish", Title: "Tweezer", StartTime: mustParseDate("2020-06-05T20:15:00"), PerformanceTime: mustParseDate("1997-11-22"), Location: "MSG, New York, NY"},
+		{Artist: "Goose", Title: "Arcadia", StartTime: mustParseDate("2020-06-05T21:00:00"), PerformanceTime: mustParseDate("2019-12-31")},
+		{Artist: "Goose", Title: "Hot Tea", StartTime: mustParseDate("2020-06-06T09:00:00"), PerformanceTime: mustParseDate("2019-07-04")},
+	}
+	s := buildPlayStats(plays, mustParseDate("2020-06-02"), time.UTC)
+	if s.Plays != 3 {
+		t.Errorf("wanted 3 plays, but got %d", s.Plays)
+	}
+	if s.Venues["Madison Square Garden"] != 1 || len(s.Venues) != 1 {
+		t.Errorf("wanted 1 play at Madison Square Garden, but got %v", s.Venues)
+	}
+	if s.Sets != 0 || s.Encores != 0 {
+		t.Errorf("wanted no full sets, but got %d with %d encores", s.Sets, s.Encores)
+	}
+	if s.Hours[20] != 1 || s.Hours[21] != 1 || s.Hours[9] != 1 {
+		t.Errorf("wanted one play each at 9:00, 20:00, and 21:00, but got %v", s.Hours)
+	}
+	if s.Days[time.Friday] != 2 || s.Days[time.Saturday] != 1 {
+		t.Errorf("wanted 2 plays on Friday and 1 on Saturday, but got %v", s.Days)
+	}
+	if s.Artists["Goose"] != 2 || s.Artists["Phish"] != 1 {
+		t.Errorf("wanted 2 Goose plays and 1 Phish play, but got %v", s.Artists)
+	}
+	wantDecades := []decadeShare{{Decade: 1990, Plays: 1, Percent: 100.0 / 3}, {Decade: 2010, Plays: 2, Percent: 200.0 / 3}}
+	if len(s.Decades) != len(wantDecades) {
+		t.Fatalf("wanted decades %v, but got %v", wantDecades, s.Decades)
+	}
+	for i := range wantDecades {
+		if s.Decades[i] != wantDecades[i] {
+			t.Errorf("decade %d: wanted %v, but got %v", i, wantDecades[i], s.Decades[i])
+		}
+	}
+
+	text := s.text(5, 10)
+	for _, want := range []string{"3 plays", "Mon     0\n", "Fri     2 ██████████", "Goose     2 ██████████", "1990s  33%     1 █████"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("wanted stats to contain %q, but got:\n%s", want, text)
+		}
+	}
+}
+
+func TestBuildPlayStats_Sets(t *testing.T) {
+	var (
+		at      = mustParseDate("2020-06-05T20:00:00")
+		hampton = mustParseDate("1997-11-22")
+		plays   = TrackList{
+			{Artist: "Phish", Title: "22-Nov-1997 Set 1", Set: &ShowSet{Number: 1}, PerformanceTime: hampton, StartTime: at},
+			{Artist: "jempradio.com", Title: "Station ID", StartTime: at.Add(55 * time.Minute)},
+			{Artist: "Phish", Title: "22-Nov-1997 Set 2 + Encore", Set: &ShowSet{Number: 2, IncludesEncore: true}, PerformanceTime: hampton, StartTime: at.Add(time.Hour)},
+			{Artist: "Goose", Title: "31-Dec-2019 Encore", Set: &ShowSet{EncoreOnly: true}, PerformanceTime: mustParseDate("2019-12-31"), StartTime: at.Add(3 * time.Hour)},
+			{Artist: "Phish", Title: "Reba", StartTime: at.Add(4 * time.Hour)},
+		}
+	)
+	s := buildPlayStats(plays, time.Time{}, time.UTC)
+	// The Hampton sets aired around a station break, as one show.
+	if s.Shows != 2 || s.Sets != 3 || s.Encores != 2 {
+		t.Errorf("wanted 2 full shows of 3 sets with 2 encores, but got %d of %d with %d", s.Shows, s.Sets, s.Encores)
+	}
+	if text, want := s.text(5, 10), "Full show broadcasts: 2, of 3 sets, 2 with the encore"; !strings.Contains(text, want) {
+		t.Errorf("wanted stats to contain %q, but got:\n%s", want, text)
+	}
+}
+
+func TestBuildPlayStats_Breaks(t *testing.T) {
+	var (
+		stationID = func(start string) Track {
+			return Track{Artist: "jempradio.com", Title: "JEMP Radio", StartTime: mustParseDate(start)}
+		}
+		song = func(start string) Track {
+			return Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate(start)}
+		}
+		plays = TrackList{
+			stationID("2020-06-05T20:10:00"),
+			song("2020-06-05T20:15:00"),
+			stationID("2020-06-05T21:30:00"),
+			song("2020-06-05T21:32:00"),
+			// Too long a gap to measure.
+			stationID("2020-06-06T08:00:00"),
+			song("2020-06-06T10:00:00"),
+			stationID("2020-06-06T10:30:00"),
+			song("2020-06-06T10:31:00"),
+			// The last break has no following play to measure it by.
+			stationID("2020-06-06T11:00:00"),
+		}
+	)
+	s := buildPlayStats(plays, time.Time{}, time.UTC)
+	want := []breakDay{
+		{Date: "2020-06-05", Count: 2, Total: 7 * time.Minute, Seconds: 420},
+		{Date: "2020-06-06", Count: 1, Total: time.Minute, Seconds: 60},
+	}
+	if len(s.Breaks) != len(want) {
+		t.Fatalf("wanted breaks %+v, but got %+v", want, s.Breaks)
+	}
+	for i := range want {
+		if s.Breaks[i] != want[i] {
+			t.Errorf("day %d: wanted %+v, but got %+v", i, want[i], s.Breaks[i])
+		}
+	}
+	if s.Plays != 4 {
+		t.Errorf("wanted station breaks not to count as plays, but got %d plays", s.Plays)
+	}
+	text := s.text(5, 10)
+	for _, want := range []string{"Station breaks: 3, averaging 2m40s each and 4m0s a day", "2020-06-05   2 breaks, avg 3m30s     7 ██████████"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("wanted stats to contain %q, but got:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// statusRate and statusBurst limit how often radio.co is asked for a
+	// station's status, across the whole process: statusBurst requests at
+	// once, then statusRate a second.
+	statusRate  = 1
+	statusBurst = 3
+)
+
+// statusRequests is the process-wide statusGroup that getStatus fetches
+// through.
+var statusRequests = newStatusGroup(time.Now, time.Sleep)
+
+// statusGroup collapses concurrent requests for the same station's status
+// into one request to radio.co, whose result they all share, and spaces
+// out the requests it does make with a rate limiter for each station, so
+// that the API, TUI, and poller never duplicate a request however many of
+// them ask at once.
+type statusGroup struct {
+	limiter *rateLimiter
+	now     func() time.Time
+	sleep   func(time.Duration)
+
+	mu    sync.Mutex
+	calls map[string]*statusCall
+	last  map[string]fetchedStatus
+}
+
+// fetchedStatus is a status, and when it was fetched.
+type fetchedStatus struct {
+	status statusResponseBody
+	time   time.Time
+}
+
+// statusCall is a request for a station's status in flight. done is closed
+// once status and err are set.
+type statusCall struct {
+	done   chan struct{}
+	status statusResponseBody
+	err    error
+}
+
+func newStatusGroup(now func() time.Time, sleep func(time.Duration)) *statusGroup {
+	return &statusGroup{
+		limiter: newRateLimiter(statusRate, statusBurst, now),
+		now:     now,
+		sleep:   sleep,
+		calls:   make(map[string]*statusCall),
+		last:    make(map[string]fetchedStatus),
+	}
+}
+
+// do returns the status of station from fetch, or from a call of fetch for
+// the same station that is already in flight. A call waits for the rate
+// limiter before fetching. A call sharing another's stops waiting for it
+// when ctx is done.
+func (g *statusGroup) do(ctx context.Context, station string, fetch func() (statusResponseBody, error)) (statusResponseBody, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[station]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.status, c.err
+		case <-ctx.Done():
+			return statusResponseBody{}, ctx.Err()
+		}
+	}
+	c := &statusCall{done: make(chan struct{})}
+	g.calls[station] = c
+	g.mu.Unlock()
+
+	for {
+		wait, ok := g.limiter.allow(station)
+		if ok {
+			break
+		}
+		g.sleep(wait)
+	}
+	c.status, c.err = fetch()
+
+	g.mu.Lock()
+	delete(g.calls, station)
+	if c.err == nil {
+		g.last[station] = fetchedStatus{status: c.status, time: g.now()}
+	}
+	g.mu.Unlock()
+	close(c.done)
+	return c.status, c.err
+}
+
+// lastStatus returns the last status of station that was fetched, and
+// when, reporting whether there is one.
+func (g *statusGroup) lastStatus(station string) (statusResponseBody, time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f, ok := g.last[station]
+	return f.status, f.time, ok
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatusGroup_Collapse(t *testing.T) {
+	var (
+		g       = newStatusGroup(time.Now, time.Sleep)
+		fetches int32
+		release = make(chan struct{})
+		started = make(chan struct{})
+		wg      sync.WaitGroup
+		fetch   = func() (statusResponseBody, error) {
+			if atomic.AddInt32(&fetches, 1) == 1 {
+				close(started)
+			}
+			<-release
+			return statusResponseBody{CurrentTrack: Track{Title: "Reba"}}, nil
+		}
+	)
+	results := make([]string, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		status, _ := g.do(context.Background(), "s1", fetch)
+		results[0] = status.CurrentTrack.Title
+	}()
+	<-started
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status, _ := g.do(context.Background(), "s1", fetch)
+			results[i] = status.CurrentTrack.Title
+		}(i)
+	}
+	// Give the other requests time to join the one in flight.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("wanted 1 fetch, but got %d", n)
+	}
+	for i, got := range results {
+		if got != "Reba" {
+			t.Errorf("request %d: wanted Reba, but got %q", i, got)
+		}
+	}
+}
+
+func TestStatusGroup_Canceled(t *testing.T) {
+	var (
+		g       = newStatusGroup(time.Now, time.Sleep)
+		release = make(chan struct{})
+		started = make(chan struct{})
+		fetch   = func() (statusResponseBody, error) {
+			close(started)
+			<-release
+			return statusResponseBody{}, nil
+		}
+	)
+	defer close(release)
+	go g.do(context.Background(), "s1", fetch)
+	<-started
+
+	// A request sharing one in flight gives up on it when canceled.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.do(ctx, "s1", fetch); err != context.DeadlineExceeded {
+		t.Errorf("wanted %v, but got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestStatusGroup_RateLimit(t *testing.T) {
+	var (
+		now   = mustParseDate("2020-06-05T20:00:00")
+		slept time.Duration
+		g     = newStatusGroup(func() time.Time { return now }, func(d time.Duration) {
+			slept += d
+			now = now.Add(d)
+		})
+		fetch = func() (statusResponseBody, error) { return statusResponseBody{}, nil }
+	)
+	for i := 0; i < statusBurst; i++ {
+		g.do(context.Background(), "s1", fetch)
+	}
+	if slept != 0 {
+		t.Errorf("wanted no wait within the burst, but waited %s", slept)
+	}
+	g.do(context.Background(), "s1", fetch)
+	if want := time.Second / statusRate; slept != want {
+		t.Errorf("wanted to wait %s after the burst, but waited %s", want, slept)
+	}
+	// Each station has its own limit.
+	slept = 0
+	g.do(context.Background(), "s2", fetch)
+	if slept != 0 {
+		t.Errorf("wanted no wait for another station, but waited %s", slept)
+	}
+}
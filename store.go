@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// store keeps ph's local data, such as the listening queue, as JSON files
+// in a directory.
+type store struct {
+	dir string
+}
+
+// openStore returns the store in the user's data directory, first
+// migrating data written by older versions of ph. A store that does not
+// exist yet is created with the latest schema version, since it has the
+// latest layout.
+func openStore() (*store, error) {
+	s, err := localStore()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) {
+		if err := s.setSchemaVersion(latestSchemaVersion()); err != nil {
+			return nil, fmt.Errorf("record schema version: %w", err)
+		}
+		return s, nil
+	}
+	applied, err := s.migrate()
+	for _, m := range applied {
+		log.Printf("migrated local data to version %d: %s", m.version, m.summary)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// localStore returns the store in the user's data directory as it is.
+func localStore() (*store, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	return &store{dir: dir}, nil
+}
+
+// dataDir returns the directory where ph keeps data that should persist,
+// following the XDG base directory specification where it applies.
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "ph"), nil
+	}
+	if runtime.GOOS == "linux" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".local", "share", "ph"), nil
+	}
+	// macOS and Windows keep application data alongside configuration.
+	return configDir()
+}
+
+func (s *store) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// readJSON decodes the named file into v. A missing file leaves v
+// unchanged and is not an error.
+func (s *store) readJSON(name string, v interface{}) error {
+	b, err := ioutil.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// writeJSON encodes v into the named file, replacing it atomically.
+func (s *store) writeJSON(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, os.FileMode(0777)); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(name), b)
+}
+
+// appendJSONLine encodes v as a single line of JSON appended to the named
+// file, for logs that only grow.
+func (s *store) appendJSONLine(name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, os.FileMode(0777)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path(name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readJSONLines calls fn with each line of the named file. A missing file
+// has no lines.
+func (s *store) readJSONLines(name string, fn func([]byte) error) error {
+	f, err := os.Open(s.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readJSONLinesTail calls fn with each of the lines in the last size bytes
+// of the named file, so that the most recent entries of a log can be read
+// without reading all of it. A line cut off by the start of the tail is
+// skipped. A missing file has no lines.
+func (s *store) readJSONLinesTail(name string, size int64, fn func([]byte) error) error {
+	f, err := os.Open(s.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var offset int64
+	if info.Size() > size {
+		offset = info.Size() - size
+	}
+	// Start one byte early, so that a tail beginning on a line boundary
+	// does not lose its first line.
+	if offset > 0 {
+		offset--
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	if offset > 0 {
+		if _, err := r.ReadString('\n'); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// writeFileAtomic writes b to path by way of a temporary file in the same
+// directory, so readers never see a partially written file.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), os.FileMode(0644)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
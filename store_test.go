@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStore_ReadJSONLinesTail(t *testing.T) {
+	st := newTestStore(t)
+	for i := 0; i < 10; i++ {
+		if err := st.appendJSONLine("log.jsonl", fmt.Sprintf("line %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tt := []struct {
+		size int64
+		want []string
+	}{
+		{size: 1 << 10, want: []string{`"line 0"`, `"line 1"`, `"line 2"`, `"line 3"`, `"line 4"`, `"line 5"`, `"line 6"`, `"line 7"`, `"line 8"`, `"line 9"`}},
+		{size: 2 * int64(len(`"line 0"`)+1), want: []string{`"line 8"`, `"line 9"`}},
+		{size: 2*int64(len(`"line 0"`)+1) + 3, want: []string{`"line 8"`, `"line 9"`}},
+		{size: 3, want: nil},
+	}
+	for _, tc := range tt {
+		var got []string
+		err := st.readJSONLinesTail("log.jsonl", tc.size, func(line []byte) error {
+			got = append(got, string(line))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d bytes: wanted %s, but got %s", tc.size, strings.Join(tc.want, " "), strings.Join(got, " "))
+		}
+	}
+	if err := st.readJSONLinesTail("missing.jsonl", 10, func([]byte) error { return nil }); err != nil {
+		t.Errorf("wanted no error for a missing file, but got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// tagFile replaces the tags of a recording with ones describing t, and its
+// ReplayGain if its loudness is not nil: ID3v2 tags for MP3 and AAC files,
+// and Vorbis comments for Ogg Vorbis and Opus files.
+func tagFile(path string, t Track, l *loudness) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".mp3", ".aac":
+		frames := trackID3Frames(t)
+		if l != nil {
+			for _, f := range l.replayGainFields() {
+				frames = append(frames, id3Frame{ID: "TXXX", Desc: f[0], Text: f[1]})
+			}
+		}
+		b = append(id3v2Tag(frames), stripID3v2(b)...)
+	case ".ogg", ".oga", ".opus":
+		comments := trackVorbisComments(t)
+		switch {
+		case l != nil && ext == ".opus":
+			// Opus players apply R128 gain, not ReplayGain.
+			comments = append(comments, fmt.Sprintf("R128_TRACK_GAIN=%d", l.r128Gain()))
+		case l != nil:
+			for _, f := range l.replayGainFields() {
+				comments = append(comments, f[0]+"="+f[1])
+			}
+		}
+		if b, err = retagOgg(b, comments); err != nil {
+			return fmt.Errorf("tag %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("tag %s: unsupported file type", path)
+	}
+	return writeFileAtomic(path, b)
+}
+
+// taggableExtensions are the extensions of the files tagFile can tag.
+var taggableExtensions = []string{".mp3", ".aac", ".ogg", ".oga", ".opus"}
+
+// recordingCopy matches the number ph record adds to the name of a track
+// recorded more than once.
+var recordingCopy = regexp.MustCompile(`\s\(\d+\)$`)
+
+// matchRecording finds the play a recording, named as ph record names
+// them, is of. Of the plays with the same name, the last one to start
+// before the file was last modified, which is when its recording ended, is
+// taken.
+func matchRecording(plays TrackList, name string, modTime time.Time) (Track, bool) {
+	name = recordingCopy.ReplaceAllString(strings.TrimSuffix(name, filepath.Ext(name)), "")
+	var (
+		match Track
+		found bool
+	)
+	for _, p := range plays {
+		if recordingName(p) != name {
+			continue
+		}
+		if !found || !p.StartTime.After(modTime) {
+			match, found = p, true
+		}
+	}
+	return match, found
+}
+
+// runTagfix tags recordings made before ph record tagged them as it does
+// now, using the play history to find what each recording is of.
+func runTagfix(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs     = flag.NewFlagSet("tagfix", flag.ExitOnError)
+		dryRun = fs.BoolP("dry-run", "n", false, "Show what would be tagged without changing any files")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ph tagfix [flags] <dir>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("no directory given")
+	}
+	dir := fs.Arg(0)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	plays, err := st.plays()
+	if err != nil {
+		return fmt.Errorf("read play history: %w", err)
+	}
+	correct := func(t Track) Track { return t }
+	if cfg.Catalog.Autocorrect {
+		correct = loadSongCatalog(context.Background(), apiClient(cfg), cfg).correct
+	}
+
+	var tagged, total int
+	for _, f := range files {
+		if f.IsDir() || !containsString(taggableExtensions, strings.ToLower(filepath.Ext(f.Name()))) {
+			continue
+		}
+		total++
+		t, ok := matchRecording(plays, f.Name(), f.ModTime())
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Skipping %s: not in the play history\n", f.Name())
+			continue
+		}
+		t = correct(t)
+		if *dryRun {
+			fmt.Printf("Would tag %s as %s\n", f.Name(), trackSummary(t))
+			tagged++
+			continue
+		}
+		// The recording index keeps the loudness of recordings that have
+		// been scanned, so their ReplayGain tags are kept.
+		path, err := filepath.Abs(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		var l *loudness
+		if e, ok, err := st.recording(path); err != nil {
+			return fmt.Errorf("read recording index: %w", err)
+		} else if ok {
+			l = e.Loudness
+		}
+		if err := tagFile(path, t, l); err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", f.Name(), err)
+			continue
+		}
+		fmt.Printf("Tagged %s as %s\n", f.Name(), trackSummary(t))
+		tagged++
+	}
+	fmt.Printf("Tagged %d of %d recordings\n", tagged, total)
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchRecording(t *testing.T) {
+	var (
+		reba    = Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")}
+		first   = reba
+		second  = reba
+		arcadia = Track{Artist: "Goose", Title: "Arcadia", StartTime: mustParseDate("2024-06-01T21:00:00")}
+	)
+	first.StartTime = mustParseDate("2024-06-01T10:00:00")
+	second.StartTime = mustParseDate("2024-06-01T20:00:00")
+	plays := TrackList{first, arcadia, second}
+	tt := []struct {
+		desc    string
+		name    string
+		modTime string
+		want    Track
+		wantOK  bool
+	}{
+		{desc: "first airing", name: "Phish - 1997-11-22 - Reba.mp3", modTime: "2024-06-01T10:15:00", want: first, wantOK: true},
+		{desc: "second airing", name: "Phish - 1997-11-22 - Reba (2).mp3", modTime: "2024-06-01T20:15:00", want: second, wantOK: true},
+		{desc: "studio track", name: "Goose - 2024-06-01 - Arcadia.ogg", modTime: "2024-06-01T21:05:00", want: arcadia, wantOK: true},
+		{desc: "not in the history", name: "Phish - 1997-11-22 - Ghost.mp3", modTime: "2024-06-01T10:15:00"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := matchRecording(plays, tc.name, mustParseDate(tc.modTime))
+			if ok != tc.wantOK || !got.Key().Equal(tc.want.Key()) {
+				t.Errorf("wanted %v (%t), but got %v (%t)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestTagFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ph-tagfix")
+	if err != nil {
+		t.Fatalf("unable to create dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	reba := Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22"), Location: "Hampton, VA"}
+	mp3 := filepath.Join(dir, "Phish - 1997-11-22 - Reba.mp3")
+	old := id3v2Tag([]id3Frame{{ID: "TIT2", Text: "Reba (soundcheck)"}})
+	if err := ioutil.WriteFile(mp3, append(old, "\xff\xfbaudio"...), 0644); err != nil {
+		t.Fatalf("unable to write recording: %v", err)
+	}
+	if err := tagFile(mp3, reba, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := ioutil.ReadFile(mp3)
+	if err != nil {
+		t.Fatalf("unable to read recording: %v", err)
+	}
+	if want := append(id3v2Tag(trackID3Frames(reba)), "\xff\xfbaudio"...); !bytes.Equal(got, want) {
+		t.Errorf("wanted %q, but got %q", want, got)
+	}
+
+	ogg := filepath.Join(dir, "Phish - 1997-11-22 - Reba.ogg")
+	if err := ioutil.WriteFile(ogg, testOggFile(nil), 0644); err != nil {
+		t.Fatalf("unable to write recording: %v", err)
+	}
+	if err := tagFile(ogg, reba, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(ogg)
+	if err != nil {
+		t.Fatalf("unable to read recording: %v", err)
+	}
+	if got, err := oggComment(b, "LOCATION"); err != nil || got != "Hampton, VA" {
+		t.Errorf("wanted location %q, but got %q (%v)", "Hampton, VA", got, err)
+	}
+
+	notes := filepath.Join(dir, "notes.txt")
+	if err := ioutil.WriteFile(notes, []byte("great Reba"), 0644); err != nil {
+		t.Fatalf("unable to write notes: %v", err)
+	}
+	if err := tagFile(notes, reba, nil); err == nil {
+		t.Error("wanted an error tagging an unsupported file, but got none")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	telegramAPI = "https://api.telegram.org"
+
+	// telegramPollTimeout is how long Telegram holds a getUpdates request
+	// open waiting for new messages.
+	telegramPollTimeout = 30 * time.Second
+
+	// telegramMaxMessage is the longest message text Telegram accepts.
+	telegramMaxMessage = 4096
+)
+
+// telegramConfig holds the settings for a Telegram bot.
+type telegramConfig struct {
+	Token  string `yaml:"token,omitempty" doc:"Bot token from @BotFather"`
+	ChatID int64  `yaml:"chat_id,omitempty" doc:"Chat to push announcements of favorite-artist plays to; without it, the bot only answers commands"`
+}
+
+// telegramClient is a minimal Telegram bot, using the Bot API's long
+// polling for updates.
+type telegramClient struct {
+	client *http.Client
+	api    string
+	token  string
+	chatID int64
+}
+
+func newTelegramClient(client *http.Client, cfg telegramConfig) (*telegramClient, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("bot.telegram.token must be set")
+	}
+	return &telegramClient{
+		client: client,
+		api:    telegramAPI,
+		token:  cfg.Token,
+		chatID: cfg.ChatID,
+	}, nil
+}
+
+// call calls a Bot API method, decoding its result into v if it is not nil.
+func (c *telegramClient) call(ctx context.Context, method string, params, v interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.api+"/bot"+c.token+"/"+method, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// The token is part of the URL, so keep it out of the error.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("telegram %s: %s", method, resp.Status)
+	}
+	if !body.OK {
+		return fmt.Errorf("telegram %s: %s", method, body.Description)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(body.Result, v)
+}
+
+// telegramUpdate is the part of an update the bot uses.
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+func (c *telegramClient) getUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]telegramUpdate, error) {
+	var updates []telegramUpdate
+	err := c.call(ctx, "getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(timeout / time.Second),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+func (c *telegramClient) serve(ctx context.Context, onMessage func(text string, reply func(string) error)) error {
+	// Messages sent while the bot was not running have gone unanswered for
+	// too long to be worth answering, so start after the latest one.
+	pending, err := c.getUpdates(ctx, -1, 0)
+	if err != nil {
+		return err
+	}
+	var offset int64
+	for _, u := range pending {
+		offset = u.UpdateID + 1
+	}
+	for {
+		updates, err := c.getUpdates(ctx, offset, telegramPollTimeout)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil || u.Message.Text == "" {
+				continue
+			}
+			chatID := u.Message.Chat.ID
+			onMessage(telegramCommand(u.Message.Text), func(text string) error {
+				return c.sendTo(chatID, text)
+			})
+		}
+	}
+}
+
+// telegramCommand removes the bot's username from a command, which
+// Telegram adds in group chats, as in "/now@phbot".
+func telegramCommand(text string) string {
+	if !strings.HasPrefix(text, "/") {
+		return text
+	}
+	end := strings.IndexAny(text, " \n")
+	if end < 0 {
+		end = len(text)
+	}
+	if at := strings.IndexByte(text[:end], '@'); at >= 0 {
+		return text[:at] + text[end:]
+	}
+	return text
+}
+
+// send pushes text to the configured chat.
+func (c *telegramClient) send(text string) error {
+	if c.chatID == 0 {
+		return errors.New("bot.telegram.chat_id is not set")
+	}
+	return c.sendTo(c.chatID, text)
+}
+
+func (c *telegramClient) sendTo(chatID int64, text string) error {
+	if len(text) > telegramMaxMessage {
+		n := telegramMaxMessage
+		for n > 0 && !isUTF8Start(text[n]) {
+			n--
+		}
+		text = text[:n]
+	}
+	return c.call(context.Background(), "sendMessage", map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}, nil)
+}
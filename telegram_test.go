@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestTelegramCommand(t *testing.T) {
+	tt := []struct {
+		text string
+		want string
+	}{
+		{text: "/now", want: "/now"},
+		{text: "/now@phbot", want: "/now"},
+		{text: "/setlist@phbot please", want: "/setlist please"},
+		{text: "mail me@example.com", want: "mail me@example.com"},
+	}
+	for _, tc := range tt {
+		if got := telegramCommand(tc.text); got != tc.want {
+			t.Errorf("%q: wanted %q, but got %q", tc.text, tc.want, got)
+		}
+	}
+}
+
+func TestTelegramClient(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		polls       int
+		sent        []map[string]interface{}
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var params map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&params)
+		switch r.URL.Path {
+		case "/bot123:secret/getUpdates":
+			polls++
+			switch polls {
+			case 1:
+				if params["offset"] != -1.0 {
+					t.Errorf("wanted the first poll to skip pending updates, but got offset %v", params["offset"])
+				}
+				w.Write([]byte(`{"ok": true, "result": [{"update_id": 41, "message": {"text": "/now", "chat": {"id": 7}}}]}`))
+			case 2:
+				if params["offset"] != 42.0 {
+					t.Errorf("wanted offset 42, but got %v", params["offset"])
+				}
+				w.Write([]byte(`{"ok": true, "result": [
+					{"update_id": 42, "message": {"text": "/now@phbot", "chat": {"id": 7}}},
+					{"update_id": 43}
+				]}`))
+			default:
+				cancel()
+				w.Write([]byte(`{"ok": true, "result": []}`))
+			}
+		case "/bot123:secret/sendMessage":
+			sent = append(sent, params)
+			w.Write([]byte(`{"ok": true, "result": {}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := newTelegramClient(srv.Client(), telegramConfig{Token: "123:secret", ChatID: 99})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.api = srv.URL
+	var received []string
+	err = c.serve(ctx, func(text string, reply func(string) error) {
+		received = append(received, text)
+		if err := reply("Now playing: Phish - Reba"); err != nil {
+			t.Errorf("unexpected error replying: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.send("Now playing: Phish - Tweezer"); err != nil {
+		t.Fatalf("unexpected error sending: %v", err)
+	}
+	if len(received) != 1 || received[0] != "/now" {
+		t.Errorf("wanted to receive only the new /now, but got %q", received)
+	}
+	if len(sent) != 2 || sent[0]["chat_id"] != 7.0 || sent[1]["chat_id"] != 99.0 {
+		t.Errorf("wanted a reply to chat 7 and an announcement to chat 99, but got %v", sent)
+	}
+}
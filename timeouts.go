@@ -0,0 +1,56 @@
+package main
+
+import "time"
+
+// timeoutsConfig limits how long each service ph talks to may take to
+// respond to a request, so that one that is slow or unreachable cannot
+// stall ph watching the station or serving its pages.
+type timeoutsConfig struct {
+	RadioCo   time.Duration `yaml:"radio_co,omitempty" doc:"How long to wait for the station's status from radio.co (default 10s)"`
+	Relisten  time.Duration `yaml:"relisten,omitempty" doc:"How long to wait for each request to Relisten (default 15s)"`
+	PhishNet  time.Duration `yaml:"phish_net,omitempty" doc:"How long to wait for the phish.net song catalog, a setlist, or lyrics (default 15s)"`
+	SetlistFM time.Duration `yaml:"setlist_fm,omitempty" doc:"How long to wait for a setlist from setlist.fm (default 15s)"`
+	Spotify   time.Duration `yaml:"spotify,omitempty" doc:"How long to wait for each request to Spotify (default 10s)"`
+	Lyrics    time.Duration `yaml:"lyrics,omitempty" doc:"How long to wait for the lyrics of a song (default 15s)"`
+}
+
+// Default timeouts for each service, unless configured otherwise.
+const (
+	defaultRadioCoTimeout   = 10 * time.Second
+	defaultRelistenTimeout  = 15 * time.Second
+	defaultPhishNetTimeout  = 15 * time.Second
+	defaultSetlistFMTimeout = 15 * time.Second
+	defaultSpotifyTimeout   = 10 * time.Second
+	defaultLyricsTimeout    = 15 * time.Second
+)
+
+func (c timeoutsConfig) radioCo() time.Duration {
+	return timeoutOrDefault(c.RadioCo, defaultRadioCoTimeout)
+}
+
+func (c timeoutsConfig) relisten() time.Duration {
+	return timeoutOrDefault(c.Relisten, defaultRelistenTimeout)
+}
+
+func (c timeoutsConfig) phishNet() time.Duration {
+	return timeoutOrDefault(c.PhishNet, defaultPhishNetTimeout)
+}
+
+func (c timeoutsConfig) setlistFM() time.Duration {
+	return timeoutOrDefault(c.SetlistFM, defaultSetlistFMTimeout)
+}
+
+func (c timeoutsConfig) spotify() time.Duration {
+	return timeoutOrDefault(c.Spotify, defaultSpotifyTimeout)
+}
+
+func (c timeoutsConfig) lyrics() time.Duration {
+	return timeoutOrDefault(c.Lyrics, defaultLyricsTimeout)
+}
+
+func timeoutOrDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
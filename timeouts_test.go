@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchStatus_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var cfg config
+	cfg.Timeouts.RadioCo = 10 * time.Millisecond
+	radio := newRadioCoClient(&http.Client{Transport: rewriteTransport{url: srv.URL}}, cfg)
+	if _, err := fetchStatus(context.Background(), radio, "s1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wanted %v, but got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestTimeoutsConfig(t *testing.T) {
+	tt := []struct {
+		desc string
+		got  time.Duration
+		want time.Duration
+	}{
+		{"default", timeoutsConfig{}.radioCo(), defaultRadioCoTimeout},
+		{"configured", timeoutsConfig{PhishNet: time.Minute}.phishNet(), time.Minute},
+	}
+	for _, tc := range tt {
+		if tc.got != tc.want {
+			t.Errorf("%s: wanted %s, but got %s", tc.desc, tc.want, tc.got)
+		}
+	}
+}
+
+// rewriteTransport sends every request to url instead of the host it was
+// made for.
+type rewriteTransport struct {
+	url string
+}
+
+func (rt rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = strings.TrimPrefix(rt.url, "http://")
+	return http.DefaultTransport.RoundTrip(r)
+}
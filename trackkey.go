@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// TrackKey identifies an airing of a track. The artist and title are
+// normalized, so that an airing reported with different capitalization or
+// punctuation, as when its title is corrected against the song catalog,
+// has the same key. Keys are comparable, so they can be used as map keys.
+type TrackKey struct {
+	Artist string
+	Title  string
+
+	// Performed is the date the track was performed, as YYYY-MM-DD, or
+	// empty if it is not known.
+	Performed string
+
+	// Start is when the airing started, in Unix nanoseconds, or zero if it
+	// is not known.
+	Start int64
+}
+
+// Key returns the key that identifies the airing t.
+func (t Track) Key() TrackKey {
+	k := TrackKey{
+		Artist: strings.ToLower(strings.TrimSpace(t.Artist)),
+		Title:  normalizeSongTitle(t.Title),
+	}
+	if !t.PerformanceTime.IsZero() {
+		k.Performed = t.PerformanceTime.Format("2006-01-02")
+	}
+	if !t.StartTime.IsZero() {
+		k.Start = t.StartTime.UnixNano()
+	}
+	return k
+}
+
+// Song returns the key with only its artist and title, which identifies
+// the song however many times, or from whichever show, it aired.
+func (k TrackKey) Song() TrackKey {
+	return TrackKey{Artist: k.Artist, Title: k.Title}
+}
+
+// Equal reports whether k and other identify the same airing.
+func (k TrackKey) Equal(other TrackKey) bool {
+	return k == other
+}
+
+// Hash returns a short string that is the same for equal keys, for where a
+// key must be a string, as in a file or a URL. It is not the same as a
+// play's ID, which is taken from the title as broadcast.
+func (k TrackKey) Hash() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{k.Artist, k.Title, k.Performed, strconv.FormatInt(k.Start, 10)}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestTrackKey(t *testing.T) {
+	var (
+		reba      = Track{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22"), StartTime: mustParseDate("2020-06-05T20:00:00")}
+		rebaAgain = reba
+		loud      = reba
+		untimed   = Track{Artist: "Phish", Title: "Reba"}
+		wolfman   = Track{Artist: "Phish", Title: "Wolfman's Brother", StartTime: reba.StartTime}
+		corrected = Track{Artist: "phish ", Title: "Wolfman's Brother!", StartTime: reba.StartTime}
+	)
+	rebaAgain.StartTime = mustParseDate("2020-06-05T23:00:00")
+	loud.Title = "REBA"
+	tt := []struct {
+		desc     string
+		a, b     Track
+		want     bool
+		wantSong bool
+	}{
+		{desc: "same airing", a: reba, b: reba, want: true, wantSong: true},
+		{desc: "capitalized differently", a: reba, b: loud, want: true, wantSong: true},
+		{desc: "punctuated differently", a: wolfman, b: corrected, want: true, wantSong: true},
+		{desc: "aired again", a: reba, b: rebaAgain, want: false, wantSong: true},
+		{desc: "no start time", a: reba, b: untimed, want: false, wantSong: true},
+		{desc: "different song", a: reba, b: wolfman, want: false, wantSong: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			a, b := tc.a.Key(), tc.b.Key()
+			if got := a.Equal(b); got != tc.want {
+				t.Errorf("wanted %t, but got %t for %+v and %+v", tc.want, got, a, b)
+			}
+			if got := a.Hash() == b.Hash(); got != tc.want {
+				t.Errorf("wanted hashes equal %t, but got %t", tc.want, got)
+			}
+			if got := a.Song().Equal(b.Song()); got != tc.wantSong {
+				t.Errorf("wanted songs equal %t, but got %t", tc.wantSong, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrackList is a list of tracks, such as the station's recent history or
+// the play history. Lists from radio.co are most recent first; the play
+// history is oldest first.
+//
+// TrackList methods never modify the list they are called on, and the
+// lists they return never share storage with it, down to what the tracks'
+// pointer fields, such as Set and Break, point at, so a result can be
+// changed or handed to another goroutine without affecting the original. Like any
+// slice, a TrackList is safe for concurrent use only while no goroutine
+// modifies it.
+type TrackList []Track
+
+// clone returns a copy of t that shares nothing with it, copying what its
+// pointer fields point at, too.
+func (t Track) clone() Track {
+	if t.Set != nil {
+		set := *t.Set
+		t.Set = &set
+	}
+	if t.Break != nil {
+		b := *t.Break
+		t.Break = &b
+	}
+	if t.JamChart != nil {
+		jc := *t.JamChart
+		t.JamChart = &jc
+	}
+	if t.Position != nil {
+		pos := *t.Position
+		t.Position = &pos
+	}
+	return t
+}
+
+// Filter returns the tracks for which keep returns true, in order.
+func (tl TrackList) Filter(keep func(Track) bool) TrackList {
+	out := make(TrackList, 0, len(tl))
+	for _, t := range tl {
+		if keep(t) {
+			out = append(out, t.clone())
+		}
+	}
+	return out
+}
+
+// Map returns the result of applying f to each track, in order.
+func (tl TrackList) Map(f func(Track) Track) TrackList {
+	out := make(TrackList, len(tl))
+	for i, t := range tl {
+		out[i] = f(t.clone())
+	}
+	return out
+}
+
+// Take returns the first n tracks, or all of them if there are fewer than
+// n. For a list from radio.co, these are the n most recent.
+func (tl TrackList) Take(n int) TrackList {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(tl) {
+		n = len(tl)
+	}
+	out := make(TrackList, n)
+	for i, t := range tl[:n] {
+		out[i] = t.clone()
+	}
+	return out
+}
+
+// Since returns the tracks that started at or after t, in order. Tracks
+// without a start time are left out, since when they aired is not known.
+func (tl TrackList) Since(t time.Time) TrackList {
+	return tl.Filter(ByDateRange(t, time.Time{}))
+}
+
+// Dedupe returns the tracks with repeated airings removed, keeping the
+// first of each. Airings are the same if they have the same key, so a track
+// that aired twice is kept twice.
+func (tl TrackList) Dedupe() TrackList {
+	var (
+		out  = make(TrackList, 0, len(tl))
+		seen = make(map[TrackKey]bool, len(tl))
+	)
+	for _, t := range tl {
+		k := t.Key()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, t.clone())
+	}
+	return out
+}
+
+// Merge combines a remote history, most recent first as radio.co returns
+// it, with a local history, oldest first as the play history records it,
+// into a single history, oldest first, with each airing once.
+//
+// Airings are aligned by start time and title. A remote track without a
+// start time, as in radio.co's history, is aligned with the latest local
+// airing of the same artist and title before the last one aligned, since
+// the remote history is the tail of the local one. When both histories
+// have an airing, the local record wins, with any fields it lacks filled in
+// from the remote one. Airings only in one history are placed by start
+// time; one without a start time follows the airing before it in its own
+// history.
+func Merge(remote, local TrackList) TrackList {
+	type entry struct {
+		Track
+		at   time.Time // start time, or that of the airing before it
+		rank int       // order among entries with the same at
+	}
+	entries := make([]entry, 0, len(remote)+len(local))
+	var at time.Time
+	for _, t := range local {
+		if !t.StartTime.IsZero() {
+			at = t.StartTime
+		}
+		entries = append(entries, entry{Track: t, at: at, rank: len(entries)})
+	}
+
+	// Align remote tracks from the most recent back, then place those that
+	// did not align after the airing before them, in chronological order.
+	// Tracks before any airing that is placed go just before the first one
+	// that is, or at the end, since the remote history is the most recent.
+	var (
+		matches = make([]int, len(remote))
+		before  = len(local)
+	)
+	for i, t := range remote {
+		matches[i] = findAiring(local, before, len(remote), t)
+		if j := matches[i]; j >= 0 {
+			entries[j].Track = fillTrack(entries[j].Track, t)
+			before = j
+		}
+	}
+	var (
+		placed  bool
+		leading []int
+		place   = func(t time.Time) {
+			at, placed = t, true
+			for k, l := range leading {
+				entries[l].at, entries[l].rank = at, k-len(leading)
+			}
+			leading = nil
+		}
+	)
+	at = time.Time{}
+	for i := len(remote) - 1; i >= 0; i-- {
+		t := remote[i]
+		if j := matches[i]; j >= 0 {
+			place(entries[j].at)
+			continue
+		}
+		if !t.StartTime.IsZero() {
+			place(t.StartTime)
+		}
+		if !placed {
+			leading = append(leading, len(entries))
+		}
+		entries = append(entries, entry{Track: t, at: at, rank: len(entries)})
+	}
+	if len(leading) > 0 && len(local) > 0 {
+		last := entries[len(local)-1].at
+		for _, l := range leading {
+			entries[l].at = last
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].at.Equal(entries[j].at) {
+			return entries[i].at.Before(entries[j].at)
+		}
+		return entries[i].rank < entries[j].rank
+	})
+	out := make(TrackList, len(entries))
+	for i, e := range entries {
+		out[i] = e.Track
+	}
+	return out
+}
+
+// findAiring returns the index of the local airing that is the same as t,
+// or -1 if there is none. An airing with a start time matches on start
+// time and title. One without is the latest airing of the same artist and
+// title before index before, searching back no further than window.
+func findAiring(local TrackList, before, window int, t Track) int {
+	k := t.Key()
+	if k.Start != 0 {
+		for i := len(local) - 1; i >= 0; i-- {
+			if lk := local[i].Key(); lk.Start == k.Start && lk.Title == k.Title {
+				return i
+			}
+		}
+		return -1
+	}
+	for i := before - 1; i >= 0 && i >= before-window; i-- {
+		if local[i].Key().Song() == k.Song() {
+			return i
+		}
+	}
+	return -1
+}
+
+// fillTrack fills in the fields that t lacks from other.
+func fillTrack(t, other Track) Track {
+	fill := func(s *string, v string) {
+		if *s == "" {
+			*s = v
+		}
+	}
+	fill(&t.Artist, other.Artist)
+	fill(&t.Location, other.Location)
+	fill(&t.RawTitle, other.RawTitle)
+	fill(&t.ArtworkURL, other.ArtworkURL)
+	fill(&t.Program, other.Program)
+	fill(&t.PlayID, other.PlayID)
+	if t.StartTime.IsZero() {
+		t.StartTime = other.StartTime
+	}
+	if t.PerformanceTime.IsZero() {
+		t.PerformanceTime = other.PerformanceTime
+	}
+	if t.Set == nil {
+		t.Set = other.Set
+	}
+	if t.Break == nil {
+		t.Break = other.Break
+	}
+	return t
+}
+
+// Predicates select tracks for Filter. Command line filters, notification
+// triggers, and exports all build on them, so that tracks are selected the
+// same way everywhere. They can be combined with All, Any, and Not.
+
+// ByArtist selects tracks by any of the artists, ignoring case.
+func ByArtist(artists ...string) func(Track) bool {
+	return func(t Track) bool {
+		for _, a := range artists {
+			if strings.EqualFold(a, t.Artist) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ByKind selects tracks of any of the kinds, as returned by Track.Kind.
+func ByKind(kinds ...string) func(Track) bool {
+	return func(t Track) bool {
+		return containsString(kinds, t.Kind())
+	}
+}
+
+// ByDateRange selects tracks that started in [from, to). A zero from or to
+// leaves that end of the range open. Tracks without a start time are never
+// selected, since when they aired is not known.
+func ByDateRange(from, to time.Time) func(Track) bool {
+	return func(t Track) bool {
+		if t.StartTime.IsZero() {
+			return false
+		}
+		return !t.StartTime.Before(from) && (to.IsZero() || t.StartTime.Before(to))
+	}
+}
+
+// ByPerformanceDate selects tracks performed on the same day as d.
+func ByPerformanceDate(d time.Time) func(Track) bool {
+	return func(t Track) bool {
+		return !t.PerformanceTime.IsZero() && t.PerformanceTime.Format("2006-01-02") == d.Format("2006-01-02")
+	}
+}
+
+// ByNew selects tracks that are new to the user, by artist or song, as
+// marked in Track.New.
+func ByNew() func(Track) bool {
+	return func(t Track) bool {
+		return t.New != ""
+	}
+}
+
+// ByProgram selects tracks that aired in any of the programs, ignoring
+// case.
+func ByProgram(programs ...string) func(Track) bool {
+	return func(t Track) bool {
+		for _, p := range programs {
+			if strings.EqualFold(p, t.Program) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ByVenue selects tracks performed at any of the venues, by the names they
+// are known by, ignoring case.
+func ByVenue(venues ...string) func(Track) bool {
+	return func(t Track) bool {
+		v := t.Place().Venue
+		if v == "" {
+			return false
+		}
+		for _, venue := range venues {
+			if strings.EqualFold(canonicalVenue(venue), v) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ByTitleRegex selects tracks whose title matches re.
+func ByTitleRegex(re *regexp.Regexp) func(Track) bool {
+	return func(t Track) bool {
+		return re.MatchString(t.Title)
+	}
+}
+
+// All selects tracks that all of the predicates select. With no
+// predicates, it selects every track.
+func All(preds ...func(Track) bool) func(Track) bool {
+	return func(t Track) bool {
+		for _, p := range preds {
+			if !p(t) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any selects tracks that any of the predicates select. With no
+// predicates, it selects no tracks.
+func Any(preds ...func(Track) bool) func(Track) bool {
+	return func(t Track) bool {
+		for _, p := range preds {
+			if p(t) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not selects the tracks that p does not.
+func Not(p func(Track) bool) func(Track) bool {
+	return func(t Track) bool {
+		return !p(t)
+	}
+}
+
+// Ways of numbering the tracks in a table.
+const (
+	// numberForward counts from 1 at the first track, which for a list
+	// from radio.co is the most recent.
+	numberForward = "forward"
+
+	// numberReverse counts from 1 at the last track.
+	numberReverse = "reverse"
+
+	// numberNone leaves the tracks unnumbered, for copying and pasting.
+	numberNone = "none"
+)
+
+// numberings lists the ways of numbering the tracks in a table.
+var numberings = []string{numberNone, numberForward, numberReverse}
+
+// checkNumbering returns an error if numbering is not one of numberings.
+func checkNumbering(numbering string) error {
+	if !containsString(numberings, numbering) {
+		return fmt.Errorf("invalid numbering %q: must be one of %s", numbering, strings.Join(numberings, ", "))
+	}
+	return nil
+}
+
+// Columns of a table of tracks.
+const (
+	columnArtist    = "artist"
+	columnTitle     = "title"
+	columnPerformed = "performed"
+	columnPlayed    = "played"
+	columnStream    = "stream"
+)
+
+// tableColumns lists the columns a table of tracks can have, in the order
+// they are shown by default.
+var tableColumns = []string{columnArtist, columnTitle, columnPerformed, columnPlayed, columnStream}
+
+var columnHeadings = map[string]string{
+	columnArtist:    "ARTIST",
+	columnTitle:     "TITLE",
+	columnPerformed: "PERFORMED ON",
+	columnPlayed:    "PLAYED",
+	columnStream:    "STREAM",
+}
+
+// checkColumns returns an error if any of columns is not one of
+// tableColumns, or if there are none.
+func checkColumns(columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns given: use any of %s", strings.Join(tableColumns, ", "))
+	}
+	for _, c := range columns {
+		if !containsString(tableColumns, c) {
+			return fmt.Errorf("invalid column %q: must be one of %s", c, strings.Join(tableColumns, ", "))
+		}
+	}
+	return nil
+}
+
+// tableLayout is how a tracklist is laid out as a text table: how its
+// tracks are numbered, which columns it has, in order, and how precisely
+// it gives how long ago they were played.
+type tableLayout struct {
+	numbering string
+	columns   []string
+	elapsed   elapsedFormat
+}
+
+var defaultTableLayout = tableLayout{numbering: numberForward, columns: tableColumns}
+
+// String renders the tracklist as a text table.
+func (tl TrackList) String() string {
+	return tl.table(nil, defaultTableLayout)
+}
+
+// text is String with the output settings of cfg.
+func (tl TrackList) text(cfg config) string {
+	layout := defaultTableLayout
+	layout.elapsed = cfg.elapsedFormat()
+	return tl.table(nil, layout)
+}
+
+// table renders the tracklist as a text table laid out as layout says. The
+// stream column has each track's Relisten link from links, if given, and
+// otherwise the link Relisten would have if the show is there. When any
+// track is new to the user, or on the jam charts, a column marks those
+// that are, and the title of a track on the jam charts has its note.
+func (tl TrackList) table(links map[TrackID][]Link, layout tableLayout) string {
+	if len(tl) == 0 {
+		return ""
+	}
+	var (
+		rows   = make([][]string, len(tl))
+		widths = make([]int, len(layout.columns))
+		anyNew bool
+		anyJam bool
+	)
+	for j, c := range layout.columns {
+		widths[j] = len(columnHeadings[c])
+	}
+	for i, t := range tl {
+		anyNew = anyNew || t.New != ""
+		anyJam = anyJam || t.JamChart != nil
+		rows[i] = make([]string, len(layout.columns))
+		for j, c := range layout.columns {
+			rows[i][j] = tableCell(t, c, links, layout.elapsed)
+			if l := len(rows[i][j]); l > widths[j] {
+				widths[j] = l
+			}
+		}
+	}
+
+	var (
+		maxLenIndex = int(math.Floor(math.Log10(float64(len(tl))))) + 1
+		builder     strings.Builder
+	)
+	writeRow := func(prefix string, cells []string) {
+		var line strings.Builder
+		line.WriteString(prefix)
+		for j, cell := range cells {
+			if j > 0 {
+				line.WriteString("  ")
+			}
+			fmt.Fprintf(&line, "%-*s", widths[j], cell)
+		}
+		builder.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	var headingPrefix string
+	if layout.numbering != numberNone {
+		headingPrefix = strings.Repeat(" ", maxLenIndex+1)
+	}
+	// Markers are as wide as two spaces on the terminal, but not in bytes,
+	// so they are written unpadded.
+	var noMarkers string
+	if anyNew {
+		noMarkers += "   "
+	}
+	if anyJam {
+		noMarkers += "   "
+	}
+	headingPrefix = noMarkers + headingPrefix
+	headings := make([]string, len(layout.columns))
+	for j, c := range layout.columns {
+		headings[j] = columnHeadings[c]
+	}
+	writeRow(headingPrefix, headings)
+	for i, t := range tl {
+		var marker string
+		if anyNew {
+			marker += tableMarker(t.New != "", newMarker)
+		}
+		if anyJam {
+			marker += tableMarker(t.JamChart != nil, jamChartMarker)
+		}
+		var index string
+		switch layout.numbering {
+		case numberForward:
+			index = fmt.Sprintf("%*d ", maxLenIndex, i+1)
+		case numberReverse:
+			index = fmt.Sprintf("%*d ", maxLenIndex, len(tl)-i)
+		}
+		writeRow(index+marker, rows[i])
+	}
+	s := builder.String()
+	return s[:len(s)-1]
+}
+
+// tableMarker returns marker, followed by a space, if marked, and otherwise
+// the spaces it takes up on the terminal.
+func tableMarker(marked bool, marker string) string {
+	if marked {
+		return marker + " "
+	}
+	return "   "
+}
+
+// tableCell returns what a table of tracks shows for t in column, giving
+// how long ago it was played as elapsed says.
+func tableCell(t Track, column string, links map[TrackID][]Link, elapsed elapsedFormat) string {
+	switch column {
+	case columnArtist:
+		return t.Artist
+	case columnTitle:
+		if t.JamChart != nil {
+			return t.Title + " (" + t.JamChart.String() + ")"
+		}
+		return t.Title
+	case columnPerformed:
+		if pt := t.PerformanceTime; !pt.IsZero() {
+			return pt.Format("Mon _2-Jan-2006")
+		}
+	case columnPlayed:
+		if !t.StartTime.IsZero() {
+			return elapsed.started(t.Elapsed())
+		}
+	case columnStream:
+		if links == nil {
+			return t.streamingURL(relistenArtists)
+		}
+		var stream string
+		for _, l := range links[t.ID()] {
+			if l.Service == "Relisten" {
+				stream = l.URL
+			}
+		}
+		return stream
+	}
+	return ""
+}
+
+// linkedTrackList is a tracklist with its resolved links, which renders as
+// a text table showing them, laid out as layout says.
+type linkedTrackList struct {
+	TrackList
+	links  map[TrackID][]Link
+	layout tableLayout
+}
+
+func (l linkedTrackList) String() string {
+	return l.table(l.links, l.layout)
+}
+
+// text is String with the output settings of cfg.
+func (l linkedTrackList) text(cfg config) string {
+	layout := l.layout
+	layout.elapsed = cfg.elapsedFormat()
+	return l.table(l.links, layout)
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTrackList_Filter(t *testing.T) {
+	tt := []struct {
+		desc string
+		in   TrackList
+		keep func(Track) bool
+		want TrackList
+	}{
+		{
+			desc: "Phish only",
+			in: TrackList{
+				Track{Artist: "Phish"},
+				Track{Artist: "Grateful Dead"},
+				Track{Artist: "Phish"},
+			},
+			keep: func(t Track) bool {
+				return t.Artist == "Phish"
+			},
+			want: TrackList{
+				Track{Artist: "Phish"},
+				Track{Artist: "Phish"},
+			},
+		},
+		{
+			desc: "Exclude JEMP Radio meta-tracks",
+			in: TrackList{
+				Track{Artist: "Phish"},
+				Track{Artist: "www.jempradio.com"},
+				Track{Artist: "jempradio.com"},
+				Track{Artist: "Phish"},
+			},
+			keep: func(t Track) bool {
+				return !t.IsStationBreak()
+			},
+			want: TrackList{
+				Track{Artist: "Phish"},
+				Track{Artist: "Phish"},
+			},
+		},
+		{
+			desc: "Nothing kept",
+			in:   TrackList{Track{Artist: "Phish"}},
+			keep: func(Track) bool { return false },
+			want: TrackList{},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := tc.in.Filter(tc.keep)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackList_Map(t *testing.T) {
+	in := TrackList{{Title: "reba"}, {Title: "tweezer"}}
+	got := in.Map(func(t Track) Track {
+		t.Title = t.Title + "!"
+		return t
+	})
+	want := TrackList{{Title: "reba!"}, {Title: "tweezer!"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v but got %v", want, got)
+	}
+	if in[0].Title != "reba" {
+		t.Errorf("wanted the original list to be unchanged, but got %v", in)
+	}
+}
+
+func TestTrackList_Take(t *testing.T) {
+	in := TrackList{{Title: "Reba"}, {Title: "Tweezer"}, {Title: "Possum"}}
+	tt := []struct {
+		desc string
+		n    int
+		want TrackList
+	}{
+		{desc: "fewer", n: 2, want: TrackList{{Title: "Reba"}, {Title: "Tweezer"}}},
+		{desc: "all", n: 3, want: in},
+		{desc: "more than all", n: 10, want: in},
+		{desc: "none", n: 0, want: TrackList{}},
+		{desc: "negative", n: -1, want: TrackList{}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := in.Take(tc.n)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v but got %v", tc.want, got)
+			}
+		})
+	}
+
+	// Appending to the result must not overwrite the original list, as it
+	// would if the result shared the original's backing array.
+	taken := in.Take(1)
+	taken = append(taken, Track{Title: "Bowie"})
+	if in[1].Title != "Tweezer" {
+		t.Errorf("wanted the original list to be unchanged, but got %v", in)
+	}
+}
+
+func TestTrackList_ResultsShareNothing(t *testing.T) {
+	in := TrackList{{Title: "Reba", Set: &ShowSet{Number: 1}, Break: &StationBreak{Kind: "id"}}}
+	results := map[string]TrackList{
+		"Filter": in.Filter(func(Track) bool { return true }),
+		"Map":    in.Map(func(t Track) Track { return t }),
+		"Take":   in.Take(1),
+		"Dedupe": in.Dedupe(),
+	}
+	for name, got := range results {
+		got[0].Set.Number = 2
+		got[0].Break.Kind = "promo"
+		if in[0].Set.Number != 1 || in[0].Break.Kind != "id" {
+			t.Errorf("%s: wanted the original track unchanged, but got set %d and break %q", name, in[0].Set.Number, in[0].Break.Kind)
+		}
+	}
+}
+
+func TestTrackList_Since(t *testing.T) {
+	var (
+		early = Track{Title: "Reba", StartTime: mustParseDate("2020-06-05T20:00:00")}
+		late  = Track{Title: "Tweezer", StartTime: mustParseDate("2020-06-05T21:00:00")}
+		unset = Track{Title: "Possum"}
+		in    = TrackList{late, unset, early}
+	)
+	tt := []struct {
+		desc  string
+		since string
+		want  TrackList
+	}{
+		{desc: "all timed tracks", since: "2020-06-05T19:00:00", want: TrackList{late, early}},
+		{desc: "inclusive", since: "2020-06-05T21:00:00", want: TrackList{late}},
+		{desc: "none", since: "2020-06-05T22:00:00", want: TrackList{}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := in.Since(mustParseDate(tc.since))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackList_Dedupe(t *testing.T) {
+	var (
+		reba      = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:00:00")}
+		rebaAgain = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T23:00:00")}
+		untimed   = Track{Artist: "Cream", Title: "Crossroads"}
+	)
+	tt := []struct {
+		desc string
+		in   TrackList
+		want TrackList
+	}{
+		{desc: "repeated airing", in: TrackList{reba, reba, untimed}, want: TrackList{reba, untimed}},
+		{desc: "aired twice", in: TrackList{reba, rebaAgain}, want: TrackList{reba, rebaAgain}},
+		{desc: "untimed", in: TrackList{untimed, untimed}, want: TrackList{untimed}},
+		{desc: "empty", in: nil, want: TrackList{}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := tc.in.Dedupe()
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackPredicates(t *testing.T) {
+	var (
+		reba     = Track{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2020-06-05T20:00:00")}
+		show     = Track{Artist: "Phish", Title: "22-Nov-1997 Set 2", Set: &ShowSet{Number: 2}, PerformanceTime: mustParseDate("1997-11-22"), StartTime: mustParseDate("2020-06-05T21:00:00")}
+		station  = Track{Artist: "jempradio.com", Title: "Station ID", StartTime: mustParseDate("2020-06-05T22:00:00")}
+		arcadia  = Track{Artist: "Goose", Title: "Arcadia", New: newArtist}
+		tracks   = TrackList{reba, show, station, arcadia}
+		notPhish = Not(ByArtist("Phish"))
+	)
+	tt := []struct {
+		desc string
+		keep func(Track) bool
+		want TrackList
+	}{
+		{desc: "artist ignores case", keep: ByArtist("phish"), want: TrackList{reba, show}},
+		{desc: "several artists", keep: ByArtist("Goose", "Phish"), want: TrackList{reba, show, arcadia}},
+		{desc: "song", keep: ByKind(trackKindSong), want: TrackList{reba, arcadia}},
+		{desc: "full show or break", keep: ByKind(trackKindFullShow, trackKindStationBreak), want: TrackList{show, station}},
+		{
+			desc: "date range",
+			keep: ByDateRange(mustParseDate("2020-06-05T20:30:00"), mustParseDate("2020-06-05T22:00:00")),
+			want: TrackList{show},
+		},
+		{desc: "open date range", keep: ByDateRange(time.Time{}, time.Time{}), want: TrackList{reba, show, station}},
+		{desc: "performance date", keep: ByPerformanceDate(mustParseDate("1997-11-22")), want: TrackList{show}},
+		{desc: "new", keep: ByNew(), want: TrackList{arcadia}},
+		{desc: "title", keep: ByTitleRegex(regexp.MustCompile(`^(Reba|Arcadia)$`)), want: TrackList{reba, arcadia}},
+		{desc: "all", keep: All(ByArtist("Phish"), ByKind(trackKindSong)), want: TrackList{reba}},
+		{desc: "all of none", keep: All(), want: tracks},
+		{desc: "any", keep: Any(ByKind(trackKindFullShow), ByArtist("Goose")), want: TrackList{show, arcadia}},
+		{desc: "any of none", keep: Any(), want: TrackList{}},
+		{desc: "not", keep: notPhish, want: TrackList{station, arcadia}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := tracks.Filter(tc.keep)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %v but got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var (
+		at = func(clock string) time.Time { return mustParseDate("2020-06-05T" + clock) }
+
+		reba       = Track{Artist: "Phish", Title: "Reba", StartTime: at("20:00:00")}
+		tweezer    = Track{Artist: "Phish", Title: "Tweezer", StartTime: at("20:15:00")}
+		possum     = Track{Artist: "Phish", Title: "Possum", StartTime: at("20:30:00")}
+		crossroads = Track{Artist: "Cream", Title: "Crossroads", StartTime: at("20:40:00")}
+
+		// radio.co histories do not have start times.
+		untimed = func(t Track) Track {
+			t.StartTime = time.Time{}
+			return t
+		}
+		withArtwork = func(t Track) Track {
+			t.ArtworkURL = "https://images.radio.co/" + t.Title + ".jpg"
+			return t
+		}
+		bowie = Track{Artist: "Phish", Title: "David Bowie"}
+	)
+	tt := []struct {
+		desc   string
+		remote TrackList
+		local  TrackList
+		want   TrackList
+	}{
+		{
+			desc:   "timed airings in both",
+			remote: TrackList{possum, tweezer},
+			local:  TrackList{reba, tweezer},
+			want:   TrackList{reba, tweezer, possum},
+		},
+		{
+			desc:   "untimed remote aligned with local",
+			remote: TrackList{untimed(withArtwork(tweezer)), untimed(reba)},
+			local:  TrackList{reba, tweezer},
+			want:   TrackList{reba, withArtwork(tweezer)},
+		},
+		{
+			desc:   "untimed remote missed locally",
+			remote: TrackList{untimed(possum), bowie, untimed(tweezer)},
+			local:  TrackList{reba, tweezer, possum},
+			want:   TrackList{reba, tweezer, bowie, possum},
+		},
+		{
+			desc:   "untimed remote before anything aligned",
+			remote: TrackList{untimed(possum), bowie},
+			local:  TrackList{reba, tweezer, possum},
+			want:   TrackList{reba, tweezer, bowie, possum},
+		},
+		{
+			desc:   "nothing aligned",
+			remote: TrackList{bowie},
+			local:  TrackList{reba, tweezer},
+			want:   TrackList{reba, tweezer, bowie},
+		},
+		{
+			desc:   "aired twice",
+			remote: TrackList{untimed(reba), untimed(crossroads), untimed(reba)},
+			local:  TrackList{reba, crossroads, {Artist: "Phish", Title: "Reba", StartTime: at("20:50:00")}},
+			want:   TrackList{reba, crossroads, {Artist: "Phish", Title: "Reba", StartTime: at("20:50:00")}},
+		},
+		{
+			desc:   "local record wins",
+			remote: TrackList{{Artist: "Phish", Title: "Reba", StartTime: at("20:00:00"), Location: "Hampton, VA"}},
+			local:  TrackList{{Artist: "Phish", Title: "Reba", StartTime: at("20:00:00"), RawTitle: "Rebba"}},
+			want:   TrackList{{Artist: "Phish", Title: "Reba", StartTime: at("20:00:00"), Location: "Hampton, VA", RawTitle: "Rebba"}},
+		},
+		{
+			desc:   "remote only",
+			remote: TrackList{crossroads, reba},
+			want:   TrackList{reba, crossroads},
+		},
+		{
+			desc: "empty",
+			want: TrackList{},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := Merge(tc.remote, tc.local)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted\n%v\nbut got\n%v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackList_Table(t *testing.T) {
+	in := TrackList{
+		{Artist: "Phish", Title: "Reba", PerformanceTime: mustParseDate("1997-11-22")},
+		{Artist: "Goose", Title: "Arcadia"},
+	}
+	tt := []struct {
+		desc   string
+		layout tableLayout
+		want   string
+	}{
+		{
+			desc:   "forward",
+			layout: tableLayout{numbering: numberForward, columns: []string{columnArtist, columnTitle, columnPerformed}},
+			want: "  ARTIST  TITLE    PERFORMED ON\n" +
+				"1 Phish   Reba     Sat 22-Nov-1997\n" +
+				"2 Goose   Arcadia",
+		},
+		{
+			desc:   "reverse",
+			layout: tableLayout{numbering: numberReverse, columns: []string{columnArtist, columnTitle}},
+			want: "  ARTIST  TITLE\n" +
+				"2 Phish   Reba\n" +
+				"1 Goose   Arcadia",
+		},
+		{
+			desc:   "none",
+			layout: tableLayout{numbering: numberNone, columns: []string{columnArtist, columnTitle}},
+			want: "ARTIST  TITLE\n" +
+				"Phish   Reba\n" +
+				"Goose   Arcadia",
+		},
+		{
+			desc:   "columns in order given",
+			layout: tableLayout{numbering: numberNone, columns: []string{columnTitle, columnArtist}},
+			want: "TITLE    ARTIST\n" +
+				"Reba     Phish\n" +
+				"Arcadia  Goose",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := in.table(nil, tc.layout); got != tc.want {
+				t.Errorf("wanted\n%q\nbut got\n%q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackList_TablePlayed(t *testing.T) {
+	clock := useFakeClock(t, mustParseDate("2020-07-04T18:00:00"))
+	in := TrackList{
+		{Artist: "Phish", Title: "Reba", StartTime: clock.Now().Add(-3 * time.Minute)},
+		{Artist: "Goose", Title: "Arcadia"},
+	}
+	got := in.table(nil, tableLayout{numbering: numberNone, columns: []string{columnTitle, columnPlayed}})
+	want := "TITLE    PLAYED\n" +
+		"Reba     3m ago\n" +
+		"Arcadia"
+	if got != want {
+		t.Errorf("wanted\n%q\nbut got\n%q", want, got)
+	}
+}
+
+func TestCheckColumns(t *testing.T) {
+	tt := []struct {
+		desc    string
+		columns []string
+		wantErr bool
+	}{
+		{desc: "all", columns: tableColumns},
+		{desc: "some", columns: []string{columnTitle, columnPlayed}},
+		{desc: "none", wantErr: true},
+		{desc: "invalid", columns: []string{columnTitle, "venue"}, wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if err := checkColumns(tc.columns); (err != nil) != tc.wantErr {
+				t.Errorf("wanted error %v, but got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckNumbering(t *testing.T) {
+	for _, n := range numberings {
+		if err := checkNumbering(n); err != nil {
+			t.Errorf("wanted %q to be valid, but got %v", n, err)
+		}
+	}
+	if err := checkNumbering("backward"); err == nil {
+		t.Error("wanted an error for an invalid numbering, but got none")
+	}
+}
+
+func BenchmarkTrackList_Table(b *testing.B) {
+	var tl TrackList
+	for i := 0; i < 50; i++ {
+		tl = append(tl, Track{
+			Artist:          "Phish",
+			Title:           fmt.Sprintf("Song %d", i),
+			PerformanceTime: mustParseDate("1997-11-22"),
+			StartTime:       time.Now().Add(-time.Duration(i) * 5 * time.Minute),
+		})
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = tl.table(nil, defaultTableLayout)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	ansiReverse = "\x1b[7m"
+
+	// Switching to the terminal's alternate screen keeps the full screen
+	// display out of the scrollback, and restores what was there on exit.
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	exitAltScreen  = "\x1b[?25h\x1b[?1049l"
+
+	// tuiMinSideWidth is the narrowest the terminal can be for the setlist
+	// pane to be shown beside the current track.
+	tuiMinSideWidth = 70
+)
+
+// showSetlist is the original setlist of the show a track was performed
+// at, from the show's best rated source on Relisten.
+type showSetlist struct {
+	Artist string
+	Date   time.Time
+	Venue  string
+	Source relisten.Source
+}
+
+// showKey identifies the show a track was performed at, if any.
+func showKey(t Track) string {
+	if t.Artist == "" || t.PerformanceTime.IsZero() {
+		return ""
+	}
+	return t.Artist + " " + t.PerformanceTime.Format("2006-01-02")
+}
+
+// lookupShowSetlist fetches the setlist of the show that t was performed
+// at. It returns nil if the show is not available on Relisten.
+func lookupShowSetlist(ctx context.Context, rc *relisten.Client, artists map[string]string, t Track) (*showSetlist, error) {
+	slug, ok := artists[t.Artist]
+	if !ok || t.PerformanceTime.IsZero() {
+		return nil, nil
+	}
+	show, err := rc.Show(ctx, slug, t.PerformanceTime)
+	if err != nil {
+		return nil, err
+	}
+	source, ok := bestSource(show)
+	if !ok {
+		return nil, nil
+	}
+	s := &showSetlist{Artist: t.Artist, Date: t.PerformanceTime, Source: source}
+	if show.Venue != nil {
+		s.Venue = strings.TrimSuffix(show.Venue.Name+", "+show.Venue.Location, ", ")
+	}
+	return s, nil
+}
+
+// tuiLine is a line of the display, with the ANSI style it is shown in
+// when color is enabled.
+type tuiLine struct {
+	text  string
+	style string
+
+	// short is shown instead of text when text does not fit, as a link's
+	// URL is without its label, rather than cutting the URL short.
+	short string
+}
+
+// tuiView is what ph tui displays: the current track and recent history,
+// and beside them, when the track is from a show on Relisten, the show's
+// setlist with the airing song highlighted.
+type tuiView struct {
+	status  statusResponseBody
+	have    bool
+	err     error
+	setlist *showSetlist
+	color   bool
+
+	// cfg has the output settings the view is shown with.
+	cfg config
+}
+
+// trackLines describes the current track and the tracks aired before it.
+func (v tuiView) trackLines(now time.Time) []tuiLine {
+	if !v.have {
+		return []tuiLine{{text: "Checking the station..."}}
+	}
+	current := v.status.CurrentTrack
+	lines := []tuiLine{{text: trackSummary(current), style: ansiBold}}
+	if !current.StartTime.IsZero() {
+		lines = append(lines, tuiLine{text: "Started " + v.cfg.elapsedFormat().started(now.Sub(current.StartTime))})
+	}
+	for _, l := range current.Links(v.cfg, relistenArtists) {
+		lines = append(lines, tuiLine{text: l.String(), style: ansiCyan, short: l.URL})
+	}
+	history := v.status.History
+	if len(history) > 0 && history[0].Key().Song() == current.Key().Song() {
+		history = history[1:]
+	}
+	if len(history) > 0 {
+		lines = append(lines, tuiLine{}, tuiLine{text: "Recently played", style: ansiBold})
+		for i, t := range history {
+			lines = append(lines, tuiLine{text: fmt.Sprintf("%2d. %s", i+1, trackSummary(t))})
+		}
+	}
+	return lines
+}
+
+// setlistLines renders the setlist, returning the index of the line of the
+// airing song, or -1 if it was not found in the setlist.
+func (v tuiView) setlistLines() ([]tuiLine, int) {
+	s := v.setlist
+	lines := []tuiLine{{text: s.Artist + " " + s.Date.Format("2006-01-02"), style: ansiBold}}
+	if s.Venue != "" {
+		lines = append(lines, tuiLine{text: s.Venue})
+	}
+	var (
+		current     = -1
+		set, i, ok  = findSourceTrack(s.Source, v.status.CurrentTrack.Title)
+		sameShow    = showKey(v.status.CurrentTrack) == s.Artist+" "+s.Date.Format("2006-01-02")
+		highlighted = ok && sameShow
+	)
+	for si, st := range s.Source.Sets {
+		lines = append(lines, tuiLine{}, tuiLine{text: setName(st), style: ansiBold})
+		for ti, t := range st.Tracks {
+			line := tuiLine{text: fmt.Sprintf("  %2d. %s", ti+1, t.Title)}
+			if highlighted && si == set && ti == i {
+				line = tuiLine{text: fmt.Sprintf("▶ %2d. %s", ti+1, t.Title), style: ansiReverse}
+				current = len(lines)
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines, current
+}
+
+// trackLength returns the length of the airing song in the original show,
+// if the song was found in the show's setlist.
+func (v tuiView) trackLength() (time.Duration, bool) {
+	s := v.setlist
+	if s == nil || showKey(v.status.CurrentTrack) != s.Artist+" "+s.Date.Format("2006-01-02") {
+		return 0, false
+	}
+	set, i, ok := findSourceTrack(s.Source, v.status.CurrentTrack.Title)
+	if !ok {
+		return 0, false
+	}
+	length := s.Source.Sets[set].Tracks[i].Length()
+	return length, length > 0
+}
+
+// renderMini draws the view in one line, or in two if there is room: the
+// track, and how far into it the station is. The progress is only known
+// when the song was found in the original show on Relisten, which gives
+// its length.
+func (v tuiView) renderMini(width, height int, now time.Time) string {
+	if !v.have {
+		return fitWidth("Checking the station...", width)
+	}
+	var (
+		current = v.status.CurrentTrack
+		track   = fitWidth(trackSummary(current), width)
+		elapsed time.Duration
+	)
+	if v.color {
+		track = ansiBold + track + ansiReset
+	}
+	if current.StartTime.IsZero() {
+		return track
+	}
+	if elapsed = now.Sub(current.StartTime); elapsed < 0 {
+		elapsed = 0
+	}
+	progress := clockDuration(elapsed)
+	length, ok := v.trackLength()
+	if ok {
+		progress += " / " + clockDuration(length)
+	}
+	if height < 2 {
+		return fitWidth(trackSummary(current)+"  "+progress, width)
+	}
+	if barWidth := width - len(progress) - 1; ok && barWidth >= 10 {
+		if elapsed > length {
+			elapsed = length
+		}
+		bar := textBar(int(elapsed/time.Second), int(length/time.Second), barWidth)
+		progress += " " + bar + strings.Repeat("░", barWidth-len([]rune(bar)))
+	}
+	return track + "\n" + fitWidth(progress, width)
+}
+
+// clockDuration formats d as a clock reading, such as 3:07 or 1:02:07.
+func clockDuration(d time.Duration) string {
+	secs := int(d / time.Second)
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+// render draws the view to fit a terminal of the given size.
+func (v tuiView) render(width, height int, now time.Time) string {
+	left := v.trackLines(now)
+	if v.err != nil {
+		left = append(left, tuiLine{}, tuiLine{text: "Unable to check the station: " + v.err.Error()})
+	}
+	if v.err == nil && v.status.Stale != nil {
+		left = append(left, tuiLine{}, tuiLine{text: v.status.Stale.String()})
+	}
+	var (
+		right     []tuiLine
+		highlight = -1
+		leftWidth = width
+		sideWidth int
+	)
+	if v.setlist != nil && width >= tuiMinSideWidth {
+		right, highlight = v.setlistLines()
+		sideWidth = width * 2 / 5
+		leftWidth = width - sideWidth - 3
+		// Scroll the setlist so the airing song is in view.
+		if len(right) > height && highlight >= height {
+			start := highlight - height/3
+			if start > len(right)-height {
+				start = len(right) - height
+			}
+			right = right[start:]
+		}
+	}
+
+	rows := make([]string, 0, height)
+	for i := 0; i < height; i++ {
+		var row strings.Builder
+		var l tuiLine
+		if i < len(left) {
+			l = left[i]
+		}
+		row.WriteString(v.style(l, leftWidth, sideWidth > 0))
+		if sideWidth > 0 {
+			var r tuiLine
+			if i < len(right) {
+				r = right[i]
+			}
+			row.WriteString(" │ " + v.style(r, sideWidth, false))
+		}
+		rows = append(rows, strings.TrimRight(row.String(), " "))
+	}
+	return strings.Join(rows, "\n")
+}
+
+// style fits a line to width, padding it if pad is set, and styles it if
+// color is enabled.
+func (v tuiView) style(l tuiLine, width int, pad bool) string {
+	text := l.text
+	if l.short != "" && len([]rune(text)) > width {
+		text = l.short
+	}
+	text = fitWidth(text, width)
+	if pad {
+		text += strings.Repeat(" ", width-len([]rune(text)))
+	}
+	if !v.color || l.style == "" || l.text == "" {
+		return text
+	}
+	return l.style + text + ansiReset
+}
+
+// fitWidth truncates s to at most width characters, marking truncation
+// with an ellipsis.
+func fitWidth(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// terminalSize returns the width and height of the terminal, falling back
+// to the COLUMNS and LINES environment variables, and then to 80x24.
+func terminalSize() (int, int) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	if out, err := cmd.Output(); err == nil {
+		var height, width int
+		if _, err := fmt.Sscan(string(out), &height, &width); err == nil && width > 0 && height > 0 {
+			return width, height
+		}
+	}
+	width, height := 80, 24
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		width = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		height = n
+	}
+	return width, height
+}
+
+// runTUI shows a full screen, continuously updated view of the station
+// until interrupted. With --mini, the view is just a line or two, for a
+// small terminal pane.
+func runTUI(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var (
+		fs       = flag.NewFlagSet("tui", flag.ExitOnError)
+		interval = fs.Duration("interval", 15*time.Second, "How often to check the station")
+		mini     = fs.Bool("mini", false, "Show just the track and its progress, in a line or two")
+	)
+	fs.Parse(args)
+
+	rc := newRelistenClient(cfg)
+	relistenArtists, err = relistenGetArtists(rc, cfg)
+	if err != nil {
+		warn(fmt.Errorf("unable to get Relisten artists: %w", err))
+	}
+	var catalog songCatalog
+	if cfg.Catalog.Autocorrect {
+		catalog = loadSongCatalog(context.Background(), apiClient(cfg), cfg)
+	}
+
+	var (
+		b      = &bus{}
+		events = b.subscribe(1)
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &poller{
+		radioCo:  newRadioCoClient(httpClient(cfg), cfg),
+		station:  cfg.station(),
+		interval: *interval,
+		bus:      b,
+	}
+	go func() {
+		p.run(ctx)
+		b.close()
+	}()
+
+	type setlistResult struct {
+		key     string
+		setlist *showSetlist
+	}
+	var (
+		view     = tuiView{color: !cfg.NoColor && os.Getenv("TERM") != "dumb", cfg: cfg}
+		setlists = make(chan setlistResult, 1)
+		showing  string
+		ticker   = time.NewTicker(time.Second)
+		signals  = make(chan os.Signal, 1)
+	)
+	defer ticker.Stop()
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	fmt.Print(enterAltScreen)
+	defer fmt.Print(exitAltScreen)
+	draw := func() {
+		width, height := terminalSize()
+		if *mini {
+			fmt.Print(clearScreen + view.renderMini(width, height, time.Now()))
+			return
+		}
+		fmt.Print(clearScreen + view.render(width, height, time.Now()))
+	}
+	draw()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			switch e := e.(type) {
+			case statusFetched:
+				status := e.Status
+				if catalog != nil {
+					status.CurrentTrack = catalog.correct(status.CurrentTrack)
+					status.History = catalog.correctAll(status.History)
+				}
+				view.status, view.have, view.err = status, true, nil
+				// Fetch the setlist when a new show starts airing; the
+				// previous show's setlist stays until then.
+				if key := showKey(status.CurrentTrack); key != "" && key != showing {
+					showing = key
+					go func(t Track) {
+						s, err := lookupShowSetlist(ctx, rc, relistenArtists, t)
+						if err != nil {
+							warn(fmt.Errorf("unable to get setlist: %w", err))
+						}
+						setlists <- setlistResult{key: key, setlist: s}
+					}(status.CurrentTrack)
+				} else if key == "" {
+					showing, view.setlist = "", nil
+				}
+			case statusFailed:
+				view.err = e.Err
+			}
+		case r := <-setlists:
+			if r.key == showing {
+				view.setlist = r.setlist
+			}
+		case <-ticker.C:
+		case <-signals:
+			return nil
+		}
+		draw()
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ianfoo/ph/relisten"
+)
+
+func TestTUIView_Render(t *testing.T) {
+	relistenArtists = goldenRelistenArtists(t)
+	var (
+		hampton = mustParseDate("1997-11-22")
+		reba    = Track{Artist: "Phish", Title: "Reba", PerformanceTime: hampton, StartTime: mustParseDate("2020-06-05T20:00:00")}
+		setlist = &showSetlist{
+			Artist: "Phish",
+			Date:   hampton,
+			Venue:  "Hampton Coliseum, Hampton, VA",
+			Source: relisten.Source{Sets: []relisten.Set{
+				{Index: 0, Tracks: []relisten.Track{{Title: "Mike's Song"}, {Title: "Reba"}}},
+				{Index: 1, IsEncore: true, Tracks: []relisten.Track{{Title: "Possum"}}},
+			}},
+		}
+		view = tuiView{
+			status:  statusResponseBody{CurrentTrack: reba, History: TrackList{reba, {Artist: "Cream", Title: "Crossroads"}}},
+			have:    true,
+			setlist: setlist,
+		}
+		now = mustParseDate("2020-06-05T20:03:00")
+	)
+
+	got := view.render(80, 10, now)
+	want := strings.Join([]string{
+		"Phish - Reba (Sat 22-Nov-1997)                │ Phish 1997-11-22",
+		"Started 3m ago                                │ Hampton Coliseum, Hampton, VA",
+		"Stream: https://relisten.net/phish/1997/11/22 │",
+		"https://phish.net/setlists/?d=1997-11-22      │ Set 1",
+		"                                              │    1. Mike's Song",
+		"Recently played                               │ ▶  2. Reba",
+		" 1. Cream - Crossroads                        │",
+		"                                              │ Encore",
+		"                                              │    1. Possum",
+		"                                              │",
+	}, "\n")
+	if got != want {
+		t.Errorf("wanted\n%s\nbut got\n%s", want, got)
+	}
+
+	// Without room for the setlist, only the current track is shown.
+	got = view.render(40, 2, now)
+	want = "Phish - Reba (Sat 22-Nov-1997)\nStarted 3m ago"
+	if got != want {
+		t.Errorf("wanted\n%s\nbut got\n%s", want, got)
+	}
+}
+
+func TestTUIView_SetlistScroll(t *testing.T) {
+	var tracks []relisten.Track
+	for i := 1; i <= 20; i++ {
+		tracks = append(tracks, relisten.Track{Title: "Song " + string(rune('A'+i-1))})
+	}
+	view := tuiView{
+		status: statusResponseBody{CurrentTrack: Track{Artist: "Phish", Title: "Song T", PerformanceTime: mustParseDate("1997-11-22")}},
+		have:   true,
+		setlist: &showSetlist{
+			Artist: "Phish",
+			Date:   mustParseDate("1997-11-22"),
+			Source: relisten.Source{Sets: []relisten.Set{{Tracks: tracks}}},
+		},
+	}
+	if got := view.render(80, 8, time.Time{}); !strings.Contains(got, "▶ 20. Song T") {
+		t.Errorf("wanted the airing song to be scrolled into view, but got\n%s", got)
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	tt := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{s: "Reba", width: 10, want: "Reba"},
+		{s: "Mercury>thru>Death Don't", width: 10, want: "Mercury>t…"},
+		{s: "Déjà vu", width: 5, want: "Déjà…"},
+		{s: "Reba", width: 0, want: ""},
+	}
+	for _, tc := range tt {
+		if got := fitWidth(tc.s, tc.width); got != tc.want {
+			t.Errorf("fitWidth(%q, %d): wanted %q, but got %q", tc.s, tc.width, tc.want, got)
+		}
+	}
+}
+
+func TestTUIView_RenderMini(t *testing.T) {
+	var (
+		hampton = mustParseDate("1997-11-22")
+		reba    = Track{Artist: "Phish", Title: "Reba", PerformanceTime: hampton, StartTime: mustParseDate("2020-06-05T20:00:00")}
+		setlist = &showSetlist{
+			Artist: "Phish",
+			Date:   hampton,
+			Source: relisten.Source{Sets: []relisten.Set{
+				{Tracks: []relisten.Track{{Title: "Reba", Duration: 800}}},
+			}},
+		}
+		now = mustParseDate("2020-06-05T20:03:20")
+	)
+	tt := []struct {
+		desc   string
+		view   tuiView
+		width  int
+		height int
+		want   string
+	}{
+		{
+			desc:   "before first status",
+			view:   tuiView{},
+			width:  40,
+			height: 2,
+			want:   "Checking the station...",
+		},
+		{
+			desc:   "progress bar",
+			view:   tuiView{status: statusResponseBody{CurrentTrack: reba}, have: true, setlist: setlist},
+			width:  36,
+			height: 2,
+			want:   "Phish - Reba (Sat 22-Nov-1997)\n3:20 / 13:20 █████░░░░░░░░░░░░░░░░░░",
+		},
+		{
+			desc:   "one line",
+			view:   tuiView{status: statusResponseBody{CurrentTrack: reba}, have: true, setlist: setlist},
+			width:  80,
+			height: 1,
+			want:   "Phish - Reba (Sat 22-Nov-1997)  3:20 / 13:20",
+		},
+		{
+			desc:   "length unknown",
+			view:   tuiView{status: statusResponseBody{CurrentTrack: reba}, have: true},
+			width:  80,
+			height: 2,
+			want:   "Phish - Reba (Sat 22-Nov-1997)\n3:20",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := tc.view.renderMini(tc.width, tc.height, now); got != tc.want {
+				t.Errorf("wanted\n%q\nbut got\n%q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestClockDuration(t *testing.T) {
+	tt := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 7 * time.Second, want: "0:07"},
+		{d: 3*time.Minute + 7*time.Second, want: "3:07"},
+		{d: time.Hour + 2*time.Minute + 7*time.Second, want: "1:02:07"},
+	}
+	for _, tc := range tt {
+		if got := clockDuration(tc.d); got != tc.want {
+			t.Errorf("wanted %q, but got %q", tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// warn receives warnings: problems that do not stop ph from doing what it
+// was asked, but that may be worth knowing about, such as a performance
+// date in a track title that does not parse. On the command line they are
+// logged to standard error; ph serve routes them to its structured log,
+// and tests may collect them.
+var warn = logWarning
+
+// logWarning logs a warning to standard error.
+func logWarning(err error) {
+	log.Printf("warning: %v", err)
+}
+
+// parseWarning is a part of a track from radio.co that could not be
+// parsed, and was left out of the track.
+type parseWarning struct {
+	// Title is the track's title as broadcast.
+	Title string
+
+	// Field is the field of the track left out, named as in JSON, and
+	// Value what could not be parsed for it.
+	Field string
+	Value string
+
+	Err error
+}
+
+func (w *parseWarning) Error() string {
+	return fmt.Sprintf("track %q: invalid %s %q: %v", w.Title, w.Field, w.Value, w.Err)
+}
+
+func (w *parseWarning) Unwrap() error {
+	return w.Err
+}
+
+// warning logs a warning, with the details of a parseWarning as fields.
+func (l *structuredLogger) warning(err error) {
+	var pw *parseWarning
+	if errors.As(err, &pw) {
+		l.Warn("unable to parse track", "title", pw.Title, "field", pw.Field, "value", pw.Value, "error", pw.Err)
+		return
+	}
+	l.Warn(err.Error())
+}
+
+// onceWarner passes each distinct warning to warn only the first time it is
+// seen, so that a warning about a track in the station's history is not
+// repeated each time the history is fetched. It remembers up to
+// maxWarningsSeen warnings, and starts over once it has seen that many.
+type onceWarner struct {
+	warn func(error)
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+const maxWarningsSeen = 1000
+
+func (w *onceWarner) warning(err error) {
+	w.mu.Lock()
+	msg := err.Error()
+	if w.seen == nil || len(w.seen) >= maxWarningsSeen {
+		w.seen = make(map[string]bool)
+	}
+	seen := w.seen[msg]
+	w.seen[msg] = true
+	w.mu.Unlock()
+	if !seen {
+		w.warn(err)
+	}
+}
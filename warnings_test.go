@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatusResponseBody_UnmarshalJSONWarnings(t *testing.T) {
+	payload := `{
+		"status": "online",
+		"current_track": {"title": "Phish - Reba (13-45-97)", "start_time": "2020-05-28T08:01:32+00:00"},
+		"history": [
+			{"title": "Phish - Reba (13-45-97)"},
+			{"title": "Phish - Tweezer (7-18-14)", "start_time": "yesterday"}
+		]
+	}`
+	var status statusResponseBody
+	if err := json.Unmarshal([]byte(payload), &status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := status.CurrentTrack; got.Title != "Reba" || !got.PerformanceTime.IsZero() || got.StartTime.IsZero() {
+		t.Errorf("wanted Reba with a start time and no performance time, but got %+v", got)
+	}
+	if got := status.History[1]; got.Title != "Tweezer" || got.PerformanceTime.IsZero() {
+		t.Errorf("wanted Tweezer with its performance time, but got %+v", got)
+	}
+
+	var fields []string
+	for _, w := range status.Warnings {
+		var pw *parseWarning
+		if !errors.As(w, &pw) {
+			t.Fatalf("wanted a parse warning, but got %v", w)
+		}
+		fields = append(fields, pw.Field+"="+pw.Value)
+	}
+	want := "performance_time=13-45-97 performance_time=13-45-97 start_time=yesterday"
+	if got := strings.Join(fields, " "); got != want {
+		t.Errorf("wanted warnings %s, but got %s", want, got)
+	}
+}
+
+func TestOnceWarner(t *testing.T) {
+	var (
+		got []string
+		w   = &onceWarner{warn: func(err error) { got = append(got, err.Error()) }}
+	)
+	for _, msg := range []string{"one", "two", "one"} {
+		w.warning(errors.New(msg))
+	}
+	if want := "one two"; strings.Join(got, " ") != want {
+		t.Errorf("wanted %q, but got %q", want, strings.Join(got, " "))
+	}
+
+	// Once it has seen as many warnings as it remembers, it starts over.
+	for i := 0; i < maxWarningsSeen; i++ {
+		w.warning(fmt.Errorf("warning %d", i))
+	}
+	got = nil
+	w.warning(errors.New("one"))
+	if len(got) != 1 {
+		t.Errorf("wanted a repeat after starting over, but got %v", got)
+	}
+}
+
+func TestStructuredLogger_Warning(t *testing.T) {
+	var b bytes.Buffer
+	logger, err := newStructuredLogger(&b, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger.warning(&parseWarning{Title: "Phish - Reba (13-45-97)", Field: "performance_time", Value: "13-45-97", Err: errors.New("month out of range")})
+	var entry map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &entry); err != nil {
+		t.Fatalf("unable to parse log entry %q: %v", b.String(), err)
+	}
+	if entry["field"] != "performance_time" || entry["value"] != "13-45-97" {
+		t.Errorf("wanted the warning's details as fields, but got %v", entry)
+	}
+}
+
+// TestWarningsGoThroughWarn checks that warnings are passed to warn, so that
+// ph serve logs every one of them in its structured log, rather than being
+// logged directly.
+func TestWarningsGoThroughWarn(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") || f == "warnings.go" {
+			continue
+		}
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, line := range strings.Split(string(b), "\n") {
+			if strings.Contains(line, `"warning: `) {
+				t.Errorf("%s:%d: pass the warning to warn instead of logging it: %s", f, i+1, strings.TrimSpace(line))
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// widgetVersion is the version of the widget format. The format is stable:
+// within a version, fields are only ever added, never renamed, removed, or
+// given different meanings, so that home screen widgets built on it keep
+// working across releases of ph.
+const widgetVersion = 1
+
+// What the station is doing, as in widget.Status.
+const (
+	widgetPlaying = "playing"
+	widgetIdle    = "idle"
+	widgetOffline = "offline"
+)
+
+// widget is what is playing, laid out for home screen widget tools, such
+// as Scriptable on iOS and KWGT on Android: ready-made lines of text, an
+// image, and links to open when tapped, with nothing left to format.
+type widget struct {
+	Version int    `json:"version"`
+	Status  string `json:"status"`
+
+	// Line1 is the title, Line2 the artist, and Line3 when and where the
+	// track was performed, when it is from a live show. Lines a track
+	// does not have are empty.
+	Line1 string `json:"line1"`
+	Line2 string `json:"line2"`
+	Line3 string `json:"line3"`
+
+	// ArtworkURL is the track's cover art, or else the station's logo.
+	ArtworkURL string `json:"artwork_url,omitempty"`
+
+	StartedAt time.Time   `json:"started_at,omitempty"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Links     widgetLinks `json:"links"`
+}
+
+// widgetLinks are the links a widget can open.
+type widgetLinks struct {
+	// Listen is the station's audio stream.
+	Listen  string `json:"listen,omitempty"`
+	Stream  string `json:"stream,omitempty"`
+	Setlist string `json:"setlist,omitempty"`
+}
+
+// newWidget lays out the track status says is playing, with links, which
+// are the track's links, and listen, the station's stream, as of now.
+func newWidget(status statusResponseBody, links []Link, listen string, now time.Time) widget {
+	t := status.CurrentTrack
+	w := widget{
+		Version:    widgetVersion,
+		Status:     widgetPlaying,
+		ArtworkURL: t.ArtworkURL,
+		UpdatedAt:  now,
+		Links:      widgetLinks{Listen: listen},
+	}
+	if w.ArtworkURL == "" {
+		w.ArtworkURL = status.stationInfo.LogoURL
+	}
+	switch {
+	case status.Status == "offline":
+		w.Status, w.Line1 = widgetOffline, tr("Off the air")
+		return w
+	case t.Title == "":
+		w.Status, w.Line1 = widgetIdle, tr("Nothing is playing.")
+		return w
+	}
+	w.Line1, w.Line2, w.StartedAt = t.Title, t.Artist, t.StartTime
+	if pt := t.PerformanceTime; !pt.IsZero() {
+		w.Line3 = pt.Format("Mon 2-Jan-2006")
+		if t.Location != "" {
+			w.Line3 = fmt.Sprintf("%s · %s", w.Line3, t.Location)
+		}
+	}
+	w.Links.Stream = linkURL(links, linkKindStream)
+	w.Links.Setlist = linkURL(links, linkKindSetlist)
+	return w
+}
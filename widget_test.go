@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewWidget(t *testing.T) {
+	var (
+		now    = mustParseDate("2020-06-05T20:05:00")
+		listen = "https://stream.radio.co/sd71de59b3/listen"
+		reba   = Track{
+			Artist:          "Phish",
+			Title:           "Reba",
+			PerformanceTime: mustParseDate("1997-11-22"),
+			Location:        "Hampton, VA",
+			StartTime:       mustParseDate("2020-06-05T20:00:00"),
+			ArtworkURL:      "https://images.radio.co/reba.jpg",
+		}
+		links = []Link{
+			{Kind: linkKindStream, Service: "Relisten", URL: "https://relisten.net/phish/1997/11/22"},
+			{Kind: linkKindSetlist, Service: "phish.net", URL: "https://phish.net/setlists/?d=1997-11-22"},
+		}
+	)
+	tt := []struct {
+		desc   string
+		status statusResponseBody
+		links  []Link
+		want   widget
+	}{
+		{
+			desc:   "live track",
+			status: statusResponseBody{Status: "online", CurrentTrack: reba},
+			links:  links,
+			want: widget{
+				Version:    widgetVersion,
+				Status:     widgetPlaying,
+				Line1:      "Reba",
+				Line2:      "Phish",
+				Line3:      "Sat 22-Nov-1997 · Hampton, VA",
+				ArtworkURL: "https://images.radio.co/reba.jpg",
+				StartedAt:  reba.StartTime,
+				UpdatedAt:  now,
+				Links: widgetLinks{
+					Listen:  listen,
+					Stream:  "https://relisten.net/phish/1997/11/22",
+					Setlist: "https://phish.net/setlists/?d=1997-11-22",
+				},
+			},
+		},
+		{
+			desc:   "studio track",
+			status: statusResponseBody{Status: "online", CurrentTrack: Track{Artist: "Cream", Title: "Crossroads"}},
+			want: widget{
+				Version:   widgetVersion,
+				Status:    widgetPlaying,
+				Line1:     "Crossroads",
+				Line2:     "Cream",
+				UpdatedAt: now,
+				Links:     widgetLinks{Listen: listen},
+			},
+		},
+		{
+			desc:   "offline",
+			status: statusResponseBody{Status: "offline", CurrentTrack: reba},
+			links:  links,
+			want: widget{
+				Version:    widgetVersion,
+				Status:     widgetOffline,
+				Line1:      "Off the air",
+				ArtworkURL: "https://images.radio.co/reba.jpg",
+				UpdatedAt:  now,
+				Links:      widgetLinks{Listen: listen},
+			},
+		},
+		{
+			desc:   "nothing playing",
+			status: statusResponseBody{Status: "online"},
+			want: widget{
+				Version:   widgetVersion,
+				Status:    widgetIdle,
+				Line1:     "Nothing is playing.",
+				UpdatedAt: now,
+				Links:     widgetLinks{Listen: listen},
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := newWidget(tc.status, tc.links, listen, now)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted %+v, but got %+v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// wrapped is a year in review of the plays recorded in the play history.
+type wrapped struct {
+	Year       int
+	Plays      int
+	Listening  time.Duration
+	TopArtists []tally
+	TopSongs   []tally
+	Months     []tally
+
+	// LongestJam is the longest single song aired, as inferred from when
+	// the next play began.
+	LongestJam       *Track
+	LongestJamLength time.Duration
+
+	// MostAiredShow is the show that tracks were aired from most often.
+	MostAiredShow tally
+
+	// BustOut is the song that aired after the longest wait since it last
+	// aired.
+	BustOut    *Track
+	BustOutGap time.Duration
+}
+
+// buildWrapped summarizes the plays of the given year. Plays from earlier
+// years are only used to find bust-outs.
+func buildWrapped(plays TrackList, year int) wrapped {
+	var (
+		w        = wrapped{Year: year}
+		from     = time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+		to       = from.AddDate(1, 0, 0)
+		d        = buildDigest(plays, from, to, 10)
+		shows    = make(map[string]int)
+		lastAir  = make(map[string]time.Time)
+		perMonth = make([]int, 12)
+	)
+	w.Plays, w.Listening, w.TopArtists, w.TopSongs = d.Plays, d.Listening, d.TopArtists, d.TopSongs
+	for i, t := range plays {
+		if t.IsStationBreak() || !t.StartTime.Before(to) {
+			continue
+		}
+		song := trackSummary(Track{Artist: t.Artist, Title: t.Title})
+		prev, aired := lastAir[song]
+		lastAir[song] = t.StartTime
+		if t.StartTime.Before(from) {
+			continue
+		}
+		perMonth[t.StartTime.In(time.Local).Month()-1]++
+		if pt := t.PerformanceTime; !pt.IsZero() {
+			shows[strings.TrimSpace(t.Artist+" "+pt.Format("Mon 2-Jan-2006"))]++
+		}
+		if t.IsFullShow() {
+			continue
+		}
+		if l := playDuration(plays, i, maxPlayLength, 0); l > w.LongestJamLength {
+			t := t
+			w.LongestJam, w.LongestJamLength = &t, l
+		}
+		if gap := t.StartTime.Sub(prev); aired && gap > w.BustOutGap {
+			t := t
+			w.BustOut, w.BustOutGap = &t, gap
+		}
+	}
+	if top := topTallies(shows, 1); len(top) > 0 {
+		w.MostAiredShow = top[0]
+	}
+	for m, n := range perMonth {
+		w.Months = append(w.Months, tally{Name: time.Month(m + 1).String()[:3], Count: n})
+	}
+	return w
+}
+
+// maxMonth returns the most plays in any month, for scaling charts.
+func (w wrapped) maxMonth() int {
+	max := 0
+	for _, m := range w.Months {
+		if m.Count > max {
+			max = m.Count
+		}
+	}
+	return max
+}
+
+// days formats a duration as a whole number of days.
+func days(d time.Duration) string {
+	n := int(d.Hours() / 24)
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
+
+// superlatives returns a line describing each superlative that was found.
+func (w wrapped) superlatives() []string {
+	var lines []string
+	if w.LongestJam != nil {
+		lines = append(lines, fmt.Sprintf("Longest jam aired: %s, %s",
+			trackSummary(*w.LongestJam), w.LongestJamLength.Round(time.Minute)))
+	}
+	if w.MostAiredShow.Count > 1 {
+		lines = append(lines, fmt.Sprintf("Most-aired show: %s, %d plays",
+			w.MostAiredShow.Name, w.MostAiredShow.Count))
+	}
+	if w.BustOut != nil {
+		lines = append(lines, fmt.Sprintf("Biggest bust-out: %s, after %s",
+			trackSummary(Track{Artist: w.BustOut.Artist, Title: w.BustOut.Title}), days(w.BustOutGap)))
+	}
+	return lines
+}
+
+// markdown renders the year in review as Markdown, with a bar chart of plays
+// per month.
+func (w wrapped) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# JEMP Radio %d Wrapped\n\n", w.Year)
+	fmt.Fprintf(&b, "%d plays, %.0f hours of music.\n", w.Plays, w.Listening.Hours())
+	if s := w.superlatives(); len(s) > 0 {
+		b.WriteString("\n## Superlatives\n\n")
+		for _, line := range s {
+			fmt.Fprintf(&b, "- %s\n", line)
+		}
+	}
+	list := func(heading string, tallies []tally) {
+		if len(tallies) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", heading)
+		for i, t := range tallies {
+			fmt.Fprintf(&b, "%d. %s (%d)\n", i+1, t.Name, t.Count)
+		}
+	}
+	list("Top artists", w.TopArtists)
+	list("Top songs", w.TopSongs)
+	b.WriteString("\n## Plays by month\n\n```\n")
+	writeBarChart(&b, w.Months, 40)
+	b.WriteString("```\n")
+	return b.String()
+}
+
+var wrappedHTML = template.Must(template.New("wrapped").Funcs(template.FuncMap{
+	"percent": func(n, max int) int {
+		if max == 0 {
+			return 0
+		}
+		return n * 100 / max
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>JEMP Radio {{.Year}} Wrapped</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; }
+.bar { background: #c0392b; height: 1em; }
+td { padding: 0.1em 0.5em; }
+</style>
+</head>
+<body>
+<h1>JEMP Radio {{.Year}} Wrapped</h1>
+<p>{{.Plays}} plays, {{printf "%.0f" .Listening.Hours}} hours of music.</p>
+{{with .Superlatives}}<h2>Superlatives</h2>
+<ul>
+{{range .}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{with .TopArtists}}<h2>Top artists</h2>
+<ol>
+{{range .}}<li>{{.Name}} ({{.Count}})</li>
+{{end}}</ol>
+{{end}}{{with .TopSongs}}<h2>Top songs</h2>
+<ol>
+{{range .}}<li>{{.Name}} ({{.Count}})</li>
+{{end}}</ol>
+{{end}}<h2>Plays by month</h2>
+<table>
+{{$max := .MaxMonth}}{{range .Months}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td style="width: 30em"><div class="bar" style="width: {{percent .Count $max}}%"></div></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writeHTML renders the year in review as a standalone HTML page, with a bar
+// chart of plays per month.
+func (w wrapped) writeHTML(out io.Writer) error {
+	return wrappedHTML.Execute(out, struct {
+		wrapped
+		Superlatives []string
+		MaxMonth     int
+	}{w, w.superlatives(), w.maxMonth()})
+}
+
+// runWrapped writes a year in review of the play history.
+func runWrapped(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("wrapped", flag.ExitOnError)
+		format = fs.StringP("format", "f", "markdown", "Output format: markdown or html")
+		output = fs.StringP("output", "o", "-", "File to write the report to, or - for standard output")
+	)
+	fs.Parse(args)
+	if *format != "markdown" && *format != "html" {
+		return fmt.Errorf("invalid format %q: must be markdown or html", *format)
+	}
+	year := time.Now().Year()
+	if fs.NArg() > 0 {
+		y, err := strconv.Atoi(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid year %q", fs.Arg(0))
+		}
+		year = y
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	plays, err := st.plays()
+	if err != nil {
+		return fmt.Errorf("read play history: %w", err)
+	}
+	if cfg.Catalog.Autocorrect {
+		plays = loadSongCatalog(context.Background(), apiClient(cfg), cfg).correctAll(plays)
+	}
+	w := buildWrapped(plays, year)
+	if w.Plays == 0 {
+		return fmt.Errorf("no plays recorded in %d", year)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if *format == "html" {
+		return w.writeHTML(out)
+	}
+	_, err = io.WriteString(out, w.markdown())
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildWrapped(t *testing.T) {
+	plays := TrackList{
+		{Artist: "Phish", Title: "Fluffhead", StartTime: mustParseDate("2023-03-01T20:00:00")},
+		{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2023-09-01T20:15:00")},
+		{Artist: "Phish", Title: "Fluffhead", StartTime: mustParseDate("2024-02-01T20:00:00")},
+		{Artist: "Phish", Title: "Tweezer", StartTime: mustParseDate("2024-02-01T20:10:00"), PerformanceTime: mustParseDate("1995-12-31")},
+		{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2024-02-01T20:45:00"), PerformanceTime: mustParseDate("1995-12-31")},
+		{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2024-06-01T20:00:00")},
+		{Artist: "Goose", Title: "Arcadia", StartTime: mustParseDate("2024-06-01T20:20:00")},
+		{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2025-01-01T20:00:00")},
+	}
+	w := buildWrapped(plays, 2024)
+	if w.Plays != 5 {
+		t.Errorf("wanted 5 plays, but got %d", w.Plays)
+	}
+	if w.LongestJam == nil || w.LongestJam.Title != "Tweezer" || w.LongestJamLength != 35*time.Minute {
+		t.Errorf("wanted a 35m Tweezer as the longest jam, but got %v (%v)", w.LongestJam, w.LongestJamLength)
+	}
+	if want := (tally{"Phish Sun 31-Dec-1995", 2}); w.MostAiredShow != want {
+		t.Errorf("wanted most-aired show %v, but got %v", want, w.MostAiredShow)
+	}
+	// Fluffhead went 11 months without airing; Reba only went 5 months.
+	if w.BustOut == nil || w.BustOut.Title != "Fluffhead" {
+		t.Errorf("wanted Fluffhead as the biggest bust-out, but got %v", w.BustOut)
+	}
+	if feb, jun := w.Months[1].Count, w.Months[5].Count; feb != 3 || jun != 2 {
+		t.Errorf("wanted 3 plays in February and 2 in June, but got %d and %d", feb, jun)
+	}
+}
+
+func TestWrapped_Render(t *testing.T) {
+	w := buildWrapped(TrackList{
+		{Artist: "Phish", Title: "Reba", StartTime: mustParseDate("2024-02-01T20:00:00")},
+		{Artist: "Phish", Title: "Tweezer", StartTime: mustParseDate("2024-02-01T20:15:00")},
+	}, 2024)
+	md := w.markdown()
+	for _, want := range []string{"# JEMP Radio 2024 Wrapped", "Longest jam aired: Phish - Reba, 15m0s", "Feb     2 ████"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("wanted markdown to contain %q, but got:\n%s", want, md)
+		}
+	}
+	var b bytes.Buffer
+	if err := w.writeHTML(&b); err != nil {
+		t.Fatalf("unexpected error rendering HTML: %v", err)
+	}
+	for _, want := range []string{"<h1>JEMP Radio 2024 Wrapped</h1>", "<li>Phish - Reba (1)</li>", `style="width: 100%"`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("wanted HTML to contain %q, but got:\n%s", want, b.String())
+		}
+	}
+}